@@ -9,6 +9,9 @@ import (
 	"github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/bolt/customtemplate"
 	"github.com/cloudogu/portainer-ce/api/bolt/dockerhub"
+	"github.com/cloudogu/portainer-ce/api/bolt/edgecheckin"
+	"github.com/cloudogu/portainer-ce/api/bolt/edgeenrollmentrule"
+	"github.com/cloudogu/portainer-ce/api/bolt/edgeenrollmenttoken"
 	"github.com/cloudogu/portainer-ce/api/bolt/edgegroup"
 	"github.com/cloudogu/portainer-ce/api/bolt/edgejob"
 	"github.com/cloudogu/portainer-ce/api/bolt/edgestack"
@@ -17,6 +20,9 @@ import (
 	"github.com/cloudogu/portainer-ce/api/bolt/endpointrelation"
 	"github.com/cloudogu/portainer-ce/api/bolt/errors"
 	"github.com/cloudogu/portainer-ce/api/bolt/extension"
+	"github.com/cloudogu/portainer-ce/api/bolt/housekeeping"
+	"github.com/cloudogu/portainer-ce/api/bolt/imagescan"
+	"github.com/cloudogu/portainer-ce/api/bolt/imagestatus"
 	"github.com/cloudogu/portainer-ce/api/bolt/migrator"
 	"github.com/cloudogu/portainer-ce/api/bolt/registry"
 	"github.com/cloudogu/portainer-ce/api/bolt/resourcecontrol"
@@ -27,6 +33,7 @@ import (
 	"github.com/cloudogu/portainer-ce/api/bolt/tag"
 	"github.com/cloudogu/portainer-ce/api/bolt/team"
 	"github.com/cloudogu/portainer-ce/api/bolt/teammembership"
+	"github.com/cloudogu/portainer-ce/api/bolt/templatesource"
 	"github.com/cloudogu/portainer-ce/api/bolt/tunnelserver"
 	"github.com/cloudogu/portainer-ce/api/bolt/user"
 	"github.com/cloudogu/portainer-ce/api/bolt/version"
@@ -41,32 +48,39 @@ const (
 // Store defines the implementation of portainer.DataStore using
 // BoltDB as the storage system.
 type Store struct {
-	path                    string
-	db                      *bolt.DB
-	isNew                   bool
-	fileService             portainer.FileService
-	CustomTemplateService   *customtemplate.Service
-	DockerHubService        *dockerhub.Service
-	EdgeGroupService        *edgegroup.Service
-	EdgeJobService          *edgejob.Service
-	EdgeStackService        *edgestack.Service
-	EndpointGroupService    *endpointgroup.Service
-	EndpointService         *endpoint.Service
-	EndpointRelationService *endpointrelation.Service
-	ExtensionService        *extension.Service
-	RegistryService         *registry.Service
-	ResourceControlService  *resourcecontrol.Service
-	RoleService             *role.Service
-	ScheduleService         *schedule.Service
-	SettingsService         *settings.Service
-	StackService            *stack.Service
-	TagService              *tag.Service
-	TeamMembershipService   *teammembership.Service
-	TeamService             *team.Service
-	TunnelServerService     *tunnelserver.Service
-	UserService             *user.Service
-	VersionService          *version.Service
-	WebhookService          *webhook.Service
+	path                       string
+	db                         *bolt.DB
+	isNew                      bool
+	fileService                portainer.FileService
+	CustomTemplateService      *customtemplate.Service
+	DockerHubService           *dockerhub.Service
+	EdgeCheckinService         *edgecheckin.Service
+	EdgeEnrollmentRuleService  *edgeenrollmentrule.Service
+	EdgeEnrollmentTokenService *edgeenrollmenttoken.Service
+	EdgeGroupService           *edgegroup.Service
+	EdgeJobService             *edgejob.Service
+	EdgeStackService           *edgestack.Service
+	EndpointGroupService       *endpointgroup.Service
+	EndpointService            *endpoint.Service
+	EndpointRelationService    *endpointrelation.Service
+	ExtensionService           *extension.Service
+	HousekeepingService        *housekeeping.Service
+	ImageScanService           *imagescan.Service
+	ImageStatusService         *imagestatus.Service
+	RegistryService            *registry.Service
+	ResourceControlService     *resourcecontrol.Service
+	RoleService                *role.Service
+	ScheduleService            *schedule.Service
+	SettingsService            *settings.Service
+	StackService               *stack.Service
+	TagService                 *tag.Service
+	TeamMembershipService      *teammembership.Service
+	TeamService                *team.Service
+	TemplateSourceService      *templatesource.Service
+	TunnelServerService        *tunnelserver.Service
+	UserService                *user.Service
+	VersionService             *version.Service
+	WebhookService             *webhook.Service
 }
 
 // NewStore initializes a new Store and the associated services
@@ -189,6 +203,24 @@ func (store *Store) initServices() error {
 	}
 	store.EdgeStackService = edgeStackService
 
+	edgeCheckinService, err := edgecheckin.NewService(store.db)
+	if err != nil {
+		return err
+	}
+	store.EdgeCheckinService = edgeCheckinService
+
+	edgeEnrollmentRuleService, err := edgeenrollmentrule.NewService(store.db)
+	if err != nil {
+		return err
+	}
+	store.EdgeEnrollmentRuleService = edgeEnrollmentRuleService
+
+	edgeEnrollmentTokenService, err := edgeenrollmenttoken.NewService(store.db)
+	if err != nil {
+		return err
+	}
+	store.EdgeEnrollmentTokenService = edgeEnrollmentTokenService
+
 	edgeGroupService, err := edgegroup.NewService(store.db)
 	if err != nil {
 		return err
@@ -225,6 +257,24 @@ func (store *Store) initServices() error {
 	}
 	store.ExtensionService = extensionService
 
+	housekeepingService, err := housekeeping.NewService(store.db)
+	if err != nil {
+		return err
+	}
+	store.HousekeepingService = housekeepingService
+
+	imageScanService, err := imagescan.NewService(store.db)
+	if err != nil {
+		return err
+	}
+	store.ImageScanService = imageScanService
+
+	imageStatusService, err := imagestatus.NewService(store.db)
+	if err != nil {
+		return err
+	}
+	store.ImageStatusService = imageStatusService
+
 	registryService, err := registry.NewService(store.db)
 	if err != nil {
 		return err
@@ -267,6 +317,12 @@ func (store *Store) initServices() error {
 	}
 	store.TeamService = teamService
 
+	templateSourceService, err := templatesource.NewService(store.db)
+	if err != nil {
+		return err
+	}
+	store.TemplateSourceService = templateSourceService
+
 	tunnelServerService, err := tunnelserver.NewService(store.db)
 	if err != nil {
 		return err
@@ -310,6 +366,21 @@ func (store *Store) DockerHub() portainer.DockerHubService {
 	return store.DockerHubService
 }
 
+// EdgeCheckin gives access to the EdgeCheckin data management layer
+func (store *Store) EdgeCheckin() portainer.EdgeCheckinService {
+	return store.EdgeCheckinService
+}
+
+// EdgeEnrollmentRule gives access to the EdgeEnrollmentRule data management layer
+func (store *Store) EdgeEnrollmentRule() portainer.EdgeEnrollmentRuleService {
+	return store.EdgeEnrollmentRuleService
+}
+
+// EdgeEnrollmentToken gives access to the EdgeEnrollmentToken data management layer
+func (store *Store) EdgeEnrollmentToken() portainer.EdgeEnrollmentTokenService {
+	return store.EdgeEnrollmentTokenService
+}
+
 // EdgeGroup gives access to the EdgeGroup data management layer
 func (store *Store) EdgeGroup() portainer.EdgeGroupService {
 	return store.EdgeGroupService
@@ -340,6 +411,21 @@ func (store *Store) EndpointRelation() portainer.EndpointRelationService {
 	return store.EndpointRelationService
 }
 
+// HousekeepingPolicy gives access to the HousekeepingPolicy data management layer
+func (store *Store) HousekeepingPolicy() portainer.HousekeepingPolicyService {
+	return store.HousekeepingService
+}
+
+// ImageScan gives access to the ImageScan data management layer
+func (store *Store) ImageScan() portainer.ImageScanService {
+	return store.ImageScanService
+}
+
+// ImageStatus gives access to the ImageStatus data management layer
+func (store *Store) ImageStatus() portainer.ImageStatusService {
+	return store.ImageStatusService
+}
+
 // Registry gives access to the Registry data management layer
 func (store *Store) Registry() portainer.RegistryService {
 	return store.RegistryService
@@ -380,6 +466,11 @@ func (store *Store) Team() portainer.TeamService {
 	return store.TeamService
 }
 
+// TemplateSource gives access to the TemplateSource data management layer
+func (store *Store) TemplateSource() portainer.TemplateSourceService {
+	return store.TemplateSourceService
+}
+
 // TunnelServer gives access to the TunnelServer data management layer
 func (store *Store) TunnelServer() portainer.TunnelServerService {
 	return store.TunnelServerService
@@ -0,0 +1,77 @@
+package edgecheckin
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/bolt/internal"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "edge_checkins"
+)
+
+// Service represents a service for managing Edge check-in data.
+type Service struct {
+	db *bolt.DB
+}
+
+// NewService creates a new instance of a service.
+func NewService(db *bolt.DB) (*Service, error) {
+	err := internal.CreateBucket(db, BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		db: db,
+	}, nil
+}
+
+// EdgeCheckins return an array containing the check-in state of all the Edge endpoints.
+func (service *Service) EdgeCheckins() ([]portainer.EdgeCheckin, error) {
+	var checkins = make([]portainer.EdgeCheckin, 0)
+
+	err := service.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var checkin portainer.EdgeCheckin
+			err := internal.UnmarshalObject(v, &checkin)
+			if err != nil {
+				return err
+			}
+			checkins = append(checkins, checkin)
+		}
+
+		return nil
+	})
+
+	return checkins, err
+}
+
+// EdgeCheckin returns the check-in state of an Edge endpoint.
+func (service *Service) EdgeCheckin(endpointID portainer.EndpointID) (*portainer.EdgeCheckin, error) {
+	var checkin portainer.EdgeCheckin
+	identifier := internal.Itob(int(endpointID))
+
+	err := internal.GetObject(service.db, BucketName, identifier, &checkin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &checkin, nil
+}
+
+// UpdateEdgeCheckin saves the check-in state of an Edge endpoint.
+func (service *Service) UpdateEdgeCheckin(endpointID portainer.EndpointID, checkin *portainer.EdgeCheckin) error {
+	identifier := internal.Itob(int(endpointID))
+	return internal.UpdateObject(service.db, BucketName, identifier, checkin)
+}
+
+// DeleteEdgeCheckin deletes the check-in state of an Edge endpoint.
+func (service *Service) DeleteEdgeCheckin(endpointID portainer.EndpointID) error {
+	identifier := internal.Itob(int(endpointID))
+	return internal.DeleteObject(service.db, BucketName, identifier)
+}
@@ -0,0 +1,94 @@
+package edgeenrollmentrule
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/bolt/internal"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "edge_enrollment_rules"
+)
+
+// Service represents a service for managing Edge enrollment rule data.
+type Service struct {
+	db *bolt.DB
+}
+
+// NewService creates a new instance of a service.
+func NewService(db *bolt.DB) (*Service, error) {
+	err := internal.CreateBucket(db, BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		db: db,
+	}, nil
+}
+
+// EdgeEnrollmentRules return an array containing all the Edge enrollment rules.
+func (service *Service) EdgeEnrollmentRules() ([]portainer.EdgeEnrollmentRule, error) {
+	var rules = make([]portainer.EdgeEnrollmentRule, 0)
+
+	err := service.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var rule portainer.EdgeEnrollmentRule
+			err := internal.UnmarshalObject(v, &rule)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+
+		return nil
+	})
+
+	return rules, err
+}
+
+// EdgeEnrollmentRule returns an Edge enrollment rule by ID.
+func (service *Service) EdgeEnrollmentRule(ID portainer.EdgeEnrollmentRuleID) (*portainer.EdgeEnrollmentRule, error) {
+	var rule portainer.EdgeEnrollmentRule
+	identifier := internal.Itob(int(ID))
+
+	err := internal.GetObject(service.db, BucketName, identifier, &rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+// UpdateEdgeEnrollmentRule updates an Edge enrollment rule.
+func (service *Service) UpdateEdgeEnrollmentRule(ID portainer.EdgeEnrollmentRuleID, rule *portainer.EdgeEnrollmentRule) error {
+	identifier := internal.Itob(int(ID))
+	return internal.UpdateObject(service.db, BucketName, identifier, rule)
+}
+
+// DeleteEdgeEnrollmentRule deletes an Edge enrollment rule.
+func (service *Service) DeleteEdgeEnrollmentRule(ID portainer.EdgeEnrollmentRuleID) error {
+	identifier := internal.Itob(int(ID))
+	return internal.DeleteObject(service.db, BucketName, identifier)
+}
+
+// CreateEdgeEnrollmentRule assign an ID to a new Edge enrollment rule and saves it.
+func (service *Service) CreateEdgeEnrollmentRule(rule *portainer.EdgeEnrollmentRule) error {
+	return service.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+
+		id, _ := bucket.NextSequence()
+		rule.ID = portainer.EdgeEnrollmentRuleID(id)
+
+		data, err := internal.MarshalObject(rule)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(internal.Itob(int(rule.ID)), data)
+	})
+}
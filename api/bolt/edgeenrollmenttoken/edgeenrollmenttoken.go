@@ -0,0 +1,125 @@
+package edgeenrollmenttoken
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/bolt/internal"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "edge_enrollment_tokens"
+)
+
+// Service represents a service for managing Edge enrollment token data.
+type Service struct {
+	db *bolt.DB
+}
+
+// NewService creates a new instance of a service.
+func NewService(db *bolt.DB) (*Service, error) {
+	err := internal.CreateBucket(db, BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		db: db,
+	}, nil
+}
+
+// EdgeEnrollmentTokens return an array containing all the Edge enrollment tokens.
+func (service *Service) EdgeEnrollmentTokens() ([]portainer.EdgeEnrollmentToken, error) {
+	var tokens = make([]portainer.EdgeEnrollmentToken, 0)
+
+	err := service.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var token portainer.EdgeEnrollmentToken
+			err := internal.UnmarshalObject(v, &token)
+			if err != nil {
+				return err
+			}
+			tokens = append(tokens, token)
+		}
+
+		return nil
+	})
+
+	return tokens, err
+}
+
+// EdgeEnrollmentToken returns an Edge enrollment token by ID.
+func (service *Service) EdgeEnrollmentToken(ID portainer.EdgeEnrollmentTokenID) (*portainer.EdgeEnrollmentToken, error) {
+	var token portainer.EdgeEnrollmentToken
+	identifier := internal.Itob(int(ID))
+
+	err := internal.GetObject(service.db, BucketName, identifier, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// EdgeEnrollmentTokenByToken returns an Edge enrollment token by its secret value.
+func (service *Service) EdgeEnrollmentTokenByToken(value string) (*portainer.EdgeEnrollmentToken, error) {
+	var token *portainer.EdgeEnrollmentToken
+
+	err := service.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+		cursor := bucket.Cursor()
+
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var t portainer.EdgeEnrollmentToken
+			err := internal.UnmarshalObject(v, &t)
+			if err != nil {
+				return err
+			}
+
+			if t.Token == value {
+				token = &t
+				break
+			}
+		}
+
+		if token == nil {
+			return errors.ErrObjectNotFound
+		}
+		return nil
+	})
+
+	return token, err
+}
+
+// UpdateEdgeEnrollmentToken updates an Edge enrollment token.
+func (service *Service) UpdateEdgeEnrollmentToken(ID portainer.EdgeEnrollmentTokenID, token *portainer.EdgeEnrollmentToken) error {
+	identifier := internal.Itob(int(ID))
+	return internal.UpdateObject(service.db, BucketName, identifier, token)
+}
+
+// DeleteEdgeEnrollmentToken deletes an Edge enrollment token.
+func (service *Service) DeleteEdgeEnrollmentToken(ID portainer.EdgeEnrollmentTokenID) error {
+	identifier := internal.Itob(int(ID))
+	return internal.DeleteObject(service.db, BucketName, identifier)
+}
+
+// CreateEdgeEnrollmentToken assign an ID to a new Edge enrollment token and saves it.
+func (service *Service) CreateEdgeEnrollmentToken(token *portainer.EdgeEnrollmentToken) error {
+	return service.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+
+		id, _ := bucket.NextSequence()
+		token.ID = portainer.EdgeEnrollmentTokenID(id)
+
+		data, err := internal.MarshalObject(token)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(internal.Itob(int(token.ID)), data)
+	})
+}
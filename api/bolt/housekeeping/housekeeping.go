@@ -0,0 +1,94 @@
+package housekeeping
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/bolt/internal"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "housekeeping_policies"
+)
+
+// Service represents a service for managing housekeeping policies data.
+type Service struct {
+	db *bolt.DB
+}
+
+// NewService creates a new instance of a service.
+func NewService(db *bolt.DB) (*Service, error) {
+	err := internal.CreateBucket(db, BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		db: db,
+	}, nil
+}
+
+// HousekeepingPolicies returns a list of housekeeping policies
+func (service *Service) HousekeepingPolicies() ([]portainer.HousekeepingPolicy, error) {
+	var policies = make([]portainer.HousekeepingPolicy, 0)
+
+	err := service.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var policy portainer.HousekeepingPolicy
+			err := internal.UnmarshalObject(v, &policy)
+			if err != nil {
+				return err
+			}
+			policies = append(policies, policy)
+		}
+
+		return nil
+	})
+
+	return policies, err
+}
+
+// HousekeepingPolicy returns a housekeeping policy by ID
+func (service *Service) HousekeepingPolicy(ID portainer.HousekeepingPolicyID) (*portainer.HousekeepingPolicy, error) {
+	var policy portainer.HousekeepingPolicy
+	identifier := internal.Itob(int(ID))
+
+	err := internal.GetObject(service.db, BucketName, identifier, &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// CreateHousekeepingPolicy creates a new housekeeping policy
+func (service *Service) CreateHousekeepingPolicy(policy *portainer.HousekeepingPolicy) error {
+	return service.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+
+		id, _ := bucket.NextSequence()
+		policy.ID = portainer.HousekeepingPolicyID(id)
+
+		data, err := internal.MarshalObject(policy)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(internal.Itob(int(policy.ID)), data)
+	})
+}
+
+// UpdateHousekeepingPolicy updates a housekeeping policy by ID
+func (service *Service) UpdateHousekeepingPolicy(ID portainer.HousekeepingPolicyID, policy *portainer.HousekeepingPolicy) error {
+	identifier := internal.Itob(int(ID))
+	return internal.UpdateObject(service.db, BucketName, identifier, policy)
+}
+
+// DeleteHousekeepingPolicy deletes a housekeeping policy
+func (service *Service) DeleteHousekeepingPolicy(ID portainer.HousekeepingPolicyID) error {
+	identifier := internal.Itob(int(ID))
+	return internal.DeleteObject(service.db, BucketName, identifier)
+}
@@ -0,0 +1,74 @@
+package imagescan
+
+import (
+	"github.com/boltdb/bolt"
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/bolt/internal"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "image_scans"
+)
+
+// Service represents a service for managing image scan data.
+type Service struct {
+	db *bolt.DB
+}
+
+// NewService creates a new instance of a service.
+func NewService(db *bolt.DB) (*Service, error) {
+	err := internal.CreateBucket(db, BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		db: db,
+	}, nil
+}
+
+// ImageScan returns the scan of an image digest, identified by the image name and the digest (name@digest)
+func (service *Service) ImageScan(key string) (*portainer.ImageScan, error) {
+	var scan portainer.ImageScan
+
+	err := internal.GetObject(service.db, BucketName, []byte(key), &scan)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scan, nil
+}
+
+// ImageScans returns all the image scans
+func (service *Service) ImageScans() ([]portainer.ImageScan, error) {
+	var scans = make([]portainer.ImageScan, 0)
+
+	err := service.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var scan portainer.ImageScan
+			err := internal.UnmarshalObject(v, &scan)
+			if err != nil {
+				return err
+			}
+			scans = append(scans, scan)
+		}
+
+		return nil
+	})
+
+	return scans, err
+}
+
+// UpdateImageScan saves the scan of an image digest
+func (service *Service) UpdateImageScan(key string, scan *portainer.ImageScan) error {
+	return internal.UpdateObject(service.db, BucketName, []byte(key), scan)
+}
+
+// DeleteImageScan deletes the scan of an image digest
+func (service *Service) DeleteImageScan(key string) error {
+	return internal.DeleteObject(service.db, BucketName, []byte(key))
+}
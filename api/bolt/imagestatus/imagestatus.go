@@ -0,0 +1,54 @@
+package imagestatus
+
+import (
+	"github.com/boltdb/bolt"
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/bolt/internal"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "image_status"
+)
+
+// Service represents a service for managing image status data.
+type Service struct {
+	db *bolt.DB
+}
+
+// NewService creates a new instance of a service.
+func NewService(db *bolt.DB) (*Service, error) {
+	err := internal.CreateBucket(db, BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		db: db,
+	}, nil
+}
+
+// ImageStatus returns the image status of an endpoint
+func (service *Service) ImageStatus(endpointID portainer.EndpointID) (*portainer.ImageStatus, error) {
+	var status portainer.ImageStatus
+	identifier := internal.Itob(int(endpointID))
+
+	err := internal.GetObject(service.db, BucketName, identifier, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// UpdateImageStatus saves the image status of an endpoint
+func (service *Service) UpdateImageStatus(endpointID portainer.EndpointID, status *portainer.ImageStatus) error {
+	identifier := internal.Itob(int(endpointID))
+	return internal.UpdateObject(service.db, BucketName, identifier, status)
+}
+
+// DeleteImageStatus deletes the image status of an endpoint
+func (service *Service) DeleteImageStatus(endpointID portainer.EndpointID) error {
+	identifier := internal.Itob(int(endpointID))
+	return internal.DeleteObject(service.db, BucketName, identifier)
+}
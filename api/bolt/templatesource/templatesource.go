@@ -0,0 +1,96 @@
+package templatesource
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/bolt/internal"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "template_sources"
+)
+
+// Service represents a service for managing template source data.
+type Service struct {
+	db *bolt.DB
+}
+
+// NewService creates a new instance of a service.
+func NewService(db *bolt.DB) (*Service, error) {
+	err := internal.CreateBucket(db, BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		db: db,
+	}, nil
+}
+
+// TemplateSources return an array containing all the template sources.
+func (service *Service) TemplateSources() ([]portainer.TemplateSource, error) {
+	var templateSources = make([]portainer.TemplateSource, 0)
+
+	err := service.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var templateSource portainer.TemplateSource
+			err := internal.UnmarshalObjectWithJsoniter(v, &templateSource)
+			if err != nil {
+				return err
+			}
+			templateSources = append(templateSources, templateSource)
+		}
+
+		return nil
+	})
+
+	return templateSources, err
+}
+
+// TemplateSource returns a template source by ID.
+func (service *Service) TemplateSource(ID portainer.TemplateSourceID) (*portainer.TemplateSource, error) {
+	var templateSource portainer.TemplateSource
+	identifier := internal.Itob(int(ID))
+
+	err := internal.GetObject(service.db, BucketName, identifier, &templateSource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &templateSource, nil
+}
+
+// UpdateTemplateSource updates a template source.
+func (service *Service) UpdateTemplateSource(ID portainer.TemplateSourceID, templateSource *portainer.TemplateSource) error {
+	identifier := internal.Itob(int(ID))
+	return internal.UpdateObject(service.db, BucketName, identifier, templateSource)
+}
+
+// DeleteTemplateSource deletes a template source.
+func (service *Service) DeleteTemplateSource(ID portainer.TemplateSourceID) error {
+	identifier := internal.Itob(int(ID))
+	return internal.DeleteObject(service.db, BucketName, identifier)
+}
+
+// CreateTemplateSource assign an ID to a new template source and saves it.
+func (service *Service) CreateTemplateSource(templateSource *portainer.TemplateSource) error {
+	return service.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BucketName))
+
+		data, err := internal.MarshalObject(templateSource)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(internal.Itob(int(templateSource.ID)), data)
+	})
+}
+
+// GetNextIdentifier returns the next identifier for a template source.
+func (service *Service) GetNextIdentifier() int {
+	return internal.GetNextIdentifier(service.db, BucketName)
+}
@@ -0,0 +1,121 @@
+package chisel
+
+import (
+	"log"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/bolt/errors"
+)
+
+const (
+	// tunnelEventsLimit is the maximum number of tunnel events kept in the history of an endpoint
+	tunnelEventsLimit = 100
+	// checkinPersistInterval is the minimum duration between two check-ins persisted for an endpoint
+	// when neither the agent version nor the public IP address changed
+	checkinPersistInterval = 5 * time.Minute
+)
+
+// checkinState is the latest check-in of an Edge agent kept in memory
+type checkinState struct {
+	lastCheckin  int64
+	persistedAt  int64
+	agentVersion string
+	publicIP     string
+}
+
+// UpdateCheckin records the time of the latest check-in of the Edge agent associated to an endpoint
+// along with the agent version and the public IP address the request was received from. The check-in
+// is kept in memory and only persisted when the version or the address changed, or once per interval.
+func (service *Service) UpdateCheckin(endpointID portainer.EndpointID, agentVersion, publicIP string) error {
+	service.checkinMutex.Lock()
+	defer service.checkinMutex.Unlock()
+
+	now := time.Now().Unix()
+
+	state, ok := service.checkins[endpointID]
+	if !ok {
+		state = &checkinState{}
+		service.checkins[endpointID] = state
+	}
+	state.lastCheckin = now
+
+	if agentVersion == "" {
+		agentVersion = state.agentVersion
+	}
+
+	if ok && agentVersion == state.agentVersion && publicIP == state.publicIP &&
+		time.Duration(now-state.persistedAt)*time.Second < checkinPersistInterval {
+		return nil
+	}
+
+	checkin, err := service.edgeCheckin(endpointID)
+	if err != nil {
+		return err
+	}
+
+	checkin.LastCheckin = now
+	checkin.PublicIP = publicIP
+	if agentVersion != "" {
+		checkin.AgentVersion = agentVersion
+	}
+
+	err = service.dataStore.EdgeCheckin().UpdateEdgeCheckin(endpointID, checkin)
+	if err != nil {
+		return err
+	}
+
+	state.persistedAt = now
+	state.agentVersion = checkin.AgentVersion
+	state.publicIP = publicIP
+	return nil
+}
+
+// LastCheckin returns the time of the latest check-in of the Edge agent associated to an endpoint
+// received since Portainer started, 0 is returned when the agent did not check in yet.
+func (service *Service) LastCheckin(endpointID portainer.EndpointID) int64 {
+	service.checkinMutex.Lock()
+	defer service.checkinMutex.Unlock()
+
+	if state, ok := service.checkins[endpointID]; ok {
+		return state.lastCheckin
+	}
+	return 0
+}
+
+// recordTunnelEvent appends an event to the tunnel history of an endpoint
+func (service *Service) recordTunnelEvent(endpointID portainer.EndpointID, eventType portainer.EdgeTunnelEventType, port int) {
+	service.checkinMutex.Lock()
+	defer service.checkinMutex.Unlock()
+
+	checkin, err := service.edgeCheckin(endpointID)
+	if err == nil {
+		checkin.TunnelEvents = append(checkin.TunnelEvents, portainer.EdgeTunnelEvent{
+			Type:      eventType,
+			Timestamp: time.Now().Unix(),
+			Port:      port,
+		})
+
+		if len(checkin.TunnelEvents) > tunnelEventsLimit {
+			checkin.TunnelEvents = checkin.TunnelEvents[len(checkin.TunnelEvents)-tunnelEventsLimit:]
+		}
+
+		err = service.dataStore.EdgeCheckin().UpdateEdgeCheckin(endpointID, checkin)
+	}
+
+	if err != nil {
+		log.Printf("[WARN] [chisel,history] [message: unable to persist tunnel event] [endpoint_id: %d] [event: %s] [err: %s]", endpointID, eventType, err)
+	}
+}
+
+func (service *Service) edgeCheckin(endpointID portainer.EndpointID) (*portainer.EdgeCheckin, error) {
+	checkin, err := service.dataStore.EdgeCheckin().EdgeCheckin(endpointID)
+	if err == errors.ErrObjectNotFound {
+		return &portainer.EdgeCheckin{
+			EndpointID:   endpointID,
+			TunnelEvents: []portainer.EdgeTunnelEvent{},
+		}, nil
+	}
+
+	return checkin, err
+}
@@ -0,0 +1,41 @@
+package chisel
+
+import (
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UpdateCheckin(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	service := NewService(store)
+	endpointID := portainer.EndpointID(1)
+
+	assert.NoError(t, service.UpdateCheckin(endpointID, "2.1.0", "203.0.113.5"))
+	checkin, err := store.EdgeCheckin().EdgeCheckin(endpointID)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.1.0", checkin.AgentVersion)
+
+	// an unchanged check-in is only kept in memory
+	service.checkins[endpointID].lastCheckin = 0
+	checkin.LastCheckin = 1
+	assert.NoError(t, store.EdgeCheckin().UpdateEdgeCheckin(endpointID, checkin))
+
+	assert.NoError(t, service.UpdateCheckin(endpointID, "", "203.0.113.5"))
+	checkin, err = store.EdgeCheckin().EdgeCheckin(endpointID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), checkin.LastCheckin)
+	assert.NotZero(t, service.LastCheckin(endpointID))
+
+	// a new address is persisted immediately
+	assert.NoError(t, service.UpdateCheckin(endpointID, "", "203.0.113.6"))
+	checkin, err = store.EdgeCheckin().EdgeCheckin(endpointID)
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.6", checkin.PublicIP)
+	assert.Equal(t, "2.1.0", checkin.AgentVersion)
+	assert.NotEqual(t, int64(1), checkin.LastCheckin)
+}
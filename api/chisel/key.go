@@ -12,6 +12,9 @@ import (
 // portainer_instance_url|tunnel_server_addr|tunnel_server_fingerprint|endpoint_ID
 // The key returned by this function is a base64 encoded version of the data.
 func (service *Service) GenerateEdgeKey(url, host string, endpointIdentifier int) string {
+	service.serverMutex.Lock()
+	defer service.serverMutex.Unlock()
+
 	keyInformation := []string{
 		url,
 		fmt.Sprintf("%s:%s", host, service.serverPort),
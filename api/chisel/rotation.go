@@ -0,0 +1,208 @@
+package chisel
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/dchest/uniuri"
+	chserver "github.com/jpillora/chisel/server"
+)
+
+const (
+	// listenerStartAttempts is the number of attempts to bind the port of a listener that was just closed
+	listenerStartAttempts   = 10
+	listenerStartRetryDelay = 100 * time.Millisecond
+)
+
+var errRotationInProgress = errors.New("A tunnel server key rotation is already in progress")
+
+// RotateServerKey generates a new key for the tunnel server. The new key is served by the listener
+// that is not currently encoded inside the Edge keys, the other listener keeps serving the previous key
+// until the end of the grace period. Edge keys generated after the rotation use the new key.
+func (service *Service) RotateServerKey(gracePeriod time.Duration) error {
+	service.serverMutex.Lock()
+	defer service.serverMutex.Unlock()
+
+	serverInfo, err := service.dataStore.TunnelServer().Info()
+	if err != nil {
+		return err
+	}
+
+	if serverInfo.RotationGracePeriodEnd != 0 {
+		return errRotationInProgress
+	}
+
+	serverInfo.PreviousPrivateKeySeed = serverInfo.PrivateKeySeed
+	serverInfo.PrivateKeySeed = uniuri.NewLen(16)
+	serverInfo.KeyListener = otherListener(serverInfo.KeyListener)
+	serverInfo.RotationGracePeriodEnd = time.Now().Add(gracePeriod).Unix()
+
+	err = service.startListener(serverInfo.KeyListener, serverInfo.PrivateKeySeed)
+	if err != nil {
+		return err
+	}
+
+	err = service.dataStore.TunnelServer().UpdateInfo(serverInfo)
+	if err != nil {
+		return err
+	}
+	service.useKeyListener(serverInfo.KeyListener)
+
+	log.Printf("[INFO] [chisel,rotation] [message: tunnel server key rotated] [grace_period_end: %d]", serverInfo.RotationGracePeriodEnd)
+	return nil
+}
+
+// checkKeyRotation completes a key rotation once its grace period is over,
+// the previous key is replaced by the current key on both listeners.
+func (service *Service) checkKeyRotation() {
+	service.serverMutex.Lock()
+	defer service.serverMutex.Unlock()
+
+	serverInfo, err := service.dataStore.TunnelServer().Info()
+	if err != nil {
+		log.Printf("[ERROR] [chisel,rotation] [message: unable to retrieve tunnel server information] [err: %s]", err)
+		return
+	}
+
+	if serverInfo.RotationGracePeriodEnd == 0 || time.Now().Unix() < serverInfo.RotationGracePeriodEnd {
+		return
+	}
+
+	err = service.startListener(otherListener(serverInfo.KeyListener), serverInfo.PrivateKeySeed)
+	if err != nil {
+		log.Printf("[ERROR] [chisel,rotation] [message: unable to restart tunnel server listener with the current key] [err: %s]", err)
+		return
+	}
+
+	serverInfo.PreviousPrivateKeySeed = ""
+	serverInfo.RotationGracePeriodEnd = 0
+
+	err = service.dataStore.TunnelServer().UpdateInfo(serverInfo)
+	if err != nil {
+		log.Printf("[ERROR] [chisel,rotation] [message: unable to persist tunnel server information] [err: %s]", err)
+		return
+	}
+
+	log.Printf("[INFO] [chisel,rotation] [message: tunnel server key rotation grace period is over, previous key revoked]")
+}
+
+// startListener (re)starts the chisel server bound to a listener with the key generated from the seed.
+// The credentials of the existing tunnels are registered on the new server, the tunnels opened on the
+// previous server with the replaced key are closed.
+// It must be called with the server mutex held.
+func (service *Service) startListener(listener portainer.TunnelListener, keySeed string) error {
+	config := &chserver.Config{
+		Reverse: true,
+		KeySeed: keySeed,
+	}
+
+	chiselServer, err := chserver.NewServer(config)
+	if err != nil {
+		return err
+	}
+
+	tracker, err := trackSessions(chiselServer)
+	if err != nil {
+		return err
+	}
+
+	for username, user := range service.tunnelUsers {
+		err = chiselServer.AddUser(username, user.password, user.authorizedRemote)
+		if err != nil {
+			return err
+		}
+	}
+
+	previousServer := service.chiselServers[listener]
+	if previousServer != nil {
+		previousServer.Close()
+		service.sessionTrackers[listener].closeAll()
+	}
+
+	err = startChiselServer(chiselServer, service.serverAddr, service.serverPorts[listener])
+	if err != nil {
+		return err
+	}
+
+	service.chiselServers[listener] = chiselServer
+	service.sessionTrackers[listener] = tracker
+	return nil
+}
+
+// startChiselServer starts a chisel server, retrying while the port is still bound by the previous server
+func startChiselServer(chiselServer *chserver.Server, addr, port string) error {
+	var err error
+	for attempt := 0; attempt < listenerStartAttempts; attempt++ {
+		err = chiselServer.Start(addr, port)
+		if err == nil {
+			return nil
+		}
+		time.Sleep(listenerStartRetryDelay)
+	}
+	return err
+}
+
+// useKeyListener sets the fingerprint and the port encoded inside the Edge keys.
+// It must be called with the server mutex held.
+func (service *Service) useKeyListener(listener portainer.TunnelListener) {
+	service.serverFingerprint = service.chiselServers[listener].GetFingerprint()
+	service.serverPort = service.serverPorts[listener]
+}
+
+// addUser registers the credentials allowing the agent of an endpoint to open a reverse tunnel on both listeners
+func (service *Service) addUser(endpointID portainer.EndpointID, username, password, authorizedRemote string) error {
+	service.serverMutex.Lock()
+	defer service.serverMutex.Unlock()
+
+	for _, chiselServer := range service.chiselServers {
+		err := chiselServer.AddUser(username, password, authorizedRemote)
+		if err != nil {
+			return err
+		}
+	}
+
+	service.tunnelUsers[username] = tunnelUser{
+		endpointID:       endpointID,
+		password:         password,
+		authorizedRemote: authorizedRemote,
+	}
+
+	return nil
+}
+
+// deleteEndpointUsers removes the tunnel credentials associated to an endpoint from both listeners
+// and closes the tunnels opened with them
+func (service *Service) deleteEndpointUsers(endpointID portainer.EndpointID) {
+	service.serverMutex.Lock()
+	defer service.serverMutex.Unlock()
+
+	for username, user := range service.tunnelUsers {
+		if user.endpointID != endpointID {
+			continue
+		}
+
+		for idx, chiselServer := range service.chiselServers {
+			chiselServer.DeleteUser(username)
+			service.sessionTrackers[idx].closeUser(username)
+		}
+		delete(service.tunnelUsers, username)
+	}
+}
+
+// listenerKeySeed returns the seed of the key served by a listener, the previous key is served
+// by the listener that is not encoded inside the Edge keys during a rotation grace period
+func listenerKeySeed(serverInfo *portainer.TunnelServerInfo, listener portainer.TunnelListener) string {
+	if listener != serverInfo.KeyListener && serverInfo.RotationGracePeriodEnd != 0 && serverInfo.PreviousPrivateKeySeed != "" {
+		return serverInfo.PreviousPrivateKeySeed
+	}
+	return serverInfo.PrivateKeySeed
+}
+
+func otherListener(listener portainer.TunnelListener) portainer.TunnelListener {
+	if listener == portainer.TunnelMainListener {
+		return portainer.TunnelRotationListener
+	}
+	return portainer.TunnelMainListener
+}
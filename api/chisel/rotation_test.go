@@ -0,0 +1,163 @@
+package chisel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	chclient "github.com/jpillora/chisel/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to find a free port: %s", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func startTestTunnelServer(t *testing.T) (*Service, func()) {
+	store, teardown := testhelpers.NewDatastore(t)
+
+	service := NewService(store)
+	err := service.StartTunnelServer("127.0.0.1", strconv.Itoa(freePort(t)), strconv.Itoa(freePort(t)), nil)
+	if err != nil {
+		teardown()
+		t.Fatalf("unable to start the tunnel server: %s", err)
+	}
+
+	return service, func() {
+		for _, chiselServer := range service.chiselServers {
+			chiselServer.Close()
+		}
+		for _, tracker := range service.sessionTrackers {
+			tracker.closeAll()
+		}
+		teardown()
+	}
+}
+
+// openTunnel connects an agent to a listener and waits for its reverse tunnel port to be opened
+func openTunnel(t *testing.T, serverPort, fingerprint, credentials string, tunnelPort int) *chclient.Client {
+	client, err := chclient.NewClient(&chclient.Config{
+		Fingerprint: fingerprint,
+		Auth:        credentials,
+		Server:      "http://127.0.0.1:" + serverPort,
+		Remotes:     []string{fmt.Sprintf("R:0.0.0.0:%d:127.0.0.1:9", tunnelPort)},
+	})
+	if err != nil {
+		t.Fatalf("unable to create the tunnel client: %s", err)
+	}
+
+	err = client.Start(context.Background())
+	if err != nil {
+		t.Fatalf("unable to start the tunnel client: %s", err)
+	}
+
+	for attempt := 0; attempt < 50; attempt++ {
+		conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", tunnelPort))
+		if err == nil {
+			conn.Close()
+			return client
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	client.Close()
+	t.Fatalf("the tunnel was not opened")
+	return nil
+}
+
+func assertTunnelClosed(t *testing.T, client *chclient.Client) {
+	closed := make(chan error, 1)
+	go func() { closed <- client.Wait() }()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		client.Close()
+		t.Fatalf("the tunnel was not closed")
+	}
+}
+
+func Test_RotateServerKey(t *testing.T) {
+	service, teardown := startTestTunnelServer(t)
+	defer teardown()
+
+	previousFingerprint := service.serverFingerprint
+	previousPort := service.serverPort
+
+	assert.NoError(t, service.RotateServerKey(time.Hour))
+
+	assert.NotEqual(t, previousFingerprint, service.serverFingerprint)
+	assert.NotEqual(t, previousPort, service.serverPort)
+
+	serverInfo, err := service.dataStore.TunnelServer().Info()
+	assert.NoError(t, err)
+	assert.Equal(t, portainer.TunnelRotationListener, serverInfo.KeyListener)
+	assert.NotZero(t, serverInfo.RotationGracePeriodEnd)
+
+	// the previous key is still served until the end of the grace period
+	assert.Equal(t, previousFingerprint, service.chiselServers[portainer.TunnelMainListener].GetFingerprint())
+	assert.Equal(t, service.serverFingerprint, service.chiselServers[portainer.TunnelRotationListener].GetFingerprint())
+
+	assert.Equal(t, errRotationInProgress, service.RotateServerKey(time.Hour))
+}
+
+func Test_checkKeyRotation_RevokesPreviousKey(t *testing.T) {
+	service, teardown := startTestTunnelServer(t)
+	defer teardown()
+
+	tunnelPort := freePort(t)
+	assert.NoError(t, service.addUser(1, "agent", "secret", fmt.Sprintf("^R:0.0.0.0:%d$", tunnelPort)))
+
+	previousFingerprint := service.serverFingerprint
+	previousPort := service.serverPort
+
+	assert.NoError(t, service.RotateServerKey(time.Hour))
+
+	client := openTunnel(t, previousPort, previousFingerprint, "agent:secret", tunnelPort)
+	defer client.Close()
+
+	// the grace period is not over, the previous key is kept
+	service.checkKeyRotation()
+	assert.Equal(t, previousFingerprint, service.chiselServers[portainer.TunnelMainListener].GetFingerprint())
+
+	serverInfo, err := service.dataStore.TunnelServer().Info()
+	assert.NoError(t, err)
+	serverInfo.RotationGracePeriodEnd = time.Now().Add(-time.Minute).Unix()
+	assert.NoError(t, service.dataStore.TunnelServer().UpdateInfo(serverInfo))
+
+	service.checkKeyRotation()
+
+	assert.NotEqual(t, previousFingerprint, service.chiselServers[portainer.TunnelMainListener].GetFingerprint())
+	serverInfo, err = service.dataStore.TunnelServer().Info()
+	assert.NoError(t, err)
+	assert.Zero(t, serverInfo.RotationGracePeriodEnd)
+	assert.Empty(t, serverInfo.PreviousPrivateKeySeed)
+
+	assertTunnelClosed(t, client)
+}
+
+func Test_deleteEndpointUsers_ClosesTunnels(t *testing.T) {
+	service, teardown := startTestTunnelServer(t)
+	defer teardown()
+
+	tunnelPort := freePort(t)
+	assert.NoError(t, service.addUser(1, "agent", "secret", fmt.Sprintf("^R:0.0.0.0:%d$", tunnelPort)))
+
+	client := openTunnel(t, service.serverPort, service.serverFingerprint, "agent:secret", tunnelPort)
+	defer client.Close()
+
+	service.deleteEndpointUsers(1)
+
+	assert.NotContains(t, service.tunnelUsers, "agent")
+	assertTunnelClosed(t, client)
+}
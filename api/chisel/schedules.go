@@ -45,3 +45,21 @@ func (service *Service) RemoveEdgeJob(edgeJobID portainer.EdgeJobID) {
 		service.tunnelDetailsMap.Set(item.Key, tunnelDetails)
 	}
 }
+
+// RemoveEdgeJobFromEndpoint will remove the specified Edge job from the tunnel associated to an endpoint.
+func (service *Service) RemoveEdgeJobFromEndpoint(endpointID portainer.EndpointID, edgeJobID portainer.EdgeJobID) {
+	tunnel := service.GetTunnelDetails(endpointID)
+
+	updatedJobs := make([]portainer.EdgeJob, 0)
+	for _, edgeJob := range tunnel.Jobs {
+		if edgeJob.ID == edgeJobID {
+			continue
+		}
+		updatedJobs = append(updatedJobs, edgeJob)
+	}
+
+	tunnel.Jobs = updatedJobs
+
+	key := strconv.Itoa(int(endpointID))
+	service.tunnelDetailsMap.Set(key, tunnel)
+}
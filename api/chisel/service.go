@@ -1,13 +1,15 @@
 package chisel
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/cloudogu/portainer-ce/api"
-	"github.com/cloudogu/portainer-ce/api/bolt/errors"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
 	"github.com/dchest/uniuri"
 	chserver "github.com/jpillora/chisel/server"
 	cmap "github.com/orcaman/concurrent-map"
@@ -25,57 +27,69 @@ const (
 type Service struct {
 	serverFingerprint string
 	serverPort        string
+	serverAddr        string
+	serverPorts       [2]string
+	chiselServers     [2]*chserver.Server
+	sessionTrackers   [2]*sessionTracker
+	tunnelUsers       map[string]tunnelUser
+	serverMutex       sync.Mutex
 	tunnelDetailsMap  cmap.ConcurrentMap
 	dataStore         portainer.DataStore
 	snapshotService   portainer.SnapshotService
-	chiselServer      *chserver.Server
+	checkinMutex      sync.Mutex
+	checkins          map[portainer.EndpointID]*checkinState
+}
+
+// tunnelUser represents the credentials allowing an agent to open a reverse tunnel
+type tunnelUser struct {
+	endpointID       portainer.EndpointID
+	password         string
+	authorizedRemote string
 }
 
 // NewService returns a pointer to a new instance of Service
 func NewService(dataStore portainer.DataStore) *Service {
 	return &Service{
 		tunnelDetailsMap: cmap.New(),
+		tunnelUsers:      make(map[string]tunnelUser),
+		checkins:         make(map[portainer.EndpointID]*checkinState),
 		dataStore:        dataStore,
 	}
 }
 
-// StartTunnelServer starts a tunnel server on the specified addr and port.
-// It uses a seed to generate a new private/public key pair. If the seed cannot
+// StartTunnelServer starts a tunnel server on the specified addr, listening on both the tunnel port and
+// the rotation port. It uses a seed to generate a new private/public key pair. If the seed cannot
 // be found inside the database, it will generate a new one randomly and persist it.
 // It starts the tunnel status verification process in the background.
 // The snapshotter is used in the tunnel status verification process.
-func (service *Service) StartTunnelServer(addr, port string, snapshotService portainer.SnapshotService) error {
-	keySeed, err := service.retrievePrivateKeySeed()
-	if err != nil {
-		return err
+func (service *Service) StartTunnelServer(addr, port, rotationPort string, snapshotService portainer.SnapshotService) error {
+	if port == rotationPort {
+		return errors.New("The tunnel port and the tunnel rotation port must be different")
 	}
 
-	config := &chserver.Config{
-		Reverse: true,
-		KeySeed: keySeed,
-	}
-
-	chiselServer, err := chserver.NewServer(config)
+	serverInfo, err := service.retrieveServerInfo()
 	if err != nil {
 		return err
 	}
 
-	service.serverFingerprint = chiselServer.GetFingerprint()
-	service.serverPort = port
+	service.serverMutex.Lock()
+	defer service.serverMutex.Unlock()
 
-	err = chiselServer.Start(addr, port)
-	if err != nil {
-		return err
-	}
-	service.chiselServer = chiselServer
+	service.serverAddr = addr
+	service.serverPorts = [2]string{port, rotationPort}
 
 	// TODO: work-around Chisel default behavior.
 	// By default, Chisel will allow anyone to connect if no user exists.
 	username, password := generateRandomCredentials()
-	err = service.chiselServer.AddUser(username, password, "127.0.0.1")
-	if err != nil {
-		return err
+	service.tunnelUsers[username] = tunnelUser{password: password, authorizedRemote: "127.0.0.1"}
+
+	for _, listener := range []portainer.TunnelListener{portainer.TunnelMainListener, portainer.TunnelRotationListener} {
+		err = service.startListener(listener, listenerKeySeed(serverInfo, listener))
+		if err != nil {
+			return err
+		}
 	}
+	service.useKeyListener(serverInfo.KeyListener)
 
 	service.snapshotService = snapshotService
 	go service.startTunnelVerificationLoop()
@@ -83,11 +97,11 @@ func (service *Service) StartTunnelServer(addr, port string, snapshotService por
 	return nil
 }
 
-func (service *Service) retrievePrivateKeySeed() (string, error) {
+func (service *Service) retrieveServerInfo() (*portainer.TunnelServerInfo, error) {
 	var serverInfo *portainer.TunnelServerInfo
 
 	serverInfo, err := service.dataStore.TunnelServer().Info()
-	if err == errors.ErrObjectNotFound {
+	if err == bolterrors.ErrObjectNotFound {
 		keySeed := uniuri.NewLen(16)
 
 		serverInfo = &portainer.TunnelServerInfo{
@@ -96,13 +110,13 @@ func (service *Service) retrievePrivateKeySeed() (string, error) {
 
 		err := service.dataStore.TunnelServer().UpdateInfo(serverInfo)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	} else if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return serverInfo.PrivateKeySeed, nil
+	return serverInfo, nil
 }
 
 func (service *Service) startTunnelVerificationLoop() {
@@ -114,6 +128,7 @@ func (service *Service) startTunnelVerificationLoop() {
 		select {
 		case <-ticker.C:
 			service.checkTunnels()
+			service.checkKeyRotation()
 		case <-stopSignal:
 			ticker.Stop()
 			return
@@ -163,6 +178,13 @@ func (service *Service) checkTunnels() {
 
 			service.SetTunnelStatusToIdle(portainer.EndpointID(endpointID))
 		} else {
+			if tunnel.Status == portainer.EdgeAgentActive {
+				endpointID, err := strconv.Atoi(item.Key)
+				if err == nil {
+					service.recordTunnelEvent(portainer.EndpointID(endpointID), portainer.EdgeTunnelClosed, tunnel.Port)
+				}
+			}
+
 			service.tunnelDetailsMap.Remove(item.Key)
 		}
 
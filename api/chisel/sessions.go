@@ -0,0 +1,132 @@
+package chisel
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+
+	chserver "github.com/jpillora/chisel/server"
+	chshare "github.com/jpillora/chisel/share"
+	"golang.org/x/crypto/ssh"
+)
+
+// pendingSessionTimeout is the duration after which a tunnel connection that did not authenticate is forgotten
+const pendingSessionTimeout = time.Minute
+
+var errUnsupportedChiselServer = errors.New("Unable to track the sessions of the tunnel server")
+
+type (
+	// sessionTracker keeps the connections of the tunnels opened on a chisel server by username,
+	// so that the tunnels opened with revoked credentials or a revoked key can be closed.
+	sessionTracker struct {
+		mu       sync.Mutex
+		pending  map[string]pendingSession
+		sessions map[string][]net.Conn
+	}
+
+	// pendingSession is a tunnel connection waiting for the SSH authentication of the agent
+	pendingSession struct {
+		conn     net.Conn
+		openedAt time.Time
+	}
+)
+
+// trackSessions hooks a session tracker inside a chisel server, it must be called before the server is started.
+// The chisel version used does not expose its sessions: the connection state hook of its HTTP server and the
+// password callback of its SSH configuration are retrieved by reflection.
+func trackSessions(chiselServer *chserver.Server) (*sessionTracker, error) {
+	value := reflect.ValueOf(chiselServer).Elem()
+	httpServerField := value.FieldByName("httpServer")
+	sshConfigField := value.FieldByName("sshConfig")
+
+	if !httpServerField.IsValid() || httpServerField.Type() != reflect.TypeOf(&chshare.HTTPServer{}) ||
+		!sshConfigField.IsValid() || sshConfigField.Type() != reflect.TypeOf(&ssh.ServerConfig{}) {
+		return nil, errUnsupportedChiselServer
+	}
+
+	httpServer := (*chshare.HTTPServer)(unsafe.Pointer(httpServerField.Pointer()))
+	sshConfig := (*ssh.ServerConfig)(unsafe.Pointer(sshConfigField.Pointer()))
+
+	tracker := &sessionTracker{
+		pending:  make(map[string]pendingSession),
+		sessions: make(map[string][]net.Conn),
+	}
+
+	httpServer.ConnState = tracker.connState
+
+	passwordCallback := sshConfig.PasswordCallback
+	sshConfig.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+		permissions, err := passwordCallback(conn, password)
+		if err == nil {
+			tracker.authenticated(conn.RemoteAddr().String(), conn.User())
+		}
+		return permissions, err
+	}
+
+	return tracker, nil
+}
+
+// connState keeps the connections hijacked by the websocket handler of the tunnels until they authenticate
+func (tracker *sessionTracker) connState(conn net.Conn, state http.ConnState) {
+	if state != http.StateHijacked {
+		return
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	now := time.Now()
+	for addr, session := range tracker.pending {
+		if now.Sub(session.openedAt) > pendingSessionTimeout {
+			delete(tracker.pending, addr)
+		}
+	}
+
+	tracker.pending[conn.RemoteAddr().String()] = pendingSession{conn: conn, openedAt: now}
+}
+
+func (tracker *sessionTracker) authenticated(remoteAddr, username string) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	session, ok := tracker.pending[remoteAddr]
+	if !ok {
+		return
+	}
+
+	delete(tracker.pending, remoteAddr)
+	tracker.sessions[username] = append(tracker.sessions[username], session.conn)
+}
+
+// closeUser closes the tunnels opened with the credentials of a user
+func (tracker *sessionTracker) closeUser(username string) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	for _, conn := range tracker.sessions[username] {
+		conn.Close()
+	}
+	delete(tracker.sessions, username)
+}
+
+// closeAll closes all the tunnels opened on the server
+func (tracker *sessionTracker) closeAll() {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	for _, session := range tracker.pending {
+		session.conn.Close()
+	}
+	for _, conns := range tracker.sessions {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+
+	tracker.pending = make(map[string]pendingSession)
+	tracker.sessions = make(map[string][]net.Conn)
+}
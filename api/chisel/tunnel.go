@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/portainer/libcrypto"
@@ -60,6 +59,10 @@ func (service *Service) GetTunnelDetails(endpointID portainer.EndpointID) *porta
 // It sets the status to ACTIVE.
 func (service *Service) SetTunnelStatusToActive(endpointID portainer.EndpointID) {
 	tunnel := service.GetTunnelDetails(endpointID)
+	if tunnel.Status != portainer.EdgeAgentActive && tunnel.Port != 0 {
+		service.recordTunnelEvent(endpointID, portainer.EdgeTunnelOpened, tunnel.Port)
+	}
+
 	tunnel.Status = portainer.EdgeAgentActive
 	tunnel.Credentials = ""
 	tunnel.LastActivity = time.Now()
@@ -73,16 +76,16 @@ func (service *Service) SetTunnelStatusToActive(endpointID portainer.EndpointID)
 // It removes any existing credentials associated to the tunnel.
 func (service *Service) SetTunnelStatusToIdle(endpointID portainer.EndpointID) {
 	tunnel := service.GetTunnelDetails(endpointID)
+	if tunnel.Status == portainer.EdgeAgentActive {
+		service.recordTunnelEvent(endpointID, portainer.EdgeTunnelClosed, tunnel.Port)
+	}
 
 	tunnel.Status = portainer.EdgeAgentIdle
 	tunnel.Port = 0
 	tunnel.LastActivity = time.Now()
 
-	credentials := tunnel.Credentials
-	if credentials != "" {
-		tunnel.Credentials = ""
-		service.chiselServer.DeleteUser(strings.Split(credentials, ":")[0])
-	}
+	tunnel.Credentials = ""
+	service.deleteEndpointUsers(endpointID)
 
 	key := strconv.Itoa(int(endpointID))
 	service.tunnelDetailsMap.Set(key, tunnel)
@@ -108,7 +111,7 @@ func (service *Service) SetTunnelStatusToRequired(endpointID portainer.EndpointI
 
 		username, password := generateRandomCredentials()
 		authorizedRemote := fmt.Sprintf("^R:0.0.0.0:%d$", tunnel.Port)
-		err = service.chiselServer.AddUser(username, password, authorizedRemote)
+		err = service.addUser(endpointID, username, password, authorizedRemote)
 		if err != nil {
 			return err
 		}
@@ -21,6 +21,10 @@ var (
 	errInvalidEndpointProtocol       = errors.New("Invalid endpoint protocol: Portainer only supports unix://, npipe:// or tcp://")
 	errSocketOrNamedPipeNotFound     = errors.New("Unable to locate Unix socket or named pipe")
 	errInvalidSnapshotInterval       = errors.New("Invalid snapshot interval")
+	errInvalidImageCheckInterval     = errors.New("Invalid image check interval")
+	errInvalidGitSyncInterval        = errors.New("Invalid edge stack Git sync interval")
+	errInvalidRetentionInterval      = errors.New("Invalid registry retention interval")
+	errInvalidImageScanInterval      = errors.New("Invalid image scan interval")
 	errAdminPassExcludeAdminPassFile = errors.New("Cannot use --admin-password with --admin-password-file")
 )
 
@@ -32,6 +36,7 @@ func (*Service) ParseFlags(version string) (*portainer.CLIFlags, error) {
 		Addr:                      kingpin.Flag("bind", "Address and port to serve Portainer").Default(defaultBindAddress).Short('p').String(),
 		TunnelAddr:                kingpin.Flag("tunnel-addr", "Address to serve the tunnel server").Default(defaultTunnelServerAddress).String(),
 		TunnelPort:                kingpin.Flag("tunnel-port", "Port to serve the tunnel server").Default(defaultTunnelServerPort).String(),
+		TunnelRotationPort:        kingpin.Flag("tunnel-rotation-port", "Port to serve the tunnel server with the previous or the next key during a key rotation").Default(defaultTunnelServerRotationPort).String(),
 		Assets:                    kingpin.Flag("assets", "Path to the assets").Default(defaultAssetsDirectory).Short('a').String(),
 		Data:                      kingpin.Flag("data", "Path to the folder where the data is stored").Default(defaultDataDirectory).Short('d').String(),
 		EndpointURL:               kingpin.Flag("host", "Endpoint URL").Short('H').String(),
@@ -46,6 +51,12 @@ func (*Service) ParseFlags(version string) (*portainer.CLIFlags, error) {
 		SSLCert:                   kingpin.Flag("sslcert", "Path to the SSL certificate used to secure the Portainer instance").Default(defaultSSLCertPath).String(),
 		SSLKey:                    kingpin.Flag("sslkey", "Path to the SSL key used to secure the Portainer instance").Default(defaultSSLKeyPath).String(),
 		SnapshotInterval:          kingpin.Flag("snapshot-interval", "Duration between each endpoint snapshot job").Default(defaultSnapshotInterval).String(),
+		ImageCheckInterval:        kingpin.Flag("image-check-interval", "Duration between each image update check job").Default(defaultImageCheckInterval).String(),
+		EdgeStackGitSyncInterval:  kingpin.Flag("edge-stack-git-sync-interval", "Duration between each check of the Git repositories of the edge stacks").Default(defaultEdgeStackGitSyncInterval).String(),
+		TemplateGitSyncInterval:   kingpin.Flag("template-git-sync-interval", "Duration between each check of the Git repositories of the custom templates").Default(defaultTemplateGitSyncInterval).String(),
+		RegistryRetentionInterval: kingpin.Flag("registry-retention-interval", "Duration between each execution of the retention policies of the registries").Default(defaultRetentionInterval).String(),
+		ImageScanInterval:         kingpin.Flag("image-scan-interval", "Duration between each vulnerability scan of the images found in the endpoint snapshots").Default(defaultImageScanInterval).String(),
+		TrustedProxies:            kingpin.Flag("trusted-proxy", "IP address or CIDR range of a reverse proxy allowed to set the X-Forwarded-* headers").Strings(),
 		AdminPassword:             kingpin.Flag("admin-password", "Hashed admin password").String(),
 		AdminPasswordFile:         kingpin.Flag("admin-password-file", "Path to the file containing the password for the admin user").String(),
 		Labels:                    pairs(kingpin.Flag("hide-label", "Hide containers with a specific label in the UI").Short('l')),
@@ -81,6 +92,31 @@ func (*Service) ValidateFlags(flags *portainer.CLIFlags) error {
 		return err
 	}
 
+	err = validateImageCheckInterval(*flags.ImageCheckInterval)
+	if err != nil {
+		return err
+	}
+
+	err = validateGitSyncInterval(*flags.EdgeStackGitSyncInterval)
+	if err != nil {
+		return err
+	}
+
+	err = validateGitSyncInterval(*flags.TemplateGitSyncInterval)
+	if err != nil {
+		return err
+	}
+
+	err = validateRetentionInterval(*flags.RegistryRetentionInterval)
+	if err != nil {
+		return err
+	}
+
+	err = validateImageScanInterval(*flags.ImageScanInterval)
+	if err != nil {
+		return err
+	}
+
 	if *flags.AdminPassword != "" && *flags.AdminPasswordFile != "" {
 		return errAdminPassExcludeAdminPassFile
 	}
@@ -123,3 +159,35 @@ func validateSnapshotInterval(snapshotInterval string) error {
 	}
 	return nil
 }
+
+func validateImageCheckInterval(imageCheckInterval string) error {
+	interval, err := time.ParseDuration(imageCheckInterval)
+	if err != nil || interval <= 0 {
+		return errInvalidImageCheckInterval
+	}
+	return nil
+}
+
+func validateGitSyncInterval(gitSyncInterval string) error {
+	interval, err := time.ParseDuration(gitSyncInterval)
+	if err != nil || interval <= 0 {
+		return errInvalidGitSyncInterval
+	}
+	return nil
+}
+
+func validateRetentionInterval(retentionInterval string) error {
+	interval, err := time.ParseDuration(retentionInterval)
+	if err != nil || interval <= 0 {
+		return errInvalidRetentionInterval
+	}
+	return nil
+}
+
+func validateImageScanInterval(scanInterval string) error {
+	interval, err := time.ParseDuration(scanInterval)
+	if err != nil || interval <= 0 {
+		return errInvalidImageScanInterval
+	}
+	return nil
+}
@@ -3,18 +3,24 @@
 package cli
 
 const (
-	defaultBindAddress         = ":9000"
-	defaultTunnelServerAddress = "0.0.0.0"
-	defaultTunnelServerPort    = "8000"
-	defaultDataDirectory       = "/data"
-	defaultAssetsDirectory     = "./"
-	defaultTLS                 = "false"
-	defaultTLSSkipVerify       = "false"
-	defaultTLSCACertPath       = "/certs/ca.pem"
-	defaultTLSCertPath         = "/certs/cert.pem"
-	defaultTLSKeyPath          = "/certs/key.pem"
-	defaultSSL                 = "false"
-	defaultSSLCertPath         = "/certs/portainer.crt"
-	defaultSSLKeyPath          = "/certs/portainer.key"
-	defaultSnapshotInterval    = "5m"
+	defaultBindAddress              = ":9000"
+	defaultTunnelServerAddress      = "0.0.0.0"
+	defaultTunnelServerPort         = "8000"
+	defaultTunnelServerRotationPort = "8001"
+	defaultDataDirectory            = "/data"
+	defaultAssetsDirectory          = "./"
+	defaultTLS                      = "false"
+	defaultTLSSkipVerify            = "false"
+	defaultTLSCACertPath            = "/certs/ca.pem"
+	defaultTLSCertPath              = "/certs/cert.pem"
+	defaultTLSKeyPath               = "/certs/key.pem"
+	defaultSSL                      = "false"
+	defaultSSLCertPath              = "/certs/portainer.crt"
+	defaultSSLKeyPath               = "/certs/portainer.key"
+	defaultSnapshotInterval         = "5m"
+	defaultImageCheckInterval       = "1h"
+	defaultEdgeStackGitSyncInterval = "5m"
+	defaultTemplateGitSyncInterval  = "5m"
+	defaultRetentionInterval        = "24h"
+	defaultImageScanInterval        = "24h"
 )
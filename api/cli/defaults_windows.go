@@ -1,18 +1,24 @@
 package cli
 
 const (
-	defaultBindAddress         = ":9000"
-	defaultTunnelServerAddress = "0.0.0.0"
-	defaultTunnelServerPort    = "8000"
-	defaultDataDirectory       = "C:\\data"
-	defaultAssetsDirectory     = "./"
-	defaultTLS                 = "false"
-	defaultTLSSkipVerify       = "false"
-	defaultTLSCACertPath       = "C:\\certs\\ca.pem"
-	defaultTLSCertPath         = "C:\\certs\\cert.pem"
-	defaultTLSKeyPath          = "C:\\certs\\key.pem"
-	defaultSSL                 = "false"
-	defaultSSLCertPath         = "C:\\certs\\portainer.crt"
-	defaultSSLKeyPath          = "C:\\certs\\portainer.key"
-	defaultSnapshotInterval    = "5m"
+	defaultBindAddress              = ":9000"
+	defaultTunnelServerAddress      = "0.0.0.0"
+	defaultTunnelServerPort         = "8000"
+	defaultTunnelServerRotationPort = "8001"
+	defaultDataDirectory            = "C:\\data"
+	defaultAssetsDirectory          = "./"
+	defaultTLS                      = "false"
+	defaultTLSSkipVerify            = "false"
+	defaultTLSCACertPath            = "C:\\certs\\ca.pem"
+	defaultTLSCertPath              = "C:\\certs\\cert.pem"
+	defaultTLSKeyPath               = "C:\\certs\\key.pem"
+	defaultSSL                      = "false"
+	defaultSSLCertPath              = "C:\\certs\\portainer.crt"
+	defaultSSLKeyPath               = "C:\\certs\\portainer.key"
+	defaultSnapshotInterval         = "5m"
+	defaultImageCheckInterval       = "1h"
+	defaultEdgeStackGitSyncInterval = "5m"
+	defaultTemplateGitSyncInterval  = "5m"
+	defaultRetentionInterval        = "24h"
+	defaultImageScanInterval        = "24h"
 )
@@ -19,7 +19,17 @@ import (
 	"github.com/cloudogu/portainer-ce/api/http/client"
 	"github.com/cloudogu/portainer-ce/api/http/proxy"
 	kubeproxy "github.com/cloudogu/portainer-ce/api/http/proxy/factory/kubernetes"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/customtemplatesync"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	"github.com/cloudogu/portainer-ce/api/internal/edgestacksync"
+	"github.com/cloudogu/portainer-ce/api/internal/housekeeping"
+	"github.com/cloudogu/portainer-ce/api/internal/imagetrust"
+	"github.com/cloudogu/portainer-ce/api/internal/imageupdate"
+	"github.com/cloudogu/portainer-ce/api/internal/registryretention"
 	"github.com/cloudogu/portainer-ce/api/internal/snapshot"
+	"github.com/cloudogu/portainer-ce/api/internal/templatecatalog"
+	"github.com/cloudogu/portainer-ce/api/internal/vulnscan"
 	"github.com/cloudogu/portainer-ce/api/jwt"
 	"github.com/cloudogu/portainer-ce/api/kubernetes"
 	kubecli "github.com/cloudogu/portainer-ce/api/kubernetes/cli"
@@ -219,6 +229,55 @@ func initKeyPair(fileService portainer.FileService, signatureService portainer.D
 	return generateAndStoreKeyPair(fileService, signatureService)
 }
 
+func initSecretKey(fileService portainer.FileService, secretService portainer.SecretService) error {
+	existingKey, err := fileService.SecretKeyFileExists()
+	if err != nil {
+		return err
+	}
+
+	if !existingKey {
+		key, err := secretService.GenerateKey()
+		if err != nil {
+			return err
+		}
+
+		err = fileService.StoreSecretKey(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err := fileService.LoadSecretKey()
+	if err != nil {
+		return err
+	}
+	return secretService.ParseKey(key)
+}
+
+// sealEdgeSecrets encrypts the secret environment variables of the Edge stacks and the endpoint metadata
+// overriding them that were stored before their encryption.
+func sealEdgeSecrets(dataStore portainer.DataStore, secretService portainer.SecretService) error {
+	edgeStacks, err := dataStore.EdgeStack().EdgeStacks()
+	if err != nil {
+		return err
+	}
+
+	for idx := range edgeStacks {
+		edgeStack := &edgeStacks[idx]
+		err = edge.SealEdgeStackEnv(edgeStack, secretService)
+		if err != nil {
+			return err
+		}
+
+		err = dataStore.EdgeStack().UpdateEdgeStack(edgeStack.ID, edgeStack)
+		if err != nil {
+			return err
+		}
+	}
+
+	return edge.SealEndpointsMetadata(dataStore, secretService)
+}
+
 func createTLSSecuredEndpoint(flags *portainer.CLIFlags, dataStore portainer.DataStore, snapshotService portainer.SnapshotService) error {
 	tlsConfiguration := portainer.TLSConfiguration{
 		TLS:           *flags.TLS,
@@ -371,6 +430,18 @@ func main() {
 		log.Fatal(err)
 	}
 
+	secretService := crypto.NewSecretService()
+
+	err = initSecretKey(fileService, secretService)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = sealEdgeSecrets(dataStore, secretService)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	reverseTunnelService := chisel.NewService(dataStore)
 
 	instanceID, err := dataStore.Version().InstanceID()
@@ -391,8 +462,10 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	imageTrustService := imagetrust.NewService(dataStore, fileService)
+
 	kubernetesTokenCacheManager := kubeproxy.NewTokenCacheManager()
-	proxyManager := proxy.NewManager(dataStore, digitalSignatureService, reverseTunnelService, dockerClientFactory, kubernetesClientFactory, kubernetesTokenCacheManager)
+	proxyManager := proxy.NewManager(dataStore, digitalSignatureService, reverseTunnelService, imageTrustService, dockerClientFactory, kubernetesClientFactory, kubernetesTokenCacheManager)
 
 	composeStackManager := initComposeStackManager(*flags.Assets, *flags.Data, reverseTunnelService, proxyManager)
 
@@ -410,6 +483,44 @@ func main() {
 		log.Fatal(err)
 	}
 
+	housekeepingService := housekeeping.NewService(dataStore, dockerClientFactory, reverseTunnelService)
+	err = housekeepingService.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	vulnerabilityScanService, err := vulnscan.NewService(*flags.ImageScanInterval, dataStore)
+	if err != nil {
+		log.Fatal(err)
+	}
+	vulnerabilityScanService.Start()
+
+	imageUpdateService, err := imageupdate.NewService(*flags.ImageCheckInterval, dataStore, fileService, dockerClientFactory, reverseTunnelService, swarmStackManager, composeStackManager, imageTrustService, vulnerabilityScanService)
+	if err != nil {
+		log.Fatal(err)
+	}
+	imageUpdateService.Start()
+
+	edgeStackGitSyncService, err := edgestacksync.NewService(*flags.EdgeStackGitSyncInterval, dataStore, fileService, gitService)
+	if err != nil {
+		log.Fatal(err)
+	}
+	edgeStackGitSyncService.Start()
+
+	customTemplateGitSyncService, err := customtemplatesync.NewService(*flags.TemplateGitSyncInterval, dataStore, fileService, gitService)
+	if err != nil {
+		log.Fatal(err)
+	}
+	customTemplateGitSyncService.Start()
+
+	registryRetentionService, err := registryretention.NewService(*flags.RegistryRetentionInterval, dataStore)
+	if err != nil {
+		log.Fatal(err)
+	}
+	registryRetentionService.Start()
+
+	templateCatalogService := templatecatalog.NewService(fileService, gitService)
+
 	applicationStatus := initStatus(flags)
 
 	err = initEndpoint(flags, dataStore, snapshotService)
@@ -455,35 +566,50 @@ func main() {
 
 	go terminateIfNoAdminCreated(dataStore)
 
-	err = reverseTunnelService.StartTunnelServer(*flags.TunnelAddr, *flags.TunnelPort, snapshotService)
+	err = reverseTunnelService.StartTunnelServer(*flags.TunnelAddr, *flags.TunnelPort, *flags.TunnelRotationPort, snapshotService)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	trustedProxies, err := security.ParseTrustedProxies(*flags.TrustedProxies)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	var server portainer.Server = &http.Server{
-		ReverseTunnelService:        reverseTunnelService,
-		Status:                      applicationStatus,
-		BindAddress:                 *flags.Addr,
-		AssetsPath:                  *flags.Assets,
-		DataStore:                   dataStore,
-		SwarmStackManager:           swarmStackManager,
-		ComposeStackManager:         composeStackManager,
-		KubernetesDeployer:          kubernetesDeployer,
-		CryptoService:               cryptoService,
-		JWTService:                  jwtService,
-		FileService:                 fileService,
-		LDAPService:                 ldapService,
-		OAuthService:                oauthService,
-		GitService:                  gitService,
-		ProxyManager:                proxyManager,
-		KubernetesTokenCacheManager: kubernetesTokenCacheManager,
-		SignatureService:            digitalSignatureService,
-		SnapshotService:             snapshotService,
-		SSL:                         *flags.SSL,
-		SSLCert:                     *flags.SSLCert,
-		SSLKey:                      *flags.SSLKey,
-		DockerClientFactory:         dockerClientFactory,
-		KubernetesClientFactory:     kubernetesClientFactory,
+		ReverseTunnelService:         reverseTunnelService,
+		Status:                       applicationStatus,
+		BindAddress:                  *flags.Addr,
+		AssetsPath:                   *flags.Assets,
+		DataStore:                    dataStore,
+		SwarmStackManager:            swarmStackManager,
+		ComposeStackManager:          composeStackManager,
+		KubernetesDeployer:           kubernetesDeployer,
+		CryptoService:                cryptoService,
+		JWTService:                   jwtService,
+		FileService:                  fileService,
+		LDAPService:                  ldapService,
+		OAuthService:                 oauthService,
+		GitService:                   gitService,
+		HousekeepingService:          housekeepingService,
+		ImageUpdateService:           imageUpdateService,
+		EdgeStackGitSyncService:      edgeStackGitSyncService,
+		CustomTemplateGitSyncService: customTemplateGitSyncService,
+		RegistryRetentionService:     registryRetentionService,
+		VulnerabilityScanService:     vulnerabilityScanService,
+		ImageTrustService:            imageTrustService,
+		TemplateCatalogService:       templateCatalogService,
+		ProxyManager:                 proxyManager,
+		KubernetesTokenCacheManager:  kubernetesTokenCacheManager,
+		SignatureService:             digitalSignatureService,
+		SecretService:                secretService,
+		SnapshotService:              snapshotService,
+		SSL:                          *flags.SSL,
+		SSLCert:                      *flags.SSLCert,
+		SSLKey:                       *flags.SSLKey,
+		TrustedProxies:               trustedProxies,
+		DockerClientFactory:          dockerClientFactory,
+		KubernetesClientFactory:      kubernetesClientFactory,
 	}
 
 	log.Printf("Starting Portainer %s on %s", portainer.APIVersion, *flags.Addr)
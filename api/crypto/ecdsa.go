@@ -113,6 +113,13 @@ func (service *ECDSAService) CreateSignature(message string) (string, error) {
 		message = service.secret
 	}
 
+	return service.SignMessage(message)
+}
+
+// SignMessage creates a digital signature of the specified message, the same way as
+// CreateSignature but without replacing the message by the secret associated to the service.
+// It is used to sign content that is verified with the public key, such as exported bundles.
+func (service *ECDSAService) SignMessage(message string) (string, error) {
 	hash := libcrypto.HashFromBytes([]byte(message))
 
 	r := big.NewInt(0)
@@ -0,0 +1,107 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	// secretKeySize is the size of the AES-256 key used to encrypt the secrets stored in the database
+	secretKeySize = 32
+	// EncryptedSecretPrefix identifies the values encrypted by the secret service, values submitted
+	// by users must not start with it
+	EncryptedSecretPrefix = "encrypted:"
+)
+
+var errInvalidSecretKey = errors.New("Invalid secret key size")
+
+// SecretService is a service used to encrypt the secrets stored in the database with AES-GCM.
+// Encrypted values are prefixed so that the values stored before their encryption can still be read.
+type SecretService struct {
+	aead cipher.AEAD
+}
+
+// NewSecretService returns a pointer to a SecretService.
+func NewSecretService() *SecretService {
+	return &SecretService{}
+}
+
+// GenerateKey generates a new random secret key.
+func (service *SecretService) GenerateKey() ([]byte, error) {
+	key := make([]byte, secretKeySize)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ParseKey associates an existing secret key to the service.
+func (service *SecretService) ParseKey(key []byte) error {
+	if len(key) != secretKeySize {
+		return errInvalidSecretKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	service.aead = aead
+	return nil
+}
+
+// Encrypt encrypts a value, values that are already encrypted are returned as is.
+func (service *SecretService) Encrypt(value string) (string, error) {
+	if service.IsEncrypted(value) {
+		return value, nil
+	}
+
+	nonce := make([]byte, service.aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	encryptedValue := service.aead.Seal(nonce, nonce, []byte(value), nil)
+	return EncryptedSecretPrefix + base64.RawStdEncoding.EncodeToString(encryptedValue), nil
+}
+
+// Decrypt decrypts a value, values that are not encrypted are returned as is.
+func (service *SecretService) Decrypt(value string) (string, error) {
+	if !service.IsEncrypted(value) {
+		return value, nil
+	}
+
+	encryptedValue, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedSecretPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := service.aead.NonceSize()
+	if len(encryptedValue) < nonceSize {
+		return "", errors.New("Invalid encrypted value")
+	}
+
+	data, err := service.aead.Open(nil, encryptedValue[:nonceSize], encryptedValue[nonceSize:], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// IsEncrypted returns true when a value was encrypted by the service.
+func (service *SecretService) IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedSecretPrefix)
+}
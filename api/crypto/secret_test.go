@@ -0,0 +1,59 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSecretService(t *testing.T) *SecretService {
+	service := NewSecretService()
+	key, err := service.GenerateKey()
+	assert.NoError(t, err)
+	assert.NoError(t, service.ParseKey(key))
+	return service
+}
+
+func TestSecretService_EncryptDecrypt(t *testing.T) {
+	service := newTestSecretService(t)
+
+	encrypted, err := service.Encrypt("password")
+	assert.NoError(t, err)
+	assert.True(t, service.IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "password")
+
+	decrypted, err := service.Decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "password", decrypted)
+}
+
+func TestSecretService_EncryptIsIdempotent(t *testing.T) {
+	service := newTestSecretService(t)
+
+	encrypted, err := service.Encrypt("password")
+	assert.NoError(t, err)
+
+	encryptedTwice, err := service.Encrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, encrypted, encryptedTwice)
+}
+
+func TestSecretService_DecryptPlainValue(t *testing.T) {
+	service := newTestSecretService(t)
+
+	decrypted, err := service.Decrypt("password")
+	assert.NoError(t, err)
+	assert.Equal(t, "password", decrypted)
+}
+
+func TestSecretService_DecryptWithAnotherKey(t *testing.T) {
+	encrypted, err := newTestSecretService(t).Encrypt("password")
+	assert.NoError(t, err)
+
+	_, err = newTestSecretService(t).Decrypt(encrypted)
+	assert.Error(t, err)
+}
+
+func TestSecretService_ParseKeyInvalidSize(t *testing.T) {
+	assert.Equal(t, errInvalidSecretKey, NewSecretService().ParseKey([]byte("short")))
+}
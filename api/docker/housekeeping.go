@@ -0,0 +1,303 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+var errBuildCacheLabelFilter = errors.New("Build cache entries do not support label filters, operation skipped")
+
+// pruneCriteria represents the criteria used to select the resources removed by a housekeeping run
+type pruneCriteria struct {
+	labels    []portainer.Pair
+	olderThan string
+	until     time.Time
+	dryRun    bool
+}
+
+// PruneResources executes the prune operations enabled in the policy against a Docker engine.
+// When the dry-run mode is enabled, the resources that would be removed are listed without being removed.
+func PruneResources(ctx context.Context, cli *client.Client, policy *portainer.HousekeepingPolicy) ([]portainer.HousekeepingOperationReport, error) {
+	criteria := pruneCriteria{
+		labels:    policy.Labels,
+		olderThan: policy.OlderThan,
+		dryRun:    policy.DryRun,
+	}
+
+	if policy.OlderThan != "" {
+		olderThan, err := time.ParseDuration(policy.OlderThan)
+		if err != nil {
+			return nil, err
+		}
+		criteria.until = time.Now().Add(-olderThan)
+	}
+
+	var usage *types.DiskUsage
+	if policy.DryRun || policy.PruneVolumes {
+		diskUsage, err := cli.DiskUsage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		usage = &diskUsage
+	}
+
+	reports := make([]portainer.HousekeepingOperationReport, 0)
+
+	if policy.PruneImages {
+		reports = append(reports, pruneImages(ctx, cli, usage, policy.PruneAllImages, criteria))
+	}
+
+	if policy.PruneVolumes {
+		reports = append(reports, pruneVolumes(ctx, cli, usage, criteria))
+	}
+
+	if policy.PruneNetworks {
+		reports = append(reports, pruneNetworks(ctx, cli, criteria))
+	}
+
+	if policy.PruneBuildCache {
+		reports = append(reports, pruneBuildCache(ctx, cli, usage, criteria))
+	}
+
+	return reports, nil
+}
+
+func pruneImages(ctx context.Context, cli *client.Client, usage *types.DiskUsage, all bool, criteria pruneCriteria) portainer.HousekeepingOperationReport {
+	report := portainer.HousekeepingOperationReport{
+		Operation:    portainer.HousekeepingOperationImagePrune,
+		ItemsDeleted: []string{},
+	}
+
+	if criteria.dryRun {
+		for _, image := range usage.Images {
+			if image.Containers > 0 || (!all && !isDanglingImage(image)) {
+				continue
+			}
+
+			if !criteria.until.IsZero() && !time.Unix(image.Created, 0).Before(criteria.until) {
+				continue
+			}
+
+			if !matchLabels(image.Labels, criteria.labels) {
+				continue
+			}
+
+			report.ItemsDeleted = append(report.ItemsDeleted, image.ID)
+			report.SpaceReclaimed += exclusiveImageSize(image)
+		}
+
+		return report
+	}
+
+	pruneFilters := labelFilters(criteria.labels)
+	pruneFilters.Add("dangling", strconv.FormatBool(!all))
+	if !criteria.until.IsZero() {
+		pruneFilters.Add("until", strconv.FormatInt(criteria.until.Unix(), 10))
+	}
+
+	pruneReport, err := cli.ImagesPrune(ctx, pruneFilters)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	for _, item := range pruneReport.ImagesDeleted {
+		if item.Deleted != "" {
+			report.ItemsDeleted = append(report.ItemsDeleted, item.Deleted)
+		}
+	}
+	report.SpaceReclaimed = pruneReport.SpaceReclaimed
+
+	return report
+}
+
+// pruneVolumes removes the unused volumes one by one as the volume prune API
+// does not support filtering volumes based on their creation date.
+func pruneVolumes(ctx context.Context, cli *client.Client, usage *types.DiskUsage, criteria pruneCriteria) portainer.HousekeepingOperationReport {
+	report := portainer.HousekeepingOperationReport{
+		Operation:    portainer.HousekeepingOperationVolumePrune,
+		ItemsDeleted: []string{},
+	}
+
+	for _, volume := range usage.Volumes {
+		if volume.UsageData == nil || volume.UsageData.RefCount != 0 {
+			continue
+		}
+
+		if !criteria.until.IsZero() {
+			createdAt, err := time.Parse(time.RFC3339, volume.CreatedAt)
+			if err != nil || !createdAt.Before(criteria.until) {
+				continue
+			}
+		}
+
+		if !matchLabels(volume.Labels, criteria.labels) {
+			continue
+		}
+
+		if !criteria.dryRun {
+			err := cli.VolumeRemove(ctx, volume.Name, false)
+			if err != nil {
+				report.Error = err.Error()
+				continue
+			}
+		}
+
+		report.ItemsDeleted = append(report.ItemsDeleted, volume.Name)
+		if volume.UsageData.Size > 0 {
+			report.SpaceReclaimed += uint64(volume.UsageData.Size)
+		}
+	}
+
+	return report
+}
+
+func pruneNetworks(ctx context.Context, cli *client.Client, criteria pruneCriteria) portainer.HousekeepingOperationReport {
+	report := portainer.HousekeepingOperationReport{
+		Operation:    portainer.HousekeepingOperationNetworkPrune,
+		ItemsDeleted: []string{},
+	}
+
+	if criteria.dryRun {
+		networks, err := cli.NetworkList(ctx, types.NetworkListOptions{Filters: labelFilters(criteria.labels)})
+		if err != nil {
+			report.Error = err.Error()
+			return report
+		}
+
+		for _, network := range networks {
+			if isPredefinedNetwork(network) {
+				continue
+			}
+
+			if !criteria.until.IsZero() && !network.Created.Before(criteria.until) {
+				continue
+			}
+
+			details, err := cli.NetworkInspect(ctx, network.ID, types.NetworkInspectOptions{})
+			if err != nil || len(details.Containers) > 0 {
+				continue
+			}
+
+			report.ItemsDeleted = append(report.ItemsDeleted, network.Name)
+		}
+
+		return report
+	}
+
+	pruneFilters := labelFilters(criteria.labels)
+	if !criteria.until.IsZero() {
+		pruneFilters.Add("until", strconv.FormatInt(criteria.until.Unix(), 10))
+	}
+
+	pruneReport, err := cli.NetworksPrune(ctx, pruneFilters)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.ItemsDeleted = append(report.ItemsDeleted, pruneReport.NetworksDeleted...)
+
+	return report
+}
+
+func pruneBuildCache(ctx context.Context, cli *client.Client, usage *types.DiskUsage, criteria pruneCriteria) portainer.HousekeepingOperationReport {
+	report := portainer.HousekeepingOperationReport{
+		Operation:    portainer.HousekeepingOperationBuildCachePrune,
+		ItemsDeleted: []string{},
+	}
+
+	if len(criteria.labels) > 0 {
+		report.Error = errBuildCacheLabelFilter.Error()
+		return report
+	}
+
+	if criteria.dryRun {
+		for _, record := range usage.BuildCache {
+			if record.InUse || record.Type == "internal" || record.Type == "frontend" {
+				continue
+			}
+
+			lastUsedAt := record.CreatedAt
+			if record.LastUsedAt != nil {
+				lastUsedAt = *record.LastUsedAt
+			}
+
+			if !criteria.until.IsZero() && !lastUsedAt.Before(criteria.until) {
+				continue
+			}
+
+			report.ItemsDeleted = append(report.ItemsDeleted, record.ID)
+			if record.Size > 0 {
+				report.SpaceReclaimed += uint64(record.Size)
+			}
+		}
+
+		return report
+	}
+
+	pruneFilters := filters.NewArgs()
+	if criteria.olderThan != "" {
+		pruneFilters.Add("until", criteria.olderThan)
+	}
+
+	pruneReport, err := cli.BuildCachePrune(ctx, types.BuildCachePruneOptions{Filters: pruneFilters})
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.ItemsDeleted = append(report.ItemsDeleted, pruneReport.CachesDeleted...)
+	report.SpaceReclaimed = pruneReport.SpaceReclaimed
+
+	return report
+}
+
+func labelFilters(labels []portainer.Pair) filters.Args {
+	args := filters.NewArgs()
+	for _, label := range labels {
+		if label.Value == "" {
+			args.Add("label", label.Name)
+			continue
+		}
+		args.Add("label", label.Name+"="+label.Value)
+	}
+	return args
+}
+
+// matchLabels reproduces the Docker label filter: every label must be present
+// and match the expected value when one is specified.
+func matchLabels(labels map[string]string, expected []portainer.Pair) bool {
+	for _, label := range expected {
+		value, ok := labels[label.Name]
+		if !ok || (label.Value != "" && value != label.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDanglingImage(image *types.ImageSummary) bool {
+	return len(image.RepoTags) == 0 || (len(image.RepoTags) == 1 && image.RepoTags[0] == "<none>:<none>")
+}
+
+func exclusiveImageSize(image *types.ImageSummary) uint64 {
+	size := image.Size
+	if image.SharedSize > 0 {
+		size -= image.SharedSize
+	}
+
+	if size < 0 {
+		return 0
+	}
+	return uint64(size)
+}
+
+func isPredefinedNetwork(network types.NetworkResource) bool {
+	return network.Ingress || network.Name == "bridge" || network.Name == "host" || network.Name == "none"
+}
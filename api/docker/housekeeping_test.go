@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_matchLabels(t *testing.T) {
+	labels := map[string]string{"com.example.env": "ci", "com.example.temporary": ""}
+
+	assert.True(t, matchLabels(labels, nil))
+	assert.True(t, matchLabels(labels, []portainer.Pair{{Name: "com.example.env", Value: "ci"}}))
+	assert.True(t, matchLabels(labels, []portainer.Pair{{Name: "com.example.temporary"}}))
+	assert.False(t, matchLabels(labels, []portainer.Pair{{Name: "com.example.env", Value: "prod"}}))
+	assert.False(t, matchLabels(labels, []portainer.Pair{{Name: "com.example.env"}, {Name: "com.example.team"}}))
+}
+
+func Test_isDanglingImage(t *testing.T) {
+	assert.True(t, isDanglingImage(&types.ImageSummary{}))
+	assert.True(t, isDanglingImage(&types.ImageSummary{RepoTags: []string{"<none>:<none>"}}))
+	assert.False(t, isDanglingImage(&types.ImageSummary{RepoTags: []string{"nginx:latest"}}))
+}
+
+func Test_PruneResources_DryRun(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+
+	removals := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			removals++
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1.37/system/df":
+			fmt.Fprintf(w, `{
+				"Images": [
+					{"Id":"sha256:dangling","RepoTags":["<none>:<none>"],"Created":%[1]d,"Size":100,"SharedSize":40,"Containers":0},
+					{"Id":"sha256:recent","RepoTags":[],"Created":%[2]d,"Size":100,"Containers":0},
+					{"Id":"sha256:used","RepoTags":[],"Created":%[1]d,"Size":100,"Containers":1},
+					{"Id":"sha256:tagged","RepoTags":["nginx:latest"],"Created":%[1]d,"Size":100,"Containers":0}
+				],
+				"Volumes": [
+					{"Name":"unused","CreatedAt":"%[3]s","UsageData":{"RefCount":0,"Size":10}},
+					{"Name":"used","CreatedAt":"%[3]s","UsageData":{"RefCount":1,"Size":10}}
+				],
+				"BuildCache": []
+			}`, old.Unix(), now.Unix(), old.Format(time.RFC3339))
+		case "/v1.37/networks":
+			fmt.Fprintf(w, `[
+				{"Id":"unused","Name":"unused","Created":"%[1]s"},
+				{"Id":"attached","Name":"attached","Created":"%[1]s"},
+				{"Id":"bridge","Name":"bridge","Created":"%[1]s"}
+			]`, old.Format(time.RFC3339Nano))
+		case "/v1.37/networks/unused":
+			fmt.Fprint(w, `{"Id":"unused","Name":"unused","Containers":{}}`)
+		case "/v1.37/networks/attached":
+			fmt.Fprint(w, `{"Id":"attached","Name":"attached","Containers":{"container":{}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+server.Listener.Addr().String()), client.WithVersion(dockerClientVersion))
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	policy := &portainer.HousekeepingPolicy{
+		PruneImages:   true,
+		PruneVolumes:  true,
+		PruneNetworks: true,
+		OlderThan:     "24h",
+		DryRun:        true,
+	}
+
+	reports, err := PruneResources(context.Background(), cli, policy)
+	assert.NoError(t, err)
+	assert.Zero(t, removals, "a dry run must not remove any resource")
+
+	assert.Equal(t, []portainer.HousekeepingOperationReport{
+		{Operation: portainer.HousekeepingOperationImagePrune, ItemsDeleted: []string{"sha256:dangling"}, SpaceReclaimed: 60},
+		{Operation: portainer.HousekeepingOperationVolumePrune, ItemsDeleted: []string{"unused"}, SpaceReclaimed: 10},
+		{Operation: portainer.HousekeepingOperationNetworkPrune, ItemsDeleted: []string{"unused"}},
+	}, reports)
+}
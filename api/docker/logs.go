@@ -0,0 +1,260 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// logStreamHeaderSize is the size of the header prefixing each frame of a multiplexed log stream
+	logStreamHeaderSize = 8
+	// logLineBufferSize is the number of log lines buffered for each container
+	logLineBufferSize = 64
+	// maxLogLineSize is the maximum size of a log line, and of a frame of a multiplexed log stream
+	maxLogLineSize = 1024 * 1024
+	// MaxLogStreams is the maximum number of containers whose logs are aggregated by a single request,
+	// each container log stream being read by its own worker
+	MaxLogStreams = 50
+)
+
+// ErrTooManyLogStreams is returned when the logs of more than MaxLogStreams containers are requested
+var ErrTooManyLogStreams = fmt.Errorf("The logs of at most %d containers can be aggregated", MaxLogStreams)
+
+var errLogFrameTooLarge = errors.New("Log stream frame exceeds the maximum line size")
+
+type (
+	// LogLine represents a single log line emitted by a container
+	LogLine struct {
+		Timestamp     time.Time `json:"Timestamp"`
+		ContainerID   string    `json:"ContainerId"`
+		ContainerName string    `json:"ContainerName"`
+		Stream        string    `json:"Stream"`
+		Message       string    `json:"Message"`
+	}
+
+	// ContainerLogsSource associates a container to the client of the node running it
+	ContainerLogsSource struct {
+		Client    *client.Client
+		Container types.Container
+	}
+
+	// LogsOptions represents the options used to retrieve the logs of multiple containers
+	LogsOptions struct {
+		Since  string
+		Until  string
+		Tail   string
+		Search string
+	}
+)
+
+// AggregateContainerLogs retrieves the logs of the specified containers concurrently, through the client of
+// the node running each container, and emits them merged by timestamp. Lines that do not contain the search
+// criteria (case insensitive) are skipped. ErrTooManyLogStreams is returned when more than MaxLogStreams
+// containers are specified, as the merge requires a worker per container.
+func AggregateContainerLogs(ctx context.Context, sources []ContainerLogsSource, options LogsOptions, emit func(line *LogLine) error) error {
+	if len(sources) > MaxLogStreams {
+		return ErrTooManyLogStreams
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	streams := make([]<-chan *LogLine, 0, len(sources))
+	for _, source := range sources {
+		lines := make(chan *LogLine, logLineBufferSize)
+		streams = append(streams, lines)
+
+		go func(source ContainerLogsSource) {
+			defer close(lines)
+
+			err := streamContainerLogs(ctx, source.Client, source.Container, options, lines)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("[WARN] [docker,logs] [message: unable to retrieve container logs] [container: %s] [err: %s]", source.Container.ID, err)
+			}
+		}(source)
+	}
+
+	return mergeLogLines(streams, emit)
+}
+
+func streamContainerLogs(ctx context.Context, cli *client.Client, container types.Container, options LogsOptions, lines chan<- *LogLine) error {
+	containerDetails, err := cli.ContainerInspect(ctx, container.ID)
+	if err != nil {
+		return err
+	}
+
+	reader, err := cli.ContainerLogs(ctx, container.ID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Since:      options.Since,
+		Until:      options.Until,
+		Tail:       options.Tail,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	containerName := strings.TrimPrefix(containerDetails.Name, "/")
+	search := strings.ToLower(options.Search)
+	tty := containerDetails.Config != nil && containerDetails.Config.Tty
+
+	return demultiplexLogStream(reader, tty, func(stream string, content []byte) error {
+		line := parseLogLine(content)
+		if search != "" && !strings.Contains(strings.ToLower(line.Message), search) {
+			return nil
+		}
+
+		line.ContainerID = container.ID
+		line.ContainerName = containerName
+		line.Stream = stream
+
+		select {
+		case lines <- line:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// demultiplexLogStream splits a Docker log stream into lines. When the container is not using a TTY,
+// the stream is multiplexed and each frame is prefixed by a header containing the stream type and the frame size.
+// Frames and lines larger than maxLogLineSize are rejected.
+func demultiplexLogStream(reader io.Reader, tty bool, emit func(stream string, line []byte) error) error {
+	if tty {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+		for scanner.Scan() {
+			err := emit("stdout", bytes.TrimRight(scanner.Bytes(), "\r"))
+			if err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+
+	pending := map[string][]byte{}
+	header := make([]byte, logStreamHeaderSize)
+
+	for {
+		_, err := io.ReadFull(reader, header)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		stream := "stdout"
+		if header[0] == 2 {
+			stream = "stderr"
+		}
+
+		frameSize := binary.BigEndian.Uint32(header[4:])
+		if frameSize > maxLogLineSize {
+			return errLogFrameTooLarge
+		}
+
+		frame := make([]byte, frameSize)
+		_, err = io.ReadFull(reader, frame)
+		if err != nil {
+			return err
+		}
+
+		buffer := append(pending[stream], frame...)
+		for {
+			index := bytes.IndexByte(buffer, '\n')
+			if index < 0 {
+				break
+			}
+
+			err = emit(stream, buffer[:index])
+			if err != nil {
+				return err
+			}
+			buffer = buffer[index+1:]
+		}
+
+		if len(buffer) > maxLogLineSize {
+			return errLogFrameTooLarge
+		}
+		pending[stream] = buffer
+	}
+
+	for _, stream := range []string{"stdout", "stderr"} {
+		if len(pending[stream]) > 0 {
+			err := emit(stream, pending[stream])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseLogLine extracts the RFC3339 timestamp prefixing a log line
+func parseLogLine(content []byte) *LogLine {
+	line := &LogLine{
+		Message: string(content),
+	}
+
+	index := bytes.IndexByte(content, ' ')
+	if index < 0 {
+		return line
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, string(content[:index]))
+	if err != nil {
+		return line
+	}
+
+	line.Timestamp = timestamp
+	line.Message = string(content[index+1:])
+	return line
+}
+
+// mergeLogLines emits the lines of all the sources ordered by timestamp.
+// Each source must already be ordered.
+func mergeLogLines(sources []<-chan *LogLine, emit func(line *LogLine) error) error {
+	heads := make([]*LogLine, len(sources))
+	for idx, source := range sources {
+		heads[idx] = <-source
+	}
+
+	for {
+		next := -1
+		for idx, head := range heads {
+			if head == nil {
+				continue
+			}
+
+			if next == -1 || head.Timestamp.Before(heads[next].Timestamp) {
+				next = idx
+			}
+		}
+
+		if next == -1 {
+			return nil
+		}
+
+		err := emit(heads[next])
+		if err != nil {
+			return err
+		}
+
+		heads[next] = <-sources[next]
+	}
+}
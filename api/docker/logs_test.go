@@ -0,0 +1,178 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func multiplexedFrame(stream byte, payload string) []byte {
+	header := make([]byte, logStreamHeaderSize)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, []byte(payload)...)
+}
+
+func Test_demultiplexLogStream(t *testing.T) {
+	t.Run("should split a multiplexed stream into lines", func(t *testing.T) {
+		stream := bytes.Buffer{}
+		stream.Write(multiplexedFrame(1, "2020-01-01T00:00:01Z first\n"))
+		stream.Write(multiplexedFrame(2, "2020-01-01T00:00:02Z sec"))
+		stream.Write(multiplexedFrame(2, "ond\n"))
+		stream.Write(multiplexedFrame(1, "2020-01-01T00:00:03Z third"))
+
+		lines := []string{}
+		streams := []string{}
+		err := demultiplexLogStream(&stream, false, func(stream string, line []byte) error {
+			streams = append(streams, stream)
+			lines = append(lines, string(line))
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2020-01-01T00:00:01Z first", "2020-01-01T00:00:02Z second", "2020-01-01T00:00:03Z third"}, lines)
+		assert.Equal(t, []string{"stdout", "stderr", "stdout"}, streams)
+	})
+
+	t.Run("should split a TTY stream into lines", func(t *testing.T) {
+		stream := bytes.NewBufferString("2020-01-01T00:00:01Z first\r\n2020-01-01T00:00:02Z second\n")
+
+		lines := []string{}
+		err := demultiplexLogStream(stream, true, func(stream string, line []byte) error {
+			lines = append(lines, string(line))
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2020-01-01T00:00:01Z first", "2020-01-01T00:00:02Z second"}, lines)
+	})
+
+	t.Run("should reject a frame larger than the maximum line size", func(t *testing.T) {
+		header := make([]byte, logStreamHeaderSize)
+		header[0] = 1
+		binary.BigEndian.PutUint32(header[4:], 0xffffffff)
+
+		err := demultiplexLogStream(bytes.NewBuffer(header), false, func(stream string, line []byte) error {
+			return nil
+		})
+
+		assert.Equal(t, errLogFrameTooLarge, err)
+	})
+}
+
+func Test_parseLogLine(t *testing.T) {
+	line := parseLogLine([]byte("2020-01-01T00:00:01.5Z hello world"))
+	assert.Equal(t, "hello world", line.Message)
+	assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 1, 500000000, time.UTC), line.Timestamp.UTC())
+
+	line = parseLogLine([]byte("no timestamp"))
+	assert.Equal(t, "no timestamp", line.Message)
+	assert.True(t, line.Timestamp.IsZero())
+}
+
+func Test_mergeLogLines(t *testing.T) {
+	source := func(seconds ...int) <-chan *LogLine {
+		lines := make(chan *LogLine, len(seconds))
+		for _, second := range seconds {
+			lines <- &LogLine{Timestamp: time.Unix(int64(second), 0)}
+		}
+		close(lines)
+		return lines
+	}
+
+	merged := []int64{}
+	err := mergeLogLines([]<-chan *LogLine{source(1, 4, 5), source(2, 3), source()}, func(line *LogLine) error {
+		merged = append(merged, line.Timestamp.Unix())
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, merged)
+}
+
+// newTestNodeClient returns a client of a Docker daemon running a single container whose logs contain
+// a line emitted at each of the specified seconds
+func newTestNodeClient(t *testing.T, containerID string, seconds ...int) (*client.Client, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.37/containers/" + containerID + "/json":
+			fmt.Fprintf(w, `{"Id":"%s","Name":"/%s","Config":{"Tty":false}}`, containerID, containerID)
+		case "/v1.37/containers/" + containerID + "/logs":
+			for _, second := range seconds {
+				w.Write(multiplexedFrame(1, fmt.Sprintf("%s %s\n", time.Unix(int64(second), 0).UTC().Format(time.RFC3339Nano), containerID)))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+server.Listener.Addr().String()), client.WithVersion(dockerClientVersion))
+	assert.NoError(t, err)
+
+	return cli, func() {
+		cli.Close()
+		server.Close()
+	}
+}
+
+func Test_AggregateContainerLogs(t *testing.T) {
+	firstNode, closeFirstNode := newTestNodeClient(t, "first", 1, 3)
+	defer closeFirstNode()
+	secondNode, closeSecondNode := newTestNodeClient(t, "second", 2, 4)
+	defer closeSecondNode()
+
+	sources := []ContainerLogsSource{
+		{Client: firstNode, Container: types.Container{ID: "first"}},
+		{Client: secondNode, Container: types.Container{ID: "second"}},
+	}
+
+	messages := []string{}
+	err := AggregateContainerLogs(context.Background(), sources, LogsOptions{}, func(line *LogLine) error {
+		messages = append(messages, line.ContainerName+":"+line.Message)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first:first", "second:second", "first:first", "second:second"}, messages)
+
+	tooManySources := make([]ContainerLogsSource, MaxLogStreams+1)
+	err = AggregateContainerLogs(context.Background(), tooManySources, LogsOptions{}, func(line *LogLine) error {
+		return nil
+	})
+	assert.Equal(t, ErrTooManyLogStreams, err)
+}
+
+func Test_EndpointNodeNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"ID":"1","Description":{"Hostname":"manager"}},{"ID":"2","Description":{"Hostname":"worker"}}]`)
+	}))
+	defer server.Close()
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+server.Listener.Addr().String()), client.WithVersion(dockerClientVersion))
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	swarmSnapshots := []portainer.DockerSnapshot{{Swarm: true}}
+
+	nodeNames, err := EndpointNodeNames(context.Background(), cli, &portainer.Endpoint{Type: portainer.AgentOnDockerEnvironment, Snapshots: swarmSnapshots})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"manager", "worker"}, nodeNames)
+
+	nodeNames, err = EndpointNodeNames(context.Background(), cli, &portainer.Endpoint{Type: portainer.DockerEnvironment, Snapshots: swarmSnapshots})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{""}, nodeNames)
+
+	nodeNames, err = EndpointNodeNames(context.Background(), cli, &portainer.Endpoint{Type: portainer.AgentOnDockerEnvironment})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{""}, nodeNames)
+}
@@ -0,0 +1,30 @@
+package docker
+
+import (
+	"context"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// EndpointNodeNames returns the names of the nodes that can be targeted individually on an endpoint.
+// Agents can target each node of a Swarm cluster, other endpoints are only reachable through a single
+// node identified by an empty name. The client must not target a specific node.
+func EndpointNodeNames(ctx context.Context, cli *client.Client, endpoint *portainer.Endpoint) ([]string, error) {
+	if endpoint.Type == portainer.DockerEnvironment || len(endpoint.Snapshots) == 0 || !endpoint.Snapshots[0].Swarm {
+		return []string{""}, nil
+	}
+
+	nodes, err := cli.NodeList(ctx, types.NodeListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	nodeNames := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		nodeNames = append(nodeNames, node.Description.Hostname)
+	}
+
+	return nodeNames, nil
+}
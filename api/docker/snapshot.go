@@ -139,6 +139,7 @@ func snapshotSwarmServices(snapshot *portainer.DockerSnapshot, cli *client.Clien
 
 	snapshot.ServiceCount = len(services)
 	snapshot.StackCount += len(stacks)
+	snapshot.SnapshotRaw.Services = services
 	return nil
 }
 
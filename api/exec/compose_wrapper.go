@@ -11,6 +11,7 @@ import (
 
 	portainer "github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/http/proxy"
+	"github.com/cloudogu/portainer-ce/api/internal/dockerconfig"
 )
 
 // ComposeWrapper is a wrapper for docker-compose binary
@@ -38,19 +39,26 @@ func (w *ComposeWrapper) ComposeSyntaxMaxVersion() string {
 	return portainer.ComposeSyntaxMaxVersion
 }
 
-// Up builds, (re)creates and starts containers in the background. Wraps `docker-compose up -d` command
-func (w *ComposeWrapper) Up(stack *portainer.Stack, endpoint *portainer.Endpoint) error {
-	_, err := w.command([]string{"up", "-d"}, stack, endpoint)
+// Up builds, (re)creates and starts containers in the background. Wraps `docker-compose up -d` command.
+// The images are pulled with the credentials of the specified registries.
+func (w *ComposeWrapper) Up(stack *portainer.Stack, endpoint *portainer.Endpoint, dockerhub *portainer.DockerHub, registries []portainer.Registry) error {
+	configPath, cleanUp, err := dockerconfig.Create(w.dataPath, dockerconfig.RegistryAuths(dockerhub, registries))
+	if err != nil {
+		return err
+	}
+	defer cleanUp()
+
+	_, err = w.command([]string{"up", "-d"}, stack, endpoint, configPath)
 	return err
 }
 
 // Down stops and removes containers, networks, images, and volumes. Wraps `docker-compose down --remove-orphans` command
 func (w *ComposeWrapper) Down(stack *portainer.Stack, endpoint *portainer.Endpoint) error {
-	_, err := w.command([]string{"down", "--remove-orphans"}, stack, endpoint)
+	_, err := w.command([]string{"down", "--remove-orphans"}, stack, endpoint, w.dataPath)
 	return err
 }
 
-func (w *ComposeWrapper) command(command []string, stack *portainer.Stack, endpoint *portainer.Endpoint) ([]byte, error) {
+func (w *ComposeWrapper) command(command []string, stack *portainer.Stack, endpoint *portainer.Endpoint, configPath string) ([]byte, error) {
 	if endpoint == nil {
 		return nil, errors.New("cannot call a compose command on an empty endpoint")
 	}
@@ -82,7 +90,7 @@ func (w *ComposeWrapper) command(command []string, stack *portainer.Stack, endpo
 	var stderr bytes.Buffer
 	cmd := exec.Command(program, args...)
 	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, fmt.Sprintf("DOCKER_CONFIG=%s", w.dataPath))
+	cmd.Env = append(cmd.Env, fmt.Sprintf("DOCKER_CONFIG=%s", configPath))
 	cmd.Stderr = &stderr
 
 	out, err := cmd.Output()
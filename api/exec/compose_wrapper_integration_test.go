@@ -44,7 +44,7 @@ func Test_UpAndDown(t *testing.T) {
 
 	w := NewComposeWrapper("", "", nil)
 
-	err := w.Up(stack, endpoint)
+	err := w.Up(stack, endpoint, &portainer.DockerHub{}, nil)
 	if err != nil {
 		t.Fatalf("Error calling docker-compose up: %s", err)
 	}
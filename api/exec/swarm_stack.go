@@ -11,6 +11,7 @@ import (
 	"runtime"
 
 	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/dockerconfig"
 )
 
 // SwarmStackManager represents a service for managing stacks.
@@ -23,7 +24,8 @@ type SwarmStackManager struct {
 }
 
 // NewSwarmStackManager initializes a new SwarmStackManager service.
-// It also updates the configuration of the Docker CLI binary.
+// It also updates the configuration of the Docker CLI binary and removes the
+// configurations left by the deployments of a previous run.
 func NewSwarmStackManager(binaryPath, dataPath string, signatureService portainer.DigitalSignatureService, fileService portainer.FileService, reverseTunnelService portainer.ReverseTunnelService) (*SwarmStackManager, error) {
 	manager := &SwarmStackManager{
 		binaryPath:           binaryPath,
@@ -38,36 +40,25 @@ func NewSwarmStackManager(binaryPath, dataPath string, signatureService portaine
 		return nil, err
 	}
 
-	return manager, nil
-}
-
-// Login executes the docker login command against a list of registries (including DockerHub).
-func (manager *SwarmStackManager) Login(dockerhub *portainer.DockerHub, registries []portainer.Registry, endpoint *portainer.Endpoint) {
-	command, args := manager.prepareDockerCommandAndArgs(manager.binaryPath, manager.dataPath, endpoint)
-	for _, registry := range registries {
-		if registry.Authentication {
-			registryArgs := append(args, "login", "--username", registry.Username, "--password", registry.Password, registry.URL)
-			runCommandAndCaptureStdErr(command, registryArgs, nil, "")
-		}
+	err = dockerconfig.RemoveAll(dataPath)
+	if err != nil {
+		return nil, err
 	}
 
-	if dockerhub.Authentication {
-		dockerhubArgs := append(args, "login", "--username", dockerhub.Username, "--password", dockerhub.Password)
-		runCommandAndCaptureStdErr(command, dockerhubArgs, nil, "")
-	}
+	return manager, nil
 }
 
-// Logout executes the docker logout command.
-func (manager *SwarmStackManager) Logout(endpoint *portainer.Endpoint) error {
-	command, args := manager.prepareDockerCommandAndArgs(manager.binaryPath, manager.dataPath, endpoint)
-	args = append(args, "logout")
-	return runCommandAndCaptureStdErr(command, args, nil, "")
-}
+// Deploy executes the docker stack deploy command. The command runs with a Docker configuration created for
+// the deployment, containing only the credentials of the specified registries (including DockerHub).
+func (manager *SwarmStackManager) Deploy(stack *portainer.Stack, prune bool, endpoint *portainer.Endpoint, dockerhub *portainer.DockerHub, registries []portainer.Registry) error {
+	configPath, cleanUp, err := dockerconfig.Create(manager.dataPath, dockerconfig.RegistryAuths(dockerhub, registries))
+	if err != nil {
+		return err
+	}
+	defer cleanUp()
 
-// Deploy executes the docker stack deploy command.
-func (manager *SwarmStackManager) Deploy(stack *portainer.Stack, prune bool, endpoint *portainer.Endpoint) error {
 	stackFilePath := path.Join(stack.ProjectPath, stack.EntryPoint)
-	command, args := manager.prepareDockerCommandAndArgs(manager.binaryPath, manager.dataPath, endpoint)
+	command, args := manager.prepareDockerCommandAndArgs(manager.binaryPath, configPath, endpoint)
 
 	if prune {
 		args = append(args, "stack", "deploy", "--prune", "--with-registry-auth", "--compose-file", stackFilePath, stack.Name)
@@ -110,7 +101,7 @@ func runCommandAndCaptureStdErr(command string, args []string, env []string, wor
 	return nil
 }
 
-func (manager *SwarmStackManager) prepareDockerCommandAndArgs(binaryPath, dataPath string, endpoint *portainer.Endpoint) (string, []string) {
+func (manager *SwarmStackManager) prepareDockerCommandAndArgs(binaryPath, configPath string, endpoint *portainer.Endpoint) (string, []string) {
 	// Assume Linux as a default
 	command := path.Join(binaryPath, "docker")
 
@@ -119,7 +110,7 @@ func (manager *SwarmStackManager) prepareDockerCommandAndArgs(binaryPath, dataPa
 	}
 
 	args := make([]string, 0)
-	args = append(args, "--config", dataPath)
+	args = append(args, "--config", configPath)
 
 	endpointURL := endpoint.URL
 	if endpoint.Type == portainer.EdgeAgentOnDockerEnvironment {
@@ -166,6 +157,9 @@ func (manager *SwarmStackManager) updateDockerCLIConfiguration(dataPath string)
 	headersObject["X-PortainerAgent-Signature"] = signature
 	headersObject["X-PortainerAgent-PublicKey"] = manager.signatureService.EncodedPublicKey()
 
+	// the registry credentials are only written in the configurations created for each deployment
+	delete(config, "auths")
+
 	err = manager.fileService.WriteJSONToFile(configFilePath, config)
 	if err != nil {
 		return err
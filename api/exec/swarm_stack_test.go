@@ -0,0 +1,101 @@
+package exec
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDockerScript copies the Docker configuration used by the command to the output
+// directory, in a file named after the stack (the last argument of the command)
+const fakeDockerScript = `#!/bin/sh
+config="$2"
+for stack; do :; done
+sleep 0.1
+cp "$config/config.json" "$OUTPUT_PATH/$stack.json"
+`
+
+func Test_SwarmStackManager_ConcurrentDeployments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake Docker binary is a shell script")
+	}
+
+	binaryPath, err := ioutil.TempDir("", "swarm-binary")
+	assert.NoError(t, err)
+	defer os.RemoveAll(binaryPath)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(binaryPath, "docker"), []byte(fakeDockerScript), 0700))
+
+	dataPath, err := ioutil.TempDir("", "swarm-data")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	outputPath, err := ioutil.TempDir("", "swarm-output")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outputPath)
+
+	manager := &SwarmStackManager{binaryPath: binaryPath, dataPath: dataPath}
+	endpoint := &portainer.Endpoint{URL: "tcp://127.0.0.1:2375"}
+
+	const deployments = 10
+	var wg sync.WaitGroup
+	for i := 0; i < deployments; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			stack := &portainer.Stack{
+				Name:        fmt.Sprintf("stack%d", i),
+				ProjectPath: dataPath,
+				EntryPoint:  "docker-compose.yml",
+				Env:         []portainer.Pair{{Name: "OUTPUT_PATH", Value: outputPath}},
+			}
+			registries := []portainer.Registry{
+				{Name: "shared", URL: "shared.example.com", Authentication: true, Username: fmt.Sprintf("user%d", i), Password: "secret"},
+			}
+			if i%2 == 0 {
+				registries = append(registries, portainer.Registry{Name: "private", URL: fmt.Sprintf("private%d.example.com", i), Authentication: true, Username: "owner", Password: "secret"})
+			}
+
+			err := manager.Deploy(stack, false, endpoint, &portainer.DockerHub{}, registries)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < deployments; i++ {
+		data, err := ioutil.ReadFile(filepath.Join(outputPath, fmt.Sprintf("stack%d.json", i)))
+		assert.NoError(t, err)
+
+		var config struct {
+			Auths map[string]struct {
+				Auth string `json:"auth"`
+			} `json:"auths"`
+		}
+		assert.NoError(t, json.Unmarshal(data, &config))
+
+		expected := []string{"shared.example.com"}
+		if i%2 == 0 {
+			expected = append(expected, fmt.Sprintf("private%d.example.com", i))
+		}
+
+		servers := make([]string, 0)
+		for server := range config.Auths {
+			servers = append(servers, server)
+		}
+		assert.ElementsMatch(t, expected, servers)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("user%d:secret", i))), config.Auths["shared.example.com"].Auth)
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(dataPath, "docker_configs"))
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "the configurations of the deployments must be removed")
+}
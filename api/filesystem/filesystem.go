@@ -14,6 +14,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"strconv"
 )
 
 const (
@@ -31,12 +32,18 @@ const (
 	ComposeStorePath = "compose"
 	// ComposeFileDefaultName represents the default name of a compose file.
 	ComposeFileDefaultName = "docker-compose.yml"
+	// ManifestFileDefaultName represents the default name of a Kubernetes manifest file.
+	ManifestFileDefaultName = "deployment.yml"
 	// EdgeStackStorePath represents the subfolder where edge stack files are stored in the file store folder.
 	EdgeStackStorePath = "edge_stacks"
 	// PrivateKeyFile represents the name on disk of the file containing the private key.
 	PrivateKeyFile = "portainer.key"
 	// PublicKeyFile represents the name on disk of the file containing the public key.
 	PublicKeyFile = "portainer.pub"
+	// SecretKeyFile represents the name on disk of the file containing the key used to encrypt the secrets of the database.
+	SecretKeyFile = "portainer_secret.key"
+	// SecretKeyPemHeader represents the header of the PEM file containing the secret key.
+	SecretKeyPemHeader = "PORTAINER SECRET KEY"
 	// BinaryStorePath represents the subfolder where binaries are stored in the file store folder.
 	BinaryStorePath = "bin"
 	// EdgeJobStorePath represents the subfolder where schedule files are stored.
@@ -46,6 +53,10 @@ const (
 	ExtensionRegistryManagementStorePath = "extensions"
 	// CustomTemplateStorePath represents the subfolder where custom template files are stored in the file store folder.
 	CustomTemplateStorePath = "custom_templates"
+	// CustomTemplateVersionStorePath represents the subfolder where the files of the versions of the custom templates are stored in the file store folder.
+	CustomTemplateVersionStorePath = "custom_template_versions"
+	// TemplateSourceStorePath represents the subfolder where the files of the template sources are stored in the file store folder.
+	TemplateSourceStorePath = "template_sources"
 	// TempPath represent the subfolder where temporary files are saved
 	TempPath = "tmp"
 )
@@ -341,6 +352,21 @@ func (service *Service) LoadKeyPair() ([]byte, []byte, error) {
 	return privateKey, publicKey, nil
 }
 
+// SecretKeyFileExists checks for the existence of the secret key file.
+func (service *Service) SecretKeyFileExists() (bool, error) {
+	return service.FileExists(path.Join(service.fileStorePath, SecretKeyFile))
+}
+
+// StoreSecretKey stores the key used to encrypt the secrets of the database as a PEM file on disk.
+func (service *Service) StoreSecretKey(key []byte) error {
+	return service.createPEMFileInStore(key, SecretKeyPemHeader, SecretKeyFile)
+}
+
+// LoadSecretKey retrieves the content of the secret key file on disk.
+func (service *Service) LoadSecretKey() ([]byte, error) {
+	return service.getContentFromPEMFile(SecretKeyFile)
+}
+
 // createDirectoryInStore creates a new directory in the file store
 func (service *Service) createDirectoryInStore(name string) error {
 	path := path.Join(service.fileStorePath, name)
@@ -395,6 +421,32 @@ func (service *Service) getContentFromPEMFile(filePath string) ([]byte, error) {
 	return block.Bytes, nil
 }
 
+// GetTemplateSourceProjectPath returns the absolute path on the FS for a template source based
+// on its identifier.
+func (service *Service) GetTemplateSourceProjectPath(identifier string) string {
+	return path.Join(service.fileStorePath, TemplateSourceStorePath, identifier)
+}
+
+// StoreTemplateSourceFileFromBytes creates a subfolder in the TemplateSourceStorePath and stores a new file from bytes.
+// It returns the path to the folder where the file is stored.
+func (service *Service) StoreTemplateSourceFileFromBytes(identifier, fileName string, data []byte) (string, error) {
+	templateSourceStorePath := path.Join(TemplateSourceStorePath, identifier)
+	err := service.createDirectoryInStore(templateSourceStorePath)
+	if err != nil {
+		return "", err
+	}
+
+	filePath := path.Join(templateSourceStorePath, fileName)
+	r := bytes.NewReader(data)
+
+	err = service.createFileInStore(filePath, r)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(service.fileStorePath, templateSourceStorePath), nil
+}
+
 // GetCustomTemplateProjectPath returns the absolute path on the FS for a custom template based
 // on its identifier.
 func (service *Service) GetCustomTemplateProjectPath(identifier string) string {
@@ -421,6 +473,37 @@ func (service *Service) StoreCustomTemplateFileFromBytes(identifier, fileName st
 	return path.Join(service.fileStorePath, customTemplateStorePath), nil
 }
 
+// GetCustomTemplateVersionsPath returns the absolute path on the FS of the folder containing the files
+// of the versions of a custom template based on its identifier.
+func (service *Service) GetCustomTemplateVersionsPath(identifier string) string {
+	return path.Join(service.fileStorePath, CustomTemplateVersionStorePath, identifier)
+}
+
+// GetCustomTemplateVersionFilePath returns the absolute path on the FS of the file of a version of a custom template.
+func (service *Service) GetCustomTemplateVersionFilePath(identifier string, version int) string {
+	return path.Join(service.GetCustomTemplateVersionsPath(identifier), strconv.Itoa(version)+".yml")
+}
+
+// StoreCustomTemplateVersionFileFromBytes creates a subfolder in the CustomTemplateVersionStorePath and stores the file
+// of a version of a custom template from bytes. It returns the path to the file.
+func (service *Service) StoreCustomTemplateVersionFileFromBytes(identifier string, version int, data []byte) (string, error) {
+	versionStorePath := path.Join(CustomTemplateVersionStorePath, identifier)
+	err := service.createDirectoryInStore(versionStorePath)
+	if err != nil {
+		return "", err
+	}
+
+	versionFilePath := path.Join(versionStorePath, strconv.Itoa(version)+".yml")
+	r := bytes.NewReader(data)
+
+	err = service.createFileInStore(versionFilePath, r)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(service.fileStorePath, versionFilePath), nil
+}
+
 // GetEdgeJobFolder returns the absolute path on the filesystem for an Edge job based
 // on its identifier.
 func (service *Service) GetEdgeJobFolder(identifier string) string {
@@ -2,15 +2,18 @@ package git
 
 import (
 	"crypto/tls"
+	"errors"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
 	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
 )
 
 // Service represents a service for managing Git.
@@ -48,6 +51,55 @@ func (service *Service) ClonePrivateRepositoryWithBasicAuth(repositoryURL, refer
 	return cloneRepository(repositoryURL, referenceName, destination)
 }
 
+// LatestCommitID returns the identifier of the commit referenced by the specified reference of a remote
+// repository, or by its HEAD when no reference is specified. Credentials are optional.
+func (service *Service) LatestCommitID(repositoryURL, referenceName, username, password string) (string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{repositoryURL},
+	})
+
+	options := &git.ListOptions{}
+	if username != "" || password != "" {
+		options.Auth = &githttp.BasicAuth{
+			Username: username,
+			Password: password,
+		}
+	}
+
+	references, err := remote.List(options)
+	if err != nil {
+		return "", err
+	}
+
+	if referenceName == "" {
+		referenceName = string(plumbing.HEAD)
+	}
+
+	for _, reference := range references {
+		if reference.Name().String() != referenceName {
+			continue
+		}
+
+		if reference.Type() == plumbing.SymbolicReference {
+			referenceName = reference.Target().String()
+			return findReferenceHash(references, referenceName)
+		}
+		return reference.Hash().String(), nil
+	}
+
+	return "", errors.New("Reference not found in the repository")
+}
+
+func findReferenceHash(references []*plumbing.Reference, referenceName string) (string, error) {
+	for _, reference := range references {
+		if reference.Name().String() == referenceName {
+			return reference.Hash().String(), nil
+		}
+	}
+	return "", errors.New("Reference not found in the repository")
+}
+
 func cloneRepository(repositoryURL, referenceName, destination string) error {
 	options := &git.CloneOptions{
 		URL: repositoryURL,
@@ -0,0 +1,76 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// createTestRepository creates a local repository with a commit on master and another one on a develop branch
+func createTestRepository(t *testing.T) (string, string, string) {
+	repositoryPath, err := ioutil.TempDir("", "git")
+	assert.NoError(t, err)
+
+	repository, err := git.PlainInit(repositoryPath, false)
+	assert.NoError(t, err)
+
+	worktree, err := repository.Worktree()
+	assert.NoError(t, err)
+
+	commit := func(content string) string {
+		assert.NoError(t, ioutil.WriteFile(path.Join(repositoryPath, "docker-compose.yml"), []byte(content), 0644))
+		_, err := worktree.Add("docker-compose.yml")
+		assert.NoError(t, err)
+
+		hash, err := worktree.Commit(content, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		assert.NoError(t, err)
+		return hash.String()
+	}
+
+	masterCommit := commit("version: '3'")
+
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("develop"), Create: true})
+	assert.NoError(t, err)
+	developCommit := commit("version: '3.7'")
+
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.Master})
+	assert.NoError(t, err)
+
+	return repositoryPath, masterCommit, developCommit
+}
+
+func Test_LatestCommitID(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("the git binary is required to list the references of a local repository")
+	}
+
+	repositoryPath, masterCommit, developCommit := createTestRepository(t)
+	defer os.RemoveAll(repositoryPath)
+
+	service := &Service{}
+
+	commitID, err := service.LatestCommitID(repositoryPath, "", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, masterCommit, commitID, "HEAD must be resolved to the commit of its branch")
+
+	commitID, err = service.LatestCommitID(repositoryPath, "refs/heads/master", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, masterCommit, commitID)
+
+	commitID, err = service.LatestCommitID(repositoryPath, "refs/heads/develop", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, developCommit, commitID)
+
+	_, err = service.LatestCommitID(repositoryPath, "refs/heads/missing", "", "")
+	assert.Error(t, err)
+}
@@ -5,6 +5,8 @@ import "errors"
 var (
 	// ErrEndpointAccessDenied Access denied to endpoint error
 	ErrEndpointAccessDenied = errors.New("Access denied to endpoint")
+	// ErrRegistryAccessDenied Access denied to registry error
+	ErrRegistryAccessDenied = errors.New("Access denied to registry")
 	// ErrUnauthorized Unauthorized error
 	ErrUnauthorized = errors.New("Unauthorized")
 	// ErrResourceAccessDenied Access denied to resource error
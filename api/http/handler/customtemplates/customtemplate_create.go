@@ -3,13 +3,16 @@ package customtemplates
 import (
 	"errors"
 	"net/http"
+	"path"
 	"strconv"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	portainer "github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/filesystem"
 	"github.com/cloudogu/portainer-ce/api/http/security"
 	"github.com/cloudogu/portainer-ce/api/internal/authorization"
+	"github.com/cloudogu/portainer-ce/api/internal/customtemplate"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -33,6 +36,21 @@ func (handler *Handler) customTemplateCreate(w http.ResponseWriter, r *http.Requ
 
 	customTemplate.CreatedByUserID = tokenData.ID
 
+	fileContent, err := handler.FileService.GetFileContent(path.Join(customTemplate.ProjectPath, customTemplate.EntryPoint))
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve custom template file from disk", err}
+	}
+
+	commitHash := ""
+	if customTemplate.GitConfig != nil {
+		commitHash = customTemplate.GitConfig.CommitHash
+	}
+
+	err = customtemplate.AddVersion(handler.FileService, customTemplate, fileContent, commitHash, tokenData.ID)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist custom template version on disk", err}
+	}
+
 	customTemplates, err := handler.DataStore.CustomTemplate().CustomTemplates()
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve custom templates from the database", err}
@@ -58,6 +76,7 @@ func (handler *Handler) customTemplateCreate(w http.ResponseWriter, r *http.Requ
 
 	customTemplate.ResourceControl = resourceControl
 
+	hideCustomTemplateSecrets(customTemplate)
 	return response.JSON(w, customTemplate)
 }
 
@@ -81,6 +100,7 @@ type customTemplateFromFileContentPayload struct {
 	Note        string
 	Platform    portainer.CustomTemplatePlatform
 	Type        portainer.StackType
+	Variables   []portainer.CustomTemplateVariable
 }
 
 func (payload *customTemplateFromFileContentPayload) Validate(r *http.Request) error {
@@ -99,7 +119,7 @@ func (payload *customTemplateFromFileContentPayload) Validate(r *http.Request) e
 	if payload.Type != portainer.DockerSwarmStack && payload.Type != portainer.DockerComposeStack {
 		return errors.New("Invalid custom template type")
 	}
-	return nil
+	return customtemplate.ValidateVariables(payload.Variables)
 }
 
 func (handler *Handler) createCustomTemplateFromFileContent(r *http.Request) (*portainer.CustomTemplate, error) {
@@ -119,6 +139,7 @@ func (handler *Handler) createCustomTemplateFromFileContent(r *http.Request) (*p
 		Platform:    (payload.Platform),
 		Type:        (payload.Type),
 		Logo:        payload.Logo,
+		Variables:   payload.Variables,
 	}
 
 	templateFolder := strconv.Itoa(customTemplateID)
@@ -144,6 +165,9 @@ type customTemplateFromGitRepositoryPayload struct {
 	RepositoryUsername          string
 	RepositoryPassword          string
 	ComposeFilePathInRepository string
+	Variables                   []portainer.CustomTemplateVariable
+	// Periodically check the repository for a new commit on the reference and update the template
+	RepositoryAutoSync bool
 }
 
 func (payload *customTemplateFromGitRepositoryPayload) Validate(r *http.Request) error {
@@ -168,7 +192,7 @@ func (payload *customTemplateFromGitRepositoryPayload) Validate(r *http.Request)
 	if payload.Type != portainer.DockerSwarmStack && payload.Type != portainer.DockerComposeStack {
 		return errors.New("Invalid custom template type")
 	}
-	return nil
+	return customtemplate.ValidateVariables(payload.Variables)
 }
 
 func (handler *Handler) createCustomTemplateFromGitRepository(r *http.Request) (*portainer.CustomTemplate, error) {
@@ -188,8 +212,27 @@ func (handler *Handler) createCustomTemplateFromGitRepository(r *http.Request) (
 		Platform:    payload.Platform,
 		Type:        payload.Type,
 		Logo:        payload.Logo,
+		Variables:   payload.Variables,
+	}
+
+	customTemplate.GitConfig = &portainer.CustomTemplateGitConfig{
+		URL:            payload.RepositoryURL,
+		ReferenceName:  payload.RepositoryReferenceName,
+		Authentication: payload.RepositoryAuthentication,
+		AutoSync:       payload.RepositoryAutoSync,
+		LastSync:       time.Now().Unix(),
+	}
+	if payload.RepositoryAuthentication {
+		customTemplate.GitConfig.Username = payload.RepositoryUsername
+		customTemplate.GitConfig.Password = payload.RepositoryPassword
 	}
 
+	commitHash, err := handler.GitService.LatestCommitID(payload.RepositoryURL, payload.RepositoryReferenceName, customTemplate.GitConfig.Username, customTemplate.GitConfig.Password)
+	if err != nil {
+		return nil, err
+	}
+	customTemplate.GitConfig.CommitHash = commitHash
+
 	projectPath := handler.FileService.GetCustomTemplateProjectPath(strconv.Itoa(customTemplateID))
 	customTemplate.ProjectPath = projectPath
 
@@ -218,6 +261,7 @@ type customTemplateFromFileUploadPayload struct {
 	Platform    portainer.CustomTemplatePlatform
 	Type        portainer.StackType
 	FileContent []byte
+	Variables   []portainer.CustomTemplateVariable
 }
 
 func (payload *customTemplateFromFileUploadPayload) Validate(r *http.Request) error {
@@ -257,7 +301,14 @@ func (payload *customTemplateFromFileUploadPayload) Validate(r *http.Request) er
 	}
 	payload.FileContent = composeFileContent
 
-	return nil
+	var variables []portainer.CustomTemplateVariable
+	err = request.RetrieveMultiPartFormJSONValue(r, "Variables", &variables, true)
+	if err != nil {
+		return errors.New("Invalid custom template variables")
+	}
+	payload.Variables = variables
+
+	return customtemplate.ValidateVariables(payload.Variables)
 }
 
 func (handler *Handler) createCustomTemplateFromFileUpload(r *http.Request) (*portainer.CustomTemplate, error) {
@@ -277,6 +328,7 @@ func (handler *Handler) createCustomTemplateFromFileUpload(r *http.Request) (*po
 		Type:        payload.Type,
 		Logo:        payload.Logo,
 		EntryPoint:  filesystem.ComposeFileDefaultName,
+		Variables:   payload.Variables,
 	}
 
 	templateFolder := strconv.Itoa(customTemplateID)
@@ -51,6 +51,11 @@ func (handler *Handler) customTemplateDelete(w http.ResponseWriter, r *http.Requ
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove custom template files from disk", err}
 	}
 
+	err = handler.FileService.RemoveDirectory(handler.FileService.GetCustomTemplateVersionsPath(strconv.Itoa(customTemplateID)))
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove custom template versions from disk", err}
+	}
+
 	if resourceControl != nil {
 		err = handler.DataStore.ResourceControl().DeleteResourceControl(resourceControl.ID)
 		if err != nil {
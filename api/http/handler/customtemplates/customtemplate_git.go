@@ -0,0 +1,135 @@
+package customtemplates
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperrors "github.com/cloudogu/portainer-ce/api/http/errors"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type customTemplateGitUpdatePayload struct {
+	ReferenceName            *string
+	AutoSync                 *bool
+	RepositoryAuthentication *bool
+	RepositoryUsername       string
+	RepositoryPassword       string
+}
+
+func (payload *customTemplateGitUpdatePayload) Validate(r *http.Request) error {
+	if payload.RepositoryAuthentication != nil && *payload.RepositoryAuthentication && govalidator.IsNull(payload.RepositoryUsername) {
+		return errors.New("Invalid repository credentials. Username must be specified when authentication is enabled")
+	}
+	return nil
+}
+
+// PUT request on /api/custom_templates/:id/git
+// Updates the Git settings of a custom template linked to a Git repository. The password is kept
+// when authentication remains enabled and no new password is specified.
+func (handler *Handler) customTemplateGitUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	customTemplateID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid Custom template identifier route variable", err}
+	}
+
+	var payload customTemplateGitUpdatePayload
+	err = request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	customTemplate, handlerErr := handler.editableGitCustomTemplate(r, portainer.CustomTemplateID(customTemplateID))
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	gitConfig := customTemplate.GitConfig
+
+	if payload.ReferenceName != nil && *payload.ReferenceName != gitConfig.ReferenceName {
+		gitConfig.ReferenceName = *payload.ReferenceName
+		gitConfig.CommitHash = ""
+	}
+
+	if payload.AutoSync != nil {
+		gitConfig.AutoSync = *payload.AutoSync
+	}
+
+	if payload.RepositoryAuthentication != nil {
+		if *payload.RepositoryAuthentication {
+			password := payload.RepositoryPassword
+			if password == "" && gitConfig.Authentication {
+				password = gitConfig.Password
+			}
+			if password == "" {
+				return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", errors.New("Invalid repository credentials. Password must be specified when authentication is enabled")}
+			}
+			gitConfig.Username = payload.RepositoryUsername
+			gitConfig.Password = password
+		} else {
+			gitConfig.Username = ""
+			gitConfig.Password = ""
+		}
+		gitConfig.Authentication = *payload.RepositoryAuthentication
+	}
+
+	err = handler.DataStore.CustomTemplate().UpdateCustomTemplate(customTemplate.ID, customTemplate)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist custom template changes inside the database", err}
+	}
+
+	hideCustomTemplateSecrets(customTemplate)
+	return response.JSON(w, customTemplate)
+}
+
+// POST request on /api/custom_templates/:id/git/sync
+// Checks the repository of the custom template for a new commit and records a new version of the template when one is found
+func (handler *Handler) customTemplateGitSync(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	customTemplateID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid Custom template identifier route variable", err}
+	}
+
+	customTemplate, handlerErr := handler.editableGitCustomTemplate(r, portainer.CustomTemplateID(customTemplateID))
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	customTemplate, err = handler.GitSyncService.SyncCustomTemplate(customTemplate.ID)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to sync the custom template with its Git repository", err}
+	}
+
+	hideCustomTemplateSecrets(customTemplate)
+	return response.JSON(w, customTemplate)
+}
+
+// editableGitCustomTemplate returns a custom template linked to a Git repository that can be edited by the user of the request
+func (handler *Handler) editableGitCustomTemplate(r *http.Request, customTemplateID portainer.CustomTemplateID) (*portainer.CustomTemplate, *httperror.HandlerError) {
+	customTemplate, err := handler.DataStore.CustomTemplate().CustomTemplate(customTemplateID)
+	if err == bolterrors.ErrObjectNotFound {
+		return nil, &httperror.HandlerError{http.StatusNotFound, "Unable to find a custom template with the specified identifier inside the database", err}
+	} else if err != nil {
+		return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a custom template with the specified identifier inside the database", err}
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve info from request context", err}
+	}
+
+	if !userCanEditTemplate(customTemplate, securityContext) {
+		return nil, &httperror.HandlerError{http.StatusForbidden, "Access denied to resource", httperrors.ErrResourceAccessDenied}
+	}
+
+	if customTemplate.GitConfig == nil {
+		return nil, &httperror.HandlerError{http.StatusBadRequest, "The custom template is not linked to a Git repository", errors.New("Missing Git configuration")}
+	}
+
+	return customTemplate, nil
+}
@@ -45,5 +45,6 @@ func (handler *Handler) customTemplateInspect(w http.ResponseWriter, r *http.Req
 		customTemplate.ResourceControl = resourceControl
 	}
 
+	hideCustomTemplateSecrets(customTemplate)
 	return response.JSON(w, customTemplate)
 }
@@ -47,6 +47,10 @@ func (handler *Handler) customTemplateList(w http.ResponseWriter, r *http.Reques
 		customTemplates = authorization.FilterAuthorizedCustomTemplates(customTemplates, user, userTeamIDs)
 	}
 
+	for idx := range customTemplates {
+		hideCustomTemplateSecrets(&customTemplates[idx])
+	}
+
 	return response.JSON(w, customTemplates)
 }
 
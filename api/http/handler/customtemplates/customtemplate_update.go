@@ -3,6 +3,8 @@ package customtemplates
 import (
 	"errors"
 	"net/http"
+	"path"
+	"reflect"
 	"strconv"
 
 	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
@@ -11,6 +13,7 @@ import (
 	portainer "github.com/cloudogu/portainer-ce/api"
 	httperrors "github.com/cloudogu/portainer-ce/api/http/errors"
 	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/customtemplate"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -24,6 +27,7 @@ type customTemplateUpdatePayload struct {
 	Platform    portainer.CustomTemplatePlatform
 	Type        portainer.StackType
 	FileContent string
+	Variables   []portainer.CustomTemplateVariable
 }
 
 func (payload *customTemplateUpdatePayload) Validate(r *http.Request) error {
@@ -42,7 +46,7 @@ func (payload *customTemplateUpdatePayload) Validate(r *http.Request) error {
 	if govalidator.IsNull(payload.Description) {
 		return errors.New("Invalid custom template description")
 	}
-	return nil
+	return customtemplate.ValidateVariables(payload.Variables)
 }
 
 func (handler *Handler) customTemplateUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -85,23 +89,53 @@ func (handler *Handler) customTemplateUpdate(w http.ResponseWriter, r *http.Requ
 		return &httperror.HandlerError{http.StatusForbidden, "Access denied to resource", httperrors.ErrResourceAccessDenied}
 	}
 
-	templateFolder := strconv.Itoa(customTemplateID)
-	_, err = handler.FileService.StoreCustomTemplateFileFromBytes(templateFolder, customTemplate.EntryPoint, []byte(payload.FileContent))
+	fileContent, err := handler.FileService.GetFileContent(path.Join(customTemplate.ProjectPath, customTemplate.EntryPoint))
 	if err != nil {
-		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist updated custom template file on disk", err}
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve custom template file from disk", err}
 	}
 
+	fileChanged := string(fileContent) != payload.FileContent
+	if fileChanged && customTemplate.GitConfig != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", errors.New("The file of a custom template linked to a Git repository cannot be updated")}
+	}
+
+	if fileChanged {
+		templateFolder := strconv.Itoa(customTemplateID)
+		_, err = handler.FileService.StoreCustomTemplateFileFromBytes(templateFolder, customTemplate.EntryPoint, []byte(payload.FileContent))
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist updated custom template file on disk", err}
+		}
+	}
+
+	variablesChanged := payload.Variables != nil && !reflect.DeepEqual(customTemplate.Variables, payload.Variables)
+
 	customTemplate.Title = payload.Title
 	customTemplate.Logo = payload.Logo
 	customTemplate.Description = payload.Description
 	customTemplate.Note = payload.Note
 	customTemplate.Platform = payload.Platform
 	customTemplate.Type = payload.Type
+	if payload.Variables != nil {
+		customTemplate.Variables = payload.Variables
+	}
+
+	if fileChanged || variablesChanged {
+		commitHash := ""
+		if customTemplate.GitConfig != nil {
+			commitHash = customTemplate.GitConfig.CommitHash
+		}
+
+		err = customtemplate.AddVersion(handler.FileService, customTemplate, []byte(payload.FileContent), commitHash, securityContext.UserID)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist custom template version on disk", err}
+		}
+	}
 
 	err = handler.DataStore.CustomTemplate().UpdateCustomTemplate(customTemplate.ID, customTemplate)
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist custom template changes inside the database", err}
 	}
 
+	hideCustomTemplateSecrets(customTemplate)
 	return response.JSON(w, customTemplate)
 }
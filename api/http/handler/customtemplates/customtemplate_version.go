@@ -0,0 +1,92 @@
+package customtemplates
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperrors "github.com/cloudogu/portainer-ce/api/http/errors"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/customtemplate"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/custom_templates/:id/versions
+// Returns the versions of a custom template, most recent first
+func (handler *Handler) customTemplateVersionList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	customTemplateID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid Custom template identifier route variable", err}
+	}
+
+	customTemplate, handlerErr := handler.accessibleCustomTemplate(r, portainer.CustomTemplateID(customTemplateID))
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	versions := make([]portainer.CustomTemplateVersion, 0, len(customTemplate.History))
+	for idx := len(customTemplate.History) - 1; idx >= 0; idx-- {
+		versions = append(versions, customTemplate.History[idx])
+	}
+
+	return response.JSON(w, versions)
+}
+
+// GET request on /api/custom_templates/:id/versions/:version/file
+func (handler *Handler) customTemplateVersionFile(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	customTemplateID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid Custom template identifier route variable", err}
+	}
+
+	version, err := request.RetrieveNumericRouteVariableValue(r, "version")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid version route variable", err}
+	}
+
+	customTemplate, handlerErr := handler.accessibleCustomTemplate(r, portainer.CustomTemplateID(customTemplateID))
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	if customtemplate.FindVersion(customTemplate, version) == nil {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find the specified version of the custom template", errors.New("Version not found")}
+	}
+
+	fileContent, err := handler.FileService.GetFileContent(handler.FileService.GetCustomTemplateVersionFilePath(strconv.Itoa(customTemplateID), version))
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve custom template version file from disk", err}
+	}
+
+	return response.JSON(w, &fileResponse{FileContent: string(fileContent)})
+}
+
+// accessibleCustomTemplate returns a custom template that can be used by the user of the request
+func (handler *Handler) accessibleCustomTemplate(r *http.Request, customTemplateID portainer.CustomTemplateID) (*portainer.CustomTemplate, *httperror.HandlerError) {
+	customTemplate, err := handler.DataStore.CustomTemplate().CustomTemplate(customTemplateID)
+	if err == bolterrors.ErrObjectNotFound {
+		return nil, &httperror.HandlerError{http.StatusNotFound, "Unable to find a custom template with the specified identifier inside the database", err}
+	} else if err != nil {
+		return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a custom template with the specified identifier inside the database", err}
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve info from request context", err}
+	}
+
+	resourceControl, err := handler.DataStore.ResourceControl().ResourceControlByResourceIDAndType(strconv.Itoa(int(customTemplateID)), portainer.CustomTemplateResourceControl)
+	if err != nil {
+		return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve a resource control associated to the custom template", err}
+	}
+
+	if !userCanAccessTemplate(*customTemplate, securityContext, resourceControl) {
+		return nil, &httperror.HandlerError{http.StatusForbidden, "Access denied to resource", httperrors.ErrResourceAccessDenied}
+	}
+
+	return customTemplate, nil
+}
@@ -13,9 +13,10 @@ import (
 // Handler is the HTTP handler used to handle endpoint group operations.
 type Handler struct {
 	*mux.Router
-	DataStore   portainer.DataStore
-	FileService portainer.FileService
-	GitService  portainer.GitService
+	DataStore      portainer.DataStore
+	FileService    portainer.FileService
+	GitService     portainer.GitService
+	GitSyncService portainer.CustomTemplateGitSyncService
 }
 
 // NewHandler creates a handler to manage endpoint group operations.
@@ -35,6 +36,14 @@ func NewHandler(bouncer *security.RequestBouncer) *Handler {
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateUpdate))).Methods(http.MethodPut)
 	h.Handle("/custom_templates/{id}",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateDelete))).Methods(http.MethodDelete)
+	h.Handle("/custom_templates/{id}/git",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateGitUpdate))).Methods(http.MethodPut)
+	h.Handle("/custom_templates/{id}/git/sync",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateGitSync))).Methods(http.MethodPost)
+	h.Handle("/custom_templates/{id}/versions",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateVersionList))).Methods(http.MethodGet)
+	h.Handle("/custom_templates/{id}/versions/{version}/file",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateVersionFile))).Methods(http.MethodGet)
 	return h
 }
 
@@ -58,3 +67,10 @@ func userCanAccessTemplate(customTemplate portainer.CustomTemplate, securityCont
 
 	return false
 }
+
+// hideCustomTemplateSecrets removes the password of the Git repository of a custom template
+func hideCustomTemplateSecrets(customTemplate *portainer.CustomTemplate) {
+	if customTemplate.GitConfig != nil {
+		customTemplate.GitConfig.Password = ""
+	}
+}
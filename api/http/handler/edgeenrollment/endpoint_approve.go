@@ -0,0 +1,152 @@
+package edgeenrollment
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type endpointApprovePayload struct {
+	GroupID *portainer.EndpointGroupID
+	TagIDs  []portainer.TagID
+}
+
+func (payload *endpointApprovePayload) Validate(r *http.Request) error {
+	return nil
+}
+
+// POST request on /api/edge_enrollment/endpoints/:id/approve
+// Approves a pending endpoint. The endpoint group and tags are taken from the payload,
+// or from the first matching enrollment rule when they are not specified.
+func (handler *Handler) endpointApprove(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid endpoint identifier route variable", err}
+	}
+
+	var payload endpointApprovePayload
+	if r.ContentLength != 0 {
+		err = request.DecodeAndValidateJSONPayload(r, &payload)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+		}
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
+	}
+
+	if endpoint.EdgeEnrollment == nil || endpoint.EdgeEnrollment.Status != portainer.EdgeEnrollmentPending {
+		return &httperror.HandlerError{http.StatusBadRequest, "Endpoint is not pending approval", errors.New("Invalid enrollment status")}
+	}
+
+	groupID := portainer.EndpointGroupID(0)
+	tagIDs := []portainer.TagID{}
+
+	rules, err := handler.DataStore.EdgeEnrollmentRule().EdgeEnrollmentRules()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve enrollment rules from the database", err}
+	}
+
+	rule := edge.MatchEnrollmentRule(endpoint, rules)
+	if rule != nil {
+		groupID = rule.GroupID
+		tagIDs = rule.TagIDs
+	}
+
+	if payload.GroupID != nil {
+		_, err := handler.DataStore.EndpointGroup().EndpointGroup(*payload.GroupID)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Unable to find the endpoint group inside the database", err}
+		}
+		groupID = *payload.GroupID
+	}
+
+	if payload.TagIDs != nil {
+		tagIDs = payload.TagIDs
+	}
+
+	err = handler.approveEndpoint(endpoint, groupID, tagIDs)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to approve the endpoint", err}
+	}
+
+	return response.JSON(w, endpoint)
+}
+
+// approveEndpoint assigns the endpoint group and the tags to an enrolled endpoint,
+// marks it as approved and associates it with the Edge stacks of its Edge groups.
+func (handler *Handler) approveEndpoint(endpoint *portainer.Endpoint, groupID portainer.EndpointGroupID, tagIDs []portainer.TagID) error {
+	if groupID != 0 {
+		endpoint.GroupID = groupID
+	}
+
+	for _, tagID := range tagIDs {
+		tag, err := handler.DataStore.Tag().Tag(tagID)
+		if err != nil {
+			return err
+		}
+
+		if tag.Endpoints[endpoint.ID] {
+			continue
+		}
+
+		tag.Endpoints[endpoint.ID] = true
+		err = handler.DataStore.Tag().UpdateTag(tagID, tag)
+		if err != nil {
+			return err
+		}
+
+		endpoint.TagIDs = append(endpoint.TagIDs, tagID)
+	}
+
+	endpoint.EdgeEnrollment.Status = portainer.EdgeEnrollmentApproved
+	endpoint.EdgeEnrollment.ReviewedAt = time.Now().Unix()
+
+	err := handler.DataStore.Endpoint().UpdateEndpoint(endpoint.ID, endpoint)
+	if err != nil {
+		return err
+	}
+
+	endpointGroup, err := handler.DataStore.EndpointGroup().EndpointGroup(endpoint.GroupID)
+	if err != nil {
+		return err
+	}
+
+	edgeGroups, err := handler.DataStore.EdgeGroup().EdgeGroups()
+	if err != nil {
+		return err
+	}
+
+	edgeStacks, err := handler.DataStore.EdgeStack().EdgeStacks()
+	if err != nil {
+		return err
+	}
+
+	relation, err := handler.DataStore.EndpointRelation().EndpointRelation(endpoint.ID)
+	if err != nil {
+		return err
+	}
+
+	relation.EdgeStacks = map[portainer.EdgeStackID]bool{}
+	for _, stackID := range edge.EndpointRelatedEdgeStacks(endpoint, endpointGroup, edgeGroups, edgeStacks) {
+		relation.EdgeStacks[stackID] = true
+	}
+
+	err = handler.DataStore.EndpointRelation().UpdateEndpointRelation(endpoint.ID, relation)
+	if err != nil {
+		return err
+	}
+
+	return edge.UpdateEdgeJobsEndpoints(handler.DataStore, handler.ReverseTunnelService)
+}
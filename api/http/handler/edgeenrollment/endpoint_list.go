@@ -0,0 +1,37 @@
+package edgeenrollment
+
+import (
+	"net/http"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/edge_enrollment/endpoints?(status=<pending|approved|rejected>)
+// Returns the endpoints registered with an enrollment token, the approval queue when status is pending.
+func (handler *Handler) endpointList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	status, _ := request.RetrieveQueryParameter(r, "status", true)
+
+	endpoints, err := handler.DataStore.Endpoint().Endpoints()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve endpoints from the database", err}
+	}
+
+	enrolledEndpoints := make([]portainer.Endpoint, 0)
+	for _, endpoint := range endpoints {
+		if endpoint.EdgeEnrollment == nil {
+			continue
+		}
+
+		if status != "" && endpoint.EdgeEnrollment.Status != portainer.EdgeEnrollmentStatus(status) {
+			continue
+		}
+
+		endpoint.Snapshots = []portainer.DockerSnapshot{}
+		enrolledEndpoints = append(enrolledEndpoints, endpoint)
+	}
+
+	return response.JSON(w, enrolledEndpoints)
+}
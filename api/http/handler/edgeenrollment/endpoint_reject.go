@@ -0,0 +1,44 @@
+package edgeenrollment
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// POST request on /api/edge_enrollment/endpoints/:id/reject
+// Rejects a pending endpoint. The endpoint is kept so that its agent cannot enroll again,
+// deleting the endpoint allows a new enrollment.
+func (handler *Handler) endpointReject(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid endpoint identifier route variable", err}
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
+	}
+
+	if endpoint.EdgeEnrollment == nil || endpoint.EdgeEnrollment.Status != portainer.EdgeEnrollmentPending {
+		return &httperror.HandlerError{http.StatusBadRequest, "Endpoint is not pending approval", errors.New("Invalid enrollment status")}
+	}
+
+	endpoint.EdgeEnrollment.Status = portainer.EdgeEnrollmentRejected
+	endpoint.EdgeEnrollment.ReviewedAt = time.Now().Unix()
+
+	err = handler.DataStore.Endpoint().UpdateEndpoint(endpoint.ID, endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist endpoint changes inside the database", err}
+	}
+
+	return response.JSON(w, endpoint)
+}
@@ -0,0 +1,235 @@
+package edgeenrollment
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type enrollPayload struct {
+	Token    string
+	Secret   string
+	Name     string
+	Tags     []string
+	Metadata map[string]string
+}
+
+func (payload *enrollPayload) Validate(r *http.Request) error {
+	if govalidator.IsNull(payload.Token) {
+		return errors.New("Invalid enrollment token")
+	}
+	return nil
+}
+
+type enrollResponse struct {
+	EndpointID portainer.EndpointID           `json:"EndpointId"`
+	EdgeKey    string                         `json:"EdgeKey"`
+	Status     portainer.EdgeEnrollmentStatus `json:"Status"`
+	Secret     string                         `json:"Secret,omitempty"`
+}
+
+// POST request on /api/edge_enrollment/enroll
+// Registers an unknown Edge agent presenting a valid enrollment token. A pending endpoint is created
+// with the metadata reported by the agent and a secret is issued to the agent, it is only returned once.
+// An agent enrolling again with the same Edge identifier, the token it enrolled with and its secret
+// retrieves the status of its endpoint. The Edge key is only returned once approved.
+func (handler *Handler) enroll(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload enrollPayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	edgeID := r.Header.Get(portainer.PortainerAgentEdgeIDHeader)
+	if edgeID == "" {
+		return &httperror.HandlerError{http.StatusBadRequest, "Edge identifier header is missing", errors.New("missing Edge identifier")}
+	}
+
+	endpointType, err := agentEndpointType(r)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid agent platform header", err}
+	}
+
+	handler.enrollmentMutex.Lock()
+	defer handler.enrollmentMutex.Unlock()
+
+	token, err := handler.DataStore.EdgeEnrollmentToken().EdgeEnrollmentTokenByToken(payload.Token)
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusForbidden, "Invalid enrollment token", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve the enrollment token from the database", err}
+	}
+
+	endpoints, err := handler.DataStore.Endpoint().Endpoints()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve endpoints from the database", err}
+	}
+
+	if edge.EnrollmentTokenExpired(token) {
+		return &httperror.HandlerError{http.StatusForbidden, "Enrollment token is expired", errors.New("Invalid enrollment token")}
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.EdgeID != edgeID {
+			continue
+		}
+
+		if endpoint.EdgeEnrollment == nil {
+			return &httperror.HandlerError{http.StatusConflict, "The Edge identifier is already used by another endpoint", errors.New("Edge identifier already in use")}
+		}
+
+		// the token of the enrollment is exhausted by the enrollment itself, only its expiration applies
+		if endpoint.EdgeEnrollment.TokenID != token.ID {
+			return &httperror.HandlerError{http.StatusForbidden, "The Edge identifier is enrolled with another enrollment token", errors.New("Invalid enrollment token")}
+		}
+
+		if !edge.EnrollmentSecretMatches(endpoint.EdgeEnrollment, payload.Secret) {
+			return &httperror.HandlerError{http.StatusForbidden, "Invalid enrollment secret", errors.New("Invalid enrollment secret")}
+		}
+
+		if endpoint.EdgeEnrollment.Status == portainer.EdgeEnrollmentRejected {
+			return &httperror.HandlerError{http.StatusForbidden, "Edge agent enrollment was rejected", errors.New("Enrollment rejected")}
+		}
+
+		return response.JSON(w, newEnrollResponse(&endpoint))
+	}
+
+	if !edge.EnrollmentTokenUsable(token) {
+		return &httperror.HandlerError{http.StatusForbidden, "Enrollment token is expired or exhausted", errors.New("Invalid enrollment token")}
+	}
+
+	portainerHost, err := parsePortainerHost(token.PortainerURL)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Invalid Portainer URL associated to the enrollment token", err}
+	}
+
+	name := payload.Name
+	if name == "" {
+		name = edgeID
+	}
+
+	tags := payload.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	metadata := payload.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
+	secret, secretHash, err := edge.GenerateEnrollmentSecret()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to generate the enrollment secret", err}
+	}
+
+	endpointID := handler.DataStore.Endpoint().GetNextIdentifier()
+	endpoint := &portainer.Endpoint{
+		ID:      portainer.EndpointID(endpointID),
+		Name:    name,
+		URL:     portainerHost,
+		Type:    endpointType,
+		GroupID: portainer.EndpointGroupID(1),
+		TLSConfig: portainer.TLSConfiguration{
+			TLS: false,
+		},
+		AuthorizedUsers:    []portainer.UserID{},
+		AuthorizedTeams:    []portainer.TeamID{},
+		UserAccessPolicies: portainer.UserAccessPolicies{},
+		TeamAccessPolicies: portainer.TeamAccessPolicies{},
+		Extensions:         []portainer.EndpointExtension{},
+		TagIDs:             []portainer.TagID{},
+		Status:             portainer.EndpointStatusUp,
+		Snapshots:          []portainer.DockerSnapshot{},
+		EdgeID:             edgeID,
+		EdgeKey:            handler.ReverseTunnelService.GenerateEdgeKey(token.PortainerURL, portainerHost, endpointID),
+		Kubernetes:         portainer.KubernetesDefault(),
+		EdgeEnrollment: &portainer.EdgeEnrollment{
+			TokenID:    token.ID,
+			Status:     portainer.EdgeEnrollmentPending,
+			SecretHash: secretHash,
+			Tags:       tags,
+			Metadata:   metadata,
+			EnrolledAt: time.Now().Unix(),
+		},
+	}
+
+	err = handler.DataStore.Endpoint().CreateEndpoint(endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the endpoint inside the database", err}
+	}
+
+	relation := &portainer.EndpointRelation{
+		EndpointID: endpoint.ID,
+		EdgeStacks: map[portainer.EdgeStackID]bool{},
+	}
+
+	err = handler.DataStore.EndpointRelation().CreateEndpointRelation(relation)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the relation object inside the database", err}
+	}
+
+	token.Uses++
+	err = handler.DataStore.EdgeEnrollmentToken().UpdateEdgeEnrollmentToken(token.ID, token)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the enrollment token changes inside the database", err}
+	}
+
+	rules, err := handler.DataStore.EdgeEnrollmentRule().EdgeEnrollmentRules()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve enrollment rules from the database", err}
+	}
+
+	rule := edge.MatchEnrollmentRule(endpoint, rules)
+	if rule != nil && rule.AutoApprove {
+		err = handler.approveEndpoint(endpoint, rule.GroupID, rule.TagIDs)
+		if err != nil {
+			log.Printf("[WARN] [http,edge_enrollment] [message: unable to automatically approve endpoint] [endpoint: %s] [rule: %s] [err: %s]", endpoint.Name, rule.Name, err)
+		}
+	}
+
+	enrollment := newEnrollResponse(endpoint)
+	enrollment.Secret = secret
+	return response.JSON(w, enrollment)
+}
+
+// newEnrollResponse returns the enrollment status of an endpoint, the Edge key is only included once approved
+func newEnrollResponse(endpoint *portainer.Endpoint) *enrollResponse {
+	enrollment := &enrollResponse{EndpointID: endpoint.ID, Status: endpoint.EdgeEnrollment.Status}
+	if endpoint.EdgeEnrollment.Status == portainer.EdgeEnrollmentApproved {
+		enrollment.EdgeKey = endpoint.EdgeKey
+	}
+	return enrollment
+}
+
+// agentEndpointType returns the endpoint type matching the platform reported by the agent
+func agentEndpointType(r *http.Request) (portainer.EndpointType, error) {
+	agentPlatformHeader := r.Header.Get(portainer.HTTPResponseAgentPlatform)
+	if agentPlatformHeader == "" {
+		return 0, errors.New("Agent Platform Header is missing")
+	}
+
+	agentPlatformNumber, err := strconv.Atoi(agentPlatformHeader)
+	if err != nil {
+		return 0, err
+	}
+
+	switch portainer.AgentPlatform(agentPlatformNumber) {
+	case portainer.AgentPlatformDocker:
+		return portainer.EdgeAgentOnDockerEnvironment, nil
+	case portainer.AgentPlatformKubernetes:
+		return portainer.EdgeAgentOnKubernetesEnvironment, nil
+	}
+
+	return 0, errors.New("Unsupported agent platform")
+}
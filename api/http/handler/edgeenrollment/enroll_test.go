@@ -0,0 +1,171 @@
+package edgeenrollment
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEnrollRequest(t *testing.T, token, secret, edgeID string) (*httptest.ResponseRecorder, *http.Request) {
+	body, err := json.Marshal(enrollPayload{Token: token, Secret: secret})
+	assert.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/edge_enrollment/enroll", bytes.NewReader(body))
+	r.Header.Set(portainer.PortainerAgentEdgeIDHeader, edgeID)
+	r.Header.Set(portainer.HTTPResponseAgentPlatform, strconv.Itoa(int(portainer.AgentPlatformDocker)))
+	return httptest.NewRecorder(), r
+}
+
+type testReverseTunnelService struct {
+	portainer.ReverseTunnelService
+}
+
+func (service *testReverseTunnelService) GenerateEdgeKey(url, host string, endpointIdentifier int) string {
+	return "edge-key"
+}
+
+func Test_enroll_NewEndpoint(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	enrollmentToken := &portainer.EdgeEnrollmentToken{Token: "enrollment-token", PortainerURL: "https://portainer.example.com:9443"}
+	assert.NoError(t, store.EdgeEnrollmentToken().CreateEdgeEnrollmentToken(enrollmentToken))
+
+	handler := &Handler{DataStore: store, ReverseTunnelService: &testReverseTunnelService{}}
+
+	w, r := newEnrollRequest(t, enrollmentToken.Token, "", "agent-1")
+	assert.Nil(t, handler.enroll(w, r))
+
+	var enrollment enrollResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&enrollment))
+	assert.Equal(t, portainer.EdgeEnrollmentPending, enrollment.Status)
+	assert.NotEmpty(t, enrollment.Secret)
+
+	endpoint, err := store.Endpoint().Endpoint(enrollment.EndpointID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, enrollment.Secret, endpoint.EdgeEnrollment.SecretHash)
+	assert.True(t, edge.EnrollmentSecretMatches(endpoint.EdgeEnrollment, enrollment.Secret))
+
+	t.Run("enrollment of the same agent", func(t *testing.T) {
+		w, r := newEnrollRequest(t, enrollmentToken.Token, enrollment.Secret, "agent-1")
+		assert.Nil(t, handler.enroll(w, r))
+
+		var status enrollResponse
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+		assert.Equal(t, enrollment.EndpointID, status.EndpointID)
+		assert.Empty(t, status.Secret)
+	})
+
+	t.Run("enrollment of another device with the same token", func(t *testing.T) {
+		w, r := newEnrollRequest(t, enrollmentToken.Token, "", "agent-1")
+		handlerErr := handler.enroll(w, r)
+		assert.NotNil(t, handlerErr)
+		assert.Equal(t, http.StatusForbidden, handlerErr.StatusCode)
+	})
+}
+
+func Test_enroll_ExistingEndpoint(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	enrollmentToken := &portainer.EdgeEnrollmentToken{Token: "enrollment-token", MaxUses: 1, Uses: 1}
+	assert.NoError(t, store.EdgeEnrollmentToken().CreateEdgeEnrollmentToken(enrollmentToken))
+	otherToken := &portainer.EdgeEnrollmentToken{Token: "other-token"}
+	assert.NoError(t, store.EdgeEnrollmentToken().CreateEdgeEnrollmentToken(otherToken))
+	expiredToken := &portainer.EdgeEnrollmentToken{Token: "expired-token", ExpiresAt: time.Now().Add(-time.Hour).Unix()}
+	assert.NoError(t, store.EdgeEnrollmentToken().CreateEdgeEnrollmentToken(expiredToken))
+
+	secret, secretHash, err := edge.GenerateEnrollmentSecret()
+	assert.NoError(t, err)
+
+	endpoint := &portainer.Endpoint{
+		ID:             1,
+		EdgeID:         "agent-1",
+		EdgeKey:        "edge-key",
+		EdgeEnrollment: &portainer.EdgeEnrollment{TokenID: enrollmentToken.ID, Status: portainer.EdgeEnrollmentPending, SecretHash: secretHash},
+	}
+	assert.NoError(t, store.Endpoint().CreateEndpoint(endpoint))
+
+	handler := &Handler{DataStore: store}
+
+	t.Run("mismatched token", func(t *testing.T) {
+		w, r := newEnrollRequest(t, otherToken.Token, secret, "agent-1")
+		handlerErr := handler.enroll(w, r)
+		assert.NotNil(t, handlerErr)
+		assert.Equal(t, http.StatusForbidden, handlerErr.StatusCode)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		w, r := newEnrollRequest(t, expiredToken.Token, secret, "agent-1")
+		handlerErr := handler.enroll(w, r)
+		assert.NotNil(t, handlerErr)
+		assert.Equal(t, http.StatusForbidden, handlerErr.StatusCode)
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		w, r := newEnrollRequest(t, enrollmentToken.Token, "", "agent-1")
+		handlerErr := handler.enroll(w, r)
+		assert.NotNil(t, handlerErr)
+		assert.Equal(t, http.StatusForbidden, handlerErr.StatusCode)
+	})
+
+	t.Run("mismatched secret", func(t *testing.T) {
+		w, r := newEnrollRequest(t, enrollmentToken.Token, "other-secret", "agent-1")
+		handlerErr := handler.enroll(w, r)
+		assert.NotNil(t, handlerErr)
+		assert.Equal(t, http.StatusForbidden, handlerErr.StatusCode)
+	})
+
+	t.Run("pending enrollment", func(t *testing.T) {
+		w, r := newEnrollRequest(t, enrollmentToken.Token, secret, "agent-1")
+		assert.Nil(t, handler.enroll(w, r))
+
+		var enrollment enrollResponse
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&enrollment))
+		assert.Equal(t, portainer.EdgeEnrollmentPending, enrollment.Status)
+		assert.Empty(t, enrollment.EdgeKey)
+	})
+
+	t.Run("approved enrollment", func(t *testing.T) {
+		endpoint.EdgeEnrollment.Status = portainer.EdgeEnrollmentApproved
+		assert.NoError(t, store.Endpoint().UpdateEndpoint(endpoint.ID, endpoint))
+
+		w, r := newEnrollRequest(t, enrollmentToken.Token, secret, "agent-1")
+		assert.Nil(t, handler.enroll(w, r))
+
+		var enrollment enrollResponse
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&enrollment))
+		assert.Equal(t, "edge-key", enrollment.EdgeKey)
+	})
+}
+
+func Test_enroll_ManualEndpoint(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	enrollmentToken := &portainer.EdgeEnrollmentToken{Token: "enrollment-token"}
+	assert.NoError(t, store.EdgeEnrollmentToken().CreateEdgeEnrollmentToken(enrollmentToken))
+
+	endpoint := &portainer.Endpoint{ID: 1, EdgeID: "agent-1", EdgeKey: "edge-key"}
+	assert.NoError(t, store.Endpoint().CreateEndpoint(endpoint))
+
+	handler := &Handler{DataStore: store}
+
+	w, r := newEnrollRequest(t, enrollmentToken.Token, "", "agent-1")
+	handlerErr := handler.enroll(w, r)
+	assert.NotNil(t, handlerErr)
+	assert.Equal(t, http.StatusConflict, handlerErr.StatusCode)
+
+	endpoints, err := store.Endpoint().Endpoints()
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+}
@@ -0,0 +1,50 @@
+package edgeenrollment
+
+import (
+	"net/http"
+	"sync"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/gorilla/mux"
+	httperror "github.com/portainer/libhttp/error"
+)
+
+// Handler is the HTTP handler used to handle Edge agent enrollment operations.
+type Handler struct {
+	*mux.Router
+	DataStore            portainer.DataStore
+	ReverseTunnelService portainer.ReverseTunnelService
+	enrollmentMutex      sync.Mutex
+}
+
+// NewHandler creates a handler to manage Edge agent enrollment operations.
+func NewHandler(bouncer *security.RequestBouncer) *Handler {
+	h := &Handler{
+		Router: mux.NewRouter(),
+	}
+
+	h.Handle("/edge_enrollment/enroll",
+		bouncer.PublicAccess(httperror.LoggerHandler(h.enroll))).Methods(http.MethodPost)
+	h.Handle("/edge_enrollment/tokens",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.tokenCreate)))).Methods(http.MethodPost)
+	h.Handle("/edge_enrollment/tokens",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.tokenList)))).Methods(http.MethodGet)
+	h.Handle("/edge_enrollment/tokens/{id}",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.tokenDelete)))).Methods(http.MethodDelete)
+	h.Handle("/edge_enrollment/rules",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.ruleCreate)))).Methods(http.MethodPost)
+	h.Handle("/edge_enrollment/rules",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.ruleList)))).Methods(http.MethodGet)
+	h.Handle("/edge_enrollment/rules/{id}",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.ruleUpdate)))).Methods(http.MethodPut)
+	h.Handle("/edge_enrollment/rules/{id}",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.ruleDelete)))).Methods(http.MethodDelete)
+	h.Handle("/edge_enrollment/endpoints",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.endpointList)))).Methods(http.MethodGet)
+	h.Handle("/edge_enrollment/endpoints/{id}/approve",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.endpointApprove)))).Methods(http.MethodPost)
+	h.Handle("/edge_enrollment/endpoints/{id}/reject",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.endpointReject)))).Methods(http.MethodPost)
+	return h
+}
@@ -0,0 +1,99 @@
+package edgeenrollment
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/asaskevich/govalidator"
+	portainer "github.com/cloudogu/portainer-ce/api"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type ruleCreatePayload struct {
+	Name        string
+	Priority    int
+	TokenIDs    []portainer.EdgeEnrollmentTokenID
+	MatchTags   []string
+	NamePattern string
+	GroupID     portainer.EndpointGroupID
+	TagIDs      []portainer.TagID
+	AutoApprove bool
+}
+
+func (payload *ruleCreatePayload) Validate(r *http.Request) error {
+	if govalidator.IsNull(payload.Name) {
+		return errors.New("Invalid enrollment rule name")
+	}
+	return nil
+}
+
+// POST request on /api/edge_enrollment/rules
+func (handler *Handler) ruleCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload ruleCreatePayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	rule := &portainer.EdgeEnrollmentRule{
+		Name:        payload.Name,
+		Priority:    payload.Priority,
+		TokenIDs:    payload.TokenIDs,
+		MatchTags:   payload.MatchTags,
+		NamePattern: payload.NamePattern,
+		GroupID:     payload.GroupID,
+		TagIDs:      payload.TagIDs,
+		AutoApprove: payload.AutoApprove,
+	}
+
+	httpErr := handler.validateRule(rule)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	err = handler.DataStore.EdgeEnrollmentRule().CreateEdgeEnrollmentRule(rule)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the enrollment rule inside the database", err}
+	}
+
+	return response.JSON(w, rule)
+}
+
+// validateRule verifies the name pattern of a rule and the existence of the endpoint group and tags it assigns
+func (handler *Handler) validateRule(rule *portainer.EdgeEnrollmentRule) *httperror.HandlerError {
+	if rule.TokenIDs == nil {
+		rule.TokenIDs = []portainer.EdgeEnrollmentTokenID{}
+	}
+	if rule.MatchTags == nil {
+		rule.MatchTags = []string{}
+	}
+	if rule.TagIDs == nil {
+		rule.TagIDs = []portainer.TagID{}
+	}
+
+	if rule.NamePattern != "" {
+		_, err := regexp.Compile(rule.NamePattern)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Invalid name pattern. Must be a valid regular expression", err}
+		}
+	}
+
+	if rule.GroupID != 0 {
+		_, err := handler.DataStore.EndpointGroup().EndpointGroup(rule.GroupID)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Unable to find the endpoint group assigned by the rule inside the database", err}
+		}
+	}
+
+	for _, tagID := range rule.TagIDs {
+		_, err := handler.DataStore.Tag().Tag(tagID)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Unable to find a tag assigned by the rule inside the database", err}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package edgeenrollment
+
+import (
+	"net/http"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// DELETE request on /api/edge_enrollment/rules/:id
+func (handler *Handler) ruleDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	ruleID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid enrollment rule identifier route variable", err}
+	}
+
+	_, err = handler.DataStore.EdgeEnrollmentRule().EdgeEnrollmentRule(portainer.EdgeEnrollmentRuleID(ruleID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an enrollment rule with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an enrollment rule with the specified identifier inside the database", err}
+	}
+
+	err = handler.DataStore.EdgeEnrollmentRule().DeleteEdgeEnrollmentRule(portainer.EdgeEnrollmentRuleID(ruleID))
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove the enrollment rule from the database", err}
+	}
+
+	return response.Empty(w)
+}
@@ -0,0 +1,18 @@
+package edgeenrollment
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/edge_enrollment/rules
+func (handler *Handler) ruleList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	rules, err := handler.DataStore.EdgeEnrollmentRule().EdgeEnrollmentRules()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve enrollment rules from the database", err}
+	}
+
+	return response.JSON(w, rules)
+}
@@ -0,0 +1,84 @@
+package edgeenrollment
+
+import (
+	"net/http"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type ruleUpdatePayload struct {
+	Name        *string
+	Priority    *int
+	TokenIDs    []portainer.EdgeEnrollmentTokenID
+	MatchTags   []string
+	NamePattern *string
+	GroupID     *portainer.EndpointGroupID
+	TagIDs      []portainer.TagID
+	AutoApprove *bool
+}
+
+func (payload *ruleUpdatePayload) Validate(r *http.Request) error {
+	return nil
+}
+
+// PUT request on /api/edge_enrollment/rules/:id
+func (handler *Handler) ruleUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	ruleID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid enrollment rule identifier route variable", err}
+	}
+
+	var payload ruleUpdatePayload
+	err = request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	rule, err := handler.DataStore.EdgeEnrollmentRule().EdgeEnrollmentRule(portainer.EdgeEnrollmentRuleID(ruleID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an enrollment rule with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an enrollment rule with the specified identifier inside the database", err}
+	}
+
+	if payload.Name != nil && *payload.Name != "" {
+		rule.Name = *payload.Name
+	}
+	if payload.Priority != nil {
+		rule.Priority = *payload.Priority
+	}
+	if payload.TokenIDs != nil {
+		rule.TokenIDs = payload.TokenIDs
+	}
+	if payload.MatchTags != nil {
+		rule.MatchTags = payload.MatchTags
+	}
+	if payload.NamePattern != nil {
+		rule.NamePattern = *payload.NamePattern
+	}
+	if payload.GroupID != nil {
+		rule.GroupID = *payload.GroupID
+	}
+	if payload.TagIDs != nil {
+		rule.TagIDs = payload.TagIDs
+	}
+	if payload.AutoApprove != nil {
+		rule.AutoApprove = *payload.AutoApprove
+	}
+
+	httpErr := handler.validateRule(rule)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	err = handler.DataStore.EdgeEnrollmentRule().UpdateEdgeEnrollmentRule(rule.ID, rule)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the enrollment rule changes inside the database", err}
+	}
+
+	return response.JSON(w, rule)
+}
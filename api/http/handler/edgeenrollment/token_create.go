@@ -0,0 +1,88 @@
+package edgeenrollment
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/dchest/uniuri"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type tokenCreatePayload struct {
+	Name         string
+	PortainerURL string
+	ExpiresAt    int64
+	MaxUses      int
+}
+
+func (payload *tokenCreatePayload) Validate(r *http.Request) error {
+	if govalidator.IsNull(payload.Name) {
+		return errors.New("Invalid enrollment token name")
+	}
+	if govalidator.IsNull(payload.PortainerURL) || !govalidator.IsURL(payload.PortainerURL) {
+		return errors.New("Invalid Portainer URL")
+	}
+	if payload.ExpiresAt != 0 && payload.ExpiresAt <= time.Now().Unix() {
+		return errors.New("Invalid expiration date. Must be in the future")
+	}
+	if payload.MaxUses < 0 {
+		return errors.New("Invalid maximum number of uses. Must be positive")
+	}
+	return nil
+}
+
+// POST request on /api/edge_enrollment/tokens
+func (handler *Handler) tokenCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload tokenCreatePayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	portainerHost, err := parsePortainerHost(payload.PortainerURL)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid Portainer URL", err}
+	}
+
+	if portainerHost == "localhost" {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid Portainer URL", errors.New("cannot use localhost as Portainer URL")}
+	}
+
+	token := &portainer.EdgeEnrollmentToken{
+		Name:         payload.Name,
+		Token:        uniuri.NewLen(32),
+		PortainerURL: payload.PortainerURL,
+		ExpiresAt:    payload.ExpiresAt,
+		MaxUses:      payload.MaxUses,
+		Created:      time.Now().Unix(),
+	}
+
+	err = handler.DataStore.EdgeEnrollmentToken().CreateEdgeEnrollmentToken(token)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the enrollment token inside the database", err}
+	}
+
+	return response.JSON(w, token)
+}
+
+// parsePortainerHost returns the host, without port, of the Portainer instance URL
+func parsePortainerHost(portainerURL string) (string, error) {
+	parsedURL, err := url.Parse(portainerURL)
+	if err != nil {
+		return "", err
+	}
+
+	host, _, err := net.SplitHostPort(parsedURL.Host)
+	if err != nil {
+		host = parsedURL.Host
+	}
+
+	return host, nil
+}
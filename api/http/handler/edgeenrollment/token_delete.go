@@ -0,0 +1,34 @@
+package edgeenrollment
+
+import (
+	"net/http"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// DELETE request on /api/edge_enrollment/tokens/:id
+// Revokes an enrollment token. Endpoints already enrolled with the token are kept.
+func (handler *Handler) tokenDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	tokenID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid enrollment token identifier route variable", err}
+	}
+
+	_, err = handler.DataStore.EdgeEnrollmentToken().EdgeEnrollmentToken(portainer.EdgeEnrollmentTokenID(tokenID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an enrollment token with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an enrollment token with the specified identifier inside the database", err}
+	}
+
+	err = handler.DataStore.EdgeEnrollmentToken().DeleteEdgeEnrollmentToken(portainer.EdgeEnrollmentTokenID(tokenID))
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove the enrollment token from the database", err}
+	}
+
+	return response.Empty(w)
+}
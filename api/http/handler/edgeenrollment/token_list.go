@@ -0,0 +1,18 @@
+package edgeenrollment
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/edge_enrollment/tokens
+func (handler *Handler) tokenList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	tokens, err := handler.DataStore.EdgeEnrollmentToken().EdgeEnrollmentTokens()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve enrollment tokens from the database", err}
+	}
+
+	return response.JSON(w, tokens)
+}
@@ -37,6 +37,19 @@ func (handler *Handler) edgeGroupDelete(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	edgeJobs, err := handler.DataStore.EdgeJob().EdgeJobs()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve Edge jobs from the database", err}
+	}
+
+	for _, edgeJob := range edgeJobs {
+		for _, groupID := range edgeJob.EdgeGroups {
+			if groupID == portainer.EdgeGroupID(edgeGroupID) {
+				return &httperror.HandlerError{http.StatusForbidden, "Edge group is used by an Edge job", errors.New("Edge group is used by an Edge job")}
+			}
+		}
+	}
+
 	err = handler.DataStore.EdgeGroup().DeleteEdgeGroup(portainer.EdgeGroupID(edgeGroupID))
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove the Edge group from the database", err}
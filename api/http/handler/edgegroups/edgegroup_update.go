@@ -115,6 +115,11 @@ func (handler *Handler) edgeGroupUpdate(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	err = edge.UpdateEdgeJobsEndpoints(handler.DataStore, handler.ReverseTunnelService)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update the endpoints of the Edge jobs", err}
+	}
+
 	return response.JSON(w, edgeGroup)
 }
 
@@ -9,6 +9,7 @@ import (
 
 	"github.com/asaskevich/govalidator"
 	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -32,11 +33,14 @@ func (handler *Handler) edgeJobCreate(w http.ResponseWriter, r *http.Request) *h
 }
 
 type edgeJobCreateFromFileContentPayload struct {
-	Name           string
-	CronExpression string
-	Recurring      bool
-	Endpoints      []portainer.EndpointID
-	FileContent    string
+	Name                 string
+	CronExpression       string
+	Recurring            bool
+	Endpoints            []portainer.EndpointID
+	EdgeGroups           []portainer.EdgeGroupID
+	FileContent          string
+	Timeout              int
+	CollectLogsOnFailure bool
 }
 
 func (payload *edgeJobCreateFromFileContentPayload) Validate(r *http.Request) error {
@@ -52,14 +56,22 @@ func (payload *edgeJobCreateFromFileContentPayload) Validate(r *http.Request) er
 		return errors.New("Invalid cron expression")
 	}
 
-	if payload.Endpoints == nil || len(payload.Endpoints) == 0 {
-		return errors.New("Invalid endpoints payload")
+	if len(payload.Endpoints) == 0 && len(payload.EdgeGroups) == 0 {
+		return errors.New("Invalid endpoints payload. Endpoints or Edge groups are mandatory")
+	}
+
+	if len(payload.Endpoints) != 0 && len(payload.EdgeGroups) != 0 {
+		return errors.New("Invalid endpoints payload. Endpoints and Edge groups cannot be combined")
 	}
 
 	if govalidator.IsNull(payload.FileContent) {
 		return errors.New("Invalid script file content")
 	}
 
+	if payload.Timeout < 0 {
+		return errors.New("Invalid timeout. Must be a positive number of seconds")
+	}
+
 	return nil
 }
 
@@ -81,11 +93,14 @@ func (handler *Handler) createEdgeJobFromFileContent(w http.ResponseWriter, r *h
 }
 
 type edgeJobCreateFromFilePayload struct {
-	Name           string
-	CronExpression string
-	Recurring      bool
-	Endpoints      []portainer.EndpointID
-	File           []byte
+	Name                 string
+	CronExpression       string
+	Recurring            bool
+	Endpoints            []portainer.EndpointID
+	EdgeGroups           []portainer.EdgeGroupID
+	File                 []byte
+	Timeout              int
+	CollectLogsOnFailure bool
 }
 
 func (payload *edgeJobCreateFromFilePayload) Validate(r *http.Request) error {
@@ -106,18 +121,42 @@ func (payload *edgeJobCreateFromFilePayload) Validate(r *http.Request) error {
 	payload.CronExpression = cronExpression
 
 	var endpoints []portainer.EndpointID
-	err = request.RetrieveMultiPartFormJSONValue(r, "Endpoints", &endpoints, false)
+	err = request.RetrieveMultiPartFormJSONValue(r, "Endpoints", &endpoints, true)
 	if err != nil {
 		return errors.New("Invalid endpoints")
 	}
 	payload.Endpoints = endpoints
 
+	var edgeGroups []portainer.EdgeGroupID
+	err = request.RetrieveMultiPartFormJSONValue(r, "EdgeGroups", &edgeGroups, true)
+	if err != nil {
+		return errors.New("Invalid Edge groups")
+	}
+	payload.EdgeGroups = edgeGroups
+
+	if len(payload.Endpoints) == 0 && len(payload.EdgeGroups) == 0 {
+		return errors.New("Invalid endpoints. Endpoints or Edge groups are mandatory")
+	}
+
+	if len(payload.Endpoints) != 0 && len(payload.EdgeGroups) != 0 {
+		return errors.New("Invalid endpoints. Endpoints and Edge groups cannot be combined")
+	}
+
 	file, _, err := request.RetrieveMultiPartFormFile(r, "file")
 	if err != nil {
 		return errors.New("Invalid script file. Ensure that the file is uploaded correctly")
 	}
 	payload.File = file
 
+	timeout, _ := request.RetrieveNumericMultiPartFormValue(r, "Timeout", true)
+	if timeout < 0 {
+		return errors.New("Invalid timeout. Must be a positive number of seconds")
+	}
+	payload.Timeout = timeout
+
+	collectLogsOnFailure, _ := request.RetrieveBooleanMultiPartFormValue(r, "CollectLogsOnFailure", true)
+	payload.CollectLogsOnFailure = collectLogsOnFailure
+
 	return nil
 }
 
@@ -144,13 +183,16 @@ func (handler *Handler) createEdgeJobObjectFromFilePayload(payload *edgeJobCreat
 	endpoints := convertEndpointsToMetaObject(payload.Endpoints)
 
 	edgeJob := &portainer.EdgeJob{
-		ID:             edgeJobIdentifier,
-		Name:           payload.Name,
-		CronExpression: payload.CronExpression,
-		Recurring:      payload.Recurring,
-		Created:        time.Now().Unix(),
-		Endpoints:      endpoints,
-		Version:        1,
+		ID:                   edgeJobIdentifier,
+		Name:                 payload.Name,
+		CronExpression:       payload.CronExpression,
+		Recurring:            payload.Recurring,
+		Created:              time.Now().Unix(),
+		Endpoints:            endpoints,
+		Version:              1,
+		Timeout:              payload.Timeout,
+		CollectLogsOnFailure: payload.CollectLogsOnFailure,
+		EdgeGroups:           payload.EdgeGroups,
 	}
 
 	return edgeJob
@@ -162,13 +204,16 @@ func (handler *Handler) createEdgeJobObjectFromFileContentPayload(payload *edgeJ
 	endpoints := convertEndpointsToMetaObject(payload.Endpoints)
 
 	edgeJob := &portainer.EdgeJob{
-		ID:             edgeJobIdentifier,
-		Name:           payload.Name,
-		CronExpression: payload.CronExpression,
-		Recurring:      payload.Recurring,
-		Created:        time.Now().Unix(),
-		Endpoints:      endpoints,
-		Version:        1,
+		ID:                   edgeJobIdentifier,
+		Name:                 payload.Name,
+		CronExpression:       payload.CronExpression,
+		Recurring:            payload.Recurring,
+		Created:              time.Now().Unix(),
+		Endpoints:            endpoints,
+		Version:              1,
+		Timeout:              payload.Timeout,
+		CollectLogsOnFailure: payload.CollectLogsOnFailure,
+		EdgeGroups:           payload.EdgeGroups,
 	}
 
 	return edgeJob
@@ -181,6 +226,14 @@ func (handler *Handler) addAndPersistEdgeJob(edgeJob *portainer.EdgeJob, file []
 	}
 	edgeJob.CronExpression = strings.Join(edgeCronExpression, " ")
 
+	if len(edgeJob.EdgeGroups) != 0 {
+		endpointIDs, err := handler.edgeJobRelatedEndpoints(edgeJob)
+		if err != nil {
+			return err
+		}
+		edgeJob.Endpoints = convertEndpointsToMetaObject(endpointIDs)
+	}
+
 	for ID := range edgeJob.Endpoints {
 		endpoint, err := handler.DataStore.Endpoint().Endpoint(ID)
 		if err != nil {
@@ -192,7 +245,7 @@ func (handler *Handler) addAndPersistEdgeJob(edgeJob *portainer.EdgeJob, file []
 		}
 	}
 
-	if len(edgeJob.Endpoints) == 0 {
+	if len(edgeJob.Endpoints) == 0 && len(edgeJob.EdgeGroups) == 0 {
 		return errors.New("Endpoints are mandatory for an Edge job")
 	}
 
@@ -218,3 +271,22 @@ func convertEndpointsToMetaObject(endpoints []portainer.EndpointID) map[portaine
 
 	return endpointsMap
 }
+
+func (handler *Handler) edgeJobRelatedEndpoints(edgeJob *portainer.EdgeJob) ([]portainer.EndpointID, error) {
+	endpoints, err := handler.DataStore.Endpoint().Endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	endpointGroups, err := handler.DataStore.EndpointGroup().EndpointGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	edgeGroups, err := handler.DataStore.EdgeGroup().EdgeGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	return edge.EdgeJobRelatedEndpoints(edgeJob, endpoints, endpointGroups, edgeGroups)
+}
@@ -0,0 +1,65 @@
+package edgejobs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/edge_jobs/:id/endpoints/:endpointID/runs/:runID/logs
+// Returns the logs collected for a run of the Edge job on an endpoint.
+func (handler *Handler) edgeJobRunLogsInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	edgeJobID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid Edge job identifier route variable", err}
+	}
+
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "endpointID")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid endpoint identifier route variable", err}
+	}
+
+	runID, err := request.RetrieveNumericRouteVariableValue(r, "runID")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid run identifier route variable", err}
+	}
+
+	edgeJob, err := handler.DataStore.EdgeJob().EdgeJob(portainer.EdgeJobID(edgeJobID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an Edge job with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an Edge job with the specified identifier inside the database", err}
+	}
+
+	if !edgeJobRunExists(edgeJob, portainer.EndpointID(endpointID), runID) {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find a run with the specified identifier for the endpoint", errors.New("Edge job run not found")}
+	}
+
+	logFileContent, err := handler.FileService.GetEdgeJobTaskLogFileContent(strconv.Itoa(edgeJobID), edge.EdgeJobRunLogsTaskID(portainer.EndpointID(endpointID), runID))
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve log file from disk", err}
+	}
+
+	return response.JSON(w, &fileResponse{FileContent: string(logFileContent)})
+}
+
+func edgeJobRunExists(edgeJob *portainer.EdgeJob, endpointID portainer.EndpointID, runID int) bool {
+	meta, ok := edgeJob.Endpoints[endpointID]
+	if !ok {
+		return false
+	}
+
+	for _, run := range meta.Runs {
+		if run.ID == runID {
+			return true
+		}
+	}
+	return false
+}
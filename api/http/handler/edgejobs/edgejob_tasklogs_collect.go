@@ -5,6 +5,7 @@ import (
 
 	"github.com/cloudogu/portainer-ce/api"
 	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -22,26 +23,22 @@ func (handler *Handler) edgeJobTasksCollect(w http.ResponseWriter, r *http.Reque
 		return &httperror.HandlerError{http.StatusBadRequest, "Invalid Task identifier route variable", err}
 	}
 
-	edgeJob, err := handler.DataStore.EdgeJob().EdgeJob(portainer.EdgeJobID(edgeJobID))
+	endpointID := portainer.EndpointID(taskID)
+
+	edgeJob, err := edge.UpdateEdgeJob(handler.DataStore, portainer.EdgeJobID(edgeJobID), func(edgeJob *portainer.EdgeJob) error {
+		meta := edgeJob.Endpoints[endpointID]
+		meta.CollectLogs = true
+		meta.LogsStatus = portainer.EdgeJobLogsStatusPending
+		edgeJob.Endpoints[endpointID] = meta
+		return nil
+	})
 	if err == bolterrors.ErrObjectNotFound {
 		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an Edge job with the specified identifier inside the database", err}
 	} else if err != nil {
-		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an Edge job with the specified identifier inside the database", err}
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist Edge job changes in the database", err}
 	}
 
-	endpointID := portainer.EndpointID(taskID)
-
-	meta := edgeJob.Endpoints[endpointID]
-	meta.CollectLogs = true
-	meta.LogsStatus = portainer.EdgeJobLogsStatusPending
-	edgeJob.Endpoints[endpointID] = meta
-
 	handler.ReverseTunnelService.AddEdgeJob(endpointID, edgeJob)
 
-	err = handler.DataStore.EdgeJob().UpdateEdgeJob(edgeJob.ID, edgeJob)
-	if err != nil {
-		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist Edge job changes in the database", err}
-	}
-
 	return response.Empty(w)
 }
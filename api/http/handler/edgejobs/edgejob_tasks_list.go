@@ -3,6 +3,7 @@ package edgejobs
 import (
 	"fmt"
 	"net/http"
+	"sort"
 
 	"github.com/cloudogu/portainer-ce/api"
 	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
@@ -15,9 +16,12 @@ type taskContainer struct {
 	ID         string                      `json:"Id"`
 	EndpointID portainer.EndpointID        `json:"EndpointId"`
 	LogsStatus portainer.EdgeJobLogsStatus `json:"LogsStatus"`
+	Run        *portainer.EdgeJobRun       `json:"Run,omitempty"`
 }
 
 // GET request on /api/edge_jobs/:id/tasks
+// Returns the run history of the Edge job, most recent runs first. Endpoints on which the job
+// did not run yet are listed with a single task without run information.
 func (handler *Handler) edgeJobTasksList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
 	edgeJobID, err := request.RetrieveNumericRouteVariableValue(r, "id")
 	if err != nil {
@@ -34,15 +38,33 @@ func (handler *Handler) edgeJobTasksList(w http.ResponseWriter, r *http.Request)
 	tasks := make([]taskContainer, 0)
 
 	for endpointID, meta := range edgeJob.Endpoints {
-
-		cronTask := taskContainer{
-			ID:         fmt.Sprintf("edgejob_task_%d_%d", edgeJob.ID, endpointID),
-			EndpointID: endpointID,
-			LogsStatus: meta.LogsStatus,
+		if len(meta.Runs) == 0 {
+			tasks = append(tasks, taskContainer{
+				ID:         fmt.Sprintf("edgejob_task_%d_%d", edgeJob.ID, endpointID),
+				EndpointID: endpointID,
+				LogsStatus: meta.LogsStatus,
+			})
+			continue
 		}
 
-		tasks = append(tasks, cronTask)
+		for idx := range meta.Runs {
+			run := meta.Runs[idx]
+
+			tasks = append(tasks, taskContainer{
+				ID:         fmt.Sprintf("edgejob_task_%d_%d_%d", edgeJob.ID, endpointID, run.ID),
+				EndpointID: endpointID,
+				LogsStatus: meta.LogsStatus,
+				Run:        &run,
+			})
+		}
 	}
 
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Run == nil || tasks[j].Run == nil {
+			return tasks[i].Run == nil && tasks[j].Run != nil
+		}
+		return tasks[i].Run.StartedAt > tasks[j].Run.StartedAt
+	})
+
 	return response.JSON(w, tasks)
 }
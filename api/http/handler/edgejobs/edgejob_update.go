@@ -8,23 +8,35 @@ import (
 	"github.com/asaskevich/govalidator"
 	"github.com/cloudogu/portainer-ce/api"
 	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
 )
 
 type edgeJobUpdatePayload struct {
-	Name           *string
-	CronExpression *string
-	Recurring      *bool
-	Endpoints      []portainer.EndpointID
-	FileContent    *string
+	Name                 *string
+	CronExpression       *string
+	Recurring            *bool
+	Endpoints            []portainer.EndpointID
+	EdgeGroups           []portainer.EdgeGroupID
+	FileContent          *string
+	Timeout              *int
+	CollectLogsOnFailure *bool
 }
 
 func (payload *edgeJobUpdatePayload) Validate(r *http.Request) error {
 	if payload.Name != nil && !govalidator.Matches(*payload.Name, `^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`) {
 		return errors.New("Invalid Edge job name format. Allowed characters are: [a-zA-Z0-9_.-]")
 	}
+
+	if len(payload.Endpoints) != 0 && len(payload.EdgeGroups) != 0 {
+		return errors.New("Invalid endpoints payload. Endpoints and Edge groups cannot be combined")
+	}
+
+	if payload.Timeout != nil && *payload.Timeout < 0 {
+		return errors.New("Invalid timeout. Must be a positive number of seconds")
+	}
 	return nil
 }
 
@@ -40,20 +52,16 @@ func (handler *Handler) edgeJobUpdate(w http.ResponseWriter, r *http.Request) *h
 		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
 	}
 
-	edgeJob, err := handler.DataStore.EdgeJob().EdgeJob(portainer.EdgeJobID(edgeJobID))
-	if err == bolterrors.ErrObjectNotFound {
+	var updateErr error
+	edgeJob, err := edge.UpdateEdgeJob(handler.DataStore, portainer.EdgeJobID(edgeJobID), func(edgeJob *portainer.EdgeJob) error {
+		updateErr = handler.updateEdgeSchedule(edgeJob, &payload)
+		return updateErr
+	})
+	if updateErr != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update Edge job", updateErr}
+	} else if err == bolterrors.ErrObjectNotFound {
 		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an Edge job with the specified identifier inside the database", err}
 	} else if err != nil {
-		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an Edge job with the specified identifier inside the database", err}
-	}
-
-	err = handler.updateEdgeSchedule(edgeJob, &payload)
-	if err != nil {
-		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update Edge job", err}
-	}
-
-	err = handler.DataStore.EdgeJob().UpdateEdgeJob(edgeJob.ID, edgeJob)
-	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist Edge job changes inside the database", err}
 	}
 
@@ -65,7 +73,21 @@ func (handler *Handler) updateEdgeSchedule(edgeJob *portainer.EdgeJob, payload *
 		edgeJob.Name = *payload.Name
 	}
 
-	if payload.Endpoints != nil {
+	previousEndpoints := edgeJob.Endpoints
+
+	if payload.EdgeGroups != nil {
+		edgeJob.EdgeGroups = payload.EdgeGroups
+	} else if len(payload.Endpoints) != 0 {
+		edgeJob.EdgeGroups = nil
+	}
+
+	if len(edgeJob.EdgeGroups) != 0 {
+		endpointIDs, err := handler.edgeJobRelatedEndpoints(edgeJob)
+		if err != nil {
+			return err
+		}
+		edge.SetEdgeJobEndpoints(edgeJob, endpointIDs)
+	} else if payload.Endpoints != nil {
 		endpointsMap := map[portainer.EndpointID]portainer.EdgeJobEndpointMeta{}
 
 		for _, endpointID := range payload.Endpoints {
@@ -108,10 +130,26 @@ func (handler *Handler) updateEdgeSchedule(edgeJob *portainer.EdgeJob, payload *
 		updateVersion = true
 	}
 
+	if payload.Timeout != nil {
+		edgeJob.Timeout = *payload.Timeout
+		updateVersion = true
+	}
+
+	if payload.CollectLogsOnFailure != nil {
+		edgeJob.CollectLogsOnFailure = *payload.CollectLogsOnFailure
+		updateVersion = true
+	}
+
 	if updateVersion {
 		edgeJob.Version++
 	}
 
+	for endpointID := range previousEndpoints {
+		if _, ok := edgeJob.Endpoints[endpointID]; !ok {
+			handler.ReverseTunnelService.RemoveEdgeJobFromEndpoint(endpointID, edgeJob.ID)
+		}
+	}
+
 	for endpointID := range edgeJob.Endpoints {
 		handler.ReverseTunnelService.AddEdgeJob(endpointID, edgeJob)
 	}
@@ -0,0 +1,416 @@
+package edgestacks
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	"github.com/cloudogu/portainer-ce/api/internal/endpointutils"
+	"github.com/cloudogu/portainer-ce/api/internal/imagetrust"
+	"github.com/cloudogu/portainer-ce/api/registry"
+	"github.com/docker/docker/api/types"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+)
+
+const (
+	bundleManifestFileName  = "manifest.json"
+	bundleSignatureFileName = "manifest.sig"
+	bundleEnvFileName       = "env.json"
+	bundleImagesFileName    = "images.tar"
+	bundleStackDirectory    = "stack"
+)
+
+type edgeStackBundleManifest struct {
+	StackID        portainer.EdgeStackID
+	Name           string
+	Version        int
+	EndpointID     portainer.EndpointID
+	DeploymentType portainer.EdgeStackDeploymentType
+	Namespace      string `json:",omitempty"`
+	Prune          bool
+	EntryPoint     string
+	CommitHash     string `json:",omitempty"`
+	Images         []string
+	ImagesIncluded bool
+	Files          map[string]string
+	CreatedAt      int64
+}
+
+type edgeStackBundleSignature struct {
+	Signature string
+	PublicKey string
+}
+
+type edgeStackBundleEnv struct {
+	Env            []portainer.Pair
+	SecretEnvNames []string
+}
+
+type bundleFile struct {
+	name    string
+	content []byte
+	path    string
+}
+
+// GET request on /api/edge_stacks/:id/bundle?endpointId=<endpointId>&sourceEndpointId=<sourceEndpointId>
+// Exports an Edge stack as a gzipped archive that can be deployed by the agent of an endpoint without connectivity.
+// The images of a Compose file are verified by the image trust policy of the endpoint group and pinned by digest,
+// the export is refused when the enforce policy rejects images.
+// The archive contains the stack file, the environment resolved for the endpoint without the values of the secret
+// variables, which are sent to the agent through the reverse tunnel at its next check-in, the images of the stack saved
+// from the Docker endpoint specified by sourceEndpointId when present, and a manifest listing the SHA256 digest
+// of each file. The manifest is signed with the digital signature key of the instance.
+// The manifest version is the version expected in the status later reported by the agent.
+func (handler *Handler) edgeStackBundle(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	edgeStackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid edge stack identifier route variable", err}
+	}
+
+	endpointID, err := request.RetrieveNumericQueryParameter(r, "endpointId", false)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid query parameter: endpointId", err}
+	}
+
+	sourceEndpointID, err := request.RetrieveNumericQueryParameter(r, "sourceEndpointId", true)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid query parameter: sourceEndpointId", err}
+	}
+
+	edgeStack, err := handler.DataStore.EdgeStack().EdgeStack(portainer.EdgeStackID(edgeStackID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an edge stack with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an edge stack with the specified identifier inside the database", err}
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
+	}
+
+	relation, err := handler.DataStore.EndpointRelation().EndpointRelation(endpoint.ID)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find endpoint relation in database", err}
+	}
+
+	if !relation.EdgeStacks[edgeStack.ID] {
+		return &httperror.HandlerError{http.StatusBadRequest, "The edge stack is not deployed on the endpoint", errors.New("Endpoint is not related to the edge stack")}
+	}
+
+	if !edge.EdgeStackDeployableOnEndpoint(edgeStack, endpoint.Type) {
+		return &httperror.HandlerError{http.StatusBadRequest, "The deployment type of the edge stack is not supported by the endpoint", errors.New("Unsupported deployment type")}
+	}
+
+	stackFileContent, err := handler.FileService.GetFileContent(path.Join(edgeStack.ProjectPath, edgeStack.EntryPoint))
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve stack file from disk", err}
+	}
+
+	endpointGroup, err := handler.DataStore.EndpointGroup().EndpointGroup(endpoint.GroupID)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find the endpoint group inside the database", err}
+	}
+
+	env, secretEnv := edge.ResolveEdgeStackEnv(edgeStack, endpoint, endpointGroup)
+
+	env, err = edge.DecryptEnv(env, handler.SecretService)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to decrypt the environment variables", err}
+	}
+
+	if edgeStack.DeploymentType == portainer.EdgeStackDeploymentCompose {
+		decryptedSecretEnv, err := edge.DecryptEnv(secretEnv, handler.SecretService)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to decrypt the secret environment variables", err}
+		}
+
+		interpolationEnv := append(append([]portainer.Pair{}, env...), decryptedSecretEnv...)
+		stackFileContent, err = handler.ImageTrustService.TrustedComposeFile(stackFileContent, interpolationEnv, endpoint)
+		if _, ok := err.(*imagetrust.UntrustedImagesError); ok {
+			return &httperror.HandlerError{http.StatusForbidden, "Unable to verify the signature of the edge stack images", err}
+		} else if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to verify the signature of the edge stack images", err}
+		}
+	}
+
+	images, err := edge.EdgeStackImages(stackFileContent)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Unable to parse the images of the stack file", err}
+	}
+
+	bundleEnv := edgeStackBundleEnv{Env: env, SecretEnvNames: make([]string, 0, len(secretEnv))}
+	for _, pair := range secretEnv {
+		bundleEnv.SecretEnvNames = append(bundleEnv.SecretEnvNames, pair.Name)
+	}
+
+	envContent, err := json.Marshal(bundleEnv)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to encode the environment variables", err}
+	}
+
+	files := []bundleFile{
+		{name: path.Join(bundleStackDirectory, edgeStack.EntryPoint), content: stackFileContent},
+		{name: bundleEnvFileName, content: envContent},
+	}
+
+	imagesIncluded := false
+	if sourceEndpointID != 0 && len(images) > 0 {
+		sourceEndpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(sourceEndpointID))
+		if err == bolterrors.ErrObjectNotFound {
+			return &httperror.HandlerError{http.StatusNotFound, "Unable to find the source endpoint inside the database", err}
+		} else if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find the source endpoint inside the database", err}
+		}
+
+		if !endpointutils.IsDockerEndpoint(sourceEndpoint) {
+			return &httperror.HandlerError{http.StatusBadRequest, "The images can only be saved from a Docker endpoint", errors.New("Invalid source endpoint type")}
+		}
+
+		imagesDirectory, err := handler.FileService.GetTemporaryPath()
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to create a temporary directory", err}
+		}
+		defer handler.FileService.RemoveDirectory(imagesDirectory)
+
+		imagesPath := path.Join(imagesDirectory, bundleImagesFileName)
+		err = handler.saveImages(sourceEndpoint, images, imagesPath)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to save the images of the stack from the source endpoint", err}
+		}
+
+		files = append(files, bundleFile{name: bundleImagesFileName, path: imagesPath})
+		imagesIncluded = true
+	}
+
+	manifest := edgeStackBundleManifest{
+		StackID:        edgeStack.ID,
+		Name:           edgeStack.Name,
+		Version:        edge.EdgeStackEndpointVersion(edgeStack, endpoint.ID),
+		EndpointID:     endpoint.ID,
+		DeploymentType: edgeStack.DeploymentType,
+		Namespace:      edgeStack.Namespace,
+		Prune:          edgeStack.Prune,
+		EntryPoint:     edgeStack.EntryPoint,
+		Images:         images,
+		ImagesIncluded: imagesIncluded,
+		Files:          map[string]string{},
+		CreatedAt:      time.Now().Unix(),
+	}
+
+	if edgeStack.GitConfig != nil {
+		manifest.CommitHash = edgeStack.GitConfig.CommitHash
+	}
+
+	if len(secretEnv) > 0 {
+		if edgeStack.PendingSecretEnv == nil {
+			edgeStack.PendingSecretEnv = map[portainer.EndpointID]int{}
+		}
+		edgeStack.PendingSecretEnv[endpoint.ID] = manifest.Version
+
+		err = handler.DataStore.EdgeStack().UpdateEdgeStack(edgeStack.ID, edgeStack)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the edge stack changes inside the database", err}
+		}
+	}
+
+	for _, file := range files {
+		manifest.Files[file.name], err = file.digest()
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to compute the digest of the bundle files", err}
+		}
+	}
+
+	manifestContent, err := json.Marshal(manifest)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to encode the bundle manifest", err}
+	}
+
+	signature, err := handler.SignatureService.SignMessage(string(manifestContent))
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to sign the bundle manifest", err}
+	}
+
+	signatureContent, err := json.Marshal(edgeStackBundleSignature{
+		Signature: signature,
+		PublicKey: handler.SignatureService.EncodedPublicKey(),
+	})
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to encode the bundle signature", err}
+	}
+
+	files = append(files,
+		bundleFile{name: bundleManifestFileName, content: manifestContent},
+		bundleFile{name: bundleSignatureFileName, content: signatureContent},
+	)
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=edge-stack-%d-endpoint-%d-v%d.tar.gz", edgeStack.ID, endpoint.ID, manifest.Version))
+
+	err = writeBundle(w, files)
+	if err != nil {
+		log.Printf("[WARN] [http,edgestacks] [message: unable to write edge stack bundle] [edge_stack: %s] [err: %s]", edgeStack.Name, err)
+	}
+
+	return nil
+}
+
+func (handler *Handler) saveImages(endpoint *portainer.Endpoint, images []string, destination string) error {
+	if endpoint.Type == portainer.EdgeAgentOnDockerEnvironment {
+		err := edge.WakeUpEdgeAgent(endpoint.ID, handler.ReverseTunnelService, handler.DataStore)
+		if err != nil {
+			return err
+		}
+	}
+
+	cli, err := handler.DockerClientFactory.CreateClient(endpoint, "")
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	for _, image := range images {
+		ref, err := registry.ParseImageReference(image)
+		if err != nil {
+			return err
+		}
+
+		credentials, err := registry.FindCredentials(handler.DataStore, ref.Domain)
+		if err != nil {
+			return err
+		}
+
+		options := types.ImagePullOptions{}
+		if credentials != nil {
+			options.RegistryAuth, err = registry.EncodeAuthConfig(credentials, ref.Domain)
+			if err != nil {
+				return err
+			}
+		}
+
+		reader, err := cli.ImagePull(context.Background(), image, options)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(ioutil.Discard, reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	reader, err := cli.ImageSave(context.Background(), images)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = os.MkdirAll(path.Dir(destination), 0700)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, reader)
+	return err
+}
+
+func (file *bundleFile) open() (io.ReadCloser, int64, error) {
+	if file.path == "" {
+		return ioutil.NopCloser(bytes.NewReader(file.content)), int64(len(file.content)), nil
+	}
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, info.Size(), nil
+}
+
+func (file *bundleFile) digest() (string, error) {
+	reader, _, err := file.open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, reader)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func writeBundle(w io.Writer, files []bundleFile) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, file := range files {
+		err := writeBundleFile(tarWriter, file)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := tarWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
+
+func writeBundleFile(tarWriter *tar.Writer, file bundleFile) error {
+	reader, size, err := file.open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:    file.name,
+		Mode:    0600,
+		Size:    size,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tarWriter, reader)
+	return err
+}
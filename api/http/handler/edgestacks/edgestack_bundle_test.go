@@ -0,0 +1,217 @@
+package edgestacks
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/crypto"
+	"github.com/cloudogu/portainer-ce/api/internal/imagetrust"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/gorilla/mux"
+	"github.com/portainer/libcrypto"
+	"github.com/stretchr/testify/assert"
+)
+
+const bundleTestStackFile = "version: '3'\nservices:\n  web:\n    image: nginx:latest\n"
+
+type testFileService struct {
+	portainer.FileService
+	content []byte
+}
+
+func (service testFileService) GetFileContent(filePath string) ([]byte, error) {
+	return service.content, nil
+}
+
+// testImageTrustService pins the image of the stack file when it is trusted and rejects it otherwise
+type testImageTrustService struct {
+	portainer.ImageTrustService
+	pinnedImages map[string]string
+}
+
+func (service testImageTrustService) TrustedComposeFile(content []byte, env []portainer.Pair, endpoint *portainer.Endpoint) ([]byte, error) {
+	pinnedImage, ok := service.pinnedImages["nginx:latest"]
+	if !ok {
+		return nil, &imagetrust.UntrustedImagesError{Images: []string{"nginx:latest"}}
+	}
+	return []byte(strings.Replace(string(content), "nginx:latest", pinnedImage, -1)), nil
+}
+
+func readBundle(t *testing.T, body io.Reader) map[string][]byte {
+	gzipReader, err := gzip.NewReader(body)
+	assert.NoError(t, err)
+
+	files := map[string][]byte{}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+
+		content, err := ioutil.ReadAll(tarReader)
+		assert.NoError(t, err)
+		files[header.Name] = content
+	}
+	return files
+}
+
+func verifyBundleSignature(t *testing.T, manifest []byte, bundleSignature edgeStackBundleSignature) bool {
+	publicKeyContent, err := hex.DecodeString(bundleSignature.PublicKey)
+	assert.NoError(t, err)
+	publicKey, err := x509.ParsePKIXPublicKey(publicKeyContent)
+	assert.NoError(t, err)
+
+	signature, err := base64.RawStdEncoding.DecodeString(bundleSignature.Signature)
+	assert.NoError(t, err)
+	r := new(big.Int).SetBytes(signature[:len(signature)/2])
+	s := new(big.Int).SetBytes(signature[len(signature)/2:])
+
+	return ecdsa.Verify(publicKey.(*ecdsa.PublicKey), libcrypto.HashFromBytes(manifest), r, s)
+}
+
+func newBundleTestHandler(t *testing.T, store portainer.DataStore) *Handler {
+	// the agent secret must not replace the signed manifest
+	signatureService := crypto.NewECDSAService("agent-secret")
+	_, _, err := signatureService.GenerateKeyPair()
+	assert.NoError(t, err)
+
+	secretService := crypto.NewSecretService()
+	key, err := secretService.GenerateKey()
+	assert.NoError(t, err)
+	assert.NoError(t, secretService.ParseKey(key))
+
+	encryptedToken, err := secretService.Encrypt("secret-token")
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.EndpointGroup().CreateEndpointGroup(&portainer.EndpointGroup{Name: "group"}))
+	assert.NoError(t, store.Endpoint().CreateEndpoint(&portainer.Endpoint{ID: 1, GroupID: 1, Type: portainer.EdgeAgentOnDockerEnvironment, EdgeID: "edge-id"}))
+	assert.NoError(t, store.EndpointRelation().CreateEndpointRelation(&portainer.EndpointRelation{EndpointID: 1, EdgeStacks: map[portainer.EdgeStackID]bool{1: true}}))
+	assert.NoError(t, store.EdgeStack().CreateEdgeStack(&portainer.EdgeStack{
+		ID:         1,
+		Name:       "stack",
+		Version:    2,
+		EntryPoint: "docker-compose.yml",
+		Env: []portainer.EdgeStackEnvVar{
+			{Name: "REGION", Value: "eu"},
+			{Name: "API_TOKEN", Value: encryptedToken, Secret: true},
+		},
+	}))
+
+	return &Handler{
+		DataStore:        store,
+		FileService:      testFileService{content: []byte(bundleTestStackFile)},
+		SignatureService: signatureService,
+		SecretService:    secretService,
+		ImageTrustService: testImageTrustService{
+			pinnedImages: map[string]string{"nginx:latest": "nginx@sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+		},
+	}
+}
+
+func requestBundle(handler *Handler, query string) (*httptest.ResponseRecorder, error) {
+	r := httptest.NewRequest(http.MethodGet, "/edge_stacks/1/bundle?"+query, nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+	w := httptest.NewRecorder()
+
+	handlerErr := handler.edgeStackBundle(w, r)
+	if handlerErr != nil {
+		w.WriteHeader(handlerErr.StatusCode)
+		return w, handlerErr.Err
+	}
+	return w, nil
+}
+
+func Test_edgeStackBundle(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	handler := newBundleTestHandler(t, store)
+
+	w, err := requestBundle(handler, "endpointId=1")
+	assert.NoError(t, err)
+
+	files := readBundle(t, w.Body)
+	assert.Equal(t, "version: '3'\nservices:\n  web:\n    image: nginx@sha256:1111111111111111111111111111111111111111111111111111111111111111\n", string(files["stack/docker-compose.yml"]))
+	for name, content := range files {
+		assert.NotContains(t, string(content), "secret-token", "%s must not contain the secret values", name)
+		assert.NotContains(t, string(content), "encrypted:", "%s must not contain the secret values", name)
+	}
+
+	var manifest edgeStackBundleManifest
+	assert.NoError(t, json.Unmarshal(files[bundleManifestFileName], &manifest))
+	assert.Equal(t, portainer.EdgeStackID(1), manifest.StackID)
+	assert.Equal(t, portainer.EndpointID(1), manifest.EndpointID)
+	assert.Equal(t, 2, manifest.Version)
+	assert.Equal(t, []string{"nginx@sha256:1111111111111111111111111111111111111111111111111111111111111111"}, manifest.Images)
+	assert.False(t, manifest.ImagesIncluded)
+	assert.Len(t, manifest.Files, 2)
+
+	for name, digest := range manifest.Files {
+		content, ok := files[name]
+		assert.True(t, ok, "the bundle must contain %s", name)
+		sum := sha256.Sum256(content)
+		assert.Equal(t, hex.EncodeToString(sum[:]), digest, "the digest of %s must match its content", name)
+	}
+
+	var env edgeStackBundleEnv
+	assert.NoError(t, json.Unmarshal(files[bundleEnvFileName], &env))
+	assert.Equal(t, []portainer.Pair{{Name: "REGION", Value: "eu"}}, env.Env)
+
+	assert.Equal(t, []string{"API_TOKEN"}, env.SecretEnvNames)
+
+	edgeStack, err := store.EdgeStack().EdgeStack(1)
+	assert.NoError(t, err)
+	assert.Equal(t, map[portainer.EndpointID]int{1: 2}, edgeStack.PendingSecretEnv)
+
+	var bundleSignature edgeStackBundleSignature
+	assert.NoError(t, json.Unmarshal(files[bundleSignatureFileName], &bundleSignature))
+	assert.Equal(t, handler.SignatureService.EncodedPublicKey(), bundleSignature.PublicKey)
+	assert.True(t, verifyBundleSignature(t, files[bundleManifestFileName], bundleSignature))
+
+	tamperedManifest := append([]byte{}, files[bundleManifestFileName]...)
+	tamperedManifest[len(tamperedManifest)-2]++
+	assert.False(t, verifyBundleSignature(t, tamperedManifest, bundleSignature))
+}
+
+func Test_edgeStackBundle_InvalidSourceEndpoint(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	handler := newBundleTestHandler(t, store)
+
+	w, err := requestBundle(handler, "endpointId=1&sourceEndpointId=abc")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func Test_edgeStackBundle_UntrustedImages(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	handler := newBundleTestHandler(t, store)
+	handler.ImageTrustService = testImageTrustService{}
+
+	w, err := requestBundle(handler, "endpointId=1")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	edgeStack, err := store.EdgeStack().EdgeStack(1)
+	assert.NoError(t, err)
+	assert.Empty(t, edgeStack.PendingSecretEnv)
+}
@@ -9,7 +9,6 @@ import (
 
 	"github.com/asaskevich/govalidator"
 	"github.com/cloudogu/portainer-ce/api"
-	"github.com/cloudogu/portainer-ce/api/filesystem"
 	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
@@ -59,6 +58,12 @@ func (handler *Handler) edgeStackCreate(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	err = edge.SealEndpointsMetadata(handler.DataStore, handler.SecretService)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to encrypt the secret metadata of the endpoints", err}
+	}
+
+	hideSecretEnv(edgeStack)
 	return response.JSON(w, edgeStack)
 }
 
@@ -78,6 +83,10 @@ type swarmStackFromFileContentPayload struct {
 	Name             string
 	StackFileContent string
 	EdgeGroups       []portainer.EdgeGroupID
+	Env              []portainer.EdgeStackEnvVar
+	TagEnv           []portainer.EdgeStackTagEnvVar
+	DeploymentType   portainer.EdgeStackDeploymentType
+	Namespace        string
 }
 
 func (payload *swarmStackFromFileContentPayload) Validate(r *http.Request) error {
@@ -90,7 +99,11 @@ func (payload *swarmStackFromFileContentPayload) Validate(r *http.Request) error
 	if payload.EdgeGroups == nil || len(payload.EdgeGroups) == 0 {
 		return errors.New("Edge Groups are mandatory for an Edge stack")
 	}
-	return nil
+	err := validateEdgeStackDeployment(payload.DeploymentType, &payload.Namespace)
+	if err != nil {
+		return err
+	}
+	return validateEdgeStackEnv(payload.Env, payload.TagEnv)
 }
 
 func (handler *Handler) createSwarmStackFromFileContent(r *http.Request) (*portainer.EdgeStack, error) {
@@ -107,13 +120,17 @@ func (handler *Handler) createSwarmStackFromFileContent(r *http.Request) (*porta
 
 	stackID := handler.DataStore.EdgeStack().GetNextIdentifier()
 	stack := &portainer.EdgeStack{
-		ID:           portainer.EdgeStackID(stackID),
-		Name:         payload.Name,
-		EntryPoint:   filesystem.ComposeFileDefaultName,
-		CreationDate: time.Now().Unix(),
-		EdgeGroups:   payload.EdgeGroups,
-		Status:       make(map[portainer.EndpointID]portainer.EdgeStackStatus),
-		Version:      1,
+		ID:             portainer.EdgeStackID(stackID),
+		Name:           payload.Name,
+		EntryPoint:     edgeStackDefaultEntryPoint(payload.DeploymentType),
+		CreationDate:   time.Now().Unix(),
+		EdgeGroups:     payload.EdgeGroups,
+		Status:         make(map[portainer.EndpointID]portainer.EdgeStackStatus),
+		Version:        1,
+		Env:            payload.Env,
+		TagEnv:         payload.TagEnv,
+		DeploymentType: payload.DeploymentType,
+		Namespace:      payload.Namespace,
 	}
 
 	stackFolder := strconv.Itoa(int(stack.ID))
@@ -123,6 +140,11 @@ func (handler *Handler) createSwarmStackFromFileContent(r *http.Request) (*porta
 	}
 	stack.ProjectPath = projectPath
 
+	err = edge.SealEdgeStackEnv(stack, handler.SecretService)
+	if err != nil {
+		return nil, err
+	}
+
 	err = handler.DataStore.EdgeStack().CreateEdgeStack(stack)
 	if err != nil {
 		return nil, err
@@ -139,7 +161,12 @@ type swarmStackFromGitRepositoryPayload struct {
 	RepositoryUsername          string
 	RepositoryPassword          string
 	ComposeFilePathInRepository string
+	AutoSync                    bool
 	EdgeGroups                  []portainer.EdgeGroupID
+	Env                         []portainer.EdgeStackEnvVar
+	TagEnv                      []portainer.EdgeStackTagEnvVar
+	DeploymentType              portainer.EdgeStackDeploymentType
+	Namespace                   string
 }
 
 func (payload *swarmStackFromGitRepositoryPayload) Validate(r *http.Request) error {
@@ -153,12 +180,16 @@ func (payload *swarmStackFromGitRepositoryPayload) Validate(r *http.Request) err
 		return errors.New("Invalid repository credentials. Username and password must be specified when authentication is enabled")
 	}
 	if govalidator.IsNull(payload.ComposeFilePathInRepository) {
-		payload.ComposeFilePathInRepository = filesystem.ComposeFileDefaultName
+		payload.ComposeFilePathInRepository = edgeStackDefaultEntryPoint(payload.DeploymentType)
 	}
 	if payload.EdgeGroups == nil || len(payload.EdgeGroups) == 0 {
 		return errors.New("Edge Groups are mandatory for an Edge stack")
 	}
-	return nil
+	err := validateEdgeStackDeployment(payload.DeploymentType, &payload.Namespace)
+	if err != nil {
+		return err
+	}
+	return validateEdgeStackEnv(payload.Env, payload.TagEnv)
 }
 
 func (handler *Handler) createSwarmStackFromGitRepository(r *http.Request) (*portainer.EdgeStack, error) {
@@ -175,13 +206,17 @@ func (handler *Handler) createSwarmStackFromGitRepository(r *http.Request) (*por
 
 	stackID := handler.DataStore.EdgeStack().GetNextIdentifier()
 	stack := &portainer.EdgeStack{
-		ID:           portainer.EdgeStackID(stackID),
-		Name:         payload.Name,
-		EntryPoint:   payload.ComposeFilePathInRepository,
-		CreationDate: time.Now().Unix(),
-		EdgeGroups:   payload.EdgeGroups,
-		Status:       make(map[portainer.EndpointID]portainer.EdgeStackStatus),
-		Version:      1,
+		ID:             portainer.EdgeStackID(stackID),
+		Name:           payload.Name,
+		EntryPoint:     payload.ComposeFilePathInRepository,
+		CreationDate:   time.Now().Unix(),
+		EdgeGroups:     payload.EdgeGroups,
+		Status:         make(map[portainer.EndpointID]portainer.EdgeStackStatus),
+		Version:        1,
+		Env:            payload.Env,
+		TagEnv:         payload.TagEnv,
+		DeploymentType: payload.DeploymentType,
+		Namespace:      payload.Namespace,
 	}
 
 	projectPath := handler.FileService.GetEdgeStackProjectPath(strconv.Itoa(int(stack.ID)))
@@ -201,6 +236,29 @@ func (handler *Handler) createSwarmStackFromGitRepository(r *http.Request) (*por
 		return nil, err
 	}
 
+	stack.GitConfig = &portainer.EdgeStackGitConfig{
+		URL:            payload.RepositoryURL,
+		ReferenceName:  payload.RepositoryReferenceName,
+		Authentication: payload.RepositoryAuthentication,
+		AutoSync:       payload.AutoSync,
+		LastSync:       time.Now().Unix(),
+	}
+	if payload.RepositoryAuthentication {
+		stack.GitConfig.Username = payload.RepositoryUsername
+		stack.GitConfig.Password = payload.RepositoryPassword
+	}
+
+	commitHash, err := handler.GitService.LatestCommitID(payload.RepositoryURL, payload.RepositoryReferenceName, stack.GitConfig.Username, stack.GitConfig.Password)
+	if err != nil {
+		return nil, err
+	}
+	stack.GitConfig.CommitHash = commitHash
+
+	err = edge.SealEdgeStackEnv(stack, handler.SecretService)
+	if err != nil {
+		return nil, err
+	}
+
 	err = handler.DataStore.EdgeStack().CreateEdgeStack(stack)
 	if err != nil {
 		return nil, err
@@ -213,6 +271,10 @@ type swarmStackFromFileUploadPayload struct {
 	Name             string
 	StackFileContent []byte
 	EdgeGroups       []portainer.EdgeGroupID
+	Env              []portainer.EdgeStackEnvVar
+	TagEnv           []portainer.EdgeStackTagEnvVar
+	DeploymentType   portainer.EdgeStackDeploymentType
+	Namespace        string
 }
 
 func (payload *swarmStackFromFileUploadPayload) Validate(r *http.Request) error {
@@ -234,7 +296,34 @@ func (payload *swarmStackFromFileUploadPayload) Validate(r *http.Request) error
 		return errors.New("Edge Groups are mandatory for an Edge stack")
 	}
 	payload.EdgeGroups = edgeGroups
-	return nil
+
+	err = request.RetrieveMultiPartFormJSONValue(r, "Env", &payload.Env, true)
+	if err != nil {
+		return errors.New("Invalid environment variables")
+	}
+
+	err = request.RetrieveMultiPartFormJSONValue(r, "TagEnv", &payload.TagEnv, true)
+	if err != nil {
+		return errors.New("Invalid tag environment variables")
+	}
+
+	deploymentType, err := request.RetrieveNumericMultiPartFormValue(r, "DeploymentType", true)
+	if err != nil {
+		return errors.New("Invalid deployment type")
+	}
+	payload.DeploymentType = portainer.EdgeStackDeploymentType(deploymentType)
+
+	payload.Namespace, err = request.RetrieveMultiPartFormValue(r, "Namespace", true)
+	if err != nil {
+		return errors.New("Invalid namespace")
+	}
+
+	err = validateEdgeStackDeployment(payload.DeploymentType, &payload.Namespace)
+	if err != nil {
+		return err
+	}
+
+	return validateEdgeStackEnv(payload.Env, payload.TagEnv)
 }
 
 func (handler *Handler) createSwarmStackFromFileUpload(r *http.Request) (*portainer.EdgeStack, error) {
@@ -251,13 +340,17 @@ func (handler *Handler) createSwarmStackFromFileUpload(r *http.Request) (*portai
 
 	stackID := handler.DataStore.EdgeStack().GetNextIdentifier()
 	stack := &portainer.EdgeStack{
-		ID:           portainer.EdgeStackID(stackID),
-		Name:         payload.Name,
-		EntryPoint:   filesystem.ComposeFileDefaultName,
-		CreationDate: time.Now().Unix(),
-		EdgeGroups:   payload.EdgeGroups,
-		Status:       make(map[portainer.EndpointID]portainer.EdgeStackStatus),
-		Version:      1,
+		ID:             portainer.EdgeStackID(stackID),
+		Name:           payload.Name,
+		EntryPoint:     edgeStackDefaultEntryPoint(payload.DeploymentType),
+		CreationDate:   time.Now().Unix(),
+		EdgeGroups:     payload.EdgeGroups,
+		Status:         make(map[portainer.EndpointID]portainer.EdgeStackStatus),
+		Version:        1,
+		Env:            payload.Env,
+		TagEnv:         payload.TagEnv,
+		DeploymentType: payload.DeploymentType,
+		Namespace:      payload.Namespace,
 	}
 
 	stackFolder := strconv.Itoa(int(stack.ID))
@@ -267,6 +360,11 @@ func (handler *Handler) createSwarmStackFromFileUpload(r *http.Request) (*portai
 	}
 	stack.ProjectPath = projectPath
 
+	err = edge.SealEdgeStackEnv(stack, handler.SecretService)
+	if err != nil {
+		return nil, err
+	}
+
 	err = handler.DataStore.EdgeStack().CreateEdgeStack(stack)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,56 @@
+package edgestacks
+
+import (
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type edgeStackEndpointInspectResponse struct {
+	EndpointID  portainer.EndpointID            `json:"EndpointId"`
+	Version     int                             `json:"Version"`
+	Status      *portainer.EdgeStackStatus      `json:"Status"`
+	Deployments []portainer.EdgeStackDeployment `json:"Deployments"`
+}
+
+// GET request on /api/edge_stacks/:id/endpoints/:endpointId
+// Returns the deployment status and the deployment history of the Edge stack on an endpoint.
+func (handler *Handler) edgeStackEndpointInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	edgeStackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid edge stack identifier route variable", err}
+	}
+
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "endpointId")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid endpoint identifier route variable", err}
+	}
+
+	edgeStack, err := handler.DataStore.EdgeStack().EdgeStack(portainer.EdgeStackID(edgeStackID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an edge stack with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an edge stack with the specified identifier inside the database", err}
+	}
+
+	resp := edgeStackEndpointInspectResponse{
+		EndpointID:  portainer.EndpointID(endpointID),
+		Version:     edge.EdgeStackEndpointVersion(edgeStack, portainer.EndpointID(endpointID)),
+		Deployments: edgeStack.Deployments[portainer.EndpointID(endpointID)],
+	}
+
+	if status, ok := edgeStack.Status[portainer.EndpointID(endpointID)]; ok {
+		resp.Status = &status
+	}
+
+	if resp.Deployments == nil {
+		resp.Deployments = []portainer.EdgeStackDeployment{}
+	}
+
+	return response.JSON(w, resp)
+}
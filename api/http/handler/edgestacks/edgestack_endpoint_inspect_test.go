@@ -0,0 +1,76 @@
+package edgestacks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func inspectEdgeStackEndpoint(t *testing.T, handler *Handler, edgeStackID, endpointID string) edgeStackEndpointInspectResponse {
+	r := httptest.NewRequest(http.MethodGet, "/edge_stacks/"+edgeStackID+"/endpoints/"+endpointID, nil)
+	r = mux.SetURLVars(r, map[string]string{"id": edgeStackID, "endpointId": endpointID})
+	w := httptest.NewRecorder()
+
+	handlerErr := handler.edgeStackEndpointInspect(w, r)
+	assert.Nil(t, handlerErr)
+
+	var resp edgeStackEndpointInspectResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	return resp
+}
+
+func Test_edgeStackEndpointInspect(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	edgeStack := &portainer.EdgeStack{
+		ID:              1,
+		Name:            "stack",
+		Version:         2,
+		EndpointRetries: map[portainer.EndpointID]int{1: 1},
+		Status: map[portainer.EndpointID]portainer.EdgeStackStatus{
+			1: {Type: portainer.StatusError, EndpointID: 1, Version: 3, Error: "pull failed"},
+		},
+		Deployments: map[portainer.EndpointID][]portainer.EdgeStackDeployment{
+			1: {
+				{Version: 2, Type: portainer.StatusError, Error: "pull failed", StartedAt: 100, FinishedAt: 110},
+				{Version: 3, Type: portainer.StatusError, Error: "pull failed", StartedAt: 200, FinishedAt: 210},
+			},
+		},
+	}
+	assert.NoError(t, store.EdgeStack().CreateEdgeStack(edgeStack))
+
+	handler := &Handler{DataStore: store}
+
+	t.Run("endpoint with a deployment history", func(t *testing.T) {
+		resp := inspectEdgeStackEndpoint(t, handler, "1", "1")
+		assert.Equal(t, portainer.EndpointID(1), resp.EndpointID)
+		assert.Equal(t, 3, resp.Version)
+		assert.NotNil(t, resp.Status)
+		assert.Equal(t, "pull failed", resp.Status.Error)
+		assert.Len(t, resp.Deployments, 2)
+		assert.Equal(t, int64(200), resp.Deployments[1].StartedAt)
+	})
+
+	t.Run("endpoint without deployment", func(t *testing.T) {
+		resp := inspectEdgeStackEndpoint(t, handler, "1", "2")
+		assert.Equal(t, 2, resp.Version)
+		assert.Nil(t, resp.Status)
+		assert.NotNil(t, resp.Deployments)
+		assert.Empty(t, resp.Deployments)
+	})
+
+	t.Run("unknown edge stack", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/edge_stacks/2/endpoints/1", nil)
+		r = mux.SetURLVars(r, map[string]string{"id": "2", "endpointId": "1"})
+		handlerErr := handler.edgeStackEndpointInspect(httptest.NewRecorder(), r)
+		assert.NotNil(t, handlerErr)
+		assert.Equal(t, http.StatusNotFound, handlerErr.StatusCode)
+	})
+}
@@ -0,0 +1,120 @@
+package edgestacks
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type edgeStackGitUpdatePayload struct {
+	ReferenceName            *string
+	AutoSync                 *bool
+	RepositoryAuthentication *bool
+	RepositoryUsername       string
+	RepositoryPassword       string
+}
+
+func (payload *edgeStackGitUpdatePayload) Validate(r *http.Request) error {
+	if payload.RepositoryAuthentication != nil && *payload.RepositoryAuthentication && govalidator.IsNull(payload.RepositoryUsername) {
+		return errors.New("Invalid repository credentials. Username must be specified when authentication is enabled")
+	}
+	return nil
+}
+
+// PUT request on /api/edge_stacks/:id/git
+// Updates the Git settings of an Edge stack deployed from a Git repository. The password is kept
+// when authentication remains enabled and no new password is specified.
+func (handler *Handler) edgeStackGitUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	edgeStackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid edge stack identifier route variable", err}
+	}
+
+	var payload edgeStackGitUpdatePayload
+	err = request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	edgeStack, err := handler.DataStore.EdgeStack().EdgeStack(portainer.EdgeStackID(edgeStackID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an edge stack with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an edge stack with the specified identifier inside the database", err}
+	}
+
+	if edgeStack.GitConfig == nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "The edge stack is not deployed from a Git repository", errors.New("Missing Git configuration")}
+	}
+
+	gitConfig := edgeStack.GitConfig
+
+	if payload.ReferenceName != nil && *payload.ReferenceName != gitConfig.ReferenceName {
+		gitConfig.ReferenceName = *payload.ReferenceName
+		gitConfig.CommitHash = ""
+	}
+
+	if payload.AutoSync != nil {
+		gitConfig.AutoSync = *payload.AutoSync
+	}
+
+	if payload.RepositoryAuthentication != nil {
+		if *payload.RepositoryAuthentication {
+			password := payload.RepositoryPassword
+			if password == "" && gitConfig.Authentication {
+				password = gitConfig.Password
+			}
+			if password == "" {
+				return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", errors.New("Invalid repository credentials. Password must be specified when authentication is enabled")}
+			}
+			gitConfig.Username = payload.RepositoryUsername
+			gitConfig.Password = password
+		} else {
+			gitConfig.Username = ""
+			gitConfig.Password = ""
+		}
+		gitConfig.Authentication = *payload.RepositoryAuthentication
+	}
+
+	err = handler.DataStore.EdgeStack().UpdateEdgeStack(edgeStack.ID, edgeStack)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the edge stack changes inside the database", err}
+	}
+
+	hideSecretEnv(edgeStack)
+	return response.JSON(w, edgeStack)
+}
+
+// POST request on /api/edge_stacks/:id/git/sync
+// Checks the repository of the Edge stack for a new commit and redeploys the stack when one is found
+func (handler *Handler) edgeStackGitSync(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	edgeStackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid edge stack identifier route variable", err}
+	}
+
+	edgeStack, err := handler.DataStore.EdgeStack().EdgeStack(portainer.EdgeStackID(edgeStackID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an edge stack with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an edge stack with the specified identifier inside the database", err}
+	}
+
+	if edgeStack.GitConfig == nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "The edge stack is not deployed from a Git repository", errors.New("Missing Git configuration")}
+	}
+
+	edgeStack, err = handler.GitSyncService.SyncEdgeStack(edgeStack.ID)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to sync the edge stack with its Git repository", err}
+	}
+
+	hideSecretEnv(edgeStack)
+	return response.JSON(w, edgeStack)
+}
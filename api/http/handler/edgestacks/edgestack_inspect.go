@@ -23,5 +23,6 @@ func (handler *Handler) edgeStackInspect(w http.ResponseWriter, r *http.Request)
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an edge stack with the specified identifier inside the database", err}
 	}
 
+	hideSecretEnv(edgeStack)
 	return response.JSON(w, edgeStack)
 }
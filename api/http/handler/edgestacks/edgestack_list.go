@@ -13,5 +13,10 @@ func (handler *Handler) edgeStackList(w http.ResponseWriter, r *http.Request) *h
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve edge stacks from the database", err}
 	}
 
+	for idx := range edgeStacks {
+		edgeStacks[idx].Deployments = nil
+		hideSecretEnv(&edgeStacks[idx])
+	}
+
 	return response.JSON(w, edgeStacks)
 }
@@ -0,0 +1,80 @@
+package edgestacks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type edgeStackRetryPayload struct {
+	EndpointIDs []portainer.EndpointID
+}
+
+func (payload *edgeStackRetryPayload) Validate(r *http.Request) error {
+	return nil
+}
+
+// POST request on /api/edge_stacks/:id/retry
+// Retries the deployment of the Edge stack on the specified endpoints, or on every endpoint where
+// the deployment failed when no endpoint is specified. The stack version is left untouched so that
+// the endpoints where the deployment succeeded do not deploy the stack again.
+func (handler *Handler) edgeStackRetry(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	edgeStackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid edge stack identifier route variable", err}
+	}
+
+	var payload edgeStackRetryPayload
+	if r.ContentLength != 0 {
+		err = request.DecodeAndValidateJSONPayload(r, &payload)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+		}
+	}
+
+	edgeStack, err := handler.DataStore.EdgeStack().EdgeStack(portainer.EdgeStackID(edgeStackID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an edge stack with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an edge stack with the specified identifier inside the database", err}
+	}
+
+	endpointIDs := payload.EndpointIDs
+	if len(endpointIDs) == 0 {
+		for endpointID, status := range edgeStack.Status {
+			if status.Type == portainer.StatusError {
+				endpointIDs = append(endpointIDs, endpointID)
+			}
+		}
+	}
+
+	for _, endpointID := range endpointIDs {
+		status, ok := edgeStack.Status[endpointID]
+		if !ok || status.Type != portainer.StatusError {
+			return &httperror.HandlerError{http.StatusBadRequest, fmt.Sprintf("The deployment of the edge stack did not fail on endpoint %d", endpointID), errors.New("Deployment can only be retried on endpoints where it failed")}
+		}
+	}
+
+	if edgeStack.EndpointRetries == nil {
+		edgeStack.EndpointRetries = map[portainer.EndpointID]int{}
+	}
+
+	for _, endpointID := range endpointIDs {
+		edgeStack.EndpointRetries[endpointID]++
+		delete(edgeStack.Status, endpointID)
+	}
+
+	err = handler.DataStore.EdgeStack().UpdateEdgeStack(edgeStack.ID, edgeStack)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the stack changes inside the database", err}
+	}
+
+	hideSecretEnv(edgeStack)
+	return response.JSON(w, edgeStack)
+}
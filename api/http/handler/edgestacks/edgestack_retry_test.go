@@ -0,0 +1,84 @@
+package edgestacks
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRetryRequest(t *testing.T, edgeStackID string, payload *edgeStackRetryPayload) (*httptest.ResponseRecorder, *http.Request) {
+	r := httptest.NewRequest(http.MethodPost, "/edge_stacks/"+edgeStackID+"/retry", nil)
+	if payload != nil {
+		body, err := json.Marshal(payload)
+		assert.NoError(t, err)
+		r = httptest.NewRequest(http.MethodPost, "/edge_stacks/"+edgeStackID+"/retry", bytes.NewReader(body))
+	}
+
+	return httptest.NewRecorder(), mux.SetURLVars(r, map[string]string{"id": edgeStackID})
+}
+
+func Test_edgeStackRetry(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	edgeStack := &portainer.EdgeStack{
+		ID:      1,
+		Name:    "stack",
+		Version: 2,
+		Status: map[portainer.EndpointID]portainer.EdgeStackStatus{
+			1: {Type: portainer.StatusError, EndpointID: 1, Version: 2},
+			2: {Type: portainer.StatusOk, EndpointID: 2, Version: 2},
+			3: {Type: portainer.StatusError, EndpointID: 3, Version: 2},
+		},
+	}
+	assert.NoError(t, store.EdgeStack().CreateEdgeStack(edgeStack))
+
+	handler := &Handler{DataStore: store}
+
+	t.Run("unknown edge stack", func(t *testing.T) {
+		w, r := newRetryRequest(t, "2", nil)
+		handlerErr := handler.edgeStackRetry(w, r)
+		assert.NotNil(t, handlerErr)
+		assert.Equal(t, http.StatusNotFound, handlerErr.StatusCode)
+	})
+
+	t.Run("endpoint where the deployment succeeded", func(t *testing.T) {
+		w, r := newRetryRequest(t, "1", &edgeStackRetryPayload{EndpointIDs: []portainer.EndpointID{2}})
+		handlerErr := handler.edgeStackRetry(w, r)
+		assert.NotNil(t, handlerErr)
+		assert.Equal(t, http.StatusBadRequest, handlerErr.StatusCode)
+	})
+
+	t.Run("specified endpoint", func(t *testing.T) {
+		w, r := newRetryRequest(t, "1", &edgeStackRetryPayload{EndpointIDs: []portainer.EndpointID{1}})
+		assert.Nil(t, handler.edgeStackRetry(w, r))
+
+		edgeStack, err := store.EdgeStack().EdgeStack(1)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, edgeStack.Version)
+		assert.Equal(t, 1, edgeStack.EndpointRetries[1])
+		assert.NotContains(t, edgeStack.Status, portainer.EndpointID(1))
+		assert.Contains(t, edgeStack.Status, portainer.EndpointID(3))
+	})
+
+	t.Run("every failed endpoint", func(t *testing.T) {
+		w, r := newRetryRequest(t, "1", nil)
+		assert.Nil(t, handler.edgeStackRetry(w, r))
+
+		edgeStack, err := store.EdgeStack().EdgeStack(1)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, edgeStack.Version)
+		assert.Equal(t, 1, edgeStack.EndpointRetries[1])
+		assert.Equal(t, 1, edgeStack.EndpointRetries[3])
+		assert.Zero(t, edgeStack.EndpointRetries[2])
+		assert.NotContains(t, edgeStack.Status, portainer.EndpointID(3))
+		assert.Contains(t, edgeStack.Status, portainer.EndpointID(2))
+	})
+}
@@ -3,10 +3,12 @@ package edgestacks
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/cloudogu/portainer-ce/api"
 	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -16,6 +18,9 @@ type updateStatusPayload struct {
 	Error      string
 	Status     *portainer.EdgeStackStatusType
 	EndpointID *portainer.EndpointID
+	Version    *int
+	Output     string
+	Services   []portainer.EdgeStackServiceStatus
 }
 
 func (payload *updateStatusPayload) Validate(r *http.Request) error {
@@ -31,6 +36,10 @@ func (payload *updateStatusPayload) Validate(r *http.Request) error {
 	return nil
 }
 
+// PUT request on /api/edge_stacks/:id/status
+// Updates the deployment status of the Edge stack on an endpoint and records it in the deployment history.
+// Version is the version of the stack deployed by the agent, it defaults to the version sent to the endpoint.
+// For the stacks deployed from Git, the commit of the current stack file is recorded when the versions match.
 func (handler *Handler) edgeStackStatusUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
 	stackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
 	if err != nil {
@@ -62,17 +71,39 @@ func (handler *Handler) edgeStackStatusUpdate(w http.ResponseWriter, r *http.Req
 		return &httperror.HandlerError{http.StatusForbidden, "Permission denied to access endpoint", err}
 	}
 
+	version := edge.EdgeStackEndpointVersion(stack, endpoint.ID)
+	if payload.Version != nil {
+		version = *payload.Version
+	}
+
+	commitHash := ""
+	if stack.GitConfig != nil && version == edge.EdgeStackEndpointVersion(stack, endpoint.ID) {
+		commitHash = stack.GitConfig.CommitHash
+	}
+
 	stack.Status[*payload.EndpointID] = portainer.EdgeStackStatus{
 		Type:       *payload.Status,
 		Error:      payload.Error,
 		EndpointID: *payload.EndpointID,
+		Version:    version,
+		CommitHash: commitHash,
 	}
 
+	edge.RecordEdgeStackDeployment(stack, endpoint.ID, portainer.EdgeStackDeployment{
+		Version:    version,
+		CommitHash: commitHash,
+		Type:       *payload.Status,
+		Error:      payload.Error,
+		Output:     payload.Output,
+		Services:   payload.Services,
+	}, time.Now().Unix())
+
 	err = handler.DataStore.EdgeStack().UpdateEdgeStack(stack.ID, stack)
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the stack changes inside the database", err}
 	}
 
+	hideSecretEnv(stack)
 	return response.JSON(w, stack)
 
 }
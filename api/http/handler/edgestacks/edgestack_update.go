@@ -3,6 +3,7 @@ package edgestacks
 import (
 	"errors"
 	"net/http"
+	"reflect"
 	"strconv"
 
 	"github.com/asaskevich/govalidator"
@@ -19,6 +20,11 @@ type updateEdgeStackPayload struct {
 	Version          *int
 	Prune            *bool
 	EdgeGroups       []portainer.EdgeGroupID
+	Env              []portainer.EdgeStackEnvVar
+	TagEnv           []portainer.EdgeStackTagEnvVar
+	// ClearSecretEnv lists the secret variables whose empty values are applied, the secret values
+	// sent empty are kept otherwise as they are never returned by the API
+	ClearSecretEnv []string
 }
 
 func (payload *updateEdgeStackPayload) Validate(r *http.Request) error {
@@ -28,7 +34,8 @@ func (payload *updateEdgeStackPayload) Validate(r *http.Request) error {
 	if payload.EdgeGroups != nil && len(payload.EdgeGroups) == 0 {
 		return errors.New("Edge Groups are mandatory for an Edge stack")
 	}
-	return nil
+	// the tag environment variables are validated against the resulting environment variables of the stack
+	return validateEdgeStackEnv(payload.Env, nil)
 }
 
 func (handler *Handler) edgeStackUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -129,6 +136,37 @@ func (handler *Handler) edgeStackUpdate(w http.ResponseWriter, r *http.Request)
 		stack.Prune = *payload.Prune
 	}
 
+	envChanged := false
+	if payload.Env != nil || payload.TagEnv != nil {
+		env, tagEnv := stack.Env, stack.TagEnv
+		if payload.Env != nil {
+			env = payload.Env
+		}
+		if payload.TagEnv != nil {
+			tagEnv = payload.TagEnv
+		}
+
+		err = validateEdgeStackEnv(env, tagEnv)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Invalid environment variables", err}
+		}
+
+		keepSecretValues(stack, env, tagEnv, payload.ClearSecretEnv)
+		envChanged = !reflect.DeepEqual(env, stack.Env) || !reflect.DeepEqual(tagEnv, stack.TagEnv)
+		stack.Env = env
+		stack.TagEnv = tagEnv
+
+		err = edge.SealEdgeStackEnv(stack, handler.SecretService)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to encrypt the secret environment variables", err}
+		}
+	}
+
+	if envChanged && (payload.Version == nil || *payload.Version == stack.Version) {
+		version := stack.Version + 1
+		payload.Version = &version
+	}
+
 	stackFolder := strconv.Itoa(int(stack.ID))
 	_, err = handler.FileService.StoreEdgeStackFileFromBytes(stackFolder, stack.EntryPoint, []byte(payload.StackFileContent))
 	if err != nil {
@@ -145,9 +183,59 @@ func (handler *Handler) edgeStackUpdate(w http.ResponseWriter, r *http.Request)
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the stack changes inside the database", err}
 	}
 
+	if envChanged {
+		err = edge.SealEndpointsMetadata(handler.DataStore, handler.SecretService)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to encrypt the secret metadata of the endpoints", err}
+		}
+	}
+
+	hideSecretEnv(stack)
 	return response.JSON(w, stack)
 }
 
+// keepSecretValues restores the previous values of the secret variables of an Edge stack sent without a value,
+// as secret values are never returned by the API. The default and tag values of the variables listed in
+// clearSecretEnv are left empty.
+func keepSecretValues(previousStack *portainer.EdgeStack, env []portainer.EdgeStackEnvVar, tagEnv []portainer.EdgeStackTagEnvVar, clearSecretEnv []string) {
+	cleared := map[string]bool{}
+	for _, name := range clearSecretEnv {
+		cleared[name] = true
+	}
+
+	previousSecretNames := edge.SecretEnvNames([]portainer.EdgeStack{*previousStack})
+	secretNames := edge.SecretEnvNames([]portainer.EdgeStack{{Env: env}})
+
+	keep := func(name string) bool {
+		return secretNames[name] && previousSecretNames[name] && !cleared[name]
+	}
+
+	previousValues := map[string]string{}
+	for _, envVar := range previousStack.Env {
+		previousValues[envVar.Name] = envVar.Value
+	}
+
+	for idx := range env {
+		if env[idx].Value == "" && keep(env[idx].Name) {
+			env[idx].Value = previousValues[env[idx].Name]
+		}
+	}
+
+	previousTagValues := map[portainer.TagID]map[string]string{}
+	for _, tagEnvVar := range previousStack.TagEnv {
+		if previousTagValues[tagEnvVar.TagID] == nil {
+			previousTagValues[tagEnvVar.TagID] = map[string]string{}
+		}
+		previousTagValues[tagEnvVar.TagID][tagEnvVar.Name] = tagEnvVar.Value
+	}
+
+	for idx := range tagEnv {
+		if tagEnv[idx].Value == "" && keep(tagEnv[idx].Name) {
+			tagEnv[idx].Value = previousTagValues[tagEnv[idx].TagID][tagEnv[idx].Name]
+		}
+	}
+}
+
 func EndpointSet(endpointIDs []portainer.EndpointID) map[portainer.EndpointID]bool {
 	set := map[portainer.EndpointID]bool{}
 
@@ -0,0 +1,52 @@
+package edgestacks
+
+import (
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_keepSecretValues(t *testing.T) {
+	previousStack := &portainer.EdgeStack{
+		Env: []portainer.EdgeStackEnvVar{
+			{Name: "API_TOKEN", Value: "encrypted:token", Secret: true},
+			{Name: "DB_PASSWORD", Value: "encrypted:password", Secret: true},
+			{Name: "REGION", Value: "eu"},
+		},
+		TagEnv: []portainer.EdgeStackTagEnvVar{
+			{TagID: 1, Name: "API_TOKEN", Value: "encrypted:tag-token"},
+			{TagID: 2, Name: "DB_PASSWORD", Value: "encrypted:tag-password"},
+		},
+	}
+
+	env := []portainer.EdgeStackEnvVar{
+		{Name: "API_TOKEN", Value: "", Secret: true},
+		{Name: "DB_PASSWORD", Value: "", Secret: true},
+		{Name: "REGION", Value: ""},
+	}
+	tagEnv := []portainer.EdgeStackTagEnvVar{
+		{TagID: 1, Name: "API_TOKEN", Value: ""},
+		{TagID: 2, Name: "DB_PASSWORD", Value: ""},
+	}
+
+	keepSecretValues(previousStack, env, tagEnv, []string{"DB_PASSWORD"})
+
+	assert.Equal(t, "encrypted:token", env[0].Value)
+	assert.Equal(t, "", env[1].Value, "a cleared secret must not be restored")
+	assert.Equal(t, "", env[2].Value, "a variable that is not secret must not be restored")
+	assert.Equal(t, "encrypted:tag-token", tagEnv[0].Value)
+	assert.Equal(t, "", tagEnv[1].Value, "a cleared secret must not be restored")
+}
+
+func Test_keepSecretValues_NewSecret(t *testing.T) {
+	previousStack := &portainer.EdgeStack{
+		Env: []portainer.EdgeStackEnvVar{{Name: "API_TOKEN", Value: "plain-value"}},
+	}
+
+	env := []portainer.EdgeStackEnvVar{{Name: "API_TOKEN", Value: "", Secret: true}}
+
+	keepSecretValues(previousStack, env, nil, nil)
+
+	assert.Equal(t, "", env[0].Value, "a variable that was not secret must not be restored")
+}
@@ -1,21 +1,105 @@
 package edgestacks
 
 import (
+	"errors"
 	"net/http"
+	"regexp"
 
 	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/docker"
+	"github.com/cloudogu/portainer-ce/api/filesystem"
 	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	"github.com/gorilla/mux"
 	httperror "github.com/portainer/libhttp/error"
 )
 
+func hideSecretEnv(edgeStack *portainer.EdgeStack) {
+	for idx := range edgeStack.Env {
+		if edgeStack.Env[idx].Secret {
+			edgeStack.Env[idx].Value = ""
+		}
+	}
+
+	secretNames := edge.SecretEnvNames([]portainer.EdgeStack{*edgeStack})
+	for idx := range edgeStack.TagEnv {
+		if secretNames[edgeStack.TagEnv[idx].Name] {
+			edgeStack.TagEnv[idx].Value = ""
+		}
+	}
+
+	if edgeStack.GitConfig != nil {
+		edgeStack.GitConfig.Password = ""
+	}
+}
+
+func validateEdgeStackEnv(env []portainer.EdgeStackEnvVar, tagEnv []portainer.EdgeStackTagEnvVar) error {
+	names := map[string]bool{}
+	for _, envVar := range env {
+		if envVar.Name == "" {
+			return errors.New("Invalid environment variable name")
+		}
+		if names[envVar.Name] {
+			return errors.New("Environment variable names must be unique")
+		}
+		err := edge.ValidateEnvValue(envVar.Value)
+		if err != nil {
+			return err
+		}
+		names[envVar.Name] = true
+	}
+
+	for _, tagEnvVar := range tagEnv {
+		if !names[tagEnvVar.Name] {
+			return errors.New("Tag environment variables must override an environment variable of the stack")
+		}
+		err := edge.ValidateEnvValue(tagEnvVar.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var kubernetesNamespaceFormat = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+func validateEdgeStackDeployment(deploymentType portainer.EdgeStackDeploymentType, namespace *string) error {
+	switch deploymentType {
+	case portainer.EdgeStackDeploymentCompose:
+		*namespace = ""
+	case portainer.EdgeStackDeploymentKubernetes:
+		if *namespace == "" {
+			*namespace = "default"
+		}
+		if len(*namespace) > 63 || !kubernetesNamespaceFormat.MatchString(*namespace) {
+			return errors.New("Invalid namespace. Must be a valid Kubernetes namespace name")
+		}
+	default:
+		return errors.New("Invalid deployment type. Value must be one of: 0 (Compose) or 1 (Kubernetes)")
+	}
+	return nil
+}
+
+func edgeStackDefaultEntryPoint(deploymentType portainer.EdgeStackDeploymentType) string {
+	if deploymentType == portainer.EdgeStackDeploymentKubernetes {
+		return filesystem.ManifestFileDefaultName
+	}
+	return filesystem.ComposeFileDefaultName
+}
+
 // Handler is the HTTP handler used to handle endpoint group operations.
 type Handler struct {
 	*mux.Router
-	requestBouncer *security.RequestBouncer
-	DataStore      portainer.DataStore
-	FileService    portainer.FileService
-	GitService     portainer.GitService
+	requestBouncer       *security.RequestBouncer
+	DataStore            portainer.DataStore
+	FileService          portainer.FileService
+	GitService           portainer.GitService
+	GitSyncService       portainer.EdgeStackGitSyncService
+	DockerClientFactory  *docker.ClientFactory
+	ReverseTunnelService portainer.ReverseTunnelService
+	SignatureService     portainer.DigitalSignatureService
+	SecretService        portainer.SecretService
+	ImageTrustService    portainer.ImageTrustService
 }
 
 // NewHandler creates a handler to manage endpoint group operations.
@@ -34,8 +118,18 @@ func NewHandler(bouncer *security.RequestBouncer) *Handler {
 		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.edgeStackUpdate)))).Methods(http.MethodPut)
 	h.Handle("/edge_stacks/{id}",
 		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.edgeStackDelete)))).Methods(http.MethodDelete)
+	h.Handle("/edge_stacks/{id}/bundle",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.edgeStackBundle)))).Methods(http.MethodGet)
 	h.Handle("/edge_stacks/{id}/file",
 		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.edgeStackFile)))).Methods(http.MethodGet)
+	h.Handle("/edge_stacks/{id}/git",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.edgeStackGitUpdate)))).Methods(http.MethodPut)
+	h.Handle("/edge_stacks/{id}/git/sync",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.edgeStackGitSync)))).Methods(http.MethodPost)
+	h.Handle("/edge_stacks/{id}/retry",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.edgeStackRetry)))).Methods(http.MethodPost)
+	h.Handle("/edge_stacks/{id}/endpoints/{endpointId}",
+		bouncer.AdminAccess(bouncer.EdgeComputeOperation(httperror.LoggerHandler(h.edgeStackEndpointInspect)))).Methods(http.MethodGet)
 	h.Handle("/edge_stacks/{id}/status",
 		bouncer.PublicAccess(httperror.LoggerHandler(h.edgeStackStatusUpdate))).Methods(http.MethodPut)
 	return h
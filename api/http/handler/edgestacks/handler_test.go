@@ -0,0 +1,88 @@
+package edgestacks
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateEdgeStackDeployment(t *testing.T) {
+	tests := []struct {
+		name              string
+		deploymentType    portainer.EdgeStackDeploymentType
+		namespace         string
+		expectedNamespace string
+		expectError       bool
+	}{
+		{name: "compose ignores the namespace", deploymentType: portainer.EdgeStackDeploymentCompose, namespace: "apps", expectedNamespace: ""},
+		{name: "kubernetes defaults the namespace", deploymentType: portainer.EdgeStackDeploymentKubernetes, namespace: "", expectedNamespace: "default"},
+		{name: "kubernetes keeps a valid namespace", deploymentType: portainer.EdgeStackDeploymentKubernetes, namespace: "edge-apps", expectedNamespace: "edge-apps"},
+		{name: "kubernetes rejects an invalid namespace", deploymentType: portainer.EdgeStackDeploymentKubernetes, namespace: "Edge_Apps", expectError: true},
+		{name: "kubernetes rejects a namespace that is too long", deploymentType: portainer.EdgeStackDeploymentKubernetes, namespace: strings.Repeat("a", 64), expectError: true},
+		{name: "unknown deployment type", deploymentType: portainer.EdgeStackDeploymentType(2), expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			namespace := test.namespace
+			err := validateEdgeStackDeployment(test.deploymentType, &namespace)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedNamespace, namespace)
+		})
+	}
+}
+
+func Test_validateEdgeStackEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         []portainer.EdgeStackEnvVar
+		tagEnv      []portainer.EdgeStackTagEnvVar
+		expectError bool
+	}{
+		{name: "valid variables", env: []portainer.EdgeStackEnvVar{{Name: "API_TOKEN", Value: "token", Secret: true}}, tagEnv: []portainer.EdgeStackTagEnvVar{{TagID: 1, Name: "API_TOKEN", Value: "tag-token"}}},
+		{name: "duplicated name", env: []portainer.EdgeStackEnvVar{{Name: "REGION"}, {Name: "REGION"}}, expectError: true},
+		{name: "unknown tag variable", env: []portainer.EdgeStackEnvVar{{Name: "REGION"}}, tagEnv: []portainer.EdgeStackTagEnvVar{{TagID: 1, Name: "ZONE"}}, expectError: true},
+		{name: "encrypted prefix in a value", env: []portainer.EdgeStackEnvVar{{Name: "API_TOKEN", Value: "encrypted:token", Secret: true}}, expectError: true},
+		{name: "encrypted prefix in a tag value", env: []portainer.EdgeStackEnvVar{{Name: "REGION"}}, tagEnv: []portainer.EdgeStackTagEnvVar{{TagID: 1, Name: "REGION", Value: "encrypted:eu"}}, expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateEdgeStackEnv(test.env, test.tagEnv)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_swarmStackFromFileUploadPayload_InvalidDeploymentType(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	assert.NoError(t, writer.WriteField("Name", "stack"))
+	assert.NoError(t, writer.WriteField("EdgeGroups", "[1]"))
+	assert.NoError(t, writer.WriteField("DeploymentType", "kubernetes"))
+	file, err := writer.CreateFormFile("file", "docker-compose.yml")
+	assert.NoError(t, err)
+	_, err = file.Write([]byte("version: '3'"))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/edge_stacks?method=file", body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	payload := &swarmStackFromFileUploadPayload{}
+	assert.EqualError(t, payload.Validate(r), "Invalid deployment type")
+}
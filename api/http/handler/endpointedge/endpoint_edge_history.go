@@ -0,0 +1,73 @@
+package endpointedge
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type edgeHistoryResponse struct {
+	portainer.EdgeCheckin
+	Status          portainer.EndpointStatus `json:"Status"`
+	CheckinInterval int                      `json:"CheckinInterval"`
+}
+
+// GET request on /api/endpoints/:id/edge/history
+// Returns the latest check-in of the Edge agent, the status derived from it and the history of the reverse tunnel.
+func (handler *Handler) endpointEdgeHistory(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid endpoint identifier route variable", err}
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
+	}
+
+	err = handler.requestBouncer.AuthorizedEndpointOperation(r, endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusForbidden, "Permission denied to access endpoint", err}
+	}
+
+	if endpoint.Type != portainer.EdgeAgentOnDockerEnvironment && endpoint.Type != portainer.EdgeAgentOnKubernetesEnvironment {
+		return &httperror.HandlerError{http.StatusBadRequest, "Check-in history is only available for Edge endpoints", errors.New("Invalid endpoint type")}
+	}
+
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve settings from the database", err}
+	}
+
+	checkin, err := handler.DataStore.EdgeCheckin().EdgeCheckin(endpoint.ID)
+	if err == bolterrors.ErrObjectNotFound {
+		checkin = &portainer.EdgeCheckin{
+			EndpointID:   endpoint.ID,
+			TunnelEvents: []portainer.EdgeTunnelEvent{},
+		}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve Edge endpoint check-in state from the database", err}
+	}
+
+	// check-ins are only persisted periodically, the latest one is kept by the tunnel service
+	if recentCheckin := handler.ReverseTunnelService.LastCheckin(endpoint.ID); recentCheckin > checkin.LastCheckin {
+		checkin.LastCheckin = recentCheckin
+	}
+
+	checkinInterval := edge.EndpointCheckinInterval(endpoint, settings)
+
+	return response.JSON(w, &edgeHistoryResponse{
+		EdgeCheckin:     *checkin,
+		Status:          edge.CheckinStatus(checkin.LastCheckin, checkinInterval, time.Now()),
+		CheckinInterval: checkinInterval,
+	})
+}
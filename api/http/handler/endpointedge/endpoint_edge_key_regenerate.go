@@ -0,0 +1,97 @@
+package endpointedge
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/asaskevich/govalidator"
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type edgeKeyRegeneratePayload struct {
+	PortainerURL string
+}
+
+func (payload *edgeKeyRegeneratePayload) Validate(r *http.Request) error {
+	if payload.PortainerURL != "" && !govalidator.IsURL(payload.PortainerURL) {
+		return errors.New("Invalid Portainer URL")
+	}
+	return nil
+}
+
+type edgeKeyRegenerateResponse struct {
+	EdgeKey string `json:"EdgeKey"`
+}
+
+// POST request on /api/endpoints/:id/edge/key
+// Generates a new Edge key for the endpoint, using the current tunnel server key. The Edge identifier
+// of the agent registered with the previous key is revoked and its reverse tunnel is closed, an agent
+// started with the new key and a new Edge identifier must then check in to manage the endpoint.
+func (handler *Handler) endpointEdgeKeyRegenerate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid endpoint identifier route variable", err}
+	}
+
+	var payload edgeKeyRegeneratePayload
+	if r.ContentLength != 0 {
+		err = request.DecodeAndValidateJSONPayload(r, &payload)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+		}
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
+	}
+
+	if endpoint.Type != portainer.EdgeAgentOnDockerEnvironment && endpoint.Type != portainer.EdgeAgentOnKubernetesEnvironment {
+		return &httperror.HandlerError{http.StatusBadRequest, "Edge keys are only available for Edge endpoints", errors.New("Invalid endpoint type")}
+	}
+
+	portainerURL := payload.PortainerURL
+	portainerHost := endpoint.URL
+	if portainerURL == "" {
+		portainerURL, err = edge.EdgeKeyPortainerURL(endpoint.EdgeKey)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Unable to retrieve the Portainer URL from the current Edge key, a Portainer URL must be specified", err}
+		}
+	} else {
+		parsedURL, err := url.Parse(portainerURL)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Invalid Portainer URL", err}
+		}
+
+		portainerHost, _, err = net.SplitHostPort(parsedURL.Host)
+		if err != nil {
+			portainerHost = parsedURL.Host
+		}
+	}
+
+	if endpoint.EdgeID != "" {
+		endpoint.RevokedEdgeIDs = append(endpoint.RevokedEdgeIDs, endpoint.EdgeID)
+		endpoint.EdgeID = ""
+	}
+
+	endpoint.URL = portainerHost
+	endpoint.EdgeKey = handler.ReverseTunnelService.GenerateEdgeKey(portainerURL, portainerHost, int(endpoint.ID))
+
+	err = handler.DataStore.Endpoint().UpdateEndpoint(endpoint.ID, endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist endpoint changes inside the database", err}
+	}
+
+	handler.ReverseTunnelService.SetTunnelStatusToIdle(endpoint.ID)
+
+	return response.JSON(w, &edgeKeyRegenerateResponse{EdgeKey: endpoint.EdgeKey})
+}
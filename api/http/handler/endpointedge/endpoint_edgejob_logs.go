@@ -6,6 +6,7 @@ import (
 
 	"github.com/cloudogu/portainer-ce/api"
 	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -49,30 +50,35 @@ func (handler *Handler) endpointEdgeJobsLogs(w http.ResponseWriter, r *http.Requ
 		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
 	}
 
-	edgeJob, err := handler.DataStore.EdgeJob().EdgeJob(portainer.EdgeJobID(edgeJobID))
+	edgeJob, err := edge.UpdateEdgeJob(handler.DataStore, portainer.EdgeJobID(edgeJobID), func(edgeJob *portainer.EdgeJob) error {
+		err := handler.FileService.StoreEdgeJobTaskLogFileFromBytes(strconv.Itoa(edgeJobID), strconv.Itoa(endpointID), []byte(payload.FileContent))
+		if err != nil {
+			return err
+		}
+
+		meta := edgeJob.Endpoints[endpoint.ID]
+		meta.CollectLogs = false
+		meta.LogsStatus = portainer.EdgeJobLogsStatusCollected
+
+		if len(meta.Runs) > 0 && meta.Runs[len(meta.Runs)-1].LogsStatus == portainer.EdgeJobLogsStatusPending {
+			lastRun := &meta.Runs[len(meta.Runs)-1]
+
+			err = handler.FileService.StoreEdgeJobTaskLogFileFromBytes(strconv.Itoa(edgeJobID), edge.EdgeJobRunLogsTaskID(endpoint.ID, lastRun.ID), []byte(payload.FileContent))
+			if err != nil {
+				return err
+			}
+			lastRun.LogsStatus = portainer.EdgeJobLogsStatusCollected
+		}
+		edgeJob.Endpoints[endpoint.ID] = meta
+		return nil
+	})
 	if err == bolterrors.ErrObjectNotFound {
 		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an edge job with the specified identifier inside the database", err}
 	} else if err != nil {
-		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an edge job with the specified identifier inside the database", err}
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to save edge job logs", err}
 	}
 
-	err = handler.FileService.StoreEdgeJobTaskLogFileFromBytes(strconv.Itoa(edgeJobID), strconv.Itoa(endpointID), []byte(payload.FileContent))
-	if err != nil {
-		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to save task log to the filesystem", err}
-	}
-
-	meta := edgeJob.Endpoints[endpoint.ID]
-	meta.CollectLogs = false
-	meta.LogsStatus = portainer.EdgeJobLogsStatusCollected
-	edgeJob.Endpoints[endpoint.ID] = meta
-
-	err = handler.DataStore.EdgeJob().UpdateEdgeJob(edgeJob.ID, edgeJob)
-
 	handler.ReverseTunnelService.AddEdgeJob(endpoint.ID, edgeJob)
 
-	if err != nil {
-		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist edge job changes to the database", err}
-	}
-
 	return response.JSON(w, nil)
 }
@@ -0,0 +1,116 @@
+package endpointedge
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+var errEdgeJobNotAssociated = errors.New("Edge job not found for endpoint")
+
+type edgeJobRunPayload struct {
+	ExitCode   int
+	StartedAt  int64
+	FinishedAt int64
+	TimedOut   bool
+	Logs       string
+}
+
+func (payload *edgeJobRunPayload) Validate(r *http.Request) error {
+	if payload.StartedAt <= 0 {
+		return errors.New("Invalid run start time")
+	}
+
+	if payload.FinishedAt < payload.StartedAt {
+		return errors.New("Invalid run end time. Must be greater than or equal to the start time")
+	}
+	return nil
+}
+
+// POST request on api/endpoints/:id/edge/jobs/:jobID/runs
+// Records the result of an Edge job run on the endpoint. When the run failed and the job
+// collects logs on failure, the logs sent with the result are stored, or requested from the agent
+// on its next check-in if they are missing.
+func (handler *Handler) endpointEdgeJobRun(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid endpoint identifier route variable", err}
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
+	}
+
+	err = handler.requestBouncer.AuthorizedEdgeEndpointOperation(r, endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusForbidden, "Permission denied to access endpoint", err}
+	}
+
+	edgeJobID, err := request.RetrieveNumericRouteVariableValue(r, "jobID")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid edge job identifier route variable", err}
+	}
+
+	var payload edgeJobRunPayload
+	err = request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	var run *portainer.EdgeJobRun
+	edgeJob, err := edge.UpdateEdgeJob(handler.DataStore, portainer.EdgeJobID(edgeJobID), func(edgeJob *portainer.EdgeJob) error {
+		meta, ok := edgeJob.Endpoints[endpoint.ID]
+		if !ok {
+			return errEdgeJobNotAssociated
+		}
+
+		run = &portainer.EdgeJobRun{
+			Status:     edge.EdgeJobRunStatus(payload.ExitCode, payload.TimedOut),
+			ExitCode:   payload.ExitCode,
+			StartedAt:  payload.StartedAt,
+			FinishedAt: payload.FinishedAt,
+			Duration:   payload.FinishedAt - payload.StartedAt,
+			LogsStatus: portainer.EdgeJobLogsStatusIdle,
+		}
+		edge.AddEdgeJobRun(&meta, run)
+
+		if run.Status != portainer.EdgeJobRunSucceeded && edgeJob.CollectLogsOnFailure {
+			if payload.Logs != "" {
+				err := handler.FileService.StoreEdgeJobTaskLogFileFromBytes(strconv.Itoa(edgeJobID), edge.EdgeJobRunLogsTaskID(endpoint.ID, run.ID), []byte(payload.Logs))
+				if err != nil {
+					return err
+				}
+				run.LogsStatus = portainer.EdgeJobLogsStatusCollected
+			} else {
+				run.LogsStatus = portainer.EdgeJobLogsStatusPending
+				meta.CollectLogs = true
+				meta.LogsStatus = portainer.EdgeJobLogsStatusPending
+			}
+			meta.Runs[len(meta.Runs)-1].LogsStatus = run.LogsStatus
+		}
+
+		edgeJob.Endpoints[endpoint.ID] = meta
+		return nil
+	})
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an edge job with the specified identifier inside the database", err}
+	} else if err == errEdgeJobNotAssociated {
+		return &httperror.HandlerError{http.StatusNotFound, "The edge job is not associated to the endpoint", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist edge job run", err}
+	}
+
+	handler.ReverseTunnelService.AddEdgeJob(endpoint.ID, edgeJob)
+
+	return response.JSON(w, run)
+}
@@ -1,11 +1,14 @@
 package endpointedge
 
 import (
+	"errors"
 	"net/http"
 	"path"
 
 	portainer "github.com/cloudogu/portainer-ce/api"
-	"github.com/cloudogu/portainer-ce/api/bolt/errors"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	"github.com/cloudogu/portainer-ce/api/internal/imagetrust"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -15,9 +18,18 @@ type configResponse struct {
 	Prune            bool
 	StackFileContent string
 	Name             string
+	Env              []portainer.Pair
+	SecretEnvNames   []string
+	DeploymentType   portainer.EdgeStackDeploymentType
+	Namespace        string
 }
 
 // GET request on api/endpoints/:id/edge/stacks/:stackId
+// Returns the configuration of the Edge stack with the environment variables resolved for the endpoint.
+// StackFileContent contains a Compose file for Docker endpoints and a manifest for Kubernetes endpoints.
+// Only the names of the secret variables are returned, their values are sent to the agent through the reverse tunnel.
+// Compose files are returned with the images verified by the image trust policy pinned by digest, the images
+// are verified once for each version of the stack.
 func (handler *Handler) endpointEdgeStackInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
 	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
 	if err != nil {
@@ -25,7 +37,7 @@ func (handler *Handler) endpointEdgeStackInspect(w http.ResponseWriter, r *http.
 	}
 
 	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
-	if err == errors.ErrObjectNotFound {
+	if err == bolterrors.ErrObjectNotFound {
 		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
 	} else if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
@@ -42,20 +54,64 @@ func (handler *Handler) endpointEdgeStackInspect(w http.ResponseWriter, r *http.
 	}
 
 	edgeStack, err := handler.DataStore.EdgeStack().EdgeStack(portainer.EdgeStackID(edgeStackID))
-	if err == errors.ErrObjectNotFound {
+	if err == bolterrors.ErrObjectNotFound {
 		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an edge stack with the specified identifier inside the database", err}
 	} else if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an edge stack with the specified identifier inside the database", err}
 	}
 
+	if !edge.EdgeStackDeployableOnEndpoint(edgeStack, endpoint.Type) {
+		return &httperror.HandlerError{http.StatusBadRequest, "The deployment type of the edge stack is not supported by the endpoint", errors.New("Unsupported deployment type")}
+	}
+
 	stackFileContent, err := handler.FileService.GetFileContent(path.Join(edgeStack.ProjectPath, edgeStack.EntryPoint))
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve Compose file from disk", err}
 	}
 
+	endpointGroup, err := handler.DataStore.EndpointGroup().EndpointGroup(endpoint.GroupID)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find the endpoint group inside the database", err}
+	}
+
+	env, secretEnv := edge.ResolveEdgeStackEnv(edgeStack, endpoint, endpointGroup)
+
+	env, err = edge.DecryptEnv(env, handler.SecretService)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to decrypt the environment variables", err}
+	}
+
+	secretEnv, err = edge.DecryptEnv(secretEnv, handler.SecretService)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to decrypt the secret environment variables", err}
+	}
+
+	if edgeStack.DeploymentType == portainer.EdgeStackDeploymentCompose {
+		interpolationEnv := append(append([]portainer.Pair{}, env...), secretEnv...)
+		stackFileContent, err = handler.trustedEdgeStackFile(edgeStack, endpoint, endpointGroup, stackFileContent, interpolationEnv)
+		if _, ok := err.(*imagetrust.UntrustedImagesError); ok {
+			return &httperror.HandlerError{http.StatusForbidden, "Unable to verify the signature of the edge stack images", err}
+		} else if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to verify the signature of the edge stack images", err}
+		}
+	}
+
+	secretEnvNames := make([]string, 0, len(secretEnv))
+	for _, pair := range secretEnv {
+		secretEnvNames = append(secretEnvNames, pair.Name)
+	}
+
+	if len(secretEnv) > 0 {
+		go handler.SecretEnvSender.SendEdgeStackSecretEnv(endpoint.ID, edgeStack.ID, edge.EdgeStackEndpointVersion(edgeStack, endpoint.ID), secretEnv)
+	}
+
 	return response.JSON(w, configResponse{
 		Prune:            edgeStack.Prune,
 		StackFileContent: string(stackFileContent),
 		Name:             edgeStack.Name,
+		Env:              env,
+		SecretEnvNames:   secretEnvNames,
+		DeploymentType:   edgeStack.DeploymentType,
+		Namespace:        edgeStack.Namespace,
 	})
 }
@@ -0,0 +1,132 @@
+package endpointedge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/crypto"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type testFileService struct {
+	portainer.FileService
+	content []byte
+}
+
+func (service testFileService) GetFileContent(filePath string) ([]byte, error) {
+	return service.content, nil
+}
+
+type testImageTrustService struct {
+	portainer.ImageTrustService
+}
+
+func (service testImageTrustService) TrustedComposeFile(content []byte, env []portainer.Pair, endpoint *portainer.Endpoint) ([]byte, error) {
+	return content, nil
+}
+
+type testReverseTunnelService struct {
+	portainer.ReverseTunnelService
+	port int
+}
+
+func (service testReverseTunnelService) GetTunnelDetails(endpointID portainer.EndpointID) *portainer.TunnelDetails {
+	return &portainer.TunnelDetails{Status: portainer.EdgeAgentActive, Port: service.port}
+}
+
+func Test_endpointEdgeStackInspect_SendsSecretsThroughTunnel(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	secretService := crypto.NewSecretService()
+	key, err := secretService.GenerateKey()
+	assert.NoError(t, err)
+	assert.NoError(t, secretService.ParseKey(key))
+
+	encryptedToken, err := secretService.Encrypt("secret-token")
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.EndpointGroup().CreateEndpointGroup(&portainer.EndpointGroup{Name: "group"}))
+	assert.NoError(t, store.Endpoint().CreateEndpoint(&portainer.Endpoint{
+		ID:      1,
+		GroupID: 1,
+		Type:    portainer.EdgeAgentOnDockerEnvironment,
+		EdgeID:  "edge-id",
+	}))
+	assert.NoError(t, store.EdgeStack().CreateEdgeStack(&portainer.EdgeStack{
+		ID:             1,
+		Name:           "stack",
+		Version:        3,
+		DeploymentType: portainer.EdgeStackDeploymentCompose,
+		Env: []portainer.EdgeStackEnvVar{
+			{Name: "REGION", Value: "eu"},
+			{Name: "API_TOKEN", Value: encryptedToken, Secret: true},
+		},
+	}))
+
+	type secretEnvPayload struct {
+		Version   int
+		SecretEnv []portainer.Pair
+	}
+
+	delivered := make(chan secretEnvPayload, 1)
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/edge/stacks/1/secrets", r.URL.Path)
+
+		var payload secretEnvPayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		delivered <- payload
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer agent.Close()
+
+	agentURL, err := url.Parse(agent.URL)
+	assert.NoError(t, err)
+	agentPort, err := strconv.Atoi(agentURL.Port())
+	assert.NoError(t, err)
+
+	reverseTunnelService := testReverseTunnelService{port: agentPort}
+	handler := &Handler{
+		requestBouncer:       security.NewRequestBouncer(store, nil),
+		DataStore:            store,
+		FileService:          testFileService{content: []byte("version: '3'")},
+		ReverseTunnelService: reverseTunnelService,
+		ImageTrustService:    testImageTrustService{},
+		SecretService:        secretService,
+		SecretEnvSender:      edge.NewSecretEnvSender(store, reverseTunnelService),
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/endpoints/1/edge/stacks/1", nil)
+	r.Header.Set(portainer.PortainerAgentEdgeIDHeader, "edge-id")
+	r = mux.SetURLVars(r, map[string]string{"id": "1", "stackId": "1"})
+	w := httptest.NewRecorder()
+
+	handlerErr := handler.endpointEdgeStackInspect(w, r)
+	assert.Nil(t, handlerErr)
+	assert.NotContains(t, w.Body.String(), "secret-token")
+	assert.NotContains(t, w.Body.String(), encryptedToken)
+
+	var resp configResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, []portainer.Pair{{Name: "REGION", Value: "eu"}}, resp.Env)
+	assert.Equal(t, []string{"API_TOKEN"}, resp.SecretEnvNames)
+
+	select {
+	case payload := <-delivered:
+		assert.Equal(t, 3, payload.Version)
+		assert.Equal(t, []portainer.Pair{{Name: "API_TOKEN", Value: "secret-token"}}, payload.SecretEnv)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the secret environment variables were not sent through the tunnel")
+	}
+}
@@ -0,0 +1,164 @@
+package endpointedge
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	"github.com/cloudogu/portainer-ce/api/internal/imagetrust"
+)
+
+// trustedStackFile is the result of the verification of the images of an Edge stack version for an endpoint.
+// done is closed once the verification is completed.
+type trustedStackFile struct {
+	edgeStackID portainer.EdgeStackID
+	endpointID  portainer.EndpointID
+	version     int
+	source      [sha256.Size]byte
+	done        chan struct{}
+	content     []byte
+	err         error
+}
+
+// trustedEdgeStackFile verifies the images of the Compose file of an Edge stack version for an endpoint and pins
+// them by digest. The result is kept for the version so that the registries are only queried once, and not each
+// time the agent retrieves the stack. It is verified again when the image trust policy of the endpoint group or
+// the trusted keys change. A rejection by the enforce policy is recorded in the status of the stack.
+func (handler *Handler) trustedEdgeStackFile(edgeStack *portainer.EdgeStack, endpoint *portainer.Endpoint, endpointGroup *portainer.EndpointGroup, content []byte, env []portainer.Pair) ([]byte, error) {
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%d_%d", endpoint.ID, edgeStack.ID)
+	version := edge.EdgeStackEndpointVersion(edgeStack, endpoint.ID)
+	source := stackFileSource(content, env, endpointGroup.ImageTrustPolicy, settings.ImageTrustKeys)
+
+	handler.trustedStackFilesMutex.Lock()
+	if handler.trustedStackFiles == nil {
+		handler.trustedStackFiles = make(map[string]*trustedStackFile)
+	}
+
+	stackFile, ok := handler.trustedStackFiles[key]
+	if ok && stackFile.version == version && stackFile.source == source {
+		handler.trustedStackFilesMutex.Unlock()
+		<-stackFile.done
+		return stackFile.content, stackFile.err
+	}
+
+	stackFile = &trustedStackFile{edgeStackID: edgeStack.ID, endpointID: endpoint.ID, version: version, source: source, done: make(chan struct{})}
+	handler.trustedStackFiles[key] = stackFile
+	handler.trustedStackFilesMutex.Unlock()
+
+	handler.pruneTrustedStackFiles()
+
+	stackFile.content, stackFile.err = handler.ImageTrustService.TrustedComposeFile(content, env, endpoint)
+	close(stackFile.done)
+
+	if _, ok := stackFile.err.(*imagetrust.UntrustedImagesError); ok {
+		handler.recordUntrustedEdgeStack(edgeStack.ID, endpoint.ID, version, stackFile.err)
+	} else if stackFile.err != nil {
+		// the verification is attempted again on the next retrieval when the registries could not be queried
+		handler.trustedStackFilesMutex.Lock()
+		if handler.trustedStackFiles[key] == stackFile {
+			delete(handler.trustedStackFiles, key)
+		}
+		handler.trustedStackFilesMutex.Unlock()
+	}
+
+	return stackFile.content, stackFile.err
+}
+
+// pruneTrustedStackFiles removes the verifications of the Edge stack versions that are not expected anymore
+// on their endpoint, and of the deleted Edge stacks and endpoints. It runs when a version is verified.
+func (handler *Handler) pruneTrustedStackFiles() {
+	edgeStacks, err := handler.DataStore.EdgeStack().EdgeStacks()
+	if err != nil {
+		log.Printf("[WARN] [endpointedge,imagetrust] [message: unable to retrieve edge stacks from the database] [err: %s]", err)
+		return
+	}
+
+	endpoints, err := handler.DataStore.Endpoint().Endpoints()
+	if err != nil {
+		log.Printf("[WARN] [endpointedge,imagetrust] [message: unable to retrieve endpoints from the database] [err: %s]", err)
+		return
+	}
+
+	edgeStacksByID := make(map[portainer.EdgeStackID]*portainer.EdgeStack, len(edgeStacks))
+	for idx := range edgeStacks {
+		edgeStacksByID[edgeStacks[idx].ID] = &edgeStacks[idx]
+	}
+
+	endpointIDs := make(map[portainer.EndpointID]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpointIDs[endpoint.ID] = true
+	}
+
+	handler.trustedStackFilesMutex.Lock()
+	defer handler.trustedStackFilesMutex.Unlock()
+
+	for key, stackFile := range handler.trustedStackFiles {
+		edgeStack, ok := edgeStacksByID[stackFile.edgeStackID]
+		if ok && endpointIDs[stackFile.endpointID] && edge.EdgeStackEndpointVersion(edgeStack, stackFile.endpointID) <= stackFile.version {
+			continue
+		}
+		delete(handler.trustedStackFiles, key)
+	}
+}
+
+// recordUntrustedEdgeStack records the rejection of the images of an Edge stack version in the status of the stack
+// for the endpoint, the stack is reloaded to keep the changes made during the verification
+func (handler *Handler) recordUntrustedEdgeStack(edgeStackID portainer.EdgeStackID, endpointID portainer.EndpointID, version int, untrustedErr error) {
+	edgeStack, err := handler.DataStore.EdgeStack().EdgeStack(edgeStackID)
+	if err != nil {
+		log.Printf("[ERROR] [endpointedge,imagetrust] [message: unable to find the edge stack inside the database] [edge_stack_id: %d] [err: %s]", edgeStackID, err)
+		return
+	}
+
+	if edge.EdgeStackEndpointVersion(edgeStack, endpointID) != version {
+		return
+	}
+
+	if edgeStack.Status == nil {
+		edgeStack.Status = map[portainer.EndpointID]portainer.EdgeStackStatus{}
+	}
+
+	edgeStack.Status[endpointID] = portainer.EdgeStackStatus{
+		Type:       portainer.StatusError,
+		Error:      untrustedErr.Error(),
+		EndpointID: endpointID,
+		Version:    version,
+	}
+
+	edge.RecordEdgeStackDeployment(edgeStack, endpointID, portainer.EdgeStackDeployment{
+		Version: version,
+		Type:    portainer.StatusError,
+		Error:   untrustedErr.Error(),
+	}, time.Now().Unix())
+
+	err = handler.DataStore.EdgeStack().UpdateEdgeStack(edgeStack.ID, edgeStack)
+	if err != nil {
+		log.Printf("[ERROR] [endpointedge,imagetrust] [message: unable to record the rejection of the edge stack images] [edge_stack_id: %d] [err: %s]", edgeStackID, err)
+	}
+}
+
+// stackFileSource identifies the content of a Compose file, the environment used to interpolate it
+// and the image trust policy and keys used to verify its images
+func stackFileSource(content []byte, env []portainer.Pair, policy portainer.ImageTrustPolicy, keys []portainer.ImageTrustKey) [sha256.Size]byte {
+	hash := sha256.New()
+	hash.Write(content)
+	for _, pair := range env {
+		fmt.Fprintf(hash, "\x00%s=%s", pair.Name, pair.Value)
+	}
+	fmt.Fprintf(hash, "\x00policy=%s", policy)
+	for _, key := range keys {
+		fmt.Fprintf(hash, "\x00key=%s", key.PublicKey)
+	}
+
+	var source [sha256.Size]byte
+	copy(source[:], hash.Sum(nil))
+	return source
+}
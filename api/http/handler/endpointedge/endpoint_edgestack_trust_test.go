@@ -0,0 +1,177 @@
+package endpointedge
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/imagetrust"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingImageTrustService struct {
+	portainer.ImageTrustService
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (service *countingImageTrustService) TrustedComposeFile(content []byte, env []portainer.Pair, endpoint *portainer.Endpoint) ([]byte, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	service.calls++
+	if service.err != nil {
+		return nil, service.err
+	}
+	return append([]byte("pinned "), content...), nil
+}
+
+func newTrustTestHandler(t *testing.T, imageTrustService portainer.ImageTrustService) (*Handler, *portainer.EdgeStack, *portainer.Endpoint, func()) {
+	store, teardown := testhelpers.NewDatastore(t)
+
+	endpoint := &portainer.Endpoint{ID: 1, GroupID: 1, Type: portainer.EdgeAgentOnDockerEnvironment}
+	assert.NoError(t, store.Endpoint().CreateEndpoint(endpoint))
+	edgeStack := &portainer.EdgeStack{ID: 1, Name: "stack", Version: 1}
+	assert.NoError(t, store.EdgeStack().CreateEdgeStack(edgeStack))
+
+	handler := &Handler{DataStore: store, ImageTrustService: imageTrustService}
+	return handler, edgeStack, endpoint, teardown
+}
+
+var enforcedGroup = &portainer.EndpointGroup{ID: 1, ImageTrustPolicy: portainer.ImageTrustPolicyEnforce}
+
+func Test_trustedEdgeStackFile_VerifiesOncePerVersion(t *testing.T) {
+	imageTrustService := &countingImageTrustService{}
+	handler, edgeStack, endpoint, teardown := newTrustTestHandler(t, imageTrustService)
+	defer teardown()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := handler.trustedEdgeStackFile(edgeStack, endpoint, enforcedGroup, []byte("file"), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, "pinned file", string(content))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, imageTrustService.calls)
+
+	// the environment is part of the verified source
+	_, err := handler.trustedEdgeStackFile(edgeStack, endpoint, enforcedGroup, []byte("file"), []portainer.Pair{{Name: "TAG", Value: "2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, imageTrustService.calls)
+
+	edgeStack.Version = 2
+	_, err = handler.trustedEdgeStackFile(edgeStack, endpoint, enforcedGroup, []byte("file"), []portainer.Pair{{Name: "TAG", Value: "2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, imageTrustService.calls)
+}
+
+func Test_trustedEdgeStackFile_RetriesFailedVerifications(t *testing.T) {
+	imageTrustService := &countingImageTrustService{err: errors.New("registry unavailable")}
+	handler, edgeStack, endpoint, teardown := newTrustTestHandler(t, imageTrustService)
+	defer teardown()
+
+	_, err := handler.trustedEdgeStackFile(edgeStack, endpoint, enforcedGroup, []byte("file"), nil)
+	assert.Error(t, err)
+
+	imageTrustService.err = nil
+	content, err := handler.trustedEdgeStackFile(edgeStack, endpoint, enforcedGroup, []byte("file"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "pinned file", string(content))
+	assert.Equal(t, 2, imageTrustService.calls)
+
+	storedStack, err := handler.DataStore.EdgeStack().EdgeStack(edgeStack.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, storedStack.Status)
+}
+
+func Test_trustedEdgeStackFile_RecordsRejection(t *testing.T) {
+	imageTrustService := &countingImageTrustService{err: &imagetrust.UntrustedImagesError{Images: []string{"nginx:latest"}}}
+	handler, edgeStack, endpoint, teardown := newTrustTestHandler(t, imageTrustService)
+	defer teardown()
+
+	for i := 0; i < 2; i++ {
+		_, err := handler.trustedEdgeStackFile(edgeStack, endpoint, enforcedGroup, []byte("file"), nil)
+		assert.IsType(t, &imagetrust.UntrustedImagesError{}, err)
+	}
+	assert.Equal(t, 1, imageTrustService.calls)
+
+	storedStack, err := handler.DataStore.EdgeStack().EdgeStack(edgeStack.ID)
+	assert.NoError(t, err)
+
+	status := storedStack.Status[endpoint.ID]
+	assert.Equal(t, portainer.StatusError, status.Type)
+	assert.Equal(t, 1, status.Version)
+	assert.Contains(t, status.Error, "nginx:latest")
+
+	if assert.Len(t, storedStack.Deployments[endpoint.ID], 1) {
+		assert.Equal(t, portainer.StatusError, storedStack.Deployments[endpoint.ID][0].Type)
+	}
+}
+
+func Test_trustedEdgeStackFile_VerifiesAgainWhenTrustChanges(t *testing.T) {
+	imageTrustService := &countingImageTrustService{}
+	handler, edgeStack, endpoint, teardown := newTrustTestHandler(t, imageTrustService)
+	defer teardown()
+
+	_, err := handler.trustedEdgeStackFile(edgeStack, endpoint, enforcedGroup, []byte("file"), nil)
+	assert.NoError(t, err)
+
+	auditedGroup := &portainer.EndpointGroup{ID: 1, ImageTrustPolicy: portainer.ImageTrustPolicyAudit}
+	_, err = handler.trustedEdgeStackFile(edgeStack, endpoint, auditedGroup, []byte("file"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, imageTrustService.calls)
+
+	settings, err := handler.DataStore.Settings().Settings()
+	assert.NoError(t, err)
+	settings.ImageTrustKeys = []portainer.ImageTrustKey{{Name: "release", PublicKey: "public-key"}}
+	assert.NoError(t, handler.DataStore.Settings().UpdateSettings(settings))
+
+	_, err = handler.trustedEdgeStackFile(edgeStack, endpoint, auditedGroup, []byte("file"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, imageTrustService.calls)
+
+	_, err = handler.trustedEdgeStackFile(edgeStack, endpoint, auditedGroup, []byte("file"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, imageTrustService.calls)
+}
+
+func Test_trustedEdgeStackFile_PrunesOutdatedVerifications(t *testing.T) {
+	imageTrustService := &countingImageTrustService{}
+	handler, edgeStack, endpoint, teardown := newTrustTestHandler(t, imageTrustService)
+	defer teardown()
+
+	otherEndpoint := &portainer.Endpoint{ID: 2, GroupID: 1, Type: portainer.EdgeAgentOnDockerEnvironment}
+	assert.NoError(t, handler.DataStore.Endpoint().CreateEndpoint(otherEndpoint))
+	otherStack := &portainer.EdgeStack{ID: 2, Name: "other", Version: 1}
+	assert.NoError(t, handler.DataStore.EdgeStack().CreateEdgeStack(otherStack))
+	outdatedStack := &portainer.EdgeStack{ID: 3, Name: "outdated", Version: 1}
+	assert.NoError(t, handler.DataStore.EdgeStack().CreateEdgeStack(outdatedStack))
+
+	for _, stack := range []*portainer.EdgeStack{edgeStack, otherStack, outdatedStack} {
+		_, err := handler.trustedEdgeStackFile(stack, endpoint, enforcedGroup, []byte("file"), nil)
+		assert.NoError(t, err)
+	}
+	_, err := handler.trustedEdgeStackFile(edgeStack, otherEndpoint, enforcedGroup, []byte("file"), nil)
+	assert.NoError(t, err)
+	assert.Len(t, handler.trustedStackFiles, 4)
+
+	assert.NoError(t, handler.DataStore.EdgeStack().DeleteEdgeStack(otherStack.ID))
+	assert.NoError(t, handler.DataStore.Endpoint().DeleteEndpoint(otherEndpoint.ID))
+	outdatedStack.Version = 2
+	assert.NoError(t, handler.DataStore.EdgeStack().UpdateEdgeStack(outdatedStack.ID, outdatedStack))
+
+	edgeStack.Version = 2
+	assert.NoError(t, handler.DataStore.EdgeStack().UpdateEdgeStack(edgeStack.ID, edgeStack))
+	_, err = handler.trustedEdgeStackFile(edgeStack, endpoint, enforcedGroup, []byte("file"), nil)
+	assert.NoError(t, err)
+
+	assert.Len(t, handler.trustedStackFiles, 1)
+	assert.Equal(t, 2, handler.trustedStackFiles["1_1"].version)
+}
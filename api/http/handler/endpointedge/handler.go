@@ -2,11 +2,13 @@ package endpointedge
 
 import (
 	"net/http"
+	"sync"
 
 	httperror "github.com/portainer/libhttp/error"
 
 	portainer "github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	"github.com/gorilla/mux"
 )
 
@@ -17,6 +19,12 @@ type Handler struct {
 	DataStore            portainer.DataStore
 	FileService          portainer.FileService
 	ReverseTunnelService portainer.ReverseTunnelService
+	ImageTrustService    portainer.ImageTrustService
+	SecretService        portainer.SecretService
+	SecretEnvSender      *edge.SecretEnvSender
+
+	trustedStackFilesMutex sync.Mutex
+	trustedStackFiles      map[string]*trustedStackFile
 }
 
 // NewHandler creates a handler to manage endpoint operations.
@@ -26,9 +34,15 @@ func NewHandler(bouncer *security.RequestBouncer) *Handler {
 		requestBouncer: bouncer,
 	}
 
+	h.Handle("/{id}/edge/history",
+		bouncer.RestrictedAccess(httperror.LoggerHandler(h.endpointEdgeHistory))).Methods(http.MethodGet)
+	h.Handle("/{id}/edge/key",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.endpointEdgeKeyRegenerate))).Methods(http.MethodPost)
 	h.Handle("/{id}/edge/stacks/{stackId}",
 		bouncer.PublicAccess(httperror.LoggerHandler(h.endpointEdgeStackInspect))).Methods(http.MethodGet)
 	h.Handle("/{id}/edge/jobs/{jobID}/logs",
 		bouncer.PublicAccess(httperror.LoggerHandler(h.endpointEdgeJobsLogs))).Methods(http.MethodPost)
+	h.Handle("/{id}/edge/jobs/{jobID}/runs",
+		bouncer.PublicAccess(httperror.LoggerHandler(h.endpointEdgeJobRun))).Methods(http.MethodPost)
 	return h
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/asaskevich/govalidator"
 	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -16,8 +17,11 @@ type endpointGroupCreatePayload struct {
 	Description         string
 	AssociatedEndpoints []portainer.EndpointID
 	TagIDs              []portainer.TagID
+	ImageTrustPolicy    portainer.ImageTrustPolicy
 }
 
+var errInvalidImageTrustPolicy = errors.New("Invalid image trust policy. Value must be one of: audit, enforce or empty")
+
 func (payload *endpointGroupCreatePayload) Validate(r *http.Request) error {
 	if govalidator.IsNull(payload.Name) {
 		return errors.New("Invalid endpoint group name")
@@ -25,9 +29,16 @@ func (payload *endpointGroupCreatePayload) Validate(r *http.Request) error {
 	if payload.TagIDs == nil {
 		payload.TagIDs = []portainer.TagID{}
 	}
+	if !isValidImageTrustPolicy(payload.ImageTrustPolicy) {
+		return errInvalidImageTrustPolicy
+	}
 	return nil
 }
 
+func isValidImageTrustPolicy(policy portainer.ImageTrustPolicy) bool {
+	return policy == portainer.ImageTrustPolicyNone || policy == portainer.ImageTrustPolicyAudit || policy == portainer.ImageTrustPolicyEnforce
+}
+
 // POST request on /api/endpoint_groups
 func (handler *Handler) endpointGroupCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
 	var payload endpointGroupCreatePayload
@@ -42,6 +53,7 @@ func (handler *Handler) endpointGroupCreate(w http.ResponseWriter, r *http.Reque
 		UserAccessPolicies: portainer.UserAccessPolicies{},
 		TeamAccessPolicies: portainer.TeamAccessPolicies{},
 		TagIDs:             payload.TagIDs,
+		ImageTrustPolicy:   payload.ImageTrustPolicy,
 	}
 
 	err = handler.DataStore.EndpointGroup().CreateEndpointGroup(endpointGroup)
@@ -88,5 +100,10 @@ func (handler *Handler) endpointGroupCreate(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	err = edge.UpdateEdgeJobsEndpoints(handler.DataStore, handler.ReverseTunnelService)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update the endpoints of the Edge jobs", err}
+	}
+
 	return response.JSON(w, endpointGroup)
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/cloudogu/portainer-ce/api"
 	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -68,5 +69,10 @@ func (handler *Handler) endpointGroupDelete(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	err = edge.UpdateEdgeJobsEndpoints(handler.DataStore, handler.ReverseTunnelService)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update the endpoints of the Edge jobs", err}
+	}
+
 	return response.Empty(w)
 }
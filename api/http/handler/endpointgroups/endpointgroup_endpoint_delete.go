@@ -5,6 +5,7 @@ import (
 
 	"github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -48,5 +49,10 @@ func (handler *Handler) endpointGroupDeleteEndpoint(w http.ResponseWriter, r *ht
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist endpoint relations changes inside the database", err}
 	}
 
+	err = edge.UpdateEdgeJobsEndpoints(handler.DataStore, handler.ReverseTunnelService)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update the endpoints of the Edge jobs", err}
+	}
+
 	return response.Empty(w)
 }
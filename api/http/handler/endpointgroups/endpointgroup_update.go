@@ -6,6 +6,7 @@ import (
 
 	"github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	"github.com/cloudogu/portainer-ce/api/internal/tag"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
@@ -18,9 +19,13 @@ type endpointGroupUpdatePayload struct {
 	TagIDs             []portainer.TagID
 	UserAccessPolicies portainer.UserAccessPolicies
 	TeamAccessPolicies portainer.TeamAccessPolicies
+	ImageTrustPolicy   *portainer.ImageTrustPolicy
 }
 
 func (payload *endpointGroupUpdatePayload) Validate(r *http.Request) error {
+	if payload.ImageTrustPolicy != nil && !isValidImageTrustPolicy(*payload.ImageTrustPolicy) {
+		return errInvalidImageTrustPolicy
+	}
 	return nil
 }
 
@@ -100,6 +105,10 @@ func (handler *Handler) endpointGroupUpdate(w http.ResponseWriter, r *http.Reque
 		endpointGroup.TeamAccessPolicies = payload.TeamAccessPolicies
 	}
 
+	if payload.ImageTrustPolicy != nil {
+		endpointGroup.ImageTrustPolicy = *payload.ImageTrustPolicy
+	}
+
 	err = handler.DataStore.EndpointGroup().UpdateEndpointGroup(endpointGroup.ID, endpointGroup)
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist endpoint group changes inside the database", err}
@@ -122,5 +131,10 @@ func (handler *Handler) endpointGroupUpdate(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	err = edge.UpdateEdgeJobsEndpoints(handler.DataStore, handler.ReverseTunnelService)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update the endpoints of the Edge jobs", err}
+	}
+
 	return response.JSON(w, endpointGroup)
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -45,6 +46,16 @@ func (handler *Handler) endpointDelete(w http.ResponseWriter, r *http.Request) *
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove endpoint relation from the database", err}
 	}
 
+	err = handler.DataStore.EdgeCheckin().DeleteEdgeCheckin(endpoint.ID)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove Edge endpoint check-in state from the database", err}
+	}
+
+	err = handler.DataStore.ImageStatus().DeleteImageStatus(endpoint.ID)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove endpoint image status from the database", err}
+	}
+
 	for _, tagID := range endpoint.TagIDs {
 		tag, err := handler.DataStore.Tag().Tag(tagID)
 		if err != nil {
@@ -83,8 +94,14 @@ func (handler *Handler) endpointDelete(w http.ResponseWriter, r *http.Request) *
 
 	for idx := range edgeStacks {
 		edgeStack := &edgeStacks[idx]
-		if _, ok := edgeStack.Status[endpoint.ID]; ok {
+		_, hasStatus := edgeStack.Status[endpoint.ID]
+		_, hasDeployments := edgeStack.Deployments[endpoint.ID]
+		_, hasPendingSecretEnv := edgeStack.PendingSecretEnv[endpoint.ID]
+		if hasStatus || hasDeployments || hasPendingSecretEnv {
 			delete(edgeStack.Status, endpoint.ID)
+			delete(edgeStack.Deployments, endpoint.ID)
+			delete(edgeStack.EndpointRetries, endpoint.ID)
+			delete(edgeStack.PendingSecretEnv, endpoint.ID)
 			err = handler.DataStore.EdgeStack().UpdateEdgeStack(edgeStack.ID, edgeStack)
 			if err != nil {
 				return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update edge stack", err}
@@ -92,6 +109,25 @@ func (handler *Handler) endpointDelete(w http.ResponseWriter, r *http.Request) *
 		}
 	}
 
+	edgeJobs, err := handler.DataStore.EdgeJob().EdgeJobs()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve edge jobs from the database", err}
+	}
+
+	for idx := range edgeJobs {
+		if _, ok := edgeJobs[idx].Endpoints[endpoint.ID]; !ok {
+			continue
+		}
+
+		_, err = edge.UpdateEdgeJob(handler.DataStore, edgeJobs[idx].ID, func(edgeJob *portainer.EdgeJob) error {
+			delete(edgeJob.Endpoints, endpoint.ID)
+			return nil
+		})
+		if err != nil && err != errors.ErrObjectNotFound {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update edge job", err}
+		}
+	}
+
 	return response.Empty(w)
 }
 
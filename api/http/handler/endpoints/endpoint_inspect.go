@@ -29,6 +29,18 @@ func (handler *Handler) endpointInspect(w http.ResponseWriter, r *http.Request)
 		return &httperror.HandlerError{http.StatusForbidden, "Permission denied to access endpoint", err}
 	}
 
+	endpoints := []portainer.Endpoint{*endpoint}
+	err = handler.updateEdgeEndpointsStatus(endpoints)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve Edge endpoint check-in state from the database", err}
+	}
+
+	err = handler.hideSecretMetadata(endpoints)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve edge stacks from the database", err}
+	}
+	endpoint = &endpoints[0]
+
 	hideFields(endpoint)
 	endpoint.ComposeSyntaxMaxVersion = handler.ComposeStackManager.ComposeSyntaxMaxVersion()
 
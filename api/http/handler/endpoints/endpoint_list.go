@@ -86,6 +86,16 @@ func (handler *Handler) endpointList(w http.ResponseWriter, r *http.Request) *ht
 
 	paginatedEndpoints := paginateEndpoints(filteredEndpoints, start, limit)
 
+	err = handler.updateEdgeEndpointsStatus(paginatedEndpoints)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve Edge endpoints check-in state from the database", err}
+	}
+
+	err = handler.hideSecretMetadata(paginatedEndpoints)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve edge stacks from the database", err}
+	}
+
 	for idx := range paginatedEndpoints {
 		hideFields(&paginatedEndpoints[idx])
 		paginatedEndpoints[idx].ComposeSyntaxMaxVersion = handler.ComposeStackManager.ComposeSyntaxMaxVersion()
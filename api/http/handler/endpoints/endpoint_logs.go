@@ -0,0 +1,211 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/docker"
+	dockerproxy "github.com/cloudogu/portainer-ce/api/http/proxy/factory/docker"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	"github.com/docker/docker/api/types"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+)
+
+const (
+	logsFormatNDJSON = "ndjson"
+	logsFormatSSE    = "sse"
+)
+
+type endpointLogsFilters struct {
+	stack   string
+	service string
+	name    *regexp.Regexp
+}
+
+// GET request on /api/endpoints/:id/logs?(stack=<stack>)&(service=<service>)&(name=<name>)&(since=<since>)&(until=<until>)&(tail=<tail>)&(search=<search>)&(format=<ndjson|sse>)
+// Retrieves the logs of all the containers of a Docker endpoint matching the filters,
+// merges them by timestamp and streams them as newline delimited JSON or server-sent events.
+// On agent Swarm endpoints, the logs of each container are read through the node running it.
+// At most docker.MaxLogStreams containers can be selected by the filters.
+func (handler *Handler) endpointLogs(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid endpoint identifier route variable", err}
+	}
+
+	filters := endpointLogsFilters{}
+	filters.stack, _ = request.RetrieveQueryParameter(r, "stack", true)
+	filters.service, _ = request.RetrieveQueryParameter(r, "service", true)
+
+	namePattern, _ := request.RetrieveQueryParameter(r, "name", true)
+	if namePattern != "" {
+		filters.name, err = regexp.Compile(namePattern)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Invalid query parameter: name. Must be a valid regular expression", err}
+		}
+	}
+
+	options := docker.LogsOptions{}
+	options.Since, _ = request.RetrieveQueryParameter(r, "since", true)
+	options.Until, _ = request.RetrieveQueryParameter(r, "until", true)
+	options.Tail, _ = request.RetrieveQueryParameter(r, "tail", true)
+	options.Search, _ = request.RetrieveQueryParameter(r, "search", true)
+
+	format, _ := request.RetrieveQueryParameter(r, "format", true)
+	if format == "" {
+		format = logsFormatNDJSON
+	}
+	if format != logsFormatNDJSON && format != logsFormatSSE {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid query parameter: format. Value must be one of: ndjson or sse", errors.New("Invalid logs format")}
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
+	}
+
+	err = handler.requestBouncer.AuthorizedEndpointOperation(r, endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusForbidden, "Permission denied to access endpoint", err}
+	}
+
+	if endpoint.Type != portainer.DockerEnvironment && endpoint.Type != portainer.AgentOnDockerEnvironment && endpoint.Type != portainer.EdgeAgentOnDockerEnvironment {
+		return &httperror.HandlerError{http.StatusBadRequest, "Logs aggregation is only supported on Docker endpoints", errors.New("Unsupported endpoint type")}
+	}
+
+	if endpoint.Type == portainer.EdgeAgentOnDockerEnvironment {
+		if endpoint.EdgeID == "" {
+			return &httperror.HandlerError{http.StatusInternalServerError, "No Edge agent registered with the endpoint", errors.New("No agent available")}
+		}
+
+		err = edge.WakeUpEdgeAgent(endpoint.ID, handler.ReverseTunnelService, handler.DataStore)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update tunnel status", err}
+		}
+	}
+
+	dockerClient, err := handler.DockerClientFactory.CreateClient(endpoint, "")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to create Docker client", err}
+	}
+	defer dockerClient.Close()
+
+	nodeNames, err := docker.EndpointNodeNames(r.Context(), dockerClient, endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve the nodes of the endpoint", err}
+	}
+
+	// the logs of a container can only be read through the node running it
+	sources := make([]docker.ContainerLogsSource, 0)
+	for _, nodeName := range nodeNames {
+		nodeClient := dockerClient
+		if nodeName != "" {
+			nodeClient, err = handler.DockerClientFactory.CreateClient(endpoint, nodeName)
+			if err != nil {
+				return &httperror.HandlerError{http.StatusInternalServerError, "Unable to create Docker client", err}
+			}
+			defer nodeClient.Close()
+		}
+
+		containers, err := nodeClient.ContainerList(r.Context(), types.ContainerListOptions{All: true})
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve containers", err}
+		}
+
+		containers, err = dockerproxy.FilterContainers(handler.DataStore, r, containers)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to filter containers", err}
+		}
+
+		for _, container := range filterContainersForLogs(containers, filters) {
+			sources = append(sources, docker.ContainerLogsSource{Client: nodeClient, Container: container})
+		}
+	}
+
+	if len(sources) > docker.MaxLogStreams {
+		return &httperror.HandlerError{http.StatusBadRequest, "Too many containers match the filters, use the stack, service or name filters to select fewer containers", docker.ErrTooManyLogStreams}
+	}
+
+	if format == logsFormatSSE {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	err = docker.AggregateContainerLogs(r.Context(), sources, options, func(line *docker.LogLine) error {
+		if format == logsFormatSSE {
+			_, err := fmt.Fprint(w, "data: ")
+			if err != nil {
+				return err
+			}
+		}
+
+		err := encoder.Encode(line)
+		if err != nil {
+			return err
+		}
+
+		if format == logsFormatSSE {
+			_, err = fmt.Fprint(w, "\n")
+			if err != nil {
+				return err
+			}
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil && r.Context().Err() == nil {
+		log.Printf("[WARN] [http,endpoints,logs] [message: unable to stream containers logs] [endpoint: %s] [err: %s]", endpoint.Name, err)
+	}
+
+	return nil
+}
+
+func filterContainersForLogs(containers []types.Container, filters endpointLogsFilters) []types.Container {
+	filteredContainers := make([]types.Container, 0)
+
+	for _, container := range containers {
+		if filters.stack != "" && container.Labels["com.docker.compose.project"] != filters.stack && container.Labels["com.docker.stack.namespace"] != filters.stack {
+			continue
+		}
+
+		if filters.service != "" && container.Labels["com.docker.compose.service"] != filters.service && container.Labels["com.docker.swarm.service.name"] != filters.service {
+			continue
+		}
+
+		if filters.name != nil && !containerNameMatches(container, filters.name) {
+			continue
+		}
+
+		filteredContainers = append(filteredContainers, container)
+	}
+
+	return filteredContainers
+}
+
+func containerNameMatches(container types.Container, pattern *regexp.Regexp) bool {
+	for _, name := range container.Names {
+		if pattern.MatchString(strings.TrimPrefix(name, "/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,11 +3,13 @@ package endpoints
 import (
 	"encoding/base64"
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/cloudogu/portainer-ce/api"
 	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -19,11 +21,13 @@ type stackStatusResponse struct {
 }
 
 type edgeJobResponse struct {
-	ID             portainer.EdgeJobID `json:"Id"`
-	CollectLogs    bool                `json:"CollectLogs"`
-	CronExpression string              `json:"CronExpression"`
-	Script         string              `json:"Script"`
-	Version        int                 `json:"Version"`
+	ID                   portainer.EdgeJobID `json:"Id"`
+	CollectLogs          bool                `json:"CollectLogs"`
+	CollectLogsOnFailure bool                `json:"CollectLogsOnFailure"`
+	CronExpression       string              `json:"CronExpression"`
+	Script               string              `json:"Script"`
+	Timeout              int                 `json:"Timeout"`
+	Version              int                 `json:"Version"`
 }
 
 type endpointStatusInspectResponse struct {
@@ -54,6 +58,10 @@ func (handler *Handler) endpointStatusInspect(w http.ResponseWriter, r *http.Req
 		return &httperror.HandlerError{http.StatusForbidden, "Permission denied to access endpoint", err}
 	}
 
+	if endpoint.EdgeEnrollment != nil && endpoint.EdgeEnrollment.Status != portainer.EdgeEnrollmentApproved {
+		return &httperror.HandlerError{http.StatusForbidden, "Edge endpoint enrollment has not been approved", errors.New("Endpoint pending approval")}
+	}
+
 	if endpoint.EdgeID == "" {
 		edgeIdentifier := r.Header.Get(portainer.PortainerAgentEdgeIDHeader)
 		endpoint.EdgeID = edgeIdentifier
@@ -82,6 +90,11 @@ func (handler *Handler) endpointStatusInspect(w http.ResponseWriter, r *http.Req
 		}
 	}
 
+	err = handler.ReverseTunnelService.UpdateCheckin(endpoint.ID, r.Header.Get(portainer.PortainerAgentVersionHeader), handler.TrustedProxies.ClientIP(r))
+	if err != nil {
+		log.Printf("[WARN] [http,endpoints] [message: unable to persist Edge agent check-in] [endpoint: %s] [err: %s]", endpoint.Name, err)
+	}
+
 	settings, err := handler.DataStore.Settings().Settings()
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve settings from the database", err}
@@ -89,18 +102,17 @@ func (handler *Handler) endpointStatusInspect(w http.ResponseWriter, r *http.Req
 
 	tunnel := handler.ReverseTunnelService.GetTunnelDetails(endpoint.ID)
 
-	checkinInterval := settings.EdgeAgentCheckinInterval
-	if endpoint.EdgeCheckinInterval != 0 {
-		checkinInterval = endpoint.EdgeCheckinInterval
-	}
+	checkinInterval := edge.EndpointCheckinInterval(endpoint, settings)
 
 	schedules := []edgeJobResponse{}
 	for _, job := range tunnel.Jobs {
 		schedule := edgeJobResponse{
-			ID:             job.ID,
-			CronExpression: job.CronExpression,
-			CollectLogs:    job.Endpoints[endpoint.ID].CollectLogs,
-			Version:        job.Version,
+			ID:                   job.ID,
+			CronExpression:       job.CronExpression,
+			CollectLogs:          job.Endpoints[endpoint.ID].CollectLogs,
+			CollectLogsOnFailure: job.CollectLogsOnFailure,
+			Timeout:              job.Timeout,
+			Version:              job.Version,
 		}
 
 		file, err := handler.FileService.GetFileContent(job.ScriptPath)
@@ -138,15 +150,61 @@ func (handler *Handler) endpointStatusInspect(w http.ResponseWriter, r *http.Req
 			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve edge stack from the database", err}
 		}
 
+		if !edge.EdgeStackDeployableOnEndpoint(stack, endpoint.Type) {
+			continue
+		}
+
 		stackStatus := stackStatusResponse{
 			ID:      stack.ID,
-			Version: stack.Version,
+			Version: edge.EdgeStackEndpointVersion(stack, endpoint.ID),
 		}
 
 		edgeStacksStatus = append(edgeStacksStatus, stackStatus)
+
+		err = handler.sendPendingSecretEnv(endpoint, stack)
+		if err != nil {
+			log.Printf("[WARN] [http,endpoints] [message: unable to send the secret environment variables of an exported edge stack] [endpoint: %s] [edge_stack: %s] [err: %s]", endpoint.Name, stack.Name, err)
+		}
 	}
 
 	statusResponse.Stacks = edgeStacksStatus
 
 	return response.JSON(w, statusResponse)
 }
+
+// sendPendingSecretEnv sends to the agent the secret environment variables of an Edge stack exported in a bundle
+// for the endpoint. The delivery is dropped when the endpoint is expected to run a newer version of the stack,
+// the agent then retrieves the stack configuration and its secret environment variables are sent again.
+func (handler *Handler) sendPendingSecretEnv(endpoint *portainer.Endpoint, edgeStack *portainer.EdgeStack) error {
+	version, ok := edgeStack.PendingSecretEnv[endpoint.ID]
+	if !ok {
+		return nil
+	}
+
+	delete(edgeStack.PendingSecretEnv, endpoint.ID)
+	err := handler.DataStore.EdgeStack().UpdateEdgeStack(edgeStack.ID, edgeStack)
+	if err != nil {
+		return err
+	}
+
+	if version != edge.EdgeStackEndpointVersion(edgeStack, endpoint.ID) {
+		return nil
+	}
+
+	endpointGroup, err := handler.DataStore.EndpointGroup().EndpointGroup(endpoint.GroupID)
+	if err != nil {
+		return err
+	}
+
+	_, secretEnv := edge.ResolveEdgeStackEnv(edgeStack, endpoint, endpointGroup)
+	secretEnv, err = edge.DecryptEnv(secretEnv, handler.SecretService)
+	if err != nil {
+		return err
+	}
+
+	if len(secretEnv) > 0 {
+		go handler.SecretEnvSender.SendEdgeStackSecretEnv(endpoint.ID, edgeStack.ID, version, secretEnv)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/crypto"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+type testReverseTunnelService struct {
+	portainer.ReverseTunnelService
+	port int
+}
+
+func (service testReverseTunnelService) GetTunnelDetails(endpointID portainer.EndpointID) *portainer.TunnelDetails {
+	return &portainer.TunnelDetails{Status: portainer.EdgeAgentActive, Port: service.port}
+}
+
+func Test_sendPendingSecretEnv(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	secretService := crypto.NewSecretService()
+	key, err := secretService.GenerateKey()
+	assert.NoError(t, err)
+	assert.NoError(t, secretService.ParseKey(key))
+
+	encryptedToken, err := secretService.Encrypt("secret-token")
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.EndpointGroup().CreateEndpointGroup(&portainer.EndpointGroup{Name: "group"}))
+	endpoint := &portainer.Endpoint{ID: 1, GroupID: 1, Type: portainer.EdgeAgentOnDockerEnvironment, EdgeID: "edge-id"}
+	assert.NoError(t, store.Endpoint().CreateEndpoint(endpoint))
+
+	type secretEnvPayload struct {
+		Version   int
+		SecretEnv []portainer.Pair
+	}
+
+	delivered := make(chan secretEnvPayload, 2)
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload secretEnvPayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		delivered <- payload
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer agent.Close()
+
+	agentURL, err := url.Parse(agent.URL)
+	assert.NoError(t, err)
+	agentPort, err := strconv.Atoi(agentURL.Port())
+	assert.NoError(t, err)
+
+	reverseTunnelService := testReverseTunnelService{port: agentPort}
+	handler := &Handler{
+		DataStore:            store,
+		ReverseTunnelService: reverseTunnelService,
+		SecretService:        secretService,
+		SecretEnvSender:      edge.NewSecretEnvSender(store, reverseTunnelService),
+	}
+
+	newEdgeStack := func(id portainer.EdgeStackID, pendingVersion int) *portainer.EdgeStack {
+		edgeStack := &portainer.EdgeStack{
+			ID:               id,
+			Version:          3,
+			Env:              []portainer.EdgeStackEnvVar{{Name: "API_TOKEN", Value: encryptedToken, Secret: true}},
+			PendingSecretEnv: map[portainer.EndpointID]int{endpoint.ID: pendingVersion},
+		}
+		assert.NoError(t, store.EdgeStack().CreateEdgeStack(edgeStack))
+		return edgeStack
+	}
+
+	t.Run("exported version", func(t *testing.T) {
+		edgeStack := newEdgeStack(1, 3)
+		assert.NoError(t, handler.sendPendingSecretEnv(endpoint, edgeStack))
+
+		select {
+		case payload := <-delivered:
+			assert.Equal(t, 3, payload.Version)
+			assert.Equal(t, []portainer.Pair{{Name: "API_TOKEN", Value: "secret-token"}}, payload.SecretEnv)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the secret environment variables were not sent through the tunnel")
+		}
+
+		edgeStack, err := store.EdgeStack().EdgeStack(1)
+		assert.NoError(t, err)
+		assert.Empty(t, edgeStack.PendingSecretEnv)
+	})
+
+	t.Run("outdated version", func(t *testing.T) {
+		edgeStack := newEdgeStack(2, 2)
+		assert.NoError(t, handler.sendPendingSecretEnv(endpoint, edgeStack))
+
+		select {
+		case <-delivered:
+			t.Fatal("the secret environment variables of an outdated bundle must not be sent")
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		edgeStack, err := store.EdgeStack().EdgeStack(2)
+		assert.NoError(t, err)
+		assert.Empty(t, edgeStack.PendingSecretEnv)
+	})
+}
@@ -32,9 +32,16 @@ type endpointUpdatePayload struct {
 	TeamAccessPolicies     portainer.TeamAccessPolicies
 	EdgeCheckinInterval    *int
 	Kubernetes             *portainer.KubernetesData
+	Metadata               map[string]string
 }
 
 func (payload *endpointUpdatePayload) Validate(r *http.Request) error {
+	for _, value := range payload.Metadata {
+		err := edge.ValidateEnvValue(value)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -74,6 +81,34 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 		endpoint.EdgeCheckinInterval = *payload.EdgeCheckinInterval
 	}
 
+	isEdgeEndpoint := endpoint.Type == portainer.EdgeAgentOnDockerEnvironment || endpoint.Type == portainer.EdgeAgentOnKubernetesEnvironment
+	previousEndpoint := *endpoint
+
+	var previousEdgeStacks map[portainer.EdgeStackID]bool
+	if isEdgeEndpoint {
+		relation, err := handler.DataStore.EndpointRelation().EndpointRelation(endpoint.ID)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find endpoint relation inside the database", err}
+		}
+		previousEdgeStacks = relation.EdgeStacks
+	}
+
+	if payload.Metadata != nil {
+		edgeStacks, err := handler.DataStore.EdgeStack().EdgeStacks()
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve edge stacks from the database", err}
+		}
+		secretNames := edge.SecretEnvNames(edgeStacks)
+
+		handler.keepSecretMetadata(endpoint.Metadata, payload.Metadata, secretNames)
+		endpoint.Metadata = payload.Metadata
+
+		err = edge.SealEndpointMetadata(endpoint, secretNames, handler.SecretService)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to encrypt the secret metadata of the endpoint", err}
+		}
+	}
+
 	groupIDChanged := false
 	if payload.GroupID != nil {
 		groupID := portainer.EndpointGroupID(*payload.GroupID)
@@ -223,7 +258,7 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist endpoint changes inside the database", err}
 	}
 
-	if (endpoint.Type == portainer.EdgeAgentOnDockerEnvironment || endpoint.Type == portainer.EdgeAgentOnKubernetesEnvironment) && (groupIDChanged || tagsChanged) {
+	if isEdgeEndpoint && (groupIDChanged || tagsChanged) {
 		relation, err := handler.DataStore.EndpointRelation().EndpointRelation(endpoint.ID)
 		if err != nil {
 			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find endpoint relation inside the database", err}
@@ -257,7 +292,36 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 		if err != nil {
 			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist endpoint relation changes inside the database", err}
 		}
+
+		err = edge.UpdateEdgeJobsEndpoints(handler.DataStore, handler.ReverseTunnelService)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update the endpoints of the Edge jobs", err}
+		}
 	}
 
-	return response.JSON(w, endpoint)
+	if isEdgeEndpoint && (groupIDChanged || tagsChanged || payload.Metadata != nil) {
+		err = edge.UpdateEdgeStacksEnvVersion(handler.DataStore, &previousEndpoint, previousEdgeStacks, endpoint)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to update the version of the edge stacks", err}
+		}
+	}
+
+	endpoints := []portainer.Endpoint{*endpoint}
+	err = handler.hideSecretMetadata(endpoints)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve edge stacks from the database", err}
+	}
+
+	return response.JSON(w, &endpoints[0])
+}
+
+// keepSecretMetadata restores the previous values of the secret metadata sent without a value, as these
+// values are never returned by the API. Removing a key clears its value.
+func (handler *Handler) keepSecretMetadata(previousMetadata, metadata map[string]string, secretNames map[string]bool) {
+	for name, value := range metadata {
+		previousValue, ok := previousMetadata[name]
+		if value == "" && ok && handler.isSecretMetadata(name, previousValue, secretNames) {
+			metadata[name] = previousValue
+		}
+	}
 }
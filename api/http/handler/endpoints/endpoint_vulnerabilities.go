@@ -0,0 +1,49 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/endpoints/:id/vulnerabilities
+// Returns the vulnerabilities of the images running in the latest snapshot of a Docker endpoint,
+// summarized per image and per stack.
+func (handler *Handler) endpointVulnerabilities(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid endpoint identifier route variable", err}
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
+	}
+
+	err = handler.requestBouncer.AuthorizedEndpointOperation(r, endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusForbidden, "Permission denied to access endpoint", err}
+	}
+
+	if endpoint.Type != portainer.DockerEnvironment && endpoint.Type != portainer.AgentOnDockerEnvironment && endpoint.Type != portainer.EdgeAgentOnDockerEnvironment {
+		return &httperror.HandlerError{http.StatusBadRequest, "Image vulnerability scanning is only supported on Docker endpoints", errors.New("Unsupported endpoint type")}
+	}
+
+	if len(endpoint.Snapshots) == 0 {
+		return &httperror.HandlerError{http.StatusNotFound, "No snapshot available for the endpoint", errors.New("No snapshot available for the endpoint")}
+	}
+
+	summary, err := handler.VulnerabilityScanService.EndpointSummary(endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to summarize the vulnerabilities of the endpoint images", err}
+	}
+
+	return response.JSON(w, summary)
+}
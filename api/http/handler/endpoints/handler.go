@@ -1,9 +1,13 @@
 package endpoints
 
 import (
+	"time"
+
 	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/docker"
 	"github.com/cloudogu/portainer-ce/api/http/proxy"
 	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 	httperror "github.com/portainer/libhttp/error"
 
 	"net/http"
@@ -18,16 +22,82 @@ func hideFields(endpoint *portainer.Endpoint) {
 	}
 }
 
+// hideSecretMetadata removes the values of the secret metadata of the endpoints
+func (handler *Handler) hideSecretMetadata(endpoints []portainer.Endpoint) error {
+	edgeStacks, err := handler.DataStore.EdgeStack().EdgeStacks()
+	if err != nil {
+		return err
+	}
+	secretNames := edge.SecretEnvNames(edgeStacks)
+
+	for idx := range endpoints {
+		for name, value := range endpoints[idx].Metadata {
+			if handler.isSecretMetadata(name, value, secretNames) {
+				endpoints[idx].Metadata[name] = ""
+			}
+		}
+	}
+	return nil
+}
+
+// isSecretMetadata returns true when a stored metadata value overrides a secret environment variable of
+// an Edge stack or is still encrypted, these values are never returned by the API
+func (handler *Handler) isSecretMetadata(name, value string, secretNames map[string]bool) bool {
+	return secretNames[name] || handler.SecretService.IsEncrypted(value)
+}
+
+// updateEdgeEndpointsStatus derives the status of the Edge endpoints from the latest check-in of their agent
+func (handler *Handler) updateEdgeEndpointsStatus(endpoints []portainer.Endpoint) error {
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return err
+	}
+
+	checkins, err := handler.DataStore.EdgeCheckin().EdgeCheckins()
+	if err != nil {
+		return err
+	}
+
+	lastCheckins := make(map[portainer.EndpointID]int64)
+	for _, checkin := range checkins {
+		lastCheckins[checkin.EndpointID] = checkin.LastCheckin
+	}
+
+	now := time.Now()
+	for idx := range endpoints {
+		endpoint := &endpoints[idx]
+		if endpoint.Type != portainer.EdgeAgentOnDockerEnvironment && endpoint.Type != portainer.EdgeAgentOnKubernetesEnvironment {
+			continue
+		}
+
+		// check-ins are only persisted periodically, the latest one is kept by the tunnel service
+		lastCheckin := lastCheckins[endpoint.ID]
+		if recentCheckin := handler.ReverseTunnelService.LastCheckin(endpoint.ID); recentCheckin > lastCheckin {
+			lastCheckin = recentCheckin
+		}
+
+		endpoint.Status = edge.CheckinStatus(lastCheckin, edge.EndpointCheckinInterval(endpoint, settings), now)
+	}
+
+	return nil
+}
+
 // Handler is the HTTP handler used to handle endpoint operations.
 type Handler struct {
 	*mux.Router
-	requestBouncer       *security.RequestBouncer
-	DataStore            portainer.DataStore
-	FileService          portainer.FileService
-	ProxyManager         *proxy.Manager
-	ReverseTunnelService portainer.ReverseTunnelService
-	SnapshotService      portainer.SnapshotService
-	ComposeStackManager  portainer.ComposeStackManager
+	requestBouncer           *security.RequestBouncer
+	DataStore                portainer.DataStore
+	FileService              portainer.FileService
+	ProxyManager             *proxy.Manager
+	ReverseTunnelService     portainer.ReverseTunnelService
+	SnapshotService          portainer.SnapshotService
+	ComposeStackManager      portainer.ComposeStackManager
+	DockerClientFactory      *docker.ClientFactory
+	ImageUpdateService       portainer.ImageUpdateService
+	VulnerabilityScanService portainer.VulnerabilityScanService
+	TrustedProxies           security.TrustedProxies
+	SecretService            portainer.SecretService
+	SecretEnvSender          *edge.SecretEnvSender
 }
 
 // NewHandler creates a handler to manage endpoint operations.
@@ -53,9 +123,15 @@ func NewHandler(bouncer *security.RequestBouncer) *Handler {
 		bouncer.RestrictedAccess(httperror.LoggerHandler(h.endpointExtensionAdd))).Methods(http.MethodPost)
 	h.Handle("/endpoints/{id}/extensions/{extensionType}",
 		bouncer.RestrictedAccess(httperror.LoggerHandler(h.endpointExtensionRemove))).Methods(http.MethodDelete)
+	h.Handle("/endpoints/{id}/image_status",
+		bouncer.RestrictedAccess(httperror.LoggerHandler(h.endpointImageStatus))).Methods(http.MethodGet)
+	h.Handle("/endpoints/{id}/logs",
+		bouncer.RestrictedAccess(httperror.LoggerHandler(h.endpointLogs))).Methods(http.MethodGet)
 	h.Handle("/endpoints/{id}/snapshot",
 		bouncer.AdminAccess(httperror.LoggerHandler(h.endpointSnapshot))).Methods(http.MethodPost)
 	h.Handle("/endpoints/{id}/status",
 		bouncer.PublicAccess(httperror.LoggerHandler(h.endpointStatusInspect))).Methods(http.MethodGet)
+	h.Handle("/endpoints/{id}/vulnerabilities",
+		bouncer.RestrictedAccess(httperror.LoggerHandler(h.endpointVulnerabilities))).Methods(http.MethodGet)
 	return h
 }
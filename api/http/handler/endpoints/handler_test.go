@@ -0,0 +1,52 @@
+package endpoints
+
+import (
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/crypto"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SecretMetadata(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	secretService := crypto.NewSecretService()
+	key, err := secretService.GenerateKey()
+	assert.NoError(t, err)
+	assert.NoError(t, secretService.ParseKey(key))
+
+	edgeStack := &portainer.EdgeStack{ID: 1, Env: []portainer.EdgeStackEnvVar{{Name: "API_TOKEN", Secret: true}}}
+	assert.NoError(t, store.EdgeStack().CreateEdgeStack(edgeStack))
+
+	// LEGACY_TOKEN does not override a secret variable anymore but its value is not decrypted yet
+	apiToken, err := secretService.Encrypt("device-token")
+	assert.NoError(t, err)
+	legacyToken, err := secretService.Encrypt("legacy-token")
+	assert.NoError(t, err)
+	previousMetadata := map[string]string{"API_TOKEN": apiToken, "LEGACY_TOKEN": legacyToken, "REGION": "eu"}
+
+	handler := &Handler{DataStore: store, SecretService: secretService}
+
+	endpoints := []portainer.Endpoint{{ID: 1, Metadata: map[string]string{}}}
+	for name, value := range previousMetadata {
+		endpoints[0].Metadata[name] = value
+	}
+	assert.NoError(t, handler.hideSecretMetadata(endpoints))
+	assert.Equal(t, map[string]string{"API_TOKEN": "", "LEGACY_TOKEN": "", "REGION": "eu"}, endpoints[0].Metadata)
+
+	// the hidden values sent back by a client are restored
+	metadata := endpoints[0].Metadata
+	handler.keepSecretMetadata(previousMetadata, metadata, map[string]bool{"API_TOKEN": true})
+	assert.Equal(t, previousMetadata, metadata)
+}
+
+func Test_endpointUpdatePayload_EncryptedPrefix(t *testing.T) {
+	payload := &endpointUpdatePayload{Metadata: map[string]string{"API_TOKEN": "encrypted:token"}}
+	assert.Error(t, payload.Validate(nil))
+
+	payload.Metadata["API_TOKEN"] = "token"
+	assert.NoError(t, payload.Validate(nil))
+}
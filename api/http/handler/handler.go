@@ -7,6 +7,7 @@ import (
 	"github.com/cloudogu/portainer-ce/api/http/handler/auth"
 	"github.com/cloudogu/portainer-ce/api/http/handler/customtemplates"
 	"github.com/cloudogu/portainer-ce/api/http/handler/dockerhub"
+	"github.com/cloudogu/portainer-ce/api/http/handler/edgeenrollment"
 	"github.com/cloudogu/portainer-ce/api/http/handler/edgegroups"
 	"github.com/cloudogu/portainer-ce/api/http/handler/edgejobs"
 	"github.com/cloudogu/portainer-ce/api/http/handler/edgestacks"
@@ -16,6 +17,9 @@ import (
 	"github.com/cloudogu/portainer-ce/api/http/handler/endpointproxy"
 	"github.com/cloudogu/portainer-ce/api/http/handler/endpoints"
 	"github.com/cloudogu/portainer-ce/api/http/handler/file"
+	"github.com/cloudogu/portainer-ce/api/http/handler/housekeeping"
+	"github.com/cloudogu/portainer-ce/api/http/handler/imagescans"
+	"github.com/cloudogu/portainer-ce/api/http/handler/kubernetes"
 	"github.com/cloudogu/portainer-ce/api/http/handler/motd"
 	"github.com/cloudogu/portainer-ce/api/http/handler/registries"
 	"github.com/cloudogu/portainer-ce/api/http/handler/resourcecontrols"
@@ -38,6 +42,7 @@ type Handler struct {
 	AuthHandler            *auth.Handler
 	CustomTemplatesHandler *customtemplates.Handler
 	DockerHubHandler       *dockerhub.Handler
+	EdgeEnrollmentHandler  *edgeenrollment.Handler
 	EdgeGroupsHandler      *edgegroups.Handler
 	EdgeJobsHandler        *edgejobs.Handler
 	EdgeStacksHandler      *edgestacks.Handler
@@ -47,6 +52,9 @@ type Handler struct {
 	EndpointHandler        *endpoints.Handler
 	EndpointProxyHandler   *endpointproxy.Handler
 	FileHandler            *file.Handler
+	HousekeepingHandler    *housekeeping.Handler
+	ImageScanHandler       *imagescans.Handler
+	KubernetesHandler      *kubernetes.Handler
 	MOTDHandler            *motd.Handler
 	RegistryHandler        *registries.Handler
 	ResourceControlHandler *resourcecontrols.Handler
@@ -75,6 +83,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.StripPrefix("/api", h.CustomTemplatesHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/edge_stacks"):
 		http.StripPrefix("/api", h.EdgeStacksHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/edge_enrollment"):
+		http.StripPrefix("/api", h.EdgeEnrollmentHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/edge_groups"):
 		http.StripPrefix("/api", h.EdgeGroupsHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/edge_jobs"):
@@ -100,6 +110,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		default:
 			http.StripPrefix("/api", h.EndpointHandler).ServeHTTP(w, r)
 		}
+	case strings.HasPrefix(r.URL.Path, "/api/housekeeping_policies"):
+		http.StripPrefix("/api", h.HousekeepingHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/image_scans"):
+		http.StripPrefix("/api", h.ImageScanHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/kubernetes"):
+		http.StripPrefix("/api", h.KubernetesHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/motd"):
 		http.StripPrefix("/api", h.MOTDHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/registries"):
@@ -0,0 +1,40 @@
+package housekeeping
+
+import (
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/gorilla/mux"
+	httperror "github.com/portainer/libhttp/error"
+)
+
+// Handler is the HTTP handler used to handle housekeeping policy operations.
+type Handler struct {
+	*mux.Router
+	DataStore           portainer.DataStore
+	HousekeepingService portainer.HousekeepingService
+}
+
+// NewHandler creates a handler to manage housekeeping policy operations.
+func NewHandler(bouncer *security.RequestBouncer) *Handler {
+	h := &Handler{
+		Router: mux.NewRouter(),
+	}
+
+	h.Handle("/housekeeping_policies",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.policyList))).Methods(http.MethodGet)
+	h.Handle("/housekeeping_policies",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.policyCreate))).Methods(http.MethodPost)
+	h.Handle("/housekeeping_policies/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.policyInspect))).Methods(http.MethodGet)
+	h.Handle("/housekeeping_policies/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.policyUpdate))).Methods(http.MethodPut)
+	h.Handle("/housekeeping_policies/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.policyDelete))).Methods(http.MethodDelete)
+	h.Handle("/housekeeping_policies/{id}/run",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.policyRun))).Methods(http.MethodPost)
+	h.Handle("/housekeeping_policies/{id}/executions/{executionId}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.policyExecutionInspect))).Methods(http.MethodGet)
+	return h
+}
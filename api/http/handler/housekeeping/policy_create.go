@@ -0,0 +1,111 @@
+package housekeeping
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/cloudogu/portainer-ce/api"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	"github.com/robfig/cron/v3"
+)
+
+type policyCreatePayload struct {
+	Name             string
+	CronExpression   string
+	EndpointIDs      []portainer.EndpointID
+	EndpointGroupIDs []portainer.EndpointGroupID
+	PruneImages      bool
+	PruneAllImages   bool
+	PruneVolumes     bool
+	PruneNetworks    bool
+	PruneBuildCache  bool
+	Labels           []portainer.Pair
+	OlderThan        string
+	DryRun           bool
+	Enabled          bool
+}
+
+func (payload *policyCreatePayload) Validate(r *http.Request) error {
+	if govalidator.IsNull(payload.Name) {
+		return errors.New("Invalid housekeeping policy name")
+	}
+	return nil
+}
+
+// POST request on /api/housekeeping_policies
+func (handler *Handler) policyCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload policyCreatePayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	policy := &portainer.HousekeepingPolicy{
+		Name:             payload.Name,
+		CronExpression:   payload.CronExpression,
+		EndpointIDs:      payload.EndpointIDs,
+		EndpointGroupIDs: payload.EndpointGroupIDs,
+		PruneImages:      payload.PruneImages,
+		PruneAllImages:   payload.PruneAllImages,
+		PruneVolumes:     payload.PruneVolumes,
+		PruneNetworks:    payload.PruneNetworks,
+		PruneBuildCache:  payload.PruneBuildCache,
+		Labels:           payload.Labels,
+		OlderThan:        payload.OlderThan,
+		DryRun:           payload.DryRun,
+		Enabled:          payload.Enabled,
+		Created:          time.Now().Unix(),
+		History:          []portainer.HousekeepingRun{},
+	}
+
+	err = validatePolicy(policy)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid housekeeping policy", err}
+	}
+
+	err = handler.DataStore.HousekeepingPolicy().CreateHousekeepingPolicy(policy)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the housekeeping policy inside the database", err}
+	}
+
+	err = handler.HousekeepingService.SchedulePolicy(policy)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to schedule the housekeeping policy", err}
+	}
+
+	return response.JSON(w, policy)
+}
+
+func validatePolicy(policy *portainer.HousekeepingPolicy) error {
+	_, err := cron.ParseStandard(policy.CronExpression)
+	if err != nil {
+		return errors.New("Invalid cron expression")
+	}
+
+	if len(policy.EndpointIDs) == 0 && len(policy.EndpointGroupIDs) == 0 {
+		return errors.New("At least one endpoint or endpoint group is required")
+	}
+
+	if !policy.PruneImages && !policy.PruneVolumes && !policy.PruneNetworks && !policy.PruneBuildCache {
+		return errors.New("At least one prune operation is required")
+	}
+
+	if policy.OlderThan != "" {
+		olderThan, err := time.ParseDuration(policy.OlderThan)
+		if err != nil || olderThan < 0 {
+			return errors.New("Invalid older than duration. Must be a positive duration such as 24h")
+		}
+	}
+
+	for _, label := range policy.Labels {
+		if govalidator.IsNull(label.Name) {
+			return errors.New("Invalid label filter name")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package housekeeping
+
+import (
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validatePolicy(t *testing.T) {
+	validPolicy := func() *portainer.HousekeepingPolicy {
+		return &portainer.HousekeepingPolicy{
+			Name:           "policy",
+			CronExpression: "0 3 * * *",
+			EndpointIDs:    []portainer.EndpointID{1},
+			PruneImages:    true,
+			OlderThan:      "24h",
+			Labels:         []portainer.Pair{{Name: "com.example.env", Value: "ci"}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		update func(policy *portainer.HousekeepingPolicy)
+		valid  bool
+	}{
+		{"a valid policy is accepted", func(policy *portainer.HousekeepingPolicy) {}, true},
+		{"a policy targeting an endpoint group is accepted", func(policy *portainer.HousekeepingPolicy) {
+			policy.EndpointIDs = nil
+			policy.EndpointGroupIDs = []portainer.EndpointGroupID{2}
+		}, true},
+		{"a policy without age filter is accepted", func(policy *portainer.HousekeepingPolicy) { policy.OlderThan = "" }, true},
+		{"an invalid cron expression is rejected", func(policy *portainer.HousekeepingPolicy) { policy.CronExpression = "every day" }, false},
+		{"an empty cron expression is rejected", func(policy *portainer.HousekeepingPolicy) { policy.CronExpression = "" }, false},
+		{"a policy without target is rejected", func(policy *portainer.HousekeepingPolicy) { policy.EndpointIDs = nil }, false},
+		{"a policy without prune operation is rejected", func(policy *portainer.HousekeepingPolicy) { policy.PruneImages = false }, false},
+		{"an invalid older than duration is rejected", func(policy *portainer.HousekeepingPolicy) { policy.OlderThan = "1 day" }, false},
+		{"a negative older than duration is rejected", func(policy *portainer.HousekeepingPolicy) { policy.OlderThan = "-24h" }, false},
+		{"a label filter without name is rejected", func(policy *portainer.HousekeepingPolicy) {
+			policy.Labels = []portainer.Pair{{Value: "ci"}}
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := validPolicy()
+			test.update(policy)
+
+			err := validatePolicy(policy)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
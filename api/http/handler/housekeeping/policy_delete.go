@@ -0,0 +1,35 @@
+package housekeeping
+
+import (
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// DELETE request on /api/housekeeping_policies/:id
+func (handler *Handler) policyDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	policyID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid housekeeping policy identifier route variable", err}
+	}
+
+	policy, err := handler.DataStore.HousekeepingPolicy().HousekeepingPolicy(portainer.HousekeepingPolicyID(policyID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find a housekeeping policy with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a housekeeping policy with the specified identifier inside the database", err}
+	}
+
+	handler.HousekeepingService.UnschedulePolicy(policy.ID)
+
+	err = handler.DataStore.HousekeepingPolicy().DeleteHousekeepingPolicy(policy.ID)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove the housekeeping policy from the database", err}
+	}
+
+	return response.Empty(w)
+}
@@ -0,0 +1,32 @@
+package housekeeping
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/housekeeping_policies/:id/executions/:executionId
+// Retrieves a manual execution of the policy. Completed executions are kept for an hour.
+func (handler *Handler) policyExecutionInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	policyID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid housekeeping policy identifier route variable", err}
+	}
+
+	executionID, err := request.RetrieveRouteVariableValue(r, "executionId")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid housekeeping execution identifier route variable", err}
+	}
+
+	execution := handler.HousekeepingService.PolicyExecution(portainer.HousekeepingPolicyID(policyID), executionID)
+	if execution == nil {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find a housekeeping execution with the specified identifier", errors.New("Housekeeping execution not found")}
+	}
+
+	return response.JSON(w, execution)
+}
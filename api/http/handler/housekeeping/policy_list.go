@@ -0,0 +1,18 @@
+package housekeeping
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/housekeeping_policies
+func (handler *Handler) policyList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	policies, err := handler.DataStore.HousekeepingPolicy().HousekeepingPolicies()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve housekeeping policies from the database", err}
+	}
+
+	return response.JSON(w, policies)
+}
@@ -0,0 +1,31 @@
+package housekeeping
+
+import (
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// POST request on /api/housekeeping_policies/:id/run
+// Executes the policy in the background on all its endpoints and returns the execution, whose runs can be
+// retrieved on /api/housekeeping_policies/:id/executions/:executionId once it is completed. The runs are also
+// recorded in the policy history.
+func (handler *Handler) policyRun(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	policyID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid housekeeping policy identifier route variable", err}
+	}
+
+	execution, err := handler.HousekeepingService.StartPolicyExecution(portainer.HousekeepingPolicyID(policyID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find a housekeeping policy with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to execute the housekeeping policy", err}
+	}
+
+	return response.JSON(w, execution)
+}
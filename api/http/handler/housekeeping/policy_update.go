@@ -0,0 +1,125 @@
+package housekeeping
+
+import (
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type policyUpdatePayload struct {
+	Name             *string
+	CronExpression   *string
+	EndpointIDs      []portainer.EndpointID
+	EndpointGroupIDs []portainer.EndpointGroupID
+	PruneImages      *bool
+	PruneAllImages   *bool
+	PruneVolumes     *bool
+	PruneNetworks    *bool
+	PruneBuildCache  *bool
+	Labels           []portainer.Pair
+	OlderThan        *string
+	DryRun           *bool
+	Enabled          *bool
+}
+
+func (payload *policyUpdatePayload) Validate(r *http.Request) error {
+	return nil
+}
+
+// PUT request on /api/housekeeping_policies/:id
+func (handler *Handler) policyUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	policyID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid housekeeping policy identifier route variable", err}
+	}
+
+	var payload policyUpdatePayload
+	err = request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	policy, err := handler.DataStore.HousekeepingPolicy().HousekeepingPolicy(portainer.HousekeepingPolicyID(policyID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find a housekeeping policy with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a housekeeping policy with the specified identifier inside the database", err}
+	}
+
+	updatePolicy(policy, &payload)
+
+	err = validatePolicy(policy)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid housekeeping policy", err}
+	}
+
+	err = handler.DataStore.HousekeepingPolicy().UpdateHousekeepingPolicy(policy.ID, policy)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist housekeeping policy changes inside the database", err}
+	}
+
+	err = handler.HousekeepingService.SchedulePolicy(policy)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to schedule the housekeeping policy", err}
+	}
+
+	return response.JSON(w, policy)
+}
+
+func updatePolicy(policy *portainer.HousekeepingPolicy, payload *policyUpdatePayload) {
+	if payload.Name != nil && *payload.Name != "" {
+		policy.Name = *payload.Name
+	}
+
+	if payload.CronExpression != nil {
+		policy.CronExpression = *payload.CronExpression
+	}
+
+	if payload.EndpointIDs != nil {
+		policy.EndpointIDs = payload.EndpointIDs
+	}
+
+	if payload.EndpointGroupIDs != nil {
+		policy.EndpointGroupIDs = payload.EndpointGroupIDs
+	}
+
+	if payload.PruneImages != nil {
+		policy.PruneImages = *payload.PruneImages
+	}
+
+	if payload.PruneAllImages != nil {
+		policy.PruneAllImages = *payload.PruneAllImages
+	}
+
+	if payload.PruneVolumes != nil {
+		policy.PruneVolumes = *payload.PruneVolumes
+	}
+
+	if payload.PruneNetworks != nil {
+		policy.PruneNetworks = *payload.PruneNetworks
+	}
+
+	if payload.PruneBuildCache != nil {
+		policy.PruneBuildCache = *payload.PruneBuildCache
+	}
+
+	if payload.Labels != nil {
+		policy.Labels = payload.Labels
+	}
+
+	if payload.OlderThan != nil {
+		policy.OlderThan = *payload.OlderThan
+	}
+
+	if payload.DryRun != nil {
+		policy.DryRun = *payload.DryRun
+	}
+
+	if payload.Enabled != nil {
+		policy.Enabled = *payload.Enabled
+	}
+}
@@ -0,0 +1,30 @@
+package imagescans
+
+import (
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/gorilla/mux"
+	httperror "github.com/portainer/libhttp/error"
+)
+
+// Handler is the HTTP handler used to handle image vulnerability scan operations.
+type Handler struct {
+	*mux.Router
+	DataStore                portainer.DataStore
+	VulnerabilityScanService portainer.VulnerabilityScanService
+}
+
+// NewHandler creates a handler to manage image vulnerability scan operations.
+func NewHandler(bouncer *security.RequestBouncer) *Handler {
+	h := &Handler{
+		Router: mux.NewRouter(),
+	}
+
+	h.Handle("/image_scans",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.imageScanList))).Methods(http.MethodGet)
+	h.Handle("/image_scans",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.imageScanCreate))).Methods(http.MethodPost)
+	return h
+}
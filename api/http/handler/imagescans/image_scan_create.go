@@ -0,0 +1,50 @@
+package imagescans
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type imageScanCreatePayload struct {
+	Image string
+	Force bool
+}
+
+func (payload *imageScanCreatePayload) Validate(r *http.Request) error {
+	if govalidator.IsNull(payload.Image) {
+		return errors.New("Invalid image")
+	}
+	return nil
+}
+
+// POST request on /api/image_scans
+// Scans an image for vulnerabilities. The previous scan of the image digest is returned
+// when it is recent enough, unless Force is specified.
+func (handler *Handler) imageScanCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload imageScanCreatePayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve the settings from the database", err}
+	}
+
+	if !settings.ImageScanSettings.Enabled {
+		return &httperror.HandlerError{http.StatusBadRequest, "Image vulnerability scanning is not enabled", errors.New("Image vulnerability scanning is not enabled")}
+	}
+
+	scan, err := handler.VulnerabilityScanService.ScanImage(payload.Image, payload.Force)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to scan the image", err}
+	}
+
+	return response.JSON(w, scan)
+}
@@ -0,0 +1,18 @@
+package imagescans
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/image_scans
+func (handler *Handler) imageScanList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	scans, err := handler.DataStore.ImageScan().ImageScans()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve image scans from the database", err}
+	}
+
+	return response.JSON(w, scans)
+}
@@ -0,0 +1,33 @@
+package kubernetes
+
+import (
+	"net/http"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/gorilla/mux"
+	httperror "github.com/portainer/libhttp/error"
+)
+
+// Handler is the HTTP handler used to handle Kubernetes operations.
+type Handler struct {
+	*mux.Router
+	requestBouncer *security.RequestBouncer
+	DataStore      portainer.DataStore
+	JWTService     portainer.JWTService
+	FileService    portainer.FileService
+	TrustedProxies security.TrustedProxies
+	// SSLCert is the path of the certificate served by Portainer, empty when SSL is not enabled
+	SSLCert string
+}
+
+// NewHandler creates a handler to manage Kubernetes operations.
+func NewHandler(bouncer *security.RequestBouncer) *Handler {
+	h := &Handler{
+		Router:         mux.NewRouter(),
+		requestBouncer: bouncer,
+	}
+	h.Handle("/kubernetes/config",
+		bouncer.RestrictedAccess(httperror.LoggerHandler(h.kubernetesConfig))).Methods(http.MethodGet)
+	return h
+}
@@ -0,0 +1,173 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	clientcmd "k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// GET request on /api/kubernetes/config?(ids=<ids>)&(insecure=<insecure>)
+// Generates a kubeconfig file containing one context per authorized Kubernetes endpoint.
+// Each context points to the Kubernetes proxy of the endpoint inside Portainer and uses a
+// Portainer token that expires with the user session. The certificate served by Portainer is
+// embedded when it is reached directly over SSL, TLS verification is only disabled with insecure.
+func (handler *Handler) kubernetesConfig(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	tokenData, err := security.RetrieveTokenData(r)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusForbidden, "Permission denied to access endpoint", err}
+	}
+
+	var endpointIDs []portainer.EndpointID
+	err = request.RetrieveJSONQueryParameter(r, "ids", &endpointIDs, true)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid query parameter: ids", err}
+	}
+
+	insecure, _ := request.RetrieveBooleanQueryParameter(r, "insecure", true)
+
+	endpoints, handlerErr := handler.authorizedKubernetesEndpoints(r, endpointIDs)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	if len(endpoints) == 0 {
+		return &httperror.HandlerError{http.StatusNotFound, "No authorized Kubernetes endpoint found", errors.New("No Kubernetes endpoint available")}
+	}
+
+	token, err := handler.JWTService.GenerateToken(&portainer.TokenData{
+		ID:       tokenData.ID,
+		Username: tokenData.Username,
+		Role:     tokenData.Role,
+	})
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to generate access token", err}
+	}
+
+	baseURL, forwarded := handler.serverBaseURL(r)
+
+	cluster := &clientcmdapi.Cluster{InsecureSkipTLSVerify: insecure}
+	if !insecure && !forwarded && r.TLS != nil && handler.SSLCert != "" {
+		cluster.CertificateAuthorityData, err = handler.FileService.GetFileContent(handler.SSLCert)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to read the SSL certificate", err}
+		}
+	}
+
+	config := buildKubeconfig(baseURL, tokenData.Username, token, cluster, endpoints)
+
+	content, err := clientcmd.Write(*config)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to generate kubeconfig file", err}
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", `attachment; filename="config"`)
+	w.Write(content)
+	return nil
+}
+
+func (handler *Handler) authorizedKubernetesEndpoints(r *http.Request, endpointIDs []portainer.EndpointID) ([]portainer.Endpoint, *httperror.HandlerError) {
+	if len(endpointIDs) == 0 {
+		endpoints, err := handler.DataStore.Endpoint().Endpoints()
+		if err != nil {
+			return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve endpoints from the database", err}
+		}
+
+		for _, endpoint := range endpoints {
+			endpointIDs = append(endpointIDs, endpoint.ID)
+		}
+	}
+
+	authorizedEndpoints := make([]portainer.Endpoint, 0)
+	for _, endpointID := range endpointIDs {
+		endpoint, err := handler.DataStore.Endpoint().Endpoint(endpointID)
+		if err == bolterrors.ErrObjectNotFound {
+			return nil, &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
+		} else if err != nil {
+			return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
+		}
+
+		if !isKubernetesEndpoint(endpoint) {
+			continue
+		}
+
+		err = handler.requestBouncer.AuthorizedEndpointOperation(r, endpoint)
+		if err != nil {
+			continue
+		}
+
+		authorizedEndpoints = append(authorizedEndpoints, *endpoint)
+	}
+
+	return authorizedEndpoints, nil
+}
+
+func isKubernetesEndpoint(endpoint *portainer.Endpoint) bool {
+	switch endpoint.Type {
+	case portainer.KubernetesLocalEnvironment, portainer.AgentOnKubernetesEnvironment, portainer.EdgeAgentOnKubernetesEnvironment:
+		return true
+	}
+	return false
+}
+
+// serverBaseURL returns the URL used by clients to reach the Portainer instance. The reverse proxy
+// headers are only taken into account for trusted proxies, forwarded is true when one of them is used.
+func (handler *Handler) serverBaseURL(r *http.Request) (string, bool) {
+	forwarded := false
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if forwardedProto := handler.TrustedProxies.ForwardedHeader(r, "X-Forwarded-Proto"); forwardedProto == "http" || forwardedProto == "https" {
+		scheme = forwardedProto
+		forwarded = true
+	}
+
+	host := r.Host
+	if forwardedHost := handler.TrustedProxies.ForwardedHeader(r, "X-Forwarded-Host"); forwardedHost != "" {
+		host = forwardedHost
+		forwarded = true
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, host), forwarded
+}
+
+// buildKubeconfig creates a context per endpoint, the clusters share the TLS settings of the cluster template
+func buildKubeconfig(baseURL, username, token string, clusterTemplate *clientcmdapi.Cluster, endpoints []portainer.Endpoint) *clientcmdapi.Config {
+	authInfoName := fmt.Sprintf("portainer-%s", username)
+
+	config := clientcmdapi.NewConfig()
+	config.AuthInfos[authInfoName] = &clientcmdapi.AuthInfo{
+		Token: token,
+	}
+
+	for idx, endpoint := range endpoints {
+		name := fmt.Sprintf("portainer-%d-%s", endpoint.ID, endpoint.Name)
+
+		config.Clusters[name] = &clientcmdapi.Cluster{
+			Server:                   fmt.Sprintf("%s/api/endpoints/%d/kubernetes", baseURL, endpoint.ID),
+			InsecureSkipTLSVerify:    clusterTemplate.InsecureSkipTLSVerify,
+			CertificateAuthorityData: clusterTemplate.CertificateAuthorityData,
+		}
+
+		config.Contexts[name] = &clientcmdapi.Context{
+			Cluster:  name,
+			AuthInfo: authInfoName,
+		}
+
+		if idx == 0 {
+			config.CurrentContext = name
+		}
+	}
+
+	return config
+}
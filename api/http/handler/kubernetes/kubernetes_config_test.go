@@ -0,0 +1,48 @@
+package kubernetes
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/stretchr/testify/assert"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func Test_serverBaseURL(t *testing.T) {
+	trustedProxies, err := security.ParseTrustedProxies([]string{"10.0.0.1"})
+	assert.NoError(t, err)
+	handler := &Handler{TrustedProxies: trustedProxies}
+
+	r := httptest.NewRequest("GET", "/api/kubernetes/config", nil)
+	r.Host = "portainer.example.com:9443"
+	r.TLS = &tls.ConnectionState{}
+	r.RemoteAddr = "203.0.113.5:4000"
+	r.Header.Set("X-Forwarded-Proto", "http")
+	r.Header.Set("X-Forwarded-Host", "attacker.example.com")
+
+	baseURL, forwarded := handler.serverBaseURL(r)
+	assert.Equal(t, "https://portainer.example.com:9443", baseURL)
+	assert.False(t, forwarded)
+
+	r.RemoteAddr = "10.0.0.1:4000"
+	baseURL, forwarded = handler.serverBaseURL(r)
+	assert.Equal(t, "http://attacker.example.com", baseURL)
+	assert.True(t, forwarded)
+}
+
+func Test_buildKubeconfig(t *testing.T) {
+	endpoints := []portainer.Endpoint{{ID: 1, Name: "local"}, {ID: 2, Name: "prod"}}
+	cluster := &clientcmdapi.Cluster{CertificateAuthorityData: []byte("certificate")}
+
+	config := buildKubeconfig("https://portainer.example.com", "admin", "token", cluster, endpoints)
+
+	assert.Equal(t, "portainer-1-local", config.CurrentContext)
+	assert.Len(t, config.Clusters, 2)
+	assert.Equal(t, "https://portainer.example.com/api/endpoints/2/kubernetes", config.Clusters["portainer-2-prod"].Server)
+	assert.False(t, config.Clusters["portainer-2-prod"].InsecureSkipTLSVerify)
+	assert.Equal(t, []byte("certificate"), config.Clusters["portainer-2-prod"].CertificateAuthorityData)
+	assert.Equal(t, "token", config.AuthInfos["portainer-admin"].Token)
+}
@@ -13,15 +13,22 @@ import (
 func hideFields(registry *portainer.Registry) {
 	registry.Password = ""
 	registry.ManagementConfiguration = nil
+	if registry.Ecr != nil {
+		registry.Ecr.SecretAccessKey = ""
+	}
+	if registry.Acr != nil {
+		registry.Acr.AuthenticationKey = ""
+	}
 }
 
 // Handler is the HTTP handler used to handle registry operations.
 type Handler struct {
 	*mux.Router
-	requestBouncer *security.RequestBouncer
-	DataStore      portainer.DataStore
-	FileService    portainer.FileService
-	ProxyManager   *proxy.Manager
+	requestBouncer   *security.RequestBouncer
+	DataStore        portainer.DataStore
+	FileService      portainer.FileService
+	ProxyManager     *proxy.Manager
+	RetentionService portainer.RegistryRetentionService
 }
 
 // NewHandler creates a handler to manage registry operations.
@@ -43,6 +50,24 @@ func NewHandler(bouncer *security.RequestBouncer) *Handler {
 		bouncer.AdminAccess(httperror.LoggerHandler(h.registryConfigure))).Methods(http.MethodPost)
 	h.Handle("/registries/{id}",
 		bouncer.AdminAccess(httperror.LoggerHandler(h.registryDelete))).Methods(http.MethodDelete)
+	h.Handle("/registries/{id}/repositories",
+		bouncer.RestrictedAccess(httperror.LoggerHandler(h.registryRepositoryList))).Methods(http.MethodGet)
+	h.Handle("/registries/{id}/repositories/{repository:.+}/tags",
+		bouncer.RestrictedAccess(httperror.LoggerHandler(h.registryTagList))).Methods(http.MethodGet)
+	h.Handle("/registries/{id}/repositories/{repository:.+}/tags/{tag}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.registryTagDelete))).Methods(http.MethodDelete)
+	h.Handle("/registries/{id}/repositories/{repository:.+}/manifests/{reference}",
+		bouncer.RestrictedAccess(httperror.LoggerHandler(h.registryManifestInspect))).Methods(http.MethodGet)
+	h.Handle("/registries/{id}/repositories/{repository:.+}/manifests/{reference}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.registryManifestDelete))).Methods(http.MethodDelete)
+	h.Handle("/registries/{id}/retention",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.registryRetentionInspect))).Methods(http.MethodGet)
+	h.Handle("/registries/{id}/retention",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.registryRetentionUpdate))).Methods(http.MethodPut)
+	h.Handle("/registries/{id}/retention/plan",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.registryRetentionPlan))).Methods(http.MethodPost)
+	h.Handle("/registries/{id}/retention/execute",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.registryRetentionExecute))).Methods(http.MethodPost)
 	h.PathPrefix("/registries/proxies/gitlab").Handler(
 		bouncer.AdminAccess(httperror.LoggerHandler(h.proxyRequestsToGitlabAPIWithoutRegistry)))
 	return h
@@ -0,0 +1,89 @@
+package registries
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/cloudogu/portainer-ce/api/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_registryBrowseAuthorizations(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	deleted := false
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodDelete:
+			deleted = true
+			w.WriteHeader(http.StatusAccepted)
+		case r.URL.Path == "/v2/app/tags/list":
+			fmt.Fprint(w, `{"name":"app","tags":["latest"]}`)
+		case r.URL.Path == "/v2/app/manifests/latest":
+			w.Header().Set("Docker-Content-Digest", "sha256:1111")
+			fmt.Fprint(w, `{"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registryServer.Close()
+
+	jwtService, err := jwt.NewService("1h")
+	assert.NoError(t, err)
+
+	users := []*portainer.User{
+		{ID: 1, Username: "admin", Role: portainer.AdministratorRole},
+		{ID: 2, Username: "member", Role: portainer.StandardUserRole},
+		{ID: 3, Username: "outsider", Role: portainer.StandardUserRole},
+	}
+	tokens := make(map[string]string)
+	for _, user := range users {
+		assert.NoError(t, store.User().CreateUser(user))
+		tokens[user.Username], err = jwtService.GenerateToken(&portainer.TokenData{ID: user.ID, Username: user.Username, Role: user.Role})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, store.Registry().CreateRegistry(&portainer.Registry{
+		Name:               "registry",
+		URL:                registryServer.URL,
+		UserAccessPolicies: portainer.UserAccessPolicies{2: {}},
+		TeamAccessPolicies: portainer.TeamAccessPolicies{},
+	}))
+
+	handler := NewHandler(security.NewRequestBouncer(store, jwtService))
+	handler.DataStore = store
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		username       string
+		expectedStatus int
+	}{
+		{"a user with access to the registry can list the tags", http.MethodGet, "/registries/1/repositories/app/tags", "member", http.StatusOK},
+		{"a user without access to the registry cannot list the tags", http.MethodGet, "/registries/1/repositories/app/tags", "outsider", http.StatusForbidden},
+		{"a user with access to the registry cannot delete a tag", http.MethodDelete, "/registries/1/repositories/app/tags/latest", "member", http.StatusForbidden},
+		{"a user with access to the registry cannot delete a manifest", http.MethodDelete, "/registries/1/repositories/app/manifests/latest", "member", http.StatusForbidden},
+		{"an administrator can delete a tag", http.MethodDelete, "/registries/1/repositories/app/tags/latest", "admin", http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deleted = false
+
+			req := httptest.NewRequest(test.method, test.url, nil)
+			req.Header.Set("Authorization", "Bearer "+tokens[test.username])
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code, rr.Body.String())
+			assert.Equal(t, test.expectedStatus == http.StatusNoContent, deleted)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package registries
+
+import (
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/http/errors"
+	"github.com/cloudogu/portainer-ce/api/registry"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type registryTagsResponse struct {
+	Name string   `json:"Name"`
+	Tags []string `json:"Tags"`
+}
+
+// GET request on /api/registries/:id/repositories
+func (handler *Handler) registryRepositoryList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	client, handlerErr := handler.registryClient(r)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	repositories, err := client.Catalog()
+	if err != nil {
+		return registryError("Unable to retrieve the repositories of the registry", err)
+	}
+
+	return response.JSON(w, repositories)
+}
+
+// GET request on /api/registries/:id/repositories/:repository/tags
+func (handler *Handler) registryTagList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	client, handlerErr := handler.registryClient(r)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	repository, _ := request.RetrieveRouteVariableValue(r, "repository")
+
+	tags, err := client.Tags(repository)
+	if err != nil {
+		return registryError("Unable to retrieve the tags of the repository", err)
+	}
+
+	return response.JSON(w, &registryTagsResponse{Name: repository, Tags: tags})
+}
+
+// GET request on /api/registries/:id/repositories/:repository/manifests/:reference
+// The reference is a tag or a digest. The manifests of the platform images are included for an image index.
+func (handler *Handler) registryManifestInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	client, handlerErr := handler.registryClient(r)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	repository, _ := request.RetrieveRouteVariableValue(r, "repository")
+	reference, _ := request.RetrieveRouteVariableValue(r, "reference")
+
+	manifest, err := client.Manifest(repository, reference)
+	if err != nil {
+		return registryError("Unable to retrieve the manifest", err)
+	}
+
+	return response.JSON(w, manifest)
+}
+
+// DELETE request on /api/registries/:id/repositories/:repository/manifests/:reference
+// Deletes a manifest and every tag referencing it. The reference is a tag or a digest.
+// Restricted to administrators, the access to a registry only grants the right to pull its images.
+func (handler *Handler) registryManifestDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	client, handlerErr := handler.registryClient(r)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	repository, _ := request.RetrieveRouteVariableValue(r, "repository")
+	reference, _ := request.RetrieveRouteVariableValue(r, "reference")
+
+	err := client.DeleteManifest(repository, reference)
+	if err != nil {
+		return registryError("Unable to delete the manifest", err)
+	}
+
+	return response.Empty(w)
+}
+
+// DELETE request on /api/registries/:id/repositories/:repository/tags/:tag
+// Deletes a tag without deleting the other tags referencing the same manifest.
+// Restricted to administrators, the access to a registry only grants the right to pull its images.
+func (handler *Handler) registryTagDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	client, handlerErr := handler.registryClient(r)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	repository, _ := request.RetrieveRouteVariableValue(r, "repository")
+	tag, _ := request.RetrieveRouteVariableValue(r, "tag")
+
+	err := client.DeleteTag(repository, tag)
+	if err != nil {
+		return registryError("Unable to delete the tag", err)
+	}
+
+	return response.Empty(w)
+}
+
+// registryClient returns a client for the registry of the request, after verifying
+// that the user of the request is authorized to access the registry
+func (handler *Handler) registryClient(r *http.Request) (*registry.Client, *httperror.HandlerError) {
+	registryID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, &httperror.HandlerError{http.StatusBadRequest, "Invalid registry identifier route variable", err}
+	}
+
+	reg, err := handler.DataStore.Registry().Registry(portainer.RegistryID(registryID))
+	if err == bolterrors.ErrObjectNotFound {
+		return nil, &httperror.HandlerError{http.StatusNotFound, "Unable to find a registry with the specified identifier inside the database", err}
+	} else if err != nil {
+		return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a registry with the specified identifier inside the database", err}
+	}
+
+	err = handler.requestBouncer.RegistryAccess(r, reg)
+	if err != nil {
+		return nil, &httperror.HandlerError{http.StatusForbidden, "Permission denied to access registry", errors.ErrRegistryAccessDenied}
+	}
+
+	client, err := registry.NewClientForRegistry(reg)
+	if err != nil {
+		return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to create a client for the registry", err}
+	}
+
+	return client, nil
+}
+
+func registryError(message string, err error) *httperror.HandlerError {
+	switch err.(type) {
+	case *registry.SharedManifestError:
+		return &httperror.HandlerError{http.StatusConflict, message, err}
+	}
+
+	switch err {
+	case registry.ErrNotFound:
+		return &httperror.HandlerError{http.StatusNotFound, message, err}
+	case registry.ErrDeletionDisabled:
+		return &httperror.HandlerError{http.StatusMethodNotAllowed, message, err}
+	}
+
+	return &httperror.HandlerError{http.StatusBadGateway, message, err}
+}
@@ -19,6 +19,10 @@ type registryCreatePayload struct {
 	Username       string
 	Password       string
 	Gitlab         portainer.GitlabRegistryData
+	// AWS credentials of an ECR registry
+	Ecr *portainer.EcrRegistryData
+	// Azure service principal of an ACR registry
+	Acr *portainer.AzureCredentials
 }
 
 func (payload *registryCreatePayload) Validate(r *http.Request) error {
@@ -31,8 +35,18 @@ func (payload *registryCreatePayload) Validate(r *http.Request) error {
 	if payload.Authentication && (govalidator.IsNull(payload.Username) || govalidator.IsNull(payload.Password)) {
 		return errors.New("Invalid credentials. Username and password must be specified when authentication is enabled")
 	}
-	if payload.Type != portainer.QuayRegistry && payload.Type != portainer.AzureRegistry && payload.Type != portainer.CustomRegistry && payload.Type != portainer.GitlabRegistry {
-		return errors.New("Invalid registry type. Valid values are: 1 (Quay.io), 2 (Azure container registry), 3 (custom registry) or 4 (Gitlab registry)")
+	switch payload.Type {
+	case portainer.QuayRegistry, portainer.AzureRegistry, portainer.CustomRegistry, portainer.GitlabRegistry:
+	case portainer.EcrRegistry:
+		if payload.Ecr == nil || govalidator.IsNull(payload.Ecr.AccessKeyID) || govalidator.IsNull(payload.Ecr.SecretAccessKey) {
+			return errors.New("Invalid AWS credentials. Access key ID and secret access key must be specified for an ECR registry")
+		}
+	case portainer.AcrRegistry:
+		if payload.Acr == nil || govalidator.IsNull(payload.Acr.TenantID) || govalidator.IsNull(payload.Acr.ApplicationID) || govalidator.IsNull(payload.Acr.AuthenticationKey) {
+			return errors.New("Invalid Azure credentials. Tenant ID, application ID and authentication key must be specified for an ACR registry")
+		}
+	default:
+		return errors.New("Invalid registry type. Valid values are: 1 (Quay.io), 2 (Azure container registry), 3 (custom registry), 4 (Gitlab registry), 5 (AWS ECR) or 6 (Azure container registry with service principal)")
 	}
 	return nil
 }
@@ -56,6 +70,19 @@ func (handler *Handler) registryCreate(w http.ResponseWriter, r *http.Request) *
 		Gitlab:             payload.Gitlab,
 	}
 
+	switch registry.Type {
+	case portainer.EcrRegistry:
+		registry.Authentication = true
+		registry.Username = ""
+		registry.Password = ""
+		registry.Ecr = payload.Ecr
+	case portainer.AcrRegistry:
+		registry.Authentication = true
+		registry.Username = ""
+		registry.Password = ""
+		registry.Acr = payload.Acr
+	}
+
 	err = handler.DataStore.Registry().CreateRegistry(registry)
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the registry inside the database", err}
@@ -0,0 +1,118 @@
+package registries
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/registryretention"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type registryRetentionUpdatePayload struct {
+	// Execute the policies periodically
+	Enabled bool
+	// Retention policies of the registry, a policy without repository applies to the repositories without policy
+	Policies []portainer.RegistryRetentionPolicy
+}
+
+func (payload *registryRetentionUpdatePayload) Validate(r *http.Request) error {
+	if payload.Enabled && len(payload.Policies) == 0 {
+		return errors.New("Invalid retention policies. At least one policy is required to enable the retention")
+	}
+	return registryretention.ValidatePolicies(payload.Policies)
+}
+
+// GET request on /api/registries/:id/retention
+func (handler *Handler) registryRetentionInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	registry, handlerErr := handler.retentionRegistry(r)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	if registry.Retention == nil {
+		return response.JSON(w, &portainer.RegistryRetention{Policies: []portainer.RegistryRetentionPolicy{}})
+	}
+
+	return response.JSON(w, registry.Retention)
+}
+
+// PUT request on /api/registries/:id/retention
+func (handler *Handler) registryRetentionUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	registry, handlerErr := handler.retentionRegistry(r)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	var payload registryRetentionUpdatePayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	if payload.Enabled && registry.ManagementConfiguration == nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "The registry must be configured before enabling the retention", errors.New("Missing management configuration")}
+	}
+
+	if registry.Retention == nil {
+		registry.Retention = &portainer.RegistryRetention{}
+	}
+	registry.Retention.Enabled = payload.Enabled
+	registry.Retention.Policies = payload.Policies
+
+	err = handler.DataStore.Registry().UpdateRegistry(registry.ID, registry)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist registry changes inside the database", err}
+	}
+
+	return response.JSON(w, registry.Retention)
+}
+
+// POST request on /api/registries/:id/retention/plan
+// Applies the retention policies of the registry without deleting any tag and returns the report
+func (handler *Handler) registryRetentionPlan(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	return handler.runRetention(w, r, true)
+}
+
+// POST request on /api/registries/:id/retention/execute
+// Deletes the tags selected by the retention policies of the registry and returns the report
+func (handler *Handler) registryRetentionExecute(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	return handler.runRetention(w, r, false)
+}
+
+func (handler *Handler) runRetention(w http.ResponseWriter, r *http.Request, dryRun bool) *httperror.HandlerError {
+	registry, handlerErr := handler.retentionRegistry(r)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	if registry.Retention == nil || len(registry.Retention.Policies) == 0 {
+		return &httperror.HandlerError{http.StatusBadRequest, "No retention policy is defined for the registry", errors.New("Missing retention policies")}
+	}
+
+	report, err := handler.RetentionService.Run(registry.ID, dryRun)
+	if err != nil {
+		return registryError("Unable to apply the retention policies of the registry", err)
+	}
+
+	return response.JSON(w, report)
+}
+
+func (handler *Handler) retentionRegistry(r *http.Request) (*portainer.Registry, *httperror.HandlerError) {
+	registryID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, &httperror.HandlerError{http.StatusBadRequest, "Invalid registry identifier route variable", err}
+	}
+
+	registry, err := handler.DataStore.Registry().Registry(portainer.RegistryID(registryID))
+	if err == bolterrors.ErrObjectNotFound {
+		return nil, &httperror.HandlerError{http.StatusNotFound, "Unable to find a registry with the specified identifier inside the database", err}
+	} else if err != nil {
+		return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a registry with the specified identifier inside the database", err}
+	}
+
+	return registry, nil
+}
@@ -19,6 +19,10 @@ type registryUpdatePayload struct {
 	Password           *string
 	UserAccessPolicies portainer.UserAccessPolicies
 	TeamAccessPolicies portainer.TeamAccessPolicies
+	// AWS credentials of an ECR registry, the secret access key is kept when empty
+	Ecr *portainer.EcrRegistryData
+	// Azure service principal of an ACR registry, the authentication key is kept when empty
+	Acr *portainer.AzureCredentials
 }
 
 func (payload *registryUpdatePayload) Validate(r *http.Request) error {
@@ -82,6 +86,20 @@ func (handler *Handler) registryUpdate(w http.ResponseWriter, r *http.Request) *
 		}
 	}
 
+	if payload.Ecr != nil && registry.Type == portainer.EcrRegistry {
+		if payload.Ecr.SecretAccessKey == "" && registry.Ecr != nil {
+			payload.Ecr.SecretAccessKey = registry.Ecr.SecretAccessKey
+		}
+		registry.Ecr = payload.Ecr
+	}
+
+	if payload.Acr != nil && registry.Type == portainer.AcrRegistry {
+		if payload.Acr.AuthenticationKey == "" && registry.Acr != nil {
+			payload.Acr.AuthenticationKey = registry.Acr.AuthenticationKey
+		}
+		registry.Acr = payload.Acr
+	}
+
 	if payload.UserAccessPolicies != nil {
 		registry.UserAccessPolicies = payload.UserAccessPolicies
 	}
@@ -95,6 +113,7 @@ func (handler *Handler) registryUpdate(w http.ResponseWriter, r *http.Request) *
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist registry changes inside the database", err}
 	}
 
+	hideFields(registry)
 	return response.JSON(w, registry)
 }
 
@@ -12,16 +12,18 @@ import (
 func hideFields(settings *portainer.Settings) {
 	settings.LDAPSettings.Password = ""
 	settings.OAuthSettings.ClientSecret = ""
+	settings.ImageScanSettings.AccessToken = ""
 }
 
 // Handler is the HTTP handler used to handle settings operations.
 type Handler struct {
 	*mux.Router
-	DataStore       portainer.DataStore
-	FileService     portainer.FileService
-	JWTService      portainer.JWTService
-	LDAPService     portainer.LDAPService
-	SnapshotService portainer.SnapshotService
+	DataStore            portainer.DataStore
+	FileService          portainer.FileService
+	JWTService           portainer.JWTService
+	LDAPService          portainer.LDAPService
+	ReverseTunnelService portainer.ReverseTunnelService
+	SnapshotService      portainer.SnapshotService
 }
 
 // NewHandler creates a handler to manage settings operations.
@@ -37,6 +39,8 @@ func NewHandler(bouncer *security.RequestBouncer) *Handler {
 		bouncer.PublicAccess(httperror.LoggerHandler(h.settingsPublic))).Methods(http.MethodGet)
 	h.Handle("/settings/authentication/checkLDAP",
 		bouncer.AdminAccess(httperror.LoggerHandler(h.settingsLDAPCheck))).Methods(http.MethodPut)
+	h.Handle("/settings/tunnel/rotate",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.settingsTunnelKeyRotate))).Methods(http.MethodPost)
 
 	return h
 }
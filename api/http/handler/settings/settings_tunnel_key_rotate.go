@@ -0,0 +1,75 @@
+package settings
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+const defaultTunnelKeyRotationGracePeriod = "24h"
+
+type settingsTunnelKeyRotatePayload struct {
+	GracePeriod string
+}
+
+func (payload *settingsTunnelKeyRotatePayload) Validate(r *http.Request) error {
+	if payload.GracePeriod == "" {
+		payload.GracePeriod = defaultTunnelKeyRotationGracePeriod
+	}
+
+	gracePeriod, err := time.ParseDuration(payload.GracePeriod)
+	if err != nil || gracePeriod < 0 {
+		return errors.New("Invalid grace period. Must be a positive duration such as 24h or 30m")
+	}
+	return nil
+}
+
+type settingsTunnelKeyRotateResponse struct {
+	KeyListener            int   `json:"KeyListener"`
+	RotationGracePeriodEnd int64 `json:"RotationGracePeriodEnd"`
+}
+
+// POST request on /api/settings/tunnel/rotate
+// Rotates the key of the tunnel server. Edge agents using the previous key can still establish
+// a tunnel until the end of the grace period, Edge keys must be regenerated before that.
+func (handler *Handler) settingsTunnelKeyRotate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload settingsTunnelKeyRotatePayload
+	if r.ContentLength != 0 {
+		err := request.DecodeAndValidateJSONPayload(r, &payload)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+		}
+	} else {
+		payload.GracePeriod = defaultTunnelKeyRotationGracePeriod
+	}
+
+	gracePeriod, _ := time.ParseDuration(payload.GracePeriod)
+
+	serverInfo, err := handler.DataStore.TunnelServer().Info()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve the tunnel server information from the database", err}
+	}
+
+	if serverInfo.RotationGracePeriodEnd != 0 {
+		return &httperror.HandlerError{http.StatusConflict, "A tunnel server key rotation is already in progress", errors.New("Rotation in progress")}
+	}
+
+	err = handler.ReverseTunnelService.RotateServerKey(gracePeriod)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to rotate the tunnel server key", err}
+	}
+
+	serverInfo, err = handler.DataStore.TunnelServer().Info()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve the tunnel server information from the database", err}
+	}
+
+	return response.JSON(w, &settingsTunnelKeyRotateResponse{
+		KeyListener:            int(serverInfo.KeyListener),
+		RotationGracePeriodEnd: serverInfo.RotationGracePeriodEnd,
+	})
+}
@@ -2,12 +2,15 @@ package settings
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/asaskevich/govalidator"
 	portainer "github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/filesystem"
+	"github.com/cloudogu/portainer-ce/api/internal/imagetrust"
+	"github.com/cloudogu/portainer-ce/api/internal/vulnscan"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
@@ -33,6 +36,8 @@ type settingsUpdatePayload struct {
 	EnableEdgeComputeFeatures                 *bool
 	UserSessionTimeout                        *string
 	EnableTelemetry                           *bool
+	ImageScanSettings                         *portainer.ImageScanSettings
+	ImageTrustKeys                            []portainer.ImageTrustKey
 }
 
 func (payload *settingsUpdatePayload) Validate(r *http.Request) error {
@@ -51,6 +56,26 @@ func (payload *settingsUpdatePayload) Validate(r *http.Request) error {
 			return errors.New("Invalid user session timeout")
 		}
 	}
+	if payload.ImageScanSettings != nil && payload.ImageScanSettings.Enabled {
+		if payload.ImageScanSettings.ScannerType != portainer.TrivyScanner && payload.ImageScanSettings.ScannerType != portainer.ClairScanner {
+			return errors.New("Invalid image scanner type. Value must be one of: trivy or clair")
+		}
+		if !govalidator.IsURL(payload.ImageScanSettings.URL) {
+			return errors.New("Invalid image scanner URL. Must correspond to a valid URL format")
+		}
+		if payload.ImageScanSettings.DeployGate && !vulnscan.ValidSeverity(payload.ImageScanSettings.GateSeverity) {
+			return errors.New("Invalid deploy gate severity. Value must be one of: Low, Medium, High or Critical")
+		}
+	}
+	for _, trustKey := range payload.ImageTrustKeys {
+		if govalidator.IsNull(trustKey.Name) {
+			return errors.New("Invalid image trust key name. Name cannot be empty")
+		}
+		_, err := imagetrust.ParsePublicKey(trustKey.PublicKey)
+		if err != nil {
+			return fmt.Errorf("Invalid image trust key %s: %s", trustKey.Name, err)
+		}
+	}
 
 	return nil
 }
@@ -166,6 +191,19 @@ func (handler *Handler) settingsUpdate(w http.ResponseWriter, r *http.Request) *
 		settings.EnableTelemetry = *payload.EnableTelemetry
 	}
 
+	if payload.ImageScanSettings != nil {
+		accessToken := payload.ImageScanSettings.AccessToken
+		if accessToken == "" {
+			accessToken = settings.ImageScanSettings.AccessToken
+		}
+		settings.ImageScanSettings = *payload.ImageScanSettings
+		settings.ImageScanSettings.AccessToken = accessToken
+	}
+
+	if payload.ImageTrustKeys != nil {
+		settings.ImageTrustKeys = payload.ImageTrustKeys
+	}
+
 	tlsError := handler.updateTLS(settings)
 	if tlsError != nil {
 		return tlsError
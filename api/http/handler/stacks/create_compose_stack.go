@@ -13,6 +13,7 @@ import (
 	portainer "github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/filesystem"
 	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/customtemplate"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 )
@@ -28,6 +29,11 @@ type composeStackFromFileContentPayload struct {
 	Name             string
 	StackFileContent string
 	Env              []portainer.Pair
+	// Identifier of the custom template used to create the stack, the file of the custom template
+	// is deployed and the stack file content must not be specified
+	CustomTemplateID portainer.CustomTemplateID
+	// Values of the variables of the custom template
+	Variables []portainer.Pair
 }
 
 func (payload *composeStackFromFileContentPayload) Validate(r *http.Request) error {
@@ -35,9 +41,12 @@ func (payload *composeStackFromFileContentPayload) Validate(r *http.Request) err
 		return errors.New("Invalid stack name")
 	}
 	payload.Name = normalizeStackName(payload.Name)
-	if govalidator.IsNull(payload.StackFileContent) {
+	if govalidator.IsNull(payload.StackFileContent) && payload.CustomTemplateID == 0 {
 		return errors.New("Invalid stack file content")
 	}
+	if !govalidator.IsNull(payload.StackFileContent) && payload.CustomTemplateID != 0 {
+		return errors.New("The stack file content cannot be specified with a custom template")
+	}
 	return nil
 }
 
@@ -71,8 +80,21 @@ func (handler *Handler) createComposeStackFromFileContent(w http.ResponseWriter,
 		CreationDate: time.Now().Unix(),
 	}
 
+	stackFileContent := []byte(payload.StackFileContent)
+	if payload.CustomTemplateID != 0 {
+		customTemplate, rendered, secretEnv, handlerErr := handler.renderCustomTemplate(r, payload.CustomTemplateID, nil, payload.Variables)
+		if handlerErr != nil {
+			return handlerErr
+		}
+		stackFileContent = rendered
+		stack.Env = customtemplate.MergeSecretEnv(stack.Env, secretEnv)
+		stack.CustomTemplateID = customTemplate.ID
+		stack.CustomTemplateVersion = customTemplate.Version
+		stack.CustomTemplateVariables = customtemplate.PersistableValues(customTemplate.Variables, payload.Variables)
+	}
+
 	stackFolder := strconv.Itoa(int(stack.ID))
-	projectPath, err := handler.FileService.StoreStackFileFromBytes(stackFolder, stack.EntryPoint, []byte(payload.StackFileContent))
+	projectPath, err := handler.FileService.StoreStackFileFromBytes(stackFolder, stack.EntryPoint, stackFileContent)
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist Compose file on disk", err}
 	}
@@ -333,11 +355,8 @@ func (handler *Handler) createComposeDeployConfig(r *http.Request, stack *portai
 	return config, nil
 }
 
-// TODO: libcompose uses credentials store into a config.json file to pull images from
-// private registries. Right now the only solution is to re-use the embedded Docker binary
-// to login/logout, which will generate the required data in the config.json file and then
-// clean it. Hence the use of the mutex.
-// We should contribute to libcompose to support authentication without using the config.json file.
+// deployComposeStack deploys a compose stack with the credentials of the registries the user is authorized for.
+// A Docker configuration containing these credentials is created for each deployment.
 func (handler *Handler) deployComposeStack(config *composeStackDeploymentConfig) error {
 	settings, err := handler.DataStore.Settings().Settings()
 	if err != nil {
@@ -368,15 +387,11 @@ func (handler *Handler) deployComposeStack(config *composeStackDeploymentConfig)
 		}
 	}
 
-	handler.stackCreationMutex.Lock()
-	defer handler.stackCreationMutex.Unlock()
-
-	handler.SwarmStackManager.Login(config.dockerhub, config.registries, config.endpoint)
-
-	err = handler.ComposeStackManager.Up(config.stack, config.endpoint)
+	deployedStack, cleanUp, err := handler.deployableStack(config.stack, config.endpoint, settings)
 	if err != nil {
 		return err
 	}
+	defer cleanUp()
 
-	return handler.SwarmStackManager.Logout(config.endpoint)
+	return handler.ComposeStackManager.Up(deployedStack, config.endpoint, config.dockerhub, config.registries)
 }
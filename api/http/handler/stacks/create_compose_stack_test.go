@@ -0,0 +1,18 @@
+package stacks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_composeStackFromFileContentPayload_CustomTemplate(t *testing.T) {
+	payload := &composeStackFromFileContentPayload{Name: "stack", CustomTemplateID: 1}
+	assert.NoError(t, payload.Validate(nil))
+
+	payload = &composeStackFromFileContentPayload{Name: "stack", CustomTemplateID: 1, StackFileContent: "version: '3'"}
+	assert.Error(t, payload.Validate(nil), "the stack file content cannot replace the file of the custom template")
+
+	swarmPayload := &swarmStackFromFileContentPayload{Name: "stack", SwarmID: "swarm", CustomTemplateID: 1, StackFileContent: "version: '3'"}
+	assert.Error(t, swarmPayload.Validate(nil), "the stack file content cannot replace the file of the custom template")
+}
@@ -12,6 +12,7 @@ import (
 	portainer "github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/filesystem"
 	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/customtemplate"
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 )
@@ -21,6 +22,11 @@ type swarmStackFromFileContentPayload struct {
 	SwarmID          string
 	StackFileContent string
 	Env              []portainer.Pair
+	// Identifier of the custom template used to create the stack, the file of the custom template
+	// is deployed and the stack file content must not be specified
+	CustomTemplateID portainer.CustomTemplateID
+	// Values of the variables of the custom template
+	Variables []portainer.Pair
 }
 
 func (payload *swarmStackFromFileContentPayload) Validate(r *http.Request) error {
@@ -30,9 +36,12 @@ func (payload *swarmStackFromFileContentPayload) Validate(r *http.Request) error
 	if govalidator.IsNull(payload.SwarmID) {
 		return errors.New("Invalid Swarm ID")
 	}
-	if govalidator.IsNull(payload.StackFileContent) {
+	if govalidator.IsNull(payload.StackFileContent) && payload.CustomTemplateID == 0 {
 		return errors.New("Invalid stack file content")
 	}
+	if !govalidator.IsNull(payload.StackFileContent) && payload.CustomTemplateID != 0 {
+		return errors.New("The stack file content cannot be specified with a custom template")
+	}
 	return nil
 }
 
@@ -67,8 +76,21 @@ func (handler *Handler) createSwarmStackFromFileContent(w http.ResponseWriter, r
 		CreationDate: time.Now().Unix(),
 	}
 
+	stackFileContent := []byte(payload.StackFileContent)
+	if payload.CustomTemplateID != 0 {
+		customTemplate, rendered, secretEnv, handlerErr := handler.renderCustomTemplate(r, payload.CustomTemplateID, nil, payload.Variables)
+		if handlerErr != nil {
+			return handlerErr
+		}
+		stackFileContent = rendered
+		stack.Env = customtemplate.MergeSecretEnv(stack.Env, secretEnv)
+		stack.CustomTemplateID = customTemplate.ID
+		stack.CustomTemplateVersion = customTemplate.Version
+		stack.CustomTemplateVariables = customtemplate.PersistableValues(customTemplate.Variables, payload.Variables)
+	}
+
 	stackFolder := strconv.Itoa(int(stack.ID))
-	projectPath, err := handler.FileService.StoreStackFileFromBytes(stackFolder, stack.EntryPoint, []byte(payload.StackFileContent))
+	projectPath, err := handler.FileService.StoreStackFileFromBytes(stackFolder, stack.EntryPoint, stackFileContent)
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist Compose file on disk", err}
 	}
@@ -368,20 +390,11 @@ func (handler *Handler) deploySwarmStack(config *swarmStackDeploymentConfig) err
 		}
 	}
 
-	handler.stackCreationMutex.Lock()
-	defer handler.stackCreationMutex.Unlock()
-
-	handler.SwarmStackManager.Login(config.dockerhub, config.registries, config.endpoint)
-
-	err = handler.SwarmStackManager.Deploy(config.stack, config.prune, config.endpoint)
-	if err != nil {
-		return err
-	}
-
-	err = handler.SwarmStackManager.Logout(config.endpoint)
+	deployedStack, cleanUp, err := handler.deployableStack(config.stack, config.endpoint, settings)
 	if err != nil {
 		return err
 	}
+	defer cleanUp()
 
-	return nil
+	return handler.SwarmStackManager.Deploy(deployedStack, config.prune, config.endpoint, config.dockerhub, config.registries)
 }
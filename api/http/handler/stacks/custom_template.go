@@ -0,0 +1,66 @@
+package stacks
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperrors "github.com/cloudogu/portainer-ce/api/http/errors"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/authorization"
+	"github.com/cloudogu/portainer-ce/api/internal/customtemplate"
+	httperror "github.com/portainer/libhttp/error"
+)
+
+// renderCustomTemplate returns a custom template, its stack file rendered with the values of its variables and the
+// values of its secret variables, which are passed to the stack as environment variables instead of being rendered.
+// The kept values of the variables still declared by the template are used when no value is specified.
+func (handler *Handler) renderCustomTemplate(r *http.Request, customTemplateID portainer.CustomTemplateID, keptValues, values []portainer.Pair) (*portainer.CustomTemplate, []byte, []portainer.Pair, *httperror.HandlerError) {
+	customTemplate, err := handler.DataStore.CustomTemplate().CustomTemplate(customTemplateID)
+	if err == bolterrors.ErrObjectNotFound {
+		return nil, nil, nil, &httperror.HandlerError{http.StatusNotFound, "Unable to find a custom template with the specified identifier inside the database", err}
+	} else if err != nil {
+		return nil, nil, nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a custom template with the specified identifier inside the database", err}
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return nil, nil, nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve info from request context", err}
+	}
+
+	resourceControl, err := handler.DataStore.ResourceControl().ResourceControlByResourceIDAndType(strconv.Itoa(int(customTemplateID)), portainer.CustomTemplateResourceControl)
+	if err != nil {
+		return nil, nil, nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve a resource control associated to the custom template", err}
+	}
+
+	if !userCanUseCustomTemplate(customTemplate, securityContext, resourceControl) {
+		return nil, nil, nil, &httperror.HandlerError{http.StatusForbidden, "Access denied to resource", httperrors.ErrResourceAccessDenied}
+	}
+
+	content, err := handler.FileService.GetFileContent(path.Join(customTemplate.ProjectPath, customTemplate.EntryPoint))
+	if err != nil {
+		return nil, nil, nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve custom template file from disk", err}
+	}
+
+	rendered, secretEnv, err := customtemplate.RenderStackFile(content, customTemplate.Variables, customtemplate.MergeValues(customTemplate.Variables, keptValues, values))
+	if err != nil {
+		return nil, nil, nil, &httperror.HandlerError{http.StatusBadRequest, "Invalid custom template variables", err}
+	}
+
+	return customTemplate, rendered, secretEnv, nil
+}
+
+func userCanUseCustomTemplate(customTemplate *portainer.CustomTemplate, securityContext *security.RestrictedRequestContext, resourceControl *portainer.ResourceControl) bool {
+	if securityContext.IsAdmin || customTemplate.CreatedByUserID == securityContext.UserID {
+		return true
+	}
+
+	userTeamIDs := make([]portainer.TeamID, 0)
+	for _, membership := range securityContext.UserMemberships {
+		userTeamIDs = append(userTeamIDs, membership.TeamID)
+	}
+
+	return resourceControl != nil && authorization.UserCanAccessResource(securityContext.UserID, userTeamIDs, resourceControl)
+}
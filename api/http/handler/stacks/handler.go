@@ -23,12 +23,14 @@ type Handler struct {
 	stackDeletionMutex *sync.Mutex
 	requestBouncer     *security.RequestBouncer
 	*mux.Router
-	DataStore           portainer.DataStore
-	FileService         portainer.FileService
-	GitService          portainer.GitService
-	SwarmStackManager   portainer.SwarmStackManager
-	ComposeStackManager portainer.ComposeStackManager
-	KubernetesDeployer  portainer.KubernetesDeployer
+	DataStore                portainer.DataStore
+	FileService              portainer.FileService
+	GitService               portainer.GitService
+	SwarmStackManager        portainer.SwarmStackManager
+	ComposeStackManager      portainer.ComposeStackManager
+	KubernetesDeployer       portainer.KubernetesDeployer
+	VulnerabilityScanService portainer.VulnerabilityScanService
+	ImageTrustService        portainer.ImageTrustService
 }
 
 // NewHandler creates a handler to manage stack operations.
@@ -57,6 +59,10 @@ func NewHandler(bouncer *security.RequestBouncer) *Handler {
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackStart))).Methods(http.MethodPost)
 	h.Handle("/stacks/{id}/stop",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackStop))).Methods(http.MethodPost)
+	h.Handle("/stacks/{id}/upgrade",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackUpgrade))).Methods(http.MethodPost)
+	h.Handle("/stacks/{id}/vulnerabilities",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackVulnerabilities))).Methods(http.MethodGet)
 	return h
 }
 
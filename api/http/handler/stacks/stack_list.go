@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
 	"github.com/cloudogu/portainer-ce/api/http/security"
 	"github.com/cloudogu/portainer-ce/api/internal/authorization"
 	httperror "github.com/portainer/libhttp/error"
@@ -12,8 +13,11 @@ import (
 )
 
 type stackListOperationFilters struct {
-	SwarmID    string `json:"SwarmID"`
-	EndpointID int    `json:"EndpointID"`
+	SwarmID          string `json:"SwarmID"`
+	EndpointID       int    `json:"EndpointID"`
+	CustomTemplateID int    `json:"CustomTemplateID"`
+	// Only keep the stacks deployed from an older version of the custom template
+	OutdatedTemplate bool `json:"OutdatedTemplate"`
 }
 
 // GET request on /api/stacks?(filters=<filters>)
@@ -30,6 +34,17 @@ func (handler *Handler) stackList(w http.ResponseWriter, r *http.Request) *httpe
 	}
 	stacks = filterStacks(stacks, &filters)
 
+	if filters.CustomTemplateID != 0 {
+		customTemplate, err := handler.DataStore.CustomTemplate().CustomTemplate(portainer.CustomTemplateID(filters.CustomTemplateID))
+		if err == bolterrors.ErrObjectNotFound {
+			return &httperror.HandlerError{http.StatusNotFound, "Unable to find a custom template with the specified identifier inside the database", err}
+		} else if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a custom template with the specified identifier inside the database", err}
+		}
+
+		stacks = filterStacksByCustomTemplate(stacks, customTemplate, filters.OutdatedTemplate)
+	}
+
 	resourceControls, err := handler.DataStore.ResourceControl().ResourceControls()
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve resource controls from the database", err}
@@ -76,3 +91,18 @@ func filterStacks(stacks []portainer.Stack, filters *stackListOperationFilters)
 
 	return filteredStacks
 }
+
+func filterStacksByCustomTemplate(stacks []portainer.Stack, customTemplate *portainer.CustomTemplate, outdated bool) []portainer.Stack {
+	filteredStacks := make([]portainer.Stack, 0, len(stacks))
+	for _, stack := range stacks {
+		if stack.CustomTemplateID != customTemplate.ID {
+			continue
+		}
+		if outdated && stack.CustomTemplateVersion >= customTemplate.Version {
+			continue
+		}
+		filteredStacks = append(filteredStacks, stack)
+	}
+
+	return filteredStacks
+}
@@ -62,7 +62,7 @@ func (handler *Handler) stackStart(w http.ResponseWriter, r *http.Request) *http
 		return &httperror.HandlerError{http.StatusBadRequest, "Stack is already active", errors.New("Stack is already active")}
 	}
 
-	err = handler.startStack(stack, endpoint)
+	err = handler.startStack(r, stack, endpoint)
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to stop stack", err}
 	}
@@ -76,12 +76,37 @@ func (handler *Handler) stackStart(w http.ResponseWriter, r *http.Request) *http
 	return response.JSON(w, stack)
 }
 
-func (handler *Handler) startStack(stack *portainer.Stack, endpoint *portainer.Endpoint) error {
+func (handler *Handler) startStack(r *http.Request, stack *portainer.Stack, endpoint *portainer.Endpoint) error {
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return err
+	}
+
+	if stack.Type != portainer.DockerComposeStack && stack.Type != portainer.DockerSwarmStack {
+		return nil
+	}
+
+	deployedStack, cleanUp, err := handler.deployableStack(stack, endpoint, settings)
+	if err != nil {
+		return err
+	}
+	defer cleanUp()
+
 	switch stack.Type {
 	case portainer.DockerComposeStack:
-		return handler.ComposeStackManager.Up(stack, endpoint)
+		config, configErr := handler.createComposeDeployConfig(r, stack, endpoint)
+		if configErr != nil {
+			return configErr.Err
+		}
+
+		return handler.ComposeStackManager.Up(deployedStack, endpoint, config.dockerhub, config.registries)
 	case portainer.DockerSwarmStack:
-		return handler.SwarmStackManager.Deploy(stack, true, endpoint)
+		config, configErr := handler.createSwarmDeployConfig(r, stack, endpoint, true)
+		if configErr != nil {
+			return configErr.Err
+		}
+
+		return handler.SwarmStackManager.Deploy(deployedStack, true, endpoint, config.dockerhub, config.registries)
 	}
 	return nil
 }
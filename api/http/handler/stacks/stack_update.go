@@ -19,6 +19,7 @@ import (
 type updateComposeStackPayload struct {
 	StackFileContent string
 	Env              []portainer.Pair
+	AutoUpdateImages *bool
 }
 
 func (payload *updateComposeStackPayload) Validate(r *http.Request) error {
@@ -32,6 +33,7 @@ type updateSwarmStackPayload struct {
 	StackFileContent string
 	Env              []portainer.Pair
 	Prune            bool
+	AutoUpdateImages *bool
 }
 
 func (payload *updateSwarmStackPayload) Validate(r *http.Request) error {
@@ -124,6 +126,9 @@ func (handler *Handler) updateComposeStack(r *http.Request, stack *portainer.Sta
 	}
 
 	stack.Env = payload.Env
+	if payload.AutoUpdateImages != nil {
+		stack.AutoUpdateImages = *payload.AutoUpdateImages
+	}
 
 	stackFolder := strconv.Itoa(int(stack.ID))
 	_, err = handler.FileService.StoreStackFileFromBytes(stackFolder, stack.EntryPoint, []byte(payload.StackFileContent))
@@ -155,6 +160,9 @@ func (handler *Handler) updateSwarmStack(r *http.Request, stack *portainer.Stack
 	}
 
 	stack.Env = payload.Env
+	if payload.AutoUpdateImages != nil {
+		stack.AutoUpdateImages = *payload.AutoUpdateImages
+	}
 
 	stackFolder := strconv.Itoa(int(stack.ID))
 	_, err = handler.FileService.StoreStackFileFromBytes(stackFolder, stack.EntryPoint, []byte(payload.StackFileContent))
@@ -0,0 +1,159 @@
+package stacks
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperrors "github.com/cloudogu/portainer-ce/api/http/errors"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/customtemplate"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type stackUpgradePayload struct {
+	// Values of the variables of the custom template, the values kept with the stack are used for the other variables
+	Variables []portainer.Pair
+	// Prune the services that are no longer referenced (only available for Swarm stacks)
+	Prune bool
+}
+
+func (payload *stackUpgradePayload) Validate(r *http.Request) error {
+	return nil
+}
+
+// POST request on /api/stacks/:id/upgrade
+// Renders the latest version of the custom template the stack was deployed from and redeploys the stack
+func (handler *Handler) stackUpgrade(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	stackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid stack identifier route variable", err}
+	}
+
+	var payload stackUpgradePayload
+	err = request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	stack, err := handler.DataStore.Stack().Stack(portainer.StackID(stackID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find a stack with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a stack with the specified identifier inside the database", err}
+	}
+
+	if stack.CustomTemplateID == 0 {
+		return &httperror.HandlerError{http.StatusBadRequest, "The stack was not deployed from a custom template", errors.New("Missing custom template")}
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(stack.EndpointID)
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find the endpoint associated to the stack inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find the endpoint associated to the stack inside the database", err}
+	}
+
+	err = handler.requestBouncer.AuthorizedEndpointOperation(r, endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusForbidden, "Permission denied to access endpoint", err}
+	}
+
+	resourceControl, err := handler.DataStore.ResourceControl().ResourceControlByResourceIDAndType(stack.Name, portainer.StackResourceControl)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve a resource control associated to the stack", err}
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve info from request context", err}
+	}
+
+	access, err := handler.userCanAccessStack(securityContext, endpoint.ID, resourceControl)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to verify user authorizations to validate stack access", err}
+	}
+	if !access {
+		return &httperror.HandlerError{http.StatusForbidden, "Access denied to resource", httperrors.ErrResourceAccessDenied}
+	}
+
+	customTemplate, stackFileContent, secretEnv, handlerErr := handler.renderCustomTemplate(r, stack.CustomTemplateID, stack.CustomTemplateVariables, payload.Variables)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	err = customtemplate.ValidateSecretValues(customTemplate.Variables, payload.Variables)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid custom template variables", err}
+	}
+
+	stack.Env = customtemplate.MergeSecretEnv(stack.Env, secretEnv)
+	stack.CustomTemplateVersion = customTemplate.Version
+	stack.CustomTemplateVariables = customtemplate.PersistableValues(customTemplate.Variables, customtemplate.MergeValues(customTemplate.Variables, stack.CustomTemplateVariables, payload.Variables))
+
+	var deploy func() error
+	if stack.Type == portainer.DockerSwarmStack {
+		config, configErr := handler.createSwarmDeployConfig(r, stack, endpoint, payload.Prune)
+		if configErr != nil {
+			return configErr
+		}
+
+		stack.UpdatedBy = config.user.Username
+		deploy = func() error { return handler.deploySwarmStack(config) }
+	} else {
+		config, configErr := handler.createComposeDeployConfig(r, stack, endpoint)
+		if configErr != nil {
+			return configErr
+		}
+
+		stack.UpdatedBy = config.user.Username
+		deploy = func() error { return handler.deployComposeStack(config) }
+	}
+
+	err = handler.deployStackFile(stack, stackFileContent, deploy)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, err.Error(), err}
+	}
+
+	stack.UpdateDate = time.Now().Unix()
+
+	err = handler.DataStore.Stack().UpdateStack(stack.ID, stack)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the stack changes inside the database", err}
+	}
+
+	return response.JSON(w, stack)
+}
+
+// deployStackFile replaces the file of a stack and deploys the stack. The previous file is restored when the
+// deployment fails, so that the file on disk always matches the deployed stack.
+func (handler *Handler) deployStackFile(stack *portainer.Stack, stackFileContent []byte, deploy func() error) error {
+	previousStackFileContent, err := handler.FileService.GetFileContent(path.Join(stack.ProjectPath, stack.EntryPoint))
+	if err != nil {
+		return err
+	}
+
+	stackFolder := strconv.Itoa(int(stack.ID))
+	_, err = handler.FileService.StoreStackFileFromBytes(stackFolder, stack.EntryPoint, stackFileContent)
+	if err != nil {
+		return err
+	}
+
+	err = deploy()
+	if err != nil {
+		_, restoreErr := handler.FileService.StoreStackFileFromBytes(stackFolder, stack.EntryPoint, previousStackFileContent)
+		if restoreErr != nil {
+			log.Printf("[ERROR] [http,stacks] [message: unable to restore the stack file after a failed deployment] [stack: %s] [err: %s]", stack.Name, restoreErr)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package stacks
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_deployStackFile(t *testing.T) {
+	dataPath, err := ioutil.TempDir("", "stacks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	fileService, err := filesystem.NewService(dataPath, "")
+	assert.NoError(t, err)
+
+	projectPath, err := fileService.StoreStackFileFromBytes("1", "docker-compose.yml", []byte("version: '3'"))
+	assert.NoError(t, err)
+
+	handler := &Handler{FileService: fileService}
+	stack := &portainer.Stack{ID: 1, Name: "stack", EntryPoint: "docker-compose.yml", ProjectPath: projectPath}
+
+	readStackFile := func() string {
+		content, err := fileService.GetFileContent(path.Join(projectPath, "docker-compose.yml"))
+		assert.NoError(t, err)
+		return string(content)
+	}
+
+	t.Run("the previous file is restored when the deployment fails", func(t *testing.T) {
+		deployedContent := ""
+		err := handler.deployStackFile(stack, []byte("version: '3.7'"), func() error {
+			deployedContent = readStackFile()
+			return errors.New("deployment failed")
+		})
+		assert.EqualError(t, err, "deployment failed")
+		assert.Equal(t, "version: '3.7'", deployedContent, "the new file is deployed")
+		assert.Equal(t, "version: '3'", readStackFile())
+	})
+
+	t.Run("the new file is kept when the deployment succeeds", func(t *testing.T) {
+		err := handler.deployStackFile(stack, []byte("version: '3.8'"), func() error { return nil })
+		assert.NoError(t, err)
+		assert.Equal(t, "version: '3.8'", readStackFile())
+	})
+}
@@ -0,0 +1,121 @@
+package stacks
+
+import (
+	"net/http"
+	"path"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	httperrors "github.com/cloudogu/portainer-ce/api/http/errors"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/composefile"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/stacks/:id/vulnerabilities
+// Returns the vulnerabilities of the images running in the stack, based on the latest snapshot of its endpoint.
+func (handler *Handler) stackVulnerabilities(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	stackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid stack identifier route variable", err}
+	}
+
+	stack, err := handler.DataStore.Stack().Stack(portainer.StackID(stackID))
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find a stack with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a stack with the specified identifier inside the database", err}
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(stack.EndpointID)
+	if err == bolterrors.ErrObjectNotFound {
+		return &httperror.HandlerError{http.StatusNotFound, "Unable to find an endpoint with the specified identifier inside the database", err}
+	} else if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find an endpoint with the specified identifier inside the database", err}
+	}
+
+	err = handler.requestBouncer.AuthorizedEndpointOperation(r, endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusForbidden, "Permission denied to access endpoint", err}
+	}
+
+	resourceControl, err := handler.DataStore.ResourceControl().ResourceControlByResourceIDAndType(stack.Name, portainer.StackResourceControl)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve a resource control associated to the stack", err}
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve info from request context", err}
+	}
+
+	access, err := handler.userCanAccessStack(securityContext, endpoint.ID, resourceControl)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to verify user authorizations to validate stack access", err}
+	}
+	if !access {
+		return &httperror.HandlerError{http.StatusForbidden, "Access denied to resource", httperrors.ErrResourceAccessDenied}
+	}
+
+	name := stack.Name
+	if stack.Type == portainer.DockerComposeStack {
+		name = normalizeStackName(name)
+	}
+
+	stackSummary := portainer.StackVulnerabilitySummary{Name: name, Images: []string{}}
+	if len(endpoint.Snapshots) == 0 {
+		return response.JSON(w, stackSummary)
+	}
+
+	summary, err := handler.VulnerabilityScanService.EndpointSummary(endpoint)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to summarize the vulnerabilities of the endpoint images", err}
+	}
+
+	for _, summary := range summary.Stacks {
+		if summary.Name == name {
+			stackSummary = summary
+		}
+	}
+
+	return response.JSON(w, stackSummary)
+}
+
+// deployableStack returns the stack to deploy. The images of the stack file are verified by the image trust
+// policy of the endpoint, then by the vulnerability deploy gate when it is enabled. When images are pinned by
+// digest, so that the deployed images are the verified ones, the returned stack references a copy of the stack
+// file removed by the returned function once deployed.
+func (handler *Handler) deployableStack(stack *portainer.Stack, endpoint *portainer.Endpoint, settings *portainer.Settings) (*portainer.Stack, func(), error) {
+	trustedStack, cleanUp, err := handler.ImageTrustService.TrustedStack(stack, endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !settings.ImageScanSettings.Enabled || !settings.ImageScanSettings.DeployGate {
+		return trustedStack, cleanUp, nil
+	}
+
+	scannedStack, scanCleanUp, err := handler.scannedStack(trustedStack)
+	if err != nil {
+		cleanUp()
+		return nil, nil, err
+	}
+
+	return scannedStack, func() {
+		scanCleanUp()
+		cleanUp()
+	}, nil
+}
+
+// scannedStack applies the vulnerability deploy gate to the images of the stack file. The images already
+// pinned by the image trust policy are scanned by digest, the other ones are pinned by the scanned digest.
+func (handler *Handler) scannedStack(stack *portainer.Stack) (*portainer.Stack, func(), error) {
+	stackContent, err := handler.FileService.GetFileContent(path.Join(stack.ProjectPath, stack.EntryPoint))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return composefile.ScannedStack(stack, stackContent, handler.VulnerabilityScanService)
+}
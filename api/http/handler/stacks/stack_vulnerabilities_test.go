@@ -0,0 +1,95 @@
+package stacks
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+type testImageTrustService struct {
+	portainer.ImageTrustService
+	cleanedUp bool
+}
+
+func (service *testImageTrustService) TrustedStack(stack *portainer.Stack, endpoint *portainer.Endpoint) (*portainer.Stack, func(), error) {
+	return stack, func() { service.cleanedUp = true }, nil
+}
+
+type testVulnerabilityScanService struct {
+	portainer.VulnerabilityScanService
+	images       []string
+	pinnedImages map[string]string
+	err          error
+}
+
+func (service *testVulnerabilityScanService) VerifyImages(images []string) (map[string]string, error) {
+	service.images = images
+	return service.pinnedImages, service.err
+}
+
+func Test_deployableStack(t *testing.T) {
+	dataPath, err := ioutil.TempDir("", "stacks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	fileService, err := filesystem.NewService(dataPath, "")
+	assert.NoError(t, err)
+
+	stackFile := "version: '3'\nservices:\n  web:\n    image: nginx:${VERSION}\n"
+	projectPath, err := fileService.StoreStackFileFromBytes("1", "docker-compose.yml", []byte(stackFile))
+	assert.NoError(t, err)
+
+	stack := &portainer.Stack{ID: 1, EntryPoint: "docker-compose.yml", ProjectPath: projectPath, Env: []portainer.Pair{{Name: "VERSION", Value: "1.19"}}}
+	gateSettings := &portainer.Settings{ImageScanSettings: portainer.ImageScanSettings{Enabled: true, DeployGate: true}}
+
+	t.Run("the scanned images are deployed by digest", func(t *testing.T) {
+		imageTrustService := &testImageTrustService{}
+		vulnerabilityScanService := &testVulnerabilityScanService{pinnedImages: map[string]string{"nginx:1.19": "nginx:1.19@sha256:1111"}}
+		handler := &Handler{FileService: fileService, ImageTrustService: imageTrustService, VulnerabilityScanService: vulnerabilityScanService}
+
+		deployedStack, cleanUp, err := handler.deployableStack(stack, &portainer.Endpoint{}, gateSettings)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"nginx:1.19"}, vulnerabilityScanService.images)
+		assert.NotEqual(t, stack.EntryPoint, deployedStack.EntryPoint)
+
+		deployedFilePath := path.Join(deployedStack.ProjectPath, deployedStack.EntryPoint)
+		content, err := ioutil.ReadFile(deployedFilePath)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "image: nginx:1.19@sha256:1111")
+
+		cleanUp()
+		assert.True(t, imageTrustService.cleanedUp)
+		_, err = os.Stat(deployedFilePath)
+		assert.True(t, os.IsNotExist(err), "the pinned stack file is removed once deployed")
+
+		content, err = ioutil.ReadFile(path.Join(projectPath, "docker-compose.yml"))
+		assert.NoError(t, err)
+		assert.Equal(t, stackFile, string(content))
+	})
+
+	t.Run("the deployment is refused when the deploy gate rejects an image", func(t *testing.T) {
+		imageTrustService := &testImageTrustService{}
+		vulnerabilityScanService := &testVulnerabilityScanService{err: errors.New("Deployment refused")}
+		handler := &Handler{FileService: fileService, ImageTrustService: imageTrustService, VulnerabilityScanService: vulnerabilityScanService}
+
+		_, _, err := handler.deployableStack(stack, &portainer.Endpoint{}, gateSettings)
+		assert.EqualError(t, err, "Deployment refused")
+		assert.True(t, imageTrustService.cleanedUp)
+	})
+
+	t.Run("the images are not scanned when the deploy gate is disabled", func(t *testing.T) {
+		vulnerabilityScanService := &testVulnerabilityScanService{}
+		handler := &Handler{FileService: fileService, ImageTrustService: &testImageTrustService{}, VulnerabilityScanService: vulnerabilityScanService}
+
+		deployedStack, _, err := handler.deployableStack(stack, &portainer.Endpoint{}, &portainer.Settings{ImageScanSettings: portainer.ImageScanSettings{Enabled: true}})
+		assert.NoError(t, err)
+		assert.Equal(t, stack, deployedStack)
+		assert.Nil(t, vulnerabilityScanService.images)
+	})
+}
@@ -4,17 +4,21 @@ import (
 	"net/http"
 
 	"github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
 	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/templatecatalog"
 	"github.com/gorilla/mux"
 	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
 )
 
 // Handler represents an HTTP API handler for managing templates.
 type Handler struct {
 	*mux.Router
-	DataStore   portainer.DataStore
-	GitService  portainer.GitService
-	FileService portainer.FileService
+	DataStore      portainer.DataStore
+	GitService     portainer.GitService
+	FileService    portainer.FileService
+	CatalogService portainer.TemplateCatalogService
 }
 
 // NewHandler returns a new instance of Handler.
@@ -27,5 +31,90 @@ func NewHandler(bouncer *security.RequestBouncer) *Handler {
 		bouncer.RestrictedAccess(httperror.LoggerHandler(h.templateList))).Methods(http.MethodGet)
 	h.Handle("/templates/file",
 		bouncer.RestrictedAccess(httperror.LoggerHandler(h.templateFile))).Methods(http.MethodPost)
+	h.Handle("/templates/sources",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceCreate))).Methods(http.MethodPost)
+	h.Handle("/templates/sources",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceList))).Methods(http.MethodGet)
+	h.Handle("/templates/sources/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceInspect))).Methods(http.MethodGet)
+	h.Handle("/templates/sources/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceUpdate))).Methods(http.MethodPut)
+	h.Handle("/templates/sources/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceDelete))).Methods(http.MethodDelete)
+	h.Handle("/templates/sources/{id}/refresh",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceRefresh))).Methods(http.MethodPost)
 	return h
 }
+
+type templateSourceResponse struct {
+	portainer.TemplateSource
+	Cache *portainer.TemplateSourceCache `json:"Cache"`
+}
+
+// sourceResponse returns a template source without its password, along with the state of its cached copy
+func (handler *Handler) sourceResponse(source *portainer.TemplateSource) (*templateSourceResponse, error) {
+	cache, err := handler.CatalogService.SourceCache(source)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceResponse := &templateSourceResponse{TemplateSource: *source, Cache: cache}
+	sourceResponse.Password = ""
+	return sourceResponse, nil
+}
+
+// retrieveTemplateSource returns the template source matching the identifier route variable. The identifier 0
+// matches the source defined by the templates URL of the settings.
+func (handler *Handler) retrieveTemplateSource(r *http.Request) (*portainer.TemplateSource, *httperror.HandlerError) {
+	sourceID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, &httperror.HandlerError{http.StatusBadRequest, "Invalid template source identifier route variable", err}
+	}
+
+	if sourceID == 0 {
+		settings, err := handler.DataStore.Settings().Settings()
+		if err != nil {
+			return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve settings from the database", err}
+		}
+		return templatecatalog.DefaultSource(settings), nil
+	}
+
+	source, err := handler.DataStore.TemplateSource().TemplateSource(portainer.TemplateSourceID(sourceID))
+	if err == bolterrors.ErrObjectNotFound {
+		return nil, &httperror.HandlerError{http.StatusNotFound, "Unable to find a template source with the specified identifier inside the database", err}
+	} else if err != nil {
+		return nil, &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a template source with the specified identifier inside the database", err}
+	}
+
+	return source, nil
+}
+
+// sourceVisible returns true when the user is an administrator or a member of one of the teams of the source,
+// sources without teams being visible to every user
+func sourceVisible(source *portainer.TemplateSource, context *security.RestrictedRequestContext) bool {
+	if context.IsAdmin || len(source.TeamIDs) == 0 {
+		return true
+	}
+
+	for _, membership := range context.UserMemberships {
+		for _, teamID := range source.TeamIDs {
+			if membership.TeamID == teamID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (handler *Handler) validateTeams(teamIDs []portainer.TeamID) *httperror.HandlerError {
+	for _, teamID := range teamIDs {
+		_, err := handler.DataStore.Team().Team(teamID)
+		if err == bolterrors.ErrObjectNotFound {
+			return &httperror.HandlerError{http.StatusBadRequest, "Unable to find a team with the specified identifier inside the database", err}
+		} else if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to find a team with the specified identifier inside the database", err}
+		}
+	}
+	return nil
+}
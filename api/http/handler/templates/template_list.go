@@ -1,30 +1,70 @@
 package templates
 
 import (
-	"io"
+	"log"
 	"net/http"
 
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/templatecatalog"
 	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
 )
 
+type templateListResponse struct {
+	Version   string               `json:"version"`
+	Templates []portainer.Template `json:"templates"`
+}
+
 // GET request on /api/templates
+// Returns the templates of the enabled template sources visible to the user, merged in a single catalog
+// starting with the source defined by the templates URL of the settings. The templates are renumbered and
+// the sources that cannot be retrieved without a cached copy are skipped.
 func (handler *Handler) templateList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve info from request context", err}
+	}
+
 	settings, err := handler.DataStore.Settings().Settings()
 	if err != nil {
 		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve settings from the database", err}
 	}
 
-	resp, err := http.Get(settings.TemplatesURL)
+	sources, err := handler.DataStore.TemplateSource().TemplateSources()
 	if err != nil {
-		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve templates via the network", err}
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve template sources from the database", err}
 	}
-	defer resp.Body.Close()
+	sources = append([]portainer.TemplateSource{*templatecatalog.DefaultSource(settings)}, sources...)
 
-	w.Header().Set("Content-Type", "application/json")
-	_, err = io.Copy(w, resp.Body)
-	if err != nil {
-		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to write templates from templates URL", err}
+	templates := []portainer.Template{}
+	retrieved := false
+	var sourceErr error
+
+	for idx := range sources {
+		source := &sources[idx]
+		if !source.Enabled || !sourceVisible(source, securityContext) {
+			continue
+		}
+
+		sourceTemplates, err := handler.CatalogService.SourceTemplates(source)
+		if err != nil {
+			log.Printf("[WARN] [http,templates] [message: unable to retrieve the templates of a template source] [source: %s] [err: %s]", source.Name, err)
+			sourceErr = err
+			continue
+		}
+
+		retrieved = true
+		templates = append(templates, sourceTemplates...)
+	}
+
+	if !retrieved && sourceErr != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve templates via the network", sourceErr}
+	}
+
+	for idx := range templates {
+		templates[idx].ID = portainer.TemplateID(idx + 1)
 	}
 
-	return nil
+	return response.JSON(w, templateListResponse{Version: "2", Templates: templates})
 }
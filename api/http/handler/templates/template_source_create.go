@@ -0,0 +1,177 @@
+package templates
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/templatecatalog"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type templateSourceCreatePayload struct {
+	Name           string
+	URL            string
+	ReferenceName  string
+	FilePath       string
+	Authentication bool
+	Username       string
+	Password       string
+	Enabled        *bool
+	TeamIDs        []portainer.TeamID
+}
+
+func (payload *templateSourceCreatePayload) Validate(r *http.Request) error {
+	if govalidator.IsNull(payload.Name) {
+		return errors.New("Invalid template source name")
+	}
+	if govalidator.IsNull(payload.URL) || !govalidator.IsURL(payload.URL) {
+		return errors.New("Invalid URL. Must correspond to a valid URL format")
+	}
+	if payload.Authentication && (govalidator.IsNull(payload.Username) || govalidator.IsNull(payload.Password)) {
+		return errors.New("Invalid credentials. Username and password must be specified when authentication is enabled")
+	}
+	return nil
+}
+
+type templateSourceCreateFromFilePayload struct {
+	Name        string
+	FileContent []byte
+	Enabled     bool
+	TeamIDs     []portainer.TeamID
+}
+
+func (payload *templateSourceCreateFromFilePayload) Validate(r *http.Request) error {
+	name, err := request.RetrieveMultiPartFormValue(r, "Name", false)
+	if err != nil {
+		return errors.New("Invalid template source name")
+	}
+	payload.Name = name
+
+	fileContent, _, err := request.RetrieveMultiPartFormFile(r, "file")
+	if err != nil {
+		return errors.New("Invalid template file. Ensure that the file is uploaded correctly")
+	}
+	payload.FileContent = fileContent
+
+	var teamIDs []portainer.TeamID
+	err = request.RetrieveMultiPartFormJSONValue(r, "TeamIDs", &teamIDs, true)
+	if err != nil {
+		return errors.New("Invalid team identifiers")
+	}
+	payload.TeamIDs = teamIDs
+
+	payload.Enabled = true
+	if r.FormValue("Enabled") != "" {
+		enabled, err := request.RetrieveBooleanMultiPartFormValue(r, "Enabled", false)
+		if err != nil {
+			return errors.New("Invalid Enabled value")
+		}
+		payload.Enabled = enabled
+	}
+
+	_, err = templatecatalog.ParseTemplates(payload.FileContent)
+	return err
+}
+
+// POST request on /api/templates/sources?method=url|repository|file
+// Creates a template source retrieving the template file from a URL, from a file inside a Git repository
+// or from an uploaded file. The source is created even when it cannot be reached, the error of the first
+// retrieval being available in the state of its cache.
+func (handler *Handler) templateSourceCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	method, err := request.RetrieveQueryParameter(r, "method", false)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid query parameter: method. Valid values are: url, repository or file", err}
+	}
+
+	var source *portainer.TemplateSource
+	var fileContent []byte
+
+	switch method {
+	case "url", "repository":
+		var payload templateSourceCreatePayload
+		err = request.DecodeAndValidateJSONPayload(r, &payload)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+		}
+
+		source = &portainer.TemplateSource{
+			Name:           payload.Name,
+			Type:           portainer.URLTemplateSource,
+			Enabled:        payload.Enabled == nil || *payload.Enabled,
+			URL:            payload.URL,
+			Authentication: payload.Authentication,
+			TeamIDs:        payload.TeamIDs,
+		}
+
+		if payload.Authentication {
+			source.Username = payload.Username
+			source.Password = payload.Password
+		}
+
+		if method == "repository" {
+			if govalidator.IsNull(payload.FilePath) {
+				return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", errors.New("Invalid file path. The path of the template file in the repository is mandatory")}
+			}
+			source.Type = portainer.GitTemplateSource
+			source.ReferenceName = payload.ReferenceName
+			source.FilePath = payload.FilePath
+		}
+	case "file":
+		payload := &templateSourceCreateFromFilePayload{}
+		err = payload.Validate(r)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+		}
+
+		source = &portainer.TemplateSource{
+			Name:    payload.Name,
+			Type:    portainer.FileTemplateSource,
+			Enabled: payload.Enabled,
+			TeamIDs: payload.TeamIDs,
+		}
+		fileContent = payload.FileContent
+	default:
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid query parameter: method. Valid values are: url, repository or file", errors.New(request.ErrInvalidQueryParameter)}
+	}
+
+	if source.TeamIDs == nil {
+		source.TeamIDs = []portainer.TeamID{}
+	}
+
+	httpErr := handler.validateTeams(source.TeamIDs)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	source.ID = portainer.TemplateSourceID(handler.DataStore.TemplateSource().GetNextIdentifier())
+
+	if fileContent != nil {
+		_, err = handler.FileService.StoreTemplateSourceFileFromBytes(strconv.Itoa(int(source.ID)), templatecatalog.SourceFileName, fileContent)
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the template file on disk", err}
+		}
+	}
+
+	err = handler.DataStore.TemplateSource().CreateTemplateSource(source)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the template source inside the database", err}
+	}
+
+	err = handler.CatalogService.RefreshSource(source)
+	if err != nil {
+		log.Printf("[WARN] [http,templates] [message: unable to retrieve the templates of the new template source] [source: %s] [err: %s]", source.Name, err)
+	}
+
+	sourceResponse, err := handler.sourceResponse(source)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve the cache of the template source", err}
+	}
+
+	return response.JSON(w, sourceResponse)
+}
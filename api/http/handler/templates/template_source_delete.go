@@ -0,0 +1,33 @@
+package templates
+
+import (
+	"errors"
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+// DELETE request on /api/templates/sources/:id
+func (handler *Handler) templateSourceDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	source, httpErr := handler.retrieveTemplateSource(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if source.ID == 0 {
+		return &httperror.HandlerError{http.StatusBadRequest, "The default template source is managed through the settings", errors.New("Cannot remove the default template source")}
+	}
+
+	err := handler.DataStore.TemplateSource().DeleteTemplateSource(source.ID)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove the template source from the database", err}
+	}
+
+	err = handler.CatalogService.RemoveSourceCache(source)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to remove the template source files from disk", err}
+	}
+
+	return response.Empty(w)
+}
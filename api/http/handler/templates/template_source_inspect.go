@@ -0,0 +1,23 @@
+package templates
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/templates/sources/:id
+func (handler *Handler) templateSourceInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	source, httpErr := handler.retrieveTemplateSource(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	sourceResponse, err := handler.sourceResponse(source)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve the cache of the template source", err}
+	}
+
+	return response.JSON(w, sourceResponse)
+}
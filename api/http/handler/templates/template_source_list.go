@@ -0,0 +1,37 @@
+package templates
+
+import (
+	"net/http"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/templatecatalog"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+// GET request on /api/templates/sources
+// Returns the template sources along with the state of their cache. The first source, with the identifier 0,
+// is the source defined by the templates URL of the settings.
+func (handler *Handler) templateSourceList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve settings from the database", err}
+	}
+
+	sources, err := handler.DataStore.TemplateSource().TemplateSources()
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve template sources from the database", err}
+	}
+	sources = append([]portainer.TemplateSource{*templatecatalog.DefaultSource(settings)}, sources...)
+
+	sourcesResponse := make([]*templateSourceResponse, 0, len(sources))
+	for idx := range sources {
+		sourceResponse, err := handler.sourceResponse(&sources[idx])
+		if err != nil {
+			return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve the cache of the template source", err}
+		}
+		sourcesResponse = append(sourcesResponse, sourceResponse)
+	}
+
+	return response.JSON(w, sourcesResponse)
+}
@@ -0,0 +1,29 @@
+package templates
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+// POST request on /api/templates/sources/:id/refresh
+// Refreshes the cached copy of a template source without waiting for the refresh interval
+func (handler *Handler) templateSourceRefresh(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	source, httpErr := handler.retrieveTemplateSource(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	err := handler.CatalogService.RefreshSource(source)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to refresh the template source", err}
+	}
+
+	sourceResponse, err := handler.sourceResponse(source)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve the cache of the template source", err}
+	}
+
+	return response.JSON(w, sourceResponse)
+}
@@ -0,0 +1,149 @@
+package templates
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/templatecatalog"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+)
+
+type templateSourceUpdatePayload struct {
+	Name           *string
+	Enabled        *bool
+	TeamIDs        []portainer.TeamID
+	URL            *string
+	ReferenceName  *string
+	FilePath       *string
+	Authentication *bool
+	Username       string
+	Password       string
+	FileContent    *string
+}
+
+func (payload *templateSourceUpdatePayload) Validate(r *http.Request) error {
+	if payload.Name != nil && govalidator.IsNull(*payload.Name) {
+		return errors.New("Invalid template source name")
+	}
+	if payload.URL != nil && !govalidator.IsURL(*payload.URL) {
+		return errors.New("Invalid URL. Must correspond to a valid URL format")
+	}
+	if payload.FilePath != nil && govalidator.IsNull(*payload.FilePath) {
+		return errors.New("Invalid file path")
+	}
+	if payload.Authentication != nil && *payload.Authentication && govalidator.IsNull(payload.Username) {
+		return errors.New("Invalid credentials. Username must be specified when authentication is enabled")
+	}
+	if payload.FileContent != nil {
+		_, err := templatecatalog.ParseTemplates([]byte(*payload.FileContent))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PUT request on /api/templates/sources/:id
+// Updates a template source. The password is kept when authentication remains enabled and no new
+// password is specified. FileContent replaces the template file of a file source.
+func (handler *Handler) templateSourceUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	source, httpErr := handler.retrieveTemplateSource(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if source.ID == 0 {
+		return &httperror.HandlerError{http.StatusBadRequest, "The default template source is managed through the settings", errors.New("Cannot update the default template source")}
+	}
+
+	var payload templateSourceUpdatePayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", err}
+	}
+
+	if payload.Name != nil {
+		source.Name = *payload.Name
+	}
+
+	if payload.Enabled != nil {
+		source.Enabled = *payload.Enabled
+	}
+
+	if payload.TeamIDs != nil {
+		httpErr := handler.validateTeams(payload.TeamIDs)
+		if httpErr != nil {
+			return httpErr
+		}
+		source.TeamIDs = payload.TeamIDs
+	}
+
+	refresh := false
+	if source.Type == portainer.FileTemplateSource {
+		if payload.FileContent != nil {
+			_, err = handler.FileService.StoreTemplateSourceFileFromBytes(strconv.Itoa(int(source.ID)), templatecatalog.SourceFileName, []byte(*payload.FileContent))
+			if err != nil {
+				return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the template file on disk", err}
+			}
+			refresh = true
+		}
+	} else {
+		if payload.URL != nil {
+			source.URL = *payload.URL
+		}
+
+		if source.Type == portainer.GitTemplateSource {
+			if payload.ReferenceName != nil {
+				source.ReferenceName = *payload.ReferenceName
+			}
+			if payload.FilePath != nil {
+				source.FilePath = *payload.FilePath
+			}
+		}
+
+		if payload.Authentication != nil {
+			if *payload.Authentication {
+				password := payload.Password
+				if password == "" && source.Authentication {
+					password = source.Password
+				}
+				if password == "" {
+					return &httperror.HandlerError{http.StatusBadRequest, "Invalid request payload", errors.New("Invalid credentials. Password must be specified when authentication is enabled")}
+				}
+				source.Username = payload.Username
+				source.Password = password
+			} else {
+				source.Username = ""
+				source.Password = ""
+			}
+			source.Authentication = *payload.Authentication
+		}
+
+		refresh = payload.URL != nil || payload.ReferenceName != nil || payload.FilePath != nil || payload.Authentication != nil
+	}
+
+	err = handler.DataStore.TemplateSource().UpdateTemplateSource(source.ID, source)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to persist the template source changes inside the database", err}
+	}
+
+	if refresh {
+		err = handler.CatalogService.RefreshSource(source)
+		if err != nil {
+			log.Printf("[WARN] [http,templates] [message: unable to retrieve the templates of the updated template source] [source: %s] [err: %s]", source.Name, err)
+		}
+	}
+
+	sourceResponse, err := handler.sourceResponse(source)
+	if err != nil {
+		return &httperror.HandlerError{http.StatusInternalServerError, "Unable to retrieve the cache of the template source", err}
+	}
+
+	return response.JSON(w, sourceResponse)
+}
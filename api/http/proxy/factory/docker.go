@@ -60,6 +60,7 @@ func (factory *ProxyFactory) newDockerHTTPProxy(endpoint *portainer.Endpoint) (h
 		DataStore:            factory.dataStore,
 		ReverseTunnelService: factory.reverseTunnelService,
 		SignatureService:     factory.signatureService,
+		ImageTrustService:    factory.imageTrustService,
 		DockerClientFactory:  factory.dockerClientFactory,
 	}
 
@@ -12,6 +12,8 @@ import (
 	"github.com/cloudogu/portainer-ce/api/http/proxy/factory/responseutils"
 	"github.com/cloudogu/portainer-ce/api/http/security"
 	"github.com/cloudogu/portainer-ce/api/internal/authorization"
+	"github.com/cloudogu/portainer-ce/api/internal/imagetrust"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 )
 
@@ -175,6 +177,15 @@ func (transport *Transport) decorateContainerCreationOperation(request *http.Req
 		return nil, err
 	}
 
+	if transport.imageTrustService != nil {
+		err = transport.pinContainerImage(request)
+		if _, ok := err.(*imagetrust.UntrustedImagesError); ok {
+			return forbiddenResponse, err
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
 	isAdminOrEndpointAdmin, err := transport.isAdminOrEndpointAdmin(request)
 	if err != nil {
 		return nil, err
@@ -231,3 +242,132 @@ func (transport *Transport) decorateContainerCreationOperation(request *http.Req
 
 	return response, err
 }
+
+// pinContainerImage verifies the signature of the image of a container creation request according to the
+// image trust policy of the endpoint group. A verified image is replaced in the request by its reference pinned by digest.
+func (transport *Transport) pinContainerImage(request *http.Request) error {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+
+	// the request is kept as raw JSON values to forward the other fields unchanged
+	var container map[string]json.RawMessage
+	err = json.Unmarshal(body, &container)
+	if err != nil {
+		return err
+	}
+
+	var image string
+	if rawImage, ok := container["Image"]; ok {
+		err = json.Unmarshal(rawImage, &image)
+		if err != nil {
+			return err
+		}
+	}
+
+	if image != "" {
+		pinnedImage, err := transport.pinnedImage(image)
+		if err != nil {
+			return err
+		}
+
+		if pinnedImage != image {
+			container["Image"], err = json.Marshal(pinnedImage)
+			if err != nil {
+				return err
+			}
+
+			body, err = json.Marshal(container)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	request.ContentLength = int64(len(body))
+	return nil
+}
+
+// pinnedImage verifies the signature of an image according to the image trust policy of the endpoint group and
+// returns the image pinned by digest. The image is returned as is when it is not verified with the audit policy.
+func (transport *Transport) pinnedImage(image string) (string, error) {
+	pinnedImages, err := transport.imageTrustService.VerifyImages(transport.endpoint, []string{image})
+	if err != nil {
+		return "", err
+	}
+
+	if pinnedImage, ok := pinnedImages[image]; ok {
+		return pinnedImage, nil
+	}
+	return image, nil
+}
+
+// FilterContainers applies the access control and black listed labels filtering used by the
+// container list operation to a list of containers retrieved outside of the Docker proxy.
+// The user is retrieved from the token data associated to the request.
+func FilterContainers(dataStore portainer.DataStore, request *http.Request, containers []types.Container) ([]types.Container, error) {
+	transport := &Transport{
+		dataStore: dataStore,
+	}
+
+	operationContext, err := transport.createOperationContext(request)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := dataStore.Settings().Settings()
+	if err != nil {
+		return nil, err
+	}
+
+	executor := &operationExecutor{
+		operationContext: operationContext,
+		labelBlackList:   settings.BlackListedLabels,
+	}
+
+	data, err := json.Marshal(containers)
+	if err != nil {
+		return nil, err
+	}
+
+	var resourceData []interface{}
+	err = json.Unmarshal(data, &resourceData)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceOperationParameters := &resourceOperationParameters{
+		resourceIdentifierAttribute: containerObjectIdentifier,
+		resourceType:                portainer.ContainerResourceControl,
+		labelsObjectSelector:        selectorContainerLabelsFromContainerListOperation,
+	}
+
+	resourceData, err = transport.applyAccessControlOnResourceList(resourceOperationParameters, resourceData, executor)
+	if err != nil {
+		return nil, err
+	}
+
+	if executor.labelBlackList != nil {
+		resourceData, err = filterContainersWithBlackListedLabels(resourceData, executor.labelBlackList)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	authorizedContainers := make(map[string]bool)
+	for _, resource := range resourceData {
+		containerObject := resource.(map[string]interface{})
+		authorizedContainers[containerObject[containerObjectIdentifier].(string)] = true
+	}
+
+	filteredContainers := make([]types.Container, 0)
+	for _, container := range containers {
+		if authorizedContainers[container.ID] {
+			filteredContainers = append(filteredContainers, container)
+		}
+	}
+
+	return filteredContainers, nil
+}
@@ -0,0 +1,171 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/imagetrust"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/cloudogu/portainer-ce/api/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+// testImageTrustService pins the image app:signed and rejects the image app:unsigned
+type testImageTrustService struct {
+	portainer.ImageTrustService
+}
+
+func (service testImageTrustService) VerifyImages(endpoint *portainer.Endpoint, images []string) (map[string]string, error) {
+	pinnedImages := make(map[string]string)
+	for _, image := range images {
+		switch image {
+		case "app:signed":
+			pinnedImages[image] = image + "@" + testDigest
+		case "app:unsigned":
+			return nil, &imagetrust.UntrustedImagesError{Images: []string{image}}
+		}
+	}
+	return pinnedImages, nil
+}
+
+func newImageTrustRequest(body string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/containers/create", bytes.NewBufferString(body))
+}
+
+func decodeRequestBody(t *testing.T, request *http.Request) map[string]interface{} {
+	body, err := ioutil.ReadAll(request.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), request.ContentLength)
+
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(body, &decoded))
+	return decoded
+}
+
+func Test_pinContainerImage(t *testing.T) {
+	transport := &Transport{endpoint: &portainer.Endpoint{ID: 1}, imageTrustService: testImageTrustService{}}
+
+	request := newImageTrustRequest(`{"Image":"app:signed","Cmd":["run"],"Labels":{"team":"api"}}`)
+	assert.NoError(t, transport.pinContainerImage(request))
+	container := decodeRequestBody(t, request)
+	assert.Equal(t, "app:signed@"+testDigest, container["Image"])
+	assert.Equal(t, []interface{}{"run"}, container["Cmd"])
+	assert.Equal(t, map[string]interface{}{"team": "api"}, container["Labels"])
+
+	// images that are not verified with the audit policy are forwarded unchanged
+	request = newImageTrustRequest(`{"Image":"app:audited","Cmd":["run"]}`)
+	assert.NoError(t, transport.pinContainerImage(request))
+	assert.Equal(t, "app:audited", decodeRequestBody(t, request)["Image"])
+
+	request = newImageTrustRequest(`{"Image":"app:unsigned"}`)
+	assert.IsType(t, &imagetrust.UntrustedImagesError{}, transport.pinContainerImage(request))
+}
+
+func Test_pinServiceImage(t *testing.T) {
+	transport := &Transport{endpoint: &portainer.Endpoint{ID: 1}, imageTrustService: testImageTrustService{}}
+
+	request := newImageTrustRequest(`{"Name":"api","TaskTemplate":{"ContainerSpec":{"Image":"app:signed","Args":["run"]},"RestartPolicy":{"Condition":"any"}},"Mode":{"Replicated":{"Replicas":2}}}`)
+	assert.NoError(t, transport.pinServiceImage(request))
+	service := decodeRequestBody(t, request)
+	assert.Equal(t, "api", service["Name"])
+	assert.Equal(t, map[string]interface{}{"Replicated": map[string]interface{}{"Replicas": float64(2)}}, service["Mode"])
+
+	taskTemplate := service["TaskTemplate"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"Condition": "any"}, taskTemplate["RestartPolicy"])
+	assert.Equal(t, map[string]interface{}{"Image": "app:signed@" + testDigest, "Args": []interface{}{"run"}}, taskTemplate["ContainerSpec"])
+
+	request = newImageTrustRequest(`{"Name":"api","TaskTemplate":{"ContainerSpec":{"Image":"app:unsigned"}}}`)
+	assert.IsType(t, &imagetrust.UntrustedImagesError{}, transport.pinServiceImage(request))
+}
+
+func Test_ProxyDockerRequest_ServiceImageTrust(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	var forwardedBody map[string]interface{}
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedBody = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&forwardedBody))
+
+		if r.URL.Path == "/services/create" {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"ID":"service-id"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer daemon.Close()
+	daemonURL, err := url.Parse(daemon.URL)
+	assert.NoError(t, err)
+
+	jwtService, err := jwt.NewService("1h")
+	assert.NoError(t, err)
+	user := &portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}
+	assert.NoError(t, store.User().CreateUser(user))
+	token, err := jwtService.GenerateToken(&portainer.TokenData{ID: user.ID, Username: user.Username, Role: user.Role})
+	assert.NoError(t, err)
+
+	transport := &Transport{
+		HTTPTransport:     &http.Transport{},
+		endpoint:          &portainer.Endpoint{ID: 1, Type: portainer.DockerEnvironment},
+		dataStore:         store,
+		imageTrustService: testImageTrustService{},
+	}
+
+	handler := security.NewRequestBouncer(store, jwtService).AuthenticatedAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Scheme = daemonURL.Scheme
+		r.URL.Host = daemonURL.Host
+		r.RequestURI = ""
+
+		response, _ := transport.ProxyDockerRequest(r)
+		if response == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(response.StatusCode)
+	}))
+
+	tests := []struct {
+		name           string
+		url            string
+		image          string
+		expectedStatus int
+		expectedImage  string
+	}{
+		{"a service is created with its image pinned by digest", "/services/create", "app:signed", http.StatusOK, "app:signed@" + testDigest},
+		{"a service with an untrusted image is rejected", "/services/create", "app:unsigned", http.StatusForbidden, ""},
+		{"a service is updated with its image pinned by digest", "/services/service-id/update?version=1", "app:signed", http.StatusOK, "app:signed@" + testDigest},
+		{"a service cannot be updated with an untrusted image", "/services/service-id/update?version=1", "app:unsigned", http.StatusForbidden, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			forwardedBody = nil
+
+			body := `{"Name":"api","TaskTemplate":{"ContainerSpec":{"Image":"` + test.image + `"}}}`
+			req := httptest.NewRequest(http.MethodPost, test.url, bytes.NewBufferString(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			if test.expectedImage == "" {
+				assert.Nil(t, forwardedBody, "the request must not reach the Docker daemon")
+				return
+			}
+
+			containerSpec := forwardedBody["TaskTemplate"].(map[string]interface{})["ContainerSpec"].(map[string]interface{})
+			assert.Equal(t, test.expectedImage, containerSpec["Image"])
+		})
+	}
+}
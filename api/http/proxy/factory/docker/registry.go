@@ -3,6 +3,7 @@ package docker
 import (
 	"github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/registry"
 )
 
 type (
@@ -20,7 +21,7 @@ type (
 	}
 )
 
-func createRegistryAuthenticationHeader(serverAddress string, accessContext *registryAccessContext) *registryAuthenticationHeader {
+func createRegistryAuthenticationHeader(serverAddress string, accessContext *registryAccessContext) (*registryAuthenticationHeader, error) {
 	var authenticationHeader *registryAuthenticationHeader
 
 	if serverAddress == "" {
@@ -31,10 +32,10 @@ func createRegistryAuthenticationHeader(serverAddress string, accessContext *reg
 		}
 	} else {
 		var matchingRegistry *portainer.Registry
-		for _, registry := range accessContext.registries {
-			if registry.URL == serverAddress &&
-				(accessContext.isAdmin || (!accessContext.isAdmin && security.AuthorizedRegistryAccess(&registry, accessContext.userID, accessContext.teamMemberships))) {
-				matchingRegistry = &registry
+		for _, reg := range accessContext.registries {
+			if reg.URL == serverAddress &&
+				(accessContext.isAdmin || (!accessContext.isAdmin && security.AuthorizedRegistryAccess(&reg, accessContext.userID, accessContext.teamMemberships))) {
+				matchingRegistry = &reg
 				break
 			}
 		}
@@ -45,8 +46,17 @@ func createRegistryAuthenticationHeader(serverAddress string, accessContext *reg
 				Password:      matchingRegistry.Password,
 				Serveraddress: matchingRegistry.URL,
 			}
+
+			if matchingRegistry.Type == portainer.EcrRegistry || matchingRegistry.Type == portainer.AcrRegistry {
+				credentials, err := registry.RegistryCredentials(matchingRegistry)
+				if err != nil {
+					return nil, err
+				}
+				authenticationHeader.Username = credentials.Username
+				authenticationHeader.Password = credentials.Password
+			}
 		}
 	}
 
-	return authenticationHeader
+	return authenticationHeader, nil
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/cloudogu/portainer-ce/api"
 	"github.com/cloudogu/portainer-ce/api/http/proxy/factory/responseutils"
 	"github.com/cloudogu/portainer-ce/api/internal/authorization"
+	"github.com/cloudogu/portainer-ce/api/internal/imagetrust"
 )
 
 const (
@@ -110,6 +111,15 @@ func (transport *Transport) decorateServiceCreationOperation(request *http.Reque
 		return nil, err
 	}
 
+	if transport.imageTrustService != nil {
+		err = transport.pinServiceImage(request)
+		if _, ok := err.(*imagetrust.UntrustedImagesError); ok {
+			return forbiddenResponse, err
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
 	if !isAdminOrEndpointAdmin {
 		settings, err := transport.dataStore.Settings().Settings()
 		if err != nil {
@@ -140,3 +150,89 @@ func (transport *Transport) decorateServiceCreationOperation(request *http.Reque
 
 	return transport.replaceRegistryAuthenticationHeader(request)
 }
+
+// decorateServiceUpdateOperation verifies the image of a service update request before applying the access control
+// of the service, the image of a service can be replaced by an update
+func (transport *Transport) decorateServiceUpdateOperation(request *http.Request, serviceID string) (*http.Response, error) {
+	if transport.imageTrustService != nil {
+		err := transport.pinServiceImage(request)
+		if _, ok := err.(*imagetrust.UntrustedImagesError); ok {
+			return &http.Response{StatusCode: http.StatusForbidden}, err
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return transport.restrictedResourceOperation(request, serviceID, portainer.ServiceResourceControl, false)
+}
+
+// pinServiceImage verifies the signature of the image of a service creation or update request according to the
+// image trust policy of the endpoint group. A verified image is replaced in the request by its reference pinned by digest.
+func (transport *Transport) pinServiceImage(request *http.Request) error {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+
+	// the request is kept as raw JSON values to forward the other fields unchanged
+	var service, taskTemplate, containerSpec map[string]json.RawMessage
+	err = json.Unmarshal(body, &service)
+	if err != nil {
+		return err
+	}
+
+	if rawTaskTemplate, ok := service["TaskTemplate"]; ok {
+		err = json.Unmarshal(rawTaskTemplate, &taskTemplate)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rawContainerSpec, ok := taskTemplate["ContainerSpec"]; ok {
+		err = json.Unmarshal(rawContainerSpec, &containerSpec)
+		if err != nil {
+			return err
+		}
+	}
+
+	var image string
+	if rawImage, ok := containerSpec["Image"]; ok {
+		err = json.Unmarshal(rawImage, &image)
+		if err != nil {
+			return err
+		}
+	}
+
+	if image != "" {
+		pinnedImage, err := transport.pinnedImage(image)
+		if err != nil {
+			return err
+		}
+
+		if pinnedImage != image {
+			containerSpec["Image"], err = json.Marshal(pinnedImage)
+			if err != nil {
+				return err
+			}
+
+			taskTemplate["ContainerSpec"], err = json.Marshal(containerSpec)
+			if err != nil {
+				return err
+			}
+
+			service["TaskTemplate"], err = json.Marshal(taskTemplate)
+			if err != nil {
+				return err
+			}
+
+			body, err = json.Marshal(service)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	request.ContentLength = int64(len(body))
+	return nil
+}
@@ -29,6 +29,7 @@ type (
 		dataStore            portainer.DataStore
 		signatureService     portainer.DigitalSignatureService
 		reverseTunnelService portainer.ReverseTunnelService
+		imageTrustService    portainer.ImageTrustService
 		dockerClient         *client.Client
 		dockerClientFactory  *docker.ClientFactory
 	}
@@ -39,6 +40,7 @@ type (
 		DataStore            portainer.DataStore
 		SignatureService     portainer.DigitalSignatureService
 		ReverseTunnelService portainer.ReverseTunnelService
+		ImageTrustService    portainer.ImageTrustService
 		DockerClientFactory  *docker.ClientFactory
 	}
 
@@ -69,6 +71,7 @@ func NewTransport(parameters *TransportParameters, httpTransport *http.Transport
 		dataStore:            parameters.DataStore,
 		signatureService:     parameters.SignatureService,
 		reverseTunnelService: parameters.ReverseTunnelService,
+		imageTrustService:    parameters.ImageTrustService,
 		dockerClientFactory:  parameters.DockerClientFactory,
 		HTTPTransport:        httpTransport,
 		dockerClient:         dockerClient,
@@ -239,6 +242,9 @@ func (transport *Transport) proxyServiceRequest(request *http.Request) (*http.Re
 		if match, _ := path.Match("/services/*/*", requestPath); match {
 			// Handle /services/{id}/{action} requests
 			serviceID := path.Base(path.Dir(requestPath))
+			if path.Base(requestPath) == "update" {
+				return transport.decorateServiceUpdateOperation(request, serviceID)
+			}
 			return transport.restrictedResourceOperation(request, serviceID, portainer.ServiceResourceControl, false)
 		} else if match, _ := path.Match("/services/*", requestPath); match {
 			// Handle /services/{id} requests
@@ -383,7 +389,10 @@ func (transport *Transport) replaceRegistryAuthenticationHeader(request *http.Re
 			return nil, err
 		}
 
-		authenticationHeader := createRegistryAuthenticationHeader(originalHeaderData.Serveraddress, accessContext)
+		authenticationHeader, err := createRegistryAuthenticationHeader(originalHeaderData.Serveraddress, accessContext)
+		if err != nil {
+			return nil, err
+		}
 
 		headerData, err := json.Marshal(authenticationHeader)
 		if err != nil {
@@ -17,6 +17,7 @@ func (factory ProxyFactory) newOSBasedLocalProxy(path string, endpoint *portaine
 		DataStore:            factory.dataStore,
 		ReverseTunnelService: factory.reverseTunnelService,
 		SignatureService:     factory.signatureService,
+		ImageTrustService:    factory.imageTrustService,
 		DockerClientFactory:  factory.dockerClientFactory,
 	}
 
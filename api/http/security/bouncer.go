@@ -125,6 +125,12 @@ func (bouncer *RequestBouncer) AuthorizedEdgeEndpointOperation(r *http.Request,
 		return errors.New("invalid Edge identifier")
 	}
 
+	for _, revokedIdentifier := range endpoint.RevokedEdgeIDs {
+		if revokedIdentifier == edgeIdentifier {
+			return errors.New("revoked Edge identifier")
+		}
+	}
+
 	return nil
 }
 
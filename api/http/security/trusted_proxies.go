@@ -0,0 +1,83 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies represents the reverse proxies allowed to set the X-Forwarded-* headers.
+// The headers of a request are ignored unless it comes from one of these addresses.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a list of IP addresses and CIDR ranges
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid trusted proxy address: %s", value)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxy range: %s", value)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// ForwardedHeader returns the value of a X-Forwarded-* header when the request comes from a trusted proxy
+func (proxies TrustedProxies) ForwardedHeader(r *http.Request, header string) string {
+	if !proxies.contains(StripAddrPort(r.RemoteAddr)) {
+		return ""
+	}
+	return r.Header.Get(header)
+}
+
+// ClientIP returns the address of the client of a request. When the request comes from a trusted proxy,
+// the X-Forwarded-For header is read from the right and the first address that is not a trusted proxy is returned.
+func (proxies TrustedProxies) ClientIP(r *http.Request) string {
+	remoteIP := StripAddrPort(r.RemoteAddr)
+
+	forwardedFor := proxies.ForwardedHeader(r, "X-Forwarded-For")
+	if forwardedFor == "" {
+		return remoteIP
+	}
+
+	addresses := strings.Split(forwardedFor, ",")
+	for i := len(addresses) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(addresses[i])
+		if !proxies.contains(address) {
+			return address
+		}
+	}
+
+	return strings.TrimSpace(addresses[0])
+}
+
+func (proxies TrustedProxies) contains(address string) bool {
+	ip := net.ParseIP(strings.Trim(address, "[]"))
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
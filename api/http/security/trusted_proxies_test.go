@@ -0,0 +1,25 @@
+package security
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TrustedProxies_ClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.1", "192.168.0.0/16"})
+	assert.NoError(t, err)
+
+	untrustedRequest := &http.Request{RemoteAddr: "203.0.113.5:4000", Header: http.Header{}}
+	untrustedRequest.Header.Set("X-Forwarded-For", "198.51.100.1")
+	assert.Equal(t, "203.0.113.5", proxies.ClientIP(untrustedRequest))
+	assert.Equal(t, "", proxies.ForwardedHeader(untrustedRequest, "X-Forwarded-For"))
+
+	trustedRequest := &http.Request{RemoteAddr: "10.0.0.1:4000", Header: http.Header{}}
+	trustedRequest.Header.Set("X-Forwarded-For", "198.51.100.7, 198.51.100.1, 192.168.1.1")
+	assert.Equal(t, "198.51.100.1", proxies.ClientIP(trustedRequest))
+
+	_, err = ParseTrustedProxies([]string{"not-an-address"})
+	assert.Error(t, err)
+}
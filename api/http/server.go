@@ -12,6 +12,7 @@ import (
 	"github.com/cloudogu/portainer-ce/api/http/handler/auth"
 	"github.com/cloudogu/portainer-ce/api/http/handler/customtemplates"
 	"github.com/cloudogu/portainer-ce/api/http/handler/dockerhub"
+	"github.com/cloudogu/portainer-ce/api/http/handler/edgeenrollment"
 	"github.com/cloudogu/portainer-ce/api/http/handler/edgegroups"
 	"github.com/cloudogu/portainer-ce/api/http/handler/edgejobs"
 	"github.com/cloudogu/portainer-ce/api/http/handler/edgestacks"
@@ -21,6 +22,9 @@ import (
 	"github.com/cloudogu/portainer-ce/api/http/handler/endpointproxy"
 	"github.com/cloudogu/portainer-ce/api/http/handler/endpoints"
 	"github.com/cloudogu/portainer-ce/api/http/handler/file"
+	"github.com/cloudogu/portainer-ce/api/http/handler/housekeeping"
+	"github.com/cloudogu/portainer-ce/api/http/handler/imagescans"
+	kubehandler "github.com/cloudogu/portainer-ce/api/http/handler/kubernetes"
 	"github.com/cloudogu/portainer-ce/api/http/handler/motd"
 	"github.com/cloudogu/portainer-ce/api/http/handler/registries"
 	"github.com/cloudogu/portainer-ce/api/http/handler/resourcecontrols"
@@ -39,36 +43,47 @@ import (
 	"github.com/cloudogu/portainer-ce/api/http/proxy"
 	"github.com/cloudogu/portainer-ce/api/http/proxy/factory/kubernetes"
 	"github.com/cloudogu/portainer-ce/api/http/security"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
 
 	"github.com/cloudogu/portainer-ce/api/kubernetes/cli"
 )
 
 // Server implements the portainer.Server interface
 type Server struct {
-	BindAddress                 string
-	AssetsPath                  string
-	Status                      *portainer.Status
-	ReverseTunnelService        portainer.ReverseTunnelService
-	ComposeStackManager         portainer.ComposeStackManager
-	CryptoService               portainer.CryptoService
-	SignatureService            portainer.DigitalSignatureService
-	SnapshotService             portainer.SnapshotService
-	FileService                 portainer.FileService
-	DataStore                   portainer.DataStore
-	GitService                  portainer.GitService
-	JWTService                  portainer.JWTService
-	LDAPService                 portainer.LDAPService
-	OAuthService                portainer.OAuthService
-	SwarmStackManager           portainer.SwarmStackManager
-	ProxyManager                *proxy.Manager
-	KubernetesTokenCacheManager *kubernetes.TokenCacheManager
-	Handler                     *handler.Handler
-	SSL                         bool
-	SSLCert                     string
-	SSLKey                      string
-	DockerClientFactory         *docker.ClientFactory
-	KubernetesClientFactory     *cli.ClientFactory
-	KubernetesDeployer          portainer.KubernetesDeployer
+	BindAddress                  string
+	AssetsPath                   string
+	Status                       *portainer.Status
+	ReverseTunnelService         portainer.ReverseTunnelService
+	ComposeStackManager          portainer.ComposeStackManager
+	CryptoService                portainer.CryptoService
+	SignatureService             portainer.DigitalSignatureService
+	SecretService                portainer.SecretService
+	SnapshotService              portainer.SnapshotService
+	FileService                  portainer.FileService
+	DataStore                    portainer.DataStore
+	GitService                   portainer.GitService
+	HousekeepingService          portainer.HousekeepingService
+	ImageUpdateService           portainer.ImageUpdateService
+	EdgeStackGitSyncService      portainer.EdgeStackGitSyncService
+	CustomTemplateGitSyncService portainer.CustomTemplateGitSyncService
+	RegistryRetentionService     portainer.RegistryRetentionService
+	VulnerabilityScanService     portainer.VulnerabilityScanService
+	ImageTrustService            portainer.ImageTrustService
+	TemplateCatalogService       portainer.TemplateCatalogService
+	JWTService                   portainer.JWTService
+	LDAPService                  portainer.LDAPService
+	OAuthService                 portainer.OAuthService
+	SwarmStackManager            portainer.SwarmStackManager
+	ProxyManager                 *proxy.Manager
+	KubernetesTokenCacheManager  *kubernetes.TokenCacheManager
+	Handler                      *handler.Handler
+	SSL                          bool
+	SSLCert                      string
+	SSLKey                       string
+	TrustedProxies               security.TrustedProxies
+	DockerClientFactory          *docker.ClientFactory
+	KubernetesClientFactory      *cli.ClientFactory
+	KubernetesDeployer           portainer.KubernetesDeployer
 }
 
 // Start starts the HTTP server
@@ -79,6 +94,8 @@ func (server *Server) Start() error {
 
 	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
 
+	secretEnvSender := edge.NewSecretEnvSender(server.DataStore, server.ReverseTunnelService)
+
 	var authHandler = auth.NewHandler(requestBouncer, rateLimiter)
 	authHandler.DataStore = server.DataStore
 	authHandler.CryptoService = server.CryptoService
@@ -95,12 +112,18 @@ func (server *Server) Start() error {
 	customTemplatesHandler.DataStore = server.DataStore
 	customTemplatesHandler.FileService = server.FileService
 	customTemplatesHandler.GitService = server.GitService
+	customTemplatesHandler.GitSyncService = server.CustomTemplateGitSyncService
 
 	var dockerHubHandler = dockerhub.NewHandler(requestBouncer)
 	dockerHubHandler.DataStore = server.DataStore
 
+	var edgeEnrollmentHandler = edgeenrollment.NewHandler(requestBouncer)
+	edgeEnrollmentHandler.DataStore = server.DataStore
+	edgeEnrollmentHandler.ReverseTunnelService = server.ReverseTunnelService
+
 	var edgeGroupsHandler = edgegroups.NewHandler(requestBouncer)
 	edgeGroupsHandler.DataStore = server.DataStore
+	edgeGroupsHandler.ReverseTunnelService = server.ReverseTunnelService
 
 	var edgeJobsHandler = edgejobs.NewHandler(requestBouncer)
 	edgeJobsHandler.DataStore = server.DataStore
@@ -111,6 +134,12 @@ func (server *Server) Start() error {
 	edgeStacksHandler.DataStore = server.DataStore
 	edgeStacksHandler.FileService = server.FileService
 	edgeStacksHandler.GitService = server.GitService
+	edgeStacksHandler.GitSyncService = server.EdgeStackGitSyncService
+	edgeStacksHandler.DockerClientFactory = server.DockerClientFactory
+	edgeStacksHandler.ReverseTunnelService = server.ReverseTunnelService
+	edgeStacksHandler.SignatureService = server.SignatureService
+	edgeStacksHandler.SecretService = server.SecretService
+	edgeStacksHandler.ImageTrustService = server.ImageTrustService
 
 	var edgeTemplatesHandler = edgetemplates.NewHandler(requestBouncer)
 	edgeTemplatesHandler.DataStore = server.DataStore
@@ -122,14 +151,24 @@ func (server *Server) Start() error {
 	endpointHandler.SnapshotService = server.SnapshotService
 	endpointHandler.ReverseTunnelService = server.ReverseTunnelService
 	endpointHandler.ComposeStackManager = server.ComposeStackManager
+	endpointHandler.DockerClientFactory = server.DockerClientFactory
+	endpointHandler.ImageUpdateService = server.ImageUpdateService
+	endpointHandler.VulnerabilityScanService = server.VulnerabilityScanService
+	endpointHandler.TrustedProxies = server.TrustedProxies
+	endpointHandler.SecretService = server.SecretService
+	endpointHandler.SecretEnvSender = secretEnvSender
 
 	var endpointEdgeHandler = endpointedge.NewHandler(requestBouncer)
 	endpointEdgeHandler.DataStore = server.DataStore
 	endpointEdgeHandler.FileService = server.FileService
 	endpointEdgeHandler.ReverseTunnelService = server.ReverseTunnelService
+	endpointEdgeHandler.ImageTrustService = server.ImageTrustService
+	endpointEdgeHandler.SecretService = server.SecretService
+	endpointEdgeHandler.SecretEnvSender = secretEnvSender
 
 	var endpointGroupHandler = endpointgroups.NewHandler(requestBouncer)
 	endpointGroupHandler.DataStore = server.DataStore
+	endpointGroupHandler.ReverseTunnelService = server.ReverseTunnelService
 
 	var endpointProxyHandler = endpointproxy.NewHandler(requestBouncer)
 	endpointProxyHandler.DataStore = server.DataStore
@@ -138,12 +177,30 @@ func (server *Server) Start() error {
 
 	var fileHandler = file.NewHandler(filepath.Join(server.AssetsPath, "public"))
 
+	var housekeepingHandler = housekeeping.NewHandler(requestBouncer)
+	housekeepingHandler.DataStore = server.DataStore
+	housekeepingHandler.HousekeepingService = server.HousekeepingService
+
+	var imageScanHandler = imagescans.NewHandler(requestBouncer)
+	imageScanHandler.DataStore = server.DataStore
+	imageScanHandler.VulnerabilityScanService = server.VulnerabilityScanService
+
+	var kubernetesHandler = kubehandler.NewHandler(requestBouncer)
+	kubernetesHandler.DataStore = server.DataStore
+	kubernetesHandler.JWTService = server.JWTService
+	kubernetesHandler.FileService = server.FileService
+	kubernetesHandler.TrustedProxies = server.TrustedProxies
+	if server.SSL {
+		kubernetesHandler.SSLCert = server.SSLCert
+	}
+
 	var motdHandler = motd.NewHandler(requestBouncer)
 
 	var registryHandler = registries.NewHandler(requestBouncer)
 	registryHandler.DataStore = server.DataStore
 	registryHandler.FileService = server.FileService
 	registryHandler.ProxyManager = server.ProxyManager
+	registryHandler.RetentionService = server.RegistryRetentionService
 
 	var resourceControlHandler = resourcecontrols.NewHandler(requestBouncer)
 	resourceControlHandler.DataStore = server.DataStore
@@ -153,6 +210,7 @@ func (server *Server) Start() error {
 	settingsHandler.FileService = server.FileService
 	settingsHandler.JWTService = server.JWTService
 	settingsHandler.LDAPService = server.LDAPService
+	settingsHandler.ReverseTunnelService = server.ReverseTunnelService
 	settingsHandler.SnapshotService = server.SnapshotService
 
 	var stackHandler = stacks.NewHandler(requestBouncer)
@@ -162,9 +220,12 @@ func (server *Server) Start() error {
 	stackHandler.ComposeStackManager = server.ComposeStackManager
 	stackHandler.KubernetesDeployer = server.KubernetesDeployer
 	stackHandler.GitService = server.GitService
+	stackHandler.VulnerabilityScanService = server.VulnerabilityScanService
+	stackHandler.ImageTrustService = server.ImageTrustService
 
 	var tagHandler = tags.NewHandler(requestBouncer)
 	tagHandler.DataStore = server.DataStore
+	tagHandler.ReverseTunnelService = server.ReverseTunnelService
 
 	var teamHandler = teams.NewHandler(requestBouncer)
 	teamHandler.DataStore = server.DataStore
@@ -178,6 +239,7 @@ func (server *Server) Start() error {
 	templatesHandler.DataStore = server.DataStore
 	templatesHandler.FileService = server.FileService
 	templatesHandler.GitService = server.GitService
+	templatesHandler.CatalogService = server.TemplateCatalogService
 
 	var uploadHandler = upload.NewHandler(requestBouncer)
 	uploadHandler.FileService = server.FileService
@@ -201,6 +263,7 @@ func (server *Server) Start() error {
 		AuthHandler:            authHandler,
 		CustomTemplatesHandler: customTemplatesHandler,
 		DockerHubHandler:       dockerHubHandler,
+		EdgeEnrollmentHandler:  edgeEnrollmentHandler,
 		EdgeGroupsHandler:      edgeGroupsHandler,
 		EdgeJobsHandler:        edgeJobsHandler,
 		EdgeStacksHandler:      edgeStacksHandler,
@@ -210,6 +273,9 @@ func (server *Server) Start() error {
 		EndpointEdgeHandler:    endpointEdgeHandler,
 		EndpointProxyHandler:   endpointProxyHandler,
 		FileHandler:            fileHandler,
+		HousekeepingHandler:    housekeepingHandler,
+		ImageScanHandler:       imageScanHandler,
+		KubernetesHandler:      kubernetesHandler,
 		MOTDHandler:            motdHandler,
 		RegistryHandler:        registryHandler,
 		ResourceControlHandler: resourceControlHandler,
@@ -0,0 +1,165 @@
+package composefile
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/docker/cli/cli/compose/loader"
+	"github.com/docker/cli/cli/compose/types"
+	"gopkg.in/yaml.v2"
+)
+
+// ServiceImages returns the image of each service of a Compose file, interpolated with the specified
+// environment. Services only defining a build context use a locally built image and are not returned.
+func ServiceImages(content []byte, env []portainer.Pair) (map[string]string, error) {
+	composeConfigYAML, err := loader.ParseYAML(content)
+	if err != nil {
+		return nil, err
+	}
+
+	environment := make(map[string]string)
+	for _, pair := range env {
+		environment[pair.Name] = pair.Value
+	}
+
+	composeConfig, err := loader.Load(types.ConfigDetails{
+		ConfigFiles: []types.ConfigFile{{Config: composeConfigYAML}},
+		Environment: environment,
+	}, func(options *loader.Options) {
+		options.SkipValidation = true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	images := make(map[string]string)
+	for _, service := range composeConfig.Services {
+		if service.Image != "" {
+			images[service.Name] = service.Image
+		}
+	}
+
+	return images, nil
+}
+
+// ReplaceServiceImages returns a copy of a Compose file where the image of the specified services
+// is replaced. Comments are not kept and anchors are expanded.
+func ReplaceServiceImages(content []byte, images map[string]string) ([]byte, error) {
+	var document yaml.MapSlice
+	err := yaml.Unmarshal(content, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range document {
+		if document[idx].Key != "services" {
+			continue
+		}
+
+		services, ok := document[idx].Value.(yaml.MapSlice)
+		if !ok {
+			return nil, errors.New("Invalid services definition")
+		}
+
+		for serviceIdx := range services {
+			image, ok := images[fmt.Sprint(services[serviceIdx].Key)]
+			if !ok {
+				continue
+			}
+
+			service, ok := services[serviceIdx].Value.(yaml.MapSlice)
+			if !ok {
+				return nil, fmt.Errorf("Invalid definition of service %v", services[serviceIdx].Key)
+			}
+			services[serviceIdx].Value = setValue(service, "image", image)
+		}
+	}
+
+	return yaml.Marshal(document)
+}
+
+// PinnedStack returns a copy of a stack referencing a copy of its stack file with the specified content.
+// The file is created in the directory of the stack file so that the relative paths keep resolving,
+// it is removed by the returned function once the stack is deployed.
+func PinnedStack(stack *portainer.Stack, content []byte) (*portainer.Stack, func(), error) {
+	stackFilePath := path.Join(stack.ProjectPath, stack.EntryPoint)
+
+	file, err := ioutil.TempFile(filepath.Dir(stackFilePath), ".pinned-*.yml")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanUp := func() {
+		err := os.Remove(file.Name())
+		if err != nil {
+			log.Printf("[WARN] [internal,composefile] [message: unable to remove the pinned stack file] [path: %s] [err: %s]", file.Name(), err)
+		}
+	}
+
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanUp()
+		return nil, nil, err
+	}
+
+	pinnedStack := *stack
+	pinnedStack.EntryPoint = path.Join(path.Dir(stack.EntryPoint), filepath.Base(file.Name()))
+	return &pinnedStack, cleanUp, nil
+}
+
+func setValue(mapping yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for idx := range mapping {
+		if mapping[idx].Key == key {
+			mapping[idx].Value = value
+			return mapping
+		}
+	}
+	return append(mapping, yaml.MapItem{Key: key, Value: value})
+}
+
+// ScannedStack applies the vulnerability deploy gate to the images of a stack file with the specified content.
+// The images already pinned by digest are scanned by digest, the other ones are pinned by the scanned digest
+// in a copy of the stack file removed by the returned function once the stack is deployed.
+func ScannedStack(stack *portainer.Stack, content []byte, vulnerabilityScanService portainer.VulnerabilityScanService) (*portainer.Stack, func(), error) {
+	serviceImages, err := ServiceImages(content, stack.Env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	images := make([]string, 0, len(serviceImages))
+	for _, image := range serviceImages {
+		images = append(images, image)
+	}
+
+	pinnedImages, err := vulnerabilityScanService.VerifyImages(images)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replacements := make(map[string]string)
+	for name, image := range serviceImages {
+		if pinnedImage, ok := pinnedImages[image]; ok && pinnedImage != image {
+			replacements[name] = pinnedImage
+		}
+	}
+
+	if len(replacements) == 0 {
+		return stack, func() {}, nil
+	}
+
+	pinnedContent, err := ReplaceServiceImages(content, replacements)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return PinnedStack(stack, pinnedContent)
+}
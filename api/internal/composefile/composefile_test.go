@@ -0,0 +1,44 @@
+package composefile
+
+import (
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+const stackFile = `version: "3.7"
+x-defaults: &defaults
+  restart: always
+services:
+  web:
+    <<: *defaults
+    image: "nginx:${NGINX_VERSION}"
+    ports:
+      - "80:80"
+  app:
+    build: ./app
+  db:
+    image: postgres:13
+    environment:
+      POSTGRES_PASSWORD: $${SECRET}
+`
+
+func Test_ServiceImages(t *testing.T) {
+	images, err := ServiceImages([]byte(stackFile), []portainer.Pair{{Name: "NGINX_VERSION", Value: "1.19"}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": "nginx:1.19", "db": "postgres:13"}, images)
+}
+
+func Test_ReplaceServiceImages(t *testing.T) {
+	pinnedWeb := "nginx:1.19@sha256:0b970013351304af46f322da1263516b188318682b2ab1091862497591189ff1"
+
+	content, err := ReplaceServiceImages([]byte(stackFile), map[string]string{"web": pinnedWeb, "app": "app:local"})
+	assert.NoError(t, err)
+
+	images, err := ServiceImages(content, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": pinnedWeb, "app": "app:local", "db": "postgres:13"}, images)
+	assert.Contains(t, string(content), "POSTGRES_PASSWORD: $${SECRET}")
+	assert.Contains(t, string(content), "restart: always")
+}
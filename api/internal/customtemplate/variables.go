@@ -0,0 +1,254 @@
+package customtemplate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/cloudogu/portainer-ce/api"
+)
+
+var (
+	variableNameFormat  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	variablePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// ValidateVariables checks the definitions of the variables of a custom template. The default
+// values must satisfy the rules of their variable.
+func ValidateVariables(variables []portainer.CustomTemplateVariable) error {
+	names := map[string]bool{}
+
+	for _, variable := range variables {
+		if !variableNameFormat.MatchString(variable.Name) {
+			return fmt.Errorf("Invalid variable name %q. Allowed characters are: [A-Za-z0-9_]", variable.Name)
+		}
+		if names[variable.Name] {
+			return fmt.Errorf("Variable %s is declared more than once", variable.Name)
+		}
+		names[variable.Name] = true
+
+		switch variable.Type {
+		case portainer.CustomTemplateVariableString, portainer.CustomTemplateVariableSecret:
+			if variable.Pattern != "" {
+				_, err := regexp.Compile(variable.Pattern)
+				if err != nil {
+					return fmt.Errorf("Invalid pattern for variable %s: %s", variable.Name, err)
+				}
+			}
+			if variable.Type == portainer.CustomTemplateVariableSecret && variable.Default != "" {
+				return fmt.Errorf("Secret variable %s cannot have a default value", variable.Name)
+			}
+		case portainer.CustomTemplateVariableNumber:
+			if variable.Min != nil && variable.Max != nil && *variable.Min > *variable.Max {
+				return fmt.Errorf("Invalid range for variable %s", variable.Name)
+			}
+		case portainer.CustomTemplateVariableBoolean:
+		case portainer.CustomTemplateVariableSelect:
+			if len(variable.Options) == 0 {
+				return fmt.Errorf("Select variable %s must have at least one option", variable.Name)
+			}
+		default:
+			return fmt.Errorf("Invalid type for variable %s. Value must be one of: string, number, boolean, select or secret", variable.Name)
+		}
+
+		if variable.Default != "" {
+			_, err := validateValue(&variable, variable.Default)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RenderStackFile replaces the placeholders of the variables of a custom template by their values inside a stack file.
+// Variables without value use their default value. Placeholders that do not match a declared variable are left untouched.
+// The values of the secret variables are never written in the stack file: their placeholders are replaced by a reference
+// to the environment variable of the same name, ${NAME}, and their values are returned to be passed as stack environment variables.
+func RenderStackFile(stackFileContent []byte, variables []portainer.CustomTemplateVariable, values []portainer.Pair) ([]byte, []portainer.Pair, error) {
+	resolved, err := resolveValues(variables, values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets := secretNames(variables)
+	secretEnv := []portainer.Pair{}
+	for _, variable := range variables {
+		if secrets[variable.Name] {
+			secretEnv = append(secretEnv, portainer.Pair{Name: variable.Name, Value: resolved[variable.Name]})
+		}
+	}
+
+	rendered := variablePlaceholder.ReplaceAllFunc(stackFileContent, func(placeholder []byte) []byte {
+		name := string(variablePlaceholder.FindSubmatch(placeholder)[1])
+		if secrets[name] {
+			return []byte("${" + name + "}")
+		}
+		if value, ok := resolved[name]; ok {
+			return []byte(value)
+		}
+		return placeholder
+	})
+
+	return rendered, secretEnv, nil
+}
+
+// MergeSecretEnv returns the environment variables of a stack with the values of the secret variables of its
+// custom template, which replace the environment variables of the same name
+func MergeSecretEnv(env, secretEnv []portainer.Pair) []portainer.Pair {
+	secrets := map[string]bool{}
+	for _, pair := range secretEnv {
+		secrets[pair.Name] = true
+	}
+
+	merged := []portainer.Pair{}
+	for _, pair := range env {
+		if !secrets[pair.Name] {
+			merged = append(merged, pair)
+		}
+	}
+	return append(merged, secretEnv...)
+}
+
+// PersistableValues returns the values of the non secret variables of a custom template. These values are kept
+// with the stacks deployed from the template to render the newer versions of the template.
+func PersistableValues(variables []portainer.CustomTemplateVariable, values []portainer.Pair) []portainer.Pair {
+	secrets := secretNames(variables)
+
+	persistable := []portainer.Pair{}
+	for _, value := range values {
+		if !secrets[value.Name] {
+			persistable = append(persistable, value)
+		}
+	}
+	return persistable
+}
+
+// ValidateSecretValues checks that a value is specified for each secret variable of a custom template. The values
+// of the secret variables are not kept with the stacks and must be specified each time a stack is deployed.
+func ValidateSecretValues(variables []portainer.CustomTemplateVariable, values []portainer.Pair) error {
+	specified := map[string]bool{}
+	for _, value := range values {
+		specified[value.Name] = true
+	}
+
+	for _, variable := range variables {
+		if variable.Type == portainer.CustomTemplateVariableSecret && !specified[variable.Name] {
+			return fmt.Errorf("The value of secret variable %s must be specified again", variable.Name)
+		}
+	}
+	return nil
+}
+
+// MergeValues returns the kept values that are still declared by the variables of a custom template,
+// overridden by the specified values
+func MergeValues(variables []portainer.CustomTemplateVariable, keptValues, values []portainer.Pair) []portainer.Pair {
+	declared := map[string]bool{}
+	for _, variable := range variables {
+		declared[variable.Name] = true
+	}
+
+	specified := map[string]bool{}
+	for _, value := range values {
+		specified[value.Name] = true
+	}
+
+	merged := []portainer.Pair{}
+	for _, value := range keptValues {
+		if declared[value.Name] && !specified[value.Name] {
+			merged = append(merged, value)
+		}
+	}
+	return append(merged, values...)
+}
+
+func secretNames(variables []portainer.CustomTemplateVariable) map[string]bool {
+	secrets := map[string]bool{}
+	for _, variable := range variables {
+		if variable.Type == portainer.CustomTemplateVariableSecret {
+			secrets[variable.Name] = true
+		}
+	}
+	return secrets
+}
+
+func resolveValues(variables []portainer.CustomTemplateVariable, values []portainer.Pair) (map[string]string, error) {
+	provided := map[string]string{}
+	for _, value := range values {
+		provided[value.Name] = value.Value
+	}
+
+	resolved := map[string]string{}
+	for idx := range variables {
+		variable := &variables[idx]
+
+		value, ok := provided[variable.Name]
+		delete(provided, variable.Name)
+		if !ok || value == "" {
+			value = variable.Default
+		}
+
+		if value == "" {
+			if variable.Required {
+				return nil, fmt.Errorf("A value is required for variable %s", variable.Name)
+			}
+			resolved[variable.Name] = ""
+			continue
+		}
+
+		value, err := validateValue(variable, value)
+		if err != nil {
+			return nil, err
+		}
+		resolved[variable.Name] = value
+	}
+
+	for name := range provided {
+		return nil, fmt.Errorf("Unknown variable %s", name)
+	}
+
+	return resolved, nil
+}
+
+// validateValue checks a value against the rules of a variable and returns its normalized form
+func validateValue(variable *portainer.CustomTemplateVariable, value string) (string, error) {
+	switch variable.Type {
+	case portainer.CustomTemplateVariableString, portainer.CustomTemplateVariableSecret:
+		if variable.Pattern != "" {
+			pattern, err := regexp.Compile("^(?:" + variable.Pattern + ")$")
+			if err != nil {
+				return "", err
+			}
+			if !pattern.MatchString(value) {
+				return "", fmt.Errorf("The value of variable %s does not match the expected format", variable.Name)
+			}
+		}
+	case portainer.CustomTemplateVariableNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("The value of variable %s must be a number", variable.Name)
+		}
+		if (variable.Min != nil && number < *variable.Min) || (variable.Max != nil && number > *variable.Max) {
+			return "", fmt.Errorf("The value of variable %s is out of range", variable.Name)
+		}
+	case portainer.CustomTemplateVariableBoolean:
+		boolean, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("The value of variable %s must be true or false", variable.Name)
+		}
+		value = strconv.FormatBool(boolean)
+	case portainer.CustomTemplateVariableSelect:
+		for _, option := range variable.Options {
+			if option.Value == value {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("The value of variable %s must be one of its options", variable.Name)
+	default:
+		return "", errors.New("Invalid variable type")
+	}
+
+	return value, nil
+}
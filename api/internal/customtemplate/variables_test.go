@@ -0,0 +1,87 @@
+package customtemplate
+
+import (
+	"testing"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidateVariables(t *testing.T) {
+	max := 10.0
+	min := 20.0
+
+	assert.NoError(t, ValidateVariables([]portainer.CustomTemplateVariable{
+		{Name: "PORT", Type: portainer.CustomTemplateVariableNumber, Default: "8080"},
+		{Name: "MODE", Type: portainer.CustomTemplateVariableSelect, Default: "prod", Options: []portainer.CustomTemplateVariableOption{{Label: "Production", Value: "prod"}}},
+	}))
+
+	assert.Error(t, ValidateVariables([]portainer.CustomTemplateVariable{{Name: "1NAME", Type: portainer.CustomTemplateVariableString}}))
+	assert.Error(t, ValidateVariables([]portainer.CustomTemplateVariable{{Name: "TOKEN", Type: portainer.CustomTemplateVariableSecret, Default: "abc"}}))
+	assert.Error(t, ValidateVariables([]portainer.CustomTemplateVariable{{Name: "COUNT", Type: portainer.CustomTemplateVariableNumber, Min: &min, Max: &max}}))
+	assert.Error(t, ValidateVariables([]portainer.CustomTemplateVariable{{Name: "DEBUG", Type: portainer.CustomTemplateVariableBoolean, Default: "maybe"}}))
+	assert.Error(t, ValidateVariables([]portainer.CustomTemplateVariable{{Name: "A", Type: "list"}}))
+}
+
+func Test_RenderStackFile(t *testing.T) {
+	max := 65535.0
+	variables := []portainer.CustomTemplateVariable{
+		{Name: "IMAGE_TAG", Type: portainer.CustomTemplateVariableString, Default: "latest", Pattern: `[a-z0-9.]+`},
+		{Name: "PORT", Type: portainer.CustomTemplateVariableNumber, Required: true, Max: &max},
+		{Name: "DEBUG", Type: portainer.CustomTemplateVariableBoolean},
+		{Name: "PASSWORD", Type: portainer.CustomTemplateVariableSecret},
+	}
+	stackFile := []byte("image: app:{{ IMAGE_TAG }}\nports: [\"{{PORT}}:80\"]\ndebug: {{ DEBUG }}\npassword: {{ PASSWORD }}\nhostname: {{.Node.Hostname}}\nother: {{ UNDECLARED }}\n")
+
+	rendered, secretEnv, err := RenderStackFile(stackFile, variables, []portainer.Pair{{Name: "PORT", Value: "8080"}, {Name: "DEBUG", Value: "1"}, {Name: "PASSWORD", Value: "s3cret"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "image: app:latest\nports: [\"8080:80\"]\ndebug: true\npassword: ${PASSWORD}\nhostname: {{.Node.Hostname}}\nother: {{ UNDECLARED }}\n", string(rendered))
+	assert.NotContains(t, string(rendered), "s3cret", "secret values are never rendered in the stack file")
+	assert.Equal(t, []portainer.Pair{{Name: "PASSWORD", Value: "s3cret"}}, secretEnv)
+
+	_, _, err = RenderStackFile(stackFile, variables, nil)
+	assert.Error(t, err, "PORT is required")
+
+	_, _, err = RenderStackFile(stackFile, variables, []portainer.Pair{{Name: "PORT", Value: "70000"}})
+	assert.Error(t, err, "PORT is out of range")
+
+	_, _, err = RenderStackFile(stackFile, variables, []portainer.Pair{{Name: "PORT", Value: "80"}, {Name: "IMAGE_TAG", Value: "Bad Tag"}})
+	assert.Error(t, err, "IMAGE_TAG does not match the pattern")
+
+	_, _, err = RenderStackFile(stackFile, variables, []portainer.Pair{{Name: "PORT", Value: "80"}, {Name: "UNKNOWN", Value: "x"}})
+	assert.Error(t, err)
+}
+
+func Test_MergeValues(t *testing.T) {
+	variables := []portainer.CustomTemplateVariable{
+		{Name: "PORT", Type: portainer.CustomTemplateVariableNumber},
+		{Name: "TAG", Type: portainer.CustomTemplateVariableString},
+		{Name: "PASSWORD", Type: portainer.CustomTemplateVariableSecret},
+	}
+
+	kept := PersistableValues(variables, []portainer.Pair{{Name: "PORT", Value: "80"}, {Name: "PASSWORD", Value: "s3cret"}, {Name: "REMOVED", Value: "x"}})
+	assert.Equal(t, []portainer.Pair{{Name: "PORT", Value: "80"}, {Name: "REMOVED", Value: "x"}}, kept)
+
+	merged := MergeValues(variables, kept, []portainer.Pair{{Name: "TAG", Value: "1.2"}, {Name: "PASSWORD", Value: "other"}})
+	assert.Equal(t, []portainer.Pair{{Name: "PORT", Value: "80"}, {Name: "TAG", Value: "1.2"}, {Name: "PASSWORD", Value: "other"}}, merged)
+
+	merged = MergeValues(variables, kept, []portainer.Pair{{Name: "PORT", Value: "8080"}})
+	assert.Equal(t, []portainer.Pair{{Name: "PORT", Value: "8080"}}, merged)
+}
+
+func Test_MergeSecretEnv(t *testing.T) {
+	env := []portainer.Pair{{Name: "PASSWORD", Value: "previous"}, {Name: "REGION", Value: "eu"}}
+
+	merged := MergeSecretEnv(env, []portainer.Pair{{Name: "PASSWORD", Value: "s3cret"}})
+	assert.Equal(t, []portainer.Pair{{Name: "REGION", Value: "eu"}, {Name: "PASSWORD", Value: "s3cret"}}, merged)
+}
+
+func Test_ValidateSecretValues(t *testing.T) {
+	variables := []portainer.CustomTemplateVariable{
+		{Name: "PORT", Type: portainer.CustomTemplateVariableNumber},
+		{Name: "PASSWORD", Type: portainer.CustomTemplateVariableSecret},
+	}
+
+	assert.Error(t, ValidateSecretValues(variables, []portainer.Pair{{Name: "PORT", Value: "80"}}), "the secret values are not kept")
+	assert.NoError(t, ValidateSecretValues(variables, []portainer.Pair{{Name: "PASSWORD", Value: "s3cret"}}))
+}
@@ -0,0 +1,52 @@
+package customtemplate
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cloudogu/portainer-ce/api"
+)
+
+// versionHistoryLimit is the number of versions kept for each custom template
+const versionHistoryLimit = 20
+
+// AddVersion increments the version of a custom template, stores a copy of its file for the new version and
+// records the version in the history of the template. The files of the versions exceeding the history limit
+// are removed.
+func AddVersion(fileService portainer.FileService, customTemplate *portainer.CustomTemplate, fileContent []byte, commitHash string, userID portainer.UserID) error {
+	identifier := strconv.Itoa(int(customTemplate.ID))
+	version := customTemplate.Version + 1
+
+	_, err := fileService.StoreCustomTemplateVersionFileFromBytes(identifier, version, fileContent)
+	if err != nil {
+		return err
+	}
+
+	customTemplate.Version = version
+	customTemplate.History = append(customTemplate.History, portainer.CustomTemplateVersion{
+		Version:         version,
+		CommitHash:      commitHash,
+		CreatedAt:       time.Now().Unix(),
+		CreatedByUserID: userID,
+	})
+
+	for len(customTemplate.History) > versionHistoryLimit {
+		err = fileService.RemoveDirectory(fileService.GetCustomTemplateVersionFilePath(identifier, customTemplate.History[0].Version))
+		if err != nil {
+			return err
+		}
+		customTemplate.History = customTemplate.History[1:]
+	}
+
+	return nil
+}
+
+// FindVersion returns the version of a custom template recorded in its history
+func FindVersion(customTemplate *portainer.CustomTemplate, version int) *portainer.CustomTemplateVersion {
+	for idx := range customTemplate.History {
+		if customTemplate.History[idx].Version == version {
+			return &customTemplate.History[idx]
+		}
+	}
+	return nil
+}
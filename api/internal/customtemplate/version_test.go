@@ -0,0 +1,50 @@
+package customtemplate
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AddVersion(t *testing.T) {
+	dataPath, err := ioutil.TempDir("", "customtemplate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	fileService, err := filesystem.NewService(dataPath, "")
+	assert.NoError(t, err)
+
+	customTemplate := &portainer.CustomTemplate{ID: 1}
+
+	for i := 0; i < versionHistoryLimit+2; i++ {
+		assert.NoError(t, AddVersion(fileService, customTemplate, []byte("version: '3'"), "commit", 1))
+	}
+
+	assert.Equal(t, versionHistoryLimit+2, customTemplate.Version)
+	assert.Len(t, customTemplate.History, versionHistoryLimit)
+	assert.Equal(t, 3, customTemplate.History[0].Version)
+	assert.Equal(t, portainer.UserID(1), customTemplate.History[0].CreatedByUserID)
+
+	_, err = os.Stat(fileService.GetCustomTemplateVersionFilePath("1", 2))
+	assert.True(t, os.IsNotExist(err), "the files of the versions exceeding the history limit are removed")
+
+	content, err := fileService.GetFileContent(fileService.GetCustomTemplateVersionFilePath("1", customTemplate.Version))
+	assert.NoError(t, err)
+	assert.Equal(t, "version: '3'", string(content))
+}
+
+func Test_FindVersion(t *testing.T) {
+	customTemplate := &portainer.CustomTemplate{
+		History: []portainer.CustomTemplateVersion{{Version: 2, CommitHash: "a"}, {Version: 3, CommitHash: "b"}},
+	}
+
+	version := FindVersion(customTemplate, 3)
+	assert.NotNil(t, version)
+	assert.Equal(t, "b", version.CommitHash)
+
+	assert.Nil(t, FindVersion(customTemplate, 1))
+}
@@ -0,0 +1,184 @@
+package customtemplatesync
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/customtemplate"
+	"github.com/cloudogu/portainer-ce/api/internal/gitfile"
+)
+
+var errNoGitConfig = errors.New("The custom template is not linked to a Git repository")
+
+// Service represents a service used to keep the custom templates linked to a Git repository
+// up to date with the latest commit of their reference.
+type Service struct {
+	dataStore     portainer.DataStore
+	fileService   portainer.FileService
+	gitService    portainer.GitService
+	syncInterval  time.Duration
+	refreshSignal chan struct{}
+	mu            sync.Mutex
+}
+
+// NewService creates a new instance of a service
+func NewService(syncInterval string, dataStore portainer.DataStore, fileService portainer.FileService, gitService portainer.GitService) (*Service, error) {
+	interval, err := time.ParseDuration(syncInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		dataStore:    dataStore,
+		fileService:  fileService,
+		gitService:   gitService,
+		syncInterval: interval,
+	}, nil
+}
+
+// Start will start a background routine to periodically check the repositories of the custom templates with auto sync enabled
+func (service *Service) Start() {
+	if service.refreshSignal != nil {
+		return
+	}
+
+	service.refreshSignal = make(chan struct{})
+	service.startSyncLoop()
+}
+
+func (service *Service) startSyncLoop() {
+	ticker := time.NewTicker(service.syncInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				err := service.syncCustomTemplates()
+				if err != nil {
+					log.Printf("[ERROR] [internal,customtemplatesync] [message: background schedule error (custom template Git sync).] [error: %s]", err)
+				}
+
+			case <-service.refreshSignal:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (service *Service) syncCustomTemplates() error {
+	customTemplates, err := service.dataStore.CustomTemplate().CustomTemplates()
+	if err != nil {
+		return err
+	}
+
+	for _, customTemplate := range customTemplates {
+		if customTemplate.GitConfig == nil || !customTemplate.GitConfig.AutoSync {
+			continue
+		}
+
+		_, err := service.SyncCustomTemplate(customTemplate.ID)
+		if err != nil {
+			log.Printf("[WARN] [internal,customtemplatesync] [message: unable to sync custom template with its Git repository] [custom_template: %s] [err: %s]", customTemplate.Title, err)
+		}
+	}
+
+	return nil
+}
+
+// SyncCustomTemplate checks the repository of a custom template for a new commit on its reference. When one is
+// found, the template file is updated from the repository and a new version of the template is recorded.
+// The result of the check is persisted in the Git configuration of the template, the template is not written
+// when the check changed nothing.
+// The repository is checked without holding the lock of the service, the template is read again before being
+// updated and the result is dropped when the Git configuration of the template changed in the meantime.
+func (service *Service) SyncCustomTemplate(customTemplateID portainer.CustomTemplateID) (*portainer.CustomTemplate, error) {
+	customTemplate, err := service.dataStore.CustomTemplate().CustomTemplate(customTemplateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if customTemplate.GitConfig == nil {
+		return nil, errNoGitConfig
+	}
+
+	commitHash, fileContent, syncErr := service.fetchTemplateFile(customTemplate)
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	currentCustomTemplate, err := service.dataStore.CustomTemplate().CustomTemplate(customTemplateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sameSource(customTemplate, currentCustomTemplate) {
+		return currentCustomTemplate, syncErr
+	}
+
+	syncError := ""
+	if syncErr != nil {
+		syncError = syncErr.Error()
+	}
+
+	newCommit := fileContent != nil
+	if !newCommit && currentCustomTemplate.GitConfig.LastSyncError == syncError {
+		return currentCustomTemplate, syncErr
+	}
+
+	if newCommit {
+		_, err = service.fileService.StoreCustomTemplateFileFromBytes(strconv.Itoa(int(currentCustomTemplate.ID)), currentCustomTemplate.EntryPoint, fileContent)
+		if err != nil {
+			return nil, err
+		}
+
+		err = customtemplate.AddVersion(service.fileService, currentCustomTemplate, fileContent, commitHash, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		currentCustomTemplate.GitConfig.CommitHash = commitHash
+	}
+
+	currentCustomTemplate.GitConfig.LastSync = time.Now().Unix()
+	currentCustomTemplate.GitConfig.LastSyncError = syncError
+
+	err = service.dataStore.CustomTemplate().UpdateCustomTemplate(currentCustomTemplate.ID, currentCustomTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return currentCustomTemplate, syncErr
+}
+
+// sameSource returns true when the file of a custom template is still read from the same repository,
+// reference and entry point
+func sameSource(customTemplate, currentCustomTemplate *portainer.CustomTemplate) bool {
+	if currentCustomTemplate.GitConfig == nil {
+		return false
+	}
+
+	return customTemplate.EntryPoint == currentCustomTemplate.EntryPoint &&
+		customTemplate.GitConfig.URL == currentCustomTemplate.GitConfig.URL &&
+		customTemplate.GitConfig.ReferenceName == currentCustomTemplate.GitConfig.ReferenceName &&
+		customTemplate.GitConfig.CommitHash == currentCustomTemplate.GitConfig.CommitHash
+}
+
+// fetchTemplateFile returns the latest commit of the reference of the template and the content of the template
+// file at this commit. The content is nil when the template is already up to date.
+func (service *Service) fetchTemplateFile(customTemplate *portainer.CustomTemplate) (string, []byte, error) {
+	gitConfig := customTemplate.GitConfig
+
+	repository := gitfile.Repository{
+		URL:            gitConfig.URL,
+		ReferenceName:  gitConfig.ReferenceName,
+		Authentication: gitConfig.Authentication,
+		Username:       gitConfig.Username,
+		Password:       gitConfig.Password,
+	}
+
+	return gitfile.FetchFile(service.gitService, service.fileService, repository, gitConfig.CommitHash, customTemplate.EntryPoint)
+}
@@ -0,0 +1,159 @@
+package customtemplatesync
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/filesystem"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+type testGitService struct {
+	commitHash  string
+	fileContent string
+	err         error
+	// onLatestCommitID simulates an update of the template during the check of the repository
+	onLatestCommitID func()
+}
+
+func (service *testGitService) ClonePublicRepository(repositoryURL, referenceName string, destination string) error {
+	err := os.MkdirAll(destination, 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(destination, "docker-compose.yml"), []byte(service.fileContent), 0644)
+}
+
+func (service *testGitService) ClonePrivateRepositoryWithBasicAuth(repositoryURL, referenceName string, destination, username, password string) error {
+	return service.ClonePublicRepository(repositoryURL, referenceName, destination)
+}
+
+func (service *testGitService) LatestCommitID(repositoryURL, referenceName, username, password string) (string, error) {
+	if service.onLatestCommitID != nil {
+		service.onLatestCommitID()
+	}
+	return service.commitHash, service.err
+}
+
+func newTestService(t *testing.T, gitService *testGitService) (*Service, portainer.DataStore, func()) {
+	store, teardown := testhelpers.NewDatastore(t)
+
+	dataPath, err := ioutil.TempDir("", "customtemplatesync")
+	assert.NoError(t, err)
+
+	fileService, err := filesystem.NewService(dataPath, "")
+	assert.NoError(t, err)
+
+	service, err := NewService("1m", store, fileService, gitService)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.CustomTemplate().CreateCustomTemplate(&portainer.CustomTemplate{
+		ID:         1,
+		Title:      "template",
+		Version:    1,
+		EntryPoint: "docker-compose.yml",
+		GitConfig:  &portainer.CustomTemplateGitConfig{URL: "https://example.com/repo.git", CommitHash: "commit-1", AutoSync: true},
+	}))
+
+	return service, store, func() {
+		teardown()
+		os.RemoveAll(dataPath)
+	}
+}
+
+func Test_SyncCustomTemplate_NewCommit(t *testing.T) {
+	gitService := &testGitService{commitHash: "commit-2", fileContent: "version: '3'"}
+	service, store, teardown := newTestService(t, gitService)
+	defer teardown()
+
+	customTemplate, err := service.SyncCustomTemplate(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, customTemplate.Version)
+
+	storedCustomTemplate, err := store.CustomTemplate().CustomTemplate(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "commit-2", storedCustomTemplate.GitConfig.CommitHash)
+	assert.Len(t, storedCustomTemplate.History, 1)
+	assert.Equal(t, "commit-2", storedCustomTemplate.History[0].CommitHash)
+
+	content, err := service.fileService.GetFileContent(path.Join(service.fileService.GetCustomTemplateProjectPath("1"), "docker-compose.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "version: '3'", string(content))
+}
+
+func Test_SyncCustomTemplate_UpToDateIsNotWritten(t *testing.T) {
+	gitService := &testGitService{commitHash: "commit-1"}
+	service, store, teardown := newTestService(t, gitService)
+	defer teardown()
+
+	_, err := service.SyncCustomTemplate(1)
+	assert.NoError(t, err)
+
+	storedCustomTemplate, err := store.CustomTemplate().CustomTemplate(1)
+	assert.NoError(t, err)
+	assert.Zero(t, storedCustomTemplate.GitConfig.LastSync, "the template must not be written when nothing changed")
+	assert.Equal(t, 1, storedCustomTemplate.Version)
+}
+
+func Test_SyncCustomTemplate_RecordsError(t *testing.T) {
+	gitService := &testGitService{err: errors.New("repository unavailable")}
+	service, store, teardown := newTestService(t, gitService)
+	defer teardown()
+
+	_, err := service.SyncCustomTemplate(1)
+	assert.EqualError(t, err, "repository unavailable")
+
+	storedCustomTemplate, err := store.CustomTemplate().CustomTemplate(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "repository unavailable", storedCustomTemplate.GitConfig.LastSyncError)
+}
+
+func Test_SyncCustomTemplate_KeepsConcurrentUpdates(t *testing.T) {
+	gitService := &testGitService{commitHash: "commit-2", fileContent: "version: '3'"}
+	service, store, teardown := newTestService(t, gitService)
+	defer teardown()
+
+	gitService.onLatestCommitID = func() {
+		customTemplate, err := store.CustomTemplate().CustomTemplate(1)
+		assert.NoError(t, err)
+		customTemplate.Title = "renamed"
+		customTemplate.Description = "updated during the sync"
+		assert.NoError(t, store.CustomTemplate().UpdateCustomTemplate(1, customTemplate))
+	}
+
+	_, err := service.SyncCustomTemplate(1)
+	assert.NoError(t, err)
+
+	storedCustomTemplate, err := store.CustomTemplate().CustomTemplate(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", storedCustomTemplate.Title)
+	assert.Equal(t, "updated during the sync", storedCustomTemplate.Description)
+	assert.Equal(t, "commit-2", storedCustomTemplate.GitConfig.CommitHash)
+	assert.Equal(t, 2, storedCustomTemplate.Version)
+}
+
+func Test_SyncCustomTemplate_DropsResultOfChangedSource(t *testing.T) {
+	gitService := &testGitService{commitHash: "commit-2", fileContent: "version: '3'"}
+	service, store, teardown := newTestService(t, gitService)
+	defer teardown()
+
+	gitService.onLatestCommitID = func() {
+		customTemplate, err := store.CustomTemplate().CustomTemplate(1)
+		assert.NoError(t, err)
+		customTemplate.GitConfig.ReferenceName = "refs/heads/develop"
+		assert.NoError(t, store.CustomTemplate().UpdateCustomTemplate(1, customTemplate))
+	}
+
+	_, err := service.SyncCustomTemplate(1)
+	assert.NoError(t, err)
+
+	storedCustomTemplate, err := store.CustomTemplate().CustomTemplate(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "commit-1", storedCustomTemplate.GitConfig.CommitHash)
+	assert.Equal(t, 1, storedCustomTemplate.Version)
+}
@@ -0,0 +1,133 @@
+package dockerconfig
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/registry"
+)
+
+const (
+	configFileName = "config.json"
+	// configsDirectory is the directory of the data path containing the configurations of the deployments
+	configsDirectory = "docker_configs"
+	// dockerHubServerAddress is the key of the Docker Hub credentials in the auths of a Docker CLI configuration
+	dockerHubServerAddress = "https://index.docker.io/v1/"
+)
+
+// RegistryAuth represents the credentials of a registry written in a Docker CLI configuration
+type RegistryAuth struct {
+	ServerAddress string
+	Username      string
+	Password      string
+}
+
+type authEntry struct {
+	Auth string `json:"auth"`
+}
+
+// RegistryAuths returns the credentials of the authenticated registries and of the Docker Hub.
+// The registries whose credentials cannot be retrieved are skipped.
+func RegistryAuths(dockerhub *portainer.DockerHub, registries []portainer.Registry) []RegistryAuth {
+	auths := make([]RegistryAuth, 0, len(registries)+1)
+
+	for idx := range registries {
+		reg := &registries[idx]
+		if !reg.Authentication {
+			continue
+		}
+
+		credentials, err := registry.RegistryCredentials(reg)
+		if err != nil {
+			log.Printf("[WARN] [internal,dockerconfig] [message: unable to retrieve the registry credentials] [registry: %s] [err: %s]", reg.Name, err)
+			continue
+		}
+
+		auths = append(auths, RegistryAuth{ServerAddress: reg.URL, Username: credentials.Username, Password: credentials.Password})
+	}
+
+	if dockerhub != nil && dockerhub.Authentication {
+		auths = append(auths, RegistryAuth{ServerAddress: dockerHubServerAddress, Username: dockerhub.Username, Password: dockerhub.Password})
+	}
+
+	return auths
+}
+
+// Create creates a Docker CLI configuration in a new directory of the data path, only readable by Portainer.
+// The settings of the configuration found at the root of the data path, such as the HTTP headers, are kept
+// and its registry credentials are replaced by auths. The returned function removes the directory and must
+// always be called.
+func Create(dataPath string, auths []RegistryAuth) (string, func(), error) {
+	config, err := readConfig(filepath.Join(dataPath, configFileName))
+	if err != nil {
+		return "", nil, err
+	}
+
+	entries := make(map[string]authEntry)
+	for _, auth := range auths {
+		entries[auth.ServerAddress] = authEntry{Auth: base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))}
+	}
+	config["auths"] = entries
+	delete(config, "credsStore")
+	delete(config, "credHelpers")
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", nil, err
+	}
+
+	parentPath := filepath.Join(dataPath, configsDirectory)
+	err = os.MkdirAll(parentPath, 0700)
+	if err != nil {
+		return "", nil, err
+	}
+
+	configPath, err := ioutil.TempDir(parentPath, "docker-config-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanUp := func() {
+		err := os.RemoveAll(configPath)
+		if err != nil {
+			log.Printf("[WARN] [internal,dockerconfig] [message: unable to remove the Docker configuration] [path: %s] [err: %s]", configPath, err)
+		}
+	}
+
+	err = ioutil.WriteFile(filepath.Join(configPath, configFileName), data, 0600)
+	if err != nil {
+		cleanUp()
+		return "", nil, err
+	}
+
+	return configPath, cleanUp, nil
+}
+
+// RemoveAll removes the configurations created inside the data path, such as the ones left
+// when Portainer stopped during a deployment
+func RemoveAll(dataPath string) error {
+	return os.RemoveAll(filepath.Join(dataPath, configsDirectory))
+}
+
+func readConfig(path string) (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
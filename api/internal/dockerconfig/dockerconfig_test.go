@@ -0,0 +1,99 @@
+package dockerconfig
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Create(t *testing.T) {
+	dataPath, err := ioutil.TempDir("", "dockerconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	baseConfig := `{"HttpHeaders":{"X-PortainerAgent-ManagerOperation":"1"},"auths":{"shared.example.com":{"auth":"c2hhcmVkOnNlY3JldA=="}}}`
+	err = ioutil.WriteFile(filepath.Join(dataPath, configFileName), []byte(baseConfig), 0600)
+	assert.NoError(t, err)
+
+	auths := RegistryAuths(
+		&portainer.DockerHub{Authentication: true, Username: "hub", Password: "hubpass"},
+		[]portainer.Registry{
+			{URL: "registry.example.com", Authentication: true, Username: "user", Password: "pass"},
+			{URL: "public.example.com"},
+		})
+
+	configPath, cleanUp, err := Create(dataPath, auths)
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadFile(filepath.Join(configPath, configFileName))
+	assert.NoError(t, err)
+
+	var config struct {
+		HttpHeaders map[string]string
+		Auths       map[string]authEntry `json:"auths"`
+	}
+	err = json.Unmarshal(data, &config)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1", config.HttpHeaders["X-PortainerAgent-ManagerOperation"])
+	assert.Equal(t, map[string]authEntry{
+		"registry.example.com": {Auth: "dXNlcjpwYXNz"},
+		dockerHubServerAddress: {Auth: "aHViOmh1YnBhc3M="},
+	}, config.Auths)
+
+	cleanUp()
+	_, err = os.Stat(configPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_Create_ConcurrentDeployments(t *testing.T) {
+	dataPath, err := ioutil.TempDir("", "dockerconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	const deployments = 20
+	paths := make(chan string, deployments)
+
+	var wg sync.WaitGroup
+	for i := 0; i < deployments; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			username := fmt.Sprintf("user-%d", i)
+			configPath, cleanUp, err := Create(dataPath, []RegistryAuth{{ServerAddress: "registry.example.com", Username: username, Password: "pass"}})
+			assert.NoError(t, err)
+			defer cleanUp()
+			paths <- configPath
+
+			data, err := ioutil.ReadFile(filepath.Join(configPath, configFileName))
+			assert.NoError(t, err)
+
+			var config struct {
+				Auths map[string]authEntry `json:"auths"`
+			}
+			assert.NoError(t, json.Unmarshal(data, &config))
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(username+":pass")), config.Auths["registry.example.com"].Auth)
+		}(i)
+	}
+	wg.Wait()
+	close(paths)
+
+	unique := map[string]bool{}
+	for configPath := range paths {
+		unique[configPath] = true
+	}
+	assert.Len(t, unique, deployments)
+
+	entries, err := ioutil.ReadDir(filepath.Join(dataPath, configsDirectory))
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
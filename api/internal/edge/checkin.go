@@ -0,0 +1,35 @@
+package edge
+
+import (
+	"time"
+
+	"github.com/cloudogu/portainer-ce/api"
+)
+
+// checkinMissedLimit is the number of consecutive check-ins an Edge agent can miss
+// before its endpoint is considered unavailable
+const checkinMissedLimit = 3
+
+// CheckinStatus returns the status of an Edge endpoint derived from the time elapsed since
+// the latest check-in of its agent. Endpoints whose agent never checked in are considered down.
+func CheckinStatus(lastCheckin int64, checkinInterval int, now time.Time) portainer.EndpointStatus {
+	if lastCheckin == 0 {
+		return portainer.EndpointStatusDown
+	}
+
+	staleAfter := time.Duration(checkinInterval*checkinMissedLimit) * time.Second
+	if now.Sub(time.Unix(lastCheckin, 0)) > staleAfter {
+		return portainer.EndpointStatusDown
+	}
+
+	return portainer.EndpointStatusUp
+}
+
+// EndpointCheckinInterval returns the check-in interval of an Edge endpoint, falling back
+// to the interval defined in the settings
+func EndpointCheckinInterval(endpoint *portainer.Endpoint, settings *portainer.Settings) int {
+	if endpoint.EdgeCheckinInterval != 0 {
+		return endpoint.EdgeCheckinInterval
+	}
+	return settings.EdgeAgentCheckinInterval
+}
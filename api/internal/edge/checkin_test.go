@@ -0,0 +1,18 @@
+package edge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CheckinStatus(t *testing.T) {
+	now := time.Now()
+
+	assert.Equal(t, portainer.EndpointStatusDown, CheckinStatus(0, 5, now))
+	assert.Equal(t, portainer.EndpointStatusUp, CheckinStatus(now.Add(-10*time.Second).Unix(), 5, now))
+	assert.Equal(t, portainer.EndpointStatusDown, CheckinStatus(now.Add(-16*time.Second).Unix(), 5, now))
+	assert.Equal(t, portainer.EndpointStatusUp, CheckinStatus(now.Add(-16*time.Second).Unix(), 60, now))
+}
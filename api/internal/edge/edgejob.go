@@ -0,0 +1,185 @@
+package edge
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cloudogu/portainer-ce/api"
+)
+
+// edgeJobRunHistoryLimit is the number of runs kept for each Edge job and endpoint relation
+const edgeJobRunHistoryLimit = 50
+
+// EdgeJobRunStatus returns the status of an Edge job run based on its exit code
+// and whether it was stopped after exceeding the job timeout
+func EdgeJobRunStatus(exitCode int, timedOut bool) portainer.EdgeJobRunStatus {
+	if timedOut {
+		return portainer.EdgeJobRunTimedOut
+	}
+
+	if exitCode != 0 {
+		return portainer.EdgeJobRunFailed
+	}
+
+	return portainer.EdgeJobRunSucceeded
+}
+
+// AddEdgeJobRun assigns an identifier to the run and appends it to the run history of the
+// Edge job and endpoint relation, dropping the oldest runs once the history limit is reached
+func AddEdgeJobRun(meta *portainer.EdgeJobEndpointMeta, run *portainer.EdgeJobRun) {
+	meta.RunCount++
+	run.ID = meta.RunCount
+
+	meta.Runs = append(meta.Runs, *run)
+	if len(meta.Runs) > edgeJobRunHistoryLimit {
+		meta.Runs = meta.Runs[len(meta.Runs)-edgeJobRunHistoryLimit:]
+	}
+}
+
+// EdgeJobRunLogsTaskID returns the task identifier used to store the logs of an Edge job run
+func EdgeJobRunLogsTaskID(endpointID portainer.EndpointID, runID int) string {
+	return fmt.Sprintf("%d_run_%d", endpointID, runID)
+}
+
+// edgeJobsMutex serializes the read-modify-write updates of Edge jobs
+var edgeJobsMutex sync.Mutex
+
+// UpdateEdgeJob reads an Edge job, applies the update function and persists the result.
+// Every change made to an existing Edge job must go through this function, updates of the
+// same Edge job made concurrently would be lost otherwise. The Edge job is not persisted
+// when the update function returns an error.
+func UpdateEdgeJob(dataStore portainer.DataStore, edgeJobID portainer.EdgeJobID, update func(edgeJob *portainer.EdgeJob) error) (*portainer.EdgeJob, error) {
+	edgeJobsMutex.Lock()
+	defer edgeJobsMutex.Unlock()
+
+	edgeJob, err := dataStore.EdgeJob().EdgeJob(edgeJobID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = update(edgeJob)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dataStore.EdgeJob().UpdateEdgeJob(edgeJob.ID, edgeJob)
+	if err != nil {
+		return nil, err
+	}
+
+	return edgeJob, nil
+}
+
+// EdgeJobRelatedEndpoints returns the Edge endpoints related to the Edge groups of an Edge job
+func EdgeJobRelatedEndpoints(edgeJob *portainer.EdgeJob, endpoints []portainer.Endpoint, endpointGroups []portainer.EndpointGroup, edgeGroups []portainer.EdgeGroup) ([]portainer.EndpointID, error) {
+	relatedEndpoints, err := EdgeStackRelatedEndpoints(edgeJob.EdgeGroups, endpoints, endpointGroups, edgeGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	edgeEndpoints := map[portainer.EndpointID]bool{}
+	for _, endpoint := range endpoints {
+		if endpoint.Type != portainer.EdgeAgentOnDockerEnvironment && endpoint.Type != portainer.EdgeAgentOnKubernetesEnvironment {
+			continue
+		}
+
+		if endpoint.EdgeEnrollment != nil && endpoint.EdgeEnrollment.Status != portainer.EdgeEnrollmentApproved {
+			continue
+		}
+
+		edgeEndpoints[endpoint.ID] = true
+	}
+
+	endpointIDs := []portainer.EndpointID{}
+	for _, endpointID := range relatedEndpoints {
+		if edgeEndpoints[endpointID] {
+			endpointIDs = append(endpointIDs, endpointID)
+			delete(edgeEndpoints, endpointID)
+		}
+	}
+
+	return endpointIDs, nil
+}
+
+// SetEdgeJobEndpoints updates the endpoints of an Edge job, keeping the meta data of the endpoints
+// that are still targeted. It returns the endpoints that were added and removed.
+func SetEdgeJobEndpoints(edgeJob *portainer.EdgeJob, endpointIDs []portainer.EndpointID) (added, removed []portainer.EndpointID) {
+	endpoints := map[portainer.EndpointID]portainer.EdgeJobEndpointMeta{}
+
+	for _, endpointID := range endpointIDs {
+		meta, ok := edgeJob.Endpoints[endpointID]
+		if !ok {
+			added = append(added, endpointID)
+		}
+		endpoints[endpointID] = meta
+	}
+
+	for endpointID := range edgeJob.Endpoints {
+		if _, ok := endpoints[endpointID]; !ok {
+			removed = append(removed, endpointID)
+		}
+	}
+
+	edgeJob.Endpoints = endpoints
+	return added, removed
+}
+
+// UpdateEdgeJobsEndpoints resolves the endpoints targeted by the Edge jobs related to Edge groups.
+// Endpoints joining an Edge group receive the jobs through their tunnel, endpoints leaving
+// an Edge group have the jobs removed. It must be called after a change of Edge groups membership.
+func UpdateEdgeJobsEndpoints(dataStore portainer.DataStore, reverseTunnelService portainer.ReverseTunnelService) error {
+	edgeJobsMutex.Lock()
+	defer edgeJobsMutex.Unlock()
+
+	edgeJobs, err := dataStore.EdgeJob().EdgeJobs()
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := dataStore.Endpoint().Endpoints()
+	if err != nil {
+		return err
+	}
+
+	endpointGroups, err := dataStore.EndpointGroup().EndpointGroups()
+	if err != nil {
+		return err
+	}
+
+	edgeGroups, err := dataStore.EdgeGroup().EdgeGroups()
+	if err != nil {
+		return err
+	}
+
+	for idx := range edgeJobs {
+		edgeJob := &edgeJobs[idx]
+		if len(edgeJob.EdgeGroups) == 0 {
+			continue
+		}
+
+		endpointIDs, err := EdgeJobRelatedEndpoints(edgeJob, endpoints, endpointGroups, edgeGroups)
+		if err != nil {
+			return err
+		}
+
+		added, removed := SetEdgeJobEndpoints(edgeJob, endpointIDs)
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		err = dataStore.EdgeJob().UpdateEdgeJob(edgeJob.ID, edgeJob)
+		if err != nil {
+			return err
+		}
+
+		for _, endpointID := range removed {
+			reverseTunnelService.RemoveEdgeJobFromEndpoint(endpointID, edgeJob.ID)
+		}
+
+		for endpointID := range edgeJob.Endpoints {
+			reverseTunnelService.AddEdgeJob(endpointID, edgeJob)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package edge
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EdgeJobRunStatus(t *testing.T) {
+	assert.Equal(t, portainer.EdgeJobRunSucceeded, EdgeJobRunStatus(0, false))
+	assert.Equal(t, portainer.EdgeJobRunFailed, EdgeJobRunStatus(2, false))
+	assert.Equal(t, portainer.EdgeJobRunTimedOut, EdgeJobRunStatus(137, true))
+}
+
+func Test_AddEdgeJobRun_KeepsMostRecentRuns(t *testing.T) {
+	meta := portainer.EdgeJobEndpointMeta{}
+
+	for i := 0; i < edgeJobRunHistoryLimit+5; i++ {
+		AddEdgeJobRun(&meta, &portainer.EdgeJobRun{StartedAt: int64(i)})
+	}
+
+	assert.Equal(t, edgeJobRunHistoryLimit+5, meta.RunCount)
+	assert.Len(t, meta.Runs, edgeJobRunHistoryLimit)
+	assert.Equal(t, 6, meta.Runs[0].ID)
+	assert.Equal(t, edgeJobRunHistoryLimit+5, meta.Runs[len(meta.Runs)-1].ID)
+}
+
+func Test_EdgeJobRelatedEndpoints_DynamicGroup(t *testing.T) {
+	endpoints := []portainer.Endpoint{
+		{ID: 1, Type: portainer.EdgeAgentOnDockerEnvironment, GroupID: 1, TagIDs: []portainer.TagID{1}},
+		{ID: 2, Type: portainer.EdgeAgentOnDockerEnvironment, GroupID: 1, TagIDs: []portainer.TagID{2}},
+		{ID: 3, Type: portainer.DockerEnvironment, GroupID: 1, TagIDs: []portainer.TagID{1}},
+		{ID: 4, Type: portainer.EdgeAgentOnDockerEnvironment, GroupID: 1, TagIDs: []portainer.TagID{1}, EdgeEnrollment: &portainer.EdgeEnrollment{Status: portainer.EdgeEnrollmentPending}},
+	}
+	endpointGroups := []portainer.EndpointGroup{{ID: 1}}
+	edgeGroups := []portainer.EdgeGroup{{ID: 1, Dynamic: true, TagIDs: []portainer.TagID{1}}}
+
+	endpointIDs, err := EdgeJobRelatedEndpoints(&portainer.EdgeJob{EdgeGroups: []portainer.EdgeGroupID{1}}, endpoints, endpointGroups, edgeGroups)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []portainer.EndpointID{1}, endpointIDs)
+}
+
+func Test_SetEdgeJobEndpoints_KeepsExistingMeta(t *testing.T) {
+	edgeJob := &portainer.EdgeJob{
+		Endpoints: map[portainer.EndpointID]portainer.EdgeJobEndpointMeta{
+			1: {RunCount: 3},
+			2: {},
+		},
+	}
+
+	added, removed := SetEdgeJobEndpoints(edgeJob, []portainer.EndpointID{1, 3})
+
+	assert.Equal(t, []portainer.EndpointID{3}, added)
+	assert.Equal(t, []portainer.EndpointID{2}, removed)
+	assert.Equal(t, 3, edgeJob.Endpoints[1].RunCount)
+	assert.Len(t, edgeJob.Endpoints, 2)
+}
+
+func Test_UpdateEdgeJob_ConcurrentRuns(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	edgeJob := &portainer.EdgeJob{
+		ID:        1,
+		Endpoints: map[portainer.EndpointID]portainer.EdgeJobEndpointMeta{1: {}, 2: {}},
+	}
+	assert.NoError(t, store.EdgeJob().CreateEdgeJob(edgeJob))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(endpointID portainer.EndpointID) {
+			defer wg.Done()
+			_, err := UpdateEdgeJob(store, edgeJob.ID, func(edgeJob *portainer.EdgeJob) error {
+				meta := edgeJob.Endpoints[endpointID]
+				AddEdgeJobRun(&meta, &portainer.EdgeJobRun{})
+				edgeJob.Endpoints[endpointID] = meta
+				return nil
+			})
+			assert.NoError(t, err)
+		}(portainer.EndpointID(i%2 + 1))
+	}
+	wg.Wait()
+
+	edgeJob, err := store.EdgeJob().EdgeJob(edgeJob.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, edgeJob.Endpoints[1].RunCount)
+	assert.Equal(t, 10, edgeJob.Endpoints[2].RunCount)
+}
+
+func Test_UpdateEdgeJob_NotPersistedOnError(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	edgeJob := &portainer.EdgeJob{ID: 1, Name: "job"}
+	assert.NoError(t, store.EdgeJob().CreateEdgeJob(edgeJob))
+
+	updateErr := errors.New("update failed")
+	_, err := UpdateEdgeJob(store, edgeJob.ID, func(edgeJob *portainer.EdgeJob) error {
+		edgeJob.Name = "renamed"
+		return updateErr
+	})
+	assert.Equal(t, updateErr, err)
+
+	edgeJob, err = store.EdgeJob().EdgeJob(edgeJob.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "job", edgeJob.Name)
+}
@@ -1,10 +1,29 @@
 package edge
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/crypto"
+	"github.com/cloudogu/portainer-ce/api/internal/tag"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// edgeStackDeploymentHistoryLimit is the number of deployment attempts kept for each Edge stack and endpoint
+	edgeStackDeploymentHistoryLimit = 20
+	// edgeStackDeploymentOutputLimit is the maximum size in bytes of the deployment output kept for a deployment attempt
+	edgeStackDeploymentOutputLimit = 16 * 1024
 )
 
+const truncatedOutputMarker = "[output truncated]\n"
+
 // EdgeStackRelatedEndpoints returns a list of endpoints related to this Edge stack
 func EdgeStackRelatedEndpoints(edgeGroupIDs []portainer.EdgeGroupID, endpoints []portainer.Endpoint, endpointGroups []portainer.EndpointGroup, edgeGroups []portainer.EdgeGroup) ([]portainer.EndpointID, error) {
 	edgeStackEndpoints := []portainer.EndpointID{}
@@ -28,3 +47,320 @@ func EdgeStackRelatedEndpoints(edgeGroupIDs []portainer.EdgeGroupID, endpoints [
 
 	return edgeStackEndpoints, nil
 }
+
+// EdgeStackEndpointVersion returns the version of an Edge stack sent to the agent of an endpoint.
+// Retrying a deployment on an endpoint increments this version without changing the stack version.
+func EdgeStackEndpointVersion(edgeStack *portainer.EdgeStack, endpointID portainer.EndpointID) int {
+	return edgeStack.Version + edgeStack.EndpointRetries[endpointID]
+}
+
+// RecordEdgeStackDeployment adds a status reported by the agent of an endpoint to the deployment history
+// of an Edge stack. An acknowledged status starts a new deployment attempt, a final status completes the
+// attempt started for the same version or records a new attempt when none was started.
+func RecordEdgeStackDeployment(edgeStack *portainer.EdgeStack, endpointID portainer.EndpointID, deployment portainer.EdgeStackDeployment, now int64) {
+	if edgeStack.Deployments == nil {
+		edgeStack.Deployments = map[portainer.EndpointID][]portainer.EdgeStackDeployment{}
+	}
+
+	deployments := edgeStack.Deployments[endpointID]
+	deployment.Output = truncateDeploymentOutput(deployment.Output)
+
+	if deployment.Type == portainer.StatusAcknowledged {
+		deployment.StartedAt = now
+		deployments = append(deployments, deployment)
+	} else {
+		lastIdx := len(deployments) - 1
+		if lastIdx >= 0 && deployments[lastIdx].Type == portainer.StatusAcknowledged && deployments[lastIdx].Version == deployment.Version {
+			deployment.StartedAt = deployments[lastIdx].StartedAt
+			deployments = deployments[:lastIdx]
+		} else {
+			deployment.StartedAt = now
+		}
+
+		deployment.FinishedAt = now
+		deployments = append(deployments, deployment)
+	}
+
+	if len(deployments) > edgeStackDeploymentHistoryLimit {
+		deployments = deployments[len(deployments)-edgeStackDeploymentHistoryLimit:]
+	}
+
+	edgeStack.Deployments[endpointID] = deployments
+}
+
+// truncateDeploymentOutput keeps the end of a deployment output, where the errors are reported,
+// when the output exceeds the size kept for a deployment attempt
+func truncateDeploymentOutput(output string) string {
+	if len(output) <= edgeStackDeploymentOutputLimit {
+		return output
+	}
+
+	start := len(output) - edgeStackDeploymentOutputLimit + len(truncatedOutputMarker)
+	for start < len(output) && !utf8.RuneStart(output[start]) {
+		start++
+	}
+
+	return truncatedOutputMarker + output[start:]
+}
+
+// ResolveEdgeStackEnv returns the environment variables of an Edge stack for an endpoint, split between
+// the regular and the secret variables. Default values are overridden by the values defined for the tags
+// of the endpoint and its endpoint group, in their order of definition, then by the metadata of the endpoint.
+// The values are returned as stored, secret values must be decrypted with DecryptEnv before their use.
+func ResolveEdgeStackEnv(edgeStack *portainer.EdgeStack, endpoint *portainer.Endpoint, endpointGroup *portainer.EndpointGroup) ([]portainer.Pair, []portainer.Pair) {
+	endpointTags := tag.Set(endpoint.TagIDs)
+	if endpointGroup != nil && endpointGroup.TagIDs != nil {
+		endpointTags = tag.Union(endpointTags, tag.Set(endpointGroup.TagIDs))
+	}
+
+	env := []portainer.Pair{}
+	secretEnv := []portainer.Pair{}
+
+	for _, envVar := range edgeStack.Env {
+		value := envVar.Value
+
+		for _, tagEnvVar := range edgeStack.TagEnv {
+			if tagEnvVar.Name == envVar.Name && endpointTags[tagEnvVar.TagID] {
+				value = tagEnvVar.Value
+			}
+		}
+
+		if metadataValue, ok := endpoint.Metadata[envVar.Name]; ok {
+			value = metadataValue
+		}
+
+		pair := portainer.Pair{Name: envVar.Name, Value: value}
+		if envVar.Secret {
+			secretEnv = append(secretEnv, pair)
+		} else {
+			env = append(env, pair)
+		}
+	}
+
+	return env, secretEnv
+}
+
+// UpdateEdgeStacksEnvVersion increments the version of the Edge stacks deployed on an endpoint before and after
+// an update of the endpoint when their environment variables resolved for the endpoint changed, so that the
+// agent deploys them again with the new values.
+func UpdateEdgeStacksEnvVersion(dataStore portainer.DataStore, previousEndpoint *portainer.Endpoint, previousEdgeStacks map[portainer.EdgeStackID]bool, endpoint *portainer.Endpoint) error {
+	relation, err := dataStore.EndpointRelation().EndpointRelation(endpoint.ID)
+	if err != nil {
+		return err
+	}
+
+	previousEndpointGroup, err := dataStore.EndpointGroup().EndpointGroup(previousEndpoint.GroupID)
+	if err != nil {
+		return err
+	}
+
+	endpointGroup, err := dataStore.EndpointGroup().EndpointGroup(endpoint.GroupID)
+	if err != nil {
+		return err
+	}
+
+	for edgeStackID := range relation.EdgeStacks {
+		if !previousEdgeStacks[edgeStackID] {
+			continue
+		}
+
+		edgeStack, err := dataStore.EdgeStack().EdgeStack(edgeStackID)
+		if err != nil {
+			return err
+		}
+
+		previousEnv, previousSecretEnv := ResolveEdgeStackEnv(edgeStack, previousEndpoint, previousEndpointGroup)
+		env, secretEnv := ResolveEdgeStackEnv(edgeStack, endpoint, endpointGroup)
+		if reflect.DeepEqual(previousEnv, env) && reflect.DeepEqual(previousSecretEnv, secretEnv) {
+			continue
+		}
+
+		edgeStack.Version++
+		edgeStack.Status = map[portainer.EndpointID]portainer.EdgeStackStatus{}
+
+		err = dataStore.EdgeStack().UpdateEdgeStack(edgeStack.ID, edgeStack)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SecretEnvNames returns the names of the secret environment variables of Edge stacks
+func SecretEnvNames(edgeStacks []portainer.EdgeStack) map[string]bool {
+	names := map[string]bool{}
+	for _, edgeStack := range edgeStacks {
+		for _, envVar := range edgeStack.Env {
+			if envVar.Secret {
+				names[envVar.Name] = true
+			}
+		}
+	}
+	return names
+}
+
+// ValidateEnvValue returns an error when a value submitted by a user starts with the prefix of the
+// encrypted values, as it would be stored and read as an encrypted value
+func ValidateEnvValue(value string) error {
+	if strings.HasPrefix(value, crypto.EncryptedSecretPrefix) {
+		return errors.New("Environment variable and metadata values cannot start with " + crypto.EncryptedSecretPrefix)
+	}
+	return nil
+}
+
+// SealEdgeStackEnv encrypts the default and tag values of the secret environment variables of an Edge stack
+// before it is stored. The values of the variables that are not secret anymore are decrypted.
+func SealEdgeStackEnv(edgeStack *portainer.EdgeStack, secretService portainer.SecretService) error {
+	secretNames := SecretEnvNames([]portainer.EdgeStack{*edgeStack})
+
+	var err error
+	for idx := range edgeStack.Env {
+		envVar := &edgeStack.Env[idx]
+		envVar.Value, err = sealValue(envVar.Value, secretNames[envVar.Name], secretService)
+		if err != nil {
+			return err
+		}
+	}
+
+	for idx := range edgeStack.TagEnv {
+		tagEnvVar := &edgeStack.TagEnv[idx]
+		tagEnvVar.Value, err = sealValue(tagEnvVar.Value, secretNames[tagEnvVar.Name], secretService)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SealEndpointMetadata encrypts the metadata of an endpoint overriding secret environment variables
+// before it is stored. The metadata overriding variables that are not secret anymore are decrypted.
+func SealEndpointMetadata(endpoint *portainer.Endpoint, secretNames map[string]bool, secretService portainer.SecretService) error {
+	for name, value := range endpoint.Metadata {
+		sealedValue, err := sealValue(value, secretNames[name], secretService)
+		if err != nil {
+			return err
+		}
+		endpoint.Metadata[name] = sealedValue
+	}
+	return nil
+}
+
+// SealEndpointsMetadata seals the metadata of every endpoint after a change of the secret environment
+// variables of the Edge stacks, so that the values overriding a variable that became secret are encrypted.
+func SealEndpointsMetadata(dataStore portainer.DataStore, secretService portainer.SecretService) error {
+	edgeStacks, err := dataStore.EdgeStack().EdgeStacks()
+	if err != nil {
+		return err
+	}
+	secretNames := SecretEnvNames(edgeStacks)
+
+	endpoints, err := dataStore.Endpoint().Endpoints()
+	if err != nil {
+		return err
+	}
+
+	for idx := range endpoints {
+		endpoint := &endpoints[idx]
+		if len(endpoint.Metadata) == 0 {
+			continue
+		}
+
+		previousMetadata := make(map[string]string, len(endpoint.Metadata))
+		for name, value := range endpoint.Metadata {
+			previousMetadata[name] = value
+		}
+
+		err = SealEndpointMetadata(endpoint, secretNames, secretService)
+		if err != nil {
+			return err
+		}
+
+		if reflect.DeepEqual(previousMetadata, endpoint.Metadata) {
+			continue
+		}
+
+		err = dataStore.Endpoint().UpdateEndpoint(endpoint.ID, endpoint)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sealValue(value string, secret bool, secretService portainer.SecretService) (string, error) {
+	if secret {
+		return secretService.Encrypt(value)
+	}
+	return secretService.Decrypt(value)
+}
+
+// DecryptEnv returns resolved environment variables with their stored values decrypted
+func DecryptEnv(env []portainer.Pair, secretService portainer.SecretService) ([]portainer.Pair, error) {
+	decryptedEnv := make([]portainer.Pair, 0, len(env))
+	for _, pair := range env {
+		value, err := secretService.Decrypt(pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		decryptedEnv = append(decryptedEnv, portainer.Pair{Name: pair.Name, Value: value})
+	}
+	return decryptedEnv, nil
+}
+
+// EdgeStackDeployableOnEndpoint returns true when the deployment type of an Edge stack matches
+// the platform of an Edge endpoint
+func EdgeStackDeployableOnEndpoint(edgeStack *portainer.EdgeStack, endpointType portainer.EndpointType) bool {
+	switch edgeStack.DeploymentType {
+	case portainer.EdgeStackDeploymentKubernetes:
+		return endpointType == portainer.EdgeAgentOnKubernetesEnvironment
+	default:
+		return endpointType == portainer.EdgeAgentOnDockerEnvironment
+	}
+}
+
+// EdgeStackImages returns the images referenced by a Compose file or by the documents of a Kubernetes manifest,
+// sorted and without duplicates. Every "image" key holding a string value is considered as an image reference.
+func EdgeStackImages(stackFileContent []byte) ([]string, error) {
+	images := map[string]bool{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(stackFileContent))
+	for {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		collectImages(document, images)
+	}
+
+	result := make([]string, 0, len(images))
+	for image := range images {
+		result = append(result, image)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+func collectImages(node interface{}, images map[string]bool) {
+	switch value := node.(type) {
+	case map[interface{}]interface{}:
+		for key, child := range value {
+			if image, ok := child.(string); ok && key == "image" && image != "" {
+				images[image] = true
+				continue
+			}
+			collectImages(child, images)
+		}
+	case []interface{}:
+		for _, child := range value {
+			collectImages(child, images)
+		}
+	}
+}
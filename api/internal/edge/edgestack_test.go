@@ -0,0 +1,244 @@
+package edge
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/crypto"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EdgeStackEndpointVersion(t *testing.T) {
+	edgeStack := &portainer.EdgeStack{
+		Version:         3,
+		EndpointRetries: map[portainer.EndpointID]int{2: 2},
+	}
+
+	assert.Equal(t, 3, EdgeStackEndpointVersion(edgeStack, 1))
+	assert.Equal(t, 5, EdgeStackEndpointVersion(edgeStack, 2))
+}
+
+func Test_RecordEdgeStackDeployment_CompletesAcknowledgedAttempt(t *testing.T) {
+	edgeStack := &portainer.EdgeStack{}
+
+	RecordEdgeStackDeployment(edgeStack, 1, portainer.EdgeStackDeployment{Type: portainer.StatusAcknowledged, Version: 2}, 100)
+	RecordEdgeStackDeployment(edgeStack, 1, portainer.EdgeStackDeployment{Type: portainer.StatusError, Version: 2, Error: "pull failed"}, 130)
+
+	deployments := edgeStack.Deployments[1]
+	assert.Len(t, deployments, 1)
+	assert.Equal(t, portainer.StatusError, deployments[0].Type)
+	assert.Equal(t, int64(100), deployments[0].StartedAt)
+	assert.Equal(t, int64(130), deployments[0].FinishedAt)
+
+	RecordEdgeStackDeployment(edgeStack, 1, portainer.EdgeStackDeployment{Type: portainer.StatusOk, Version: 3}, 200)
+
+	deployments = edgeStack.Deployments[1]
+	assert.Len(t, deployments, 2)
+	assert.Equal(t, int64(200), deployments[1].StartedAt)
+}
+
+func Test_RecordEdgeStackDeployment_TruncatesOutput(t *testing.T) {
+	edgeStack := &portainer.EdgeStack{}
+	output := strings.Repeat("é", edgeStackDeploymentOutputLimit) + "pull access denied"
+
+	RecordEdgeStackDeployment(edgeStack, 1, portainer.EdgeStackDeployment{Type: portainer.StatusError, Version: 1, Output: output}, 100)
+
+	recorded := edgeStack.Deployments[1][0].Output
+	assert.True(t, len(recorded) <= edgeStackDeploymentOutputLimit)
+	assert.True(t, strings.HasPrefix(recorded, truncatedOutputMarker))
+	assert.True(t, strings.HasSuffix(recorded, "pull access denied"))
+	assert.True(t, utf8.ValidString(recorded))
+}
+
+func Test_ResolveEdgeStackEnv(t *testing.T) {
+	edgeStack := &portainer.EdgeStack{
+		Env: []portainer.EdgeStackEnvVar{
+			{Name: "REGION", Value: "eu"},
+			{Name: "LOG_LEVEL", Value: "info"},
+			{Name: "API_TOKEN", Value: "default", Secret: true},
+		},
+		TagEnv: []portainer.EdgeStackTagEnvVar{
+			{TagID: 1, Name: "REGION", Value: "us"},
+			{TagID: 2, Name: "LOG_LEVEL", Value: "debug"},
+		},
+	}
+	endpoint := &portainer.Endpoint{
+		TagIDs:   []portainer.TagID{1},
+		Metadata: map[string]string{"API_TOKEN": "device-token", "UNUSED": "value"},
+	}
+	endpointGroup := &portainer.EndpointGroup{TagIDs: []portainer.TagID{2}}
+
+	env, secretEnv := ResolveEdgeStackEnv(edgeStack, endpoint, endpointGroup)
+
+	assert.Equal(t, []portainer.Pair{{Name: "REGION", Value: "us"}, {Name: "LOG_LEVEL", Value: "debug"}}, env)
+	assert.Equal(t, []portainer.Pair{{Name: "API_TOKEN", Value: "device-token"}}, secretEnv)
+}
+
+func newTestSecretService(t *testing.T) *crypto.SecretService {
+	secretService := crypto.NewSecretService()
+	key, err := secretService.GenerateKey()
+	assert.NoError(t, err)
+	assert.NoError(t, secretService.ParseKey(key))
+	return secretService
+}
+
+func Test_SealEdgeStackEnv(t *testing.T) {
+	secretService := newTestSecretService(t)
+
+	previousSecret, err := secretService.Encrypt("previously-secret")
+	assert.NoError(t, err)
+
+	edgeStack := &portainer.EdgeStack{
+		Env: []portainer.EdgeStackEnvVar{
+			{Name: "REGION", Value: "eu"},
+			{Name: "API_TOKEN", Value: "default", Secret: true},
+			{Name: "LOG_LEVEL", Value: previousSecret},
+		},
+		TagEnv: []portainer.EdgeStackTagEnvVar{
+			{TagID: 1, Name: "API_TOKEN", Value: "tag-token"},
+			{TagID: 1, Name: "REGION", Value: "us"},
+		},
+	}
+
+	assert.NoError(t, SealEdgeStackEnv(edgeStack, secretService))
+
+	assert.Equal(t, "eu", edgeStack.Env[0].Value)
+	assert.True(t, secretService.IsEncrypted(edgeStack.Env[1].Value))
+	assert.Equal(t, "previously-secret", edgeStack.Env[2].Value)
+	assert.True(t, secretService.IsEncrypted(edgeStack.TagEnv[0].Value))
+	assert.Equal(t, "us", edgeStack.TagEnv[1].Value)
+
+	endpoint := &portainer.Endpoint{Metadata: map[string]string{"API_TOKEN": "device-token"}}
+	assert.NoError(t, SealEndpointMetadata(endpoint, SecretEnvNames([]portainer.EdgeStack{*edgeStack}), secretService))
+	assert.True(t, secretService.IsEncrypted(endpoint.Metadata["API_TOKEN"]))
+
+	env, secretEnv := ResolveEdgeStackEnv(edgeStack, endpoint, nil)
+	secretEnv, err = DecryptEnv(secretEnv, secretService)
+	assert.NoError(t, err)
+	assert.Equal(t, []portainer.Pair{{Name: "REGION", Value: "eu"}, {Name: "LOG_LEVEL", Value: "previously-secret"}}, env)
+	assert.Equal(t, []portainer.Pair{{Name: "API_TOKEN", Value: "device-token"}}, secretEnv)
+}
+
+func Test_ValidateEnvValue(t *testing.T) {
+	assert.NoError(t, ValidateEnvValue("token"))
+	assert.NoError(t, ValidateEnvValue("not-encrypted:token"))
+	assert.Error(t, ValidateEnvValue(crypto.EncryptedSecretPrefix+"token"))
+}
+
+func Test_UpdateEdgeStacksEnvVersion(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	assert.NoError(t, store.EndpointGroup().CreateEndpointGroup(&portainer.EndpointGroup{Name: "group"}))
+
+	edgeStacks := []*portainer.EdgeStack{
+		{ID: 1, Version: 1, Env: []portainer.EdgeStackEnvVar{{Name: "REGION", Value: "eu"}}},
+		{ID: 2, Version: 1, Env: []portainer.EdgeStackEnvVar{{Name: "LOG_LEVEL", Value: "info"}}},
+		{ID: 3, Version: 1, Env: []portainer.EdgeStackEnvVar{{Name: "REGION", Value: "eu"}}},
+	}
+	for _, edgeStack := range edgeStacks {
+		edgeStack.Status = map[portainer.EndpointID]portainer.EdgeStackStatus{1: {Type: portainer.StatusOk, EndpointID: 1}}
+		assert.NoError(t, store.EdgeStack().CreateEdgeStack(edgeStack))
+	}
+
+	assert.NoError(t, store.EndpointRelation().CreateEndpointRelation(&portainer.EndpointRelation{
+		EndpointID: 1,
+		EdgeStacks: map[portainer.EdgeStackID]bool{1: true, 2: true, 3: true},
+	}))
+
+	previousEndpoint := &portainer.Endpoint{ID: 1, GroupID: 1}
+	endpoint := &portainer.Endpoint{ID: 1, GroupID: 1, Metadata: map[string]string{"REGION": "us"}}
+	previousEdgeStacks := map[portainer.EdgeStackID]bool{1: true, 2: true}
+
+	assert.NoError(t, UpdateEdgeStacksEnvVersion(store, previousEndpoint, previousEdgeStacks, endpoint))
+
+	updatedEdgeStack, err := store.EdgeStack().EdgeStack(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updatedEdgeStack.Version)
+	assert.Empty(t, updatedEdgeStack.Status)
+
+	unchangedEdgeStack, err := store.EdgeStack().EdgeStack(2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, unchangedEdgeStack.Version)
+	assert.NotEmpty(t, unchangedEdgeStack.Status)
+
+	// stacks newly related to the endpoint are versioned by the relation update
+	newEdgeStack, err := store.EdgeStack().EdgeStack(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, newEdgeStack.Version)
+}
+
+func Test_EdgeStackDeployableOnEndpoint(t *testing.T) {
+	composeStack := &portainer.EdgeStack{DeploymentType: portainer.EdgeStackDeploymentCompose}
+	kubernetesStack := &portainer.EdgeStack{DeploymentType: portainer.EdgeStackDeploymentKubernetes}
+
+	assert.True(t, EdgeStackDeployableOnEndpoint(composeStack, portainer.EdgeAgentOnDockerEnvironment))
+	assert.False(t, EdgeStackDeployableOnEndpoint(composeStack, portainer.EdgeAgentOnKubernetesEnvironment))
+	assert.True(t, EdgeStackDeployableOnEndpoint(kubernetesStack, portainer.EdgeAgentOnKubernetesEnvironment))
+	assert.False(t, EdgeStackDeployableOnEndpoint(kubernetesStack, portainer.EdgeAgentOnDockerEnvironment))
+	assert.False(t, EdgeStackDeployableOnEndpoint(composeStack, portainer.DockerEnvironment))
+}
+
+func Test_EdgeStackImages(t *testing.T) {
+	compose := []byte(`version: "3"
+services:
+  web:
+    image: nginx:1.19
+  worker:
+    image: registry.example.com/team/worker:2.0
+  proxy:
+    image: nginx:1.19
+`)
+
+	images, err := EdgeStackImages(compose)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"nginx:1.19", "registry.example.com/team/worker:2.0"}, images)
+
+	manifest := []byte(`apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox
+      containers:
+        - name: app
+          image: redis:6
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+`)
+
+	images, err = EdgeStackImages(manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"busybox", "redis:6"}, images)
+}
+
+func Test_SealEndpointsMetadata(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	secretService := newTestSecretService(t)
+
+	assert.NoError(t, store.EdgeStack().CreateEdgeStack(&portainer.EdgeStack{
+		ID:  1,
+		Env: []portainer.EdgeStackEnvVar{{Name: "API_TOKEN", Secret: true}, {Name: "REGION"}},
+	}))
+	assert.NoError(t, store.Endpoint().CreateEndpoint(&portainer.Endpoint{
+		ID:       1,
+		Metadata: map[string]string{"API_TOKEN": "device-token", "REGION": "eu"},
+	}))
+
+	assert.NoError(t, SealEndpointsMetadata(store, secretService))
+
+	endpoint, err := store.Endpoint().Endpoint(1)
+	assert.NoError(t, err)
+	assert.True(t, secretService.IsEncrypted(endpoint.Metadata["API_TOKEN"]))
+	assert.Equal(t, "eu", endpoint.Metadata["REGION"])
+}
@@ -0,0 +1,117 @@
+package edge
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/cloudogu/portainer-ce/api"
+)
+
+// EnrollmentTokenExpired returns true if the expiration date of the enrollment token is reached
+func EnrollmentTokenExpired(token *portainer.EdgeEnrollmentToken) bool {
+	return token.ExpiresAt != 0 && time.Now().Unix() >= token.ExpiresAt
+}
+
+// EnrollmentTokenUsable returns true if the enrollment token is neither expired nor exhausted
+func EnrollmentTokenUsable(token *portainer.EdgeEnrollmentToken) bool {
+	if EnrollmentTokenExpired(token) {
+		return false
+	}
+
+	return token.MaxUses == 0 || token.Uses < token.MaxUses
+}
+
+// GenerateEnrollmentSecret returns a random secret issued to an Edge agent on its first enrollment and its hash.
+// Only the hash is persisted, the agent presents the secret when it enrolls again.
+func GenerateEnrollmentSecret() (string, string, error) {
+	key := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret := base64.RawURLEncoding.EncodeToString(key)
+	return secret, enrollmentSecretHash(secret), nil
+}
+
+// EnrollmentSecretMatches returns true if the secret is the one issued to the agent of an enrolled endpoint
+func EnrollmentSecretMatches(enrollment *portainer.EdgeEnrollment, secret string) bool {
+	if enrollment.SecretHash == "" || secret == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(enrollment.SecretHash), []byte(enrollmentSecretHash(secret))) == 1
+}
+
+func enrollmentSecretHash(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// MatchEnrollmentRule returns the first rule, by ascending priority, matching an endpoint enrolled with a token.
+// Nil is returned when no rule matches.
+func MatchEnrollmentRule(endpoint *portainer.Endpoint, rules []portainer.EdgeEnrollmentRule) *portainer.EdgeEnrollmentRule {
+	if endpoint.EdgeEnrollment == nil {
+		return nil
+	}
+
+	sorted := make([]portainer.EdgeEnrollmentRule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority == sorted[j].Priority {
+			return sorted[i].ID < sorted[j].ID
+		}
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	for idx := range sorted {
+		if enrollmentRuleMatches(&sorted[idx], endpoint) {
+			return &sorted[idx]
+		}
+	}
+
+	return nil
+}
+
+func enrollmentRuleMatches(rule *portainer.EdgeEnrollmentRule, endpoint *portainer.Endpoint) bool {
+	if len(rule.TokenIDs) > 0 {
+		found := false
+		for _, tokenID := range rule.TokenIDs {
+			if tokenID == endpoint.EdgeEnrollment.TokenID {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	reportedTags := make(map[string]bool)
+	for _, tag := range endpoint.EdgeEnrollment.Tags {
+		reportedTags[tag] = true
+	}
+
+	for _, tag := range rule.MatchTags {
+		if !reportedTags[tag] {
+			return false
+		}
+	}
+
+	if rule.NamePattern != "" {
+		matched, err := regexp.MatchString(rule.NamePattern, endpoint.Name)
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,49 @@
+package edge
+
+import (
+	"testing"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MatchEnrollmentRule(t *testing.T) {
+	endpoint := &portainer.Endpoint{
+		Name: "store-042",
+		EdgeEnrollment: &portainer.EdgeEnrollment{
+			TokenID: 2,
+			Tags:    []string{"retail", "arm64"},
+		},
+	}
+
+	rules := []portainer.EdgeEnrollmentRule{
+		{ID: 1, Priority: 10, MatchTags: []string{"retail"}},
+		{ID: 2, Priority: 1, MatchTags: []string{"retail", "amd64"}},
+		{ID: 3, Priority: 5, NamePattern: "^store-[0-9]+$", TokenIDs: []portainer.EdgeEnrollmentTokenID{2}},
+		{ID: 4, Priority: 0, TokenIDs: []portainer.EdgeEnrollmentTokenID{1}},
+	}
+
+	rule := MatchEnrollmentRule(endpoint, rules)
+	assert.NotNil(t, rule)
+	assert.Equal(t, portainer.EdgeEnrollmentRuleID(3), rule.ID)
+
+	endpoint.Name = "warehouse"
+	rule = MatchEnrollmentRule(endpoint, rules)
+	assert.NotNil(t, rule)
+	assert.Equal(t, portainer.EdgeEnrollmentRuleID(1), rule.ID)
+
+	endpoint.EdgeEnrollment.Tags = []string{}
+	assert.Nil(t, MatchEnrollmentRule(endpoint, rules))
+}
+
+func Test_EnrollmentSecretMatches(t *testing.T) {
+	secret, secretHash, err := GenerateEnrollmentSecret()
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, secretHash)
+
+	enrollment := &portainer.EdgeEnrollment{SecretHash: secretHash}
+	assert.True(t, EnrollmentSecretMatches(enrollment, secret))
+	assert.False(t, EnrollmentSecretMatches(enrollment, ""))
+	assert.False(t, EnrollmentSecretMatches(enrollment, secret+"x"))
+	assert.False(t, EnrollmentSecretMatches(&portainer.EdgeEnrollment{}, ""))
+}
@@ -0,0 +1,22 @@
+package edge
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// EdgeKeyPortainerURL returns the URL of the Portainer instance encoded inside an Edge key
+func EdgeKeyPortainerURL(edgeKey string) (string, error) {
+	decodedKey, err := base64.RawStdEncoding.DecodeString(edgeKey)
+	if err != nil {
+		return "", err
+	}
+
+	keyInformation := strings.Split(string(decodedKey), "|")
+	if len(keyInformation) != 4 {
+		return "", errors.New("Invalid Edge key format")
+	}
+
+	return keyInformation[0], nil
+}
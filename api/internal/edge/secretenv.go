@@ -0,0 +1,117 @@
+package edge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+)
+
+const (
+	secretEnvDeliveryAttempts   = 5
+	secretEnvDeliveryRetryDelay = 10 * time.Second
+	secretEnvDeliveryTimeout    = 30 * time.Second
+)
+
+type secretEnvPayload struct {
+	Version   int
+	SecretEnv []portainer.Pair
+}
+
+// SecretEnvSender sends the secret environment variables of Edge stacks to the agents through
+// the reverse tunnel of their endpoint. The values are never part of the stack configuration
+// retrieved by the agents nor of the exported bundles.
+type SecretEnvSender struct {
+	dataStore            portainer.DataStore
+	reverseTunnelService portainer.ReverseTunnelService
+	deliveriesMutex      sync.Mutex
+	deliveries           map[string]bool
+}
+
+// NewSecretEnvSender returns a pointer to a SecretEnvSender.
+func NewSecretEnvSender(dataStore portainer.DataStore, reverseTunnelService portainer.ReverseTunnelService) *SecretEnvSender {
+	return &SecretEnvSender{
+		dataStore:            dataStore,
+		reverseTunnelService: reverseTunnelService,
+		deliveries:           make(map[string]bool),
+	}
+}
+
+// SendEdgeStackSecretEnv sends the secret environment variables of an Edge stack version to the agent,
+// the tunnel is opened when it is idle. A single delivery runs at a time for an endpoint and an Edge stack version.
+func (sender *SecretEnvSender) SendEdgeStackSecretEnv(endpointID portainer.EndpointID, edgeStackID portainer.EdgeStackID, version int, secretEnv []portainer.Pair) {
+	delivery := fmt.Sprintf("%d_%d_%d", endpointID, edgeStackID, version)
+
+	sender.deliveriesMutex.Lock()
+	if sender.deliveries[delivery] {
+		sender.deliveriesMutex.Unlock()
+		return
+	}
+	sender.deliveries[delivery] = true
+	sender.deliveriesMutex.Unlock()
+
+	defer func() {
+		sender.deliveriesMutex.Lock()
+		delete(sender.deliveries, delivery)
+		sender.deliveriesMutex.Unlock()
+	}()
+
+	err := WakeUpEdgeAgent(endpointID, sender.reverseTunnelService, sender.dataStore)
+	if err != nil {
+		log.Printf("[ERROR] [edge,secrets] [message: unable to wake up the agent] [endpoint_id: %d] [err: %s]", endpointID, err)
+		return
+	}
+
+	payload, err := json.Marshal(secretEnvPayload{Version: version, SecretEnv: secretEnv})
+	if err != nil {
+		log.Printf("[ERROR] [edge,secrets] [message: unable to encode the secret environment variables] [err: %s]", err)
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = sender.postEdgeStackSecretEnv(endpointID, edgeStackID, payload)
+		if err == nil {
+			return
+		}
+
+		if attempt == secretEnvDeliveryAttempts {
+			log.Printf("[ERROR] [edge,secrets] [message: unable to send the secret environment variables to the agent] [endpoint_id: %d] [edge_stack_id: %d] [err: %s]", endpointID, edgeStackID, err)
+			return
+		}
+
+		time.Sleep(secretEnvDeliveryRetryDelay)
+	}
+}
+
+func (sender *SecretEnvSender) postEdgeStackSecretEnv(endpointID portainer.EndpointID, edgeStackID portainer.EdgeStackID, payload []byte) error {
+	tunnel := sender.reverseTunnelService.GetTunnelDetails(endpointID)
+	if tunnel.Status == portainer.EdgeAgentIdle {
+		return errors.New("The reverse tunnel is not open")
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/edge/stacks/%d/secrets", tunnel.Port, edgeStackID)
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: secretEnvDeliveryTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package edge
+
+import (
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+)
+
+// WakeUpEdgeAgent requests the opening of the reverse tunnel of an Edge endpoint when it is idle
+// and waits for the agent to connect to it during the next check-ins.
+func WakeUpEdgeAgent(endpointID portainer.EndpointID, reverseTunnelService portainer.ReverseTunnelService, dataStore portainer.DataStore) error {
+	tunnel := reverseTunnelService.GetTunnelDetails(endpointID)
+	if tunnel.Status != portainer.EdgeAgentIdle {
+		return nil
+	}
+
+	err := reverseTunnelService.SetTunnelStatusToRequired(endpointID)
+	if err != nil {
+		return err
+	}
+
+	settings, err := dataStore.Settings().Settings()
+	if err != nil {
+		return err
+	}
+
+	waitForAgentToConnect := time.Duration(settings.EdgeAgentCheckinInterval) * time.Second
+	time.Sleep(waitForAgentToConnect * 2)
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package edgestacksync
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/gitfile"
+)
+
+var errNoGitConfig = errors.New("The Edge stack is not deployed from a Git repository")
+
+// Service represents a service used to keep the Edge stacks deployed from a Git repository
+// up to date with the latest commit of their reference.
+type Service struct {
+	dataStore     portainer.DataStore
+	fileService   portainer.FileService
+	gitService    portainer.GitService
+	syncInterval  time.Duration
+	refreshSignal chan struct{}
+	mu            sync.Mutex
+}
+
+// NewService creates a new instance of a service
+func NewService(syncInterval string, dataStore portainer.DataStore, fileService portainer.FileService, gitService portainer.GitService) (*Service, error) {
+	interval, err := time.ParseDuration(syncInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		dataStore:    dataStore,
+		fileService:  fileService,
+		gitService:   gitService,
+		syncInterval: interval,
+	}, nil
+}
+
+// Start will start a background routine to periodically check the repositories of the Edge stacks with auto sync enabled
+func (service *Service) Start() {
+	if service.refreshSignal != nil {
+		return
+	}
+
+	service.refreshSignal = make(chan struct{})
+	service.startSyncLoop()
+}
+
+func (service *Service) startSyncLoop() {
+	ticker := time.NewTicker(service.syncInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				err := service.syncEdgeStacks()
+				if err != nil {
+					log.Printf("[ERROR] [internal,edgestacksync] [message: background schedule error (edge stack Git sync).] [error: %s]", err)
+				}
+
+			case <-service.refreshSignal:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (service *Service) syncEdgeStacks() error {
+	edgeStacks, err := service.dataStore.EdgeStack().EdgeStacks()
+	if err != nil {
+		return err
+	}
+
+	for _, edgeStack := range edgeStacks {
+		if edgeStack.GitConfig == nil || !edgeStack.GitConfig.AutoSync {
+			continue
+		}
+
+		_, err := service.SyncEdgeStack(edgeStack.ID)
+		if err != nil {
+			log.Printf("[WARN] [internal,edgestacksync] [message: unable to sync Edge stack with its Git repository] [edge_stack: %s] [err: %s]", edgeStack.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// SyncEdgeStack checks the repository of an Edge stack for a new commit on its reference. When one is found,
+// the stack file is updated from the repository and the stack version is increased so that the Edge agents
+// redeploy the stack. The result of the check is persisted in the Git configuration of the stack, the stack
+// is not written when the check changed nothing.
+// The repository is checked without holding the lock of the service, the stack is read again before being
+// updated and the result is dropped when the Git configuration of the stack changed in the meantime.
+func (service *Service) SyncEdgeStack(edgeStackID portainer.EdgeStackID) (*portainer.EdgeStack, error) {
+	edgeStack, err := service.dataStore.EdgeStack().EdgeStack(edgeStackID)
+	if err != nil {
+		return nil, err
+	}
+
+	if edgeStack.GitConfig == nil {
+		return nil, errNoGitConfig
+	}
+
+	commitHash, fileContent, syncErr := service.fetchStackFile(edgeStack)
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	currentEdgeStack, err := service.dataStore.EdgeStack().EdgeStack(edgeStackID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sameSource(edgeStack, currentEdgeStack) {
+		return currentEdgeStack, syncErr
+	}
+
+	syncError := ""
+	if syncErr != nil {
+		syncError = syncErr.Error()
+	}
+
+	newCommit := fileContent != nil
+	if !newCommit && currentEdgeStack.GitConfig.LastSyncError == syncError {
+		return currentEdgeStack, syncErr
+	}
+
+	if newCommit {
+		_, err = service.fileService.StoreEdgeStackFileFromBytes(strconv.Itoa(int(currentEdgeStack.ID)), currentEdgeStack.EntryPoint, fileContent)
+		if err != nil {
+			return nil, err
+		}
+
+		currentEdgeStack.GitConfig.CommitHash = commitHash
+		currentEdgeStack.Version++
+		currentEdgeStack.Status = map[portainer.EndpointID]portainer.EdgeStackStatus{}
+	}
+
+	currentEdgeStack.GitConfig.LastSync = time.Now().Unix()
+	currentEdgeStack.GitConfig.LastSyncError = syncError
+
+	err = service.dataStore.EdgeStack().UpdateEdgeStack(currentEdgeStack.ID, currentEdgeStack)
+	if err != nil {
+		return nil, err
+	}
+
+	return currentEdgeStack, syncErr
+}
+
+// sameSource returns true when the stack file of an Edge stack is still read from the same repository,
+// reference and entry point
+func sameSource(edgeStack, currentEdgeStack *portainer.EdgeStack) bool {
+	if currentEdgeStack.GitConfig == nil {
+		return false
+	}
+
+	return edgeStack.EntryPoint == currentEdgeStack.EntryPoint &&
+		edgeStack.GitConfig.URL == currentEdgeStack.GitConfig.URL &&
+		edgeStack.GitConfig.ReferenceName == currentEdgeStack.GitConfig.ReferenceName &&
+		edgeStack.GitConfig.CommitHash == currentEdgeStack.GitConfig.CommitHash
+}
+
+// fetchStackFile returns the latest commit of the reference of the stack and the content of the stack file
+// at this commit. The content is nil when the stack is already up to date.
+func (service *Service) fetchStackFile(edgeStack *portainer.EdgeStack) (string, []byte, error) {
+	gitConfig := edgeStack.GitConfig
+
+	repository := gitfile.Repository{
+		URL:            gitConfig.URL,
+		ReferenceName:  gitConfig.ReferenceName,
+		Authentication: gitConfig.Authentication,
+		Username:       gitConfig.Username,
+		Password:       gitConfig.Password,
+	}
+
+	return gitfile.FetchFile(service.gitService, service.fileService, repository, gitConfig.CommitHash, edgeStack.EntryPoint)
+}
@@ -0,0 +1,174 @@
+package edgestacksync
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/filesystem"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+type testGitService struct {
+	commitHash  string
+	fileContent string
+	err         error
+	clones      int
+	// onLatestCommitID simulates an update of the stack during the check of the repository
+	onLatestCommitID func()
+}
+
+func (service *testGitService) ClonePublicRepository(repositoryURL, referenceName string, destination string) error {
+	service.clones++
+	err := os.MkdirAll(destination, 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(destination, "docker-compose.yml"), []byte(service.fileContent), 0644)
+}
+
+func (service *testGitService) ClonePrivateRepositoryWithBasicAuth(repositoryURL, referenceName string, destination, username, password string) error {
+	return service.ClonePublicRepository(repositoryURL, referenceName, destination)
+}
+
+func (service *testGitService) LatestCommitID(repositoryURL, referenceName, username, password string) (string, error) {
+	if service.onLatestCommitID != nil {
+		service.onLatestCommitID()
+	}
+	return service.commitHash, service.err
+}
+
+func newTestService(t *testing.T, gitService *testGitService) (*Service, portainer.DataStore, func()) {
+	store, teardown := testhelpers.NewDatastore(t)
+
+	dataPath, err := ioutil.TempDir("", "edgestacksync")
+	assert.NoError(t, err)
+
+	fileService, err := filesystem.NewService(dataPath, "")
+	assert.NoError(t, err)
+
+	service, err := NewService("1m", store, fileService, gitService)
+	assert.NoError(t, err)
+
+	return service, store, func() {
+		teardown()
+		os.RemoveAll(dataPath)
+	}
+}
+
+func createGitEdgeStack(t *testing.T, store portainer.DataStore) {
+	assert.NoError(t, store.EdgeStack().CreateEdgeStack(&portainer.EdgeStack{
+		ID:         1,
+		Name:       "stack",
+		Version:    1,
+		EntryPoint: "docker-compose.yml",
+		Status:     map[portainer.EndpointID]portainer.EdgeStackStatus{1: {Type: portainer.StatusOk, EndpointID: 1}},
+		GitConfig:  &portainer.EdgeStackGitConfig{URL: "https://example.com/repo.git", CommitHash: "commit-1", AutoSync: true},
+	}))
+}
+
+func Test_SyncEdgeStack_NewCommit(t *testing.T) {
+	gitService := &testGitService{commitHash: "commit-2", fileContent: "version: '3'"}
+	service, store, teardown := newTestService(t, gitService)
+	defer teardown()
+	createGitEdgeStack(t, store)
+
+	edgeStack, err := service.SyncEdgeStack(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, edgeStack.Version)
+	assert.Empty(t, edgeStack.Status)
+
+	storedEdgeStack, err := store.EdgeStack().EdgeStack(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "commit-2", storedEdgeStack.GitConfig.CommitHash)
+	assert.Equal(t, 2, storedEdgeStack.Version)
+	assert.NotZero(t, storedEdgeStack.GitConfig.LastSync)
+
+	content, err := service.fileService.GetFileContent(path.Join(service.fileService.GetEdgeStackProjectPath("1"), "docker-compose.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "version: '3'", string(content))
+}
+
+func Test_SyncEdgeStack_UpToDateIsNotWritten(t *testing.T) {
+	gitService := &testGitService{commitHash: "commit-1"}
+	service, store, teardown := newTestService(t, gitService)
+	defer teardown()
+	createGitEdgeStack(t, store)
+
+	edgeStack, err := service.SyncEdgeStack(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, edgeStack.Version)
+	assert.Zero(t, gitService.clones)
+
+	storedEdgeStack, err := store.EdgeStack().EdgeStack(1)
+	assert.NoError(t, err)
+	assert.Zero(t, storedEdgeStack.GitConfig.LastSync, "the stack must not be written when nothing changed")
+	assert.NotEmpty(t, storedEdgeStack.Status)
+}
+
+func Test_SyncEdgeStack_RecordsError(t *testing.T) {
+	gitService := &testGitService{err: errors.New("repository unavailable")}
+	service, store, teardown := newTestService(t, gitService)
+	defer teardown()
+	createGitEdgeStack(t, store)
+
+	_, err := service.SyncEdgeStack(1)
+	assert.EqualError(t, err, "repository unavailable")
+
+	storedEdgeStack, err := store.EdgeStack().EdgeStack(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "repository unavailable", storedEdgeStack.GitConfig.LastSyncError)
+	assert.Equal(t, 1, storedEdgeStack.Version)
+}
+
+func Test_SyncEdgeStack_KeepsConcurrentUpdates(t *testing.T) {
+	gitService := &testGitService{commitHash: "commit-2", fileContent: "version: '3'"}
+	service, store, teardown := newTestService(t, gitService)
+	defer teardown()
+	createGitEdgeStack(t, store)
+
+	gitService.onLatestCommitID = func() {
+		edgeStack, err := store.EdgeStack().EdgeStack(1)
+		assert.NoError(t, err)
+		edgeStack.Name = "renamed"
+		edgeStack.Env = []portainer.EdgeStackEnvVar{{Name: "REGION", Value: "eu"}}
+		assert.NoError(t, store.EdgeStack().UpdateEdgeStack(1, edgeStack))
+	}
+
+	_, err := service.SyncEdgeStack(1)
+	assert.NoError(t, err)
+
+	storedEdgeStack, err := store.EdgeStack().EdgeStack(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", storedEdgeStack.Name)
+	assert.Len(t, storedEdgeStack.Env, 1)
+	assert.Equal(t, "commit-2", storedEdgeStack.GitConfig.CommitHash)
+	assert.Equal(t, 2, storedEdgeStack.Version)
+}
+
+func Test_SyncEdgeStack_DropsResultOfChangedSource(t *testing.T) {
+	gitService := &testGitService{commitHash: "commit-2", fileContent: "version: '3'"}
+	service, store, teardown := newTestService(t, gitService)
+	defer teardown()
+	createGitEdgeStack(t, store)
+
+	gitService.onLatestCommitID = func() {
+		edgeStack, err := store.EdgeStack().EdgeStack(1)
+		assert.NoError(t, err)
+		edgeStack.GitConfig.URL = "https://example.com/other.git"
+		assert.NoError(t, store.EdgeStack().UpdateEdgeStack(1, edgeStack))
+	}
+
+	edgeStack, err := service.SyncEdgeStack(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, edgeStack.Version)
+
+	storedEdgeStack, err := store.EdgeStack().EdgeStack(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "commit-1", storedEdgeStack.GitConfig.CommitHash)
+	assert.Equal(t, "https://example.com/other.git", storedEdgeStack.GitConfig.URL)
+}
@@ -0,0 +1,14 @@
+package endpointutils
+
+import (
+	portainer "github.com/cloudogu/portainer-ce/api"
+)
+
+// IsDockerEndpoint returns true if the endpoint is a Docker endpoint, directly or through an agent
+func IsDockerEndpoint(endpoint *portainer.Endpoint) bool {
+	switch endpoint.Type {
+	case portainer.DockerEnvironment, portainer.AgentOnDockerEnvironment, portainer.EdgeAgentOnDockerEnvironment:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package endpointutils
+
+import (
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IsDockerEndpoint(t *testing.T) {
+	dockerTypes := []portainer.EndpointType{portainer.DockerEnvironment, portainer.AgentOnDockerEnvironment, portainer.EdgeAgentOnDockerEnvironment}
+	for _, endpointType := range dockerTypes {
+		assert.True(t, IsDockerEndpoint(&portainer.Endpoint{Type: endpointType}))
+	}
+
+	otherTypes := []portainer.EndpointType{portainer.AzureEnvironment, portainer.KubernetesLocalEnvironment, portainer.AgentOnKubernetesEnvironment, portainer.EdgeAgentOnKubernetesEnvironment}
+	for _, endpointType := range otherTypes {
+		assert.False(t, IsDockerEndpoint(&portainer.Endpoint{Type: endpointType}))
+	}
+}
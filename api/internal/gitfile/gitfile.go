@@ -0,0 +1,51 @@
+package gitfile
+
+import (
+	"path"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+)
+
+// Repository represents the reference of a Git repository and the credentials used to access it
+type Repository struct {
+	URL            string
+	ReferenceName  string
+	Authentication bool
+	Username       string
+	Password       string
+}
+
+// FetchFile returns the latest commit of the reference of a repository and the content of a file at this commit.
+// The repository is only cloned when the latest commit differs from the known commit, the content is nil otherwise.
+func FetchFile(gitService portainer.GitService, fileService portainer.FileService, repository Repository, knownCommit, filePath string) (string, []byte, error) {
+	commitHash, err := gitService.LatestCommitID(repository.URL, repository.ReferenceName, repository.Username, repository.Password)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if knownCommit != "" && commitHash == knownCommit {
+		return commitHash, nil, nil
+	}
+
+	clonePath, err := fileService.GetTemporaryPath()
+	if err != nil {
+		return "", nil, err
+	}
+	defer fileService.RemoveDirectory(clonePath)
+
+	if repository.Authentication {
+		err = gitService.ClonePrivateRepositoryWithBasicAuth(repository.URL, repository.ReferenceName, clonePath, repository.Username, repository.Password)
+	} else {
+		err = gitService.ClonePublicRepository(repository.URL, repository.ReferenceName, clonePath)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	fileContent, err := fileService.GetFileContent(path.Join(clonePath, filePath))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return commitHash, fileContent, nil
+}
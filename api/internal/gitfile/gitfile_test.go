@@ -0,0 +1,83 @@
+package gitfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/cloudogu/portainer-ce/api/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+type testGitService struct {
+	commitHash     string
+	publicClones   int
+	privateClones  int
+	cloneUsername  string
+	repositoryFile string
+}
+
+func (service *testGitService) ClonePublicRepository(repositoryURL, referenceName string, destination string) error {
+	service.publicClones++
+	return service.clone(destination)
+}
+
+func (service *testGitService) ClonePrivateRepositoryWithBasicAuth(repositoryURL, referenceName string, destination, username, password string) error {
+	service.privateClones++
+	service.cloneUsername = username
+	return service.clone(destination)
+}
+
+func (service *testGitService) LatestCommitID(repositoryURL, referenceName, username, password string) (string, error) {
+	return service.commitHash, nil
+}
+
+func (service *testGitService) clone(destination string) error {
+	err := os.MkdirAll(path.Join(destination, "stacks"), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(destination, "stacks", "docker-compose.yml"), []byte(service.repositoryFile), 0644)
+}
+
+func Test_FetchFile(t *testing.T) {
+	dataPath, err := ioutil.TempDir("", "gitfile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	fileService, err := filesystem.NewService(dataPath, "")
+	assert.NoError(t, err)
+
+	gitService := &testGitService{commitHash: "commit-2", repositoryFile: "version: '3'"}
+	repository := Repository{URL: "https://example.com/repo.git"}
+
+	t.Run("the file is read from a clone of a new commit", func(t *testing.T) {
+		commitHash, content, err := FetchFile(gitService, fileService, repository, "commit-1", "stacks/docker-compose.yml")
+		assert.NoError(t, err)
+		assert.Equal(t, "commit-2", commitHash)
+		assert.Equal(t, "version: '3'", string(content))
+		assert.Equal(t, 1, gitService.publicClones)
+	})
+
+	t.Run("the repository is not cloned when the known commit is the latest", func(t *testing.T) {
+		commitHash, content, err := FetchFile(gitService, fileService, repository, "commit-2", "stacks/docker-compose.yml")
+		assert.NoError(t, err)
+		assert.Equal(t, "commit-2", commitHash)
+		assert.Nil(t, content)
+		assert.Equal(t, 1, gitService.publicClones)
+	})
+
+	t.Run("the credentials are used for an authenticated repository", func(t *testing.T) {
+		authenticatedRepository := Repository{URL: "https://example.com/repo.git", Authentication: true, Username: "user", Password: "password"}
+		_, _, err := FetchFile(gitService, fileService, authenticatedRepository, "", "stacks/docker-compose.yml")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, gitService.privateClones)
+		assert.Equal(t, "user", gitService.cloneUsername)
+	})
+
+	t.Run("a missing file is an error", func(t *testing.T) {
+		_, _, err := FetchFile(gitService, fileService, repository, "", "missing.yml")
+		assert.Error(t, err)
+	})
+}
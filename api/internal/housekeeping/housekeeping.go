@@ -0,0 +1,362 @@
+package housekeeping
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/docker"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	"github.com/cloudogu/portainer-ce/api/internal/endpointutils"
+	"github.com/gofrs/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// historyLimit is the maximum number of runs kept in the history of a policy
+	historyLimit = 100
+	// runTimeout is the maximum duration of a policy execution on a single node
+	runTimeout = 30 * time.Minute
+	// executionRetention is the duration during which a completed manual execution can be retrieved
+	executionRetention = time.Hour
+)
+
+// Service represents a service used to schedule and execute housekeeping policies
+// against Docker endpoints.
+type Service struct {
+	dataStore            portainer.DataStore
+	dockerClientFactory  *docker.ClientFactory
+	reverseTunnelService portainer.ReverseTunnelService
+	scheduler            *cron.Cron
+	entries              map[portainer.HousekeepingPolicyID]cron.EntryID
+	entriesMutex         sync.Mutex
+	historyMutex         sync.Mutex
+	executions           map[string]*portainer.HousekeepingExecution
+	executionsMutex      sync.Mutex
+}
+
+// NewService creates a new instance of a service
+func NewService(dataStore portainer.DataStore, dockerClientFactory *docker.ClientFactory, reverseTunnelService portainer.ReverseTunnelService) *Service {
+	return &Service{
+		dataStore:            dataStore,
+		dockerClientFactory:  dockerClientFactory,
+		reverseTunnelService: reverseTunnelService,
+		scheduler:            cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger))),
+		entries:              make(map[portainer.HousekeepingPolicyID]cron.EntryID),
+		executions:           make(map[string]*portainer.HousekeepingExecution),
+	}
+}
+
+// Start schedules every enabled policy stored in the database and starts the scheduler
+func (service *Service) Start() error {
+	policies, err := service.dataStore.HousekeepingPolicy().HousekeepingPolicies()
+	if err != nil {
+		return err
+	}
+
+	for idx := range policies {
+		err := service.SchedulePolicy(&policies[idx])
+		if err != nil {
+			log.Printf("[WARN] [internal,housekeeping] [message: unable to schedule housekeeping policy] [policy: %s] [err: %s]", policies[idx].Name, err)
+		}
+	}
+
+	service.scheduler.Start()
+	return nil
+}
+
+// SchedulePolicy (re)schedules a policy based on its cron expression.
+// Disabled policies are removed from the scheduler.
+func (service *Service) SchedulePolicy(policy *portainer.HousekeepingPolicy) error {
+	service.UnschedulePolicy(policy.ID)
+
+	if !policy.Enabled {
+		return nil
+	}
+
+	policyID := policy.ID
+	entryID, err := service.scheduler.AddFunc(policy.CronExpression, func() {
+		_, err := service.RunPolicy(policyID)
+		if err != nil {
+			log.Printf("[ERROR] [internal,housekeeping] [message: background schedule error (housekeeping policy)] [policy: %d] [err: %s]", policyID, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	service.entriesMutex.Lock()
+	service.entries[policy.ID] = entryID
+	service.entriesMutex.Unlock()
+
+	return nil
+}
+
+// UnschedulePolicy removes a policy from the scheduler
+func (service *Service) UnschedulePolicy(ID portainer.HousekeepingPolicyID) {
+	service.entriesMutex.Lock()
+	defer service.entriesMutex.Unlock()
+
+	entryID, ok := service.entries[ID]
+	if !ok {
+		return
+	}
+
+	service.scheduler.Remove(entryID)
+	delete(service.entries, ID)
+}
+
+// RunPolicy executes a policy on all the Docker endpoints it targets and records the runs
+// inside the policy history.
+func (service *Service) RunPolicy(ID portainer.HousekeepingPolicyID) ([]portainer.HousekeepingRun, error) {
+	policy, err := service.dataStore.HousekeepingPolicy().HousekeepingPolicy(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints, err := service.policyEndpoints(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]portainer.HousekeepingRun, len(endpoints))
+	for idx := range endpoints {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			results[idx] = service.runOnEndpoint(policy, &endpoints[idx])
+		}(idx)
+	}
+	wg.Wait()
+
+	runs := make([]portainer.HousekeepingRun, 0)
+	for _, endpointRuns := range results {
+		runs = append(runs, endpointRuns...)
+	}
+
+	return runs, service.recordRuns(ID, runs)
+}
+
+// StartPolicyExecution executes a policy in the background and returns the execution, whose status can be
+// retrieved with PolicyExecution. The running execution is returned when the policy is already being executed.
+func (service *Service) StartPolicyExecution(ID portainer.HousekeepingPolicyID) (*portainer.HousekeepingExecution, error) {
+	_, err := service.dataStore.HousekeepingPolicy().HousekeepingPolicy(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	service.executionsMutex.Lock()
+	defer service.executionsMutex.Unlock()
+
+	service.pruneExecutions(time.Now())
+
+	for _, execution := range service.executions {
+		if execution.PolicyID == ID && execution.Status == portainer.HousekeepingExecutionRunning {
+			return copyExecution(execution), nil
+		}
+	}
+
+	executionID, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	execution := &portainer.HousekeepingExecution{
+		ID:        executionID.String(),
+		PolicyID:  ID,
+		Status:    portainer.HousekeepingExecutionRunning,
+		StartedAt: time.Now().Unix(),
+		Runs:      []portainer.HousekeepingRun{},
+	}
+	service.executions[execution.ID] = execution
+
+	go service.execute(execution.ID, ID)
+
+	return copyExecution(execution), nil
+}
+
+// PolicyExecution returns a manual execution of a policy, nil is returned when the execution is unknown
+// or was completed more than an hour ago
+func (service *Service) PolicyExecution(ID portainer.HousekeepingPolicyID, executionID string) *portainer.HousekeepingExecution {
+	service.executionsMutex.Lock()
+	defer service.executionsMutex.Unlock()
+
+	service.pruneExecutions(time.Now())
+
+	execution, ok := service.executions[executionID]
+	if !ok || execution.PolicyID != ID {
+		return nil
+	}
+
+	return copyExecution(execution)
+}
+
+func (service *Service) execute(executionID string, policyID portainer.HousekeepingPolicyID) {
+	runs, err := service.RunPolicy(policyID)
+
+	service.executionsMutex.Lock()
+	defer service.executionsMutex.Unlock()
+
+	execution, ok := service.executions[executionID]
+	if !ok {
+		return
+	}
+
+	execution.FinishedAt = time.Now().Unix()
+	execution.Status = portainer.HousekeepingExecutionCompleted
+	if runs != nil {
+		execution.Runs = runs
+	}
+
+	if err != nil {
+		log.Printf("[ERROR] [internal,housekeeping] [message: unable to execute housekeeping policy] [policy: %d] [err: %s]", policyID, err)
+		execution.Status = portainer.HousekeepingExecutionFailed
+		execution.Error = err.Error()
+	}
+}
+
+// pruneExecutions removes the executions completed before the retention period, the lock must be held
+func (service *Service) pruneExecutions(now time.Time) {
+	for executionID, execution := range service.executions {
+		if execution.Status != portainer.HousekeepingExecutionRunning && now.Sub(time.Unix(execution.FinishedAt, 0)) > executionRetention {
+			delete(service.executions, executionID)
+		}
+	}
+}
+
+func copyExecution(execution *portainer.HousekeepingExecution) *portainer.HousekeepingExecution {
+	executionCopy := *execution
+	executionCopy.Runs = append([]portainer.HousekeepingRun{}, execution.Runs...)
+	return &executionCopy
+}
+
+// policyEndpoints returns the Docker endpoints targeted directly by the policy or through one of its endpoint groups
+func (service *Service) policyEndpoints(policy *portainer.HousekeepingPolicy) ([]portainer.Endpoint, error) {
+	endpoints, err := service.dataStore.Endpoint().Endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	endpointIDs := make(map[portainer.EndpointID]bool)
+	for _, endpointID := range policy.EndpointIDs {
+		endpointIDs[endpointID] = true
+	}
+
+	endpointGroupIDs := make(map[portainer.EndpointGroupID]bool)
+	for _, endpointGroupID := range policy.EndpointGroupIDs {
+		endpointGroupIDs[endpointGroupID] = true
+	}
+
+	targets := make([]portainer.Endpoint, 0)
+	for _, endpoint := range endpoints {
+		if !endpointutils.IsDockerEndpoint(&endpoint) {
+			continue
+		}
+
+		if endpointIDs[endpoint.ID] || endpointGroupIDs[endpoint.GroupID] {
+			targets = append(targets, endpoint)
+		}
+	}
+
+	return targets, nil
+}
+
+func (service *Service) runOnEndpoint(policy *portainer.HousekeepingPolicy, endpoint *portainer.Endpoint) []portainer.HousekeepingRun {
+	if endpoint.Type == portainer.EdgeAgentOnDockerEnvironment {
+		err := edge.WakeUpEdgeAgent(endpoint.ID, service.reverseTunnelService, service.dataStore)
+		if err != nil {
+			return []portainer.HousekeepingRun{failedRun(policy, endpoint, "", time.Now(), err)}
+		}
+	}
+
+	nodeNames, err := service.endpointNodes(endpoint)
+	if err != nil {
+		return []portainer.HousekeepingRun{failedRun(policy, endpoint, "", time.Now(), err)}
+	}
+
+	runs := make([]portainer.HousekeepingRun, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		runs = append(runs, service.runOnNode(policy, endpoint, nodeName))
+	}
+
+	return runs
+}
+
+// endpointNodes returns the name of the nodes on which the policy must be executed
+func (service *Service) endpointNodes(endpoint *portainer.Endpoint) ([]string, error) {
+	cli, err := service.dockerClientFactory.CreateClient(endpoint, "")
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	return docker.EndpointNodeNames(context.Background(), cli, endpoint)
+}
+
+func (service *Service) runOnNode(policy *portainer.HousekeepingPolicy, endpoint *portainer.Endpoint, nodeName string) portainer.HousekeepingRun {
+	startedAt := time.Now()
+
+	cli, err := service.dockerClientFactory.CreateClient(endpoint, nodeName)
+	if err != nil {
+		return failedRun(policy, endpoint, nodeName, startedAt, err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	defer cancel()
+
+	reports, err := docker.PruneResources(ctx, cli, policy)
+	if err != nil {
+		return failedRun(policy, endpoint, nodeName, startedAt, err)
+	}
+
+	run := portainer.HousekeepingRun{
+		EndpointID: endpoint.ID,
+		NodeName:   nodeName,
+		StartedAt:  startedAt.Unix(),
+		FinishedAt: time.Now().Unix(),
+		DryRun:     policy.DryRun,
+		Operations: reports,
+	}
+
+	for _, report := range reports {
+		run.SpaceReclaimed += report.SpaceReclaimed
+	}
+
+	return run
+}
+
+// recordRuns appends the runs to the latest version of the policy stored in the database
+func (service *Service) recordRuns(ID portainer.HousekeepingPolicyID, runs []portainer.HousekeepingRun) error {
+	service.historyMutex.Lock()
+	defer service.historyMutex.Unlock()
+
+	policy, err := service.dataStore.HousekeepingPolicy().HousekeepingPolicy(ID)
+	if err != nil {
+		return err
+	}
+
+	policy.History = append(policy.History, runs...)
+	if len(policy.History) > historyLimit {
+		policy.History = policy.History[len(policy.History)-historyLimit:]
+	}
+
+	return service.dataStore.HousekeepingPolicy().UpdateHousekeepingPolicy(ID, policy)
+}
+
+func failedRun(policy *portainer.HousekeepingPolicy, endpoint *portainer.Endpoint, nodeName string, startedAt time.Time, err error) portainer.HousekeepingRun {
+	log.Printf("[WARN] [internal,housekeeping] [message: unable to execute housekeeping policy] [policy: %s] [endpoint: %s] [err: %s]", policy.Name, endpoint.Name, err)
+
+	return portainer.HousekeepingRun{
+		EndpointID: endpoint.ID,
+		NodeName:   nodeName,
+		StartedAt:  startedAt.Unix(),
+		FinishedAt: time.Now().Unix(),
+		DryRun:     policy.DryRun,
+		Operations: []portainer.HousekeepingOperationReport{},
+		Error:      err.Error(),
+	}
+}
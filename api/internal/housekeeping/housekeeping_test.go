@@ -0,0 +1,256 @@
+package housekeeping
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/docker"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSignatureService struct {
+	portainer.DigitalSignatureService
+}
+
+func (service testSignatureService) CreateSignature(message string) (string, error) {
+	return "signature", nil
+}
+
+func (service testSignatureService) EncodedPublicKey() string {
+	return "public-key"
+}
+
+// newTestDaemon returns a Docker daemon, or an agent when nodes are specified, recording the node targeted
+// by each disk usage request
+func newTestDaemon(t *testing.T, nodes ...string) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	targets := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.37/nodes":
+			fmt.Fprint(w, "[")
+			for idx, node := range nodes {
+				if idx > 0 {
+					fmt.Fprint(w, ",")
+				}
+				fmt.Fprintf(w, `{"ID":"%d","Description":{"Hostname":"%s"}}`, idx, node)
+			}
+			fmt.Fprint(w, "]")
+		case "/v1.37/system/df":
+			mu.Lock()
+			targets = append(targets, r.Header.Get(portainer.PortainerAgentTargetHeader))
+			mu.Unlock()
+			fmt.Fprint(w, `{"Images":[],"Volumes":[],"BuildCache":[]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, targets...)
+	}
+}
+
+func newTestService(t *testing.T) (*Service, portainer.DataStore, func()) {
+	store, teardown := testhelpers.NewDatastore(t)
+	service := NewService(store, docker.NewClientFactory(testSignatureService{}, nil), nil)
+	return service, store, teardown
+}
+
+func createTestPolicy(t *testing.T, store portainer.DataStore, policy *portainer.HousekeepingPolicy) *portainer.HousekeepingPolicy {
+	policy.Name = "policy"
+	policy.CronExpression = "@daily"
+	policy.PruneImages = true
+	policy.DryRun = true
+	assert.NoError(t, store.HousekeepingPolicy().CreateHousekeepingPolicy(policy))
+	return policy
+}
+
+func Test_policyEndpoints(t *testing.T) {
+	service, store, teardown := newTestService(t)
+	defer teardown()
+
+	endpoints := []*portainer.Endpoint{
+		{ID: 1, Name: "targeted", Type: portainer.DockerEnvironment, GroupID: 1},
+		{ID: 2, Name: "in group", Type: portainer.AgentOnDockerEnvironment, GroupID: 2},
+		{ID: 3, Name: "kubernetes in group", Type: portainer.KubernetesLocalEnvironment, GroupID: 2},
+		{ID: 4, Name: "not targeted", Type: portainer.DockerEnvironment, GroupID: 1},
+	}
+	for _, endpoint := range endpoints {
+		assert.NoError(t, store.Endpoint().CreateEndpoint(endpoint))
+	}
+
+	targets, err := service.policyEndpoints(&portainer.HousekeepingPolicy{
+		EndpointIDs:      []portainer.EndpointID{1, 3},
+		EndpointGroupIDs: []portainer.EndpointGroupID{2},
+	})
+	assert.NoError(t, err)
+
+	names := []string{}
+	for _, endpoint := range targets {
+		names = append(names, endpoint.Name)
+	}
+	assert.ElementsMatch(t, []string{"targeted", "in group"}, names)
+}
+
+func Test_RunPolicy(t *testing.T) {
+	service, store, teardown := newTestService(t)
+	defer teardown()
+
+	standalone, standaloneTargets := newTestDaemon(t)
+	defer standalone.Close()
+	agent, agentTargets := newTestDaemon(t, "manager", "worker")
+	defer agent.Close()
+
+	assert.NoError(t, store.Endpoint().CreateEndpoint(&portainer.Endpoint{
+		ID:   1,
+		Name: "standalone",
+		Type: portainer.DockerEnvironment,
+		URL:  "tcp://" + standalone.Listener.Addr().String(),
+	}))
+	assert.NoError(t, store.Endpoint().CreateEndpoint(&portainer.Endpoint{
+		ID:        2,
+		Name:      "swarm",
+		Type:      portainer.AgentOnDockerEnvironment,
+		URL:       "tcp://" + agent.Listener.Addr().String(),
+		Snapshots: []portainer.DockerSnapshot{{Swarm: true}},
+	}))
+
+	policy := createTestPolicy(t, store, &portainer.HousekeepingPolicy{EndpointIDs: []portainer.EndpointID{1, 2}})
+
+	runs, err := service.RunPolicy(policy.ID)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 3)
+	for _, run := range runs {
+		assert.Empty(t, run.Error)
+		assert.True(t, run.DryRun)
+	}
+
+	// the policy is executed on each node of the agent Swarm cluster
+	assert.Equal(t, []string{""}, standaloneTargets())
+	assert.ElementsMatch(t, []string{"manager", "worker"}, agentTargets())
+
+	storedPolicy, err := store.HousekeepingPolicy().HousekeepingPolicy(policy.ID)
+	assert.NoError(t, err)
+	assert.Len(t, storedPolicy.History, 3)
+
+	_, err = service.RunPolicy(policy.ID + 1)
+	assert.Equal(t, bolterrors.ErrObjectNotFound, err)
+}
+
+func Test_recordRuns_TrimsHistory(t *testing.T) {
+	service, store, teardown := newTestService(t)
+	defer teardown()
+
+	policy := createTestPolicy(t, store, &portainer.HousekeepingPolicy{})
+
+	runs := make([]portainer.HousekeepingRun, historyLimit+10)
+	for idx := range runs {
+		runs[idx].StartedAt = int64(idx)
+	}
+
+	assert.NoError(t, service.recordRuns(policy.ID, runs[:10]))
+	assert.NoError(t, service.recordRuns(policy.ID, runs[10:]))
+
+	storedPolicy, err := store.HousekeepingPolicy().HousekeepingPolicy(policy.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, storedPolicy.History, historyLimit) {
+		assert.Equal(t, int64(10), storedPolicy.History[0].StartedAt)
+		assert.Equal(t, int64(historyLimit+9), storedPolicy.History[historyLimit-1].StartedAt)
+	}
+}
+
+func Test_StartPolicyExecution(t *testing.T) {
+	service, store, teardown := newTestService(t)
+	defer teardown()
+
+	daemon, _ := newTestDaemon(t)
+	defer daemon.Close()
+
+	assert.NoError(t, store.Endpoint().CreateEndpoint(&portainer.Endpoint{
+		ID:   1,
+		Name: "standalone",
+		Type: portainer.DockerEnvironment,
+		URL:  "tcp://" + daemon.Listener.Addr().String(),
+	}))
+	policy := createTestPolicy(t, store, &portainer.HousekeepingPolicy{EndpointIDs: []portainer.EndpointID{1}})
+
+	execution, err := service.StartPolicyExecution(policy.ID)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, execution.ID)
+
+	for attempt := 0; attempt < 50 && execution.Status == portainer.HousekeepingExecutionRunning; attempt++ {
+		time.Sleep(100 * time.Millisecond)
+		execution = service.PolicyExecution(policy.ID, execution.ID)
+	}
+
+	assert.Equal(t, portainer.HousekeepingExecutionCompleted, execution.Status)
+	assert.Len(t, execution.Runs, 1)
+	assert.NotZero(t, execution.FinishedAt)
+
+	assert.Nil(t, service.PolicyExecution(policy.ID+1, execution.ID))
+	assert.Nil(t, service.PolicyExecution(policy.ID, "unknown"))
+
+	_, err = service.StartPolicyExecution(policy.ID + 1)
+	assert.Equal(t, bolterrors.ErrObjectNotFound, err)
+}
+
+func Test_pruneExecutions(t *testing.T) {
+	service, _, teardown := newTestService(t)
+	defer teardown()
+
+	now := time.Now()
+	service.executions = map[string]*portainer.HousekeepingExecution{
+		"running": {Status: portainer.HousekeepingExecutionRunning},
+		"recent":  {Status: portainer.HousekeepingExecutionCompleted, FinishedAt: now.Add(-time.Minute).Unix()},
+		"expired": {Status: portainer.HousekeepingExecutionCompleted, FinishedAt: now.Add(-2 * executionRetention).Unix()},
+		"failed":  {Status: portainer.HousekeepingExecutionFailed, FinishedAt: now.Add(-2 * executionRetention).Unix()},
+	}
+
+	service.pruneExecutions(now)
+
+	remaining := []string{}
+	for executionID := range service.executions {
+		remaining = append(remaining, executionID)
+	}
+	assert.ElementsMatch(t, []string{"running", "recent"}, remaining)
+}
+
+func Test_SchedulePolicy(t *testing.T) {
+	service, _, teardown := newTestService(t)
+	defer teardown()
+
+	policy := &portainer.HousekeepingPolicy{ID: 1, CronExpression: "@daily", Enabled: true}
+	assert.NoError(t, service.SchedulePolicy(policy))
+	assert.Contains(t, service.entries, policy.ID)
+	assert.Len(t, service.scheduler.Entries(), 1)
+
+	// rescheduling a policy replaces its entry
+	policy.CronExpression = "0 3 * * *"
+	assert.NoError(t, service.SchedulePolicy(policy))
+	assert.Len(t, service.scheduler.Entries(), 1)
+
+	policy.Enabled = false
+	assert.NoError(t, service.SchedulePolicy(policy))
+	assert.NotContains(t, service.entries, policy.ID)
+	assert.Empty(t, service.scheduler.Entries())
+
+	invalidPolicy := &portainer.HousekeepingPolicy{ID: 2, CronExpression: "not a cron expression", Enabled: true}
+	assert.Error(t, service.SchedulePolicy(invalidPolicy))
+	assert.NotContains(t, service.entries, invalidPolicy.ID)
+
+	assert.NoError(t, service.SchedulePolicy(&portainer.HousekeepingPolicy{ID: 3, CronExpression: "@hourly", Enabled: true}))
+	service.UnschedulePolicy(3)
+	assert.Empty(t, service.entries)
+	assert.Empty(t, service.scheduler.Entries())
+}
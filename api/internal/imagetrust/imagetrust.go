@@ -0,0 +1,219 @@
+package imagetrust
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/composefile"
+	"github.com/cloudogu/portainer-ce/api/registry"
+)
+
+// UntrustedImagesError is returned when the enforce policy of an endpoint group rejects images
+type UntrustedImagesError struct {
+	Images []string
+}
+
+func (err *UntrustedImagesError) Error() string {
+	return fmt.Sprintf("Deployment refused, the signature of the following images cannot be verified: %s", strings.Join(err.Images, ", "))
+}
+
+// Service represents a service used to verify the cosign signatures of the images deployed on the endpoints,
+// according to the image trust policy of their endpoint group
+type Service struct {
+	dataStore   portainer.DataStore
+	fileService portainer.FileService
+}
+
+// NewService creates a new instance of a service
+func NewService(dataStore portainer.DataStore, fileService portainer.FileService) *Service {
+	return &Service{
+		dataStore:   dataStore,
+		fileService: fileService,
+	}
+}
+
+// VerifyImages resolves the images to a digest and verifies their signature against the trusted public keys.
+// The returned map associates each verified image to its reference pinned by digest. Unverified images are
+// logged with the audit policy and rejected with an UntrustedImagesError with the enforce policy.
+func (service *Service) VerifyImages(endpoint *portainer.Endpoint, images []string) (map[string]string, error) {
+	policy, err := service.policy(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy == portainer.ImageTrustPolicyNone {
+		return map[string]string{}, nil
+	}
+
+	return service.verifyImages(endpoint, policy, images)
+}
+
+// TrustedComposeFile verifies the images of a Compose file, interpolated with the specified environment,
+// and returns a copy of the file where the verified images are pinned by digest
+func (service *Service) TrustedComposeFile(content []byte, env []portainer.Pair, endpoint *portainer.Endpoint) ([]byte, error) {
+	policy, err := service.policy(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy == portainer.ImageTrustPolicyNone {
+		return content, nil
+	}
+
+	return service.trustedComposeFile(content, env, endpoint, policy)
+}
+
+// TrustedStack verifies the images of the stack file. When images are pinned by digest, the returned stack
+// references a copy of the stack file created next to it, removed by the returned function once deployed.
+func (service *Service) TrustedStack(stack *portainer.Stack, endpoint *portainer.Endpoint) (*portainer.Stack, func(), error) {
+	noCleanUp := func() {}
+
+	policy, err := service.policy(endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if policy == portainer.ImageTrustPolicyNone {
+		return stack, noCleanUp, nil
+	}
+
+	stackFilePath := path.Join(stack.ProjectPath, stack.EntryPoint)
+	content, err := service.fileService.GetFileContent(stackFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trustedContent, err := service.trustedComposeFile(content, stack.Env, endpoint, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if bytes.Equal(content, trustedContent) {
+		return stack, noCleanUp, nil
+	}
+
+	return composefile.PinnedStack(stack, trustedContent)
+}
+
+func (service *Service) policy(endpoint *portainer.Endpoint) (portainer.ImageTrustPolicy, error) {
+	endpointGroup, err := service.dataStore.EndpointGroup().EndpointGroup(endpoint.GroupID)
+	if err != nil {
+		return "", err
+	}
+
+	return endpointGroup.ImageTrustPolicy, nil
+}
+
+func (service *Service) trustedComposeFile(content []byte, env []portainer.Pair, endpoint *portainer.Endpoint, policy portainer.ImageTrustPolicy) ([]byte, error) {
+	serviceImages, err := composefile.ServiceImages(content, env)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]string, 0, len(serviceImages))
+	for _, image := range serviceImages {
+		images = append(images, image)
+	}
+
+	pinnedImages, err := service.verifyImages(endpoint, policy, images)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pinnedImages) == 0 {
+		return content, nil
+	}
+
+	replacements := make(map[string]string)
+	for name, image := range serviceImages {
+		if pinnedImage, ok := pinnedImages[image]; ok {
+			replacements[name] = pinnedImage
+		}
+	}
+
+	return composefile.ReplaceServiceImages(content, replacements)
+}
+
+func (service *Service) verifyImages(endpoint *portainer.Endpoint, policy portainer.ImageTrustPolicy, images []string) (map[string]string, error) {
+	settings, err := service.dataStore.Settings().Settings()
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := parsePublicKeys(settings.ImageTrustKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	pinnedImages := make(map[string]string)
+	verified := make(map[string]bool)
+	rejected := make([]string, 0)
+
+	for _, image := range images {
+		if verified[image] {
+			continue
+		}
+		verified[image] = true
+
+		pinnedImage, err := service.verifyImage(image, keys)
+		if err != nil {
+			if policy == portainer.ImageTrustPolicyEnforce {
+				rejected = append(rejected, fmt.Sprintf("%s (%s)", image, err))
+				continue
+			}
+
+			log.Printf("[WARN] [internal,imagetrust] [message: deploying an image whose signature cannot be verified (audit policy)] [endpoint: %s] [image: %s] [err: %s]", endpoint.Name, image, err)
+			continue
+		}
+
+		pinnedImages[image] = pinnedImage
+	}
+
+	if len(rejected) > 0 {
+		return nil, &UntrustedImagesError{Images: rejected}
+	}
+
+	return pinnedImages, nil
+}
+
+// verifyImage verifies the signature of the digest of an image and returns the image pinned by digest
+func (service *Service) verifyImage(image string, keys []*ecdsa.PublicKey) (string, error) {
+	if len(keys) == 0 {
+		return "", errNoTrustedKey
+	}
+
+	ref, err := registry.ParseImageReference(image)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := registry.NewClientForDomain(service.dataStore, ref.Domain)
+	if err != nil {
+		return "", err
+	}
+
+	digest := ref.Digest
+	if digest == "" {
+		digest, err = client.ManifestDigest(ref.Repository, ref.Tag)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = verifyDigest(client, ref.Repository, digest, keys)
+	if err != nil {
+		return "", err
+	}
+
+	return pinnedReference(image, digest), nil
+}
+
+// pinnedReference adds the digest to an image reference, the tag is kept for readability
+func pinnedReference(image, digest string) string {
+	return strings.SplitN(image, "@", 2)[0] + "@" + digest
+}
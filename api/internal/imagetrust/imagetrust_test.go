@@ -0,0 +1,111 @@
+package imagetrust
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_VerifyImages(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	encodedKey, err := x509.MarshalPKIXPublicKey(&signingKey.PublicKey)
+	assert.NoError(t, err)
+
+	settings, err := store.Settings().Settings()
+	assert.NoError(t, err)
+	settings.ImageTrustKeys = []portainer.ImageTrustKey{
+		{Name: "release", PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: encodedKey}))},
+	}
+	assert.NoError(t, store.Settings().UpdateSettings(settings))
+
+	requests := 0
+	handler := testRegistryHandler(t, signingKey)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	// registries are reached over HTTPS, the client of the test server trusts its certificate
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	signedImage := serverURL.Host + "/app/api:signed"
+	unsignedImage := serverURL.Host + "/app/api:unsigned"
+
+	service := NewService(store, nil)
+
+	endpointForPolicy := func(policy portainer.ImageTrustPolicy) *portainer.Endpoint {
+		group := &portainer.EndpointGroup{Name: string(policy), ImageTrustPolicy: policy}
+		assert.NoError(t, store.EndpointGroup().CreateEndpointGroup(group))
+		return &portainer.Endpoint{Name: "endpoint", GroupID: group.ID}
+	}
+
+	t.Run("enforce policy pins signed images and rejects unsigned images", func(t *testing.T) {
+		endpoint := endpointForPolicy(portainer.ImageTrustPolicyEnforce)
+
+		pinnedImages, err := service.VerifyImages(endpoint, []string{signedImage})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{signedImage: signedImage + "@" + signedDigest}, pinnedImages)
+
+		_, err = service.VerifyImages(endpoint, []string{signedImage, unsignedImage})
+		if assert.IsType(t, &UntrustedImagesError{}, err) {
+			untrustedErr := err.(*UntrustedImagesError)
+			assert.Len(t, untrustedErr.Images, 1)
+			assert.Contains(t, untrustedErr.Images[0], unsignedImage)
+		}
+	})
+
+	t.Run("audit policy pins signed images and deploys unsigned images", func(t *testing.T) {
+		endpoint := endpointForPolicy(portainer.ImageTrustPolicyAudit)
+
+		pinnedImages, err := service.VerifyImages(endpoint, []string{signedImage, unsignedImage})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{signedImage: signedImage + "@" + signedDigest}, pinnedImages)
+	})
+
+	t.Run("no policy does not query the registries", func(t *testing.T) {
+		endpoint := endpointForPolicy(portainer.ImageTrustPolicyNone)
+		requests = 0
+
+		pinnedImages, err := service.VerifyImages(endpoint, []string{signedImage, unsignedImage})
+		assert.NoError(t, err)
+		assert.Empty(t, pinnedImages)
+
+		content := []byte("services:\n  api:\n    image: " + unsignedImage + "\n")
+		trustedContent, err := service.TrustedComposeFile(content, nil, endpoint)
+		assert.NoError(t, err)
+		assert.Equal(t, content, trustedContent)
+		assert.Zero(t, requests)
+	})
+
+	t.Run("enforce policy rejects the unsigned images of a Compose file", func(t *testing.T) {
+		endpoint := endpointForPolicy(portainer.ImageTrustPolicyEnforce)
+
+		content := []byte("services:\n  api:\n    image: " + signedImage + "\n")
+		trustedContent, err := service.TrustedComposeFile(content, nil, endpoint)
+		assert.NoError(t, err)
+		assert.Contains(t, string(trustedContent), signedImage+"@"+signedDigest)
+
+		content = []byte("services:\n  api:\n    image: " + unsignedImage + "\n")
+		_, err = service.TrustedComposeFile(content, nil, endpoint)
+		assert.IsType(t, &UntrustedImagesError{}, err)
+	})
+}
@@ -0,0 +1,98 @@
+package imagetrust
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/registry"
+)
+
+var (
+	errNoTrustedKey     = errors.New("No trusted public key is configured")
+	errUnsigned         = errors.New("The image is not signed")
+	errInvalidSignature = errors.New("No signature of the image matches a trusted public key")
+)
+
+// simpleSigningPayload represents the part of a cosign signature payload identifying the signed digest
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// ParsePublicKey parses a PEM encoded ECDSA public key, the default key type of cosign
+func ParsePublicKey(publicKey string) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return nil, errors.New("Invalid PEM encoded public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Only ECDSA public keys are supported")
+	}
+
+	return ecdsaKey, nil
+}
+
+func parsePublicKeys(trustKeys []portainer.ImageTrustKey) ([]*ecdsa.PublicKey, error) {
+	keys := make([]*ecdsa.PublicKey, 0, len(trustKeys))
+	for _, trustKey := range trustKeys {
+		key, err := ParsePublicKey(trustKey.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// verifyDigest verifies that one of the signatures attached to a digest is made by a trusted key
+func verifyDigest(client *registry.Client, repository, digest string, keys []*ecdsa.PublicKey) error {
+	signatures, err := client.Signatures(repository, digest)
+	if err != nil {
+		return err
+	}
+
+	if len(signatures) == 0 {
+		return errUnsigned
+	}
+
+	for _, signature := range signatures {
+		if verifySignature(signature, digest, keys) {
+			return nil
+		}
+	}
+
+	return errInvalidSignature
+}
+
+// verifySignature returns true if the signature of the payload is made by one of the keys
+// and if the payload references the digest
+func verifySignature(signature registry.Signature, digest string, keys []*ecdsa.PublicKey) bool {
+	hash := sha256.Sum256(signature.Payload)
+
+	for _, key := range keys {
+		if !ecdsa.VerifyASN1(key, hash[:], signature.Signature) {
+			continue
+		}
+
+		var payload simpleSigningPayload
+		err := json.Unmarshal(signature.Payload, &payload)
+		return err == nil && payload.Critical.Image.DockerManifestDigest == digest
+	}
+
+	return false
+}
@@ -0,0 +1,81 @@
+package imagetrust
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudogu/portainer-ce/api/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	signedDigest   = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	unsignedDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// newTestRegistry returns a registry serving a cosign signature of signedDigest in the repository app/api
+func newTestRegistry(t *testing.T, key *ecdsa.PrivateKey) *httptest.Server {
+	return httptest.NewServer(testRegistryHandler(t, key))
+}
+
+// testRegistryHandler serves the tags signed and unsigned of the repository app/api, only the digest of the
+// tag signed has a cosign signature
+func testRegistryHandler(t *testing.T, key *ecdsa.PrivateKey) http.Handler {
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"registry/app/api"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`, signedDigest))
+	hash := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	assert.NoError(t, err)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/app/api/manifests/signed":
+			w.Header().Set("Docker-Content-Digest", signedDigest)
+		case "/v2/app/api/manifests/unsigned":
+			w.Header().Set("Docker-Content-Digest", unsignedDigest)
+		case "/v2/app/api/manifests/sha256-1111111111111111111111111111111111111111111111111111111111111111.sig":
+			fmt.Fprintf(w, `{"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[
+				{"digest":"sha256:payload","annotations":{"dev.cosignproject.cosign/signature":"%s"}}]}`, base64.StdEncoding.EncodeToString(signature))
+		case "/v2/app/api/blobs/sha256:payload":
+			w.Write(payload)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func Test_verifyDigest(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	server := newTestRegistry(t, signingKey)
+	defer server.Close()
+	client := registry.NewClient(server.URL, "", "")
+
+	encodedKey, err := x509.MarshalPKIXPublicKey(&signingKey.PublicKey)
+	assert.NoError(t, err)
+	publicKey, err := ParsePublicKey(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: encodedKey})))
+	assert.NoError(t, err)
+
+	assert.NoError(t, verifyDigest(client, "app/api", signedDigest, []*ecdsa.PublicKey{&otherKey.PublicKey, publicKey}))
+	assert.Equal(t, errInvalidSignature, verifyDigest(client, "app/api", signedDigest, []*ecdsa.PublicKey{&otherKey.PublicKey}))
+	assert.Equal(t, errUnsigned, verifyDigest(client, "app/api", unsignedDigest, []*ecdsa.PublicKey{publicKey}))
+
+	_, err = ParsePublicKey("not a key")
+	assert.Error(t, err)
+}
+
+func Test_pinnedReference(t *testing.T) {
+	assert.Equal(t, "nginx:1.19@"+signedDigest, pinnedReference("nginx:1.19", signedDigest))
+	assert.Equal(t, "nginx@"+signedDigest, pinnedReference("nginx@"+unsignedDigest, signedDigest))
+}
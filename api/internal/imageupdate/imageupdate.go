@@ -0,0 +1,491 @@
+package imageupdate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/docker"
+	"github.com/cloudogu/portainer-ce/api/internal/composefile"
+	"github.com/cloudogu/portainer-ce/api/internal/edge"
+	"github.com/cloudogu/portainer-ce/api/internal/endpointutils"
+	"github.com/cloudogu/portainer-ce/api/internal/snapshot"
+	"github.com/cloudogu/portainer-ce/api/registry"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+var errNoSnapshot = errors.New("No snapshot available for the endpoint")
+
+// Service represents a service used to detect newer images for the containers and services
+// found in the endpoint snapshots. Stacks opting in are redeployed when one of their images is outdated.
+type Service struct {
+	dataStore                portainer.DataStore
+	fileService              portainer.FileService
+	dockerClientFactory      *docker.ClientFactory
+	reverseTunnelService     portainer.ReverseTunnelService
+	swarmStackManager        portainer.SwarmStackManager
+	composeStackManager      portainer.ComposeStackManager
+	imageTrustService        portainer.ImageTrustService
+	vulnerabilityScanService portainer.VulnerabilityScanService
+	checkInterval            time.Duration
+	refreshSignal            chan struct{}
+}
+
+// NewService creates a new instance of a service
+func NewService(checkInterval string, dataStore portainer.DataStore, fileService portainer.FileService, dockerClientFactory *docker.ClientFactory, reverseTunnelService portainer.ReverseTunnelService, swarmStackManager portainer.SwarmStackManager, composeStackManager portainer.ComposeStackManager, imageTrustService portainer.ImageTrustService, vulnerabilityScanService portainer.VulnerabilityScanService) (*Service, error) {
+	interval, err := time.ParseDuration(checkInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		dataStore:                dataStore,
+		fileService:              fileService,
+		dockerClientFactory:      dockerClientFactory,
+		reverseTunnelService:     reverseTunnelService,
+		swarmStackManager:        swarmStackManager,
+		composeStackManager:      composeStackManager,
+		imageTrustService:        imageTrustService,
+		vulnerabilityScanService: vulnerabilityScanService,
+		checkInterval:            interval,
+	}, nil
+}
+
+// Start will start a background routine to periodically check the images of all the Docker endpoints
+func (service *Service) Start() {
+	if service.refreshSignal != nil {
+		return
+	}
+
+	service.refreshSignal = make(chan struct{})
+	service.startCheckLoop()
+}
+
+func (service *Service) startCheckLoop() {
+	ticker := time.NewTicker(service.checkInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				err := service.checkEndpoints()
+				if err != nil {
+					log.Printf("[ERROR] [internal,imageupdate] [message: background schedule error (image update check).] [error: %s]", err)
+				}
+
+			case <-service.refreshSignal:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (service *Service) checkEndpoints() error {
+	endpoints, err := service.dataStore.Endpoint().Endpoints()
+	if err != nil {
+		return err
+	}
+
+	for idx := range endpoints {
+		endpoint := &endpoints[idx]
+		if !endpointutils.IsDockerEndpoint(endpoint) || len(endpoint.Snapshots) == 0 {
+			continue
+		}
+
+		status, err := service.CheckEndpoint(endpoint)
+		if err != nil {
+			log.Printf("[WARN] [internal,imageupdate] [message: unable to check endpoint images] [endpoint: %s] [err: %s]", endpoint.Name, err)
+			continue
+		}
+
+		service.redeployOutdatedStacks(endpoint, status)
+	}
+
+	return nil
+}
+
+// CheckEndpoint compares the digest of the images running in the containers and services of the latest
+// endpoint snapshot with the current digest of their tag inside the registry and persists the result.
+func (service *Service) CheckEndpoint(endpoint *portainer.Endpoint) (*portainer.ImageStatus, error) {
+	if len(endpoint.Snapshots) == 0 {
+		return nil, errNoSnapshot
+	}
+	endpointSnapshot := endpoint.Snapshots[0]
+
+	var containers []types.Container
+	err := snapshot.DecodeSnapshotData(endpointSnapshot.SnapshotRaw.Containers, &containers)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []types.ImageSummary
+	err = snapshot.DecodeSnapshotData(endpointSnapshot.SnapshotRaw.Images, &images)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []swarm.Service
+	err = snapshot.DecodeSnapshotData(endpointSnapshot.SnapshotRaw.Services, &services)
+	if err != nil {
+		return nil, err
+	}
+
+	imagesByID := make(map[string]types.ImageSummary)
+	for _, image := range images {
+		imagesByID[image.ID] = image
+	}
+
+	resolver := newDigestResolver(service.dataStore)
+	resources := make([]portainer.ImageStatusResource, 0, len(containers)+len(services))
+
+	for _, container := range containers {
+		resources = append(resources, containerImageStatus(container, imagesByID, resolver))
+	}
+
+	for _, swarmService := range services {
+		resources = append(resources, serviceImageStatus(swarmService, resolver))
+	}
+
+	status := &portainer.ImageStatus{
+		EndpointID: endpoint.ID,
+		CheckedAt:  time.Now().Unix(),
+		Resources:  resources,
+	}
+
+	err = service.dataStore.ImageStatus().UpdateImageStatus(endpoint.ID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+func containerImageStatus(container types.Container, imagesByID map[string]types.ImageSummary, resolver *digestResolver) portainer.ImageStatusResource {
+	resource := portainer.ImageStatusResource{
+		Type:      portainer.ImageStatusResourceContainer,
+		ID:        container.ID,
+		Image:     container.Image,
+		StackName: container.Labels["com.docker.compose.project"],
+		Status:    portainer.ImageStatusSkipped,
+	}
+
+	if len(container.Names) > 0 {
+		resource.Name = strings.TrimPrefix(container.Names[0], "/")
+	}
+
+	if resource.StackName == "" {
+		resource.StackName = container.Labels["com.docker.stack.namespace"]
+	}
+
+	// the Docker engine reports the image identifier when the image was untagged after the container creation
+	if strings.HasPrefix(container.Image, "sha256:") {
+		return resource
+	}
+
+	ref, err := registry.ParseImageReference(container.Image)
+	if err != nil || ref.Tag == "" {
+		return resource
+	}
+
+	runningDigests := make([]string, 0)
+	for _, repoDigest := range imagesByID[container.ImageID].RepoDigests {
+		if ref.SameRepository(repoDigest) {
+			runningDigests = append(runningDigests, repoDigest[strings.LastIndex(repoDigest, "@")+1:])
+		}
+	}
+
+	if len(runningDigests) == 0 {
+		return resource
+	}
+	resource.RunningDigest = runningDigests[0]
+
+	latestDigest, err := resolver.digest(ref)
+	if err != nil {
+		resource.Status = portainer.ImageStatusError
+		resource.Error = err.Error()
+		return resource
+	}
+	resource.LatestDigest = latestDigest
+
+	resource.Status = portainer.ImageStatusOutdated
+	for _, digest := range runningDigests {
+		if digest == latestDigest {
+			resource.RunningDigest = digest
+			resource.Status = portainer.ImageStatusUpToDate
+		}
+	}
+
+	return resource
+}
+
+func serviceImageStatus(swarmService swarm.Service, resolver *digestResolver) portainer.ImageStatusResource {
+	resource := portainer.ImageStatusResource{
+		Type:      portainer.ImageStatusResourceService,
+		ID:        swarmService.ID,
+		Name:      swarmService.Spec.Name,
+		StackName: swarmService.Spec.Labels["com.docker.stack.namespace"],
+		Status:    portainer.ImageStatusSkipped,
+	}
+
+	if swarmService.Spec.TaskTemplate.ContainerSpec == nil {
+		return resource
+	}
+	resource.Image = swarmService.Spec.TaskTemplate.ContainerSpec.Image
+
+	// services deployed with a resolved image are pinned to the digest of the tag at deployment time
+	ref, err := registry.ParseImageReference(resource.Image)
+	if err != nil || ref.Tag == "" || ref.Digest == "" {
+		return resource
+	}
+	resource.RunningDigest = ref.Digest
+
+	latestDigest, err := resolver.digest(ref)
+	if err != nil {
+		resource.Status = portainer.ImageStatusError
+		resource.Error = err.Error()
+		return resource
+	}
+	resource.LatestDigest = latestDigest
+
+	resource.Status = portainer.ImageStatusOutdated
+	if latestDigest == ref.Digest {
+		resource.Status = portainer.ImageStatusUpToDate
+	}
+
+	return resource
+}
+
+// redeployOutdatedStacks redeploys the stacks with the auto update option enabled that are running outdated images
+func (service *Service) redeployOutdatedStacks(endpoint *portainer.Endpoint, status *portainer.ImageStatus) {
+	outdatedStacks := make(map[string]bool)
+	for _, resource := range status.Resources {
+		if resource.Status == portainer.ImageStatusOutdated && resource.StackName != "" {
+			outdatedStacks[resource.StackName] = true
+		}
+	}
+
+	if len(outdatedStacks) == 0 {
+		return
+	}
+
+	stacks, err := service.dataStore.Stack().Stacks()
+	if err != nil {
+		log.Printf("[WARN] [internal,imageupdate] [message: unable to retrieve stacks] [err: %s]", err)
+		return
+	}
+
+	for idx := range stacks {
+		stack := &stacks[idx]
+		if stack.EndpointID != endpoint.ID || !stack.AutoUpdateImages {
+			continue
+		}
+
+		if !outdatedStacks[stackProjectName(stack)] {
+			continue
+		}
+
+		err := service.redeployStack(stack, endpoint)
+		if err != nil {
+			log.Printf("[WARN] [internal,imageupdate] [message: unable to redeploy stack with updated images] [stack: %s] [endpoint: %s] [err: %s]", stack.Name, endpoint.Name, err)
+			continue
+		}
+
+		log.Printf("[INFO] [internal,imageupdate] [message: stack redeployed with updated images] [stack: %s] [endpoint: %s]", stack.Name, endpoint.Name)
+	}
+}
+
+func (service *Service) redeployStack(stack *portainer.Stack, endpoint *portainer.Endpoint) error {
+	dockerhub, err := service.dataStore.DockerHub().DockerHub()
+	if err != nil {
+		return err
+	}
+
+	registries, err := service.dataStore.Registry().Registries()
+	if err != nil {
+		return err
+	}
+
+	deployedStack, cleanUp, err := service.deployableStack(stack, endpoint)
+	if err != nil {
+		return err
+	}
+	defer cleanUp()
+
+	if stack.Type == portainer.DockerSwarmStack {
+		return service.swarmStackManager.Deploy(deployedStack, false, endpoint, dockerhub, registries)
+	}
+
+	// docker-compose only recreates the containers whose image changed locally,
+	// the images of the deployed stack file must be pulled beforehand
+	content, err := service.fileService.GetFileContent(path.Join(deployedStack.ProjectPath, deployedStack.EntryPoint))
+	if err != nil {
+		return err
+	}
+
+	serviceImages, err := composefile.ServiceImages(content, deployedStack.Env)
+	if err != nil {
+		return err
+	}
+
+	images := make([]string, 0, len(serviceImages))
+	for _, image := range serviceImages {
+		images = append(images, image)
+	}
+
+	err = service.pullImages(endpoint, images)
+	if err != nil {
+		return err
+	}
+
+	return service.composeStackManager.Up(deployedStack, endpoint, dockerhub, registries)
+}
+
+// deployableStack returns the stack to redeploy, verified like a deployment from the API: the images of the
+// stack file are verified by the image trust policy of the endpoint, then by the vulnerability deploy gate
+// when it is enabled. The returned stack can reference a copy of the stack file pinning the verified images,
+// it is removed by the returned function once deployed.
+func (service *Service) deployableStack(stack *portainer.Stack, endpoint *portainer.Endpoint) (*portainer.Stack, func(), error) {
+	settings, err := service.dataStore.Settings().Settings()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trustedStack, cleanUp, err := service.imageTrustService.TrustedStack(stack, endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !settings.ImageScanSettings.Enabled || !settings.ImageScanSettings.DeployGate {
+		return trustedStack, cleanUp, nil
+	}
+
+	content, err := service.fileService.GetFileContent(path.Join(trustedStack.ProjectPath, trustedStack.EntryPoint))
+	if err != nil {
+		cleanUp()
+		return nil, nil, err
+	}
+
+	scannedStack, scanCleanUp, err := composefile.ScannedStack(trustedStack, content, service.vulnerabilityScanService)
+	if err != nil {
+		cleanUp()
+		return nil, nil, err
+	}
+
+	return scannedStack, func() {
+		scanCleanUp()
+		cleanUp()
+	}, nil
+}
+
+func (service *Service) pullImages(endpoint *portainer.Endpoint, images []string) error {
+	if endpoint.Type == portainer.EdgeAgentOnDockerEnvironment {
+		err := edge.WakeUpEdgeAgent(endpoint.ID, service.reverseTunnelService, service.dataStore)
+		if err != nil {
+			return err
+		}
+	}
+
+	cli, err := service.dockerClientFactory.CreateClient(endpoint, "")
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	for _, image := range images {
+		ref, err := registry.ParseImageReference(image)
+		if err != nil {
+			return err
+		}
+
+		credentials, err := registry.FindCredentials(service.dataStore, ref.Domain)
+		if err != nil {
+			return err
+		}
+
+		options := types.ImagePullOptions{}
+		if credentials != nil {
+			options.RegistryAuth, err = registry.EncodeAuthConfig(credentials, ref.Domain)
+			if err != nil {
+				return err
+			}
+		}
+
+		reader, err := cli.ImagePull(context.Background(), image, options)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(ioutil.Discard, reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// digestResolver resolves and caches the digests of image tags during a check
+type digestResolver struct {
+	dataStore portainer.DataStore
+	clients   map[string]*registry.Client
+	digests   map[string]string
+	errors    map[string]error
+}
+
+func newDigestResolver(dataStore portainer.DataStore) *digestResolver {
+	return &digestResolver{
+		dataStore: dataStore,
+		clients:   make(map[string]*registry.Client),
+		digests:   make(map[string]string),
+		errors:    make(map[string]error),
+	}
+}
+
+func (resolver *digestResolver) digest(ref *registry.ImageReference) (string, error) {
+	key := ref.Name() + ":" + ref.Tag
+	if digest, ok := resolver.digests[key]; ok {
+		return digest, nil
+	}
+
+	if err, ok := resolver.errors[key]; ok {
+		return "", err
+	}
+
+	client, ok := resolver.clients[ref.Domain]
+	if !ok {
+		var err error
+		client, err = registry.NewClientForDomain(resolver.dataStore, ref.Domain)
+		if err != nil {
+			return "", err
+		}
+		resolver.clients[ref.Domain] = client
+	}
+
+	digest, err := client.ManifestDigest(ref.Repository, ref.Tag)
+	if err != nil {
+		resolver.errors[key] = err
+		return "", err
+	}
+
+	resolver.digests[key] = digest
+	return digest, nil
+}
+
+// stackProjectName returns the name used in the labels of the resources of a stack.
+// Compose project names are normalized the same way libcompose does.
+func stackProjectName(stack *portainer.Stack) string {
+	if stack.Type == portainer.DockerSwarmStack {
+		return stack.Name
+	}
+
+	return regexp.MustCompile("[^a-z0-9]+").ReplaceAllString(strings.ToLower(stack.Name), "")
+}
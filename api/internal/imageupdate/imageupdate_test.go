@@ -0,0 +1,383 @@
+package imageupdate
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/docker"
+	"github.com/cloudogu/portainer-ce/api/filesystem"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/cloudogu/portainer-ce/api/registry"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	runningDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	latestDigest  = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// newTestRegistry returns a registry:2 serving the tags 1.0 (latestDigest) and 2.0 (runningDigest) of the
+// repository app/api. Requests must be authenticated with user:pass when authentication is required.
+func newTestRegistry(t *testing.T, authenticationRequired bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authenticationRequired {
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "user" || password != "pass" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		switch r.URL.Path {
+		case "/v2/app/api/manifests/1.0":
+			w.Header().Set("Docker-Content-Digest", latestDigest)
+		case "/v2/app/api/manifests/2.0":
+			w.Header().Set("Docker-Content-Digest", runningDigest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// createTestRegistry defines a registry served over HTTP inside Portainer
+func createTestRegistry(t *testing.T, store portainer.DataStore, server *httptest.Server, authentication bool) string {
+	reg := &portainer.Registry{
+		Name:               server.URL,
+		URL:                server.URL,
+		Authentication:     authentication,
+		UserAccessPolicies: portainer.UserAccessPolicies{},
+		TeamAccessPolicies: portainer.TeamAccessPolicies{},
+	}
+	if authentication {
+		reg.Username = "user"
+		reg.Password = "pass"
+	}
+	assert.NoError(t, store.Registry().CreateRegistry(reg))
+
+	return registry.RegistryHost(server.URL)
+}
+
+type testRegistries struct {
+	public        string
+	private       string
+	noCredentials string
+	unreachable   string
+}
+
+func setupTestRegistries(t *testing.T, store portainer.DataStore) (testRegistries, func()) {
+	public := newTestRegistry(t, false)
+	private := newTestRegistry(t, true)
+	noCredentials := newTestRegistry(t, true)
+	unreachable := newTestRegistry(t, false)
+
+	registries := testRegistries{
+		public:        createTestRegistry(t, store, public, false),
+		private:       createTestRegistry(t, store, private, true),
+		noCredentials: createTestRegistry(t, store, noCredentials, false),
+		unreachable:   createTestRegistry(t, store, unreachable, false),
+	}
+	unreachable.Close()
+
+	return registries, func() {
+		public.Close()
+		private.Close()
+		noCredentials.Close()
+	}
+}
+
+func Test_containerImageStatus(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	registries, closeRegistries := setupTestRegistries(t, store)
+	defer closeRegistries()
+
+	container := func(host, tag string) types.Container {
+		return types.Container{
+			ID:      "container",
+			Names:   []string{"/web_api_1"},
+			Image:   host + "/app/api:" + tag,
+			ImageID: "sha256:image-" + host,
+			Labels:  map[string]string{"com.docker.compose.project": "web"},
+		}
+	}
+
+	imagesByID := make(map[string]types.ImageSummary)
+	for _, host := range []string{registries.public, registries.private, registries.noCredentials, registries.unreachable} {
+		imagesByID["sha256:image-"+host] = types.ImageSummary{RepoDigests: []string{host + "/app/api@" + runningDigest}}
+	}
+
+	tests := []struct {
+		name           string
+		container      types.Container
+		expectedStatus portainer.ImageStatusType
+		expectedLatest string
+	}{
+		{"digest changed", container(registries.public, "1.0"), portainer.ImageStatusOutdated, latestDigest},
+		{"digest unchanged", container(registries.public, "2.0"), portainer.ImageStatusUpToDate, runningDigest},
+		{"authentication required", container(registries.private, "1.0"), portainer.ImageStatusOutdated, latestDigest},
+		{"authentication required without credentials", container(registries.noCredentials, "1.0"), portainer.ImageStatusError, ""},
+		{"registry unreachable", container(registries.unreachable, "1.0"), portainer.ImageStatusError, ""},
+		{"image identifier", types.Container{ID: "container", Image: runningDigest}, portainer.ImageStatusSkipped, ""},
+	}
+
+	resolver := newDigestResolver(store)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resource := containerImageStatus(test.container, imagesByID, resolver)
+
+			assert.Equal(t, test.expectedStatus, resource.Status)
+			assert.Equal(t, test.expectedLatest, resource.LatestDigest)
+			if test.expectedStatus == portainer.ImageStatusError {
+				assert.NotEmpty(t, resource.Error)
+			}
+		})
+	}
+
+	resource := containerImageStatus(container(registries.public, "1.0"), imagesByID, resolver)
+	assert.Equal(t, "web_api_1", resource.Name)
+	assert.Equal(t, "web", resource.StackName)
+	assert.Equal(t, runningDigest, resource.RunningDigest)
+}
+
+func Test_serviceImageStatus(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	registries, closeRegistries := setupTestRegistries(t, store)
+	defer closeRegistries()
+
+	service := func(image string) swarm.Service {
+		return swarm.Service{
+			ID: "service",
+			Spec: swarm.ServiceSpec{
+				Annotations: swarm.Annotations{Name: "web_api", Labels: map[string]string{"com.docker.stack.namespace": "web"}},
+				TaskTemplate: swarm.TaskSpec{
+					ContainerSpec: &swarm.ContainerSpec{Image: image},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		service        swarm.Service
+		expectedStatus portainer.ImageStatusType
+	}{
+		{"digest changed", service(registries.public + "/app/api:1.0@" + runningDigest), portainer.ImageStatusOutdated},
+		{"digest unchanged", service(registries.public + "/app/api:2.0@" + runningDigest), portainer.ImageStatusUpToDate},
+		{"authentication required", service(registries.private + "/app/api:2.0@" + runningDigest), portainer.ImageStatusUpToDate},
+		{"authentication required without credentials", service(registries.noCredentials + "/app/api:2.0@" + runningDigest), portainer.ImageStatusError},
+		{"registry unreachable", service(registries.unreachable + "/app/api:2.0@" + runningDigest), portainer.ImageStatusError},
+		{"image not pinned by digest", service(registries.public + "/app/api:1.0"), portainer.ImageStatusSkipped},
+	}
+
+	resolver := newDigestResolver(store)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resource := serviceImageStatus(test.service, resolver)
+
+			assert.Equal(t, test.expectedStatus, resource.Status)
+			assert.Equal(t, "web", resource.StackName)
+		})
+	}
+}
+
+// deployedStackFile returns the content of the stack file deployed for a stack
+func deployedStackFile(stack *portainer.Stack) string {
+	content, err := ioutil.ReadFile(path.Join(stack.ProjectPath, stack.EntryPoint))
+	if err != nil {
+		return err.Error()
+	}
+	return string(content)
+}
+
+type testSwarmStackManager struct {
+	portainer.SwarmStackManager
+	deployed      []string
+	deployedFiles []string
+}
+
+func (manager *testSwarmStackManager) Deploy(stack *portainer.Stack, prune bool, endpoint *portainer.Endpoint, dockerhub *portainer.DockerHub, registries []portainer.Registry) error {
+	manager.deployed = append(manager.deployed, stack.Name)
+	manager.deployedFiles = append(manager.deployedFiles, deployedStackFile(stack))
+	return nil
+}
+
+type testComposeStackManager struct {
+	portainer.ComposeStackManager
+	deployed      []string
+	deployedFiles []string
+}
+
+func (manager *testComposeStackManager) Up(stack *portainer.Stack, endpoint *portainer.Endpoint, dockerhub *portainer.DockerHub, registries []portainer.Registry) error {
+	manager.deployed = append(manager.deployed, stack.Name)
+	manager.deployedFiles = append(manager.deployedFiles, deployedStackFile(stack))
+	return nil
+}
+
+type testImageTrustService struct {
+	portainer.ImageTrustService
+}
+
+func (service testImageTrustService) TrustedStack(stack *portainer.Stack, endpoint *portainer.Endpoint) (*portainer.Stack, func(), error) {
+	return stack, func() {}, nil
+}
+
+type testVulnerabilityScanService struct {
+	portainer.VulnerabilityScanService
+	pinnedImages map[string]string
+	err          error
+}
+
+func (service *testVulnerabilityScanService) VerifyImages(images []string) (map[string]string, error) {
+	return service.pinnedImages, service.err
+}
+
+func newTestFileService(t *testing.T) (portainer.FileService, func()) {
+	dataPath, err := ioutil.TempDir("", "imageupdate")
+	assert.NoError(t, err)
+
+	fileService, err := filesystem.NewService(dataPath, "")
+	assert.NoError(t, err)
+
+	return fileService, func() { os.RemoveAll(dataPath) }
+}
+
+func Test_redeployOutdatedStacks(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	fileService, removeFiles := newTestFileService(t)
+	defer removeFiles()
+
+	var mu sync.Mutex
+	pulledImages := []string{}
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1.37/images/create" {
+			mu.Lock()
+			pulledImages = append(pulledImages, r.URL.Query().Get("fromImage")+":"+r.URL.Query().Get("tag"))
+			mu.Unlock()
+			fmt.Fprint(w, `{"status":"Downloaded newer image"}`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer daemon.Close()
+
+	endpoint := &portainer.Endpoint{ID: 1, Name: "endpoint", Type: portainer.DockerEnvironment, URL: "tcp://" + daemon.Listener.Addr().String()}
+
+	stacks := []*portainer.Stack{
+		{ID: 1, Name: "web", Type: portainer.DockerSwarmStack, EndpointID: 1, AutoUpdateImages: true},
+		{ID: 2, Name: "My-App", Type: portainer.DockerComposeStack, EndpointID: 1, AutoUpdateImages: true},
+		{ID: 3, Name: "manual", Type: portainer.DockerSwarmStack, EndpointID: 1},
+		{ID: 4, Name: "other", Type: portainer.DockerSwarmStack, EndpointID: 2, AutoUpdateImages: true},
+		{ID: 5, Name: "current", Type: portainer.DockerSwarmStack, EndpointID: 1, AutoUpdateImages: true},
+	}
+	stackFiles := map[string]string{
+		"web":    "version: '3'\nservices:\n  web:\n    image: nginx:1.19\n",
+		"My-App": "version: '3'\nservices:\n  cache:\n    image: redis:6\n",
+	}
+	for _, stack := range stacks {
+		stack.EntryPoint = "docker-compose.yml"
+		if stackFile, ok := stackFiles[stack.Name]; ok {
+			projectPath, err := fileService.StoreStackFileFromBytes(strconv.Itoa(int(stack.ID)), stack.EntryPoint, []byte(stackFile))
+			assert.NoError(t, err)
+			stack.ProjectPath = projectPath
+		}
+		assert.NoError(t, store.Stack().CreateStack(stack))
+	}
+
+	swarmStackManager := &testSwarmStackManager{}
+	composeStackManager := &testComposeStackManager{}
+	vulnerabilityScanService := &testVulnerabilityScanService{}
+	service := &Service{
+		dataStore:                store,
+		fileService:              fileService,
+		dockerClientFactory:      docker.NewClientFactory(nil, nil),
+		swarmStackManager:        swarmStackManager,
+		composeStackManager:      composeStackManager,
+		imageTrustService:        testImageTrustService{},
+		vulnerabilityScanService: vulnerabilityScanService,
+	}
+
+	outdatedStatus := &portainer.ImageStatus{
+		EndpointID: 1,
+		Resources: []portainer.ImageStatusResource{
+			{StackName: "web", Image: "nginx:1.19", Status: portainer.ImageStatusOutdated},
+			{StackName: "myapp", Image: "redis:6", Status: portainer.ImageStatusOutdated},
+		},
+	}
+
+	service.redeployOutdatedStacks(endpoint, &portainer.ImageStatus{
+		EndpointID: 1,
+		Resources: []portainer.ImageStatusResource{
+			{StackName: "web", Image: "nginx:1.19", Status: portainer.ImageStatusOutdated},
+			{StackName: "myapp", Image: "redis:6", Status: portainer.ImageStatusOutdated},
+			{StackName: "manual", Image: "nginx:1.19", Status: portainer.ImageStatusOutdated},
+			{StackName: "other", Image: "nginx:1.19", Status: portainer.ImageStatusOutdated},
+			{StackName: "current", Image: "nginx:1.19", Status: portainer.ImageStatusUpToDate},
+			{Image: "nginx:1.19", Status: portainer.ImageStatusOutdated},
+		},
+	})
+
+	assert.Equal(t, []string{"web"}, swarmStackManager.deployed)
+	assert.Equal(t, []string{"My-App"}, composeStackManager.deployed)
+	assert.Equal(t, []string{"redis:6"}, pulledImages)
+
+	settings, err := store.Settings().Settings()
+	assert.NoError(t, err)
+	settings.ImageScanSettings = portainer.ImageScanSettings{Enabled: true, DeployGate: true}
+	assert.NoError(t, store.Settings().UpdateSettings(settings))
+
+	t.Run("the scanned images are deployed by digest", func(t *testing.T) {
+		*swarmStackManager = testSwarmStackManager{}
+		*composeStackManager = testComposeStackManager{}
+		pulledImages = []string{}
+		vulnerabilityScanService.pinnedImages = map[string]string{
+			"nginx:1.19": "nginx@" + latestDigest,
+			"redis:6":    "redis@" + latestDigest,
+		}
+		vulnerabilityScanService.err = nil
+
+		service.redeployOutdatedStacks(endpoint, outdatedStatus)
+
+		assert.Equal(t, []string{"web"}, swarmStackManager.deployed)
+		assert.Contains(t, swarmStackManager.deployedFiles[0], "nginx@"+latestDigest)
+		assert.Equal(t, []string{"My-App"}, composeStackManager.deployed)
+		assert.Contains(t, composeStackManager.deployedFiles[0], "redis@"+latestDigest)
+		assert.Equal(t, []string{"redis:" + latestDigest}, pulledImages)
+	})
+
+	t.Run("stacks rejected by the deploy gate are not redeployed", func(t *testing.T) {
+		*swarmStackManager = testSwarmStackManager{}
+		*composeStackManager = testComposeStackManager{}
+		pulledImages = []string{}
+		vulnerabilityScanService.pinnedImages = nil
+		vulnerabilityScanService.err = errors.New("critical vulnerabilities found")
+
+		service.redeployOutdatedStacks(endpoint, outdatedStatus)
+
+		assert.Empty(t, swarmStackManager.deployed)
+		assert.Empty(t, composeStackManager.deployed)
+		assert.Empty(t, pulledImages)
+	})
+}
+
+func Test_stackProjectName(t *testing.T) {
+	assert.Equal(t, "My-App", stackProjectName(&portainer.Stack{Name: "My-App", Type: portainer.DockerSwarmStack}))
+	assert.Equal(t, "myapp", stackProjectName(&portainer.Stack{Name: "My-App", Type: portainer.DockerComposeStack}))
+	assert.Equal(t, "webapi2", stackProjectName(&portainer.Stack{Name: "web_api.2", Type: portainer.DockerComposeStack}))
+}
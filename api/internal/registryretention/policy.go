@@ -0,0 +1,149 @@
+package registryretention
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/registry"
+)
+
+// ValidatePolicies checks the retention policies of a registry. Each repository can only have one policy,
+// the default policy included, and each policy must define at least one rule.
+func ValidatePolicies(policies []portainer.RegistryRetentionPolicy) error {
+	repositories := map[string]bool{}
+
+	for _, policy := range policies {
+		if repositories[policy.Repository] {
+			return fmt.Errorf("More than one retention policy is defined for repository %q", policy.Repository)
+		}
+		repositories[policy.Repository] = true
+
+		if policy.KeepLast < 0 || policy.MaxAgeDays < 0 {
+			return errors.New("Invalid retention policy. KeepLast and MaxAgeDays cannot be negative")
+		}
+
+		if policy.KeepLast == 0 && policy.MaxAgeDays == 0 && policy.KeepPattern == "" {
+			return errors.New("Invalid retention policy. At least one of KeepLast, KeepPattern or MaxAgeDays must be defined")
+		}
+
+		if policy.KeepPattern != "" {
+			_, err := regexp.Compile(policy.KeepPattern)
+			if err != nil {
+				return fmt.Errorf("Invalid retention policy pattern: %s", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// policyForRepository returns the policy defined for a repository or the default policy of the registry
+func policyForRepository(policies []portainer.RegistryRetentionPolicy, repository string) *portainer.RegistryRetentionPolicy {
+	var defaultPolicy *portainer.RegistryRetentionPolicy
+
+	for idx := range policies {
+		switch policies[idx].Repository {
+		case repository:
+			return &policies[idx]
+		case "":
+			defaultPolicy = &policies[idx]
+		}
+	}
+
+	return defaultPolicy
+}
+
+// selectTags decides which tags of a repository are deleted by a retention policy. The tags are sorted from
+// the most recent to the oldest one. A tag is kept when it is in use or when any rule of the policy keeps it.
+func selectTags(tags []portainer.RegistryRetentionTag, inUse map[string]bool, policy *portainer.RegistryRetentionPolicy, now time.Time) error {
+	var pattern *regexp.Regexp
+	if policy.KeepPattern != "" {
+		var err error
+		pattern, err = regexp.Compile("^(?:" + policy.KeepPattern + ")$")
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].Created == tags[j].Created {
+			return tags[i].Tag < tags[j].Tag
+		}
+		return tags[i].Created > tags[j].Created
+	})
+
+	maxAge := time.Duration(policy.MaxAgeDays) * 24 * time.Hour
+
+	for idx := range tags {
+		tag := &tags[idx]
+		tag.Delete = false
+
+		switch {
+		case inUse[tag.Tag]:
+			tag.Reason = portainer.RegistryRetentionInUse
+		case pattern != nil && pattern.MatchString(tag.Tag):
+			tag.Reason = portainer.RegistryRetentionKeepPattern
+		case idx < policy.KeepLast:
+			tag.Reason = portainer.RegistryRetentionKeepLast
+		case policy.MaxAgeDays > 0 && now.Sub(time.Unix(tag.Created, 0)) < maxAge:
+			tag.Reason = portainer.RegistryRetentionRecent
+		default:
+			tag.Delete = true
+			tag.Reason = portainer.RegistryRetentionExpired
+		}
+	}
+
+	return nil
+}
+
+// reclaimableSize returns the size of the blobs referenced by the manifests of which every tag is deleted,
+// excluding the blobs also referenced by a manifest that is kept. Tags that could not be deleted are kept.
+func reclaimableSize(tags []portainer.RegistryRetentionTag, manifests map[string]*registry.Manifest) int64 {
+	keptManifests := map[string]bool{}
+	for _, tag := range tags {
+		if !tag.Delete || tag.Error != "" {
+			keptManifests[tag.Digest] = true
+		}
+	}
+
+	keptBlobs := map[string]int64{}
+	deletedBlobs := map[string]int64{}
+	for _, tag := range tags {
+		manifest, ok := manifests[tag.Digest]
+		if !ok {
+			continue
+		}
+
+		if keptManifests[tag.Digest] {
+			collectBlobs(manifest, keptBlobs)
+		} else {
+			collectBlobs(manifest, deletedBlobs)
+		}
+	}
+
+	size := int64(0)
+	for digest, blobSize := range deletedBlobs {
+		if _, ok := keptBlobs[digest]; !ok {
+			size += blobSize
+		}
+	}
+	return size
+}
+
+func collectBlobs(manifest *registry.Manifest, blobs map[string]int64) {
+	if manifest.ConfigDigest != "" {
+		blobs[manifest.ConfigDigest] = manifest.ConfigSize
+	}
+
+	for _, layer := range manifest.Layers {
+		blobs[layer.Digest] = layer.Size
+	}
+
+	for idx := range manifest.Manifests {
+		collectBlobs(&manifest.Manifests[idx], blobs)
+	}
+}
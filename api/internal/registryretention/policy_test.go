@@ -0,0 +1,75 @@
+package registryretention
+
+import (
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_selectTags(t *testing.T) {
+	now := time.Date(2021, 6, 30, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) int64 {
+		return now.Add(-time.Duration(days) * 24 * time.Hour).Unix()
+	}
+
+	tags := []portainer.RegistryRetentionTag{
+		{Tag: "1.0", Created: daysAgo(90)},
+		{Tag: "1.1", Created: daysAgo(60)},
+		{Tag: "dev-a", Created: daysAgo(40)},
+		{Tag: "1.2", Created: daysAgo(20)},
+		{Tag: "dev-b", Created: daysAgo(5)},
+		{Tag: "dev-c", Created: daysAgo(1)},
+	}
+	inUse := map[string]bool{"1.0": true}
+	policy := &portainer.RegistryRetentionPolicy{KeepLast: 2, KeepPattern: `\d+\.\d+`, MaxAgeDays: 30}
+
+	err := selectTags(tags, inUse, policy, now)
+	assert.NoError(t, err)
+
+	reasons := map[string]portainer.RegistryRetentionReason{}
+	deleted := []string{}
+	for _, tag := range tags {
+		reasons[tag.Tag] = tag.Reason
+		if tag.Delete {
+			deleted = append(deleted, tag.Tag)
+		}
+	}
+
+	assert.Equal(t, "dev-c", tags[0].Tag)
+	assert.Equal(t, []string{"dev-a"}, deleted)
+	assert.Equal(t, portainer.RegistryRetentionInUse, reasons["1.0"])
+	assert.Equal(t, portainer.RegistryRetentionKeepPattern, reasons["1.1"])
+	assert.Equal(t, portainer.RegistryRetentionKeepLast, reasons["dev-b"])
+	assert.Equal(t, portainer.RegistryRetentionExpired, reasons["dev-a"])
+}
+
+func Test_reclaimableSize(t *testing.T) {
+	manifests := map[string]*registry.Manifest{
+		"sha256:a": {Digest: "sha256:a", ConfigDigest: "sha256:ca", ConfigSize: 10, Layers: []registry.Layer{{Digest: "sha256:base", Size: 1000}, {Digest: "sha256:la", Size: 100}}},
+		"sha256:b": {Digest: "sha256:b", ConfigDigest: "sha256:cb", ConfigSize: 20, Layers: []registry.Layer{{Digest: "sha256:base", Size: 1000}, {Digest: "sha256:lb", Size: 200}}},
+		"sha256:c": {Digest: "sha256:c", ConfigDigest: "sha256:cc", ConfigSize: 30, Layers: []registry.Layer{{Digest: "sha256:lc", Size: 300}}},
+	}
+
+	tags := []portainer.RegistryRetentionTag{
+		{Tag: "a", Digest: "sha256:a", Delete: true},
+		{Tag: "b", Digest: "sha256:b"},
+		{Tag: "c1", Digest: "sha256:c", Delete: true},
+		{Tag: "c2", Digest: "sha256:c"},
+	}
+
+	assert.Equal(t, int64(110), reclaimableSize(tags, manifests))
+
+	tags[0].Error = "deletion failed"
+	assert.Equal(t, int64(0), reclaimableSize(tags, manifests))
+}
+
+func Test_ValidatePolicies(t *testing.T) {
+	assert.NoError(t, ValidatePolicies([]portainer.RegistryRetentionPolicy{{KeepLast: 5}, {Repository: "app/api", KeepPattern: "v.*"}}))
+	assert.Error(t, ValidatePolicies([]portainer.RegistryRetentionPolicy{{KeepLast: 5}, {MaxAgeDays: 10}}))
+	assert.Error(t, ValidatePolicies([]portainer.RegistryRetentionPolicy{{Repository: "app/api"}}))
+	assert.Error(t, ValidatePolicies([]portainer.RegistryRetentionPolicy{{KeepPattern: "("}}))
+	assert.Error(t, ValidatePolicies([]portainer.RegistryRetentionPolicy{{KeepLast: -1}}))
+}
@@ -0,0 +1,422 @@
+package registryretention
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/endpointutils"
+	"github.com/cloudogu/portainer-ce/api/internal/snapshot"
+	"github.com/cloudogu/portainer-ce/api/registry"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+var errNoRetentionPolicy = errors.New("No retention policy is defined for the registry")
+
+// Service represents a service used to execute the retention policies of the registries. The tags used by
+// the running containers and services of the endpoint snapshots are never deleted. The tags are not deleted
+// either when the images used by an endpoint are unknown, the policies are then applied as a dry run.
+type Service struct {
+	dataStore     portainer.DataStore
+	runInterval   time.Duration
+	refreshSignal chan struct{}
+	mu            sync.Mutex
+}
+
+// imageUsage represents the images used by the running containers and services of a registry
+type imageUsage struct {
+	// tags contains the references in use, in the repository:tag format
+	tags map[string]bool
+	// digests contains the manifest and configuration digests in use
+	digests map[string]bool
+	// unknownEndpoints contains the name of the endpoints whose images in use cannot be determined
+	unknownEndpoints []string
+}
+
+// NewService creates a new instance of a service
+func NewService(runInterval string, dataStore portainer.DataStore) (*Service, error) {
+	interval, err := time.ParseDuration(runInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		dataStore:   dataStore,
+		runInterval: interval,
+	}, nil
+}
+
+// Start will start a background routine to periodically execute the retention policies of the registries with retention enabled
+func (service *Service) Start() {
+	if service.refreshSignal != nil {
+		return
+	}
+
+	service.refreshSignal = make(chan struct{})
+	service.startRunLoop()
+}
+
+func (service *Service) startRunLoop() {
+	ticker := time.NewTicker(service.runInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				err := service.runRegistries()
+				if err != nil {
+					log.Printf("[ERROR] [internal,registryretention] [message: background schedule error (registry retention).] [error: %s]", err)
+				}
+
+			case <-service.refreshSignal:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (service *Service) runRegistries() error {
+	registries, err := service.dataStore.Registry().Registries()
+	if err != nil {
+		return err
+	}
+
+	for _, reg := range registries {
+		if reg.Retention == nil || !reg.Retention.Enabled {
+			continue
+		}
+
+		if reg.ManagementConfiguration == nil {
+			log.Printf("[WARN] [internal,registryretention] [message: retention skipped, the registry has no management configuration] [registry: %s]", reg.Name)
+			continue
+		}
+
+		_, err := service.Run(reg.ID, false)
+		if err != nil {
+			log.Printf("[WARN] [internal,registryretention] [message: unable to execute the retention policies of the registry] [registry: %s] [err: %s]", reg.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Run applies the retention policies of a registry to each of its repositories. When dryRun is true, the
+// report lists the tags that would be deleted without deleting them. The report is persisted in the
+// retention configuration of the registry.
+func (service *Service) Run(registryID portainer.RegistryID, dryRun bool) (*portainer.RegistryRetentionReport, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	reg, err := service.dataStore.Registry().Registry(registryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if reg.Retention == nil || len(reg.Retention.Policies) == 0 {
+		return nil, errNoRetentionPolicy
+	}
+
+	client, err := registry.NewClientForRegistry(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := service.imageUsage(registry.RegistryHost(reg.URL))
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun && len(usage.unknownEndpoints) > 0 {
+		log.Printf("[WARN] [internal,registryretention] [message: the images used by some endpoints are unknown, the retention policies are applied as a dry run] [registry: %s] [endpoints: %s]", reg.Name, strings.Join(usage.unknownEndpoints, ", "))
+		dryRun = true
+	}
+
+	repositories, err := client.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &portainer.RegistryRetentionReport{
+		DryRun:                dryRun,
+		StartedAt:             time.Now().Unix(),
+		Repositories:          make([]portainer.RegistryRetentionRepositoryReport, 0),
+		UnknownUsageEndpoints: usage.unknownEndpoints,
+	}
+
+	for _, repository := range repositories {
+		policy := policyForRepository(reg.Retention.Policies, repository)
+		if policy == nil {
+			continue
+		}
+
+		repositoryReport := applyPolicy(client, repository, policy, usage, dryRun)
+		report.ReclaimableSize += repositoryReport.ReclaimableSize
+		report.Repositories = append(report.Repositories, repositoryReport)
+	}
+
+	report.FinishedAt = time.Now().Unix()
+
+	// the registry is loaded again as it could have been updated while the policies were applied
+	reg, err = service.dataStore.Registry().Registry(registryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if reg.Retention != nil {
+		reg.Retention.LastReport = report
+		err = service.dataStore.Registry().UpdateRegistry(reg.ID, reg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// applyPolicy selects the tags of a repository deleted by a retention policy and deletes them unless dryRun is true.
+// The manifest of a tag is deleted when all the tags referencing it are deleted, otherwise only the tag is deleted.
+func applyPolicy(client *registry.Client, repository string, policy *portainer.RegistryRetentionPolicy, usage *imageUsage, dryRun bool) portainer.RegistryRetentionRepositoryReport {
+	report := portainer.RegistryRetentionRepositoryReport{
+		Name: repository,
+		Tags: make([]portainer.RegistryRetentionTag, 0),
+	}
+
+	manifests, inUse, err := loadTags(client, repository, usage, &report)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	err = selectTags(report.Tags, inUse, policy, time.Now())
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	if !dryRun {
+		deleteTags(client, repository, report.Tags)
+	}
+
+	report.ReclaimableSize = reclaimableSize(report.Tags, manifests)
+	return report
+}
+
+// loadTags retrieves the manifest and the creation date of each tag of a repository
+func loadTags(client *registry.Client, repository string, usage *imageUsage, report *portainer.RegistryRetentionRepositoryReport) (map[string]*registry.Manifest, map[string]bool, error) {
+	tags, err := client.Tags(repository)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifests := make(map[string]*registry.Manifest)
+	created := make(map[string]int64)
+	inUse := make(map[string]bool)
+
+	for _, tag := range tags {
+		digest, err := client.ManifestDigest(repository, tag)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		manifest, ok := manifests[digest]
+		if !ok {
+			manifest, err = client.Manifest(repository, digest)
+			if err != nil {
+				return nil, nil, err
+			}
+			manifests[digest] = manifest
+
+			created[digest], err = manifestCreated(client, repository, manifest)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		inUse[tag] = usage.tags[repository+":"+tag] || usage.usesManifest(manifest)
+
+		report.Tags = append(report.Tags, portainer.RegistryRetentionTag{
+			Tag:     tag,
+			Digest:  digest,
+			Created: created[digest],
+			Size:    manifest.Size,
+		})
+	}
+
+	return manifests, inUse, nil
+}
+
+// manifestCreated returns the creation date of an image. The creation date of the first
+// platform image is used for an image index.
+func manifestCreated(client *registry.Client, repository string, manifest *registry.Manifest) (int64, error) {
+	for manifest.ConfigDigest == "" && len(manifest.Manifests) > 0 {
+		manifest = &manifest.Manifests[0]
+	}
+
+	if manifest.ConfigDigest == "" {
+		return 0, nil
+	}
+
+	created, err := client.ImageCreated(repository, manifest.ConfigDigest)
+	if err != nil {
+		return 0, err
+	}
+
+	return created.Unix(), nil
+}
+
+func deleteTags(client *registry.Client, repository string, tags []portainer.RegistryRetentionTag) {
+	tagsByDigest := make(map[string][]*portainer.RegistryRetentionTag)
+	digests := make([]string, 0)
+	for idx := range tags {
+		digest := tags[idx].Digest
+		if _, ok := tagsByDigest[digest]; !ok {
+			digests = append(digests, digest)
+		}
+		tagsByDigest[digest] = append(tagsByDigest[digest], &tags[idx])
+	}
+
+	for _, digest := range digests {
+		digestTags := tagsByDigest[digest]
+
+		deleteManifest := true
+		for _, tag := range digestTags {
+			deleteManifest = deleteManifest && tag.Delete
+		}
+
+		if deleteManifest {
+			err := client.DeleteManifest(repository, digest)
+			if err != nil {
+				for _, tag := range digestTags {
+					tag.Error = err.Error()
+				}
+			}
+			continue
+		}
+
+		for _, tag := range digestTags {
+			if !tag.Delete {
+				continue
+			}
+
+			err := client.DeleteTag(repository, tag.Tag)
+			if err != nil {
+				tag.Error = err.Error()
+			}
+		}
+	}
+}
+
+// imageUsage returns the images of a registry used by the running containers and services of the endpoint snapshots.
+// The usage of the endpoints that are not Docker endpoints, or that have no snapshot yet, is unknown.
+func (service *Service) imageUsage(registryHost string) (*imageUsage, error) {
+	endpoints, err := service.dataStore.Endpoint().Endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &imageUsage{
+		tags:    make(map[string]bool),
+		digests: make(map[string]bool),
+	}
+
+	for _, endpoint := range endpoints {
+		if !endpointutils.IsDockerEndpoint(&endpoint) || len(endpoint.Snapshots) == 0 || endpoint.Snapshots[0].SnapshotRaw.Containers == nil {
+			usage.unknownEndpoints = append(usage.unknownEndpoints, endpoint.Name)
+			continue
+		}
+
+		for _, endpointSnapshot := range endpoint.Snapshots {
+			err := usage.addSnapshot(registryHost, &endpointSnapshot)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return usage, nil
+}
+
+func (usage *imageUsage) addSnapshot(registryHost string, endpointSnapshot *portainer.DockerSnapshot) error {
+	var containers []types.Container
+	err := snapshot.DecodeSnapshotData(endpointSnapshot.SnapshotRaw.Containers, &containers)
+	if err != nil {
+		return err
+	}
+
+	var images []types.ImageSummary
+	err = snapshot.DecodeSnapshotData(endpointSnapshot.SnapshotRaw.Images, &images)
+	if err != nil {
+		return err
+	}
+
+	var services []swarm.Service
+	err = snapshot.DecodeSnapshotData(endpointSnapshot.SnapshotRaw.Services, &services)
+	if err != nil {
+		return err
+	}
+
+	imagesByID := make(map[string]types.ImageSummary)
+	for _, image := range images {
+		imagesByID[image.ID] = image
+	}
+
+	for _, container := range containers {
+		if container.State != "running" {
+			continue
+		}
+
+		usage.digests[container.ImageID] = true
+		usage.addReference(registryHost, container.Image)
+		for _, repoDigest := range imagesByID[container.ImageID].RepoDigests {
+			usage.addReference(registryHost, repoDigest)
+		}
+	}
+
+	for _, swarmService := range services {
+		if swarmService.Spec.TaskTemplate.ContainerSpec == nil {
+			continue
+		}
+		usage.addReference(registryHost, swarmService.Spec.TaskTemplate.ContainerSpec.Image)
+	}
+
+	return nil
+}
+
+func (usage *imageUsage) addReference(registryHost, image string) {
+	// the Docker engine reports the image identifier when the image was untagged after the container creation
+	if strings.HasPrefix(image, "sha256:") {
+		return
+	}
+
+	ref, err := registry.ParseImageReference(image)
+	if err != nil || registry.RegistryHost(ref.Domain) != registryHost {
+		return
+	}
+
+	if ref.Tag != "" {
+		usage.tags[ref.Repository+":"+ref.Tag] = true
+	}
+
+	if ref.Digest != "" {
+		usage.digests[ref.Digest] = true
+	}
+}
+
+// usesManifest returns true if the manifest, or one of its platform images, is in use
+func (usage *imageUsage) usesManifest(manifest *registry.Manifest) bool {
+	if usage.digests[manifest.Digest] || (manifest.ConfigDigest != "" && usage.digests[manifest.ConfigDigest]) {
+		return true
+	}
+
+	for idx := range manifest.Manifests {
+		if usage.usesManifest(&manifest.Manifests[idx]) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package registryretention
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/cloudogu/portainer-ce/api/registry"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	oldDigest     = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	currentDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// newTestRegistry returns a registry serving the repository app with the tags old and current,
+// the manifest of old being the oldest one
+func newTestRegistry(deleted *[]string) *httptest.Server {
+	tags := map[string]string{"old": oldDigest, "current": currentDigest}
+	configs := map[string]string{oldDigest: "sha256:c1", currentDigest: "sha256:c2"}
+	created := map[string]string{"sha256:c1": "2020-01-01T00:00:00Z", "sha256:c2": "2021-01-01T00:00:00Z"}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/_catalog":
+			fmt.Fprint(w, `{"repositories":["app"]}`)
+		case r.URL.Path == "/v2/app/tags/list":
+			fmt.Fprint(w, `{"name":"app","tags":["old","current"]}`)
+		case strings.HasPrefix(r.URL.Path, "/v2/app/blobs/"):
+			fmt.Fprintf(w, `{"created":"%s"}`, created[strings.TrimPrefix(r.URL.Path, "/v2/app/blobs/")])
+		case strings.HasPrefix(r.URL.Path, "/v2/app/manifests/"):
+			reference := strings.TrimPrefix(r.URL.Path, "/v2/app/manifests/")
+			if digest, ok := tags[reference]; ok {
+				reference = digest
+			}
+
+			if r.Method == http.MethodDelete {
+				*deleted = append(*deleted, reference)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+
+			w.Header().Set("Docker-Content-Digest", reference)
+			fmt.Fprintf(w, `{"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"%s","size":100}}`, configs[reference])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func dockerEndpoint(id portainer.EndpointID, name string, containers []types.Container) *portainer.Endpoint {
+	return &portainer.Endpoint{
+		ID:   id,
+		Name: name,
+		Type: portainer.DockerEnvironment,
+		Snapshots: []portainer.DockerSnapshot{
+			{SnapshotRaw: portainer.DockerSnapshotRaw{Containers: containers}},
+		},
+	}
+}
+
+func Test_Run(t *testing.T) {
+	tests := []struct {
+		name                  string
+		endpoint              *portainer.Endpoint
+		expectedDeleted       []string
+		expectedUnknownUsages []string
+	}{
+		{
+			name:            "the tags that are not in use are deleted when the usage of every endpoint is known",
+			expectedDeleted: []string{oldDigest},
+		},
+		{
+			name:                  "the policies are applied as a dry run with a Kubernetes endpoint",
+			endpoint:              &portainer.Endpoint{ID: 2, Name: "kubernetes", Type: portainer.KubernetesLocalEnvironment},
+			expectedDeleted:       []string{},
+			expectedUnknownUsages: []string{"kubernetes"},
+		},
+		{
+			name:                  "the policies are applied as a dry run with an endpoint without snapshot",
+			endpoint:              &portainer.Endpoint{ID: 2, Name: "unreachable", Type: portainer.AgentOnDockerEnvironment},
+			expectedDeleted:       []string{},
+			expectedUnknownUsages: []string{"unreachable"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, teardown := testhelpers.NewDatastore(t)
+			defer teardown()
+
+			deleted := []string{}
+			server := newTestRegistry(&deleted)
+			defer server.Close()
+
+			assert.NoError(t, store.Registry().CreateRegistry(&portainer.Registry{
+				Name: "registry",
+				URL:  server.URL,
+				Retention: &portainer.RegistryRetention{
+					Enabled:  true,
+					Policies: []portainer.RegistryRetentionPolicy{{KeepLast: 1}},
+				},
+			}))
+
+			// the current tag is kept as the most recent tag, the old tag is not in use
+			assert.NoError(t, store.Endpoint().CreateEndpoint(dockerEndpoint(1, "docker", []types.Container{
+				{Image: registry.RegistryHost(server.URL) + "/app:current", State: "running"},
+			})))
+			if test.endpoint != nil {
+				assert.NoError(t, store.Endpoint().CreateEndpoint(test.endpoint))
+			}
+
+			service, err := NewService("1h", store)
+			assert.NoError(t, err)
+
+			report, err := service.Run(1, false)
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.expectedDeleted, deleted)
+			assert.Equal(t, len(test.expectedUnknownUsages) > 0, report.DryRun)
+			assert.Equal(t, test.expectedUnknownUsages, report.UnknownUsageEndpoints)
+			assert.Len(t, report.Repositories, 1)
+			for _, tag := range report.Repositories[0].Tags {
+				assert.Equal(t, tag.Tag == "old", tag.Delete, tag.Tag)
+			}
+		})
+	}
+}
+
+func Test_imageUsage(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	assert.NoError(t, store.Endpoint().CreateEndpoint(dockerEndpoint(1, "docker", []types.Container{
+		{Image: "registry.example.com/app:1.0", State: "running"},
+		{Image: "registry.example.com/app:0.9", State: "exited"},
+		{Image: "registry.example.com/app@" + oldDigest, State: "running"},
+		{Image: "docker.io/library/nginx:latest", State: "running"},
+	})))
+	assert.NoError(t, store.Endpoint().CreateEndpoint(&portainer.Endpoint{ID: 2, Name: "kubernetes", Type: portainer.AgentOnKubernetesEnvironment}))
+	assert.NoError(t, store.Endpoint().CreateEndpoint(&portainer.Endpoint{ID: 3, Name: "edge", Type: portainer.EdgeAgentOnDockerEnvironment}))
+
+	service, err := NewService("1h", store)
+	assert.NoError(t, err)
+
+	usage, err := service.imageUsage("registry.example.com")
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]bool{"app:1.0": true}, usage.tags)
+	assert.True(t, usage.digests[oldDigest])
+	assert.ElementsMatch(t, []string{"kubernetes", "edge"}, usage.unknownEndpoints)
+}
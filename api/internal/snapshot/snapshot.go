@@ -1,6 +1,7 @@
 package snapshot
 
 import (
+	"encoding/json"
 	"log"
 	"time"
 
@@ -179,3 +180,18 @@ func (service *Service) snapshotEndpoints() error {
 
 	return nil
 }
+
+// DecodeSnapshotData converts the raw snapshot data, which is either the original Docker API
+// object or its generic JSON representation once reloaded from the database
+func DecodeSnapshotData(data interface{}, target interface{}) error {
+	if data == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, target)
+}
@@ -0,0 +1,469 @@
+package templatecatalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/gitfile"
+)
+
+const (
+	// SourceFileName is the name of the file uploaded for a file template source
+	SourceFileName = "source.json"
+	// DefaultSourceIdentifier is the identifier of the cache of the source defined by the templates URL of the settings
+	DefaultSourceIdentifier = "default"
+
+	templatesFileName = "templates.json"
+	cacheFileName     = "cache.json"
+	refreshInterval   = 5 * time.Minute
+	requestTimeout    = 10 * time.Second
+	gitTimeout        = 2 * time.Minute
+	// maxTemplateFileSize is the maximum size of a template file retrieved from a URL
+	maxTemplateFileSize = 10 * 1024 * 1024
+)
+
+var (
+	errNoCachedCopy         = errors.New("No copy of the template source available")
+	errTemplateFileTooLarge = errors.New("The template file exceeds the maximum size of 10MB")
+)
+
+// Service represents a service used to retrieve the templates of the template sources. The last valid
+// copy of each source is kept on disk and refreshed at most every 5 minutes in the background, using
+// conditional requests for the URL sources and the latest commit of the reference for the Git sources.
+type Service struct {
+	fileService portainer.FileService
+	gitService  portainer.GitService
+	httpClient  *http.Client
+	gitTimeout  time.Duration
+	mu          sync.Mutex
+	sourceLocks map[string]*sync.Mutex
+	refreshing  map[string]bool
+}
+
+// NewService creates a new instance of a service
+func NewService(fileService portainer.FileService, gitService portainer.GitService) *Service {
+	return &Service{
+		fileService: fileService,
+		gitService:  gitService,
+		httpClient:  &http.Client{Timeout: requestTimeout},
+		gitTimeout:  gitTimeout,
+		sourceLocks: make(map[string]*sync.Mutex),
+		refreshing:  make(map[string]bool),
+	}
+}
+
+// DefaultSource returns the source defined by the templates URL of the settings. It uses the
+// identifier 0, which is never assigned to the template sources stored inside the database.
+func DefaultSource(settings *portainer.Settings) *portainer.TemplateSource {
+	return &portainer.TemplateSource{
+		Name:    "Default",
+		Type:    portainer.URLTemplateSource,
+		Enabled: settings.TemplatesURL != "",
+		URL:     settings.TemplatesURL,
+	}
+}
+
+// SourceTemplates returns the templates of a source. The cached copy is served and refreshed in the background
+// when it is older than the refresh interval. The source is only retrieved before returning when it was
+// never checked before.
+func (service *Service) SourceTemplates(source *portainer.TemplateSource) ([]portainer.Template, error) {
+	cache, err := service.SourceCache(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache.LastCheck == 0 {
+		service.RefreshSource(source)
+	} else if time.Since(time.Unix(cache.LastCheck, 0)) >= refreshInterval {
+		service.refreshInBackground(source)
+	}
+
+	lock := service.sourceLock(source)
+	lock.Lock()
+	defer lock.Unlock()
+
+	cache, err = service.readCache(source)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path.Join(service.sourcePath(source), templatesFileName))
+	if os.IsNotExist(err) {
+		if cache.LastError != "" {
+			return nil, errors.New(cache.LastError)
+		}
+		return nil, errNoCachedCopy
+	} else if err != nil {
+		return nil, err
+	}
+
+	return ParseTemplates(data)
+}
+
+// RefreshSource refreshes the cached copy of a source and returns the error encountered while retrieving it.
+// The source is retrieved without holding the lock of the source and the retrieved copy is dropped when
+// the location of the source changed in the meantime.
+func (service *Service) RefreshSource(source *portainer.TemplateSource) error {
+	lock := service.sourceLock(source)
+
+	lock.Lock()
+	cache, err := service.readCache(source)
+	lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	fetched := *cache
+	data, fetchErr := service.fetch(source, &fetched)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	currentCache, err := service.loadCache(source)
+	if err != nil {
+		return err
+	}
+
+	if currentCache.Origin != "" && currentCache.Origin != sourceOrigin(source) {
+		return fetchErr
+	}
+
+	currentCache.Origin = sourceOrigin(source)
+	return service.refresh(source, currentCache, &fetched, data, fetchErr)
+}
+
+func (service *Service) refreshInBackground(source *portainer.TemplateSource) {
+	identifier := service.sourceIdentifier(source)
+
+	service.mu.Lock()
+	if service.refreshing[identifier] {
+		service.mu.Unlock()
+		return
+	}
+	service.refreshing[identifier] = true
+	service.mu.Unlock()
+
+	sourceCopy := *source
+	go func() {
+		defer func() {
+			service.mu.Lock()
+			delete(service.refreshing, identifier)
+			service.mu.Unlock()
+		}()
+
+		err := service.RefreshSource(&sourceCopy)
+		if err != nil {
+			log.Printf("[WARN] [internal,templatecatalog] [message: unable to refresh template source] [source: %s] [err: %s]", sourceCopy.Name, err)
+		}
+	}()
+}
+
+// SourceCache returns the state of the cached copy of a source
+func (service *Service) SourceCache(source *portainer.TemplateSource) (*portainer.TemplateSourceCache, error) {
+	lock := service.sourceLock(source)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return service.readCache(source)
+}
+
+// RemoveSourceCache removes the files of a source
+func (service *Service) RemoveSourceCache(source *portainer.TemplateSource) error {
+	lock := service.sourceLock(source)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return service.fileService.RemoveDirectory(service.sourcePath(source))
+}
+
+// ParseTemplates decodes a template file and validates each template against the fields required by its type
+func ParseTemplates(data []byte) ([]portainer.Template, error) {
+	var file struct {
+		Version   string               `json:"version"`
+		Templates []portainer.Template `json:"templates"`
+	}
+
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid template file: %s", err)
+	}
+
+	if file.Version != "2" {
+		return nil, errors.New("Invalid template file: only the version 2 of the template format is supported")
+	}
+
+	for idx, template := range file.Templates {
+		err := validateTemplate(&template)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid template %d (%s): %s", idx, template.Title, err)
+		}
+	}
+
+	return file.Templates, nil
+}
+
+func validateTemplate(template *portainer.Template) error {
+	if template.Title == "" {
+		return errors.New("title is mandatory")
+	}
+
+	switch template.Type {
+	case portainer.ContainerTemplate:
+		if template.Image == "" {
+			return errors.New("image is mandatory for a container template")
+		}
+	case portainer.SwarmStackTemplate, portainer.ComposeStackTemplate:
+		if template.Repository.URL == "" || template.Repository.StackFile == "" {
+			return errors.New("repository url and stackfile are mandatory for a stack template")
+		}
+	case portainer.EdgeStackTemplate:
+		if template.StackFile == "" {
+			return errors.New("stackFile is mandatory for an Edge stack template")
+		}
+	default:
+		return errors.New("unsupported template type")
+	}
+
+	for _, env := range template.Env {
+		if env.Name == "" {
+			return errors.New("env name is mandatory")
+		}
+	}
+
+	return nil
+}
+
+// refresh records the result of the retrieval of a source in its cache and stores the retrieved copy when it is valid
+func (service *Service) refresh(source *portainer.TemplateSource, cache, fetched *portainer.TemplateSourceCache, data []byte, err error) error {
+	cache.LastCheck = time.Now().Unix()
+
+	if err == nil && data != nil {
+		var templates []portainer.Template
+		templates, err = ParseTemplates(data)
+		if err == nil {
+			_, err = service.fileService.StoreTemplateSourceFileFromBytes(service.sourceIdentifier(source), templatesFileName, data)
+		}
+		if err == nil {
+			cache.ETag = fetched.ETag
+			cache.LastModified = fetched.LastModified
+			cache.TemplateCount = len(templates)
+		}
+	}
+
+	cache.LastError = ""
+	if err != nil {
+		cache.LastError = err.Error()
+	} else {
+		cache.LastRefresh = cache.LastCheck
+	}
+
+	writeErr := service.writeCache(source, cache)
+	if err != nil {
+		return err
+	}
+	return writeErr
+}
+
+// fetch returns the content of the template file of a source, or nil when the cached copy is up to date.
+// The validators of the cache are updated with the values of the retrieved copy.
+func (service *Service) fetch(source *portainer.TemplateSource, cache *portainer.TemplateSourceCache) ([]byte, error) {
+	switch source.Type {
+	case portainer.URLTemplateSource:
+		return service.fetchURL(source, cache)
+	case portainer.GitTemplateSource:
+		return service.fetchRepositoryWithTimeout(source, cache)
+	case portainer.FileTemplateSource:
+		return ioutil.ReadFile(path.Join(service.sourcePath(source), SourceFileName))
+	}
+	return nil, errors.New("Unsupported template source type")
+}
+
+func (service *Service) fetchURL(source *portainer.TemplateSource, cache *portainer.TemplateSourceCache) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, source.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if source.Authentication {
+		req.SetBasicAuth(source.Username, source.Password)
+	}
+
+	if cache.LastRefresh != 0 {
+		if cache.ETag != "" {
+			req.Header.Set("If-None-Match", cache.ETag)
+		}
+		if cache.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cache.LastModified)
+		}
+	}
+
+	resp, err := service.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected status code returned by the template source: %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTemplateFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > maxTemplateFileSize {
+		return nil, errTemplateFileTooLarge
+	}
+
+	cache.ETag = resp.Header.Get("ETag")
+	cache.LastModified = resp.Header.Get("Last-Modified")
+
+	return data, nil
+}
+
+// fetchRepositoryWithTimeout retrieves the template file of a Git source and gives up after the Git timeout
+// of the service. The retrieval keeps running in the background until it ends but its result is dropped.
+func (service *Service) fetchRepositoryWithTimeout(source *portainer.TemplateSource, cache *portainer.TemplateSourceCache) ([]byte, error) {
+	type fetchResult struct {
+		data []byte
+		etag string
+		err  error
+	}
+
+	result := make(chan fetchResult, 1)
+	repositoryCache := *cache
+	go func() {
+		data, err := service.fetchRepository(source, &repositoryCache)
+		result <- fetchResult{data: data, etag: repositoryCache.ETag, err: err}
+	}()
+
+	select {
+	case fetched := <-result:
+		cache.ETag = fetched.etag
+		return fetched.data, fetched.err
+	case <-time.After(service.gitTimeout):
+		return nil, fmt.Errorf("Unable to retrieve the template file from the repository within %s", service.gitTimeout)
+	}
+}
+
+func (service *Service) fetchRepository(source *portainer.TemplateSource, cache *portainer.TemplateSourceCache) ([]byte, error) {
+	repository := gitfile.Repository{
+		URL:            source.URL,
+		ReferenceName:  source.ReferenceName,
+		Authentication: source.Authentication,
+		Username:       source.Username,
+		Password:       source.Password,
+	}
+
+	knownCommit := ""
+	if cache.LastRefresh != 0 {
+		knownCommit = cache.ETag
+	}
+
+	commitID, data, err := gitfile.FetchFile(service.gitService, service.fileService, repository, knownCommit, source.FilePath)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	cache.ETag = commitID
+	return data, nil
+}
+
+// readCache returns the state of the cached copy of a source. The cached copy is discarded when it was
+// retrieved from another location, after an update of the source.
+func (service *Service) readCache(source *portainer.TemplateSource) (*portainer.TemplateSourceCache, error) {
+	origin := sourceOrigin(source)
+
+	cache, err := service.loadCache(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache.Origin != origin {
+		err = os.Remove(path.Join(service.sourcePath(source), templatesFileName))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		cache = &portainer.TemplateSourceCache{Origin: origin}
+	}
+
+	return cache, nil
+}
+
+// loadCache returns the state of the cached copy of a source as stored on disk
+func (service *Service) loadCache(source *portainer.TemplateSource) (*portainer.TemplateSourceCache, error) {
+	cache := &portainer.TemplateSourceCache{}
+
+	data, err := ioutil.ReadFile(path.Join(service.sourcePath(source), cacheFileName))
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, cache)
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func sourceOrigin(source *portainer.TemplateSource) string {
+	switch source.Type {
+	case portainer.GitTemplateSource:
+		return source.URL + "#" + source.ReferenceName + ":" + source.FilePath
+	case portainer.FileTemplateSource:
+		return "file"
+	}
+	return source.URL
+}
+
+func (service *Service) writeCache(source *portainer.TemplateSource, cache *portainer.TemplateSourceCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+
+	_, err = service.fileService.StoreTemplateSourceFileFromBytes(service.sourceIdentifier(source), cacheFileName, data)
+	return err
+}
+
+func (service *Service) sourceLock(source *portainer.TemplateSource) *sync.Mutex {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	identifier := service.sourceIdentifier(source)
+	lock, ok := service.sourceLocks[identifier]
+	if !ok {
+		lock = &sync.Mutex{}
+		service.sourceLocks[identifier] = lock
+	}
+	return lock
+}
+
+func (service *Service) sourceIdentifier(source *portainer.TemplateSource) string {
+	if source.ID == 0 {
+		return DefaultSourceIdentifier
+	}
+	return strconv.Itoa(int(source.ID))
+}
+
+func (service *Service) sourcePath(source *portainer.TemplateSource) string {
+	return service.fileService.GetTemplateSourceProjectPath(service.sourceIdentifier(source))
+}
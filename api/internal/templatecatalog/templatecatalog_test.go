@@ -0,0 +1,193 @@
+package templatecatalog
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+const validTemplateFile = `{"version": "2", "templates": [{"type": 1, "title": "Nginx", "image": "nginx:latest"}]}`
+
+func Test_ParseTemplates(t *testing.T) {
+	templates, err := ParseTemplates([]byte(validTemplateFile))
+	assert.NoError(t, err)
+	assert.Len(t, templates, 1)
+
+	_, err = ParseTemplates([]byte(`{"version": "2", "templates": [{"type": 2, "title": "Stack"}]}`))
+	assert.Error(t, err, "stack templates require a repository")
+
+	_, err = ParseTemplates([]byte(`{"version": "2", "templates": [{"type": "container"}]}`))
+	assert.Error(t, err, "fields must match the template structure")
+
+	_, err = ParseTemplates([]byte(`{"version": "1", "templates": []}`))
+	assert.Error(t, err)
+}
+
+func Test_SourceTemplates_CachesAndFallsBack(t *testing.T) {
+	requests := 0
+	unavailable := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(validTemplateFile))
+	}))
+	defer server.Close()
+
+	dataPath, err := ioutil.TempDir("", "templatecatalog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	fileService, err := filesystem.NewService(dataPath, "")
+	assert.NoError(t, err)
+
+	service := NewService(fileService, nil)
+	source := &portainer.TemplateSource{ID: 1, Type: portainer.URLTemplateSource, Enabled: true, URL: server.URL}
+
+	templates, err := service.SourceTemplates(source)
+	assert.NoError(t, err)
+	assert.Len(t, templates, 1)
+
+	templates, err = service.SourceTemplates(source)
+	assert.NoError(t, err)
+	assert.Len(t, templates, 1)
+	assert.Equal(t, 1, requests, "the cached copy is served within the refresh interval")
+
+	err = service.RefreshSource(source)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+
+	cache, err := service.SourceCache(source)
+	assert.NoError(t, err)
+	assert.Equal(t, `"v1"`, cache.ETag)
+	assert.Empty(t, cache.LastError)
+
+	unavailable = true
+	err = service.RefreshSource(source)
+	assert.Error(t, err)
+
+	templates, err = service.SourceTemplates(source)
+	assert.NoError(t, err)
+	assert.Len(t, templates, 1, "the last valid copy is served when the source is unreachable")
+
+	source.URL = server.URL + "/other"
+	cache, err = service.SourceCache(source)
+	assert.NoError(t, err)
+	assert.Zero(t, cache.LastRefresh, "the cached copy is discarded when the source location changes")
+}
+
+func newTestService(t *testing.T, gitService portainer.GitService) (*Service, func()) {
+	dataPath, err := ioutil.TempDir("", "templatecatalog")
+	assert.NoError(t, err)
+
+	fileService, err := filesystem.NewService(dataPath, "")
+	assert.NoError(t, err)
+
+	return NewService(fileService, gitService), func() { os.RemoveAll(dataPath) }
+}
+
+func Test_SourceTemplates_RefreshesInBackground(t *testing.T) {
+	release := make(chan struct{})
+	refreshed := make(chan struct{}, 1)
+	blocking := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if blocking {
+			<-release
+			defer func() { refreshed <- struct{}{} }()
+		}
+		w.Write([]byte(validTemplateFile))
+	}))
+	defer server.Close()
+
+	service, teardown := newTestService(t, nil)
+	defer teardown()
+
+	source := &portainer.TemplateSource{ID: 1, Type: portainer.URLTemplateSource, Enabled: true, URL: server.URL}
+
+	_, err := service.SourceTemplates(source)
+	assert.NoError(t, err)
+
+	cache, err := service.SourceCache(source)
+	assert.NoError(t, err)
+	cache.LastCheck = time.Now().Add(-2 * refreshInterval).Unix()
+	assert.NoError(t, service.writeCache(source, cache))
+
+	blocking = true
+	done := make(chan struct{})
+	go func() {
+		templates, err := service.SourceTemplates(source)
+		assert.NoError(t, err)
+		assert.Len(t, templates, 1)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cached copy must be served while the source is refreshed")
+	}
+
+	close(release)
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the source was not refreshed in the background")
+	}
+}
+
+func Test_RefreshSource_RejectsLargeFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat(" ", maxTemplateFileSize+1)))
+	}))
+	defer server.Close()
+
+	service, teardown := newTestService(t, nil)
+	defer teardown()
+
+	source := &portainer.TemplateSource{ID: 1, Type: portainer.URLTemplateSource, Enabled: true, URL: server.URL}
+	assert.Equal(t, errTemplateFileTooLarge, service.RefreshSource(source))
+}
+
+type blockingGitService struct {
+	portainer.GitService
+	release chan struct{}
+}
+
+func (service blockingGitService) LatestCommitID(repositoryURL, referenceName, username, password string) (string, error) {
+	<-service.release
+	return "", errors.New("repository unavailable")
+}
+
+func Test_RefreshSource_GitTimeout(t *testing.T) {
+	gitService := blockingGitService{release: make(chan struct{})}
+	defer close(gitService.release)
+
+	service, teardown := newTestService(t, gitService)
+	defer teardown()
+	service.gitTimeout = 100 * time.Millisecond
+
+	source := &portainer.TemplateSource{ID: 1, Type: portainer.GitTemplateSource, Enabled: true, URL: "https://example.com/repo.git", FilePath: "templates.json"}
+
+	err := service.RefreshSource(source)
+	assert.Error(t, err)
+
+	cache, err := service.SourceCache(source)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, cache.LastError)
+}
@@ -0,0 +1,45 @@
+package testhelpers
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/cloudogu/portainer-ce/api/bolt"
+	"github.com/cloudogu/portainer-ce/api/filesystem"
+)
+
+// NewDatastore creates an initialized datastore inside a temporary directory.
+// The returned function closes the datastore and removes the directory.
+func NewDatastore(t *testing.T) (*bolt.Store, func()) {
+	dataPath, err := ioutil.TempDir("", "portainer-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileService, err := filesystem.NewService(dataPath, path.Join(dataPath, "fs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := bolt.NewStore(dataPath, fileService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, func() {
+		store.Close()
+		os.RemoveAll(dataPath)
+	}
+}
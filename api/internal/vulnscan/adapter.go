@@ -0,0 +1,244 @@
+package vulnscan
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+)
+
+const (
+	scanRequestMediaType  = "application/vnd.scanner.adapter.scan.request+json; version=1.0"
+	scanResponseMediaType = "application/vnd.scanner.adapter.scan.response+json; version=1.0"
+	vulnReportMediaType   = "application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0"
+	manifestMediaType     = "application/vnd.docker.distribution.manifest.v2+json"
+
+	defaultRequestTimeout = 30 * time.Second
+	defaultPollInterval   = 5 * time.Second
+	defaultScanTimeout    = 10 * time.Minute
+)
+
+var errScanTimeout = errors.New("The scanner did not complete the scan in time")
+
+type (
+	// adapterScanner talks to a scanner exposing the Harbor pluggable scanner adapter API,
+	// such as the Trivy and Clair adapters
+	adapterScanner struct {
+		url          string
+		accessToken  string
+		httpClient   *http.Client
+		pollInterval time.Duration
+		scanTimeout  time.Duration
+	}
+
+	scanRequestPayload struct {
+		Registry struct {
+			URL           string `json:"url"`
+			Authorization string `json:"authorization,omitempty"`
+		} `json:"registry"`
+		Artifact struct {
+			Repository string `json:"repository"`
+			Digest     string `json:"digest"`
+			Tag        string `json:"tag,omitempty"`
+			MimeType   string `json:"mime_type"`
+		} `json:"artifact"`
+	}
+
+	vulnerabilityReport struct {
+		Scanner struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"scanner"`
+		Vulnerabilities []struct {
+			ID          string `json:"id"`
+			Package     string `json:"package"`
+			Version     string `json:"version"`
+			FixVersion  string `json:"fix_version"`
+			Severity    string `json:"severity"`
+			Description string `json:"description"`
+		} `json:"vulnerabilities"`
+	}
+)
+
+func newAdapterScanner(settings *portainer.ImageScanSettings) *adapterScanner {
+	httpClient := &http.Client{
+		Timeout: defaultRequestTimeout,
+		// the adapter answers with a 302 status code and no location while the report is not ready
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	if settings.TLSSkipVerify {
+		httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	return &adapterScanner{
+		url:          strings.TrimSuffix(settings.URL, "/"),
+		accessToken:  settings.AccessToken,
+		httpClient:   httpClient,
+		pollInterval: defaultPollInterval,
+		scanTimeout:  defaultScanTimeout,
+	}
+}
+
+// Scan submits a scan request and waits for the vulnerability report
+func (scanner *adapterScanner) Scan(request *ScanRequest) (*ScanReport, error) {
+	scanID, err := scanner.submit(request)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(scanner.scanTimeout)
+	for {
+		report, retryAfter, err := scanner.report(scanID)
+		if err != nil {
+			return nil, err
+		}
+
+		if report != nil {
+			return report, nil
+		}
+
+		if time.Now().Add(retryAfter).After(deadline) {
+			return nil, errScanTimeout
+		}
+		time.Sleep(retryAfter)
+	}
+}
+
+func (scanner *adapterScanner) submit(request *ScanRequest) (string, error) {
+	var payload scanRequestPayload
+	payload.Registry.URL = request.RegistryURL
+	if request.Credentials != nil {
+		payload.Registry.Authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(request.Credentials.Username+":"+request.Credentials.Password))
+	}
+	payload.Artifact.Repository = request.Repository
+	payload.Artifact.Digest = request.Digest
+	payload.Artifact.Tag = request.Tag
+	payload.Artifact.MimeType = manifestMediaType
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, scanner.url+"/api/v1/scan", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", scanRequestMediaType)
+	req.Header.Set("Accept", scanResponseMediaType)
+
+	resp, err := scanner.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return "", fmt.Errorf("Unable to submit the scan of %s@%s (status: %d)", request.Repository, request.Digest, resp.StatusCode)
+	}
+
+	var content struct {
+		ID string `json:"id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&content)
+	if err != nil {
+		return "", err
+	}
+
+	if content.ID == "" {
+		return "", errors.New("The scanner did not return a scan identifier")
+	}
+
+	return content.ID, nil
+}
+
+// report returns the vulnerability report of a scan, or the delay after which
+// the report must be requested again when the scan is still in progress
+func (scanner *adapterScanner) report(scanID string) (*ScanReport, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v1/scan/%s/report", scanner.url, url.PathEscape(scanID)), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", vulnReportMediaType)
+
+	resp, err := scanner.do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusFound:
+		retryAfter := scanner.pollInterval
+		if seconds, err := strconv.Atoi(resp.Header.Get("Refresh-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, retryAfter, nil
+	case http.StatusOK:
+	default:
+		return nil, 0, fmt.Errorf("Unable to retrieve the scan report (status: %d)", resp.StatusCode)
+	}
+
+	var content vulnerabilityReport
+	err = json.NewDecoder(resp.Body).Decode(&content)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	report := &ScanReport{
+		Scanner:         strings.TrimSpace(content.Scanner.Name + " " + content.Scanner.Version),
+		Vulnerabilities: make([]portainer.ImageVulnerability, 0, len(content.Vulnerabilities)),
+	}
+
+	for _, vulnerability := range content.Vulnerabilities {
+		report.Vulnerabilities = append(report.Vulnerabilities, portainer.ImageVulnerability{
+			ID:          vulnerability.ID,
+			Package:     vulnerability.Package,
+			Version:     vulnerability.Version,
+			FixVersion:  vulnerability.FixVersion,
+			Severity:    parseSeverity(vulnerability.Severity),
+			Description: vulnerability.Description,
+		})
+	}
+
+	return report, 0, nil
+}
+
+func (scanner *adapterScanner) do(req *http.Request) (*http.Response, error) {
+	if scanner.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+scanner.accessToken)
+	}
+
+	return scanner.httpClient.Do(req)
+}
+
+// parseSeverity maps the severities of the adapter API, negligible vulnerabilities are reported as low
+func parseSeverity(severity string) portainer.VulnerabilitySeverity {
+	switch strings.ToLower(severity) {
+	case "negligible", "low":
+		return portainer.VulnerabilitySeverityLow
+	case "medium":
+		return portainer.VulnerabilitySeverityMedium
+	case "high":
+		return portainer.VulnerabilitySeverityHigh
+	case "critical":
+		return portainer.VulnerabilitySeverityCritical
+	}
+
+	return portainer.VulnerabilitySeverityUnknown
+}
@@ -0,0 +1,140 @@
+package vulnscan
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	safeDigest       = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	vulnerableDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	brokenDigest     = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+	blockedDigest    = "sha256:4444444444444444444444444444444444444444444444444444444444444444"
+)
+
+// testScanner reports a critical vulnerability for the vulnerable digest, fails to scan the broken
+// digest and does not complete the scan of the blocked digest until released
+type testScanner struct {
+	mu      sync.Mutex
+	scans   int
+	release chan struct{}
+}
+
+func (scanner *testScanner) Scan(request *ScanRequest) (*ScanReport, error) {
+	scanner.mu.Lock()
+	scanner.scans++
+	scanner.mu.Unlock()
+
+	switch request.Digest {
+	case vulnerableDigest:
+		return &ScanReport{Scanner: "test", Vulnerabilities: []portainer.ImageVulnerability{
+			{ID: "CVE-2021-0001", Severity: portainer.VulnerabilitySeverityCritical},
+		}}, nil
+	case brokenDigest:
+		return nil, errors.New("scanner unavailable")
+	case blockedDigest:
+		<-scanner.release
+	}
+
+	return &ScanReport{Scanner: "test", Vulnerabilities: []portainer.ImageVulnerability{
+		{ID: "CVE-2021-0002", Severity: portainer.VulnerabilitySeverityLow},
+	}}, nil
+}
+
+func newTestService(t *testing.T, deployGate bool) (*Service, *testScanner, portainer.DataStore, func()) {
+	store, teardown := testhelpers.NewDatastore(t)
+
+	settings, err := store.Settings().Settings()
+	assert.NoError(t, err)
+	settings.ImageScanSettings = portainer.ImageScanSettings{
+		Enabled:      true,
+		ScannerType:  portainer.TrivyScanner,
+		DeployGate:   deployGate,
+		GateSeverity: portainer.VulnerabilitySeverityHigh,
+	}
+	assert.NoError(t, store.Settings().UpdateSettings(settings))
+
+	service, err := NewService("1h", store)
+	assert.NoError(t, err)
+
+	scanner := &testScanner{release: make(chan struct{})}
+	service.newScanner = func(settings *portainer.ImageScanSettings) (Scanner, error) {
+		return scanner, nil
+	}
+
+	return service, scanner, store, func() {
+		close(scanner.release)
+		teardown()
+	}
+}
+
+func Test_VerifyImages(t *testing.T) {
+	service, scanner, _, teardown := newTestService(t, true)
+	defer teardown()
+
+	t.Run("the images below the gate severity are pinned by the scanned digest", func(t *testing.T) {
+		pinnedImages, err := service.VerifyImages([]string{"nginx:1.19@" + safeDigest, "nginx:1.19@" + safeDigest})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"nginx:1.19@" + safeDigest: "nginx:1.19@" + safeDigest}, pinnedImages)
+		assert.Equal(t, 1, scanner.scans)
+	})
+
+	t.Run("the deployment is refused when an image exceeds the gate severity", func(t *testing.T) {
+		_, err := service.VerifyImages([]string{"nginx@" + safeDigest, "app@" + vulnerableDigest})
+		assert.IsType(t, &gateError{}, err)
+		assert.Equal(t, []string{"app@" + vulnerableDigest}, err.(*gateError).images)
+	})
+
+	t.Run("the deployment is refused when an image cannot be scanned", func(t *testing.T) {
+		_, err := service.VerifyImages([]string{"nginx@" + safeDigest, "app@" + brokenDigest})
+		assert.EqualError(t, err, "Deployment refused, unable to scan image app@"+brokenDigest+": scanner unavailable")
+	})
+
+	t.Run("the deployment is refused when the scans do not complete in time", func(t *testing.T) {
+		service.gateTimeout = 50 * time.Millisecond
+		_, err := service.VerifyImages([]string{"nginx@" + safeDigest, "app@" + blockedDigest})
+		assert.Equal(t, errGateTimeout, err)
+	})
+}
+
+func Test_VerifyImages_GateDisabled(t *testing.T) {
+	service, scanner, _, teardown := newTestService(t, false)
+	defer teardown()
+
+	pinnedImages, err := service.VerifyImages([]string{"app@" + vulnerableDigest})
+	assert.NoError(t, err)
+	assert.Empty(t, pinnedImages)
+	assert.Zero(t, scanner.scans)
+}
+
+func Test_pruneScans(t *testing.T) {
+	service, _, store, teardown := newTestService(t, false)
+	defer teardown()
+
+	scans := map[string]*portainer.ImageScan{
+		"docker.io/library/nginx@" + safeDigest:     {Image: "nginx:1.19", Digest: safeDigest, ScannedAt: time.Now().Add(-30 * 24 * time.Hour).Unix()},
+		"docker.io/library/app@" + vulnerableDigest: {Image: "app:1.0", Digest: vulnerableDigest, ScannedAt: time.Now().Add(-30 * 24 * time.Hour).Unix()},
+		"docker.io/library/redis@" + brokenDigest:   {Image: "redis:6", Digest: brokenDigest, ScannedAt: time.Now().Unix()},
+	}
+	for key, scan := range scans {
+		assert.NoError(t, store.ImageScan().UpdateImageScan(key, scan))
+	}
+
+	err := service.pruneScans(map[string]bool{"docker.io/library/nginx@" + safeDigest: true})
+	assert.NoError(t, err)
+
+	remainingScans, err := store.ImageScan().ImageScans()
+	assert.NoError(t, err)
+
+	images := []string{}
+	for _, scan := range remainingScans {
+		images = append(images, scan.Image)
+	}
+	assert.ElementsMatch(t, []string{"nginx:1.19", "redis:6"}, images, "only the old scans of the images not in use are removed")
+}
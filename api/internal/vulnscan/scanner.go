@@ -0,0 +1,40 @@
+package vulnscan
+
+import (
+	"fmt"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/registry"
+)
+
+type (
+	// Scanner represents a vulnerability scanner able to scan an image stored in a registry
+	Scanner interface {
+		Scan(request *ScanRequest) (*ScanReport, error)
+	}
+
+	// ScanRequest represents the image digest to scan and the registry serving it
+	ScanRequest struct {
+		RegistryURL string
+		Credentials *registry.Credentials
+		Repository  string
+		Tag         string
+		Digest      string
+	}
+
+	// ScanReport represents the vulnerabilities reported by a scanner
+	ScanReport struct {
+		Scanner         string
+		Vulnerabilities []portainer.ImageVulnerability
+	}
+)
+
+// NewScanner returns the scanner matching the scan settings
+func NewScanner(settings *portainer.ImageScanSettings) (Scanner, error) {
+	switch settings.ScannerType {
+	case portainer.TrivyScanner, portainer.ClairScanner:
+		return newAdapterScanner(settings), nil
+	}
+
+	return nil, fmt.Errorf("Unsupported image scanner type: %s", settings.ScannerType)
+}
@@ -0,0 +1,187 @@
+package vulnscan
+
+import (
+	"sort"
+	"strings"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/registry"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+var severityRanks = map[portainer.VulnerabilitySeverity]int{
+	portainer.VulnerabilitySeverityUnknown:  0,
+	portainer.VulnerabilitySeverityLow:      1,
+	portainer.VulnerabilitySeverityMedium:   2,
+	portainer.VulnerabilitySeverityHigh:     3,
+	portainer.VulnerabilitySeverityCritical: 4,
+}
+
+// ValidSeverity returns true if the severity can be used as a threshold
+func ValidSeverity(severity portainer.VulnerabilitySeverity) bool {
+	rank, ok := severityRanks[severity]
+	return ok && rank > 0
+}
+
+// exceedsSeverity returns true if the summary counts a vulnerability of the threshold severity or higher
+func exceedsSeverity(summary map[portainer.VulnerabilitySeverity]int, threshold portainer.VulnerabilitySeverity) bool {
+	for severity, count := range summary {
+		if count > 0 && severityRanks[severity] >= severityRanks[threshold] {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeVulnerabilities counts the vulnerabilities per severity
+func summarizeVulnerabilities(vulnerabilities []portainer.ImageVulnerability) map[portainer.VulnerabilitySeverity]int {
+	summary := newSummary()
+	for _, vulnerability := range vulnerabilities {
+		summary[vulnerability.Severity]++
+	}
+	return summary
+}
+
+func newSummary() map[portainer.VulnerabilitySeverity]int {
+	summary := make(map[portainer.VulnerabilitySeverity]int, len(severityRanks))
+	for severity := range severityRanks {
+		summary[severity] = 0
+	}
+	return summary
+}
+
+func addSummary(target, summary map[portainer.VulnerabilitySeverity]int) {
+	for severity, count := range summary {
+		target[severity] += count
+	}
+}
+
+// summarizeEndpoint groups the containers and services of an endpoint by image digest and
+// sums up the scans of the digests for the whole endpoint and for each stack. Each digest is
+// counted once, no matter how many containers run it. Locally built images have no digest and are ignored.
+func summarizeEndpoint(endpointID portainer.EndpointID, containers []types.Container, images []types.ImageSummary, services []swarm.Service, scans map[string]portainer.ImageScan) *portainer.EndpointVulnerabilitySummary {
+	imagesByID := make(map[string]types.ImageSummary)
+	for _, image := range images {
+		imagesByID[image.ID] = image
+	}
+
+	summaries := make(map[string]*portainer.ImageVulnerabilitySummary)
+	keys := make([]string, 0)
+
+	addImage := func(image string, ref *registry.ImageReference, stack string) {
+		key := scanKey(ref)
+
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &portainer.ImageVulnerabilitySummary{
+				Image:  image,
+				Digest: ref.Digest,
+				Stacks: make([]string, 0),
+			}
+
+			if scan, ok := scans[key]; ok {
+				summary.ScannedAt = scan.ScannedAt
+				summary.Summary = scan.Summary
+				summary.Error = scan.Error
+			}
+
+			summaries[key] = summary
+			keys = append(keys, key)
+		}
+
+		if stack != "" && !containsString(summary.Stacks, stack) {
+			summary.Stacks = append(summary.Stacks, stack)
+		}
+	}
+
+	for _, container := range containers {
+		stack := container.Labels["com.docker.compose.project"]
+		if stack == "" {
+			stack = container.Labels["com.docker.stack.namespace"]
+		}
+
+		ref := containerImageReference(container, imagesByID[container.ImageID])
+		if ref != nil {
+			addImage(container.Image, ref, stack)
+		}
+	}
+
+	for _, swarmService := range services {
+		if swarmService.Spec.TaskTemplate.ContainerSpec == nil {
+			continue
+		}
+
+		// services deployed with a resolved image are pinned to a digest
+		image := swarmService.Spec.TaskTemplate.ContainerSpec.Image
+		ref, err := registry.ParseImageReference(image)
+		if err != nil || ref.Digest == "" {
+			continue
+		}
+
+		addImage(strings.SplitN(image, "@", 2)[0], ref, swarmService.Spec.Labels["com.docker.stack.namespace"])
+	}
+
+	endpointSummary := &portainer.EndpointVulnerabilitySummary{
+		EndpointID: endpointID,
+		Summary:    newSummary(),
+		Images:     make([]portainer.ImageVulnerabilitySummary, 0, len(keys)),
+		Stacks:     make([]portainer.StackVulnerabilitySummary, 0),
+	}
+
+	stacks := make(map[string]*portainer.StackVulnerabilitySummary)
+	for _, key := range keys {
+		summary := summaries[key]
+		endpointSummary.Images = append(endpointSummary.Images, *summary)
+		addSummary(endpointSummary.Summary, summary.Summary)
+
+		for _, name := range summary.Stacks {
+			stack, ok := stacks[name]
+			if !ok {
+				stack = &portainer.StackVulnerabilitySummary{Name: name, Summary: newSummary(), Images: make([]string, 0)}
+				stacks[name] = stack
+			}
+			stack.Images = append(stack.Images, summary.Image)
+			addSummary(stack.Summary, summary.Summary)
+		}
+	}
+
+	for _, stack := range stacks {
+		endpointSummary.Stacks = append(endpointSummary.Stacks, *stack)
+	}
+	sort.Slice(endpointSummary.Stacks, func(i, j int) bool {
+		return endpointSummary.Stacks[i].Name < endpointSummary.Stacks[j].Name
+	})
+
+	return endpointSummary
+}
+
+// containerImageReference returns the reference of the digest run by a container, the repository
+// digest matching the image of the container is preferred when the image was pushed to several repositories
+func containerImageReference(container types.Container, image types.ImageSummary) *registry.ImageReference {
+	var ref *registry.ImageReference
+	for _, repoDigest := range image.RepoDigests {
+		digestRef, err := registry.ParseImageReference(repoDigest)
+		if err != nil || digestRef.Digest == "" {
+			continue
+		}
+
+		if digestRef.SameRepository(container.Image) {
+			return digestRef
+		}
+
+		if ref == nil {
+			ref = digestRef
+		}
+	}
+	return ref
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
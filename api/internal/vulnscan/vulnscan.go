@@ -0,0 +1,461 @@
+package vulnscan
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	bolterrors "github.com/cloudogu/portainer-ce/api/bolt/errors"
+	"github.com/cloudogu/portainer-ce/api/internal/snapshot"
+	"github.com/cloudogu/portainer-ce/api/registry"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+const (
+	// defaultGateTimeout bounds the time spent by the deploy gate to scan the images of a deployment
+	defaultGateTimeout = 5 * time.Minute
+	// maxConcurrentGateScans is the number of images of a deployment scanned at the same time by the deploy gate
+	maxConcurrentGateScans = 4
+	// scanRetention is the age after which the scans of the images that are no longer found on the endpoints are removed
+	scanRetention = 7 * 24 * time.Hour
+)
+
+var (
+	errScanDisabled = errors.New("Image vulnerability scanning is not enabled")
+	errNoSnapshot   = errors.New("No snapshot available for the endpoint")
+	errGateTimeout  = errors.New("Deployment refused, the scan of the images did not complete in time")
+)
+
+// gateError is returned when the images of a deployment have vulnerabilities above the severity threshold
+type gateError struct {
+	severity portainer.VulnerabilitySeverity
+	images   []string
+}
+
+func (err *gateError) Error() string {
+	return fmt.Sprintf("Deployment refused, the following images have vulnerabilities of severity %s or higher: %s", err.severity, strings.Join(err.images, ", "))
+}
+
+// Service represents a service used to scan the images of the endpoint snapshots for vulnerabilities.
+// Scans are kept per image digest and renewed once older than the scan interval.
+type Service struct {
+	dataStore     portainer.DataStore
+	scanInterval  time.Duration
+	gateTimeout   time.Duration
+	newScanner    func(settings *portainer.ImageScanSettings) (Scanner, error)
+	refreshSignal chan struct{}
+}
+
+type gateResult struct {
+	image       string
+	pinnedImage string
+	scan        *portainer.ImageScan
+	err         error
+}
+
+// NewService creates a new instance of a service
+func NewService(scanInterval string, dataStore portainer.DataStore) (*Service, error) {
+	interval, err := time.ParseDuration(scanInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		dataStore:    dataStore,
+		scanInterval: interval,
+		gateTimeout:  defaultGateTimeout,
+		newScanner:   NewScanner,
+	}, nil
+}
+
+// Start will start a background routine to periodically scan the images of all the Docker endpoints
+func (service *Service) Start() {
+	if service.refreshSignal != nil {
+		return
+	}
+
+	service.refreshSignal = make(chan struct{})
+	service.startScanLoop()
+}
+
+func (service *Service) startScanLoop() {
+	ticker := time.NewTicker(service.scanInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				err := service.scanEndpoints()
+				if err != nil {
+					log.Printf("[ERROR] [internal,vulnscan] [message: background schedule error (image vulnerability scan).] [error: %s]", err)
+				}
+
+			case <-service.refreshSignal:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// scanEndpoints scans the images found in the latest snapshot of each Docker endpoint
+// that were not scanned yet or whose scan is older than the scan interval. The scans of
+// the images that are no longer found on the endpoints are then pruned.
+func (service *Service) scanEndpoints() error {
+	scanner, err := service.scanner()
+	if err == errScanDisabled {
+		return service.pruneScans(map[string]bool{})
+	} else if err != nil {
+		return err
+	}
+
+	endpoints, err := service.dataStore.Endpoint().Endpoints()
+	if err != nil {
+		return err
+	}
+
+	scanned := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		if len(endpoint.Snapshots) == 0 {
+			continue
+		}
+
+		var images []types.ImageSummary
+		err := snapshot.DecodeSnapshotData(endpoint.Snapshots[0].SnapshotRaw.Images, &images)
+		if err != nil {
+			log.Printf("[WARN] [internal,vulnscan] [message: unable to decode endpoint images] [endpoint: %s] [err: %s]", endpoint.Name, err)
+			continue
+		}
+
+		for _, image := range images {
+			for _, repoDigest := range image.RepoDigests {
+				ref, err := registry.ParseImageReference(repoDigest)
+				if err != nil || ref.Digest == "" {
+					continue
+				}
+
+				key := scanKey(ref)
+				if scanned[key] {
+					continue
+				}
+				scanned[key] = true
+
+				ref.Tag = repositoryTag(ref, image.RepoTags)
+				_, err = service.scan(scanner, ref, service.scanInterval)
+				if err != nil {
+					log.Printf("[WARN] [internal,vulnscan] [message: unable to scan image] [image: %s] [err: %s]", repoDigest, err)
+				}
+			}
+		}
+	}
+
+	return service.pruneScans(scanned)
+}
+
+// pruneScans removes the scans older than the scan retention whose image digest is not in use
+func (service *Service) pruneScans(inUse map[string]bool) error {
+	scans, err := service.dataStore.ImageScan().ImageScans()
+	if err != nil {
+		return err
+	}
+
+	for _, scan := range scans {
+		if time.Since(time.Unix(scan.ScannedAt, 0)) < scanRetention {
+			continue
+		}
+
+		key, err := imageScanKey(&scan)
+		if err != nil || inUse[key] {
+			continue
+		}
+
+		err = service.dataStore.ImageScan().DeleteImageScan(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ScanImage scans an image for vulnerabilities. Images referenced by tag are resolved to the digest
+// currently pointed by the tag inside the registry. The previous scan of the digest is returned
+// unless it is older than the scan interval, failed or force is specified.
+func (service *Service) ScanImage(image string, force bool) (*portainer.ImageScan, error) {
+	scanner, err := service.scanner()
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := service.resolve(image)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAge := service.scanInterval
+	if force {
+		maxAge = 0
+	}
+
+	return service.scan(scanner, ref, maxAge)
+}
+
+// VerifyImages implements the deploy gate. When it is enabled, an error is returned if one of the images
+// has a vulnerability of the gate severity or higher, or if one of the images cannot be scanned in time.
+// The returned map associates each scanned image to its reference pinned by the scanned digest, so that
+// the deployed images are the ones that were scanned.
+func (service *Service) VerifyImages(images []string) (map[string]string, error) {
+	settings, err := service.dataStore.Settings().Settings()
+	if err != nil {
+		return nil, err
+	}
+
+	if !settings.ImageScanSettings.Enabled || !settings.ImageScanSettings.DeployGate {
+		return map[string]string{}, nil
+	}
+
+	scanner, err := service.newScanner(&settings.ImageScanSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	uniqueImages := make([]string, 0, len(images))
+	verified := make(map[string]bool)
+	for _, image := range images {
+		if !verified[image] {
+			verified[image] = true
+			uniqueImages = append(uniqueImages, image)
+		}
+	}
+
+	// the images are scanned concurrently, the scans still running after the gate timeout are
+	// left to complete in the background so that their result is available to the next deployment
+	results := make(chan gateResult, len(uniqueImages))
+	slots := make(chan struct{}, maxConcurrentGateScans)
+	for _, image := range uniqueImages {
+		go func(image string) {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results <- service.gateScan(scanner, image)
+		}(image)
+	}
+
+	timeout := time.NewTimer(service.gateTimeout)
+	defer timeout.Stop()
+
+	pinnedImages := make(map[string]string)
+	blocked := make([]string, 0)
+	for range uniqueImages {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				return nil, result.err
+			}
+
+			if exceedsSeverity(result.scan.Summary, settings.ImageScanSettings.GateSeverity) {
+				blocked = append(blocked, result.image)
+			}
+			pinnedImages[result.image] = result.pinnedImage
+		case <-timeout.C:
+			return nil, errGateTimeout
+		}
+	}
+
+	if len(blocked) > 0 {
+		sort.Strings(blocked)
+		return nil, &gateError{severity: settings.ImageScanSettings.GateSeverity, images: blocked}
+	}
+
+	return pinnedImages, nil
+}
+
+// gateScan resolves an image to a digest and returns its scan. Scan failures are returned as errors.
+func (service *Service) gateScan(scanner Scanner, image string) gateResult {
+	result := gateResult{image: image}
+
+	ref, err := service.resolve(image)
+	if err != nil {
+		result.err = fmt.Errorf("Deployment refused, unable to resolve image %s: %s", image, err)
+		return result
+	}
+
+	result.scan, err = service.scan(scanner, ref, service.scanInterval)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	if result.scan.Error != "" {
+		result.err = fmt.Errorf("Deployment refused, unable to scan image %s: %s", image, result.scan.Error)
+		return result
+	}
+
+	// the tag is kept for readability
+	result.pinnedImage = strings.SplitN(image, "@", 2)[0] + "@" + ref.Digest
+	return result
+}
+
+// EndpointSummary summarizes the scans of the images running in the containers and services
+// of the latest endpoint snapshot. Images that were not scanned yet have no summary.
+func (service *Service) EndpointSummary(endpoint *portainer.Endpoint) (*portainer.EndpointVulnerabilitySummary, error) {
+	if len(endpoint.Snapshots) == 0 {
+		return nil, errNoSnapshot
+	}
+	endpointSnapshot := endpoint.Snapshots[0]
+
+	var containers []types.Container
+	err := snapshot.DecodeSnapshotData(endpointSnapshot.SnapshotRaw.Containers, &containers)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []types.ImageSummary
+	err = snapshot.DecodeSnapshotData(endpointSnapshot.SnapshotRaw.Images, &images)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []swarm.Service
+	err = snapshot.DecodeSnapshotData(endpointSnapshot.SnapshotRaw.Services, &services)
+	if err != nil {
+		return nil, err
+	}
+
+	scans, err := service.dataStore.ImageScan().ImageScans()
+	if err != nil {
+		return nil, err
+	}
+
+	scansByKey := make(map[string]portainer.ImageScan)
+	for _, scan := range scans {
+		key, err := imageScanKey(&scan)
+		if err != nil {
+			continue
+		}
+		scansByKey[key] = scan
+	}
+
+	return summarizeEndpoint(endpoint.ID, containers, images, services, scansByKey), nil
+}
+
+func (service *Service) scanner() (Scanner, error) {
+	settings, err := service.dataStore.Settings().Settings()
+	if err != nil {
+		return nil, err
+	}
+
+	if !settings.ImageScanSettings.Enabled {
+		return nil, errScanDisabled
+	}
+
+	return service.newScanner(&settings.ImageScanSettings)
+}
+
+// resolve parses an image reference and resolves its tag to a digest when no digest is specified
+func (service *Service) resolve(image string) (*registry.ImageReference, error) {
+	ref, err := registry.ParseImageReference(image)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.Digest != "" {
+		return ref, nil
+	}
+
+	client, err := registry.NewClientForDomain(service.dataStore, ref.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	ref.Digest, err = client.ManifestDigest(ref.Repository, ref.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return ref, nil
+}
+
+// scan returns the stored scan of the image digest when it succeeded less than maxAge ago,
+// the digest is scanned again otherwise. Scan failures are stored in the scan result.
+func (service *Service) scan(scanner Scanner, ref *registry.ImageReference, maxAge time.Duration) (*portainer.ImageScan, error) {
+	key := scanKey(ref)
+
+	existingScan, err := service.dataStore.ImageScan().ImageScan(key)
+	if err != nil && err != bolterrors.ErrObjectNotFound {
+		return nil, err
+	}
+
+	if existingScan != nil && existingScan.Error == "" && time.Since(time.Unix(existingScan.ScannedAt, 0)) < maxAge {
+		return existingScan, nil
+	}
+
+	credentials, err := registry.FindCredentials(service.dataStore, ref.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	image := ref.Name()
+	if ref.Tag != "" {
+		image += ":" + ref.Tag
+	}
+
+	imageScan := &portainer.ImageScan{
+		Image:     image,
+		Digest:    ref.Digest,
+		ScannedAt: time.Now().Unix(),
+	}
+
+	report, err := scanner.Scan(&ScanRequest{
+		RegistryURL: registry.RegistryURL(ref.Domain),
+		Credentials: credentials,
+		Repository:  ref.Repository,
+		Tag:         ref.Tag,
+		Digest:      ref.Digest,
+	})
+	if err != nil {
+		imageScan.Error = err.Error()
+	} else {
+		imageScan.Scanner = report.Scanner
+		imageScan.Vulnerabilities = report.Vulnerabilities
+		imageScan.Summary = summarizeVulnerabilities(report.Vulnerabilities)
+	}
+
+	err = service.dataStore.ImageScan().UpdateImageScan(key, imageScan)
+	if err != nil {
+		return nil, err
+	}
+
+	return imageScan, nil
+}
+
+// scanKey identifies the scan of an image digest
+func scanKey(ref *registry.ImageReference) string {
+	return ref.Name() + "@" + ref.Digest
+}
+
+// imageScanKey returns the key under which a scan is stored
+func imageScanKey(scan *portainer.ImageScan) (string, error) {
+	ref, err := registry.ParseImageReference(scan.Image)
+	if err != nil {
+		return "", err
+	}
+	ref.Digest = scan.Digest
+
+	return scanKey(ref), nil
+}
+
+// repositoryTag returns the first tag of the image matching the repository of the reference
+func repositoryTag(ref *registry.ImageReference, repoTags []string) string {
+	for _, repoTag := range repoTags {
+		tagRef, err := registry.ParseImageReference(repoTag)
+		if err == nil && tagRef.Name() == ref.Name() {
+			return tagRef.Tag
+		}
+	}
+
+	return ""
+}
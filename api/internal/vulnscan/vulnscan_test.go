@@ -0,0 +1,154 @@
+package vulnscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/registry"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+const nginxDigest = "sha256:0b970013351304af46f322da1263516b188318682b2ab1091862497591189ff1"
+
+// newStubAdapter returns a server implementing the scanner adapter API. The report of
+// a scan is returned after pendingPolls requests answered with a 302 status code.
+func newStubAdapter(t *testing.T, pendingPolls int) *httptest.Server {
+	polls := 0
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/scan":
+			var payload scanRequestPayload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			assert.Equal(t, "https://registry-1.docker.io", payload.Registry.URL)
+			assert.Equal(t, "Basic dXNlcjpwYXNz", payload.Registry.Authorization)
+			assert.Equal(t, "library/nginx", payload.Artifact.Repository)
+			assert.Equal(t, nginxDigest, payload.Artifact.Digest)
+
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"id":"scan-1"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/scan/scan-1/report":
+			assert.Equal(t, vulnReportMediaType, r.Header.Get("Accept"))
+
+			if polls < pendingPolls {
+				polls++
+				w.WriteHeader(http.StatusFound)
+				return
+			}
+
+			fmt.Fprint(w, `{
+				"scanner": {"name": "Trivy", "version": "0.16.0"},
+				"severity": "Critical",
+				"vulnerabilities": [
+					{"id": "CVE-2021-0001", "package": "openssl", "version": "1.1.1d", "fix_version": "1.1.1k", "severity": "Critical"},
+					{"id": "CVE-2021-0002", "package": "libxml2", "version": "2.9.10", "severity": "Negligible"},
+					{"id": "CVE-2021-0003", "package": "zlib", "version": "1.2.11", "severity": "High"}
+				]
+			}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func Test_adapterScanner_Scan(t *testing.T) {
+	server := newStubAdapter(t, 2)
+	defer server.Close()
+
+	scanner := newAdapterScanner(&portainer.ImageScanSettings{ScannerType: portainer.TrivyScanner, URL: server.URL + "/", AccessToken: "token"})
+	scanner.pollInterval = 10 * time.Millisecond
+
+	report, err := scanner.Scan(&ScanRequest{
+		RegistryURL: registry.RegistryURL("docker.io"),
+		Credentials: &registry.Credentials{Username: "user", Password: "pass"},
+		Repository:  "library/nginx",
+		Tag:         "1.19",
+		Digest:      nginxDigest,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Trivy 0.16.0", report.Scanner)
+	assert.Len(t, report.Vulnerabilities, 3)
+	assert.Equal(t, portainer.ImageVulnerability{ID: "CVE-2021-0001", Package: "openssl", Version: "1.1.1d", FixVersion: "1.1.1k", Severity: portainer.VulnerabilitySeverityCritical}, report.Vulnerabilities[0])
+
+	summary := summarizeVulnerabilities(report.Vulnerabilities)
+	assert.Equal(t, 1, summary[portainer.VulnerabilitySeverityCritical])
+	assert.Equal(t, 1, summary[portainer.VulnerabilitySeverityHigh])
+	assert.Equal(t, 1, summary[portainer.VulnerabilitySeverityLow])
+
+	assert.True(t, exceedsSeverity(summary, portainer.VulnerabilitySeverityCritical))
+	summary[portainer.VulnerabilitySeverityCritical] = 0
+	assert.True(t, exceedsSeverity(summary, portainer.VulnerabilitySeverityHigh))
+	assert.False(t, exceedsSeverity(summary, portainer.VulnerabilitySeverityCritical))
+}
+
+func Test_adapterScanner_ScanTimeout(t *testing.T) {
+	server := newStubAdapter(t, 1000)
+	defer server.Close()
+
+	scanner := newAdapterScanner(&portainer.ImageScanSettings{ScannerType: portainer.ClairScanner, URL: server.URL, AccessToken: "token"})
+	scanner.pollInterval = 10 * time.Millisecond
+	scanner.scanTimeout = 50 * time.Millisecond
+
+	_, err := scanner.Scan(&ScanRequest{
+		RegistryURL: registry.RegistryURL("docker.io"),
+		Credentials: &registry.Credentials{Username: "user", Password: "pass"},
+		Repository:  "library/nginx",
+		Digest:      nginxDigest,
+	})
+	assert.Equal(t, errScanTimeout, err)
+}
+
+func Test_summarizeEndpoint(t *testing.T) {
+	appDigest := "sha256:1b970013351304af46f322da1263516b188318682b2ab1091862497591189ff1"
+
+	containers := []types.Container{
+		{Image: "nginx:1.19", ImageID: "sha256:nginx", Labels: map[string]string{"com.docker.compose.project": "web"}},
+		{Image: "nginx:1.19", ImageID: "sha256:nginx", Labels: map[string]string{"com.docker.compose.project": "web"}},
+		{Image: "nginx:1.19", ImageID: "sha256:nginx", Labels: map[string]string{"com.docker.compose.project": "proxy"}},
+		{Image: "local/build", ImageID: "sha256:local"},
+	}
+	images := []types.ImageSummary{
+		{ID: "sha256:nginx", RepoDigests: []string{"mirror.example.com/nginx@" + appDigest, "nginx@" + nginxDigest}},
+		{ID: "sha256:local"},
+	}
+	services := []swarm.Service{
+		{Spec: swarm.ServiceSpec{
+			Annotations:  swarm.Annotations{Labels: map[string]string{"com.docker.stack.namespace": "app"}},
+			TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{Image: "registry.example.com/app:2@" + appDigest}},
+		}},
+	}
+	scans := map[string]portainer.ImageScan{
+		"docker.io/library/nginx@" + nginxDigest: {
+			ScannedAt: 10,
+			Summary:   map[portainer.VulnerabilitySeverity]int{portainer.VulnerabilitySeverityHigh: 2, portainer.VulnerabilitySeverityLow: 1},
+		},
+	}
+
+	summary := summarizeEndpoint(1, containers, images, services, scans)
+
+	assert.Len(t, summary.Images, 2)
+	assert.Equal(t, "nginx:1.19", summary.Images[0].Image)
+	assert.Equal(t, nginxDigest, summary.Images[0].Digest)
+	assert.Equal(t, []string{"web", "proxy"}, summary.Images[0].Stacks)
+	assert.Equal(t, "registry.example.com/app:2", summary.Images[1].Image)
+	assert.Nil(t, summary.Images[1].Summary)
+
+	assert.Equal(t, 2, summary.Summary[portainer.VulnerabilitySeverityHigh])
+	assert.Equal(t, 1, summary.Summary[portainer.VulnerabilitySeverityLow])
+
+	assert.Len(t, summary.Stacks, 3)
+	assert.Equal(t, "app", summary.Stacks[0].Name)
+	assert.Equal(t, 0, summary.Stacks[0].Summary[portainer.VulnerabilitySeverityHigh])
+	assert.Equal(t, "proxy", summary.Stacks[1].Name)
+	assert.Equal(t, 2, summary.Stacks[1].Summary[portainer.VulnerabilitySeverityHigh])
+	assert.Equal(t, []string{"nginx:1.19"}, summary.Stacks[2].Images)
+}
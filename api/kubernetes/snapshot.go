@@ -1,20 +1,66 @@
 package kubernetes
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/cloudogu/portainer-ce/api/kubernetes/cli"
 
 	portainer "github.com/cloudogu/portainer-ce/api"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	// snapshotTimeBudget is the maximum amount of time spent collecting a snapshot.
+	// Collectors that are still running when the budget is exhausted are discarded
+	// and the snapshot is flagged as partial.
+	snapshotTimeBudget = 30 * time.Second
+	// snapshotPageSize is the number of objects retrieved per list request
+	snapshotPageSize = 500
+	// snapshotMaxFailingPods is the maximum number of failing pods stored in a snapshot
+	snapshotMaxFailingPods = 20
+	// snapshotMaxConcurrentNodeQueries is the maximum number of kubelet summaries queried concurrently
+	snapshotMaxConcurrentNodeQueries = 5
+)
+
+var errSnapshotTimeBudgetExceeded = errors.New("snapshot time budget exceeded")
+
+// failingContainerReasons are the container waiting/terminated reasons used to flag a pod as failing
+var failingContainerReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+	"OOMKilled":                  true,
+	"Error":                      true,
+}
+
 type Snapshotter struct {
 	clientFactory *cli.ClientFactory
 }
 
+// snapshotCollector retrieves a subset of the snapshot information. It returns a function
+// that applies the collected data to the snapshot so that collectors can run concurrently
+// without sharing the snapshot.
+type snapshotCollector struct {
+	name    string
+	collect func(cli *kubernetes.Clientset, timeout int64) (func(snapshot *portainer.KubernetesSnapshot), error)
+}
+
+type snapshotCollectorResult struct {
+	name  string
+	apply func(snapshot *portainer.KubernetesSnapshot)
+	err   error
+}
+
 // NewSnapshotter returns a new Snapshotter instance
 func NewSnapshotter(clientFactory *cli.ClientFactory) *Snapshotter {
 	return &Snapshotter{
@@ -38,46 +84,406 @@ func snapshot(cli *kubernetes.Clientset, endpoint *portainer.Endpoint) (*portain
 		return nil, res.Error()
 	}
 
-	snapshot := &portainer.KubernetesSnapshot{}
+	snapshot := &portainer.KubernetesSnapshot{
+		PodsByPhase: map[string]int{},
+		FailingPods: []portainer.KubernetesFailingPod{},
+		Nodes:       []portainer.KubernetesNodeStatus{},
+	}
 
-	err := snapshotVersion(snapshot, cli)
-	if err != nil {
-		log.Printf("[WARN] [kubernetes,snapshot] [message: unable to snapshot cluster version] [endpoint: %s] [err: %s]", endpoint.Name, err)
+	collectors := []snapshotCollector{
+		{"cluster version", snapshotVersion},
+		{"cluster nodes", snapshotNodes},
+		{"namespaces", snapshotNamespaces},
+		{"workloads", snapshotWorkloads},
+		{"pods", snapshotPods},
+		{"persistent volume claims", snapshotVolumeClaims},
+		{"persistent volume claims usage", snapshotVolumeClaimsUsage},
 	}
 
-	err = snapshotNodes(snapshot, cli)
-	if err != nil {
-		log.Printf("[WARN] [kubernetes,snapshot] [message: unable to snapshot cluster nodes] [endpoint: %s] [err: %s]", endpoint.Name, err)
+	deadline := time.Now().Add(snapshotTimeBudget)
+	timeout := int64(snapshotTimeBudget.Seconds())
+
+	results := make(chan snapshotCollectorResult, len(collectors))
+	for _, collector := range collectors {
+		go func(collector snapshotCollector) {
+			apply, err := collector.collect(cli, timeout)
+			results <- snapshotCollectorResult{name: collector.name, apply: apply, err: err}
+		}(collector)
+	}
+
+	budget := time.NewTimer(time.Until(deadline))
+	defer budget.Stop()
+
+collect:
+	for pending := len(collectors); pending > 0; pending-- {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				log.Printf("[WARN] [kubernetes,snapshot] [message: unable to snapshot %s] [endpoint: %s] [err: %s]", result.name, endpoint.Name, result.err)
+				snapshot.Partial = true
+				continue
+			}
+			result.apply(snapshot)
+		case <-budget.C:
+			log.Printf("[WARN] [kubernetes,snapshot] [message: unable to snapshot the whole cluster] [endpoint: %s] [err: %s]", endpoint.Name, errSnapshotTimeBudgetExceeded)
+			snapshot.Partial = true
+			break collect
+		}
 	}
 
 	snapshot.Time = time.Now().Unix()
 	return snapshot, nil
 }
 
-func snapshotVersion(snapshot *portainer.KubernetesSnapshot, cli *kubernetes.Clientset) error {
+func snapshotVersion(cli *kubernetes.Clientset, timeout int64) (func(snapshot *portainer.KubernetesSnapshot), error) {
 	versionInfo, err := cli.ServerVersion()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	snapshot.KubernetesVersion = versionInfo.GitVersion
-	return nil
+	return func(snapshot *portainer.KubernetesSnapshot) {
+		snapshot.KubernetesVersion = versionInfo.GitVersion
+	}, nil
 }
 
-func snapshotNodes(snapshot *portainer.KubernetesSnapshot, cli *kubernetes.Clientset) error {
-	nodeList, err := cli.CoreV1().Nodes().List(metav1.ListOptions{})
+func snapshotNodes(cli *kubernetes.Clientset, timeout int64) (func(snapshot *portainer.KubernetesSnapshot), error) {
+	nodeList, err := cli.CoreV1().Nodes().List(metav1.ListOptions{TimeoutSeconds: &timeout})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var totalCPUs, totalMemory int64
+	readyNodes := 0
+	nodes := make([]portainer.KubernetesNodeStatus, 0, len(nodeList.Items))
 	for _, node := range nodeList.Items {
 		totalCPUs += node.Status.Capacity.Cpu().Value()
 		totalMemory += node.Status.Capacity.Memory().Value()
+
+		status := nodeStatus(&node)
+		if status.Ready {
+			readyNodes++
+		}
+		nodes = append(nodes, status)
+	}
+
+	return func(snapshot *portainer.KubernetesSnapshot) {
+		snapshot.TotalCPU = totalCPUs
+		snapshot.TotalMemory = totalMemory
+		snapshot.NodeCount = len(nodeList.Items)
+		snapshot.ReadyNodeCount = readyNodes
+		snapshot.Nodes = nodes
+	}, nil
+}
+
+func nodeStatus(node *v1.Node) portainer.KubernetesNodeStatus {
+	status := portainer.KubernetesNodeStatus{Name: node.Name}
+
+	for _, condition := range node.Status.Conditions {
+		active := condition.Status == v1.ConditionTrue
+
+		switch condition.Type {
+		case v1.NodeReady:
+			status.Ready = active
+		case v1.NodeMemoryPressure:
+			status.MemoryPressure = active
+		case v1.NodeDiskPressure:
+			status.DiskPressure = active
+		case v1.NodePIDPressure:
+			status.PIDPressure = active
+		case v1.NodeNetworkUnavailable:
+			status.NetworkUnavailable = active
+		}
+	}
+
+	return status
+}
+
+func snapshotNamespaces(cli *kubernetes.Clientset, timeout int64) (func(snapshot *portainer.KubernetesSnapshot), error) {
+	count := 0
+	err := listAllPages(timeout, func(options metav1.ListOptions) (metav1.ListInterface, error) {
+		namespaces, err := cli.CoreV1().Namespaces().List(options)
+		if err != nil {
+			return nil, err
+		}
+		count += len(namespaces.Items)
+		return namespaces, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(snapshot *portainer.KubernetesSnapshot) {
+		snapshot.NamespaceCount = count
+	}, nil
+}
+
+func snapshotWorkloads(cli *kubernetes.Clientset, timeout int64) (func(snapshot *portainer.KubernetesSnapshot), error) {
+	deployments, statefulSets, daemonSets := 0, 0, 0
+
+	err := listAllPages(timeout, func(options metav1.ListOptions) (metav1.ListInterface, error) {
+		list, err := cli.AppsV1().Deployments("").List(options)
+		if err != nil {
+			return nil, err
+		}
+		deployments += len(list.Items)
+		return list, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	snapshot.TotalCPU = totalCPUs
-	snapshot.TotalMemory = totalMemory
-	snapshot.NodeCount = len(nodeList.Items)
-	return nil
+	err = listAllPages(timeout, func(options metav1.ListOptions) (metav1.ListInterface, error) {
+		list, err := cli.AppsV1().StatefulSets("").List(options)
+		if err != nil {
+			return nil, err
+		}
+		statefulSets += len(list.Items)
+		return list, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = listAllPages(timeout, func(options metav1.ListOptions) (metav1.ListInterface, error) {
+		list, err := cli.AppsV1().DaemonSets("").List(options)
+		if err != nil {
+			return nil, err
+		}
+		daemonSets += len(list.Items)
+		return list, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(snapshot *portainer.KubernetesSnapshot) {
+		snapshot.DeploymentCount = deployments
+		snapshot.StatefulSetCount = statefulSets
+		snapshot.DaemonSetCount = daemonSets
+	}, nil
+}
+
+func snapshotPods(cli *kubernetes.Clientset, timeout int64) (func(snapshot *portainer.KubernetesSnapshot), error) {
+	podCount := 0
+	failingPodCount := 0
+	podsByPhase := map[string]int{}
+	failingPods := []portainer.KubernetesFailingPod{}
+
+	err := listAllPages(timeout, func(options metav1.ListOptions) (metav1.ListInterface, error) {
+		pods, err := cli.CoreV1().Pods("").List(options)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pod := range pods.Items {
+			podCount++
+			podsByPhase[string(pod.Status.Phase)]++
+
+			failingPod := podFailure(&pod)
+			if failingPod == nil {
+				continue
+			}
+
+			failingPodCount++
+			if len(failingPods) < snapshotMaxFailingPods {
+				failingPods = append(failingPods, *failingPod)
+			}
+		}
+
+		return pods, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(snapshot *portainer.KubernetesSnapshot) {
+		snapshot.PodCount = podCount
+		snapshot.PodsByPhase = podsByPhase
+		snapshot.FailingPodCount = failingPodCount
+		snapshot.FailingPods = failingPods
+	}, nil
+}
+
+// podFailure returns a failing pod description if the pod is in a failed phase
+// or if one of its containers is stuck in an error state, nil otherwise.
+func podFailure(pod *v1.Pod) *portainer.KubernetesFailingPod {
+	failingPod := &portainer.KubernetesFailingPod{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Phase:     string(pod.Status.Phase),
+		Reason:    pod.Status.Reason,
+	}
+
+	failing := pod.Status.Phase == v1.PodFailed
+
+	statuses := make([]v1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		failingPod.RestartCount += status.RestartCount
+
+		reason := ""
+		if status.State.Waiting != nil {
+			reason = status.State.Waiting.Reason
+		} else if status.State.Terminated != nil && status.State.Terminated.ExitCode != 0 && pod.Status.Phase != v1.PodSucceeded {
+			reason = status.State.Terminated.Reason
+		}
+
+		if failingContainerReasons[reason] {
+			failing = true
+			if failingPod.Reason == "" {
+				failingPod.Reason = reason
+			}
+		}
+	}
+
+	if !failing {
+		return nil
+	}
+
+	return failingPod
+}
+
+func snapshotVolumeClaims(cli *kubernetes.Clientset, timeout int64) (func(snapshot *portainer.KubernetesSnapshot), error) {
+	statistics := portainer.KubernetesVolumeClaimsStatistics{}
+
+	err := listAllPages(timeout, func(options metav1.ListOptions) (metav1.ListInterface, error) {
+		claims, err := cli.CoreV1().PersistentVolumeClaims("").List(options)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, claim := range claims.Items {
+			statistics.Count++
+
+			switch claim.Status.Phase {
+			case v1.ClaimBound:
+				statistics.BoundCount++
+			case v1.ClaimPending:
+				statistics.PendingCount++
+			}
+
+			if request, ok := claim.Spec.Resources.Requests[v1.ResourceStorage]; ok {
+				statistics.RequestedBytes += request.Value()
+			}
+			if capacity, ok := claim.Status.Capacity[v1.ResourceStorage]; ok {
+				statistics.CapacityBytes += capacity.Value()
+			}
+		}
+
+		return claims, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(snapshot *portainer.KubernetesSnapshot) {
+		usedBytes := snapshot.PersistentVolumeClaims.UsedBytes
+		snapshot.PersistentVolumeClaims = statistics
+		snapshot.PersistentVolumeClaims.UsedBytes = usedBytes
+	}, nil
+}
+
+// kubeletSummary is the subset of the kubelet stats summary used to compute volume usage
+type kubeletSummary struct {
+	Pods []struct {
+		Volumes []struct {
+			UsedBytes *int64 `json:"usedBytes"`
+			PVCRef    *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+// snapshotVolumeClaimsUsage retrieves the volume usage reported by the kubelet of each node.
+// A claim mounted by multiple pods is only accounted once.
+func snapshotVolumeClaimsUsage(cli *kubernetes.Clientset, timeout int64) (func(snapshot *portainer.KubernetesSnapshot), error) {
+	nodeList, err := cli.CoreV1().Nodes().List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var queryErr error
+	usage := map[string]int64{}
+	semaphore := make(chan struct{}, snapshotMaxConcurrentNodeQueries)
+
+	for _, node := range nodeList.Items {
+		wg.Add(1)
+		go func(nodeName string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			body, err := cli.CoreV1().RESTClient().Get().
+				Resource("nodes").
+				Name(nodeName).
+				SubResource("proxy").
+				Suffix("stats/summary").
+				Timeout(time.Duration(timeout) * time.Second).
+				DoRaw()
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				queryErr = err
+				return
+			}
+
+			var summary kubeletSummary
+			err = json.Unmarshal(body, &summary)
+			if err != nil {
+				queryErr = err
+				return
+			}
+
+			for _, pod := range summary.Pods {
+				for _, volume := range pod.Volumes {
+					if volume.PVCRef == nil || volume.UsedBytes == nil {
+						continue
+					}
+					usage[volume.PVCRef.Namespace+"/"+volume.PVCRef.Name] = *volume.UsedBytes
+				}
+			}
+		}(node.Name)
+	}
+
+	wg.Wait()
+
+	if queryErr != nil && len(usage) == 0 {
+		return nil, queryErr
+	}
+
+	var usedBytes int64
+	for _, used := range usage {
+		usedBytes += used
+	}
+
+	return func(snapshot *portainer.KubernetesSnapshot) {
+		snapshot.PersistentVolumeClaims.UsedBytes = usedBytes
+	}, nil
+}
+
+// listAllPages iterates over a paginated list request until every page has been retrieved
+func listAllPages(timeout int64, list func(options metav1.ListOptions) (metav1.ListInterface, error)) error {
+	options := metav1.ListOptions{
+		Limit:          snapshotPageSize,
+		TimeoutSeconds: &timeout,
+	}
+
+	for {
+		result, err := list(options)
+		if err != nil {
+			return err
+		}
+
+		if result.GetContinue() == "" {
+			return nil
+		}
+		options.Continue = result.GetContinue()
+	}
 }
@@ -0,0 +1,91 @@
+package kubernetes
+
+import (
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_podFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      v1.Pod
+		expected *portainer.KubernetesFailingPod
+	}{
+		{
+			name: "should ignore a running pod",
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Status: v1.PodStatus{
+					Phase: v1.PodRunning,
+					ContainerStatuses: []v1.ContainerStatus{
+						{State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+					},
+				},
+			},
+			expected: nil,
+		},
+		{
+			name: "should flag a failed pod",
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "batch"},
+				Status:     v1.PodStatus{Phase: v1.PodFailed, Reason: "Evicted"},
+			},
+			expected: &portainer.KubernetesFailingPod{Name: "job", Namespace: "batch", Phase: "Failed", Reason: "Evicted"},
+		},
+		{
+			name: "should flag a pod with a container in crash loop",
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+				Status: v1.PodStatus{
+					Phase: v1.PodRunning,
+					ContainerStatuses: []v1.ContainerStatus{
+						{
+							RestartCount: 7,
+							State:        v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+						},
+					},
+				},
+			},
+			expected: &portainer.KubernetesFailingPod{Name: "api", Namespace: "default", Phase: "Running", Reason: "CrashLoopBackOff", RestartCount: 7},
+		},
+		{
+			name: "should ignore a completed pod",
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "init", Namespace: "default"},
+				Status: v1.PodStatus{
+					Phase: v1.PodSucceeded,
+					ContainerStatuses: []v1.ContainerStatus{
+						{State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Completed"}}},
+					},
+				},
+			},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, podFailure(&tt.pod))
+		})
+	}
+}
+
+func Test_nodeStatus(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-1"},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: v1.ConditionFalse},
+				{Type: v1.NodeMemoryPressure, Status: v1.ConditionTrue},
+				{Type: v1.NodeDiskPressure, Status: v1.ConditionFalse},
+			},
+		},
+	}
+
+	expected := portainer.KubernetesNodeStatus{Name: "worker-1", MemoryPressure: true}
+	assert.Equal(t, expected, nodeStatus(node))
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/dockerconfig"
 	"github.com/portainer/libcompose/config"
 	"github.com/portainer/libcompose/docker"
 	"github.com/portainer/libcompose/docker/client"
@@ -64,14 +65,21 @@ func (manager *ComposeStackManager) ComposeSyntaxMaxVersion() string {
 	return composeSyntaxMaxVersion
 }
 
-// Up will deploy a compose stack (equivalent of docker-compose up)
-func (manager *ComposeStackManager) Up(stack *portainer.Stack, endpoint *portainer.Endpoint) error {
+// Up will deploy a compose stack (equivalent of docker-compose up). The images are pulled
+// with the credentials of the specified registries.
+func (manager *ComposeStackManager) Up(stack *portainer.Stack, endpoint *portainer.Endpoint, dockerhub *portainer.DockerHub, registries []portainer.Registry) error {
 
 	clientFactory, err := manager.createClient(endpoint)
 	if err != nil {
 		return err
 	}
 
+	configPath, cleanUp, err := dockerconfig.Create(manager.dataPath, dockerconfig.RegistryAuths(dockerhub, registries))
+	if err != nil {
+		return err
+	}
+	defer cleanUp()
+
 	env := make(map[string]string)
 	for _, envvar := range stack.Env {
 		env[envvar.Name] = envvar.Value
@@ -79,7 +87,7 @@ func (manager *ComposeStackManager) Up(stack *portainer.Stack, endpoint *portain
 
 	composeFilePath := path.Join(stack.ProjectPath, stack.EntryPoint)
 	proj, err := docker.NewProject(&ctx.Context{
-		ConfigDir: manager.dataPath,
+		ConfigDir: configPath,
 		Context: project.Context{
 			ComposeFiles: []string{composeFilePath},
 			EnvironmentLookup: &lookup.ComposableEnvLookup{
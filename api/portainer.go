@@ -36,6 +36,7 @@ type (
 		Addr                      *string
 		TunnelAddr                *string
 		TunnelPort                *string
+		TunnelRotationPort        *string
 		AdminPassword             *string
 		AdminPasswordFile         *string
 		Assets                    *string
@@ -55,21 +56,48 @@ type (
 		SSLCert                   *string
 		SSLKey                    *string
 		SnapshotInterval          *string
+		ImageCheckInterval        *string
+		EdgeStackGitSyncInterval  *string
+		TemplateGitSyncInterval   *string
+		RegistryRetentionInterval *string
+		ImageScanInterval         *string
+		TrustedProxies            *[]string
 	}
 
-	// CustomTemplate represents a custom template
+	// CustomTemplate represents a custom template. The variables declared by the template are rendered
+	// into its stack file at deploy time. Version is incremented each time the stack file or the variables
+	// of the template change, the file of each version is kept and listed in History.
 	CustomTemplate struct {
-		ID              CustomTemplateID       `json:"Id"`
-		Title           string                 `json:"Title"`
-		Description     string                 `json:"Description"`
-		ProjectPath     string                 `json:"ProjectPath"`
-		EntryPoint      string                 `json:"EntryPoint"`
-		CreatedByUserID UserID                 `json:"CreatedByUserId"`
-		Note            string                 `json:"Note"`
-		Platform        CustomTemplatePlatform `json:"Platform"`
-		Logo            string                 `json:"Logo"`
-		Type            StackType              `json:"Type"`
-		ResourceControl *ResourceControl       `json:"ResourceControl"`
+		ID              CustomTemplateID         `json:"Id"`
+		Title           string                   `json:"Title"`
+		Description     string                   `json:"Description"`
+		ProjectPath     string                   `json:"ProjectPath"`
+		EntryPoint      string                   `json:"EntryPoint"`
+		CreatedByUserID UserID                   `json:"CreatedByUserId"`
+		Note            string                   `json:"Note"`
+		Platform        CustomTemplatePlatform   `json:"Platform"`
+		Logo            string                   `json:"Logo"`
+		Type            StackType                `json:"Type"`
+		ResourceControl *ResourceControl         `json:"ResourceControl"`
+		Variables       []CustomTemplateVariable `json:"Variables"`
+		Version         int                      `json:"Version"`
+		History         []CustomTemplateVersion  `json:"History"`
+		GitConfig       *CustomTemplateGitConfig `json:"GitConfig,omitempty"`
+	}
+
+	// CustomTemplateGitConfig represents the Git repository a custom template is linked to.
+	// When AutoSync is enabled, the repository is checked periodically and a new commit on the reference
+	// creates a new version of the template. CommitHash is the commit of the current template file.
+	CustomTemplateGitConfig struct {
+		URL            string `json:"URL"`
+		ReferenceName  string `json:"ReferenceName"`
+		Authentication bool   `json:"Authentication"`
+		Username       string `json:"Username,omitempty"`
+		Password       string `json:"Password,omitempty"`
+		AutoSync       bool   `json:"AutoSync"`
+		CommitHash     string `json:"CommitHash"`
+		LastSync       int64  `json:"LastSync"`
+		LastSyncError  string `json:"LastSyncError,omitempty"`
 	}
 
 	// CustomTemplateID represents a custom template identifier
@@ -78,6 +106,39 @@ type (
 	// CustomTemplatePlatform represents a custom template platform
 	CustomTemplatePlatform int
 
+	// CustomTemplateVariable represents a typed variable of a custom template, referenced as {{ Name }}
+	// in the stack file. Pattern applies to string and secret variables, Min and Max to number variables
+	// and Options to select variables. Secret variables cannot have a default value.
+	CustomTemplateVariable struct {
+		Name        string                         `json:"Name"`
+		Label       string                         `json:"Label"`
+		Description string                         `json:"Description,omitempty"`
+		Type        CustomTemplateVariableType     `json:"Type"`
+		Default     string                         `json:"Default,omitempty"`
+		Required    bool                           `json:"Required"`
+		Pattern     string                         `json:"Pattern,omitempty"`
+		Min         *float64                       `json:"Min,omitempty"`
+		Max         *float64                       `json:"Max,omitempty"`
+		Options     []CustomTemplateVariableOption `json:"Options,omitempty"`
+	}
+
+	// CustomTemplateVariableOption represents an option of a select variable of a custom template
+	CustomTemplateVariableOption struct {
+		Label string `json:"Label"`
+		Value string `json:"Value"`
+	}
+
+	// CustomTemplateVariableType represents the type of a custom template variable
+	CustomTemplateVariableType string
+
+	// CustomTemplateVersion represents a version of the file of a custom template
+	CustomTemplateVersion struct {
+		Version         int    `json:"Version"`
+		CommitHash      string `json:"CommitHash,omitempty"`
+		CreatedAt       int64  `json:"CreatedAt"`
+		CreatedByUserID UserID `json:"CreatedByUserId,omitempty"`
+	}
+
 	// DockerHub represents all the required information to connect and use the
 	// Docker Hub
 	DockerHub struct {
@@ -110,10 +171,73 @@ type (
 		Volumes    interface{} `json:"Volumes"`
 		Networks   interface{} `json:"Networks"`
 		Images     interface{} `json:"Images"`
+		Services   interface{} `json:"Services,omitempty"`
 		Info       interface{} `json:"Info"`
 		Version    interface{} `json:"Version"`
 	}
 
+	// EdgeCheckin represents the persisted check-in state of an Edge agent along with
+	// the history of the reverse tunnels opened and closed for its endpoint
+	EdgeCheckin struct {
+		EndpointID   EndpointID        `json:"EndpointId"`
+		LastCheckin  int64             `json:"LastCheckin"`
+		AgentVersion string            `json:"AgentVersion"`
+		PublicIP     string            `json:"PublicIP"`
+		TunnelEvents []EdgeTunnelEvent `json:"TunnelEvents"`
+	}
+
+	// EdgeEnrollment represents the enrollment information of an Edge endpoint registered
+	// by its agent with an enrollment token. Tags and Metadata are reported by the agent.
+	// SecretHash is the hash of the secret issued to the agent on its first enrollment.
+	EdgeEnrollment struct {
+		TokenID    EdgeEnrollmentTokenID `json:"TokenId"`
+		Status     EdgeEnrollmentStatus  `json:"Status"`
+		SecretHash string                `json:"SecretHash"`
+		Tags       []string              `json:"Tags"`
+		Metadata   map[string]string     `json:"Metadata"`
+		EnrolledAt int64                 `json:"EnrolledAt"`
+		ReviewedAt int64                 `json:"ReviewedAt"`
+	}
+
+	// EdgeEnrollmentRule represents a rule applied when an enrolled Edge endpoint is approved.
+	// A rule matches an endpoint enrolled with one of TokenIDs (any token when empty), reporting
+	// all the MatchTags and whose name matches NamePattern. Rules are evaluated by ascending priority,
+	// the first matching rule assigns its endpoint group and tags and can approve the endpoint automatically.
+	EdgeEnrollmentRule struct {
+		ID          EdgeEnrollmentRuleID    `json:"Id"`
+		Name        string                  `json:"Name"`
+		Priority    int                     `json:"Priority"`
+		TokenIDs    []EdgeEnrollmentTokenID `json:"TokenIds"`
+		MatchTags   []string                `json:"MatchTags"`
+		NamePattern string                  `json:"NamePattern"`
+		GroupID     EndpointGroupID         `json:"GroupId"`
+		TagIDs      []TagID                 `json:"TagIds"`
+		AutoApprove bool                    `json:"AutoApprove"`
+	}
+
+	// EdgeEnrollmentRuleID represents an Edge enrollment rule identifier
+	EdgeEnrollmentRuleID int
+
+	// EdgeEnrollmentStatus represents the enrollment status of an Edge endpoint
+	EdgeEnrollmentStatus string
+
+	// EdgeEnrollmentToken represents a reusable token presented by unknown Edge agents on their
+	// first check-in. PortainerURL is the URL encoded in the Edge keys of the enrolled endpoints.
+	// A token without ExpiresAt never expires and a token without MaxUses can be used indefinitely.
+	EdgeEnrollmentToken struct {
+		ID           EdgeEnrollmentTokenID `json:"Id"`
+		Name         string                `json:"Name"`
+		Token        string                `json:"Token"`
+		PortainerURL string                `json:"PortainerURL"`
+		ExpiresAt    int64                 `json:"ExpiresAt"`
+		MaxUses      int                   `json:"MaxUses"`
+		Uses         int                   `json:"Uses"`
+		Created      int64                 `json:"Created"`
+	}
+
+	// EdgeEnrollmentTokenID represents an Edge enrollment token identifier
+	EdgeEnrollmentTokenID int
+
 	// EdgeGroup represents an Edge group
 	EdgeGroup struct {
 		ID           EdgeGroupID  `json:"Id"`
@@ -128,26 +252,51 @@ type (
 	EdgeGroupID int
 
 	// EdgeJob represents a job that can run on Edge environments.
+	// Timeout is the maximum execution time of the script in seconds, 0 disables the timeout.
+	// When CollectLogsOnFailure is set, the agent sends the logs of failed and timed out runs with their result.
+	// When EdgeGroups is set, Endpoints is kept in sync with the endpoints related to these Edge groups.
 	EdgeJob struct {
-		ID             EdgeJobID                          `json:"Id"`
-		Created        int64                              `json:"Created"`
-		CronExpression string                             `json:"CronExpression"`
-		Endpoints      map[EndpointID]EdgeJobEndpointMeta `json:"Endpoints"`
-		Name           string                             `json:"Name"`
-		ScriptPath     string                             `json:"ScriptPath"`
-		Recurring      bool                               `json:"Recurring"`
-		Version        int                                `json:"Version"`
-	}
-
-	// EdgeJobEndpointMeta represents a meta data object for an Edge job and Endpoint relation
+		ID                   EdgeJobID                          `json:"Id"`
+		Created              int64                              `json:"Created"`
+		CronExpression       string                             `json:"CronExpression"`
+		Endpoints            map[EndpointID]EdgeJobEndpointMeta `json:"Endpoints"`
+		Name                 string                             `json:"Name"`
+		ScriptPath           string                             `json:"ScriptPath"`
+		Recurring            bool                               `json:"Recurring"`
+		Version              int                                `json:"Version"`
+		Timeout              int                                `json:"Timeout"`
+		CollectLogsOnFailure bool                               `json:"CollectLogsOnFailure"`
+		EdgeGroups           []EdgeGroupID                      `json:"EdgeGroups"`
+	}
+
+	// EdgeJobEndpointMeta represents a meta data object for an Edge job and Endpoint relation.
+	// Runs contains the most recent executions of the job on the endpoint, oldest first,
+	// RunCount is the total number of executions reported by the endpoint.
 	EdgeJobEndpointMeta struct {
 		LogsStatus  EdgeJobLogsStatus
 		CollectLogs bool
+		Runs        []EdgeJobRun `json:",omitempty"`
+		RunCount    int          `json:",omitempty"`
 	}
 
 	// EdgeJobID represents an Edge job identifier
 	EdgeJobID int
 
+	// EdgeJobRun represents the result of an execution of an Edge job on an endpoint,
+	// as reported by the Edge agent. Times are unix timestamps in seconds.
+	EdgeJobRun struct {
+		ID         int               `json:"Id"`
+		Status     EdgeJobRunStatus  `json:"Status"`
+		ExitCode   int               `json:"ExitCode"`
+		StartedAt  int64             `json:"StartedAt"`
+		FinishedAt int64             `json:"FinishedAt"`
+		Duration   int64             `json:"Duration"`
+		LogsStatus EdgeJobLogsStatus `json:"LogsStatus"`
+	}
+
+	// EdgeJobRunStatus represents the outcome of an Edge job run
+	EdgeJobRunStatus string
+
 	// EdgeJobLogsStatus represent status of logs collection job
 	EdgeJobLogsStatus int
 
@@ -161,19 +310,102 @@ type (
 		Endpoints      []EndpointID `json:"Endpoints"`
 	}
 
-	//EdgeStack represents an edge stack
+	//EdgeStack represents an edge stack.
+	//EndpointRetries counts the deployments retried on each endpoint, it is added to Version to compute
+	//the version sent to the agent of the endpoint so that a retry is not visible to the other endpoints.
+	//Deployments contains the most recent deployment attempts on each endpoint, oldest first.
+	//Env contains the default environment variables of the stack, they can be overridden for the endpoints
+	//having a tag listed in TagEnv and by the metadata of an endpoint using the name of a variable as key.
+	//DeploymentType defines whether the stack file is a Compose file deployed on Docker Edge endpoints
+	//or a Kubernetes manifest deployed inside Namespace on Kubernetes Edge endpoints.
+	//GitConfig is defined for the stacks created from a Git repository.
+	//PendingSecretEnv contains, for each endpoint, the version of an exported bundle whose secret
+	//environment variables are sent to the agent at its next check-in.
 	EdgeStack struct {
-		ID           EdgeStackID                    `json:"Id"`
-		Name         string                         `json:"Name"`
-		Status       map[EndpointID]EdgeStackStatus `json:"Status"`
-		CreationDate int64                          `json:"CreationDate"`
-		EdgeGroups   []EdgeGroupID                  `json:"EdgeGroups"`
-		ProjectPath  string                         `json:"ProjectPath"`
-		EntryPoint   string                         `json:"EntryPoint"`
-		Version      int                            `json:"Version"`
-		Prune        bool                           `json:"Prune"`
+		ID               EdgeStackID                          `json:"Id"`
+		Name             string                               `json:"Name"`
+		Status           map[EndpointID]EdgeStackStatus       `json:"Status"`
+		CreationDate     int64                                `json:"CreationDate"`
+		EdgeGroups       []EdgeGroupID                        `json:"EdgeGroups"`
+		ProjectPath      string                               `json:"ProjectPath"`
+		EntryPoint       string                               `json:"EntryPoint"`
+		Version          int                                  `json:"Version"`
+		Prune            bool                                 `json:"Prune"`
+		EndpointRetries  map[EndpointID]int                   `json:"EndpointRetries,omitempty"`
+		Deployments      map[EndpointID][]EdgeStackDeployment `json:"Deployments,omitempty"`
+		Env              []EdgeStackEnvVar                    `json:"Env"`
+		TagEnv           []EdgeStackTagEnvVar                 `json:"TagEnv"`
+		DeploymentType   EdgeStackDeploymentType              `json:"DeploymentType"`
+		Namespace        string                               `json:"Namespace,omitempty"`
+		GitConfig        *EdgeStackGitConfig                  `json:"GitConfig,omitempty"`
+		PendingSecretEnv map[EndpointID]int                   `json:"PendingSecretEnv,omitempty"`
+	}
+
+	// EdgeStackGitConfig represents the Git repository an Edge stack is deployed from.
+	// When AutoSync is enabled, the repository is checked periodically and a new commit on the reference
+	// updates the stack file and the stack version. CommitHash is the commit of the current stack file.
+	EdgeStackGitConfig struct {
+		URL            string `json:"URL"`
+		ReferenceName  string `json:"ReferenceName"`
+		Authentication bool   `json:"Authentication"`
+		Username       string `json:"Username,omitempty"`
+		Password       string `json:"Password,omitempty"`
+		AutoSync       bool   `json:"AutoSync"`
+		CommitHash     string `json:"CommitHash"`
+		LastSync       int64  `json:"LastSync"`
+		LastSyncError  string `json:"LastSyncError,omitempty"`
+	}
+
+	// EdgeStackDeploymentType represents the type of the file deployed by an Edge stack
+	EdgeStackDeploymentType int
+
+	// EdgeStackEnvVar represents an environment variable of an Edge stack. The value of a secret variable is
+	// never returned by the API and is sent encrypted to the Edge agents.
+	EdgeStackEnvVar struct {
+		Name   string `json:"Name"`
+		Value  string `json:"Value"`
+		Secret bool   `json:"Secret"`
+	}
+
+	// EdgeStackTagEnvVar represents the value of an environment variable of an Edge stack
+	// for the endpoints associated to a tag, directly or through their endpoint group
+	EdgeStackTagEnvVar struct {
+		TagID TagID  `json:"TagId"`
+		Name  string `json:"Name"`
+		Value string `json:"Value"`
+	}
+
+	// EdgeStackDeployment represents an attempt to deploy an Edge stack on an endpoint, as reported by the agent.
+	// Version is the version of the stack sent to the agent of the endpoint.
+	EdgeStackDeployment struct {
+		Version    int                      `json:"Version"`
+		CommitHash string                   `json:"CommitHash,omitempty"`
+		Type       EdgeStackStatusType      `json:"Type"`
+		Error      string                   `json:"Error,omitempty"`
+		Output     string                   `json:"Output,omitempty"`
+		Services   []EdgeStackServiceStatus `json:"Services,omitempty"`
+		StartedAt  int64                    `json:"StartedAt"`
+		FinishedAt int64                    `json:"FinishedAt,omitempty"`
 	}
 
+	// EdgeStackServiceStatus represents the state of the containers of a service of a deployed Edge stack
+	EdgeStackServiceStatus struct {
+		Name    string `json:"Name"`
+		State   string `json:"State"`
+		Running int    `json:"Running"`
+		Desired int    `json:"Desired"`
+	}
+
+	// EdgeTunnelEvent represents the opening or the closing of the reverse tunnel of an Edge endpoint
+	EdgeTunnelEvent struct {
+		Type      EdgeTunnelEventType `json:"Type"`
+		Timestamp int64               `json:"Timestamp"`
+		Port      int                 `json:"Port,omitempty"`
+	}
+
+	// EdgeTunnelEventType represents the type of an Edge tunnel event
+	EdgeTunnelEventType string
+
 	//EdgeStackID represents an edge stack id
 	EdgeStackID int
 
@@ -182,6 +414,8 @@ type (
 		Type       EdgeStackStatusType `json:"Type"`
 		Error      string              `json:"Error"`
 		EndpointID EndpointID          `json:"EndpointID"`
+		Version    int                 `json:"Version,omitempty"`
+		CommitHash string              `json:"CommitHash,omitempty"`
 	}
 
 	//EdgeStackStatusType represents an edge stack status type
@@ -209,6 +443,9 @@ type (
 		EdgeCheckinInterval     int                 `json:"EdgeCheckinInterval"`
 		Kubernetes              KubernetesData      `json:"Kubernetes"`
 		ComposeSyntaxMaxVersion string              `json:"ComposeSyntaxMaxVersion"`
+		EdgeEnrollment          *EdgeEnrollment     `json:"EdgeEnrollment,omitempty"`
+		RevokedEdgeIDs          []string            `json:"RevokedEdgeIDs,omitempty"`
+		Metadata                map[string]string   `json:"Metadata,omitempty"`
 
 		// Deprecated fields
 		// Deprecated in DBVersion == 4
@@ -239,7 +476,8 @@ type (
 	// one extension of each type can be associated to an endpoint
 	EndpointExtensionType int
 
-	// EndpointGroup represents a group of endpoints
+	// EndpointGroup represents a group of endpoints. ImageTrustPolicy defines how the images deployed
+	// on the endpoints of the group whose signature cannot be verified are handled.
 	EndpointGroup struct {
 		ID                 EndpointGroupID    `json:"Id"`
 		Name               string             `json:"Name"`
@@ -247,6 +485,7 @@ type (
 		UserAccessPolicies UserAccessPolicies `json:"UserAccessPolicies"`
 		TeamAccessPolicies TeamAccessPolicies `json:"TeamAccessPolicies"`
 		TagIDs             []TagID            `json:"TagIds"`
+		ImageTrustPolicy   ImageTrustPolicy   `json:"ImageTrustPolicy"`
 
 		// Deprecated fields
 		Labels []Pair `json:"Labels"`
@@ -281,6 +520,15 @@ type (
 		EdgeStacks map[EdgeStackID]bool
 	}
 
+	// EndpointVulnerabilitySummary represents the vulnerabilities of the images running in the containers
+	// and services of the latest snapshot of an endpoint, summarized per image and per stack
+	EndpointVulnerabilitySummary struct {
+		EndpointID EndpointID                    `json:"EndpointId"`
+		Summary    map[VulnerabilitySeverity]int `json:"Summary"`
+		Images     []ImageVulnerabilitySummary   `json:"Images"`
+		Stacks     []StackVulnerabilitySummary   `json:"Stacks"`
+	}
+
 	// Extension represents a deprecated Portainer extension
 	Extension struct {
 		ID               ExtensionID        `json:"Id"`
@@ -304,6 +552,14 @@ type (
 	// ExtensionID represents a extension identifier
 	ExtensionID int
 
+	// EcrRegistryData represents the AWS credentials used to request the authorization tokens of an ECR registry.
+	// The region is read from the registry URL when it is not specified.
+	EcrRegistryData struct {
+		Region          string `json:"Region"`
+		AccessKeyID     string `json:"AccessKeyID"`
+		SecretAccessKey string `json:"SecretAccessKey,omitempty"`
+	}
+
 	// GitlabRegistryData represents data required for gitlab registry to work
 	GitlabRegistryData struct {
 		ProjectID   int    `json:"ProjectId"`
@@ -311,6 +567,152 @@ type (
 		ProjectPath string `json:"ProjectPath"`
 	}
 
+	// HousekeepingPolicy represents a scheduled maintenance policy used to prune unused
+	// Docker resources on a set of endpoints and endpoint groups.
+	// When PruneAllImages is false, only dangling images are pruned. OlderThan is a duration (e.g. 24h)
+	// restricting the pruning to resources created before now - OlderThan.
+	HousekeepingPolicy struct {
+		ID               HousekeepingPolicyID `json:"Id"`
+		Name             string               `json:"Name"`
+		CronExpression   string               `json:"CronExpression"`
+		EndpointIDs      []EndpointID         `json:"EndpointIds"`
+		EndpointGroupIDs []EndpointGroupID    `json:"EndpointGroupIds"`
+		PruneImages      bool                 `json:"PruneImages"`
+		PruneAllImages   bool                 `json:"PruneAllImages"`
+		PruneVolumes     bool                 `json:"PruneVolumes"`
+		PruneNetworks    bool                 `json:"PruneNetworks"`
+		PruneBuildCache  bool                 `json:"PruneBuildCache"`
+		Labels           []Pair               `json:"Labels"`
+		OlderThan        string               `json:"OlderThan"`
+		DryRun           bool                 `json:"DryRun"`
+		Enabled          bool                 `json:"Enabled"`
+		Created          int64                `json:"Created"`
+		History          []HousekeepingRun    `json:"History"`
+	}
+
+	// HousekeepingPolicyID represents a housekeeping policy identifier
+	HousekeepingPolicyID int
+
+	// HousekeepingRun represents the execution of a housekeeping policy on an endpoint
+	HousekeepingRun struct {
+		EndpointID     EndpointID                    `json:"EndpointId"`
+		NodeName       string                        `json:"NodeName,omitempty"`
+		StartedAt      int64                         `json:"StartedAt"`
+		FinishedAt     int64                         `json:"FinishedAt"`
+		DryRun         bool                          `json:"DryRun"`
+		SpaceReclaimed uint64                        `json:"SpaceReclaimed"`
+		Operations     []HousekeepingOperationReport `json:"Operations"`
+		Error          string                        `json:"Error,omitempty"`
+	}
+
+	// HousekeepingOperationReport represents the result of a single prune operation
+	HousekeepingOperationReport struct {
+		Operation      HousekeepingOperation `json:"Operation"`
+		ItemsDeleted   []string              `json:"ItemsDeleted"`
+		SpaceReclaimed uint64                `json:"SpaceReclaimed"`
+		Error          string                `json:"Error,omitempty"`
+	}
+
+	// HousekeepingOperation represents the type of resource pruned by a housekeeping operation
+	HousekeepingOperation string
+
+	// HousekeepingExecution represents a manual execution of a housekeeping policy running in the background.
+	// Runs contains the runs of the policy on each node once the execution is completed.
+	HousekeepingExecution struct {
+		ID         string                      `json:"Id"`
+		PolicyID   HousekeepingPolicyID        `json:"PolicyId"`
+		Status     HousekeepingExecutionStatus `json:"Status"`
+		StartedAt  int64                       `json:"StartedAt"`
+		FinishedAt int64                       `json:"FinishedAt,omitempty"`
+		Runs       []HousekeepingRun           `json:"Runs"`
+		Error      string                      `json:"Error,omitempty"`
+	}
+
+	// HousekeepingExecutionStatus represents the status of a manual execution of a housekeeping policy
+	HousekeepingExecutionStatus string
+
+	// ImageScan represents the result of the vulnerability scan of an image digest
+	ImageScan struct {
+		Image           string                        `json:"Image"`
+		Digest          string                        `json:"Digest"`
+		ScannedAt       int64                         `json:"ScannedAt"`
+		Scanner         string                        `json:"Scanner"`
+		Summary         map[VulnerabilitySeverity]int `json:"Summary"`
+		Vulnerabilities []ImageVulnerability          `json:"Vulnerabilities"`
+		Error           string                        `json:"Error,omitempty"`
+	}
+
+	// ImageScanSettings represents the settings of the vulnerability scanner. When DeployGate is enabled,
+	// the deployment of a stack is refused if one of its images has a vulnerability of GateSeverity or higher.
+	ImageScanSettings struct {
+		Enabled       bool                  `json:"Enabled"`
+		ScannerType   ImageScannerType      `json:"ScannerType"`
+		URL           string                `json:"URL"`
+		AccessToken   string                `json:"AccessToken,omitempty"`
+		TLSSkipVerify bool                  `json:"TLSSkipVerify"`
+		DeployGate    bool                  `json:"DeployGate"`
+		GateSeverity  VulnerabilitySeverity `json:"GateSeverity"`
+	}
+
+	// ImageScannerType represents the type of a vulnerability scanner
+	ImageScannerType string
+
+	// ImageStatus represents the result of the image update detection for the containers
+	// and services of an endpoint
+	ImageStatus struct {
+		EndpointID EndpointID            `json:"EndpointId"`
+		CheckedAt  int64                 `json:"CheckedAt"`
+		Resources  []ImageStatusResource `json:"Resources"`
+	}
+
+	// ImageStatusResource represents the image status of a container or a service
+	ImageStatusResource struct {
+		Type          ImageStatusResourceType `json:"Type"`
+		ID            string                  `json:"Id"`
+		Name          string                  `json:"Name"`
+		Image         string                  `json:"Image"`
+		StackName     string                  `json:"StackName,omitempty"`
+		RunningDigest string                  `json:"RunningDigest"`
+		LatestDigest  string                  `json:"LatestDigest"`
+		Status        ImageStatusType         `json:"Status"`
+		Error         string                  `json:"Error,omitempty"`
+	}
+
+	// ImageStatusResourceType represents the type of resource running an image
+	ImageStatusResourceType string
+
+	// ImageStatusType represents the update status of an image
+	ImageStatusType string
+
+	// ImageTrustKey represents a PEM encoded public key trusted to sign images
+	ImageTrustKey struct {
+		Name      string `json:"Name"`
+		PublicKey string `json:"PublicKey"`
+	}
+
+	// ImageTrustPolicy represents the handling of the images whose signature cannot be verified
+	ImageTrustPolicy string
+
+	// ImageVulnerability represents a vulnerability found in a package of an image
+	ImageVulnerability struct {
+		ID          string                `json:"Id"`
+		Package     string                `json:"Package"`
+		Version     string                `json:"Version"`
+		FixVersion  string                `json:"FixVersion,omitempty"`
+		Severity    VulnerabilitySeverity `json:"Severity"`
+		Description string                `json:"Description,omitempty"`
+	}
+
+	// ImageVulnerabilitySummary represents the vulnerabilities of an image running on an endpoint
+	ImageVulnerabilitySummary struct {
+		Image     string                        `json:"Image"`
+		Digest    string                        `json:"Digest"`
+		ScannedAt int64                         `json:"ScannedAt"`
+		Summary   map[VulnerabilitySeverity]int `json:"Summary"`
+		Stacks    []string                      `json:"Stacks"`
+		Error     string                        `json:"Error,omitempty"`
+	}
+
 	// JobType represents a job type
 	JobType int
 
@@ -322,11 +724,52 @@ type (
 
 	// KubernetesSnapshot represents a snapshot of a specific Kubernetes endpoint at a specific time
 	KubernetesSnapshot struct {
-		Time              int64  `json:"Time"`
-		KubernetesVersion string `json:"KubernetesVersion"`
-		NodeCount         int    `json:"NodeCount"`
-		TotalCPU          int64  `json:"TotalCPU"`
-		TotalMemory       int64  `json:"TotalMemory"`
+		Time                   int64                            `json:"Time"`
+		KubernetesVersion      string                           `json:"KubernetesVersion"`
+		NodeCount              int                              `json:"NodeCount"`
+		ReadyNodeCount         int                              `json:"ReadyNodeCount"`
+		TotalCPU               int64                            `json:"TotalCPU"`
+		TotalMemory            int64                            `json:"TotalMemory"`
+		NamespaceCount         int                              `json:"NamespaceCount"`
+		DeploymentCount        int                              `json:"DeploymentCount"`
+		StatefulSetCount       int                              `json:"StatefulSetCount"`
+		DaemonSetCount         int                              `json:"DaemonSetCount"`
+		PodCount               int                              `json:"PodCount"`
+		PodsByPhase            map[string]int                   `json:"PodsByPhase"`
+		FailingPodCount        int                              `json:"FailingPodCount"`
+		FailingPods            []KubernetesFailingPod           `json:"FailingPods"`
+		PersistentVolumeClaims KubernetesVolumeClaimsStatistics `json:"PersistentVolumeClaims"`
+		Nodes                  []KubernetesNodeStatus           `json:"Nodes"`
+		Partial                bool                             `json:"Partial"`
+	}
+
+	// KubernetesFailingPod represents a pod that is failing inside a Kubernetes endpoint
+	KubernetesFailingPod struct {
+		Name         string `json:"Name"`
+		Namespace    string `json:"Namespace"`
+		Phase        string `json:"Phase"`
+		Reason       string `json:"Reason"`
+		RestartCount int32  `json:"RestartCount"`
+	}
+
+	// KubernetesNodeStatus represents the readiness and pressure conditions of a Kubernetes node
+	KubernetesNodeStatus struct {
+		Name               string `json:"Name"`
+		Ready              bool   `json:"Ready"`
+		MemoryPressure     bool   `json:"MemoryPressure"`
+		DiskPressure       bool   `json:"DiskPressure"`
+		PIDPressure        bool   `json:"PIDPressure"`
+		NetworkUnavailable bool   `json:"NetworkUnavailable"`
+	}
+
+	// KubernetesVolumeClaimsStatistics represents the persistent volume claims statistics of a Kubernetes endpoint
+	KubernetesVolumeClaimsStatistics struct {
+		Count          int   `json:"Count"`
+		BoundCount     int   `json:"BoundCount"`
+		PendingCount   int   `json:"PendingCount"`
+		RequestedBytes int64 `json:"RequestedBytes"`
+		CapacityBytes  int64 `json:"CapacityBytes"`
+		UsedBytes      int64 `json:"UsedBytes"`
 	}
 
 	// KubernetesConfiguration represents the configuration of a Kubernetes endpoint
@@ -429,8 +872,11 @@ type (
 		Password                string                           `json:"Password,omitempty"`
 		ManagementConfiguration *RegistryManagementConfiguration `json:"ManagementConfiguration"`
 		Gitlab                  GitlabRegistryData               `json:"Gitlab"`
+		Ecr                     *EcrRegistryData                 `json:"Ecr,omitempty"`
+		Acr                     *AzureCredentials                `json:"Acr,omitempty"`
 		UserAccessPolicies      UserAccessPolicies               `json:"UserAccessPolicies"`
 		TeamAccessPolicies      TeamAccessPolicies               `json:"TeamAccessPolicies"`
+		Retention               *RegistryRetention               `json:"Retention,omitempty"`
 
 		// Deprecated fields
 		// Deprecated in DBVersion == 18
@@ -451,6 +897,61 @@ type (
 		TLSConfig      TLSConfiguration `json:"TLSConfig"`
 	}
 
+	// RegistryRetention represents the retention policies of a registry. The policy defined for a repository
+	// applies instead of the default policy of the registry, defined without repository. When Enabled, the
+	// policies are executed periodically with the management configuration of the registry.
+	RegistryRetention struct {
+		Enabled    bool                      `json:"Enabled"`
+		Policies   []RegistryRetentionPolicy `json:"Policies"`
+		LastReport *RegistryRetentionReport  `json:"LastReport,omitempty"`
+	}
+
+	// RegistryRetentionPolicy represents the rules used to select the tags kept in the repositories of a registry.
+	// A tag is kept when it is one of the KeepLast most recent tags, when it matches KeepPattern, when it was
+	// created less than MaxAgeDays ago or when it is used by a running container or service of an endpoint
+	// snapshot. The other tags are deleted. A rule with a zero value is disabled.
+	RegistryRetentionPolicy struct {
+		Repository  string `json:"Repository,omitempty"`
+		KeepLast    int    `json:"KeepLast"`
+		KeepPattern string `json:"KeepPattern,omitempty"`
+		MaxAgeDays  int    `json:"MaxAgeDays"`
+	}
+
+	// RegistryRetentionReport represents the result of the execution of the retention policies of a registry.
+	// ReclaimableSize is the size of the blobs only referenced by the deleted manifests, freed once the
+	// garbage collection of the registry runs. UnknownUsageEndpoints lists the endpoints whose images in use
+	// cannot be determined, the policies are then only applied as a dry run.
+	RegistryRetentionReport struct {
+		DryRun                bool                                `json:"DryRun"`
+		StartedAt             int64                               `json:"StartedAt"`
+		FinishedAt            int64                               `json:"FinishedAt"`
+		Repositories          []RegistryRetentionRepositoryReport `json:"Repositories"`
+		ReclaimableSize       int64                               `json:"ReclaimableSize"`
+		UnknownUsageEndpoints []string                            `json:"UnknownUsageEndpoints,omitempty"`
+	}
+
+	// RegistryRetentionRepositoryReport represents the result of the execution of a retention policy on a repository
+	RegistryRetentionRepositoryReport struct {
+		Name            string                 `json:"Name"`
+		Tags            []RegistryRetentionTag `json:"Tags"`
+		ReclaimableSize int64                  `json:"ReclaimableSize"`
+		Error           string                 `json:"Error,omitempty"`
+	}
+
+	// RegistryRetentionTag represents a tag evaluated by a retention policy and the reason it is kept or deleted
+	RegistryRetentionTag struct {
+		Tag     string                  `json:"Tag"`
+		Digest  string                  `json:"Digest"`
+		Created int64                   `json:"Created"`
+		Size    int64                   `json:"Size"`
+		Delete  bool                    `json:"Delete"`
+		Reason  RegistryRetentionReason `json:"Reason"`
+		Error   string                  `json:"Error,omitempty"`
+	}
+
+	// RegistryRetentionReason represents the reason a tag is kept or deleted by a retention policy
+	RegistryRetentionReason string
+
 	// RegistryType represents a type of registry
 	RegistryType int
 
@@ -543,6 +1044,8 @@ type (
 		EnableEdgeComputeFeatures                 bool                 `json:"EnableEdgeComputeFeatures"`
 		UserSessionTimeout                        string               `json:"UserSessionTimeout"`
 		EnableTelemetry                           bool                 `json:"EnableTelemetry"`
+		ImageScanSettings                         ImageScanSettings    `json:"ImageScanSettings"`
+		ImageTrustKeys                            []ImageTrustKey      `json:"ImageTrustKeys"`
 
 		// Deprecated fields
 		DisplayDonationHeader       bool
@@ -552,22 +1055,28 @@ type (
 	// SnapshotJob represents a scheduled job that can create endpoint snapshots
 	SnapshotJob struct{}
 
-	// Stack represents a Docker stack created via docker stack deploy
+	// Stack represents a Docker stack created via docker stack deploy.
+	// CustomTemplateID and CustomTemplateVersion identify the custom template the stack was deployed from,
+	// CustomTemplateVariables keeps the values of its non secret variables to upgrade the stack to a newer version.
 	Stack struct {
-		ID              StackID          `json:"Id"`
-		Name            string           `json:"Name"`
-		Type            StackType        `json:"Type"`
-		EndpointID      EndpointID       `json:"EndpointId"`
-		SwarmID         string           `json:"SwarmId"`
-		EntryPoint      string           `json:"EntryPoint"`
-		Env             []Pair           `json:"Env"`
-		ResourceControl *ResourceControl `json:"ResourceControl"`
-		Status          StackStatus      `json:"Status"`
-		CreationDate    int64
-		CreatedBy       string
-		UpdateDate      int64
-		UpdatedBy       string
-		ProjectPath     string
+		ID                      StackID          `json:"Id"`
+		Name                    string           `json:"Name"`
+		Type                    StackType        `json:"Type"`
+		EndpointID              EndpointID       `json:"EndpointId"`
+		SwarmID                 string           `json:"SwarmId"`
+		EntryPoint              string           `json:"EntryPoint"`
+		Env                     []Pair           `json:"Env"`
+		ResourceControl         *ResourceControl `json:"ResourceControl"`
+		Status                  StackStatus      `json:"Status"`
+		CreationDate            int64
+		CreatedBy               string
+		UpdateDate              int64
+		UpdatedBy               string
+		ProjectPath             string
+		AutoUpdateImages        bool             `json:"AutoUpdateImages"`
+		CustomTemplateID        CustomTemplateID `json:"CustomTemplateId,omitempty"`
+		CustomTemplateVersion   int              `json:"CustomTemplateVersion,omitempty"`
+		CustomTemplateVariables []Pair           `json:"CustomTemplateVariables,omitempty"`
 	}
 
 	// StackID represents a stack identifier (it must be composed of Name + "_" + SwarmID to create a unique identifier)
@@ -579,6 +1088,13 @@ type (
 	// StackType represents the type of the stack (compose v2, stack deploy v3)
 	StackType int
 
+	// StackVulnerabilitySummary represents the vulnerabilities of the images running in a stack
+	StackVulnerabilitySummary struct {
+		Name    string                        `json:"Name"`
+		Summary map[VulnerabilitySeverity]int `json:"Summary"`
+		Images  []string                      `json:"Images"`
+	}
+
 	// Status represents the application status
 	Status struct {
 		Version string `json:"Version"`
@@ -691,6 +1207,43 @@ type (
 		StackFile string `json:"stackfile"`
 	}
 
+	// TemplateSource represents a source of app templates merged in the template catalog.
+	// Depending on its type, the template file is retrieved from URL, from FilePath inside the Git repository
+	// found at URL or from an uploaded file. A source without teams is visible to every user.
+	TemplateSource struct {
+		ID             TemplateSourceID   `json:"Id"`
+		Name           string             `json:"Name"`
+		Type           TemplateSourceType `json:"Type"`
+		Enabled        bool               `json:"Enabled"`
+		URL            string             `json:"URL,omitempty"`
+		ReferenceName  string             `json:"ReferenceName,omitempty"`
+		FilePath       string             `json:"FilePath,omitempty"`
+		Authentication bool               `json:"Authentication"`
+		Username       string             `json:"Username,omitempty"`
+		Password       string             `json:"Password,omitempty"`
+		TeamIDs        []TeamID           `json:"TeamIDs"`
+	}
+
+	// TemplateSourceCache represents the state of the cached copy of a template source, the last valid
+	// copy being served when the source cannot be reached. ETag and LastModified are the validators returned
+	// by the server of a URL source, ETag is the commit of the cached file for a Git source.
+	// Origin identifies the location the cached copy was retrieved from.
+	TemplateSourceCache struct {
+		Origin        string `json:"Origin"`
+		ETag          string `json:"ETag,omitempty"`
+		LastModified  string `json:"LastModified,omitempty"`
+		LastCheck     int64  `json:"LastCheck"`
+		LastRefresh   int64  `json:"LastRefresh"`
+		LastError     string `json:"LastError,omitempty"`
+		TemplateCount int    `json:"TemplateCount"`
+	}
+
+	// TemplateSourceID represents a template source identifier
+	TemplateSourceID int
+
+	// TemplateSourceType represents the type of a template source
+	TemplateSourceType int
+
 	// TemplateType represents the type of a template
 	TemplateType int
 
@@ -731,11 +1284,21 @@ type (
 		Credentials  string
 	}
 
-	// TunnelServerInfo represents information associated to the tunnel server
+	// TunnelServerInfo represents information associated to the tunnel server.
+	// The tunnel server listens on the tunnel port and on the rotation port, KeyListener is the listener
+	// serving the current key and encoded inside the Edge keys. When the key is rotated, the other listener
+	// keeps serving the previous key until RotationGracePeriodEnd so that agents using a previous Edge key
+	// can still connect.
 	TunnelServerInfo struct {
-		PrivateKeySeed string `json:"PrivateKeySeed"`
+		PrivateKeySeed         string         `json:"PrivateKeySeed"`
+		PreviousPrivateKeySeed string         `json:"PreviousPrivateKeySeed,omitempty"`
+		RotationGracePeriodEnd int64          `json:"RotationGracePeriodEnd,omitempty"`
+		KeyListener            TunnelListener `json:"KeyListener"`
 	}
 
+	// TunnelListener represents one of the listeners of the tunnel server
+	TunnelListener int
+
 	// User represents a user account
 	User struct {
 		ID         UserID   `json:"Id"`
@@ -766,6 +1329,9 @@ type (
 	// or a regular user
 	UserRole int
 
+	// VulnerabilitySeverity represents the severity of a vulnerability
+	VulnerabilitySeverity string
+
 	// Webhook represents a url webhook that can be used to update a service
 	Webhook struct {
 		ID          WebhookID   `json:"Id"`
@@ -787,10 +1353,11 @@ type (
 		ValidateFlags(flags *CLIFlags) error
 	}
 
-	// ComposeStackManager represents a service to manage Compose stacks
+	// ComposeStackManager represents a service to manage Compose stacks. The stacks are deployed with
+	// a Docker configuration containing the credentials of the specified registries, created for each deployment.
 	ComposeStackManager interface {
 		ComposeSyntaxMaxVersion() string
-		Up(stack *Stack, endpoint *Endpoint) error
+		Up(stack *Stack, endpoint *Endpoint, dockerhub *DockerHub, registries []Registry) error
 		Down(stack *Stack, endpoint *Endpoint) error
 	}
 
@@ -820,12 +1387,18 @@ type (
 
 		DockerHub() DockerHubService
 		CustomTemplate() CustomTemplateService
+		EdgeCheckin() EdgeCheckinService
+		EdgeEnrollmentRule() EdgeEnrollmentRuleService
+		EdgeEnrollmentToken() EdgeEnrollmentTokenService
 		EdgeGroup() EdgeGroupService
 		EdgeJob() EdgeJobService
 		EdgeStack() EdgeStackService
 		Endpoint() EndpointService
 		EndpointGroup() EndpointGroupService
 		EndpointRelation() EndpointRelationService
+		HousekeepingPolicy() HousekeepingPolicyService
+		ImageScan() ImageScanService
+		ImageStatus() ImageStatusService
 		Registry() RegistryService
 		ResourceControl() ResourceControlService
 		Role() RoleService
@@ -834,6 +1407,7 @@ type (
 		Tag() TagService
 		TeamMembership() TeamMembershipService
 		Team() TeamService
+		TemplateSource() TemplateSourceService
 		TunnelServer() TunnelServerService
 		User() UserService
 		Version() VersionService
@@ -847,6 +1421,7 @@ type (
 		EncodedPublicKey() string
 		PEMHeaders() (string, string)
 		CreateSignature(message string) (string, error)
+		SignMessage(message string) (string, error)
 	}
 
 	// DockerHubService represents a service for managing the DockerHub object
@@ -860,6 +1435,33 @@ type (
 		CreateSnapshot(endpoint *Endpoint) (*DockerSnapshot, error)
 	}
 
+	// EdgeCheckinService represents a service to manage the check-in state of Edge agents
+	EdgeCheckinService interface {
+		EdgeCheckins() ([]EdgeCheckin, error)
+		EdgeCheckin(endpointID EndpointID) (*EdgeCheckin, error)
+		UpdateEdgeCheckin(endpointID EndpointID, checkin *EdgeCheckin) error
+		DeleteEdgeCheckin(endpointID EndpointID) error
+	}
+
+	// EdgeEnrollmentRuleService represents a service to manage Edge enrollment rules
+	EdgeEnrollmentRuleService interface {
+		EdgeEnrollmentRules() ([]EdgeEnrollmentRule, error)
+		EdgeEnrollmentRule(ID EdgeEnrollmentRuleID) (*EdgeEnrollmentRule, error)
+		CreateEdgeEnrollmentRule(rule *EdgeEnrollmentRule) error
+		UpdateEdgeEnrollmentRule(ID EdgeEnrollmentRuleID, rule *EdgeEnrollmentRule) error
+		DeleteEdgeEnrollmentRule(ID EdgeEnrollmentRuleID) error
+	}
+
+	// EdgeEnrollmentTokenService represents a service to manage Edge enrollment tokens
+	EdgeEnrollmentTokenService interface {
+		EdgeEnrollmentTokens() ([]EdgeEnrollmentToken, error)
+		EdgeEnrollmentToken(ID EdgeEnrollmentTokenID) (*EdgeEnrollmentToken, error)
+		EdgeEnrollmentTokenByToken(token string) (*EdgeEnrollmentToken, error)
+		CreateEdgeEnrollmentToken(token *EdgeEnrollmentToken) error
+		UpdateEdgeEnrollmentToken(ID EdgeEnrollmentTokenID, token *EdgeEnrollmentToken) error
+		DeleteEdgeEnrollmentToken(ID EdgeEnrollmentTokenID) error
+	}
+
 	// EdgeGroupService represents a service to manage Edge groups
 	EdgeGroupService interface {
 		EdgeGroups() ([]EdgeGroup, error)
@@ -934,6 +1536,9 @@ type (
 		KeyPairFilesExist() (bool, error)
 		StoreKeyPair(private, public []byte, privatePEMHeader, publicPEMHeader string) error
 		LoadKeyPair() ([]byte, []byte, error)
+		SecretKeyFileExists() (bool, error)
+		StoreSecretKey(key []byte) error
+		LoadSecretKey() ([]byte, error)
 		WriteJSONToFile(path string, content interface{}) error
 		FileExists(path string) (bool, error)
 		StoreEdgeJobFileFromBytes(identifier string, data []byte) (string, error)
@@ -944,6 +1549,11 @@ type (
 		GetBinaryFolder() string
 		StoreCustomTemplateFileFromBytes(identifier, fileName string, data []byte) (string, error)
 		GetCustomTemplateProjectPath(identifier string) string
+		StoreCustomTemplateVersionFileFromBytes(identifier string, version int, data []byte) (string, error)
+		GetCustomTemplateVersionFilePath(identifier string, version int) string
+		GetCustomTemplateVersionsPath(identifier string) string
+		StoreTemplateSourceFileFromBytes(identifier, fileName string, data []byte) (string, error)
+		GetTemplateSourceProjectPath(identifier string) string
 		GetTemporaryPath() (string, error)
 	}
 
@@ -951,6 +1561,21 @@ type (
 	GitService interface {
 		ClonePublicRepository(repositoryURL, referenceName string, destination string) error
 		ClonePrivateRepositoryWithBasicAuth(repositoryURL, referenceName string, destination, username, password string) error
+		LatestCommitID(repositoryURL, referenceName, username, password string) (string, error)
+	}
+
+	// CustomTemplateGitSyncService represents a service used to keep the custom templates linked to a Git
+	// repository up to date with their repository
+	CustomTemplateGitSyncService interface {
+		Start()
+		SyncCustomTemplate(customTemplateID CustomTemplateID) (*CustomTemplate, error)
+	}
+
+	// EdgeStackGitSyncService represents a service used to keep the Edge stacks deployed from Git
+	// up to date with their repository
+	EdgeStackGitSyncService interface {
+		Start()
+		SyncEdgeStack(edgeStackID EdgeStackID) (*EdgeStack, error)
 	}
 
 	// Blocklist represents a service for blocking specific authentication tokens
@@ -959,6 +1584,53 @@ type (
 		AddTokenToBlocklist(token string)
 	}
 
+	// HousekeepingPolicyService represents a service to manage housekeeping policies
+	HousekeepingPolicyService interface {
+		HousekeepingPolicies() ([]HousekeepingPolicy, error)
+		HousekeepingPolicy(ID HousekeepingPolicyID) (*HousekeepingPolicy, error)
+		CreateHousekeepingPolicy(policy *HousekeepingPolicy) error
+		UpdateHousekeepingPolicy(ID HousekeepingPolicyID, policy *HousekeepingPolicy) error
+		DeleteHousekeepingPolicy(ID HousekeepingPolicyID) error
+	}
+
+	// HousekeepingService represents a service used to schedule and execute housekeeping policies
+	HousekeepingService interface {
+		Start() error
+		SchedulePolicy(policy *HousekeepingPolicy) error
+		UnschedulePolicy(ID HousekeepingPolicyID)
+		RunPolicy(ID HousekeepingPolicyID) ([]HousekeepingRun, error)
+		StartPolicyExecution(ID HousekeepingPolicyID) (*HousekeepingExecution, error)
+		PolicyExecution(ID HousekeepingPolicyID, executionID string) *HousekeepingExecution
+	}
+
+	// ImageScanService represents a service for managing image scan data
+	ImageScanService interface {
+		ImageScan(key string) (*ImageScan, error)
+		ImageScans() ([]ImageScan, error)
+		UpdateImageScan(key string, scan *ImageScan) error
+		DeleteImageScan(key string) error
+	}
+
+	// ImageStatusService represents a service for managing image status data
+	ImageStatusService interface {
+		ImageStatus(endpointID EndpointID) (*ImageStatus, error)
+		UpdateImageStatus(endpointID EndpointID, status *ImageStatus) error
+		DeleteImageStatus(endpointID EndpointID) error
+	}
+
+	// ImageTrustService represents a service used to verify the signatures of the images deployed on endpoints
+	ImageTrustService interface {
+		VerifyImages(endpoint *Endpoint, images []string) (map[string]string, error)
+		TrustedComposeFile(content []byte, env []Pair, endpoint *Endpoint) ([]byte, error)
+		TrustedStack(stack *Stack, endpoint *Endpoint) (*Stack, func(), error)
+	}
+
+	// ImageUpdateService represents a service used to detect newer images for the containers and services of endpoints
+	ImageUpdateService interface {
+		Start()
+		CheckEndpoint(endpoint *Endpoint) (*ImageStatus, error)
+	}
+
 	// JWTService represents a service for managing JWT tokens
 	JWTService interface {
 		GenerateToken(data *TokenData) (string, error)
@@ -1004,6 +1676,12 @@ type (
 		DeleteRegistry(ID RegistryID) error
 	}
 
+	// RegistryRetentionService represents a service used to execute the retention policies of the registries
+	RegistryRetentionService interface {
+		Start()
+		Run(registryID RegistryID, dryRun bool) (*RegistryRetentionReport, error)
+	}
+
 	// ResourceControlService represents a service for managing resource control data
 	ResourceControlService interface {
 		ResourceControl(ID ResourceControlID) (*ResourceControl, error)
@@ -1016,14 +1694,18 @@ type (
 
 	// ReverseTunnelService represensts a service used to manage reverse tunnel connections.
 	ReverseTunnelService interface {
-		StartTunnelServer(addr, port string, snapshotService SnapshotService) error
+		StartTunnelServer(addr, port, rotationPort string, snapshotService SnapshotService) error
+		RotateServerKey(gracePeriod time.Duration) error
 		GenerateEdgeKey(url, host string, endpointIdentifier int) string
 		SetTunnelStatusToActive(endpointID EndpointID)
 		SetTunnelStatusToRequired(endpointID EndpointID) error
 		SetTunnelStatusToIdle(endpointID EndpointID)
 		GetTunnelDetails(endpointID EndpointID) *TunnelDetails
+		UpdateCheckin(endpointID EndpointID, agentVersion, publicIP string) error
+		LastCheckin(endpointID EndpointID) int64
 		AddEdgeJob(endpointID EndpointID, edgeJob *EdgeJob)
 		RemoveEdgeJob(edgeJobID EdgeJobID)
+		RemoveEdgeJobFromEndpoint(endpointID EndpointID, edgeJobID EdgeJobID)
 	}
 
 	// RoleService represents a service for managing user roles
@@ -1034,6 +1716,15 @@ type (
 		UpdateRole(ID RoleID, role *Role) error
 	}
 
+	// SecretService represents a service used to encrypt the secrets stored in the database
+	SecretService interface {
+		GenerateKey() ([]byte, error)
+		ParseKey(key []byte) error
+		Encrypt(value string) (string, error)
+		Decrypt(value string) (string, error)
+		IsEncrypted(value string) bool
+	}
+
 	// SettingsService represents a service for managing application settings
 	SettingsService interface {
 		Settings() (*Settings, error)
@@ -1063,11 +1754,10 @@ type (
 		SnapshotEndpoint(endpoint *Endpoint) error
 	}
 
-	// SwarmStackManager represents a service to manage Swarm stacks
+	// SwarmStackManager represents a service to manage Swarm stacks. The stacks are deployed with
+	// a Docker configuration containing the credentials of the specified registries, created for each deployment.
 	SwarmStackManager interface {
-		Login(dockerhub *DockerHub, registries []Registry, endpoint *Endpoint)
-		Logout(endpoint *Endpoint) error
-		Deploy(stack *Stack, prune bool, endpoint *Endpoint) error
+		Deploy(stack *Stack, prune bool, endpoint *Endpoint, dockerhub *DockerHub, registries []Registry) error
 		Remove(stack *Stack, endpoint *Endpoint) error
 	}
 
@@ -1103,6 +1793,25 @@ type (
 		DeleteTeamMembershipByTeamID(teamID TeamID) error
 	}
 
+	// TemplateSourceService represents a service for managing template source data
+	TemplateSourceService interface {
+		GetNextIdentifier() int
+		TemplateSources() ([]TemplateSource, error)
+		TemplateSource(ID TemplateSourceID) (*TemplateSource, error)
+		CreateTemplateSource(templateSource *TemplateSource) error
+		UpdateTemplateSource(ID TemplateSourceID, templateSource *TemplateSource) error
+		DeleteTemplateSource(ID TemplateSourceID) error
+	}
+
+	// TemplateCatalogService represents a service used to retrieve the templates of the template sources
+	// through a cache
+	TemplateCatalogService interface {
+		SourceTemplates(source *TemplateSource) ([]Template, error)
+		RefreshSource(source *TemplateSource) error
+		SourceCache(source *TemplateSource) (*TemplateSourceCache, error)
+		RemoveSourceCache(source *TemplateSource) error
+	}
+
 	// TunnelServerService represents a service for managing data associated to the tunnel server
 	TunnelServerService interface {
 		Info() (*TunnelServerInfo, error)
@@ -1128,6 +1837,14 @@ type (
 		StoreInstanceID(ID string) error
 	}
 
+	// VulnerabilityScanService represents a service used to scan the images for vulnerabilities
+	VulnerabilityScanService interface {
+		Start()
+		ScanImage(image string, force bool) (*ImageScan, error)
+		EndpointSummary(endpoint *Endpoint) (*EndpointVulnerabilitySummary, error)
+		VerifyImages(images []string) (map[string]string, error)
+	}
+
 	// WebhookService represents a service for managing webhook data.
 	WebhookService interface {
 		Webhooks() ([]Webhook, error)
@@ -1158,6 +1875,8 @@ const (
 	PortainerAgentEdgeIDHeader = "X-PortainerAgent-EdgeID"
 	// HTTPResponseAgentPlatform represents the name of the header containing the Agent platform
 	HTTPResponseAgentPlatform = "Portainer-Agent-Platform"
+	// PortainerAgentVersionHeader represent the name of the header containing the version of an Edge agent
+	PortainerAgentVersionHeader = "X-PortainerAgent-Version"
 	// PortainerAgentTargetHeader represent the name of the header containing the target node name
 	PortainerAgentTargetHeader = "X-PortainerAgent-Target"
 	// PortainerAgentSignatureHeader represent the name of the header containing the digital signature
@@ -1205,6 +1924,15 @@ const (
 	EdgeJobLogsStatusCollected
 )
 
+const (
+	// EdgeJobRunSucceeded represents an Edge job run that exited with a zero exit code
+	EdgeJobRunSucceeded EdgeJobRunStatus = "success"
+	// EdgeJobRunFailed represents an Edge job run that exited with a non-zero exit code
+	EdgeJobRunFailed EdgeJobRunStatus = "failure"
+	// EdgeJobRunTimedOut represents an Edge job run that was stopped after exceeding its timeout
+	EdgeJobRunTimedOut EdgeJobRunStatus = "timeout"
+)
+
 const (
 	_ CustomTemplatePlatform = iota
 	// CustomTemplatePlatformLinux represents a custom template for linux
@@ -1213,6 +1941,19 @@ const (
 	CustomTemplatePlatformWindows
 )
 
+const (
+	// CustomTemplateVariableString represents a free text variable
+	CustomTemplateVariableString CustomTemplateVariableType = "string"
+	// CustomTemplateVariableNumber represents a numeric variable
+	CustomTemplateVariableNumber CustomTemplateVariableType = "number"
+	// CustomTemplateVariableBoolean represents a true or false variable
+	CustomTemplateVariableBoolean CustomTemplateVariableType = "boolean"
+	// CustomTemplateVariableSelect represents a variable whose value is one of its options
+	CustomTemplateVariableSelect CustomTemplateVariableType = "select"
+	// CustomTemplateVariableSecret represents a free text variable whose value is sensitive
+	CustomTemplateVariableSecret CustomTemplateVariableType = "secret"
+)
+
 const (
 	_ EdgeStackStatusType = iota
 	//StatusOk represents a successfully deployed edge stack
@@ -1223,6 +1964,36 @@ const (
 	StatusAcknowledged
 )
 
+const (
+	// EdgeStackDeploymentCompose represents an Edge stack deployed from a Compose file on Docker Edge endpoints
+	EdgeStackDeploymentCompose EdgeStackDeploymentType = iota
+	// EdgeStackDeploymentKubernetes represents an Edge stack deployed from a manifest on Kubernetes Edge endpoints
+	EdgeStackDeploymentKubernetes
+)
+
+const (
+	// TunnelMainListener represents the listener of the tunnel server bound to the tunnel port
+	TunnelMainListener TunnelListener = iota
+	// TunnelRotationListener represents the listener of the tunnel server bound to the rotation port
+	TunnelRotationListener
+)
+
+const (
+	// EdgeTunnelOpened represents the opening of an Edge reverse tunnel
+	EdgeTunnelOpened EdgeTunnelEventType = "open"
+	// EdgeTunnelClosed represents the closing of an Edge reverse tunnel
+	EdgeTunnelClosed EdgeTunnelEventType = "close"
+)
+
+const (
+	// EdgeEnrollmentPending represents an enrolled Edge endpoint waiting for an administrator approval
+	EdgeEnrollmentPending EdgeEnrollmentStatus = "pending"
+	// EdgeEnrollmentApproved represents an enrolled Edge endpoint approved by an administrator or a rule
+	EdgeEnrollmentApproved EdgeEnrollmentStatus = "approved"
+	// EdgeEnrollmentRejected represents an enrolled Edge endpoint rejected by an administrator
+	EdgeEnrollmentRejected EdgeEnrollmentStatus = "rejected"
+)
+
 const (
 	_ EndpointExtensionType = iota
 	// StoridgeEndpointExtension represents the Storidge extension
@@ -1279,6 +2050,23 @@ const (
 	CustomRegistry
 	// GitlabRegistry represents a gitlab registry
 	GitlabRegistry
+	// EcrRegistry represents an AWS ECR registry authenticated with tokens requested with AWS credentials
+	EcrRegistry
+	// AcrRegistry represents an ACR registry authenticated with tokens requested with an Azure service principal
+	AcrRegistry
+)
+
+const (
+	// RegistryRetentionKeepLast represents a tag kept as one of the most recent tags of the repository
+	RegistryRetentionKeepLast RegistryRetentionReason = "KeepLast"
+	// RegistryRetentionKeepPattern represents a tag kept because it matches the pattern of the policy
+	RegistryRetentionKeepPattern RegistryRetentionReason = "KeepPattern"
+	// RegistryRetentionRecent represents a tag kept because it is more recent than the maximum age of the policy
+	RegistryRetentionRecent RegistryRetentionReason = "Recent"
+	// RegistryRetentionInUse represents a tag kept because it is used by a running container or service
+	RegistryRetentionInUse RegistryRetentionReason = "InUse"
+	// RegistryRetentionExpired represents a tag deleted because no rule of the policy keeps it
+	RegistryRetentionExpired RegistryRetentionReason = "Expired"
 )
 
 const (
@@ -1336,6 +2124,16 @@ const (
 	EdgeStackTemplate
 )
 
+const (
+	_ TemplateSourceType = iota
+	// URLTemplateSource represents a template file retrieved from a URL
+	URLTemplateSource
+	// GitTemplateSource represents a template file retrieved from a Git repository
+	GitTemplateSource
+	// FileTemplateSource represents an uploaded template file
+	FileTemplateSource
+)
+
 const (
 	// TLSFileCA represents a TLS CA certificate file
 	TLSFileCA TLSFileType = iota
@@ -1359,6 +2157,74 @@ const (
 	ServiceWebhook
 )
 
+const (
+	// HousekeepingOperationImagePrune represents the pruning of unused images
+	HousekeepingOperationImagePrune HousekeepingOperation = "image"
+	// HousekeepingOperationVolumePrune represents the pruning of unused volumes
+	HousekeepingOperationVolumePrune HousekeepingOperation = "volume"
+	// HousekeepingOperationNetworkPrune represents the pruning of unused networks
+	HousekeepingOperationNetworkPrune HousekeepingOperation = "network"
+	// HousekeepingOperationBuildCachePrune represents the pruning of the builder cache
+	HousekeepingOperationBuildCachePrune HousekeepingOperation = "builder"
+)
+
+const (
+	// HousekeepingExecutionRunning represents an execution whose runs are in progress
+	HousekeepingExecutionRunning HousekeepingExecutionStatus = "running"
+	// HousekeepingExecutionCompleted represents an execution whose runs are recorded in the policy history
+	HousekeepingExecutionCompleted HousekeepingExecutionStatus = "completed"
+	// HousekeepingExecutionFailed represents an execution that could not be completed
+	HousekeepingExecutionFailed HousekeepingExecutionStatus = "failed"
+)
+
+const (
+	// TrivyScanner represents a Trivy server exposed through the Harbor scanner adapter API
+	TrivyScanner ImageScannerType = "trivy"
+	// ClairScanner represents a Clair server exposed through the Harbor scanner adapter API
+	ClairScanner ImageScannerType = "clair"
+)
+
+const (
+	// ImageStatusResourceContainer represents a container
+	ImageStatusResourceContainer ImageStatusResourceType = "container"
+	// ImageStatusResourceService represents a Swarm service
+	ImageStatusResourceService ImageStatusResourceType = "service"
+)
+
+const (
+	// ImageStatusUpToDate represents a resource running the latest digest of its image tag
+	ImageStatusUpToDate ImageStatusType = "updated"
+	// ImageStatusOutdated represents a resource running an older digest of its image tag
+	ImageStatusOutdated ImageStatusType = "outdated"
+	// ImageStatusSkipped represents a resource for which the status cannot be determined,
+	// such as a locally built image or an image referenced by digest only
+	ImageStatusSkipped ImageStatusType = "skipped"
+	// ImageStatusError represents a resource for which the registry could not be queried
+	ImageStatusError ImageStatusType = "error"
+)
+
+const (
+	// ImageTrustPolicyNone disables the verification of the image signatures
+	ImageTrustPolicyNone ImageTrustPolicy = ""
+	// ImageTrustPolicyAudit logs the deployment of the images whose signature cannot be verified
+	ImageTrustPolicyAudit ImageTrustPolicy = "audit"
+	// ImageTrustPolicyEnforce rejects the deployment of the images whose signature cannot be verified
+	ImageTrustPolicyEnforce ImageTrustPolicy = "enforce"
+)
+
+const (
+	// VulnerabilitySeverityUnknown represents a vulnerability whose severity is not assessed
+	VulnerabilitySeverityUnknown VulnerabilitySeverity = "Unknown"
+	// VulnerabilitySeverityLow represents a low severity vulnerability
+	VulnerabilitySeverityLow VulnerabilitySeverity = "Low"
+	// VulnerabilitySeverityMedium represents a medium severity vulnerability
+	VulnerabilitySeverityMedium VulnerabilitySeverity = "Medium"
+	// VulnerabilitySeverityHigh represents a high severity vulnerability
+	VulnerabilitySeverityHigh VulnerabilitySeverity = "High"
+	// VulnerabilitySeverityCritical represents a critical severity vulnerability
+	VulnerabilitySeverityCritical VulnerabilitySeverity = "Critical"
+)
+
 const (
 	// EdgeAgentIdle represents an idle state for a tunnel connected to an Edge endpoint.
 	EdgeAgentIdle string = "IDLE"
@@ -0,0 +1,307 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRequestTimeout = 30 * time.Second
+	// dockerHubDomain is the domain used in normalized image references for images hosted on the Docker Hub
+	dockerHubDomain = "docker.io"
+	// dockerHubRegistryHost is the host serving the registry API of the Docker Hub
+	dockerHubRegistryHost = "registry-1.docker.io"
+)
+
+// manifestMediaTypes are the manifest media types accepted when resolving a reference.
+// Manifest lists are listed first so that the digest of a multi-platform tag matches the
+// digest recorded by the Docker engine when the image was pulled.
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+var errUnauthorized = errors.New("Registry authentication failed")
+
+type (
+	// Client is a client for the Docker registry HTTP API V2
+	Client struct {
+		scheme     string
+		host       string
+		username   string
+		password   string
+		httpClient *http.Client
+		tokens     map[string]string
+		tokensMu   sync.Mutex
+	}
+
+	tokenResponse struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+)
+
+// NewClient returns a client for the registry available at the specified host.
+// The host can be prefixed with a scheme, HTTPS is used otherwise. Requests are
+// authenticated with the specified credentials when the registry requires it.
+func NewClient(host, username, password string) *Client {
+	scheme := "https"
+	if strings.HasPrefix(host, "http://") {
+		scheme = "http"
+	}
+
+	host = RegistryHost(host)
+	if host == dockerHubDomain {
+		host = dockerHubRegistryHost
+	}
+
+	return &Client{
+		scheme:   scheme,
+		host:     host,
+		username: username,
+		password: password,
+		httpClient: &http.Client{
+			Timeout: defaultRequestTimeout,
+		},
+		tokens: make(map[string]string),
+	}
+}
+
+// RegistryHost strips the scheme and the path of a registry URL
+func RegistryHost(registryURL string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(registryURL, "https://"), "http://")
+	host = strings.SplitN(host, "/", 2)[0]
+	return strings.ToLower(host)
+}
+
+// RegistryURL returns the base URL of the registry API serving the specified domain,
+// the Docker Hub API is used for the docker.io domain
+func RegistryURL(domain string) string {
+	scheme := "https"
+	if strings.HasPrefix(domain, "http://") {
+		scheme = "http"
+	}
+
+	host := RegistryHost(domain)
+	if host == dockerHubDomain {
+		host = dockerHubRegistryHost
+	}
+
+	return scheme + "://" + host
+}
+
+// ManifestDigest returns the content digest of the manifest referenced by a tag or a digest in a repository
+func (client *Client) ManifestDigest(repository, reference string) (string, error) {
+	req, err := http.NewRequest(http.MethodHead, client.url(fmt.Sprintf("/v2/%s/manifests/%s", repository, reference)), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+
+	resp, err := client.do(req, pullScope(repository))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unable to retrieve manifest %s:%s (status: %d)", repository, reference, resp.StatusCode)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("Registry did not return a digest for manifest %s:%s", repository, reference)
+	}
+
+	return digest, nil
+}
+
+func (client *Client) url(path string) string {
+	return fmt.Sprintf("%s://%s%s", client.scheme, client.host, path)
+}
+
+// do executes a request against the registry, answering the authentication challenge returned by the registry.
+// Registries are only reached over HTTP when their URL explicitly specifies it, credentials are never
+// sent in cleartext to a registry expected to be served over TLS.
+func (client *Client) do(req *http.Request, scope string) (*http.Response, error) {
+	client.authorize(req, scope)
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	err = client.answerChallenge(challenge, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	client.authorize(retry, scope)
+
+	resp, err = client.httpClient.Do(retry)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, errUnauthorized
+	}
+
+	return resp, nil
+}
+
+func (client *Client) authorize(req *http.Request, scope string) {
+	client.tokensMu.Lock()
+	token, ok := client.tokens[scope]
+	client.tokensMu.Unlock()
+
+	if ok && token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if client.username != "" {
+		req.SetBasicAuth(client.username, client.password)
+	}
+}
+
+func (client *Client) answerChallenge(challenge, scope string) error {
+	scheme, params := parseChallenge(challenge)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if client.username == "" {
+			return errUnauthorized
+		}
+		return nil
+	case "bearer":
+		token, err := client.requestToken(params, scope)
+		if err != nil {
+			return err
+		}
+
+		client.tokensMu.Lock()
+		client.tokens[scope] = token
+		client.tokensMu.Unlock()
+		return nil
+	}
+
+	return errUnauthorized
+}
+
+func (client *Client) requestToken(params map[string]string, scope string) (string, error) {
+	realm, ok := params["realm"]
+	if !ok {
+		return "", errors.New("Invalid registry authentication challenge")
+	}
+
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", err
+	}
+
+	if params["scope"] != "" {
+		scope = params["scope"]
+	}
+
+	query := tokenURL.Query()
+	query.Set("scope", scope)
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	if client.username != "" {
+		req.SetBasicAuth(client.username, client.password)
+	}
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errUnauthorized
+	}
+
+	var token tokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", err
+	}
+
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// pullScope returns the token scope required to read a repository
+func pullScope(repository string) string {
+	return fmt.Sprintf("repository:%s:pull", repository)
+}
+
+// parseChallenge parses a WWW-Authenticate header such as:
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"
+func parseChallenge(header string) (string, map[string]string) {
+	params := make(map[string]string)
+
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], params
+	}
+
+	for _, param := range splitChallengeParameters(parts[1]) {
+		keyValue := strings.SplitN(param, "=", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+		params[strings.ToLower(strings.TrimSpace(keyValue[0]))] = strings.Trim(strings.TrimSpace(keyValue[1]), `"`)
+	}
+
+	return parts[0], params
+}
+
+// splitChallengeParameters splits the parameters of a challenge on commas that are not quoted
+func splitChallengeParameters(value string) []string {
+	parameters := make([]string, 0)
+	quoted := false
+	start := 0
+
+	for idx, char := range value {
+		switch {
+		case char == '"':
+			quoted = !quoted
+		case char == ',' && !quoted:
+			parameters = append(parameters, value[start:idx])
+			start = idx + 1
+		}
+	}
+
+	return append(parameters, value[start:])
+}
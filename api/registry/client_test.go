@@ -0,0 +1,32 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Client_NoHTTPDowngrade(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _, hasCredentials := r.BasicAuth()
+		assert.True(t, hasCredentials)
+
+		w.Header().Set("Docker-Content-Digest", indexDigest)
+	}))
+	defer server.Close()
+
+	client := NewClient(RegistryHost(server.URL), "user", "pass")
+	_, err := client.ManifestDigest("app/api", "1.0")
+	assert.Error(t, err)
+	assert.Equal(t, 0, requests)
+
+	client = NewClient(server.URL, "user", "pass")
+	digest, err := client.ManifestDigest("app/api", "1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, indexDigest, digest)
+	assert.Equal(t, 1, requests)
+}
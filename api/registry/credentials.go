@@ -0,0 +1,160 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/crypto"
+	"github.com/docker/docker/api/types"
+)
+
+// Credentials represents the credentials used to authenticate against a registry
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// FindCredentials returns the credentials associated to the registry serving the specified domain.
+// The DockerHub credentials are used for the docker.io domain. Nil is returned when no
+// authenticated registry matches the domain.
+func FindCredentials(dataStore portainer.DataStore, domain string) (*Credentials, error) {
+	domain = RegistryHost(domain)
+
+	if isDockerHubDomain(domain) {
+		dockerhub, err := dataStore.DockerHub().DockerHub()
+		if err != nil {
+			return nil, err
+		}
+
+		if !dockerhub.Authentication {
+			return nil, nil
+		}
+
+		return &Credentials{Username: dockerhub.Username, Password: dockerhub.Password}, nil
+	}
+
+	registries, err := dataStore.Registry().Registries()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range registries {
+		registry := &registries[idx]
+		if RegistryHost(registry.URL) == domain && registry.Authentication {
+			return RegistryCredentials(registry)
+		}
+	}
+
+	return nil, nil
+}
+
+// NewClientForDomain returns a client for the registry serving the specified domain. When a registry defined
+// inside Portainer serves the domain, the client is built from its URL, credentials and TLS configuration, so
+// that registries configured over HTTP are reached over HTTP. The DockerHub credentials are used for the
+// docker.io domain, other registries are reached over HTTPS without credentials.
+func NewClientForDomain(dataStore portainer.DataStore, domain string) (*Client, error) {
+	if !isDockerHubDomain(RegistryHost(domain)) {
+		registry, err := findRegistry(dataStore, domain)
+		if err != nil {
+			return nil, err
+		}
+
+		if registry != nil {
+			return NewClientForRegistry(registry)
+		}
+	}
+
+	credentials, err := FindCredentials(dataStore, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if credentials == nil {
+		return NewClient(domain, "", ""), nil
+	}
+	return NewClient(domain, credentials.Username, credentials.Password), nil
+}
+
+// findRegistry returns the registry defined inside Portainer serving the specified domain,
+// authenticated registries are preferred. Nil is returned when no registry matches the domain.
+func findRegistry(dataStore portainer.DataStore, domain string) (*portainer.Registry, error) {
+	domain = RegistryHost(domain)
+
+	registries, err := dataStore.Registry().Registries()
+	if err != nil {
+		return nil, err
+	}
+
+	var match *portainer.Registry
+	for idx := range registries {
+		registry := &registries[idx]
+		if RegistryHost(registry.URL) != domain {
+			continue
+		}
+
+		if registry.Authentication {
+			return registry, nil
+		}
+
+		if match == nil {
+			match = registry
+		}
+	}
+
+	return match, nil
+}
+
+// NewClientForRegistry returns a client for a registry defined inside Portainer. The credentials and the TLS
+// configuration of the management configuration of the registry are used when it is defined.
+func NewClientForRegistry(registry *portainer.Registry) (*Client, error) {
+	config := registry.ManagementConfiguration
+
+	credentials := &Credentials{}
+	if config != nil && config.Authentication {
+		credentials = &Credentials{Username: config.Username, Password: config.Password}
+	} else if config == nil && registry.Authentication {
+		var err error
+		credentials, err = RegistryCredentials(registry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client := NewClient(registry.URL, credentials.Username, credentials.Password)
+
+	if config != nil && config.TLSConfig.TLS {
+		tlsConfig, err := crypto.CreateTLSConfigurationFromDisk(config.TLSConfig.TLSCACertPath, config.TLSConfig.TLSCertPath, config.TLSConfig.TLSKeyPath, config.TLSConfig.TLSSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+
+		client.httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		}
+	}
+
+	return client, nil
+}
+
+func isDockerHubDomain(host string) bool {
+	return host == dockerHubDomain || host == "index.docker.io" || host == dockerHubRegistryHost
+}
+
+// EncodeAuthConfig returns the credentials encoded as expected by the registry authentication header of the Docker API
+func EncodeAuthConfig(credentials *Credentials, serverAddress string) (string, error) {
+	authConfig := types.AuthConfig{
+		Username:      credentials.Username,
+		Password:      credentials.Password,
+		ServerAddress: serverAddress,
+	}
+
+	data, err := json.Marshal(authConfig)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/cloudogu/portainer-ce/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewClientForDomain(t *testing.T) {
+	store, teardown := testhelpers.NewDatastore(t)
+	defer teardown()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+
+		w.Header().Set("Docker-Content-Digest", indexDigest)
+	}))
+	defer server.Close()
+
+	domain := RegistryHost(server.URL)
+	assert.NoError(t, store.Registry().CreateRegistry(&portainer.Registry{
+		Name:               "local",
+		URL:                server.URL,
+		Authentication:     true,
+		Username:           "user",
+		Password:           "pass",
+		UserAccessPolicies: portainer.UserAccessPolicies{},
+		TeamAccessPolicies: portainer.TeamAccessPolicies{},
+	}))
+
+	// the registry is configured over HTTP, the client must not switch to HTTPS
+	client, err := NewClientForDomain(store, domain)
+	assert.NoError(t, err)
+	assert.Equal(t, "http", client.scheme)
+
+	digest, err := client.ManifestDigest("app/api", "1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, indexDigest, digest)
+
+	client, err = NewClientForDomain(store, "registry.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "https", client.scheme)
+	assert.Empty(t, client.username)
+}
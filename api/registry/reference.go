@@ -0,0 +1,57 @@
+package registry
+
+import (
+	"github.com/docker/distribution/reference"
+)
+
+// ImageReference represents a parsed image reference such as registry.example.com:5000/app/api:1.2@sha256:...
+type ImageReference struct {
+	// Domain is the registry domain, docker.io for the Docker Hub
+	Domain string
+	// Repository is the repository path inside the registry, e.g. library/nginx
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageReference parses and normalizes an image reference. When neither a tag
+// nor a digest is specified, the latest tag is used.
+func ParseImageReference(image string) (*ImageReference, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := &ImageReference{
+		Domain:     reference.Domain(named),
+		Repository: reference.Path(named),
+	}
+
+	if tagged, ok := named.(reference.Tagged); ok {
+		ref.Tag = tagged.Tag()
+	}
+
+	if digested, ok := named.(reference.Digested); ok {
+		ref.Digest = digested.Digest().String()
+	}
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+
+	return ref, nil
+}
+
+// Name returns the normalized name of the image, without tag nor digest
+func (ref *ImageReference) Name() string {
+	return ref.Domain + "/" + ref.Repository
+}
+
+// SameRepository returns true if the image reference points to the same repository
+func (ref *ImageReference) SameRepository(image string) bool {
+	other, err := ParseImageReference(image)
+	if err != nil {
+		return false
+	}
+	return other.Name() == ref.Name()
+}
@@ -0,0 +1,37 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseImageReference(t *testing.T) {
+	ref, err := ParseImageReference("nginx")
+	assert.NoError(t, err)
+	assert.Equal(t, &ImageReference{Domain: "docker.io", Repository: "library/nginx", Tag: "latest"}, ref)
+
+	ref, err = ParseImageReference("registry.example.com:5000/app/api:1.2@sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+	assert.NoError(t, err)
+	assert.Equal(t, "registry.example.com:5000", ref.Domain)
+	assert.Equal(t, "app/api", ref.Repository)
+	assert.Equal(t, "1.2", ref.Tag)
+	assert.Equal(t, "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", ref.Digest)
+
+	assert.True(t, ref.SameRepository("registry.example.com:5000/app/api:latest"))
+	assert.False(t, ref.SameRepository("app/api:1.2"))
+}
+
+func Test_parseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull,push"`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull,push",
+	}, params)
+
+	scheme, params = parseChallenge(`Basic`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Empty(t, params)
+}
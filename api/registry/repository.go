@@ -0,0 +1,360 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// pageSize is the number of entries requested for each page of the catalog and tag lists
+const pageSize = 100
+
+var (
+	// ErrNotFound is returned when a repository, a tag or a manifest does not exist in the registry
+	ErrNotFound = errors.New("The requested resource was not found in the registry")
+	// ErrDeletionDisabled is returned when the registry does not allow the deletion of manifests
+	ErrDeletionDisabled = errors.New("The registry does not allow the deletion of manifests")
+
+	nextLinkFormat = regexp.MustCompile(`<([^>]+)>;\s*rel="?next"?`)
+)
+
+type (
+	// Manifest represents an image manifest or a multi-platform image index. The size of an image is
+	// the compressed size of its configuration and layers, the size of an index is the sum of the
+	// sizes of its platform images.
+	Manifest struct {
+		Digest       string     `json:"Digest"`
+		MediaType    string     `json:"MediaType"`
+		Platform     *Platform  `json:"Platform,omitempty"`
+		Size         int64      `json:"Size"`
+		ConfigDigest string     `json:"ConfigDigest,omitempty"`
+		ConfigSize   int64      `json:"ConfigSize,omitempty"`
+		Layers       []Layer    `json:"Layers,omitempty"`
+		Manifests    []Manifest `json:"Manifests,omitempty"`
+	}
+
+	// Layer represents a layer of an image manifest
+	Layer struct {
+		Digest    string `json:"Digest"`
+		MediaType string `json:"MediaType"`
+		Size      int64  `json:"Size"`
+	}
+
+	// Platform represents the platform of an image referenced by an image index
+	Platform struct {
+		Architecture string `json:"Architecture"`
+		OS           string `json:"OS"`
+		Variant      string `json:"Variant,omitempty"`
+	}
+
+	// SharedManifestError is returned when a tag cannot be deleted without deleting
+	// other tags referencing the same manifest
+	SharedManifestError struct {
+		Tag  string
+		Tags []string
+	}
+
+	descriptor struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Size        int64             `json:"size"`
+		Annotations map[string]string `json:"annotations"`
+		Platform    *struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+			Variant      string `json:"variant"`
+		} `json:"platform"`
+	}
+
+	manifestResponse struct {
+		MediaType string       `json:"mediaType"`
+		Config    *descriptor  `json:"config"`
+		Layers    []descriptor `json:"layers"`
+		Manifests []descriptor `json:"manifests"`
+	}
+)
+
+func (err *SharedManifestError) Error() string {
+	return fmt.Sprintf("The manifest of tag %s is also referenced by the tags %s. Delete the manifest to remove all of them", err.Tag, strings.Join(err.Tags, ", "))
+}
+
+// Catalog returns the name of the repositories available in the registry
+func (client *Client) Catalog() ([]string, error) {
+	repositories := make([]string, 0)
+
+	err := client.list(fmt.Sprintf("/v2/_catalog?n=%d", pageSize), "registry:catalog:*", func(body io.Reader) error {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		err := json.NewDecoder(body).Decode(&page)
+		repositories = append(repositories, page.Repositories...)
+		return err
+	})
+
+	return repositories, err
+}
+
+// Tags returns the tags of a repository
+func (client *Client) Tags(repository string) ([]string, error) {
+	tags := make([]string, 0)
+
+	err := client.list(fmt.Sprintf("/v2/%s/tags/list?n=%d", repository, pageSize), pullScope(repository), func(body io.Reader) error {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err := json.NewDecoder(body).Decode(&page)
+		tags = append(tags, page.Tags...)
+		return err
+	})
+
+	return tags, err
+}
+
+// Manifest returns the manifest referenced by a tag or a digest in a repository. The manifests of the
+// platform images of an image index are retrieved to compute their size.
+func (client *Client) Manifest(repository, reference string) (*Manifest, error) {
+	content, digest, err := client.manifest(repository, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Digest:    digest,
+		MediaType: content.MediaType,
+	}
+
+	if len(content.Manifests) > 0 {
+		for _, entry := range content.Manifests {
+			platformManifest, err := client.Manifest(repository, entry.Digest)
+			if err != nil {
+				return nil, err
+			}
+
+			if entry.Platform != nil {
+				platformManifest.Platform = &Platform{
+					Architecture: entry.Platform.Architecture,
+					OS:           entry.Platform.OS,
+					Variant:      entry.Platform.Variant,
+				}
+			}
+
+			manifest.Size += platformManifest.Size
+			manifest.Manifests = append(manifest.Manifests, *platformManifest)
+		}
+		return manifest, nil
+	}
+
+	if content.Config != nil {
+		manifest.ConfigDigest = content.Config.Digest
+		manifest.ConfigSize = content.Config.Size
+		manifest.Size += content.Config.Size
+	}
+
+	for _, layer := range content.Layers {
+		manifest.Size += layer.Size
+		manifest.Layers = append(manifest.Layers, Layer{Digest: layer.Digest, MediaType: layer.MediaType, Size: layer.Size})
+	}
+
+	return manifest, nil
+}
+
+// ImageCreated returns the creation date recorded in the configuration blob of an image
+func (client *Client) ImageCreated(repository, configDigest string) (time.Time, error) {
+	req, err := http.NewRequest(http.MethodGet, client.url(fmt.Sprintf("/v2/%s/blobs/%s", repository, configDigest)), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	resp, err := client.do(req, pullScope(repository))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return time.Time{}, ErrNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("Unable to retrieve image configuration %s@%s (status: %d)", repository, configDigest, resp.StatusCode)
+	}
+
+	var config struct {
+		Created time.Time `json:"created"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&config)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return config.Created, nil
+}
+
+// DeleteManifest deletes a manifest from a repository. Every tag referencing the manifest is deleted.
+// The reference can be a tag, in which case the manifest it references is deleted.
+func (client *Client) DeleteManifest(repository, reference string) error {
+	digest := reference
+	if !strings.Contains(reference, ":") {
+		var err error
+		digest, err = client.ManifestDigest(repository, reference)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := client.delete(repository, digest)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted, http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrDeletionDisabled
+	}
+
+	return fmt.Errorf("Unable to delete manifest %s@%s (status: %d)", repository, digest, resp.StatusCode)
+}
+
+// DeleteTag deletes a tag from a repository by deleting the manifest it references. The tag is not
+// deleted when the manifest is referenced by other tags, as deleting a manifest deletes all its tags.
+// The manifest is never deleted by tag, registries deleting by tag remove the manifest of every tag.
+func (client *Client) DeleteTag(repository, tag string) error {
+	digest, err := client.ManifestDigest(repository, tag)
+	if err != nil {
+		return err
+	}
+
+	tags, err := client.Tags(repository)
+	if err != nil {
+		return err
+	}
+
+	sharedTags := make([]string, 0)
+	for _, otherTag := range tags {
+		if otherTag == tag {
+			continue
+		}
+
+		otherDigest, err := client.ManifestDigest(repository, otherTag)
+		if err != nil {
+			return err
+		}
+
+		if otherDigest == digest {
+			sharedTags = append(sharedTags, otherTag)
+		}
+	}
+
+	if len(sharedTags) > 0 {
+		return &SharedManifestError{Tag: tag, Tags: sharedTags}
+	}
+
+	return client.DeleteManifest(repository, digest)
+}
+
+func (client *Client) manifest(repository, reference string) (*manifestResponse, string, error) {
+	req, err := http.NewRequest(http.MethodGet, client.url(fmt.Sprintf("/v2/%s/manifests/%s", repository, reference)), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+
+	resp, err := client.do(req, pullScope(repository))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", ErrNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("Unable to retrieve manifest %s:%s (status: %d)", repository, reference, resp.StatusCode)
+	}
+
+	var content manifestResponse
+	err = json.NewDecoder(resp.Body).Decode(&content)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if content.MediaType == "" {
+		content.MediaType = strings.Split(resp.Header.Get("Content-Type"), ";")[0]
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" && strings.Contains(reference, ":") {
+		digest = reference
+	}
+
+	return &content, digest, nil
+}
+
+func (client *Client) delete(repository, reference string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodDelete, client.url(fmt.Sprintf("/v2/%s/manifests/%s", repository, reference)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.do(req, deleteScope(repository))
+}
+
+// list retrieves each page of a paginated list, following the Link header returned by the registry
+func (client *Client) list(path, scope string, decodePage func(body io.Reader) error) error {
+	for path != "" {
+		req, err := http.NewRequest(http.MethodGet, client.url(path), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.do(req, scope)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			return ErrNotFound
+		} else if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("Unable to retrieve %s (status: %d)", path, resp.StatusCode)
+		}
+
+		err = decodePage(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		path = nextPage(resp.Header.Get("Link"))
+	}
+
+	return nil
+}
+
+// nextPage returns the path of the next page referenced by a Link header such as:
+// </v2/_catalog?last=library%2Fnginx&n=100>; rel="next"
+func nextPage(header string) string {
+	match := nextLinkFormat.FindStringSubmatch(header)
+	if match == nil {
+		return ""
+	}
+
+	link := match[1]
+	if idx := strings.Index(link, "/v2/"); idx > 0 {
+		link = link[idx:]
+	}
+	return link
+}
+
+// deleteScope returns the token scope required to delete the manifests of a repository
+func deleteScope(repository string) string {
+	return fmt.Sprintf("repository:%s:delete", repository)
+}
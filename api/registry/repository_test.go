@@ -0,0 +1,135 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	indexDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	amd64Digest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	arm64Digest = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+)
+
+// newTestRegistry returns a registry serving the repository app/api with an image index tagged
+// 1.0 and latest, and the platform image amd64 tagged amd64. As on most registries, deleting a manifest
+// by tag deletes the manifest and every tag referencing it, these deletions are recorded with a tag: prefix.
+func newTestRegistry(deleted *[]string) *httptest.Server {
+	tags := map[string]string{"1.0": indexDigest, "latest": indexDigest, "amd64": amd64Digest}
+	manifests := map[string]string{
+		indexDigest: fmt.Sprintf(`{"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[
+			{"digest":"%s","platform":{"architecture":"amd64","os":"linux"}},
+			{"digest":"%s","platform":{"architecture":"arm64","os":"linux","variant":"v8"}}]}`, amd64Digest, arm64Digest),
+		amd64Digest: `{"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"sha256:c1","size":100},"layers":[{"digest":"sha256:l1","size":1000},{"digest":"sha256:l2","size":2000}]}`,
+		arm64Digest: `{"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"sha256:c2","size":200},"layers":[{"digest":"sha256:l3","size":500}]}`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/_catalog" && r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/_catalog?last=app%2Fapi&n=100>; rel="next"`)
+			fmt.Fprint(w, `{"repositories":["app/api"]}`)
+		case r.URL.Path == "/v2/_catalog":
+			fmt.Fprint(w, `{"repositories":["app/web"]}`)
+		case r.URL.Path == "/v2/app/api/tags/list":
+			fmt.Fprint(w, `{"name":"app/api","tags":["1.0","amd64","latest"]}`)
+		case strings.HasPrefix(r.URL.Path, "/v2/app/api/manifests/"):
+			reference := strings.TrimPrefix(r.URL.Path, "/v2/app/api/manifests/")
+			if digest, ok := tags[reference]; ok {
+				if r.Method == http.MethodDelete {
+					*deleted = append(*deleted, "tag:"+reference)
+					w.WriteHeader(http.StatusAccepted)
+					return
+				}
+				reference = digest
+			}
+
+			content, ok := manifests[reference]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			if r.Method == http.MethodDelete {
+				*deleted = append(*deleted, reference)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+
+			w.Header().Set("Docker-Content-Digest", reference)
+			fmt.Fprint(w, content)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func Test_ClientCatalogAndTags(t *testing.T) {
+	server := newTestRegistry(nil)
+	defer server.Close()
+
+	client := NewClient(server.URL, "", "")
+
+	repositories, err := client.Catalog()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app/api", "app/web"}, repositories)
+
+	tags, err := client.Tags("app/api")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.0", "amd64", "latest"}, tags)
+
+	_, err = client.Tags("missing")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func Test_ClientManifest(t *testing.T) {
+	server := newTestRegistry(nil)
+	defer server.Close()
+
+	client := NewClient(server.URL, "", "")
+
+	manifest, err := client.Manifest("app/api", "latest")
+	assert.NoError(t, err)
+	assert.Equal(t, indexDigest, manifest.Digest)
+	assert.Equal(t, int64(3800), manifest.Size)
+	assert.Len(t, manifest.Manifests, 2)
+	assert.Equal(t, &Platform{Architecture: "arm64", OS: "linux", Variant: "v8"}, manifest.Manifests[1].Platform)
+	assert.Equal(t, int64(3100), manifest.Manifests[0].Size)
+	assert.Equal(t, "sha256:c1", manifest.Manifests[0].ConfigDigest)
+	assert.Len(t, manifest.Manifests[0].Layers, 2)
+
+	_, err = client.Manifest("app/api", "missing")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func Test_ClientDelete(t *testing.T) {
+	deleted := []string{}
+	server := newTestRegistry(&deleted)
+	defer server.Close()
+
+	client := NewClient(server.URL, "", "")
+
+	err := client.DeleteTag("app/api", "latest")
+	assert.IsType(t, &SharedManifestError{}, err)
+	assert.Equal(t, []string{"1.0"}, err.(*SharedManifestError).Tags)
+	assert.Empty(t, deleted)
+
+	err = client.DeleteTag("app/api", "amd64")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{amd64Digest}, deleted)
+
+	err = client.DeleteManifest("app/api", "1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{amd64Digest, indexDigest}, deleted)
+}
+
+func Test_nextPage(t *testing.T) {
+	assert.Equal(t, "/v2/_catalog?last=b&n=100", nextPage(`</v2/_catalog?last=b&n=100>; rel="next"`))
+	assert.Equal(t, "/v2/app/tags/list?last=1.0&n=100", nextPage(`<https://registry.example.com/v2/app/tags/list?last=1.0&n=100>; rel="next"`))
+	assert.Equal(t, "", nextPage(""))
+}
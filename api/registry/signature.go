@@ -0,0 +1,84 @@
+package registry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	// cosignSignatureAnnotation is the layer annotation containing the base64 encoded signature of the layer payload
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// maxSignaturePayloadSize limits the size of the signature payloads read from the registry
+	maxSignaturePayloadSize = 1 << 20
+)
+
+// Signature represents a cosign signature attached to an image digest. The payload is a simple
+// signing document referencing the signed digest.
+type Signature struct {
+	Payload   []byte
+	Signature []byte
+}
+
+// Signatures returns the cosign signatures attached to an image digest. Cosign stores the signatures of a
+// digest in the manifest tagged sha256-<hex>.sig in the repository of the image, each layer being a signed
+// payload with its signature in an annotation. An empty list is returned when the digest is not signed.
+func (client *Client) Signatures(repository, digest string) ([]Signature, error) {
+	content, _, err := client.manifest(repository, signatureTag(digest))
+	if err == ErrNotFound {
+		return []Signature{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	signatures := make([]Signature, 0, len(content.Layers))
+	for _, layer := range content.Layers {
+		encodedSignature, ok := layer.Annotations[cosignSignatureAnnotation]
+		if !ok {
+			continue
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+		if err != nil {
+			continue
+		}
+
+		payload, err := client.blob(repository, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		signatures = append(signatures, Signature{Payload: payload, Signature: signature})
+	}
+
+	return signatures, nil
+}
+
+// signatureTag returns the tag under which cosign stores the signatures of a digest
+func signatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+func (client *Client) blob(repository, digest string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, client.url(fmt.Sprintf("/v2/%s/blobs/%s", repository, digest)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.do(req, pullScope(repository))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to retrieve blob %s@%s (status: %d)", repository, digest, resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxSignaturePayloadSize))
+}
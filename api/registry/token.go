@@ -0,0 +1,365 @@
+package registry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+)
+
+const (
+	// tokenExpiryMargin is the remaining validity under which a cached token is renewed
+	tokenExpiryMargin = 5 * time.Minute
+	// acrDefaultTokenLifetime is used when the expiry of an ACR refresh token cannot be read
+	acrDefaultTokenLifetime = time.Hour
+	// acrTokenUsername is the username expected by ACR when logging in with a refresh token
+	acrTokenUsername = "00000000-0000-0000-0000-000000000000"
+	ecrTarget        = "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken"
+)
+
+var (
+	errMissingCloudCredentials = errors.New("The cloud credentials of the registry are not defined")
+
+	ecrRegionFormat = regexp.MustCompile(`\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+	defaultTokenCache = newTokenCache()
+)
+
+type (
+	cachedToken struct {
+		credentials Credentials
+		expiresAt   time.Time
+	}
+
+	// tokenCache mints the short-lived credentials of the cloud registries and
+	// keeps them until they are about to expire. The tokens of a registry are requested
+	// under the lock of the registry, mu only protects the maps of the cache.
+	tokenCache struct {
+		mu            sync.Mutex
+		tokens        map[string]cachedToken
+		registryLocks map[portainer.RegistryID]*sync.Mutex
+		httpClient    *http.Client
+		now           func() time.Time
+		// ecrEndpoint returns the URL of the ECR API for a region
+		ecrEndpoint func(region string) string
+		// azureLoginEndpoint returns the URL of the Azure AD token endpoint of a tenant
+		azureLoginEndpoint func(tenantID string) string
+	}
+)
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		tokens:        make(map[string]cachedToken),
+		registryLocks: make(map[portainer.RegistryID]*sync.Mutex),
+		httpClient:    &http.Client{Timeout: defaultRequestTimeout},
+		now:           time.Now,
+		ecrEndpoint: func(region string) string {
+			return fmt.Sprintf("https://api.ecr.%s.amazonaws.com/", region)
+		},
+		azureLoginEndpoint: func(tenantID string) string {
+			return fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", url.PathEscape(tenantID))
+		},
+	}
+}
+
+// RegistryCredentials returns the credentials used to authenticate against a registry. Short-lived tokens are
+// requested with the cloud credentials of the ECR and ACR registries and cached until they are about to expire.
+// Nil is returned when the registry does not use authentication.
+func RegistryCredentials(registry *portainer.Registry) (*Credentials, error) {
+	return defaultTokenCache.credentials(registry)
+}
+
+func (cache *tokenCache) credentials(registry *portainer.Registry) (*Credentials, error) {
+	switch registry.Type {
+	case portainer.EcrRegistry, portainer.AcrRegistry:
+	default:
+		if !registry.Authentication {
+			return nil, nil
+		}
+		return &Credentials{Username: registry.Username, Password: registry.Password}, nil
+	}
+
+	key, err := tokenKey(registry)
+	if err != nil {
+		return nil, err
+	}
+
+	// concurrent requests for the credentials of a registry wait for the token requested by the first one
+	registryLock := cache.registryLock(registry.ID)
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if credentials, ok := cache.cachedCredentials(key); ok {
+		return credentials, nil
+	}
+
+	var token *cachedToken
+	if registry.Type == portainer.EcrRegistry {
+		token, err = cache.requestEcrToken(registry)
+	} else {
+		token, err = cache.requestAcrToken(registry)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// the tokens requested with previous credentials of the registry are discarded
+	prefix := fmt.Sprintf("%d:", registry.ID)
+	for cachedKey := range cache.tokens {
+		if strings.HasPrefix(cachedKey, prefix) {
+			delete(cache.tokens, cachedKey)
+		}
+	}
+
+	cache.tokens[key] = *token
+	credentials := token.credentials
+	return &credentials, nil
+}
+
+func (cache *tokenCache) registryLock(registryID portainer.RegistryID) *sync.Mutex {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	lock, ok := cache.registryLocks[registryID]
+	if !ok {
+		lock = &sync.Mutex{}
+		cache.registryLocks[registryID] = lock
+	}
+	return lock
+}
+
+// cachedCredentials returns the credentials of a cached token that is not about to expire
+func (cache *tokenCache) cachedCredentials(key string) (*Credentials, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	token, ok := cache.tokens[key]
+	if !ok || !cache.now().Add(tokenExpiryMargin).Before(token.expiresAt) {
+		return nil, false
+	}
+
+	credentials := token.credentials
+	return &credentials, true
+}
+
+// tokenKey identifies the token of a registry. The cloud credentials are part of the key
+// so that a token is requested again when they are updated.
+func tokenKey(registry *portainer.Registry) (string, error) {
+	var cloudCredentials interface{}
+	if registry.Type == portainer.EcrRegistry {
+		if registry.Ecr == nil {
+			return "", errMissingCloudCredentials
+		}
+		cloudCredentials = registry.Ecr
+	} else {
+		if registry.Acr == nil {
+			return "", errMissingCloudCredentials
+		}
+		cloudCredentials = registry.Acr
+	}
+
+	data, err := json.Marshal(cloudCredentials)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(append([]byte(registry.URL+"\n"), data...))
+	return fmt.Sprintf("%d:%x", registry.ID, hash), nil
+}
+
+// requestEcrToken calls the GetAuthorizationToken operation of the ECR API. The authorization
+// token is the base64 encoding of the username and the password separated by a colon.
+func (cache *tokenCache) requestEcrToken(registry *portainer.Registry) (*cachedToken, error) {
+	region := registry.Ecr.Region
+	if region == "" {
+		match := ecrRegionFormat.FindStringSubmatch(RegistryHost(registry.URL))
+		if match == nil {
+			return nil, errors.New("Unable to find the AWS region of the ECR registry, the region must be specified")
+		}
+		region = match[1]
+	}
+
+	body := []byte("{}")
+	req, err := http.NewRequest(http.MethodPost, cache.ecrEndpoint(region), strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", ecrTarget)
+	signAWSRequest(req, body, region, "ecr", registry.Ecr.AccessKeyID, registry.Ecr.SecretAccessKey, cache.now())
+
+	resp, err := cache.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to retrieve an ECR authorization token (status: %d)", resp.StatusCode)
+	}
+
+	var content struct {
+		AuthorizationData []struct {
+			AuthorizationToken string  `json:"authorizationToken"`
+			ExpiresAt          float64 `json:"expiresAt"`
+		} `json:"authorizationData"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&content)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content.AuthorizationData) == 0 {
+		return nil, errors.New("No authorization token returned by the ECR API")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(content.AuthorizationData[0].AuthorizationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("Invalid ECR authorization token")
+	}
+
+	return &cachedToken{
+		credentials: Credentials{Username: parts[0], Password: parts[1]},
+		expiresAt:   time.Unix(int64(content.AuthorizationData[0].ExpiresAt), 0),
+	}, nil
+}
+
+// requestAcrToken requests an Azure AD access token for the service principal of the registry
+// and exchanges it for an ACR refresh token, used as the password of the registry. The exchange
+// always uses HTTPS as the Azure AD token is sent to the registry.
+func (cache *tokenCache) requestAcrToken(registry *portainer.Registry) (*cachedToken, error) {
+	credentials := registry.Acr
+
+	var aadToken struct {
+		AccessToken string `json:"access_token"`
+	}
+	err := cache.postForm(cache.azureLoginEndpoint(credentials.TenantID), url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {credentials.ApplicationID},
+		"client_secret": {credentials.AuthenticationKey},
+		"scope":         {"https://management.azure.com/.default"},
+	}, &aadToken)
+	if err != nil {
+		return nil, err
+	}
+
+	host := RegistryHost(registry.URL)
+
+	var acrToken struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	err = cache.postForm(fmt.Sprintf("https://%s/oauth2/exchange", host), url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {host},
+		"tenant":       {credentials.TenantID},
+		"access_token": {aadToken.AccessToken},
+	}, &acrToken)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt, ok := jwtExpiry(acrToken.RefreshToken)
+	if !ok {
+		expiresAt = cache.now().Add(acrDefaultTokenLifetime)
+	}
+
+	return &cachedToken{
+		credentials: Credentials{Username: acrTokenUsername, Password: acrToken.RefreshToken},
+		expiresAt:   expiresAt,
+	}, nil
+}
+
+func (cache *tokenCache) postForm(endpoint string, form url.Values, target interface{}) error {
+	resp, err := cache.httpClient.PostForm(endpoint, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unable to retrieve a token from %s (status: %d)", endpoint, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// jwtExpiry returns the expiry recorded in the claims of a JWT, without verifying its signature
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Exp, 0), true
+}
+
+// signAWSRequest signs a request to an AWS API with the signature version 4
+func signAWSRequest(req *http.Request, body []byte, region, service, accessKeyID, secretAccessKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := now.UTC().Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := "content-type;host;x-amz-date;x-amz-target"
+	canonicalHeaders := fmt.Sprintf("content-type:%s\nhost:%s\nx-amz-date:%s\nx-amz-target:%s\n",
+		req.Header.Get("Content-Type"), req.URL.Host, amzDate, req.Header.Get("X-Amz-Target"))
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.RawQuery, canonicalHeaders, signedHeaders, sha256Hex(body)}, "\n")
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
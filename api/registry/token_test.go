@@ -0,0 +1,171 @@
+package registry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	portainer "github.com/cloudogu/portainer-ce/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTokenCache(server *httptest.Server, now *time.Time) *tokenCache {
+	cache := newTokenCache()
+	cache.now = func() time.Time { return *now }
+	cache.ecrEndpoint = func(region string) string { return server.URL + "/ecr/" + region }
+	cache.azureLoginEndpoint = func(tenantID string) string { return server.URL + "/aad/" + tenantID }
+	return cache
+}
+
+func Test_tokenCache_Ecr(t *testing.T) {
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/ecr/eu-west-1", r.URL.Path)
+		assert.Equal(t, ecrTarget, r.Header.Get("X-Amz-Target"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/20210630/eu-west-1/ecr/aws4_request"))
+
+		token := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("AWS:password-%d", requests)))
+		fmt.Fprintf(w, `{"authorizationData":[{"authorizationToken":"%s","expiresAt":%d.5}]}`, token, now.Add(12*time.Hour).Unix())
+	}))
+	defer server.Close()
+
+	cache := newTestTokenCache(server, &now)
+	registry := &portainer.Registry{
+		ID:             1,
+		Type:           portainer.EcrRegistry,
+		URL:            "123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+		Authentication: true,
+		Ecr:            &portainer.EcrRegistryData{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	}
+
+	credentials, err := cache.credentials(registry)
+	assert.NoError(t, err)
+	assert.Equal(t, &Credentials{Username: "AWS", Password: "password-1"}, credentials)
+
+	now = now.Add(11 * time.Hour)
+	credentials, err = cache.credentials(registry)
+	assert.NoError(t, err)
+	assert.Equal(t, "password-1", credentials.Password)
+	assert.Equal(t, 1, requests)
+
+	now = now.Add(58 * time.Minute)
+	credentials, err = cache.credentials(registry)
+	assert.NoError(t, err)
+	assert.Equal(t, "password-2", credentials.Password)
+	assert.Equal(t, 2, requests)
+}
+
+func Test_tokenCache_Acr(t *testing.T) {
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, now.Add(3*time.Hour).Unix())))
+	refreshToken := "header." + claims + ".signature"
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/aad/tenant":
+			assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+			assert.Equal(t, "app", r.Form.Get("client_id"))
+			assert.NotEmpty(t, r.Form.Get("client_secret"))
+			fmt.Fprint(w, `{"access_token":"aad-token","expires_in":3600}`)
+		case "/oauth2/exchange":
+			assert.Equal(t, "aad-token", r.Form.Get("access_token"))
+			assert.Equal(t, "tenant", r.Form.Get("tenant"))
+			fmt.Fprintf(w, `{"refresh_token":"%s"}`, refreshToken)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cache := newTestTokenCache(server, &now)
+	cache.httpClient = server.Client()
+	// the Azure AD token is exchanged over HTTPS even when the registry is defined with HTTP
+	registry := &portainer.Registry{
+		ID:             2,
+		Type:           portainer.AcrRegistry,
+		URL:            "http://" + RegistryHost(server.URL),
+		Authentication: true,
+		Acr:            &portainer.AzureCredentials{TenantID: "tenant", ApplicationID: "app", AuthenticationKey: "key"},
+	}
+
+	credentials, err := cache.credentials(registry)
+	assert.NoError(t, err)
+	assert.Equal(t, &Credentials{Username: acrTokenUsername, Password: refreshToken}, credentials)
+	assert.True(t, now.Add(3*time.Hour).Equal(cache.tokens[mustTokenKey(t, registry)].expiresAt))
+
+	registry.Acr.AuthenticationKey = "rotated"
+	_, err = cache.credentials(registry)
+	assert.NoError(t, err)
+	assert.Len(t, cache.tokens, 1)
+	assert.Contains(t, cache.tokens, mustTokenKey(t, registry))
+}
+
+func Test_tokenCache_ConcurrentRequests(t *testing.T) {
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	requests := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		// the token requests of the first registry are blocked until the end of the test
+		if r.URL.Path == "/ecr/eu-west-1" {
+			<-release
+		}
+
+		token := base64.StdEncoding.EncodeToString([]byte("AWS:password"))
+		fmt.Fprintf(w, `{"authorizationData":[{"authorizationToken":"%s","expiresAt":%d}]}`, token, now.Add(12*time.Hour).Unix())
+	}))
+	defer server.Close()
+
+	cache := newTestTokenCache(server, &now)
+	blockedRegistry := &portainer.Registry{ID: 1, Type: portainer.EcrRegistry, URL: "1.dkr.ecr.eu-west-1.amazonaws.com", Ecr: &portainer.EcrRegistryData{AccessKeyID: "AKID"}}
+	registry := &portainer.Registry{ID: 2, Type: portainer.EcrRegistry, URL: "2.dkr.ecr.eu-central-1.amazonaws.com", Ecr: &portainer.EcrRegistryData{AccessKeyID: "AKID"}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.credentials(blockedRegistry)
+			assert.NoError(t, err)
+		}()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cache.credentials(registry)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the token request of a registry was blocked by the token request of another registry")
+	}
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 1, requests["/ecr/eu-west-1"], "concurrent requests for a registry must share the same token")
+	assert.Equal(t, 1, requests["/ecr/eu-central-1"])
+}
+
+func mustTokenKey(t *testing.T, registry *portainer.Registry) string {
+	key, err := tokenKey(registry)
+	assert.NoError(t, err)
+	return key
+}